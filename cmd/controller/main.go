@@ -2,16 +2,27 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
+	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"aks-health-monitor/pkg/azure"
 	"aks-health-monitor/pkg/config"
 	"aks-health-monitor/pkg/controller"
 	"aks-health-monitor/pkg/metrics"
+	"aks-health-monitor/pkg/runtimeinfo"
+	"aks-health-monitor/pkg/server"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog/v2"
@@ -26,6 +37,14 @@ func main() {
 	}
 
 	configPath := flag.String("config", "/etc/config/config.yaml", "path to configuration file (mounted from ConfigMap)")
+	configReloadInterval := flag.Duration("config-reload-interval", 10*time.Second, "how often the configuration file is checked for changes; 0 disables reloading")
+	dryRun := flag.Bool("dry-run", false, "evaluate thresholds and log the aborts that would be sent without calling Azure; overrides dryRun in the configuration file")
+	metricsAddr := flag.String("metrics-addr", "", "bind address for the Prometheus metrics listener (e.g. :8080); overrides server.metricsAddress in the configuration file")
+	healthAddr := flag.String("health-addr", "", "bind address for the health listener serving /healthz, /readyz and /status (e.g. :8081); overrides server.healthAddress in the configuration file")
+	enableLeaderElection := flag.Bool("enable-leader-election", false, "run the poll loop only while holding a Lease in the controller namespace, so several replicas can run with one active")
+	namespace := flag.String("namespace", "", "namespace the controller runs in (defaults to POD_NAMESPACE, the service account namespace, or the kubeconfig context namespace)")
+	once := flag.Bool("once", false, "collect metrics and evaluate thresholds once, print the result as JSON and exit: 0 when healthy, 2 on violations, 1 on errors")
+	withOperationCheck := flag.Bool("with-operation-check", false, "with --once, also read the cluster operation status from Azure and abort the operation on violations, as a cycle of the poll loop would")
 	flag.Parse()
 
 	klog.InitFlags(nil)
@@ -36,18 +55,58 @@ func main() {
 	if err != nil {
 		klog.Fatalf("Failed to load configuration: %v", err)
 	}
+	applyFlags(cfg, *dryRun, *metricsAddr, *healthAddr)
 
-	// Create Kubernetes client
-	kubeClient, err := createKubernetesClient(*kubeconfig)
+	// Create Kubernetes client, counting API calls for the collection budget
+	apiCalls := &metrics.APICallCounter{}
+	warnings := metrics.NewWarningRecorder()
+	kubeClient, metadataClient, err := createKubernetesClient(*kubeconfig, apiCalls, warnings)
 	if err != nil {
 		klog.Fatalf("Failed to create Kubernetes client: %v", err)
 	}
 
+	// Resolve the controller's own namespace and pod name for self-referential writes
+	identity := runtimeinfo.Resolve(runtimeinfo.Options{
+		Namespace:        *namespace,
+		DefaultNamespace: kubeconfigNamespace(*kubeconfig),
+	})
+	if identity.Namespace == "" {
+		klog.Warning("Controller namespace is unknown; features that write objects about the controller will fail (set --namespace or POD_NAMESPACE)")
+	} else {
+		klog.Infof("Controller running as pod %q in namespace %q (from %s)", identity.PodName, identity.Namespace, identity.NamespaceSource)
+	}
+
 	// Create metrics collector
-	metricsCollector := metrics.NewCollector(kubeClient)
+	metricsCollector := metrics.NewCollector(kubeClient, cfg.Collection)
+	metricsCollector.UseMetadataClient(metadataClient)
+	metricsCollector.CountAPICalls(apiCalls)
+	metricsCollector.RecordWarnings(warnings)
+
+	// Create a dedicated Prometheus registry for the controller and process metrics
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	if *once {
+		os.Exit(checkOnce(cfg, kubeClient, metricsCollector, registry, identity, *withOperationCheck))
+	}
+
+	// Create the Azure client; depending on the startup policy, failing to create it is fatal or starts the
+	// controller in metrics-only mode
+	var azureClient azure.Interface
+	if client, err := controller.CreateAzureClient(cfg.Azure, cfg.AzureClientStartup); err != nil {
+		if cfg.AzureClientStartup.FailureMode != config.StartupFailureMetricsOnly {
+			klog.Fatalf("Failed to create Azure client: %v", err)
+		}
+		klog.Warningf("Failed to create Azure client, starting in metrics-only mode: operations will not be detected or aborted until it succeeds: %v", err)
+	} else {
+		azureClient = client
+	}
 
 	// Create controller (ConfigMap mode only)
-	healthController := controller.NewController(kubeClient, metricsCollector, cfg)
+	healthController, err := controller.NewController(kubeClient, metricsCollector, azureClient, cfg, registry, identity)
+	if err != nil {
+		klog.Fatalf("Failed to create controller: %v", err)
+	}
 
 	// Setup signal handling
 	ctx, cancel := context.WithCancel(context.Background())
@@ -56,22 +115,167 @@ func main() {
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
 
+	// Start the HTTP listeners
+	listeners, err := newListeners(cfg, registry, healthController)
+	if err != nil {
+		klog.Fatalf("Failed to configure listener: %v", err)
+	}
 	go func() {
-		<-signalCh
-		klog.Info("Received shutdown signal")
-		cancel()
+		if err := listeners.Run(ctx); err != nil {
+			klog.Fatalf("HTTP listeners failed: %v", err)
+		}
 	}()
 
-	// Start the controller
+	// Reload the configuration when the mounted ConfigMap changes
+	if *configReloadInterval > 0 {
+		watcher := config.NewWatcher(*configPath, *configReloadInterval)
+		go watcher.Run(ctx,
+			func(cfg *config.Config) {
+				applyFlags(cfg, *dryRun, *metricsAddr, *healthAddr)
+				healthController.ReloadConfig(ctx, cfg)
+			},
+			func(err error) { healthController.RejectConfig(ctx, err) })
+	}
+
+	// Start the controller; with leader election a standby waits for the Lease, and a leader that loses it
+	// exits to rejoin the election once its poll loop has stopped
 	klog.Info("Starting AKS Health Monitor Controller")
-	if err := healthController.Run(ctx); err != nil {
+	run := healthController.Run
+	if *enableLeaderElection {
+		run = healthController.RunWithLeaderElection
+	}
+	// Run only returns without an error once a signal cancelled it; the final status is then recorded
+	if err := healthController.RunUntilSignal(ctx, cancel, signalCh, run); err != nil {
 		klog.Fatalf("Controller failed: %v", err)
 	}
-
 	klog.Info("Controller stopped")
 }
 
-func createKubernetesClient(kubeconfig string) (kubernetes.Interface, error) {
+// Exit codes of --once
+const (
+	exitHealthy    = 0
+	exitError      = 1
+	exitViolations = 2
+)
+
+// checkOnce runs a single check for --once, prints its result as JSON and returns the exit code. Without
+// withOperationCheck Azure is not contacted: the metrics are evaluated against the default thresholds as if
+// an operation had just started.
+func checkOnce(cfg *config.Config, kubeClient kubernetes.Interface, metricsCollector *metrics.Collector, registry *prometheus.Registry, identity *runtimeinfo.Info, withOperationCheck bool) int {
+	defer klog.Flush()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var result controller.HealthCheckResult
+	if withOperationCheck {
+		azureClient, err := controller.CreateAzureClient(cfg.Azure, cfg.AzureClientStartup)
+		if err != nil {
+			klog.Errorf("Failed to create Azure client: %v", err)
+			return exitError
+		}
+		healthController, err := controller.NewController(kubeClient, metricsCollector, azureClient, cfg, registry, identity)
+		if err != nil {
+			klog.Errorf("Failed to create controller: %v", err)
+			return exitError
+		}
+		if result, err = healthController.RunOnce(ctx); err != nil {
+			klog.Errorf("Health check failed: %v", err)
+			return exitError
+		}
+	} else {
+		result = controller.EvaluateLocally(ctx, metricsCollector, cfg)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		klog.Errorf("Failed to write result: %v", err)
+		return exitError
+	}
+
+	switch {
+	case result.Error != "":
+		return exitError
+	case len(result.Violations) > 0:
+		return exitViolations
+	default:
+		return exitHealthy
+	}
+}
+
+// applyFlags applies the command-line overrides to a loaded configuration, so a reload keeps them
+func applyFlags(cfg *config.Config, dryRun bool, metricsAddr, healthAddr string) {
+	if dryRun {
+		cfg.DryRun = true
+	}
+	if metricsAddr != "" {
+		cfg.Server.MetricsAddress = metricsAddr
+	}
+	if healthAddr != "" {
+		cfg.Server.HealthAddress = healthAddr
+	}
+}
+
+// newListeners registers the metrics, health and admin listeners that have a bind address. They share one
+// lifecycle and shut down in reverse order, admin first.
+func newListeners(cfg *config.Config, registry *prometheus.Registry, c *controller.Controller) (*server.Manager, error) {
+	listeners := server.NewManager(server.DefaultShutdownTimeout)
+	if err := listeners.Add("metrics", cfg.Server.MetricsAddress, metricsMux(registry, cfg.Server.Exemplars), cfg.Server.Metrics); err != nil {
+		return nil, err
+	}
+	if err := listeners.Add("health", cfg.Server.HealthAddress, healthMux(c), cfg.Server.Health, "/healthz", "/readyz"); err != nil {
+		return nil, err
+	}
+	if err := listeners.Add("admin", cfg.Server.AdminAddress, adminMux(c, cfg.Server.Pprof), cfg.Server.Admin); err != nil {
+		return nil, err
+	}
+	return listeners, nil
+}
+
+// metricsMux serves the Prometheus registry. OpenMetrics negotiation is enabled with exemplars,
+// since the classic text format cannot carry them.
+func metricsMux(registry *prometheus.Registry, openMetrics bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: openMetrics}))
+	return mux
+}
+
+// healthMux serves the probes and the read-only status, the only endpoints of the public health listener
+func healthMux(c *controller.Controller) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", server.HealthzHandler(c))
+	mux.Handle("/readyz", server.ReadyHandler(c))
+	mux.Handle("/status", server.StatusHandler(c))
+	return mux
+}
+
+// adminMux serves the administrative endpoints, the records the controller keeps and, when enabled, the
+// runtime profiles. The pre-check collects metrics from the API server on every request, so it is only
+// served here. /status is served again for the status UI, which reads it from next to /ui/.
+func adminMux(c *controller.Controller, profiling bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/admin/", http.StripPrefix("/admin", server.AdminHandler(c)))
+	mux.Handle("/status", server.StatusHandler(c))
+	mux.Handle("/history", server.HistoryHandler(c))
+	mux.Handle("/fleet", server.FleetHandler(c))
+	mux.Handle("/explain/last-abort", server.ExplainHandler(c))
+	mux.Handle("/suggestions", server.SuggestionsHandler(c))
+	mux.Handle("/precheck", server.PrecheckHandler(c))
+	mux.Handle("/ui/", http.StripPrefix("/ui", server.UIHandler(c)))
+	if profiling {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	return mux
+}
+
+// createKubernetesClient creates the clientset and, on the same connection settings, the metadata client
+// Job listing uses
+func createKubernetesClient(kubeconfig string, apiCalls *metrics.APICallCounter, warnings rest.WarningHandler) (kubernetes.Interface, metadata.Interface, error) {
 	var config *rest.Config
 	var err error
 
@@ -84,8 +288,35 @@ func createKubernetesClient(kubeconfig string) (kubernetes.Interface, error) {
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	config.Wrap(apiCalls.WrapTransport)
+	config.WarningHandler = warnings
 
-	return kubernetes.NewForConfig(config)
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadataClient, err := metadata.NewForConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return kubeClient, metadataClient, nil
+}
+
+// kubeconfigNamespace returns the namespace of the kubeconfig's current context, or "" when running
+// in cluster or when the context sets none
+func kubeconfigNamespace(kubeconfig string) string {
+	if kubeconfig == "" {
+		return ""
+	}
+
+	rawConfig, err := clientcmd.LoadFromFile(kubeconfig)
+	if err != nil {
+		return ""
+	}
+	if kubeContext, ok := rawConfig.Contexts[rawConfig.CurrentContext]; ok && kubeContext != nil {
+		return kubeContext.Namespace
+	}
+	return ""
 }
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// routes reports whether handler has a route for path
+func routes(handler http.Handler, path string) bool {
+	_, pattern := handler.(*http.ServeMux).Handler(httptest.NewRequest(http.MethodGet, path, nil))
+	return pattern != ""
+}
+
+// adminOnlyPaths are served by the admin listener and nowhere else
+var adminOnlyPaths = []string{
+	"/admin/config", "/admin/thresholds", "/history", "/fleet", "/explain/last-abort", "/suggestions", "/precheck", "/ui/",
+}
+
+func TestEndpointPlacement(t *testing.T) {
+	listeners := map[string]http.Handler{
+		"metrics": metricsMux(prometheus.NewRegistry(), false),
+		"health":  healthMux(nil),
+		"admin":   adminMux(nil, true),
+	}
+	// The listeners serving each path
+	placement := map[string][]string{
+		"/metrics":      {"metrics"},
+		"/healthz":      {"health"},
+		"/readyz":       {"health"},
+		"/status":       {"health", "admin"},
+		"/debug/pprof/": {"admin"},
+	}
+	for _, path := range adminOnlyPaths {
+		placement[path] = []string{"admin"}
+	}
+
+	for path, servedBy := range placement {
+		t.Run(path, func(t *testing.T) {
+			want := map[string]bool{}
+			for _, name := range servedBy {
+				want[name] = true
+			}
+			for name, handler := range listeners {
+				if got := routes(handler, path); got != want[name] {
+					t.Errorf("%s listener serves %s: %v, want %v", name, path, got, want[name])
+				}
+			}
+		})
+	}
+}
+
+func TestPprofOnlyWhenEnabled(t *testing.T) {
+	if routes(adminMux(nil, false), "/debug/pprof/") {
+		t.Error("the admin listener serves /debug/pprof/ without server.pprof")
+	}
+}
+
+// freeAddress returns a loopback address no listener is bound to
+func freeAddress(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	return address
+}
+
+func TestDisabledAdminListenerRemovesRoutes(t *testing.T) {
+	cfg := &config.Config{Server: config.ServerConfig{
+		MetricsAddress: freeAddress(t),
+		HealthAddress:  freeAddress(t),
+	}}
+	listeners, err := newListeners(cfg, prometheus.NewRegistry(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listeners.Len() != 2 {
+		t.Fatalf("registered %d listeners, want the metrics and health listeners only", listeners.Len())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- listeners.Run(ctx) }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	get := func(url string) (int, error) {
+		var lastErr error
+		// The listeners bind in the background
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			resp, err := client.Get(url)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			resp.Body.Close()
+			return resp.StatusCode, nil
+		}
+		return 0, lastErr
+	}
+
+	for _, address := range []string{cfg.Server.MetricsAddress, cfg.Server.HealthAddress} {
+		for _, path := range adminOnlyPaths {
+			status, err := get("http://" + address + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status != http.StatusNotFound {
+				t.Errorf("GET %s on %s = %d, want 404 with the admin listener disabled", path, address, status)
+			}
+		}
+	}
+}
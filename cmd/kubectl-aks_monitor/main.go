@@ -0,0 +1,306 @@
+// Command kubectl-aks_monitor shows the health monitor's status, history and last abort. Installed on the
+// PATH it runs as "kubectl aks-monitor". It reads a running controller's API with --server, or works from
+// a local configuration file without one.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/controller"
+	"aks-health-monitor/pkg/metrics"
+	"aks-health-monitor/pkg/output"
+	"aks-health-monitor/pkg/storage"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const usage = `Usage: kubectl aks-monitor <command> [flags]
+
+Commands:
+  status    Show the controller status and the last result's metrics against their thresholds
+  history   Show recent health check results, one row per cycle
+  explain   Show the decision record of the most recent abort
+  precheck  Check whether the cluster is healthy enough to start an operation; exits 3 if not
+
+Without --server, status collects metrics with the local kubeconfig and evaluates them against
+the local configuration, precheck does the same and reads the operation state from Azure, and
+history reads the configured history storage. explain needs --server.
+Run "kubectl aks-monitor <command> -h" for a command's flags.
+`
+
+// errNotFound is returned when the controller has nothing to show, e.g. no abort was issued
+var errNotFound = errors.New("not found")
+
+// errPrecheckFailed is returned when the pre-check ran and failed
+var errPrecheckFailed = errors.New("the cluster is not ready to start an operation")
+
+// exitPrecheckFailed is the exit code of a failed pre-check, distinct from errors running the command
+const exitPrecheckFailed = 3
+
+// options are the flags every command accepts
+type options struct {
+	output     string
+	server     string
+	token      string
+	configPath string
+	kubeconfig string
+	noColor    bool
+	limit      int
+	timeout    time.Duration
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "--help" || os.Args[1] == "help" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	command := os.Args[1]
+	var run func(context.Context, *options, *output.Printer) error
+	switch command {
+	case "status":
+		run = runStatus
+	case "history":
+		run = runHistory
+	case "explain":
+		run = runExplain
+	case "precheck":
+		run = runPrecheck
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+
+	opts := &options{}
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.StringVar(&opts.output, "o", "table", "output format: table, wide, json or yaml")
+	flags.StringVar(&opts.output, "output", "table", "output format: table, wide, json or yaml")
+	flags.StringVar(&opts.server, "server", "", "URL of a running controller's admin listener, e.g. http://localhost:8082")
+	tokenFile := flags.String("token-file", "", "file holding the bearer token the admin listener requires, if any")
+	flags.StringVar(&opts.configPath, "config", "config.yaml", "configuration file used without --server")
+	flags.StringVar(&opts.kubeconfig, "kubeconfig", "", "kubeconfig used without --server (defaults to KUBECONFIG or ~/.kube/config)")
+	flags.BoolVar(&opts.noColor, "no-color", false, "disable colors, which are otherwise used on terminals")
+	flags.DurationVar(&opts.timeout, "timeout", time.Minute, "timeout for the whole command")
+	if command == "history" {
+		flags.IntVar(&opts.limit, "limit", 100, "maximum number of results read from history storage without --server")
+	}
+	flags.Parse(os.Args[2:])
+
+	if *tokenFile != "" {
+		token, err := os.ReadFile(*tokenFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read --token-file: %v\n", err)
+			os.Exit(2)
+		}
+		opts.token = strings.TrimSpace(string(token))
+	}
+
+	format, err := output.ParseFormat(opts.output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	printer := output.NewPrinter(os.Stdout, format, !opts.noColor && output.ColorEnabled(os.Stdout))
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+	defer cancel()
+	if err := run(ctx, opts, printer); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", command, err)
+		if errors.Is(err, errPrecheckFailed) {
+			os.Exit(exitPrecheckFailed)
+		}
+		os.Exit(1)
+	}
+}
+
+// runStatus shows the live controller status, or a local evaluation of the current metrics
+func runStatus(ctx context.Context, opts *options, printer *output.Printer) error {
+	if opts.server != "" {
+		var doc map[string]interface{}
+		if err := fetch(ctx, opts, "/status", &doc); err != nil {
+			return err
+		}
+		return printer.Status(doc)
+	}
+
+	cfg, err := config.LoadConfigFromConfigMap(opts.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	kubeClient, err := kubernetesClient(opts.kubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+	collector := metrics.NewCollector(kubeClient, cfg.Collection)
+	result := controller.EvaluateLocally(ctx, collector, cfg)
+
+	// The same shape as GET /status, without the fields only a running controller knows
+	return printer.Status(map[string]interface{}{
+		"mode":       output.ModeLocal,
+		"thresholds": cfg.Thresholds,
+		"namespaces": collector.Namespaces(),
+		"lastResult": result,
+	})
+}
+
+// runHistory shows the live controller's history, or reads it from the configured history storage
+func runHistory(ctx context.Context, opts *options, printer *output.Printer) error {
+	if opts.server != "" {
+		var results []controller.HealthCheckResult
+		if err := fetch(ctx, opts, "/history", &results); err != nil {
+			return err
+		}
+		return printer.History(results)
+	}
+
+	cfg, err := config.LoadConfigFromConfigMap(opts.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	store, err := openStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	results, err := controller.LoadHistory(ctx, store, opts.limit)
+	if err != nil {
+		return fmt.Errorf("failed to read history storage: %w", err)
+	}
+	return printer.History(results)
+}
+
+// runExplain shows the decision record of the live controller's most recent abort
+func runExplain(ctx context.Context, opts *options, printer *output.Printer) error {
+	if opts.server == "" {
+		return errors.New("abort explanations are only kept by the running controller; use --server")
+	}
+
+	var explanation controller.AbortExplanation
+	if err := fetch(ctx, opts, "/explain/last-abort", &explanation); err != nil {
+		if errors.Is(err, errNotFound) {
+			return errors.New("no abort has been issued")
+		}
+		return err
+	}
+	return printer.Explain(&explanation)
+}
+
+// runPrecheck runs the pre-check on the live controller, or locally with the kubeconfig and the
+// configuration's Azure credentials
+func runPrecheck(ctx context.Context, opts *options, printer *output.Printer) error {
+	var result controller.PrecheckResult
+	if opts.server != "" {
+		if err := fetchStatus(ctx, opts, "/precheck", &result, http.StatusOK, http.StatusPreconditionFailed); err != nil {
+			return err
+		}
+	} else {
+		cfg, err := config.LoadConfigFromConfigMap(opts.configPath)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %w", err)
+		}
+		kubeClient, err := kubernetesClient(opts.kubeconfig)
+		if err != nil {
+			return fmt.Errorf("failed to create Kubernetes client: %w", err)
+		}
+		var azureClient azure.Interface
+		if client, err := azure.NewClient(cfg.Azure); err != nil {
+			// The operation check fails without a client; the other checks still run
+			fmt.Fprintf(os.Stderr, "precheck: failed to create Azure client: %v\n", err)
+		} else {
+			azureClient = client
+		}
+		result = controller.RunPrecheck(ctx, metrics.NewCollector(kubeClient, cfg.Collection), azureClient, cfg)
+	}
+
+	if err := printer.Precheck(&result); err != nil {
+		return err
+	}
+	if !result.Passed {
+		return errPrecheckFailed
+	}
+	return nil
+}
+
+// fetch reads a JSON document from the controller's API
+func fetch(ctx context.Context, opts *options, path string, into interface{}) error {
+	return fetchStatus(ctx, opts, path, into, http.StatusOK)
+}
+
+// fetchStatus reads a JSON document from the controller's API, accepting it with any of the given status codes
+func fetchStatus(ctx context.Context, opts *options, path string, into interface{}, accepted ...int) error {
+	endpoint := strings.TrimSuffix(opts.server, "/") + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	if opts.token != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%s: %w", endpoint, errNotFound)
+	case !acceptedStatus(resp.StatusCode, accepted):
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s returned %s: %s", endpoint, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(into); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", endpoint, err)
+	}
+	return nil
+}
+
+// openStore opens the history storage the configuration selects
+func openStore(ctx context.Context, cfg *config.Config) (storage.Store, error) {
+	switch cfg.HistoryStorage.Backend {
+	case config.HistoryBackendSQLite:
+		return storage.NewSQLiteStore(ctx, cfg.HistoryStorage.SQLitePath)
+	case config.HistoryBackendAzureTable:
+		client, err := azure.NewClient(cfg.Azure)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Azure client: %w", err)
+		}
+		return client.NewTableStore(cfg.HistoryStorage.TableURL), nil
+	default:
+		return nil, errors.New("without historyStorage the history is only kept by the running controller; use --server")
+	}
+}
+
+// kubernetesClient creates a client from the kubeconfig, or from KUBECONFIG and ~/.kube/config like kubectl
+func kubernetesClient(kubeconfig string) (kubernetes.Interface, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(restConfig)
+}
+
+// acceptedStatus reports whether statusCode is one of accepted
+func acceptedStatus(statusCode int, accepted []int) bool {
+	for _, code := range accepted {
+		if statusCode == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+// activityLogAPIVersion is the Azure Monitor Activity Log API version used to find who started an operation
+const activityLogAPIVersion = "2015-04-01"
+
+// maxActivityLogPages bounds how many pages of a resource group's activity log are read per lookup
+const maxActivityLogPages = 10
+
+// Activity log claims that identify the caller
+const (
+	objectIDClaim = "http://schemas.microsoft.com/identity/claims/objectidentifier"
+	appIDClaim    = "appid"
+)
+
+// startingActions are the cluster actions, besides writes, that start an operation. Aborts and credential
+// reads are not among them: they change nothing, or stop the operation being attributed.
+var startingActions = []string{
+	"/start/action",
+	"/stop/action",
+	"/upgradenodeimageversion/action",
+	"/rotateclustercertificates/action",
+	"/rotateserviceaccountsigningkeys/action",
+	"/resetserviceprincipalprofile/action",
+	"/resetaadprofile/action",
+}
+
+// OperationCaller is the identity recorded in the activity log for the request that started an operation
+type OperationCaller struct {
+	// Caller is the activity log's caller: a user principal name for users, an object ID for service principals
+	Caller    string    `json:"caller"`
+	ObjectID  string    `json:"objectId,omitempty"`
+	AppID     string    `json:"appId,omitempty"`
+	Operation string    `json:"operation"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Identities returns every identifier the caller is known by
+func (c *OperationCaller) Identities() []string {
+	var identities []string
+	for _, identity := range []string{c.Caller, c.ObjectID, c.AppID} {
+		if identity != "" {
+			identities = append(identities, identity)
+		}
+	}
+	return identities
+}
+
+// activityLogResponse is the subset of an activity log page the monitor reads
+type activityLogResponse struct {
+	Value []struct {
+		Caller        string            `json:"caller"`
+		Claims        map[string]string `json:"claims"`
+		ResourceID    string            `json:"resourceId"`
+		EventTime     time.Time         `json:"eventTimestamp"`
+		OperationName struct {
+			Value string `json:"value"`
+		} `json:"operationName"`
+	} `json:"value"`
+	NextLink string `json:"nextLink"`
+}
+
+// GetOperationCaller reads the activity log for the most recent request since the given time that could
+// have started an operation on the cluster: a write, or one of the actions that start an operation. It
+// returns nil when there is none, for example because the activity log has not caught up yet.
+func (c *Client) GetOperationCaller(ctx context.Context, since time.Time) (*OperationCaller, error) {
+	clusterID := strings.ToLower(fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ContainerService/managedClusters/%s",
+		c.subscriptionID, c.resourceGroupName, c.clusterName))
+	filter := fmt.Sprintf("eventTimestamp ge '%s' and eventTimestamp le '%s' and resourceGroupName eq '%s'",
+		since.UTC().Format(time.RFC3339), time.Now().UTC().Format(time.RFC3339), c.resourceGroupName)
+
+	endpoint := fmt.Sprintf("%s/subscriptions/%s/providers/Microsoft.Insights/eventtypes/management/values",
+		strings.TrimSuffix(c.armClient.Endpoint(), "/"), c.subscriptionID)
+	var latest *OperationCaller
+	for page := 0; endpoint != "" && page < maxActivityLogPages; page++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result, err := c.activityLogPage(ctx, endpoint, page == 0, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range result.Value {
+			if !concernsCluster(event.ResourceID, clusterID) || !startsOperation(event.OperationName.Value) {
+				continue
+			}
+			if latest != nil && !event.EventTime.After(latest.Timestamp) {
+				continue
+			}
+			latest = &OperationCaller{
+				Caller:    event.Caller,
+				ObjectID:  event.Claims[objectIDClaim],
+				AppID:     event.Claims[appIDClaim],
+				Operation: event.OperationName.Value,
+				Timestamp: event.EventTime,
+			}
+		}
+		endpoint = result.NextLink
+	}
+
+	return latest, nil
+}
+
+// activityLogPage reads one page of the activity log. The first page is requested with the filter;
+// nextLink URLs already carry it.
+func (c *Client) activityLogPage(ctx context.Context, endpoint string, first bool, filter string) (*activityLogResponse, error) {
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	req, err := runtime.NewRequest(ctx, http.MethodGet, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if first {
+		values := req.Raw().URL.Query()
+		values.Set("api-version", activityLogAPIVersion)
+		values.Set("$filter", filter)
+		values.Set("$select", "caller,claims,resourceId,eventTimestamp,operationName")
+		req.Raw().URL.RawQuery = values.Encode()
+	}
+
+	resp, err := c.armClient.Pipeline().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, runtime.NewResponseError(resp)
+	}
+
+	body, err := runtime.Payload(resp)
+	if err != nil {
+		return nil, err
+	}
+	var result activityLogResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode activity log response: %w", err)
+	}
+	return &result, nil
+}
+
+// concernsCluster reports whether an activity log resource ID is the cluster or one of its child resources,
+// such as an agent pool, and not another cluster whose name starts with the same characters
+func concernsCluster(resourceID, clusterID string) bool {
+	id := strings.ToLower(resourceID)
+	return id == clusterID || strings.HasPrefix(id, clusterID+"/")
+}
+
+// startsOperation reports whether an activity log operation name is a request that starts a cluster
+// or agent pool operation
+func startsOperation(operationName string) bool {
+	name := strings.ToLower(operationName)
+	if strings.HasSuffix(name, "/write") {
+		return true
+	}
+	for _, action := range startingActions {
+		if strings.HasSuffix(name, action) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package azure
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+)
+
+// Initiator identifies who most likely started an operation
+type Initiator string
+
+const (
+	InitiatorUnknown    Initiator = "unknown"
+	InitiatorAutoscaler Initiator = "cluster-autoscaler"
+	InitiatorManual     Initiator = "manual"
+	// InitiatorTrusted and InitiatorUntrusted attribute an operation by its caller in the activity log
+	InitiatorTrusted   Initiator = "trusted"
+	InitiatorUntrusted Initiator = "untrusted"
+)
+
+// Confidence describes how strongly the evidence supports an attribution
+type Confidence string
+
+const (
+	ConfidenceLow  Confidence = "low"
+	ConfidenceHigh Confidence = "high"
+)
+
+// Attribution records the heuristic outcome of attributing an operation to an initiator
+type Attribution struct {
+	Initiator  Initiator  `json:"initiator"`
+	Confidence Confidence `json:"confidence"`
+	Evidence   string     `json:"evidence"`
+}
+
+// attributeScaleOperation guesses whether a Scaling operation was started by the cluster autoscaler.
+// An autoscaler-enabled pool whose count is within its min/max bounds strongly implies the autoscaler;
+// pools without autoscaling imply a manual scale.
+func attributeScaleOperation(pools []*armcontainerservice.ManagedClusterAgentPoolProfile) Attribution {
+	candidates := scalingPools(pools)
+	if len(candidates) == 0 {
+		return Attribution{
+			Initiator:  InitiatorUnknown,
+			Confidence: ConfidenceLow,
+			Evidence:   "no agent pool profiles available",
+		}
+	}
+
+	autoscalerPools := 0
+	for _, pool := range candidates {
+		if pool.EnableAutoScaling == nil || !*pool.EnableAutoScaling {
+			continue
+		}
+		autoscalerPools++
+
+		if pool.Count != nil && pool.MinCount != nil && pool.MaxCount != nil &&
+			*pool.Count >= *pool.MinCount && *pool.Count <= *pool.MaxCount {
+			return Attribution{
+				Initiator:  InitiatorAutoscaler,
+				Confidence: ConfidenceHigh,
+				Evidence: fmt.Sprintf("agent pool %s has autoscaling enabled with count %d within bounds [%d, %d]",
+					stringValue(pool.Name), *pool.Count, *pool.MinCount, *pool.MaxCount),
+			}
+		}
+	}
+
+	if autoscalerPools > 0 {
+		return Attribution{
+			Initiator:  InitiatorAutoscaler,
+			Confidence: ConfidenceLow,
+			Evidence:   fmt.Sprintf("%d scaling agent pool(s) have autoscaling enabled but count is outside bounds or unknown", autoscalerPools),
+		}
+	}
+
+	return Attribution{
+		Initiator:  InitiatorManual,
+		Confidence: ConfidenceHigh,
+		Evidence:   fmt.Sprintf("none of %d scaling agent pool(s) have autoscaling enabled", len(candidates)),
+	}
+}
+
+// scalingPools returns the pools reporting a Scaling provisioning state, or all pools when none do
+func scalingPools(pools []*armcontainerservice.ManagedClusterAgentPoolProfile) []*armcontainerservice.ManagedClusterAgentPoolProfile {
+	var scaling, all []*armcontainerservice.ManagedClusterAgentPoolProfile
+	for _, pool := range pools {
+		if pool == nil {
+			continue
+		}
+		all = append(all, pool)
+		if pool.ProvisioningState != nil && *pool.ProvisioningState == "Scaling" {
+			scaling = append(scaling, pool)
+		}
+	}
+
+	if len(scaling) > 0 {
+		return scaling
+	}
+	return all
+}
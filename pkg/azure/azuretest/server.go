@@ -0,0 +1,483 @@
+// Package azuretest provides a fake Azure Resource Manager server that emulates the managed cluster
+// endpoints used by pkg/azure, including the abort long-running-operation protocol, so the client can
+// be exercised without real Azure. It also emulates the subset of the Table service that history
+// storage uses, under /tables/.
+package azuretest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/config"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// AbortBehavior scripts how the fake server answers abort requests
+type AbortBehavior string
+
+const (
+	// AbortAccept accepts the abort and completes it after the configured number of polls
+	AbortAccept AbortBehavior = "accept"
+	// AbortConflict answers 409, as ARM does when the operation finished before it could be cancelled
+	AbortConflict AbortBehavior = "conflict"
+	// AbortForbidden answers 403, as ARM does when the identity lacks the abort permission
+	AbortForbidden AbortBehavior = "forbidden"
+	// AbortFail accepts the abort but reports the long-running operation as Failed
+	AbortFail AbortBehavior = "fail"
+)
+
+// RecordedRequest is a request received by the fake server
+type RecordedRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Time   time.Time
+}
+
+// Server is a fake ARM endpoint for a single managed cluster
+type Server struct {
+	*httptest.Server
+
+	mu                 sync.Mutex
+	location           string
+	states             []string
+	stateIndex         int
+	properties         map[string]interface{}
+	abortBehavior      AbortBehavior
+	abortPolls         int
+	latency            time.Duration
+	throttled          int
+	retryAfter         time.Duration
+	unavailable        int
+	requests           []RecordedRequest
+	operations         map[string]int
+	operationCounter   int
+	maintenanceConfigs []interface{}
+	activityLog        []interface{}
+	clusterMissing     bool
+	groupMissing       bool
+	forbidden          bool
+	graphState         string
+	permissions        []string
+	tables             map[string]map[string]tableRows
+	tablePageSize      int
+}
+
+// NewServer starts a fake ARM server. The server terminates TLS because the SDK refuses to send
+// bearer tokens over plain HTTP; use ClientOptions to obtain a transport that trusts it.
+func NewServer() *Server {
+	s := &Server{
+		location:      "eastus",
+		states:        []string{"Succeeded"},
+		properties:    map[string]interface{}{},
+		abortBehavior: AbortAccept,
+		abortPolls:    1,
+		operations:    map[string]int{},
+		permissions:   []string{"*"},
+		tables:        map[string]map[string]tableRows{},
+		tablePageSize: 1000,
+	}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// SetProvisioningStates scripts the provisioning states returned by successive cluster GETs.
+// The last state is repeated once the sequence is exhausted.
+func (s *Server) SetProvisioningStates(states ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states = states
+	s.stateIndex = 0
+}
+
+// SetResourceGraphState scripts the provisioning state returned by Resource Graph queries, which lag ARM.
+// An empty state reports the state most recently returned by a cluster GET.
+func (s *Server) SetResourceGraphState(state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.graphState = state
+}
+
+// SetClusterProperty sets an additional field under the cluster's properties, for example
+// "agentPoolProfiles" or "currentKubernetesVersion"
+func (s *Server) SetClusterProperty(name string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.properties[name] = value
+}
+
+// SetAbortBehavior scripts the answer to abort requests. polls is the number of InProgress
+// responses returned while polling an accepted abort before it reaches its final state.
+func (s *Server) SetAbortBehavior(behavior AbortBehavior, polls int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.abortBehavior = behavior
+	s.abortPolls = polls
+}
+
+// SetClusterMissing makes cluster requests answer 404 ResourceNotFound
+func (s *Server) SetClusterMissing(missing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clusterMissing = missing
+}
+
+// SetResourceGroupMissing makes resource group and cluster requests answer 404 ResourceGroupNotFound
+func (s *Server) SetResourceGroupMissing(missing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.groupMissing = missing
+}
+
+// SetForbidden makes cluster requests answer 403 AuthorizationFailed, as when the identity has no role assignment
+func (s *Server) SetForbidden(forbidden bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forbidden = forbidden
+}
+
+// SetPermissions sets the actions the caller is granted on the cluster by the permissions API, which
+// preflight reads for the abort identity. The default grants every action.
+func (s *Server) SetPermissions(actions ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.permissions = actions
+}
+
+// SetLatency delays every response by the given duration
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+// SetThrottled answers the next count requests with 429 Too Many Requests and a Retry-After header of
+// retryAfter, rounded up to whole seconds as ARM sends it. Clients only retry them when their ARM options
+// retry, e.g. with azure.RetryOptions; ClientOptions disables retries.
+func (s *Server) SetThrottled(count int, retryAfter time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.throttled = count
+	s.retryAfter = retryAfter
+}
+
+// SetUnavailable answers the next count requests with 503 Service Unavailable, as ARM does during an outage
+func (s *Server) SetUnavailable(count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unavailable = count
+}
+
+// SetMaintenanceConfigurations sets the maintenance configurations listed for the cluster
+func (s *Server) SetMaintenanceConfigurations(configurations ...interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maintenanceConfigs = configurations
+}
+
+// SetActivityLogEvents sets the events returned by activity log queries, as the JSON objects Azure Monitor
+// returns, for example with caller, claims, resourceId, eventTimestamp and operationName
+func (s *Server) SetActivityLogEvents(events ...interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.activityLog = events
+}
+
+// Requests returns the requests received so far
+func (s *Server) Requests() []RecordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := make([]RecordedRequest, len(s.requests))
+	copy(requests, s.requests)
+	return requests
+}
+
+// CountRequests returns how many requests matched the method and contained the path fragment
+func (s *Server) CountRequests(method, pathFragment string) int {
+	count := 0
+	for _, request := range s.Requests() {
+		if request.Method == method && strings.Contains(strings.ToLower(request.Path), strings.ToLower(pathFragment)) {
+			count++
+		}
+	}
+	return count
+}
+
+// ClientOptions returns options that direct the pkg/azure client at this server
+func (s *Server) ClientOptions() *azure.ClientOptions {
+	return &azure.ClientOptions{
+		ARM: &arm.ClientOptions{
+			ClientOptions: policy.ClientOptions{
+				Cloud: cloud.Configuration{
+					ActiveDirectoryAuthorityHost: s.URL + "/",
+					Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+						cloud.ResourceManager: {Audience: s.URL, Endpoint: s.URL},
+					},
+				},
+				Transport: s.Client(),
+				Retry:     policy.RetryOptions{MaxRetries: -1},
+			},
+			DisableRPRegistration: true,
+		},
+		PollFrequency: time.Second,
+	}
+}
+
+// NewClient creates a pkg/azure client that talks to this server with a static fake token
+func (s *Server) NewClient(azureConfig config.AzureConfig) (*azure.Client, error) {
+	return azure.NewClientWithCredential(azureConfig, Credential{}, s.ClientOptions())
+}
+
+// Credential is a token credential that returns a static token
+type Credential struct{}
+
+// GetToken returns a fake token valid for an hour
+func (Credential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "fake-token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+// handle routes requests to the emulated endpoints
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, RecordedRequest{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery, Time: time.Now()})
+	latency := s.latency
+	clusterMissing, groupMissing, forbidden := s.clusterMissing, s.groupMissing, s.forbidden
+	throttled := s.throttled > 0
+	if throttled {
+		s.throttled--
+	}
+	retryAfter := s.retryAfter
+	unavailable := !throttled && s.unavailable > 0
+	if unavailable {
+		s.unavailable--
+	}
+	s.mu.Unlock()
+
+	if throttled {
+		seconds := int((retryAfter + time.Second - 1) / time.Second)
+		w.Header().Set("Retry-After", fmt.Sprint(seconds))
+		writeError(w, http.StatusTooManyRequests, "TooManyRequests", "The request is being throttled.")
+		return
+	}
+	if unavailable {
+		writeError(w, http.StatusServiceUnavailable, "ServiceUnavailable", "The service is temporarily unavailable.")
+		return
+	}
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if strings.HasPrefix(r.URL.Path, tablePrefix) {
+		s.handleTable(w, r)
+		return
+	}
+
+	path := strings.ToLower(r.URL.Path)
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	isResourceGroup := len(segments) == 4 && segments[0] == "subscriptions" && segments[2] == "resourcegroups"
+	isCluster := strings.Contains(path, "/providers/microsoft.containerservice/managedclusters/")
+
+	switch {
+	case isResourceGroup && r.Method == http.MethodGet:
+		if groupMissing {
+			writeError(w, http.StatusNotFound, "ResourceGroupNotFound", fmt.Sprintf("Resource group '%s' could not be found.", segments[3]))
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"id": r.URL.Path, "name": segments[3], "location": s.location})
+		return
+	case isCluster && groupMissing:
+		writeError(w, http.StatusNotFound, "ResourceGroupNotFound", "Resource group could not be found.")
+		return
+	case isCluster && forbidden:
+		writeError(w, http.StatusForbidden, "AuthorizationFailed", "The client does not have authorization to perform action 'Microsoft.ContainerService/managedClusters/read'.")
+		return
+	case isCluster && clusterMissing:
+		writeError(w, http.StatusNotFound, "ResourceNotFound", "The Resource 'Microsoft.ContainerService/managedClusters' was not found.")
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(path, "/operations/"):
+		s.handleOperationStatus(w, strings.TrimPrefix(path, "/operations/"))
+	case strings.HasPrefix(path, "/operationresults/"):
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/abort"):
+		s.handleAbort(w)
+	case r.Method == http.MethodPost && path == "/providers/microsoft.resourcegraph/resources":
+		s.handleResourceGraph(w)
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/maintenanceconfigurations"):
+		s.handleMaintenanceConfigurations(w)
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/providers/microsoft.authorization/permissions"):
+		s.handlePermissions(w)
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/providers/microsoft.insights/eventtypes/management/values"):
+		s.handleActivityLog(w)
+	case r.Method == http.MethodGet && isCluster:
+		s.handleGetCluster(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "NotFound", fmt.Sprintf("no fake handler for %s %s", r.Method, r.URL.Path))
+	}
+}
+
+// handleGetCluster answers a managed cluster GET with the next scripted provisioning state
+func (s *Server) handleGetCluster(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	state := s.states[len(s.states)-1]
+	if s.stateIndex < len(s.states) {
+		state = s.states[s.stateIndex]
+		s.stateIndex++
+	}
+	properties := map[string]interface{}{"provisioningState": state}
+	for name, value := range s.properties {
+		properties[name] = value
+	}
+	location := s.location
+	s.mu.Unlock()
+
+	segments := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":         r.URL.Path,
+		"name":       segments[len(segments)-1],
+		"type":       "Microsoft.ContainerService/ManagedClusters",
+		"location":   location,
+		"properties": properties,
+	})
+}
+
+// handleResourceGraph answers a Resource Graph query with the cluster's scripted or last served state
+func (s *Server) handleResourceGraph(w http.ResponseWriter) {
+	s.mu.Lock()
+	state := s.graphState
+	if state == "" {
+		index := s.stateIndex - 1
+		if index < 0 {
+			index = 0
+		}
+		if index >= len(s.states) {
+			index = len(s.states) - 1
+		}
+		state = s.states[index]
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"totalRecords": 1,
+		"count":        1,
+		"data":         []map[string]interface{}{{"provisioningState": state}},
+	})
+}
+
+// handleAbort starts an abort long-running operation or rejects it per the scripted behavior
+func (s *Server) handleAbort(w http.ResponseWriter) {
+	s.mu.Lock()
+	behavior := s.abortBehavior
+	s.operationCounter++
+	id := fmt.Sprintf("op%d", s.operationCounter)
+	s.operations[id] = 0
+	s.mu.Unlock()
+
+	w.Header().Set("x-ms-correlation-request-id", "correlation-"+id)
+	w.Header().Set("x-ms-request-id", "request-"+id)
+	switch behavior {
+	case AbortConflict:
+		writeError(w, http.StatusConflict, "OperationNotAllowed", "The operation completed before it could be aborted.")
+		return
+	case AbortForbidden:
+		writeError(w, http.StatusForbidden, "AuthorizationFailed", "The client does not have authorization to perform action 'Microsoft.ContainerService/managedClusters/abort/action'.")
+		return
+	}
+
+	w.Header().Set("Azure-AsyncOperation", s.URL+"/operations/"+id)
+	w.Header().Set("Location", s.URL+"/operationresults/"+id)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleOperationStatus reports the status of an abort operation, completing it after the scripted polls
+func (s *Server) handleOperationStatus(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	polls, ok := s.operations[id]
+	if ok {
+		s.operations[id] = polls + 1
+	}
+	abortPolls := s.abortPolls
+	behavior := s.abortBehavior
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "NotFound", "unknown operation "+id)
+		return
+	}
+
+	status := "InProgress"
+	if polls >= abortPolls {
+		status = "Succeeded"
+		if behavior == AbortFail {
+			status = "Failed"
+		}
+	}
+
+	body := map[string]interface{}{"id": id, "name": id, "status": status}
+	if status == "Failed" {
+		body["error"] = map[string]string{"code": "AbortFailed", "message": "The abort operation failed."}
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// handleMaintenanceConfigurations lists the scripted maintenance configurations
+func (s *Server) handleMaintenanceConfigurations(w http.ResponseWriter) {
+	s.mu.Lock()
+	configurations := append([]interface{}{}, s.maintenanceConfigs...)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"value": configurations})
+}
+
+// handleActivityLog lists the scripted activity log events in one page. The query filter is not applied;
+// the client picks the events that concern the cluster.
+func (s *Server) handleActivityLog(w http.ResponseWriter) {
+	s.mu.Lock()
+	events := append([]interface{}{}, s.activityLog...)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"value": events})
+}
+
+// handlePermissions lists the scripted actions as a single role assignment
+func (s *Server) handlePermissions(w http.ResponseWriter) {
+	s.mu.Lock()
+	actions := append([]string{}, s.permissions...)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"value": []map[string]interface{}{{"actions": actions, "notActions": []string{}}},
+	})
+}
+
+// writeJSON writes a JSON body
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// writeError writes an ARM error body
+func writeError(w http.ResponseWriter, statusCode int, code, message string) {
+	writeJSON(w, statusCode, map[string]interface{}{
+		"error": map[string]string{"code": code, "message": message},
+	})
+}
@@ -0,0 +1,179 @@
+package azuretest
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tablePrefix is the path under which the server emulates the Table service
+const tablePrefix = "/tables/"
+
+var (
+	// entityPath addresses one entity: table(PartitionKey='p',RowKey='r')
+	entityPath = regexp.MustCompile(`^(\w+)\(PartitionKey='((?:[^']|'')*)',RowKey='((?:[^']|'')*)'\)$`)
+
+	// tableFilter is the subset of OData filters TableStore sends
+	tableFilter = regexp.MustCompile(`^PartitionKey eq '((?:[^']|'')*)'(?: and RowKey gt '((?:[^']|'')*)')?$`)
+)
+
+// tableRows are the entities of one table partition, keyed by row key
+type tableRows map[string]map[string]interface{}
+
+// TableURL returns the URL of a table served by the fake Table service
+func (s *Server) TableURL(name string) string {
+	return s.URL + tablePrefix + name
+}
+
+// SetTablePageSize sets how many entities a Table query returns before answering with a continuation,
+// so paging can be exercised with a handful of rows. The default is the service's 1000.
+func (s *Server) SetTablePageSize(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tablePageSize = size
+}
+
+// TableEntities returns the number of entities stored in a partition
+func (s *Server) TableEntities(table, partition string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tables[table][partition])
+}
+
+// handleTable emulates the Table service operations TableStore uses: insert, insert or replace, get,
+// delete and queries of one partition
+func (s *Server) handleTable(w http.ResponseWriter, r *http.Request) {
+	resource := strings.TrimPrefix(r.URL.Path, tablePrefix)
+
+	if match := entityPath.FindStringSubmatch(resource); match != nil {
+		s.handleEntity(w, r, match[1], unquote(match[2]), unquote(match[3]))
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && !strings.Contains(resource, "("):
+		var entity map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&entity); err != nil {
+			writeTableError(w, http.StatusBadRequest, "InvalidInput", err.Error())
+			return
+		}
+		partition, _ := entity["PartitionKey"].(string)
+		row, _ := entity["RowKey"].(string)
+		s.mu.Lock()
+		_, exists := s.partition(resource, partition)[row]
+		if !exists {
+			s.partition(resource, partition)[row] = entity
+		}
+		s.mu.Unlock()
+		if exists {
+			writeTableError(w, http.StatusConflict, "EntityAlreadyExists", "The specified entity already exists.")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && strings.HasSuffix(resource, "()"):
+		s.handleTableQuery(w, r, strings.TrimSuffix(resource, "()"))
+	default:
+		writeTableError(w, http.StatusNotImplemented, "NotImplemented", "no fake Table handler for "+r.Method+" "+r.URL.Path)
+	}
+}
+
+// handleEntity serves requests addressing one entity
+func (s *Server) handleEntity(w http.ResponseWriter, r *http.Request, table, partition, row string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entities := s.partition(table, partition)
+
+	switch r.Method {
+	case http.MethodGet:
+		entity, ok := entities[row]
+		if !ok {
+			writeTableError(w, http.StatusNotFound, "ResourceNotFound", "The specified resource does not exist.")
+			return
+		}
+		writeJSON(w, http.StatusOK, entity)
+	case http.MethodPut:
+		var entity map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&entity); err != nil {
+			writeTableError(w, http.StatusBadRequest, "InvalidInput", err.Error())
+			return
+		}
+		entities[row] = entity
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if _, ok := entities[row]; !ok {
+			writeTableError(w, http.StatusNotFound, "ResourceNotFound", "The specified resource does not exist.")
+			return
+		}
+		delete(entities, row)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeTableError(w, http.StatusMethodNotAllowed, "UnsupportedHttpVerb", r.Method)
+	}
+}
+
+// handleTableQuery answers a partition query in row key order, one page at a time
+func (s *Server) handleTableQuery(w http.ResponseWriter, r *http.Request, table string) {
+	query := r.URL.Query()
+	match := tableFilter.FindStringSubmatch(query.Get("$filter"))
+	if match == nil {
+		writeTableError(w, http.StatusBadRequest, "InvalidInput", "unsupported filter "+query.Get("$filter"))
+		return
+	}
+	partition, after := unquote(match[1]), unquote(match[2])
+	start := query.Get("NextRowKey")
+
+	s.mu.Lock()
+	pageSize := s.tablePageSize
+	var rows []string
+	for row := range s.partition(table, partition) {
+		if (match[2] == "" || row > after) && row >= start {
+			rows = append(rows, row)
+		}
+	}
+	sort.Strings(rows)
+	if top, err := strconv.Atoi(query.Get("$top")); err == nil && top < pageSize {
+		pageSize = top
+	}
+	var next string
+	if len(rows) > pageSize {
+		next = rows[pageSize]
+		rows = rows[:pageSize]
+	}
+	page := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		page = append(page, s.tables[table][partition][row])
+	}
+	s.mu.Unlock()
+
+	if next != "" {
+		w.Header().Set("x-ms-continuation-NextPartitionKey", partition)
+		w.Header().Set("x-ms-continuation-NextRowKey", next)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"value": page})
+}
+
+// partition returns the entities of a table partition, creating it if needed. s.mu must be held.
+func (s *Server) partition(table, partition string) tableRows {
+	if s.tables[table] == nil {
+		s.tables[table] = map[string]tableRows{}
+	}
+	if s.tables[table][partition] == nil {
+		s.tables[table][partition] = tableRows{}
+	}
+	return s.tables[table][partition]
+}
+
+// unquote reverses the doubling of quotes in an OData string literal
+func unquote(value string) string {
+	return strings.ReplaceAll(value, "''", "'")
+}
+
+// writeTableError writes a Table service error body
+func writeTableError(w http.ResponseWriter, statusCode int, code, message string) {
+	writeJSON(w, statusCode, map[string]interface{}{
+		"odata.error": map[string]interface{}{"code": code, "message": map[string]string{"value": message}},
+	})
+}
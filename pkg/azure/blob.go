@@ -0,0 +1,67 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+)
+
+const (
+	// storageBlobScope is the token scope of the Blob service data plane
+	storageBlobScope = "https://storage.azure.com/.default"
+
+	// storageBlobAPIVersion is the Blob service version; bearer tokens need 2017-11-09 or later
+	storageBlobAPIVersion = "2021-12-02"
+
+	// blobMaxRetries is how many times a failed Blob request is retried
+	blobMaxRetries = 3
+)
+
+// BlobUploader uploads blobs to one container, authenticating with the client's credential
+type BlobUploader struct {
+	pipeline     runtime.Pipeline
+	containerURL string
+}
+
+// NewBlobUploader creates an uploader for the container at containerURL, e.g.
+// https://myaccount.blob.core.windows.net/aks-monitor. The container must exist and the identity needs
+// Storage Blob Data Contributor on it.
+func (c *Client) NewBlobUploader(containerURL string) *BlobUploader {
+	return &BlobUploader{
+		pipeline:     c.dataPlanePipeline(storageBlobScope, blobMaxRetries),
+		containerURL: strings.TrimSuffix(containerURL, "/"),
+	}
+}
+
+// URL returns where the named blob is stored
+func (u *BlobUploader) URL(name string) string {
+	return u.containerURL + "/" + url.PathEscape(name)
+}
+
+// Upload writes data to the named block blob, replacing any blob of that name
+func (u *BlobUploader) Upload(ctx context.Context, name, contentType string, data []byte) error {
+	req, err := runtime.NewRequest(ctx, http.MethodPut, u.URL(name))
+	if err != nil {
+		return err
+	}
+	req.Raw().Header.Set("x-ms-version", storageBlobAPIVersion)
+	req.Raw().Header.Set("x-ms-blob-type", "BlockBlob")
+	if err := req.SetBody(streaming.NopCloser(bytes.NewReader(data)), contentType); err != nil {
+		return err
+	}
+
+	resp, err := u.pipeline.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return runtime.NewResponseError(resp)
+	}
+	return nil
+}
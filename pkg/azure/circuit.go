@@ -0,0 +1,178 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"k8s.io/klog/v2"
+)
+
+// ErrCircuitOpen is returned by write operations such as aborts while the write circuit is open, without
+// sending a request. The operation is temporarily unavailable and can be retried later.
+var ErrCircuitOpen = errors.New("azure write circuit is open")
+
+// CircuitState is the state of a circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets every request through
+	CircuitClosed CircuitState = iota
+	// CircuitHalfOpen lets one probe request through to test whether ARM recovered
+	CircuitHalfOpen
+	// CircuitOpen fails requests fast until the open period ends
+	CircuitOpen
+)
+
+// String returns the state name
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitHalfOpen:
+		return "halfOpen"
+	case CircuitOpen:
+		return "open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// circuitBreaker stops requests after consecutive failures; see config.CircuitBreakerConfig. A nil
+// breaker lets every request through.
+type circuitBreaker struct {
+	name             string
+	failureThreshold int
+	openDuration     time.Duration
+	maxOpenDuration  time.Duration
+	now              func() time.Time
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	backoff  time.Duration
+	probing  bool
+}
+
+// newCircuitBreaker creates a breaker, or returns nil when the configuration disables it
+func newCircuitBreaker(name string, cfg config.CircuitBreakerConfig) *circuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		return nil
+	}
+	return &circuitBreaker{
+		name:             name,
+		failureThreshold: cfg.FailureThreshold,
+		openDuration:     cfg.OpenDuration,
+		maxOpenDuration:  cfg.MaxOpenDuration,
+		now:              time.Now,
+		backoff:          cfg.OpenDuration,
+	}
+}
+
+// allow reports whether a request may be sent, returning an error wrapping ErrCircuitOpen if not. Once the
+// open period has passed, the first caller becomes the half-open probe and others keep failing fast
+// until its outcome is recorded.
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if elapsed := b.now().Sub(b.openedAt); elapsed < b.backoff {
+			return fmt.Errorf("%w after %d consecutive %s failures, next attempt in %s",
+				ErrCircuitOpen, b.failures, b.name, (b.backoff - elapsed).Round(time.Second))
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		klog.Infof("Azure %s circuit half-open, letting one request through", b.name)
+		return nil
+	case CircuitHalfOpen:
+		if b.probing {
+			return fmt.Errorf("%w, a probe %s request is in flight", ErrCircuitOpen, b.name)
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// record updates the breaker with the outcome of a request let through by allow. Only failures that
+// suggest ARM itself is unhealthy count; answers such as 409 Conflict or 403 Forbidden are successes for
+// the breaker.
+func (b *circuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+
+	if !isServiceFailure(err) {
+		if b.state != CircuitClosed {
+			klog.Infof("Azure %s circuit closed, ARM answered again", b.name)
+		}
+		b.state = CircuitClosed
+		b.failures = 0
+		b.backoff = b.openDuration
+		return
+	}
+
+	b.failures++
+	switch {
+	case b.state == CircuitHalfOpen:
+		b.backoff = min(2*b.backoff, b.maxOpenDuration)
+		b.open(err)
+	case b.failures >= b.failureThreshold:
+		b.backoff = b.openDuration
+		b.open(err)
+	}
+}
+
+// open moves the breaker to the open state; b.mu must be held
+func (b *circuitBreaker) open(err error) {
+	b.state = CircuitOpen
+	b.openedAt = b.now()
+	klog.Warningf("Azure %s circuit open for %s after %d consecutive failures: %v", b.name, b.backoff, b.failures, err)
+}
+
+// currentState returns the state, reporting an open circuit whose period has passed as half-open
+func (b *circuitBreaker) currentState() CircuitState {
+	if b == nil {
+		return CircuitClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.backoff {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// isServiceFailure reports whether err suggests ARM is unavailable: a 5xx or 429 response, or no
+// response at all. Cancellation by the caller is not a failure.
+func isServiceFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var responseErr *azcore.ResponseError
+	if errors.As(err, &responseErr) {
+		return responseErr.StatusCode >= http.StatusInternalServerError || responseErr.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// WriteCircuitState returns the state of the circuit breaker guarding write operations such as aborts
+func (c *Client) WriteCircuitState() CircuitState {
+	return c.writeCircuit.currentState()
+}
@@ -0,0 +1,239 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+func TestIsServiceFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "success", err: nil, want: false},
+		{name: "cancelled", err: fmt.Errorf("abort: %w", context.Canceled), want: false},
+		{name: "conflict", err: &azcore.ResponseError{StatusCode: http.StatusConflict}, want: false},
+		{name: "forbidden", err: &azcore.ResponseError{StatusCode: http.StatusForbidden}, want: false},
+		{name: "not found", err: &azcore.ResponseError{StatusCode: http.StatusNotFound}, want: false},
+		{name: "throttled", err: &azcore.ResponseError{StatusCode: http.StatusTooManyRequests}, want: true},
+		{name: "internal error", err: &azcore.ResponseError{StatusCode: http.StatusInternalServerError}, want: true},
+		{name: "unavailable", err: fmt.Errorf("abort: %w", &azcore.ResponseError{StatusCode: http.StatusServiceUnavailable}), want: true},
+		{name: "no response", err: errors.New("dial tcp: connection refused"), want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isServiceFailure(test.err); got != test.want {
+				t.Errorf("isServiceFailure(%v) = %v, want %v", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerHalfOpenProbe(t *testing.T) {
+	now := time.Now()
+	breaker := newCircuitBreaker("write", config.CircuitBreakerConfig{FailureThreshold: 2, OpenDuration: time.Minute, MaxOpenDuration: time.Hour})
+	breaker.now = func() time.Time { return now }
+	unavailable := &azcore.ResponseError{StatusCode: http.StatusServiceUnavailable}
+
+	for i := 0; i < 2; i++ {
+		if err := breaker.allow(); err != nil {
+			t.Fatalf("request %d refused while closed: %v", i, err)
+		}
+		breaker.record(unavailable)
+	}
+	if err := breaker.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow = %v, want ErrCircuitOpen after 2 failures", err)
+	}
+
+	// Once the open period has passed, one probe goes through and the others keep failing fast
+	now = now.Add(time.Minute)
+	if state := breaker.currentState(); state != CircuitHalfOpen {
+		t.Errorf("state = %s, want halfOpen", state)
+	}
+	if err := breaker.allow(); err != nil {
+		t.Fatalf("probe refused: %v", err)
+	}
+	if err := breaker.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("allow during the probe = %v, want ErrCircuitOpen", err)
+	}
+
+	// A failed probe opens the circuit again for twice as long
+	breaker.record(unavailable)
+	now = now.Add(time.Minute)
+	if err := breaker.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow a minute after the failed probe = %v, want ErrCircuitOpen", err)
+	}
+	now = now.Add(time.Minute)
+	if err := breaker.allow(); err != nil {
+		t.Fatalf("second probe refused: %v", err)
+	}
+
+	// A successful probe closes it
+	breaker.record(nil)
+	if state := breaker.currentState(); state != CircuitClosed {
+		t.Errorf("state = %s, want closed after a successful probe", state)
+	}
+	if err := breaker.allow(); err != nil {
+		t.Errorf("allow after closing = %v", err)
+	}
+}
+
+// circuitStep is one request through a breaker: time passes, the request asks to be let through and, if
+// it is, its outcome is recorded
+type circuitStep struct {
+	advance     time.Duration
+	outcome     error
+	wantAllowed bool
+	wantState   CircuitState
+}
+
+func TestCircuitBreakerSequences(t *testing.T) {
+	unavailable := &azcore.ResponseError{StatusCode: http.StatusServiceUnavailable}
+	throttled := &azcore.ResponseError{StatusCode: http.StatusTooManyRequests}
+	conflict := &azcore.ResponseError{StatusCode: http.StatusConflict}
+	forbidden := &azcore.ResponseError{StatusCode: http.StatusForbidden}
+	refused := errors.New("dial tcp: connection refused")
+	enabled := config.CircuitBreakerConfig{FailureThreshold: 2, OpenDuration: time.Minute, MaxOpenDuration: 3 * time.Minute}
+
+	tests := []struct {
+		name   string
+		config config.CircuitBreakerConfig
+		steps  []circuitStep
+	}{
+		{
+			name:   "opens at the failure threshold",
+			config: enabled,
+			steps: []circuitStep{
+				{outcome: unavailable, wantAllowed: true, wantState: CircuitClosed},
+				{outcome: refused, wantAllowed: true, wantState: CircuitOpen},
+				{advance: 59 * time.Second, wantAllowed: false, wantState: CircuitOpen},
+			},
+		},
+		{
+			name:   "success resets the failure count",
+			config: enabled,
+			steps: []circuitStep{
+				{outcome: unavailable, wantAllowed: true, wantState: CircuitClosed},
+				{outcome: nil, wantAllowed: true, wantState: CircuitClosed},
+				{outcome: throttled, wantAllowed: true, wantState: CircuitClosed},
+				{outcome: unavailable, wantAllowed: true, wantState: CircuitOpen},
+			},
+		},
+		{
+			name:   "client errors and cancellation do not count",
+			config: enabled,
+			steps: []circuitStep{
+				{outcome: conflict, wantAllowed: true, wantState: CircuitClosed},
+				{outcome: forbidden, wantAllowed: true, wantState: CircuitClosed},
+				{outcome: context.Canceled, wantAllowed: true, wantState: CircuitClosed},
+				{outcome: conflict, wantAllowed: true, wantState: CircuitClosed},
+			},
+		},
+		{
+			name:   "failed probes double the open period up to the maximum",
+			config: enabled,
+			steps: []circuitStep{
+				{outcome: unavailable, wantAllowed: true, wantState: CircuitClosed},
+				{outcome: unavailable, wantAllowed: true, wantState: CircuitOpen},
+				{advance: time.Minute, outcome: unavailable, wantAllowed: true, wantState: CircuitOpen},
+				{advance: 119 * time.Second, wantAllowed: false, wantState: CircuitOpen},
+				{advance: time.Second, outcome: unavailable, wantAllowed: true, wantState: CircuitOpen},
+				{advance: 179 * time.Second, wantAllowed: false, wantState: CircuitOpen},
+				{advance: time.Second, outcome: unavailable, wantAllowed: true, wantState: CircuitOpen},
+				{advance: 3 * time.Minute, outcome: nil, wantAllowed: true, wantState: CircuitClosed},
+			},
+		},
+		{
+			name:   "a client error answers the probe",
+			config: enabled,
+			steps: []circuitStep{
+				{outcome: unavailable, wantAllowed: true, wantState: CircuitClosed},
+				{outcome: unavailable, wantAllowed: true, wantState: CircuitOpen},
+				{advance: time.Minute, outcome: conflict, wantAllowed: true, wantState: CircuitClosed},
+			},
+		},
+		{
+			name:   "recovery resets the open period",
+			config: enabled,
+			steps: []circuitStep{
+				{outcome: unavailable, wantAllowed: true, wantState: CircuitClosed},
+				{outcome: unavailable, wantAllowed: true, wantState: CircuitOpen},
+				{advance: time.Minute, outcome: unavailable, wantAllowed: true, wantState: CircuitOpen},
+				{advance: 2 * time.Minute, outcome: nil, wantAllowed: true, wantState: CircuitClosed},
+				{outcome: unavailable, wantAllowed: true, wantState: CircuitClosed},
+				{outcome: unavailable, wantAllowed: true, wantState: CircuitOpen},
+				{advance: time.Minute, outcome: nil, wantAllowed: true, wantState: CircuitClosed},
+			},
+		},
+		{
+			name:   "disabled",
+			config: config.CircuitBreakerConfig{},
+			steps: []circuitStep{
+				{outcome: unavailable, wantAllowed: true, wantState: CircuitClosed},
+				{outcome: unavailable, wantAllowed: true, wantState: CircuitClosed},
+				{outcome: unavailable, wantAllowed: true, wantState: CircuitClosed},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			breaker := newCircuitBreaker("write", test.config)
+			now := time.Now()
+			if breaker != nil {
+				breaker.now = func() time.Time { return now }
+			}
+			for i, step := range test.steps {
+				now = now.Add(step.advance)
+				err := breaker.allow()
+				if allowed := err == nil; allowed != step.wantAllowed {
+					t.Fatalf("step %d: allow = %v, want allowed: %v", i, err, step.wantAllowed)
+				}
+				if err != nil && !errors.Is(err, ErrCircuitOpen) {
+					t.Fatalf("step %d: allow = %v, want ErrCircuitOpen", i, err)
+				}
+				if err == nil {
+					breaker.record(step.outcome)
+				}
+				if state := breaker.currentState(); state != step.wantState {
+					t.Fatalf("step %d: state = %s, want %s", i, state, step.wantState)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryOptions(t *testing.T) {
+	if options := RetryOptions(config.ARMRetryConfig{}); options.MaxRetries != -1 {
+		t.Errorf("MaxRetries = %d for maxRetries 0, want -1 to disable the SDK default", options.MaxRetries)
+	}
+
+	options := RetryOptions(config.ARMRetryConfig{MaxRetries: 4, RetryDelay: time.Second, MaxRetryDelay: time.Minute})
+	if options.MaxRetries != 4 || options.RetryDelay != time.Second || options.MaxRetryDelay != time.Minute {
+		t.Errorf("options = %+v, want the configured retries and delays", options)
+	}
+	retried := map[int]bool{}
+	for _, code := range options.StatusCodes {
+		retried[code] = true
+	}
+	for _, code := range []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable} {
+		if !retried[code] {
+			t.Errorf("status %d is not retried", code)
+		}
+	}
+	for _, code := range []int{http.StatusConflict, http.StatusForbidden, http.StatusNotFound} {
+		if retried[code] {
+			t.Errorf("status %d is retried", code)
+		}
+	}
+}
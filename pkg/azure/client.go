@@ -3,10 +3,16 @@ package azure
 import (
 	"context"
 	"fmt"
-	"strings"
+	"net/http"
+	"sync"
+	"time"
 
 	"aks-health-monitor/pkg/config"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
 )
@@ -16,76 +22,288 @@ type OperationStatus struct {
 	InProgress    bool
 	OperationType string
 	Status        string
+
+	// Errors reported by ARM on the cluster or its agent pools, if any
+	Errors []ProvisioningError
+
+	// Attribution of the operation to its likely initiator; only set for Scaling operations
+	Attribution *Attribution
+
+	// Upgrade distinguishes control plane from node pool upgrades; only set for Upgrading operations
+	Upgrade *UpgradeClassification
+
+	// Progress holds the values the controller watches for progress; only set while an operation is in
+	// progress and the status was read from ARM
+	Progress *ProgressSignals
+
+	// Source is where the provisioning state was read: config.StatusSourceARM or config.StatusSourceResourceGraph
+	Source string
+
+	// StaleFor is the time since the last direct ARM read when the status came from Resource Graph.
+	// Graph lags ARM; the monitor bounds the lag by reading ARM again once this reaches the configured maximum.
+	StaleFor time.Duration
+
+	// Snapshot is the cluster read the status was derived from, shared by the rest of the cycle; nil when
+	// the status came from Resource Graph
+	Snapshot *ClusterSnapshot
+}
+
+// OperationName is the name used to look up the operation's settings: the upgrade class for classified
+// upgrades, otherwise the provisioning state
+func (s *OperationStatus) OperationName() string {
+	if s.Upgrade != nil && s.Upgrade.Class != "" {
+		return s.Upgrade.Class
+	}
+	return s.OperationType
 }
 
-// Client wraps the Azure Container Service client
+// HasErrors reports whether ARM reported any provisioning errors
+func (s *OperationStatus) HasErrors() bool {
+	return len(s.Errors) > 0
+}
+
+// Client wraps the Azure Container Service client. Reads go through aksClient and aborts through
+// abortClient, which uses a separate identity when one is configured.
 type Client struct {
 	aksClient         *armcontainerservice.ManagedClustersClient
+	abortClient       *armcontainerservice.ManagedClustersClient
+	maintenanceClient *armcontainerservice.MaintenanceConfigurationsClient
+	armClient         *arm.Client
+	armOptions        *arm.ClientOptions
+	armScope          string
+	cred              azcore.TokenCredential
 	subscriptionID    string
 	resourceGroupName string
 	clusterName       string
+	tenantID          string
+	clientID          string
+	authMode          string
+	pollFrequency     time.Duration
+
+	// callTimeout bounds each read and abortTimeout a whole abort, including its polling
+	callTimeout  time.Duration
+	abortTimeout time.Duration
+
+	// abortIdentity is the separate identity aborts are sent with, or nil when they use cred
+	abortIdentity *identity
+
+	// statusSource selects how provisioning state is read; see config.AzureConfig.StatusSource
+	statusSource      string
+	graphMaxStaleness time.Duration
+
+	// writeCircuit fails aborts fast while ARM keeps failing them
+	writeCircuit *circuitBreaker
+
+	mu          sync.Mutex
+	lastARMRead time.Time
 }
 
-// NewClient creates a new Azure client
+// DefaultPollFrequency is how often long-running operations are polled when ARM sends no Retry-After
+const DefaultPollFrequency = 10 * time.Second
+
+// Timeouts applied when the configuration leaves them unset
+const (
+	DefaultCallTimeout  = 30 * time.Second
+	DefaultAbortTimeout = 10 * time.Minute
+)
+
+// ClientOptions customizes how the Client talks to ARM
+type ClientOptions struct {
+	// ARM is passed to every SDK client; nil uses the SDK defaults
+	ARM *arm.ClientOptions
+
+	// PollFrequency is the polling interval for long-running operations (minimum 1s)
+	PollFrequency time.Duration
+
+	// AbortCredential sends aborts instead of the client's credential; nil uses the client's credential
+	AbortCredential azcore.TokenCredential
+}
+
+// identity is a credential and the identifiers used to describe it in errors
+type identity struct {
+	cred      azcore.TokenCredential
+	armClient *arm.Client
+	tenantID  string
+	clientID  string
+}
+
+// NewClient creates a new Azure client authenticating with the configured auth mode
 func NewClient(azureConfig config.AzureConfig) (*Client, error) {
-	// Create credential
-	cred, err := azidentity.NewClientSecretCredential(
-		azureConfig.TenantID,
-		azureConfig.ClientID,
-		azureConfig.ClientSecret,
-		nil,
-	)
+	cred, err := newCredential(azureConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create credential: %w", err)
+		return nil, fmt.Errorf("failed to create %s credential: %w", authMode(azureConfig), err)
+	}
+
+	options := &ClientOptions{
+		ARM: &arm.ClientOptions{ClientOptions: policy.ClientOptions{
+			Cloud: cloudConfiguration(azureConfig),
+			Retry: RetryOptions(azureConfig.Retry),
+		}},
+	}
+	if azureConfig.AbortCredential.Configured() {
+		options.AbortCredential, err = newAbortCredential(azureConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create abort credential: %w", err)
+		}
+	}
+
+	return NewClientWithCredential(azureConfig, cred, options)
+}
+
+// newCredential creates the credential of the configured auth mode, authenticating against the
+// authority of the configured cloud
+func newCredential(azureConfig config.AzureConfig) (azcore.TokenCredential, error) {
+	clientOptions := policy.ClientOptions{Cloud: cloudConfiguration(azureConfig)}
+	switch authMode(azureConfig) {
+	case config.AuthModeManagedIdentity:
+		options := &azidentity.ManagedIdentityCredentialOptions{ClientOptions: clientOptions}
+		if azureConfig.ClientID != "" {
+			options.ID = azidentity.ClientID(azureConfig.ClientID)
+		}
+		return azidentity.NewManagedIdentityCredential(options)
+	case config.AuthModeWorkloadIdentity:
+		// The workload identity webhook projects the token file and sets AZURE_FEDERATED_TOKEN_FILE
+		return azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+			ClientOptions: clientOptions,
+			TenantID:      azureConfig.TenantID,
+			ClientID:      azureConfig.ClientID,
+		})
+	case config.AuthModeDefault:
+		return azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+			ClientOptions: clientOptions,
+			TenantID:      azureConfig.TenantID,
+		})
+	default:
+		return azidentity.NewClientSecretCredential(azureConfig.TenantID, azureConfig.ClientID, azureConfig.ClientSecret,
+			&azidentity.ClientSecretCredentialOptions{ClientOptions: clientOptions})
+	}
+}
+
+// authMode returns the configured auth mode, defaulting to a client secret
+func authMode(azureConfig config.AzureConfig) string {
+	if azureConfig.AuthMode == "" {
+		return config.AuthModeClientSecret
+	}
+	return azureConfig.AuthMode
+}
+
+// newAbortCredential creates the credential of the abort identity: a service principal when it has a
+// client secret, otherwise a user-assigned managed identity
+func newAbortCredential(azureConfig config.AzureConfig) (azcore.TokenCredential, error) {
+	abortCredential := azureConfig.AbortCredential
+	clientOptions := policy.ClientOptions{Cloud: cloudConfiguration(azureConfig)}
+	if abortCredential.ClientSecret == "" {
+		return azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{
+			ClientOptions: clientOptions,
+			ID:            azidentity.ClientID(abortCredential.ClientID),
+		})
+	}
+
+	tenantID := abortCredential.TenantID
+	if tenantID == "" {
+		tenantID = azureConfig.TenantID
+	}
+	return azidentity.NewClientSecretCredential(tenantID, abortCredential.ClientID, abortCredential.ClientSecret,
+		&azidentity.ClientSecretCredentialOptions{ClientOptions: clientOptions})
+}
+
+// NewClientWithCredential creates a new Azure client using the given credential and options
+func NewClientWithCredential(azureConfig config.AzureConfig, cred azcore.TokenCredential, options *ClientOptions) (*Client, error) {
+	if options == nil {
+		options = &ClientOptions{}
+	}
+	pollFrequency := options.PollFrequency
+	if pollFrequency <= 0 {
+		pollFrequency = DefaultPollFrequency
+	}
+	callTimeout := azureConfig.CallTimeout
+	if callTimeout <= 0 {
+		callTimeout = DefaultCallTimeout
+	}
+	abortTimeout := azureConfig.AbortTimeout
+	if abortTimeout <= 0 {
+		abortTimeout = DefaultAbortTimeout
 	}
 
 	// Create AKS client
-	aksClient, err := armcontainerservice.NewManagedClustersClient(azureConfig.SubscriptionID, cred, nil)
+	aksClient, err := armcontainerservice.NewManagedClustersClient(azureConfig.SubscriptionID, cred, options.ARM)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create AKS client: %w", err)
 	}
 
+	// Create maintenance configurations client
+	maintenanceClient, err := armcontainerservice.NewMaintenanceConfigurationsClient(azureConfig.SubscriptionID, cred, options.ARM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create maintenance configurations client: %w", err)
+	}
+
+	// Generic ARM client for requests outside the container service API, such as preflight checks
+	armClient, err := arm.NewClient("aks-health-monitor", "v1.0.0", cred, options.ARM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ARM client: %w", err)
+	}
+
+	abortClient := aksClient
+	var abortIdentity *identity
+	if options.AbortCredential != nil {
+		abortClient, err = armcontainerservice.NewManagedClustersClient(azureConfig.SubscriptionID, options.AbortCredential, options.ARM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create AKS abort client: %w", err)
+		}
+		abortARMClient, err := arm.NewClient("aks-health-monitor", "v1.0.0", options.AbortCredential, options.ARM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create ARM abort client: %w", err)
+		}
+		abortIdentity = &identity{
+			cred:      options.AbortCredential,
+			armClient: abortARMClient,
+			tenantID:  azureConfig.AbortCredential.TenantID,
+			clientID:  azureConfig.AbortCredential.ClientID,
+		}
+		if abortIdentity.tenantID == "" {
+			abortIdentity.tenantID = azureConfig.TenantID
+		}
+	}
+
 	return &Client{
 		aksClient:         aksClient,
+		abortClient:       abortClient,
+		abortIdentity:     abortIdentity,
+		maintenanceClient: maintenanceClient,
+		armClient:         armClient,
+		armOptions:        options.ARM,
+		armScope:          armScope(options.ARM),
+		cred:              cred,
 		subscriptionID:    azureConfig.SubscriptionID,
 		resourceGroupName: azureConfig.ResourceGroupName,
 		clusterName:       azureConfig.ClusterName,
+		tenantID:          azureConfig.TenantID,
+		clientID:          azureConfig.ClientID,
+		authMode:          authMode(azureConfig),
+		pollFrequency:     pollFrequency,
+		callTimeout:       callTimeout,
+		abortTimeout:      abortTimeout,
+		statusSource:      azureConfig.StatusSource,
+		graphMaxStaleness: azureConfig.ResourceGraphMaxStaleness,
+		writeCircuit:      newCircuitBreaker("write", azureConfig.WriteCircuit),
 	}, nil
 }
 
 // GetClusterOperationStatus checks if there's an ongoing operation on the cluster
+// In resourceGraph mode, idle clusters are read from Azure Resource Graph and everything else from ARM.
+// A status read from ARM carries the cluster snapshot it was derived from.
 func (c *Client) GetClusterOperationStatus(ctx context.Context) (*OperationStatus, error) {
-	// Get cluster information
-	cluster, err := c.aksClient.Get(ctx, c.resourceGroupName, c.clusterName, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get cluster: %w", err)
-	}
-
-	status := &OperationStatus{
-		InProgress:    false,
-		OperationType: "",
-		Status:        "",
-	}
-
-	// Check provisioning state
-	if cluster.Properties != nil && cluster.Properties.ProvisioningState != nil {
-		provisioningState := *cluster.Properties.ProvisioningState
-		status.Status = provisioningState
-
-		// Determine if operation is in progress
-		switch provisioningState {
-		case "Upgrading", "Updating", "Scaling", "Creating", "Deleting":
-			status.InProgress = true
-			status.OperationType = provisioningState
-		case "Succeeded", "Failed", "Canceled":
-			status.InProgress = false
-		default:
-			// Unknown state, assume not in progress
-			status.InProgress = false
+	if c.statusSource == config.StatusSourceResourceGraph {
+		if status, ok := c.graphOperationStatus(ctx); ok {
+			return status, nil
 		}
 	}
 
-	return status, nil
+	snapshot, err := c.FetchClusterSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.OperationStatus(), nil
 }
 
 // AbortClusterOperation attempts to abort the ongoing cluster operation using Azure AKS API
@@ -94,47 +312,56 @@ func (c *Client) GetClusterOperationStatus(ctx context.Context) (*OperationStatu
 // - Moves the cluster to a Canceling state and eventually to a Canceled state when cancellation finishes
 // - Returns a 409 error code if the operation completes before cancellation can take place
 // - May not be able to abort all types of operations (some may complete too quickly)
-func (c *Client) AbortClusterOperation(ctx context.Context, operationType string) error {
+//
+// A 409 is returned as an error wrapping ErrOperationFinished. While the write circuit is open it fails
+// fast with an error wrapping ErrCircuitOpen. The IDs ARM assigned to the abort request are returned
+// whether or not it succeeded, and are empty when no request was sent.
+func (c *Client) AbortClusterOperation(ctx context.Context, operationType string) (ARMRequestIDs, error) {
+	if err := c.writeCircuit.allow(); err != nil {
+		return ARMRequestIDs{}, err
+	}
+	ids, err := c.abortClusterOperation(ctx)
+	c.writeCircuit.record(err)
+	return ids, err
+}
+
+// callContext bounds a single read by the configured per-call timeout
+func (c *Client) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, c.callTimeout)
+}
+
+// abortClusterOperation sends the abort request and waits for it to complete, for at most the abort timeout
+func (c *Client) abortClusterOperation(ctx context.Context) (ARMRequestIDs, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.abortTimeout)
+	defer cancel()
+
 	// Use the Azure SDK's BeginAbortLatestOperation method
 	// This method aborts the currently running operation on the managed cluster
-	poller, err := c.aksClient.BeginAbortLatestOperation(ctx, c.resourceGroupName, c.clusterName, nil)
+	var resp *http.Response
+	poller, err := c.abortClient.BeginAbortLatestOperation(policy.WithCaptureResponse(ctx, &resp), c.resourceGroupName, c.clusterName, nil)
+	ids := armRequestIDs(resp)
 	if err != nil {
-		// Check for specific Azure error responses
-		if strings.Contains(err.Error(), "409") || strings.Contains(err.Error(), "Conflict") {
-			return fmt.Errorf("operation completed before abort could take effect (operation was too fast): %w", err)
+		if responseStatus(err) == http.StatusConflict {
+			return ids, fmt.Errorf("%w: %w", ErrOperationFinished, err)
 		}
-		return fmt.Errorf("failed to initiate abort operation: %w", err)
+		return ids, fmt.Errorf("failed to initiate abort operation: %w", err)
 	}
 
 	// Wait for the abort operation to complete
-	_, err = poller.PollUntilDone(ctx, nil)
+	_, err = poller.PollUntilDone(ctx, &runtime.PollUntilDoneOptions{Frequency: c.pollFrequency})
 	if err != nil {
-		return fmt.Errorf("abort operation failed: %w", err)
+		return ids, fmt.Errorf("abort operation failed: %w", err)
 	}
 
-	return nil
+	return ids, nil
 }
 
-// GetClusterInfo returns basic information about the cluster
+// GetClusterInfo returns basic information about the cluster. It reads the cluster again; within a cycle,
+// use the Info of the snapshot attached to the OperationStatus instead.
 func (c *Client) GetClusterInfo(ctx context.Context) (map[string]interface{}, error) {
-	cluster, err := c.aksClient.Get(ctx, c.resourceGroupName, c.clusterName, nil)
+	snapshot, err := c.FetchClusterSnapshot(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get cluster: %w", err)
+		return nil, err
 	}
-
-	info := map[string]interface{}{
-		"name":     c.clusterName,
-		"location": *cluster.Location,
-	}
-
-	if cluster.Properties != nil {
-		info["provisioningState"] = cluster.Properties.ProvisioningState
-		info["kubernetesVersion"] = cluster.Properties.KubernetesVersion
-
-		if cluster.Properties.AgentPoolProfiles != nil && len(cluster.Properties.AgentPoolProfiles) > 0 {
-			info["nodeCount"] = cluster.Properties.AgentPoolProfiles[0].Count
-		}
-	}
-
-	return info, nil
+	return snapshot.Info(), nil
 }
@@ -0,0 +1,473 @@
+package azure_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/azure/azuretest"
+	"aks-health-monitor/pkg/config"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"go.uber.org/goleak"
+)
+
+// testAzureConfig identifies the cluster served by azuretest
+func testAzureConfig() config.AzureConfig {
+	return config.AzureConfig{
+		SubscriptionID:    "00000000-0000-0000-0000-000000000000",
+		ResourceGroupName: "rg",
+		ClusterName:       "cluster",
+	}
+}
+
+// newTestClient starts a fake ARM server and returns it with a client talking to it, which retries as
+// azureConfig.Retry configures
+func newTestClient(t *testing.T, azureConfig config.AzureConfig) (*azuretest.Server, *azure.Client) {
+	t.Helper()
+	server := azuretest.NewServer()
+	t.Cleanup(server.Close)
+	options := server.ClientOptions()
+	options.ARM.Retry = azure.RetryOptions(azureConfig.Retry)
+	client, err := azure.NewClientWithCredential(azureConfig, azuretest.Credential{}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, client
+}
+
+func TestGetClusterOperationStatus(t *testing.T) {
+	tests := []struct {
+		state          string
+		wantInProgress bool
+		wantOperation  string
+	}{
+		{state: "Upgrading", wantInProgress: true, wantOperation: "Upgrading"},
+		{state: "Scaling", wantInProgress: true, wantOperation: "Scaling"},
+		{state: "Succeeded"},
+		{state: "Failed"},
+		{state: "Canceled"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.state, func(t *testing.T) {
+			server, client := newTestClient(t, testAzureConfig())
+			server.SetProvisioningStates(test.state)
+
+			status, err := client.GetClusterOperationStatus(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.InProgress != test.wantInProgress || status.OperationType != test.wantOperation || status.Status != test.state {
+				t.Errorf("status = %+v, want in progress %v with operation %q and status %s",
+					status, test.wantInProgress, test.wantOperation, test.state)
+			}
+			if status.Source != config.StatusSourceARM || status.Snapshot == nil {
+				t.Errorf("source = %s (snapshot %v), want an ARM read with its snapshot", status.Source, status.Snapshot)
+			}
+		})
+	}
+}
+
+func TestScaleOperationAttribution(t *testing.T) {
+	// pool returns an agent pool profile as ARM serves it
+	pool := func(name, state string, autoscaling bool, count, minCount, maxCount int) map[string]interface{} {
+		profile := map[string]interface{}{"name": name, "count": count, "provisioningState": state, "enableAutoScaling": autoscaling}
+		if autoscaling {
+			profile["minCount"], profile["maxCount"] = minCount, maxCount
+		}
+		return profile
+	}
+	tests := []struct {
+		name           string
+		pools          []interface{}
+		wantInitiator  azure.Initiator
+		wantConfidence azure.Confidence
+	}{
+		{
+			name:          "autoscaler pool within its bounds",
+			pools:         []interface{}{pool("nodepool1", "Scaling", true, 4, 2, 10)},
+			wantInitiator: azure.InitiatorAutoscaler, wantConfidence: azure.ConfidenceHigh,
+		},
+		{
+			name:          "autoscaler pool outside its bounds",
+			pools:         []interface{}{pool("nodepool1", "Scaling", true, 12, 2, 10)},
+			wantInitiator: azure.InitiatorAutoscaler, wantConfidence: azure.ConfidenceLow,
+		},
+		{
+			name:          "manually scaled pool",
+			pools:         []interface{}{pool("nodepool1", "Scaling", false, 5, 0, 0)},
+			wantInitiator: azure.InitiatorManual, wantConfidence: azure.ConfidenceHigh,
+		},
+		{
+			name: "only the scaling pool is considered",
+			pools: []interface{}{
+				pool("system", "Succeeded", true, 3, 1, 5),
+				pool("user", "Scaling", false, 8, 0, 0),
+			},
+			wantInitiator: azure.InitiatorManual, wantConfidence: azure.ConfidenceHigh,
+		},
+		{
+			name:          "no agent pool profiles",
+			wantInitiator: azure.InitiatorUnknown, wantConfidence: azure.ConfidenceLow,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, client := newTestClient(t, testAzureConfig())
+			server.SetProvisioningStates("Scaling")
+			if test.pools != nil {
+				server.SetClusterProperty("agentPoolProfiles", test.pools)
+			}
+
+			status, err := client.GetClusterOperationStatus(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			attribution := status.Attribution
+			if attribution == nil {
+				t.Fatal("a Scaling operation was not attributed")
+			}
+			if attribution.Initiator != test.wantInitiator || attribution.Confidence != test.wantConfidence {
+				t.Errorf("attribution = %+v, want %s with %s confidence", *attribution, test.wantInitiator, test.wantConfidence)
+			}
+			if attribution.Evidence == "" {
+				t.Error("the attribution has no evidence")
+			}
+		})
+	}
+}
+
+func TestUpgradeClassification(t *testing.T) {
+	// pool returns an agent pool profile as ARM serves it; empty versions are left out
+	pool := func(name, state, target, current string) map[string]interface{} {
+		profile := map[string]interface{}{"name": name, "provisioningState": state}
+		if target != "" {
+			profile["orchestratorVersion"] = target
+		}
+		if current != "" {
+			profile["currentOrchestratorVersion"] = current
+		}
+		return profile
+	}
+	tests := []struct {
+		name           string
+		target         string
+		current        string
+		pools          []interface{}
+		wantClass      string
+		wantInEvidence string
+	}{
+		{
+			name: "pool upgrading", target: "1.29.0", current: "1.29.0",
+			pools:     []interface{}{pool("system", "Succeeded", "1.29.0", "1.29.0"), pool("user", "Upgrading", "1.29.0", "1.28.5")},
+			wantClass: config.OperationNodePoolUpgrade, wantInEvidence: "agent pools upgrading: user",
+		},
+		{
+			name: "pool upgrading while the control plane moves", target: "1.29.0", current: "1.28.5",
+			pools:     []interface{}{pool("system", "Upgrading", "1.29.0", "1.28.5")},
+			wantClass: config.OperationNodePoolUpgrade, wantInEvidence: "agent pools upgrading: system",
+		},
+		{
+			name: "control plane only", target: "1.29.0", current: "1.28.5",
+			pools:     []interface{}{pool("system", "Succeeded", "1.28.5", "1.28.5")},
+			wantClass: config.OperationControlPlaneUpgrade, wantInEvidence: "control plane 1.28.5 -> 1.29.0 with no agent pool upgrading",
+		},
+		{
+			name: "control plane first, pools pending", target: "1.29.0", current: "1.28.5",
+			pools:     []interface{}{pool("system", "Succeeded", "1.29.0", "1.28.5")},
+			wantClass: config.OperationControlPlaneUpgrade, wantInEvidence: "pools awaiting upgrade: system (1.28.5 -> 1.29.0)",
+		},
+		{
+			name: "pool behind its target", target: "1.29.0", current: "1.29.0",
+			pools:     []interface{}{pool("user", "Succeeded", "1.29.0", "1.28.5")},
+			wantClass: config.OperationNodePoolUpgrade, wantInEvidence: "agent pools behind their target version: user (1.28.5 -> 1.29.0)",
+		},
+		{
+			name: "pools without current versions", target: "1.29.0", current: "1.29.0",
+			pools:          []interface{}{pool("user", "Succeeded", "1.29.0", "")},
+			wantInEvidence: "control plane and agent pools at their target versions",
+		},
+		{
+			name:           "no versions reported",
+			wantInEvidence: "control plane and agent pools at their target versions",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, client := newTestClient(t, testAzureConfig())
+			server.SetProvisioningStates("Upgrading")
+			if test.target != "" {
+				server.SetClusterProperty("kubernetesVersion", test.target)
+				server.SetClusterProperty("currentKubernetesVersion", test.current)
+			}
+			if test.pools != nil {
+				server.SetClusterProperty("agentPoolProfiles", test.pools)
+			}
+
+			status, err := client.GetClusterOperationStatus(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.Upgrade == nil {
+				t.Fatal("an Upgrading operation was not classified")
+			}
+			if status.Upgrade.Class != test.wantClass || !strings.Contains(status.Upgrade.Evidence, test.wantInEvidence) {
+				t.Errorf("classification = %+v, want class %q with evidence containing %q", *status.Upgrade, test.wantClass, test.wantInEvidence)
+			}
+		})
+	}
+}
+
+func TestThrottledReadIsRetried(t *testing.T) {
+	azureConfig := testAzureConfig()
+	azureConfig.Retry = config.ARMRetryConfig{MaxRetries: 3, RetryDelay: time.Millisecond, MaxRetryDelay: 10 * time.Millisecond}
+	server, client := newTestClient(t, azureConfig)
+	server.SetProvisioningStates("Upgrading")
+	server.SetThrottled(1, 0)
+
+	status, err := client.GetClusterOperationStatus(context.Background())
+	if err != nil {
+		t.Fatalf("the throttled read was not retried: %v", err)
+	}
+	if status.OperationType != "Upgrading" {
+		t.Errorf("operation = %q, want Upgrading", status.OperationType)
+	}
+	if reads := server.CountRequests(http.MethodGet, "/managedClusters/"); reads != 2 {
+		t.Errorf("read the cluster %d times, want the 429 and its retry", reads)
+	}
+}
+
+func TestAbortClusterOperation(t *testing.T) {
+	tests := []struct {
+		name         string
+		behavior     azuretest.AbortBehavior
+		wantErr      bool
+		wantFinished bool
+		wantPolls    bool
+	}{
+		{name: "accepted and polled until done", behavior: azuretest.AbortAccept, wantPolls: true},
+		{name: "operation already finished", behavior: azuretest.AbortConflict, wantErr: true, wantFinished: true},
+		{name: "forbidden", behavior: azuretest.AbortForbidden, wantErr: true},
+		{name: "abort failed", behavior: azuretest.AbortFail, wantErr: true, wantPolls: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, client := newTestClient(t, testAzureConfig())
+			server.SetAbortBehavior(test.behavior, 1)
+
+			ids, err := client.AbortClusterOperation(context.Background(), "Upgrading")
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error = %v, want error: %v", err, test.wantErr)
+			}
+			if errors.Is(err, azure.ErrOperationFinished) != test.wantFinished {
+				t.Errorf("error = %v, want ErrOperationFinished: %v", err, test.wantFinished)
+			}
+			if ids.CorrelationID != "correlation-op1" || ids.RequestID != "request-op1" {
+				t.Errorf("request IDs = %+v, want those of the abort request", ids)
+			}
+			// The operation completes on its second status read, so a long-running abort is polled twice
+			polls := server.CountRequests(http.MethodGet, "/operations/")
+			if test.wantPolls && polls != 2 {
+				t.Errorf("polled the abort operation %d times, want 2", polls)
+			}
+			if !test.wantPolls && polls != 0 {
+				t.Errorf("polled the abort operation %d times, want none for a rejected abort", polls)
+			}
+		})
+	}
+}
+
+func TestAbortReturnsPromptlyWhenCancelled(t *testing.T) {
+	// Registered first so it runs after the server and its connections are closed
+	ignore := goleak.IgnoreCurrent()
+	t.Cleanup(func() { goleak.VerifyNone(t, ignore) })
+
+	server, client := newTestClient(t, testAzureConfig())
+	server.SetAbortBehavior(azuretest.AbortAccept, 100)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		// Cancel once the abort is accepted and its operation is being polled
+		for server.CountRequests("GET", "/operations/") == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+	}()
+
+	started := time.Now()
+	_, err := client.AbortClusterOperation(ctx, "Upgrading")
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("abort returned %s after it was cancelled, want promptly", elapsed)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	if polls := server.CountRequests("GET", "/operations/"); polls > 2 {
+		t.Errorf("polled the abort %d times, want polling to stop on cancellation", polls)
+	}
+}
+
+func TestThrottledReadHonoursRetryAfter(t *testing.T) {
+	azureConfig := testAzureConfig()
+	// The SDK gives up on a Retry-After longer than MaxRetryDelay
+	azureConfig.Retry = config.ARMRetryConfig{MaxRetries: 3, RetryDelay: time.Millisecond, MaxRetryDelay: 5 * time.Second}
+	server, client := newTestClient(t, azureConfig)
+	server.SetThrottled(1, time.Second)
+
+	started := time.Now()
+	if _, err := client.GetClusterOperationStatus(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed < 900*time.Millisecond {
+		t.Errorf("retried after %s, want the 1s Retry-After rather than the 1ms retry delay", elapsed)
+	}
+}
+
+func TestServerErrorsExhaustRetries(t *testing.T) {
+	azureConfig := testAzureConfig()
+	azureConfig.Retry = config.ARMRetryConfig{MaxRetries: 2, RetryDelay: time.Millisecond, MaxRetryDelay: 10 * time.Millisecond}
+	server, client := newTestClient(t, azureConfig)
+	server.SetUnavailable(100)
+
+	_, err := client.GetClusterOperationStatus(context.Background())
+	var responseErr *azcore.ResponseError
+	if !errors.As(err, &responseErr) || responseErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("error = %v, want the last 503", err)
+	}
+	if reads := server.CountRequests(http.MethodGet, "/managedClusters/"); reads != 3 {
+		t.Errorf("read the cluster %d times, want the first attempt and 2 retries", reads)
+	}
+}
+
+// writeCircuitConfig opens the write circuit on the first failure, for a minute
+func writeCircuitConfig() config.AzureConfig {
+	azureConfig := testAzureConfig()
+	azureConfig.WriteCircuit = config.CircuitBreakerConfig{FailureThreshold: 1, OpenDuration: time.Minute, MaxOpenDuration: time.Hour}
+	return azureConfig
+}
+
+func TestAbortConflictDoesNotTripWriteCircuit(t *testing.T) {
+	server, client := newTestClient(t, writeCircuitConfig())
+	server.SetAbortBehavior(azuretest.AbortConflict, 0)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.AbortClusterOperation(context.Background(), "Upgrading"); !errors.Is(err, azure.ErrOperationFinished) {
+			t.Fatalf("abort %d: error = %v, want ErrOperationFinished", i, err)
+		}
+	}
+	if state := client.WriteCircuitState(); state != azure.CircuitClosed {
+		t.Errorf("write circuit = %s, want closed: a 409 is not an ARM failure", state)
+	}
+	if aborts := server.CountRequests(http.MethodPost, "/abort"); aborts != 2 {
+		t.Errorf("sent %d aborts, want both", aborts)
+	}
+}
+
+func TestAbortServerErrorsOpenWriteCircuit(t *testing.T) {
+	azureConfig := writeCircuitConfig()
+	azureConfig.Retry = config.ARMRetryConfig{MaxRetries: 1, RetryDelay: time.Millisecond, MaxRetryDelay: 10 * time.Millisecond}
+	server, client := newTestClient(t, azureConfig)
+	server.SetUnavailable(100)
+
+	if _, err := client.AbortClusterOperation(context.Background(), "Upgrading"); err == nil || errors.Is(err, azure.ErrCircuitOpen) {
+		t.Fatalf("first abort: error = %v, want the 503", err)
+	}
+	if state := client.WriteCircuitState(); state != azure.CircuitOpen {
+		t.Fatalf("write circuit = %s, want open after the retries were exhausted", state)
+	}
+
+	ids, err := client.AbortClusterOperation(context.Background(), "Upgrading")
+	if !errors.Is(err, azure.ErrCircuitOpen) {
+		t.Errorf("second abort: error = %v, want ErrCircuitOpen", err)
+	}
+	if ids != (azure.ARMRequestIDs{}) {
+		t.Errorf("request IDs = %+v, want none for a request that was not sent", ids)
+	}
+	if aborts := server.CountRequests(http.MethodPost, "/abort"); aborts != 2 {
+		t.Errorf("sent %d aborts, want the first one and its retry only", aborts)
+	}
+}
+
+func TestGetOperationCaller(t *testing.T) {
+	const clusterID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/cluster"
+	start := time.Date(2026, 10, 15, 2, 0, 0, 0, time.UTC)
+	// event returns an activity log event as Azure Monitor returns it
+	event := func(caller, resourceID, operation string, at time.Duration, claims map[string]string) map[string]interface{} {
+		return map[string]interface{}{
+			"caller":         caller,
+			"claims":         claims,
+			"resourceId":     resourceID,
+			"eventTimestamp": start.Add(at).Format(time.RFC3339),
+			"operationName":  map[string]interface{}{"value": operation, "localizedValue": operation},
+			"status":         map[string]interface{}{"value": "Started"},
+		}
+	}
+	pipeline := map[string]string{
+		"http://schemas.microsoft.com/identity/claims/objectidentifier": "6f1c2b3a-0000-4000-8000-000000000001",
+		"appid": "0c7a9e2d-0000-4000-8000-000000000002",
+	}
+	tests := []struct {
+		name   string
+		events []interface{}
+		want   *azure.OperationCaller
+	}{
+		{
+			name: "node image upgrade by a service principal",
+			events: []interface{}{
+				event("6f1c2b3a-0000-4000-8000-000000000001", clusterID+"/agentPools/nodepool1",
+					"Microsoft.ContainerService/managedClusters/agentPools/upgradeNodeImageVersion/action", 0, pipeline),
+			},
+			want: &azure.OperationCaller{
+				Caller: "6f1c2b3a-0000-4000-8000-000000000001", ObjectID: "6f1c2b3a-0000-4000-8000-000000000001",
+				AppID: "0c7a9e2d-0000-4000-8000-000000000002", Operation: "Microsoft.ContainerService/managedClusters/agentPools/upgradeNodeImageVersion/action",
+				Timestamp: start,
+			},
+		},
+		{
+			name: "most recent write wins",
+			events: []interface{}{
+				event("pipeline@contoso.com", clusterID, "Microsoft.ContainerService/managedClusters/write", time.Minute, nil),
+				event("oncall@contoso.com", clusterID, "Microsoft.ContainerService/managedClusters/write", 3*time.Minute, nil),
+			},
+			want: &azure.OperationCaller{Caller: "oncall@contoso.com", Operation: "Microsoft.ContainerService/managedClusters/write", Timestamp: start.Add(3 * time.Minute)},
+		},
+		{
+			name: "reads, aborts and other resources are ignored",
+			events: []interface{}{
+				event("pipeline@contoso.com", clusterID, "Microsoft.ContainerService/managedClusters/write", 0, nil),
+				event("oncall@contoso.com", clusterID, "Microsoft.ContainerService/managedClusters/listClusterUserCredential/action", time.Minute, nil),
+				event("aks-monitor", clusterID, "Microsoft.ContainerService/managedClusters/abort/action", 2*time.Minute, nil),
+				event("oncall@contoso.com", clusterID+"-staging", "Microsoft.ContainerService/managedClusters/write", 3*time.Minute, nil),
+			},
+			want: &azure.OperationCaller{Caller: "pipeline@contoso.com", Operation: "Microsoft.ContainerService/managedClusters/write", Timestamp: start},
+		},
+		{
+			name:   "activity log not caught up",
+			events: []interface{}{event("oncall@contoso.com", clusterID, "Microsoft.ContainerService/managedClusters/read", 0, nil)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, client := newTestClient(t, testAzureConfig())
+			server.SetActivityLogEvents(test.events...)
+
+			caller, err := client.GetOperationCaller(context.Background(), start.Add(-15*time.Minute))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (caller == nil) != (test.want == nil) || (caller != nil && (caller.Caller != test.want.Caller || caller.ObjectID != test.want.ObjectID ||
+				caller.AppID != test.want.AppID || caller.Operation != test.want.Operation || !caller.Timestamp.Equal(test.want.Timestamp))) {
+				t.Errorf("caller = %+v, want %+v", caller, test.want)
+			}
+		})
+	}
+}
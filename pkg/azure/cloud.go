@@ -0,0 +1,47 @@
+package azure
+
+import (
+	"strings"
+
+	"aks-health-monitor/pkg/config"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+)
+
+// cloudConfiguration returns the authority and ARM endpoint of the configured cloud. Credentials
+// authenticate against the authority and every ARM client, including Resource Graph reads, talks to the
+// endpoint. Data plane scopes such as storage and Service Bus are the same in every cloud.
+func cloudConfiguration(azureConfig config.AzureConfig) cloud.Configuration {
+	switch azureConfig.Cloud {
+	case config.CloudAzureGovernment:
+		return cloud.AzureGovernment
+	case config.CloudAzureChina:
+		return cloud.AzureChina
+	case config.CloudCustom:
+		custom := azureConfig.CustomCloud
+		audience := custom.ResourceManagerAudience
+		if audience == "" {
+			audience = custom.ResourceManagerEndpoint
+		}
+		return cloud.Configuration{
+			ActiveDirectoryAuthorityHost: custom.ActiveDirectoryAuthorityHost,
+			Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+				cloud.ResourceManager: {Audience: audience, Endpoint: custom.ResourceManagerEndpoint},
+			},
+		}
+	default:
+		return cloud.AzurePublic
+	}
+}
+
+// armScope returns the token scope of ARM in the cloud of the options: its audience, which differs from the
+// endpoint in the public and sovereign clouds and may in custom ones. Options without a cloud use the
+// public cloud, as the SDK does.
+func armScope(options *arm.ClientOptions) string {
+	configuration := cloud.AzurePublic
+	if options != nil && len(options.Cloud.Services) > 0 {
+		configuration = options.Cloud
+	}
+	return strings.TrimSuffix(configuration.Services[cloud.ResourceManager].Audience, "/") + "/.default"
+}
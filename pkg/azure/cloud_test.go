@@ -0,0 +1,102 @@
+package azure
+
+import (
+	"testing"
+
+	"aks-health-monitor/pkg/config"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+)
+
+func TestNewClientUsesConfiguredCloud(t *testing.T) {
+	tests := []struct {
+		name          string
+		azure         config.AzureConfig
+		wantAuthority string
+		wantEndpoint  string
+		wantScope     string
+	}{
+		{
+			name:          "default",
+			wantAuthority: "https://login.microsoftonline.com/",
+			wantEndpoint:  "https://management.azure.com",
+			wantScope:     "https://management.core.windows.net/.default",
+		},
+		{
+			name:          "AzurePublic",
+			azure:         config.AzureConfig{Cloud: config.CloudAzurePublic},
+			wantAuthority: "https://login.microsoftonline.com/",
+			wantEndpoint:  "https://management.azure.com",
+			wantScope:     "https://management.core.windows.net/.default",
+		},
+		{
+			name:          "AzureGovernment",
+			azure:         config.AzureConfig{Cloud: config.CloudAzureGovernment},
+			wantAuthority: "https://login.microsoftonline.us/",
+			wantEndpoint:  "https://management.usgovcloudapi.net",
+			wantScope:     "https://management.core.usgovcloudapi.net/.default",
+		},
+		{
+			name:          "AzureChina",
+			azure:         config.AzureConfig{Cloud: config.CloudAzureChina},
+			wantAuthority: "https://login.chinacloudapi.cn/",
+			wantEndpoint:  "https://management.chinacloudapi.cn",
+			wantScope:     "https://management.core.chinacloudapi.cn/.default",
+		},
+		{
+			name: "custom without audience",
+			azure: config.AzureConfig{Cloud: config.CloudCustom, CustomCloud: config.CustomCloudConfig{
+				ActiveDirectoryAuthorityHost: "https://login.stack.example/",
+				ResourceManagerEndpoint:      "https://management.stack.example/",
+			}},
+			wantAuthority: "https://login.stack.example/",
+			wantEndpoint:  "https://management.stack.example/",
+			wantScope:     "https://management.stack.example/.default",
+		},
+		{
+			name: "custom with audience",
+			azure: config.AzureConfig{Cloud: config.CloudCustom, CustomCloud: config.CustomCloudConfig{
+				ActiveDirectoryAuthorityHost: "https://login.stack.example/",
+				ResourceManagerEndpoint:      "https://management.stack.example/",
+				ResourceManagerAudience:      "https://management.adfs.stack.example/",
+			}},
+			wantAuthority: "https://login.stack.example/",
+			wantEndpoint:  "https://management.stack.example/",
+			wantScope:     "https://management.adfs.stack.example/.default",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			azureConfig := test.azure
+			azureConfig.SubscriptionID = "00000000-0000-0000-0000-000000000000"
+			azureConfig.TenantID = "tenant"
+			azureConfig.ClientID = "client"
+			azureConfig.ClientSecret = "secret"
+
+			client, err := NewClient(azureConfig)
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+			configuration := client.armOptions.Cloud
+			if configuration.ActiveDirectoryAuthorityHost != test.wantAuthority {
+				t.Errorf("authority = %q, want %q", configuration.ActiveDirectoryAuthorityHost, test.wantAuthority)
+			}
+			if endpoint := configuration.Services[cloud.ResourceManager].Endpoint; endpoint != test.wantEndpoint {
+				t.Errorf("ARM endpoint in options = %q, want %q", endpoint, test.wantEndpoint)
+			}
+			if endpoint := client.armClient.Endpoint(); endpoint != test.wantEndpoint {
+				t.Errorf("ARM client endpoint = %q, want %q", endpoint, test.wantEndpoint)
+			}
+			if client.armScope != test.wantScope {
+				t.Errorf("ARM scope = %q, want %q", client.armScope, test.wantScope)
+			}
+		})
+	}
+}
+
+func TestArmScopeDefaultsToPublicCloud(t *testing.T) {
+	if scope := armScope(nil); scope != "https://management.core.windows.net/.default" {
+		t.Errorf("armScope(nil) = %q", scope)
+	}
+}
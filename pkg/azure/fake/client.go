@@ -0,0 +1,321 @@
+// Package fake provides an in-memory azure.Interface that returns scripted operation statuses and
+// records every call, so the controller's decisions can be exercised without Azure or an HTTP server.
+// Use pkg/azure/azuretest instead to exercise the real client against a fake ARM endpoint.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/config"
+)
+
+// Methods recorded in Call.Method
+const (
+	MethodGetClusterOperationStatus = "GetClusterOperationStatus"
+	MethodFetchClusterSnapshot      = "FetchClusterSnapshot"
+	MethodAbortClusterOperation     = "AbortClusterOperation"
+	MethodGetClusterInfo            = "GetClusterInfo"
+	MethodListMaintenanceWindows    = "ListMaintenanceWindows"
+	MethodGetOperationCaller        = "GetOperationCaller"
+	MethodPreflight                 = "Preflight"
+	MethodHasAbortPermission        = "HasAbortPermission"
+)
+
+// Call is a call made to the fake client
+type Call struct {
+	Method string
+	// OperationType is the operation passed to AbortClusterOperation
+	OperationType string
+	Time          time.Time
+}
+
+// Client is a fake azure.Interface. Operation statuses are returned in the order they were scripted,
+// and the last one repeats, like the provisioning states of azuretest.Server.
+type Client struct {
+	mu                 sync.Mutex
+	statuses           []*azure.OperationStatus
+	statusIndex        int
+	statusErr          error
+	lastState          string
+	snapshot           azure.ClusterSnapshot
+	abortErr           error
+	preflightErr       error
+	abortDenied        bool
+	permissionErr      error
+	maintenanceWindows []azure.MaintenanceWindow
+	operationCaller    *azure.OperationCaller
+	operationCallerErr error
+	circuitState       azure.CircuitState
+	calls              []Call
+}
+
+// NewClient creates a fake client reporting a cluster with no operation in progress
+func NewClient() *Client {
+	return &Client{
+		statuses: []*azure.OperationStatus{Idle()},
+		snapshot: azure.ClusterSnapshot{Name: "fake", ProvisioningState: "Succeeded"},
+	}
+}
+
+// Idle returns the status of a cluster with no operation in progress
+func Idle() *azure.OperationStatus {
+	return &azure.OperationStatus{Status: "Succeeded", Source: config.StatusSourceARM}
+}
+
+// InProgress returns the status of a cluster running the operation reported by provisioning state
+// state, such as "Upgrading"
+func InProgress(state string) *azure.OperationStatus {
+	return &azure.OperationStatus{InProgress: true, OperationType: state, Status: state, Source: config.StatusSourceARM}
+}
+
+// SetOperationStatuses scripts the statuses returned by successive GetClusterOperationStatus calls
+func (c *Client) SetOperationStatuses(statuses ...*azure.OperationStatus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.statuses = statuses
+	c.statusIndex = 0
+}
+
+// SetOperationStatusError makes GetClusterOperationStatus fail with err until it is cleared with nil
+func (c *Client) SetOperationStatusError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.statusErr = err
+}
+
+// SetVersions sets the Kubernetes versions of the cluster snapshot: the target version and the version
+// the control plane runs
+func (c *Client) SetVersions(kubernetesVersion, currentKubernetesVersion string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.snapshot.KubernetesVersion = kubernetesVersion
+	c.snapshot.CurrentKubernetesVersion = currentKubernetesVersion
+}
+
+// SetAbortError makes AbortClusterOperation fail with err until it is cleared with nil
+func (c *Client) SetAbortError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.abortErr = err
+}
+
+// SetPreflightError makes Preflight fail with err until it is cleared with nil
+func (c *Client) SetPreflightError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.preflightErr = err
+}
+
+// SetAbortPermission sets whether HasAbortPermission reports the abort permission as held; it is held
+// until set otherwise. A non-nil err is returned instead until it is cleared with nil.
+func (c *Client) SetAbortPermission(allowed bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.abortDenied = !allowed
+	c.permissionErr = err
+}
+
+// SetMaintenanceWindows sets the windows returned by ListMaintenanceWindows
+func (c *Client) SetMaintenanceWindows(windows ...azure.MaintenanceWindow) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maintenanceWindows = windows
+}
+
+// SetOperationCaller sets the caller returned by GetOperationCaller; nil reports that the activity log
+// records none. A non-nil err is returned instead until it is cleared with nil.
+func (c *Client) SetOperationCaller(caller *azure.OperationCaller, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.operationCaller = caller
+	c.operationCallerErr = err
+}
+
+// SetWriteCircuitState sets the state returned by WriteCircuitState
+func (c *Client) SetWriteCircuitState(state azure.CircuitState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.circuitState = state
+}
+
+// Calls returns the calls made so far, oldest first
+func (c *Client) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	calls := make([]Call, len(c.calls))
+	copy(calls, c.calls)
+	return calls
+}
+
+// CountCalls returns how many calls were made to method
+func (c *Client) CountCalls(method string) int {
+	count := 0
+	for _, call := range c.Calls() {
+		if call.Method == method {
+			count++
+		}
+	}
+	return count
+}
+
+// Aborts returns the operation types passed to AbortClusterOperation, oldest first
+func (c *Client) Aborts() []string {
+	var aborts []string
+	for _, call := range c.Calls() {
+		if call.Method == MethodAbortClusterOperation {
+			aborts = append(aborts, call.OperationType)
+		}
+	}
+	return aborts
+}
+
+// record records a call; the caller holds c.mu
+func (c *Client) record(method, operationType string) {
+	c.calls = append(c.calls, Call{Method: method, OperationType: operationType, Time: time.Now()})
+}
+
+// GetClusterOperationStatus returns the next scripted status. Unless the status has a snapshot or came
+// from Resource Graph, a copy of the cluster snapshot is attached.
+func (c *Client) GetClusterOperationStatus(ctx context.Context) (*azure.OperationStatus, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record(MethodGetClusterOperationStatus, "")
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c.statusErr != nil {
+		return nil, c.statusErr
+	}
+	if len(c.statuses) == 0 {
+		return nil, fmt.Errorf("fake: no operation status scripted")
+	}
+
+	scripted := c.statuses[c.statusIndex]
+	if c.statusIndex < len(c.statuses)-1 {
+		c.statusIndex++
+	}
+	status := *scripted
+	c.lastState = status.Status
+	if status.Snapshot == nil && status.Source != config.StatusSourceResourceGraph {
+		status.Snapshot = c.currentSnapshot(status.Status)
+	}
+	return &status, nil
+}
+
+// FetchClusterSnapshot returns a copy of the cluster snapshot, in the provisioning state of the status
+// GetClusterOperationStatus last returned
+func (c *Client) FetchClusterSnapshot(ctx context.Context) (*azure.ClusterSnapshot, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record(MethodFetchClusterSnapshot, "")
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c.statusErr != nil {
+		return nil, c.statusErr
+	}
+	return c.currentSnapshot(c.lastState), nil
+}
+
+// currentSnapshot copies the snapshot as of now, reporting state when it is set; the caller holds c.mu
+func (c *Client) currentSnapshot(state string) *azure.ClusterSnapshot {
+	snapshot := c.snapshot
+	snapshot.FetchedAt = time.Now()
+	if state != "" {
+		snapshot.ProvisioningState = state
+	}
+	return &snapshot
+}
+
+// AbortClusterOperation records the abort and returns the scripted abort error, with request IDs
+// numbered by abort
+func (c *Client) AbortClusterOperation(ctx context.Context, operationType string) (azure.ARMRequestIDs, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record(MethodAbortClusterOperation, operationType)
+	if err := ctx.Err(); err != nil {
+		return azure.ARMRequestIDs{}, err
+	}
+	n := 0
+	for _, call := range c.calls {
+		if call.Method == MethodAbortClusterOperation {
+			n++
+		}
+	}
+	ids := azure.ARMRequestIDs{CorrelationID: fmt.Sprintf("fake-correlation-%d", n), RequestID: fmt.Sprintf("fake-request-%d", n)}
+	return ids, c.abortErr
+}
+
+// GetClusterInfo returns the information of the cluster snapshot
+func (c *Client) GetClusterInfo(ctx context.Context) (map[string]interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record(MethodGetClusterInfo, "")
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c.statusErr != nil {
+		return nil, c.statusErr
+	}
+	return c.currentSnapshot("").Info(), nil
+}
+
+// ListMaintenanceWindows returns the scripted maintenance windows
+func (c *Client) ListMaintenanceWindows(ctx context.Context, now time.Time) ([]azure.MaintenanceWindow, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record(MethodListMaintenanceWindows, "")
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	windows := make([]azure.MaintenanceWindow, len(c.maintenanceWindows))
+	copy(windows, c.maintenanceWindows)
+	return windows, nil
+}
+
+// GetOperationCaller returns the scripted caller
+func (c *Client) GetOperationCaller(ctx context.Context, since time.Time) (*azure.OperationCaller, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record(MethodGetOperationCaller, "")
+	if c.operationCallerErr != nil {
+		return nil, c.operationCallerErr
+	}
+	if c.operationCaller == nil {
+		return nil, nil
+	}
+	caller := *c.operationCaller
+	return &caller, nil
+}
+
+// HasAbortPermission returns the scripted permission
+func (c *Client) HasAbortPermission(ctx context.Context) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record(MethodHasAbortPermission, "")
+	if c.permissionErr != nil {
+		return false, c.permissionErr
+	}
+	return !c.abortDenied, nil
+}
+
+// Preflight returns the scripted preflight error
+func (c *Client) Preflight(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record(MethodPreflight, "")
+	return c.preflightErr
+}
+
+// WriteCircuitState returns the scripted circuit state
+func (c *Client) WriteCircuitState() azure.CircuitState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.circuitState
+}
+
+var _ azure.Interface = (*Client)(nil)
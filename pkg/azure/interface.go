@@ -0,0 +1,33 @@
+package azure
+
+import (
+	"context"
+	"time"
+)
+
+// Interface is the part of the Azure client the controller depends on. Client implements it against
+// ARM; pkg/azure/fake implements it in memory for tests and environments without Azure credentials.
+type Interface interface {
+	// GetClusterOperationStatus reports whether an operation is in progress on the cluster
+	GetClusterOperationStatus(ctx context.Context) (*OperationStatus, error)
+	// FetchClusterSnapshot reads the cluster; within a cycle, prefer the snapshot on the OperationStatus
+	FetchClusterSnapshot(ctx context.Context) (*ClusterSnapshot, error)
+	// AbortClusterOperation aborts the operation in progress and waits for the abort to complete, returning
+	// the IDs of the abort request
+	AbortClusterOperation(ctx context.Context, operationType string) (ARMRequestIDs, error)
+	// GetClusterInfo returns basic information about the cluster
+	GetClusterInfo(ctx context.Context) (map[string]interface{}, error)
+	// ListMaintenanceWindows returns the next occurrence of every maintenance configuration on the cluster
+	ListMaintenanceWindows(ctx context.Context, now time.Time) ([]MaintenanceWindow, error)
+	// GetOperationCaller returns who made the latest request since the given time that could have
+	// started an operation, or nil when the activity log records none
+	GetOperationCaller(ctx context.Context, since time.Time) (*OperationCaller, error)
+	// Preflight verifies the cluster can be read and, where aborts may be sent, aborted
+	Preflight(ctx context.Context) error
+	// HasAbortPermission reports whether the identity aborts are sent with may abort operations on the cluster
+	HasAbortPermission(ctx context.Context) (bool, error)
+	// WriteCircuitState returns the state of the circuit breaker guarding aborts
+	WriteCircuitState() CircuitState
+}
+
+var _ Interface = (*Client)(nil)
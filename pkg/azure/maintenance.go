@@ -0,0 +1,234 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+)
+
+// maintenanceLookahead bounds how far ahead the next window occurrence is searched for
+const maintenanceLookahead = 400 * 24 * time.Hour
+
+// MaintenanceWindow is the next (or currently active) occurrence of a planned maintenance configuration
+type MaintenanceWindow struct {
+	Name     string        `json:"name"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+}
+
+// End returns the end of the window
+func (w MaintenanceWindow) End() time.Time {
+	return w.Start.Add(w.Duration)
+}
+
+// ListMaintenanceWindows returns the next occurrence of every maintenance configuration on the cluster.
+// A cluster without maintenance configurations yields an empty list.
+func (c *Client) ListMaintenanceWindows(ctx context.Context, now time.Time) ([]MaintenanceWindow, error) {
+	var windows []MaintenanceWindow
+
+	pager := c.maintenanceClient.NewListByManagedClusterPager(c.resourceGroupName, c.clusterName, nil)
+	for pager.More() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		callCtx, cancel := c.callContext(ctx)
+		page, err := pager.NextPage(callCtx)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list maintenance configurations: %w", err)
+		}
+
+		for _, configuration := range page.Value {
+			if configuration == nil || configuration.Properties == nil {
+				continue
+			}
+			name := stringValue(configuration.Name)
+
+			var window MaintenanceWindow
+			var ok bool
+			if configuration.Properties.MaintenanceWindow != nil {
+				window, ok = nextMaintenanceWindow(configuration.Properties.MaintenanceWindow, now)
+			} else {
+				window, ok = nextTimeInWeekWindow(configuration.Properties.TimeInWeek, now)
+			}
+			if ok {
+				window.Name = name
+				windows = append(windows, window)
+			}
+		}
+	}
+
+	return windows, nil
+}
+
+// nextMaintenanceWindow computes the next occurrence of a schedule-based maintenance window that has not yet ended
+func nextMaintenanceWindow(window *armcontainerservice.MaintenanceWindow, now time.Time) (MaintenanceWindow, bool) {
+	if window.Schedule == nil || window.StartTime == nil || window.DurationHours == nil {
+		return MaintenanceWindow{}, false
+	}
+
+	location, err := parseUTCOffset(stringValue(window.UTCOffset))
+	if err != nil {
+		return MaintenanceWindow{}, false
+	}
+	hour, minute, err := parseClock(*window.StartTime)
+	if err != nil {
+		return MaintenanceWindow{}, false
+	}
+	duration := time.Duration(*window.DurationHours) * time.Hour
+
+	// Schedules are anchored at the start date, or today when none is set
+	localNow := now.In(location)
+	anchor := time.Date(localNow.Year(), localNow.Month(), localNow.Day(), 0, 0, 0, 0, location)
+	if window.StartDate != nil {
+		startDate := window.StartDate.In(location)
+		anchor = time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, location)
+	}
+
+	// Walk forward day by day from yesterday, so a window that started yesterday and is still active is found
+	day := time.Date(localNow.Year(), localNow.Month(), localNow.Day()-1, 0, 0, 0, 0, location)
+	if day.Before(anchor) {
+		day = anchor
+	}
+	for limit := now.Add(maintenanceLookahead); day.Before(limit); day = day.AddDate(0, 0, 1) {
+		if !scheduleMatches(window.Schedule, anchor, day) {
+			continue
+		}
+		start := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, location)
+		if start.Add(duration).After(now) {
+			return MaintenanceWindow{Start: start.UTC(), Duration: duration}, true
+		}
+	}
+
+	return MaintenanceWindow{}, false
+}
+
+// scheduleMatches reports whether the schedule has an occurrence on the given local day
+func scheduleMatches(schedule *armcontainerservice.Schedule, anchor, day time.Time) bool {
+	daysSinceAnchor := int(day.Sub(anchor).Hours()+12) / 24
+	monthsSinceAnchor := (day.Year()-anchor.Year())*12 + int(day.Month()-anchor.Month())
+
+	switch {
+	case schedule.Daily != nil:
+		return daysSinceAnchor%int(intervalOrOne(schedule.Daily.IntervalDays)) == 0
+	case schedule.Weekly != nil:
+		if schedule.Weekly.DayOfWeek == nil || day.Weekday().String() != string(*schedule.Weekly.DayOfWeek) {
+			return false
+		}
+		return (daysSinceAnchor/7)%int(intervalOrOne(schedule.Weekly.IntervalWeeks)) == 0
+	case schedule.AbsoluteMonthly != nil:
+		if schedule.AbsoluteMonthly.DayOfMonth == nil || day.Day() != int(*schedule.AbsoluteMonthly.DayOfMonth) {
+			return false
+		}
+		return monthsSinceAnchor%int(intervalOrOne(schedule.AbsoluteMonthly.IntervalMonths)) == 0
+	case schedule.RelativeMonthly != nil:
+		relative := schedule.RelativeMonthly
+		if relative.DayOfWeek == nil || relative.WeekIndex == nil || day.Weekday().String() != string(*relative.DayOfWeek) {
+			return false
+		}
+		if !weekIndexMatches(*relative.WeekIndex, day) {
+			return false
+		}
+		return monthsSinceAnchor%int(intervalOrOne(relative.IntervalMonths)) == 0
+	}
+
+	return false
+}
+
+// weekIndexMatches reports whether day falls in the given week of its month
+func weekIndexMatches(index armcontainerservice.Type, day time.Time) bool {
+	occurrence := (day.Day()-1)/7 + 1
+	switch index {
+	case armcontainerservice.TypeFirst:
+		return occurrence == 1
+	case armcontainerservice.TypeSecond:
+		return occurrence == 2
+	case armcontainerservice.TypeThird:
+		return occurrence == 3
+	case armcontainerservice.TypeFourth:
+		return occurrence == 4
+	case armcontainerservice.TypeLast:
+		return day.AddDate(0, 0, 7).Month() != day.Month()
+	}
+	return false
+}
+
+// nextTimeInWeekWindow computes the next one-hour slot of a legacy TimeInWeek maintenance configuration (UTC)
+func nextTimeInWeekWindow(times []*armcontainerservice.TimeInWeek, now time.Time) (MaintenanceWindow, bool) {
+	var next MaintenanceWindow
+	found := false
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for offset := -1; offset <= 7; offset++ {
+		day := today.AddDate(0, 0, offset)
+		for _, timeInWeek := range times {
+			if timeInWeek == nil || timeInWeek.Day == nil || day.Weekday().String() != string(*timeInWeek.Day) {
+				continue
+			}
+			for _, slot := range timeInWeek.HourSlots {
+				if slot == nil {
+					continue
+				}
+				start := day.Add(time.Duration(*slot) * time.Hour)
+				if start.Add(time.Hour).After(now) && (!found || start.Before(next.Start)) {
+					next = MaintenanceWindow{Start: start, Duration: time.Hour}
+					found = true
+				}
+			}
+		}
+	}
+
+	return next, found
+}
+
+// parseUTCOffset parses offsets such as "+05:30" or "-07:00"; an empty offset means UTC
+func parseUTCOffset(offset string) (*time.Location, error) {
+	if offset == "" {
+		return time.UTC, nil
+	}
+
+	sign := 1
+	switch offset[0] {
+	case '+':
+	case '-':
+		sign = -1
+	default:
+		return nil, fmt.Errorf("invalid UTC offset %q", offset)
+	}
+
+	hours, minutes, err := parseClock(offset[1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid UTC offset %q", offset)
+	}
+
+	return time.FixedZone(offset, sign*(hours*3600+minutes*60)), nil
+}
+
+// parseClock parses a "HH:MM" string
+func parseClock(value string) (int, int, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid time %q", value)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil || hours < 0 || hours > 23 {
+		return 0, 0, fmt.Errorf("invalid time %q", value)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || minutes < 0 || minutes > 59 {
+		return 0, 0, fmt.Errorf("invalid time %q", value)
+	}
+	return hours, minutes, nil
+}
+
+// intervalOrOne returns the interval value, treating missing or non-positive values as 1
+func intervalOrOne(interval *int32) int32 {
+	if interval == nil || *interval < 1 {
+		return 1
+	}
+	return *interval
+}
@@ -0,0 +1,324 @@
+package azure
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"aks-health-monitor/pkg/config"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// resourceGroupsAPIVersion is the Microsoft.Resources API version used to check that the resource group exists
+const resourceGroupsAPIVersion = "2021-04-01"
+
+// permissionsAPIVersion is the Microsoft.Authorization API version used to list an identity's permissions
+const permissionsAPIVersion = "2022-04-01"
+
+// abortAction is the permission the abort identity needs on the cluster
+const abortAction = "Microsoft.ContainerService/managedClusters/abort/action"
+
+// requiredRole is the built-in role that grants the cluster read and abort permissions the monitor needs
+const requiredRole = "Azure Kubernetes Service Contributor Role"
+
+var subscriptionIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Preflight verifies that the configured cluster can be reached and read, translating the common
+// misconfigurations (malformed subscription ID, missing role assignment, wrong resource group or cluster
+// name, blocked egress) into targeted errors. With a separate abort identity, it also verifies that
+// identity is allowed to abort operations on the cluster.
+func (c *Client) Preflight(ctx context.Context) error {
+	if !subscriptionIDPattern.MatchString(c.subscriptionID) {
+		return fmt.Errorf("subscription ID %q is not a UUID; copy it from `az account show --query id`", c.subscriptionID)
+	}
+
+	callCtx, cancel := c.callContext(ctx)
+	_, err := c.aksClient.Get(callCtx, c.resourceGroupName, c.clusterName, nil)
+	cancel()
+	if err != nil {
+		return c.diagnose(ctx, err)
+	}
+
+	if c.abortIdentity != nil {
+		return c.checkAbortPermission(ctx, c.abortIdentity)
+	}
+	return nil
+}
+
+// diagnose explains why reading the cluster failed
+func (c *Client) diagnose(ctx context.Context, err error) error {
+	if egressErr := diagnoseEgress(err); egressErr != nil {
+		return egressErr
+	}
+
+	var authErr *azidentity.AuthenticationFailedError
+	if errors.As(err, &authErr) {
+		return fmt.Errorf("Azure authentication failed %s; %s: %w", c.describeCredential(), c.credentialHint(), err)
+	}
+
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) {
+		return fmt.Errorf("failed to get cluster: %w", err)
+	}
+
+	switch {
+	case respErr.StatusCode == http.StatusForbidden || respErr.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("identity %s is not allowed to read cluster %s/%s; assign the %q (or a role granting Microsoft.ContainerService/managedClusters/read and /abort/action) on the cluster or resource group: %s",
+			c.describeIdentity(ctx, c.readIdentity()), c.resourceGroupName, c.clusterName, requiredRole, respErr.ErrorCode)
+	case respErr.ErrorCode == "SubscriptionNotFound" || respErr.ErrorCode == "InvalidSubscriptionId":
+		return fmt.Errorf("subscription %s was not found or is not accessible by the identity: %s", c.subscriptionID, respErr.ErrorCode)
+	case respErr.StatusCode == http.StatusNotFound:
+		exists, rgErr := c.resourceGroupExists(ctx)
+		switch {
+		case rgErr != nil:
+			return fmt.Errorf("cluster %s/%s not found in subscription %s (could not check the resource group: %v)",
+				c.resourceGroupName, c.clusterName, c.subscriptionID, rgErr)
+		case !exists:
+			return fmt.Errorf("resource group %q does not exist in subscription %s; check AZURE_RESOURCE_GROUP",
+				c.resourceGroupName, c.subscriptionID)
+		default:
+			return fmt.Errorf("cluster %q does not exist in resource group %q; check AZURE_CLUSTER_NAME",
+				c.clusterName, c.resourceGroupName)
+		}
+	}
+
+	return fmt.Errorf("failed to get cluster: %w", err)
+}
+
+// diagnoseEgress names the endpoint when the failure is a DNS or connection error, or returns nil
+func diagnoseEgress(err error) error {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return fmt.Errorf("cannot resolve Azure endpoint %s; check DNS and egress rules for the pod: %w", dnsErr.Name, err)
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		endpoint := ""
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			if parsed, parseErr := url.Parse(urlErr.URL); parseErr == nil {
+				endpoint = parsed.Host
+			}
+		}
+		if endpoint == "" && opErr.Addr != nil {
+			endpoint = opErr.Addr.String()
+		}
+		return fmt.Errorf("cannot connect to Azure endpoint %s; check firewall and egress rules allow HTTPS to it: %w", endpoint, err)
+	}
+
+	return nil
+}
+
+// resourceGroupExists checks whether the configured resource group exists
+func (c *Client) resourceGroupExists(ctx context.Context) (bool, error) {
+	endpoint := fmt.Sprintf("%s/subscriptions/%s/resourcegroups/%s",
+		strings.TrimSuffix(c.armClient.Endpoint(), "/"), url.PathEscape(c.subscriptionID), url.PathEscape(c.resourceGroupName))
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	req, err := runtime.NewRequest(ctx, http.MethodGet, endpoint)
+	if err != nil {
+		return false, err
+	}
+	query := req.Raw().URL.Query()
+	query.Set("api-version", resourceGroupsAPIVersion)
+	req.Raw().URL.RawQuery = query.Encode()
+
+	resp, err := c.armClient.Pipeline().Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, runtime.NewResponseError(resp)
+	}
+}
+
+// checkAbortPermission verifies that the abort identity can authenticate and holds the abort permission
+// on the cluster, without sending an abort
+func (c *Client) checkAbortPermission(ctx context.Context, abortIdentity *identity) error {
+	permissions, err := c.listPermissions(ctx, abortIdentity)
+	if err != nil {
+		if egressErr := diagnoseEgress(err); egressErr != nil {
+			return egressErr
+		}
+		var authErr *azidentity.AuthenticationFailedError
+		if errors.As(err, &authErr) {
+			return fmt.Errorf("Azure authentication failed for the abort identity with client ID %q in tenant %q; check azure.abortCredential: %w",
+				abortIdentity.clientID, abortIdentity.tenantID, err)
+		}
+		return fmt.Errorf("failed to list the permissions of the abort identity with client ID %q on cluster %s/%s: %w",
+			abortIdentity.clientID, c.resourceGroupName, c.clusterName, err)
+	}
+
+	if !permissions.allows(abortAction) {
+		return fmt.Errorf("abort identity %s is not allowed to abort operations on cluster %s/%s; assign the %q (or a role granting %s) on the cluster or resource group",
+			c.describeIdentity(ctx, abortIdentity), c.resourceGroupName, c.clusterName, requiredRole, abortAction)
+	}
+	return nil
+}
+
+// HasAbortPermission reports whether the identity aborts are sent with holds the abort permission on the
+// cluster, by listing its permissions rather than sending an abort
+func (c *Client) HasAbortPermission(ctx context.Context) (bool, error) {
+	caller := c.abortIdentity
+	if caller == nil {
+		caller = c.readIdentity()
+	}
+	permissions, err := c.listPermissions(ctx, caller)
+	if err != nil {
+		return false, err
+	}
+	return permissions.allows(abortAction), nil
+}
+
+// permissionList is the response of the Microsoft.Authorization permissions API: the actions the caller
+// is allowed on a scope, one entry per role assignment
+type permissionList struct {
+	Value []struct {
+		Actions    []string `json:"actions"`
+		NotActions []string `json:"notActions"`
+	} `json:"value"`
+}
+
+// allows reports whether one of the role assignments grants action without excluding it
+func (p permissionList) allows(action string) bool {
+	for _, permission := range p.Value {
+		if matchesAnyAction(permission.Actions, action) && !matchesAnyAction(permission.NotActions, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAnyAction reports whether one of the patterns, which may contain * wildcards, matches action.
+// Action names are case-insensitive.
+func matchesAnyAction(patterns []string, action string) bool {
+	for _, pattern := range patterns {
+		expression := "(?i)^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+		if matched, err := regexp.MatchString(expression, action); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// listPermissions lists the permissions the identity holds on the cluster
+func (c *Client) listPermissions(ctx context.Context, caller *identity) (permissionList, error) {
+	endpoint := fmt.Sprintf("%s/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ContainerService/managedClusters/%s/providers/Microsoft.Authorization/permissions",
+		strings.TrimSuffix(caller.armClient.Endpoint(), "/"), url.PathEscape(c.subscriptionID), url.PathEscape(c.resourceGroupName), url.PathEscape(c.clusterName))
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	req, err := runtime.NewRequest(ctx, http.MethodGet, endpoint)
+	if err != nil {
+		return permissionList{}, err
+	}
+	query := req.Raw().URL.Query()
+	query.Set("api-version", permissionsAPIVersion)
+	req.Raw().URL.RawQuery = query.Encode()
+
+	resp, err := caller.armClient.Pipeline().Do(req)
+	if err != nil {
+		return permissionList{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return permissionList{}, runtime.NewResponseError(resp)
+	}
+	var permissions permissionList
+	if err := runtime.UnmarshalAsJSON(resp, &permissions); err != nil {
+		return permissionList{}, fmt.Errorf("failed to decode permissions: %w", err)
+	}
+	return permissions, nil
+}
+
+// readIdentity is the identity every request other than aborts is sent with
+func (c *Client) readIdentity() *identity {
+	return &identity{cred: c.cred, armClient: c.armClient, tenantID: c.tenantID, clientID: c.clientID}
+}
+
+// describeCredential names the credential of the read identity for authentication errors
+func (c *Client) describeCredential() string {
+	switch {
+	case c.authMode == config.AuthModeDefault:
+		return "with the default credential chain"
+	case c.clientID == "":
+		return fmt.Sprintf("with %s", c.authMode)
+	default:
+		return fmt.Sprintf("with %s for client ID %q in tenant %q", c.authMode, c.clientID, c.tenantID)
+	}
+}
+
+// credentialHint tells what to check when the read identity cannot authenticate
+func (c *Client) credentialHint() string {
+	switch c.authMode {
+	case config.AuthModeManagedIdentity:
+		return "check that the managed identity is assigned to the node pool's VMSS and, for a user-assigned identity, that AZURE_CLIENT_ID is its client ID"
+	case config.AuthModeWorkloadIdentity:
+		return "check the service account's azure.workload.identity/client-id annotation, the pod's azure.workload.identity/use label and the identity's federated credential"
+	case config.AuthModeDefault:
+		return "check the environment, workload identity, managed identity and Azure CLI credentials the chain tries"
+	default:
+		return "check AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET"
+	}
+}
+
+// describeIdentity names an identity the monitor authenticates as, including its object ID when the
+// access token carries one
+func (c *Client) describeIdentity(ctx context.Context, caller *identity) string {
+	description := fmt.Sprintf("with client ID %q", caller.clientID)
+	if caller.clientID == "" {
+		description = fmt.Sprintf("authenticated with %s", c.authMode)
+	}
+
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	token, err := caller.cred.GetToken(ctx, policy.TokenRequestOptions{
+		Scopes: []string{c.armScope},
+	})
+	if err != nil {
+		return description
+	}
+	if objectID := tokenClaim(token.Token, "oid"); objectID != "" {
+		description = fmt.Sprintf("%s (object ID %s)", description, objectID)
+	}
+	return description
+}
+
+// tokenClaim reads a string claim from a JWT without verifying it, returning "" if the token is not a JWT
+func tokenClaim(token, claim string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	value, _ := claims[claim].(string)
+	return value
+}
@@ -0,0 +1,162 @@
+package azure_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/azure/azuretest"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+)
+
+func TestPreflightDiagnostics(t *testing.T) {
+	tests := []struct {
+		name           string
+		subscriptionID string
+		setup          func(server *azuretest.Server)
+		wantErr        []string
+	}{
+		{name: "reachable cluster"},
+		{
+			name:           "malformed subscription ID",
+			subscriptionID: "my-subscription",
+			wantErr:        []string{`subscription ID "my-subscription" is not a UUID`},
+		},
+		{
+			name:    "role assignment missing",
+			setup:   func(server *azuretest.Server) { server.SetForbidden(true) },
+			wantErr: []string{`identity with client ID "client" is not allowed to read cluster rg/cluster`, "Azure Kubernetes Service Contributor Role", "AuthorizationFailed"},
+		},
+		{
+			name:    "resource group missing",
+			setup:   func(server *azuretest.Server) { server.SetResourceGroupMissing(true) },
+			wantErr: []string{`resource group "rg" does not exist`, "AZURE_RESOURCE_GROUP"},
+		},
+		{
+			name:    "cluster missing",
+			setup:   func(server *azuretest.Server) { server.SetClusterMissing(true) },
+			wantErr: []string{`cluster "cluster" does not exist in resource group "rg"`, "AZURE_CLUSTER_NAME"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			azureConfig := testAzureConfig()
+			azureConfig.ClientID = "client"
+			if test.subscriptionID != "" {
+				azureConfig.SubscriptionID = test.subscriptionID
+			}
+			server, client := newTestClient(t, azureConfig)
+			if test.setup != nil {
+				test.setup(server)
+			}
+
+			err := client.Preflight(context.Background())
+			if len(test.wantErr) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("preflight passed, want it to fail")
+			}
+			for _, want := range test.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error = %v, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPreflightDistinguishesMissingResourceGroupFromMissingCluster(t *testing.T) {
+	server, client := newTestClient(t, testAzureConfig())
+	server.SetClusterMissing(true)
+
+	if err := client.Preflight(context.Background()); err == nil || strings.Contains(err.Error(), "resource group \"rg\" does not exist") {
+		t.Fatalf("error = %v, want the cluster named as missing", err)
+	}
+	if server.CountRequests("GET", "/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/rg") == 0 {
+		t.Error("the resource group was not checked after the cluster was not found")
+	}
+}
+
+func TestPreflightAbortIdentityPermissions(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions []string
+		wantErr     bool
+	}{
+		{name: "wildcard", permissions: []string{"*"}},
+		{name: "provider wildcard", permissions: []string{"Microsoft.ContainerService/*"}},
+		{name: "abort action", permissions: []string{"microsoft.containerservice/managedclusters/abort/action"}},
+		{name: "read only", permissions: []string{"Microsoft.ContainerService/managedClusters/read"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			azureConfig := testAzureConfig()
+			azureConfig.AbortCredential.ClientID = "abort-client"
+			server := azuretest.NewServer()
+			t.Cleanup(server.Close)
+			server.SetPermissions(test.permissions...)
+			options := server.ClientOptions()
+			options.AbortCredential = azuretest.Credential{}
+			client, err := azure.NewClientWithCredential(azureConfig, azuretest.Credential{}, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = client.Preflight(context.Background())
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error = %v, want error: %v", err, test.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), `abort identity with client ID "abort-client" is not allowed to abort`) {
+				t.Errorf("error = %v, want it to name the abort identity", err)
+			}
+		})
+	}
+}
+
+func TestPreflightNamesUnreachableEndpoint(t *testing.T) {
+	// A port nothing listens on stands in for egress blocked by a firewall
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := listener.Addr().String()
+	listener.Close()
+
+	tests := []struct {
+		name     string
+		endpoint string
+		wantErr  string
+	}{
+		{name: "DNS failure", endpoint: "https://management.azure.invalid", wantErr: "cannot resolve Azure endpoint management.azure.invalid"},
+		{name: "connection refused", endpoint: "https://" + closed, wantErr: "cannot connect to Azure endpoint " + closed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := azuretest.NewServer()
+			t.Cleanup(server.Close)
+			options := server.ClientOptions()
+			options.ARM.Cloud.Services = map[cloud.ServiceName]cloud.ServiceConfiguration{
+				cloud.ResourceManager: {Audience: test.endpoint, Endpoint: test.endpoint},
+			}
+			client, err := azure.NewClientWithCredential(testAzureConfig(), azuretest.Credential{}, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = client.Preflight(context.Background())
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("error = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
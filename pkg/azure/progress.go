@@ -0,0 +1,43 @@
+package azure
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+)
+
+// ProgressSignals are values ARM reports that move while an operation is making progress. They let the
+// controller tell a long operation from a stuck one that ARM still reports as in progress.
+type ProgressSignals struct {
+	// CurrentKubernetesVersion is the control plane version actually running, which changes when a
+	// control plane upgrade completes
+	CurrentKubernetesVersion string `json:"currentKubernetesVersion,omitempty"`
+
+	// AgentPools maps each agent pool to "<current orchestrator version>/<node image version>/<node count>",
+	// which changes as nodes are upgraded, reimaged or scaled
+	AgentPools map[string]string `json:"agentPools,omitempty"`
+}
+
+// progressSignals reads the progress signals from the cluster properties
+func progressSignals(properties *armcontainerservice.ManagedClusterProperties) *ProgressSignals {
+	if properties == nil {
+		return nil
+	}
+
+	signals := &ProgressSignals{
+		CurrentKubernetesVersion: stringValue(properties.CurrentKubernetesVersion),
+		AgentPools:               make(map[string]string, len(properties.AgentPoolProfiles)),
+	}
+	for _, pool := range properties.AgentPoolProfiles {
+		if pool == nil || pool.Name == nil {
+			continue
+		}
+		var count int32
+		if pool.Count != nil {
+			count = *pool.Count
+		}
+		signals.AgentPools[*pool.Name] = fmt.Sprintf("%s/%s/%d",
+			stringValue(pool.CurrentOrchestratorVersion), stringValue(pool.NodeImageVersion), count)
+	}
+	return signals
+}
@@ -0,0 +1,110 @@
+package azure
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+)
+
+// ProvisioningError describes an error reported by ARM on the cluster or one of its agent pools
+type ProvisioningError struct {
+	// Source is "cluster" for the managed cluster itself or "agentPool/<name>" for an agent pool
+	Source  string `json:"source"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// rawErrorBody mirrors the ARM error body shape used by provisioningError fields
+type rawErrorBody struct {
+	Code    *string        `json:"code"`
+	Message *string        `json:"message"`
+	Details []rawErrorBody `json:"details"`
+}
+
+// rawStatus mirrors the optional status block that newer API versions attach to clusters and agent pools
+type rawStatus struct {
+	ProvisioningError *rawErrorBody `json:"provisioningError"`
+}
+
+// rawCluster is the subset of the managed cluster JSON needed to extract provisioning errors.
+// The typed SDK models do not expose these fields, so they are read from the raw response body.
+type rawCluster struct {
+	Properties *struct {
+		Status            *rawStatus `json:"status"`
+		AgentPoolProfiles []*struct {
+			Name   *string    `json:"name"`
+			Status *rawStatus `json:"status"`
+		} `json:"agentPoolProfiles"`
+	} `json:"properties"`
+}
+
+// extractProvisioningErrors collects provisioning errors from the raw cluster response and the typed model.
+// Missing or malformed error structures are ignored rather than treated as failures.
+func extractProvisioningErrors(rawResp *http.Response, cluster *armcontainerservice.ManagedCluster) []ProvisioningError {
+	var errs []ProvisioningError
+
+	if rawResp != nil {
+		if body, err := runtime.Payload(rawResp); err == nil && len(body) > 0 {
+			var raw rawCluster
+			if err := json.Unmarshal(body, &raw); err == nil && raw.Properties != nil {
+				if raw.Properties.Status != nil {
+					errs = appendErrorBody(errs, "cluster", raw.Properties.Status.ProvisioningError)
+				}
+				for _, pool := range raw.Properties.AgentPoolProfiles {
+					if pool == nil || pool.Status == nil {
+						continue
+					}
+					errs = appendErrorBody(errs, "agentPool/"+stringValue(pool.Name), pool.Status.ProvisioningError)
+				}
+			}
+		}
+	}
+
+	// Pod identity provisioning errors are part of the typed model
+	if cluster != nil && cluster.Properties != nil && cluster.Properties.PodIdentityProfile != nil {
+		for _, identity := range cluster.Properties.PodIdentityProfile.UserAssignedIdentities {
+			if identity == nil || identity.ProvisioningInfo == nil || identity.ProvisioningInfo.Error == nil {
+				continue
+			}
+			body := identity.ProvisioningInfo.Error.Error
+			if body == nil {
+				continue
+			}
+			errs = append(errs, ProvisioningError{
+				Source:  "podIdentity/" + stringValue(identity.Name),
+				Code:    stringValue(body.Code),
+				Message: stringValue(body.Message),
+			})
+		}
+	}
+
+	return errs
+}
+
+// appendErrorBody appends the error and, when the top-level error carries no message, its first detail
+func appendErrorBody(errs []ProvisioningError, source string, body *rawErrorBody) []ProvisioningError {
+	if body == nil || (body.Code == nil && body.Message == nil) {
+		return errs
+	}
+
+	provisioningErr := ProvisioningError{
+		Source:  source,
+		Code:    stringValue(body.Code),
+		Message: stringValue(body.Message),
+	}
+	if provisioningErr.Message == "" && len(body.Details) > 0 {
+		provisioningErr.Message = stringValue(body.Details[0].Message)
+	}
+
+	return append(errs, provisioningErr)
+}
+
+// stringValue safely dereferences an optional string
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,104 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"k8s.io/klog/v2"
+)
+
+// resourceGraphAPIVersion is the Resource Graph API version used for provisioning state queries
+const resourceGraphAPIVersion = "2021-03-01"
+
+// resourceGraphResponse is the subset of a Resource Graph query response the monitor reads
+type resourceGraphResponse struct {
+	Data []struct {
+		ProvisioningState string `json:"provisioningState"`
+	} `json:"data"`
+}
+
+// graphOperationStatus reads the cluster's provisioning state from Azure Resource Graph, which does not
+// count against the subscription's ARM read quota. Graph lags ARM, so its answer is only used when the
+// cluster is idle (Succeeded) and a direct ARM read happened within the configured maximum staleness.
+// Anything else, including Graph errors, returns false so the caller falls back to ARM.
+func (c *Client) graphOperationStatus(ctx context.Context) (*OperationStatus, bool) {
+	c.mu.Lock()
+	lastARMRead := c.lastARMRead
+	c.mu.Unlock()
+
+	staleFor := time.Since(lastARMRead)
+	if lastARMRead.IsZero() || staleFor >= c.graphMaxStaleness {
+		return nil, false
+	}
+
+	state, err := c.queryProvisioningState(ctx)
+	if err != nil {
+		klog.Warningf("Resource Graph query failed, falling back to ARM: %v", err)
+		return nil, false
+	}
+	if state != "Succeeded" {
+		// An operation may be starting or failing; read the authoritative state and errors from ARM
+		return nil, false
+	}
+
+	return &OperationStatus{Status: state, Source: config.StatusSourceResourceGraph, StaleFor: staleFor}, true
+}
+
+// queryProvisioningState runs a Resource Graph query for the cluster's provisioning state
+func (c *Client) queryProvisioningState(ctx context.Context) (string, error) {
+	query := fmt.Sprintf("resources | where type =~ 'microsoft.containerservice/managedclusters' and resourceGroup =~ '%s' and name =~ '%s' | project provisioningState = tostring(properties.provisioningState)",
+		kqlEscape(c.resourceGroupName), kqlEscape(c.clusterName))
+
+	endpoint := strings.TrimSuffix(c.armClient.Endpoint(), "/") + "/providers/Microsoft.ResourceGraph/resources"
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	req, err := runtime.NewRequest(ctx, http.MethodPost, endpoint)
+	if err != nil {
+		return "", err
+	}
+	values := req.Raw().URL.Query()
+	values.Set("api-version", resourceGraphAPIVersion)
+	req.Raw().URL.RawQuery = values.Encode()
+	if err := runtime.MarshalAsJSON(req, map[string]interface{}{
+		"subscriptions": []string{c.subscriptionID},
+		"query":         query,
+		"options":       map[string]string{"resultFormat": "objectArray"},
+	}); err != nil {
+		return "", err
+	}
+
+	resp, err := c.armClient.Pipeline().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", runtime.NewResponseError(resp)
+	}
+
+	body, err := runtime.Payload(resp)
+	if err != nil {
+		return "", err
+	}
+	var result resourceGraphResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to decode Resource Graph response: %w", err)
+	}
+	if len(result.Data) == 0 {
+		return "", fmt.Errorf("cluster %s/%s not found in Resource Graph", c.resourceGroupName, c.clusterName)
+	}
+
+	return result.Data[0].ProvisioningState, nil
+}
+
+// kqlEscape escapes a value for use inside a single-quoted KQL string literal
+func kqlEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+}
@@ -0,0 +1,67 @@
+package azure
+
+import (
+	"errors"
+	"net/http"
+
+	"aks-health-monitor/pkg/config"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// ErrOperationFinished is returned by AbortClusterOperation when ARM answered 409 Conflict: the operation
+// finished before the abort could take effect. There is nothing left to abort, so it is not a failure.
+var ErrOperationFinished = errors.New("operation finished before the abort could take effect")
+
+// ARMRequestIDs identify a request in ARM's logs; Azure support asks for them when investigating a call
+type ARMRequestIDs struct {
+	CorrelationID string `json:"correlationId,omitempty"`
+	RequestID     string `json:"requestId,omitempty"`
+}
+
+// armRequestIDs reads the request IDs from an ARM response, which may be nil
+func armRequestIDs(resp *http.Response) ARMRequestIDs {
+	if resp == nil {
+		return ARMRequestIDs{}
+	}
+	return ARMRequestIDs{
+		CorrelationID: resp.Header.Get("x-ms-correlation-request-id"),
+		RequestID:     resp.Header.Get("x-ms-request-id"),
+	}
+}
+
+// retryStatusCodes are the ARM answers that are retried: request timeouts, throttling and server failures
+var retryStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryOptions converts the retry settings to the SDK's retry policy, which waits for Retry-After on
+// throttled responses and otherwise backs off exponentially, with jitter, up to MaxRetryDelay
+func RetryOptions(retry config.ARMRetryConfig) policy.RetryOptions {
+	options := policy.RetryOptions{
+		MaxRetries:    int32(retry.MaxRetries),
+		RetryDelay:    retry.RetryDelay,
+		MaxRetryDelay: retry.MaxRetryDelay,
+		StatusCodes:   retryStatusCodes,
+	}
+	if retry.MaxRetries == 0 {
+		// Zero means the SDK default; the config's zero means no retries
+		options.MaxRetries = -1
+	}
+	return options
+}
+
+// responseStatus returns the HTTP status of the ARM answer err wraps, or 0 when ARM did not answer
+func responseStatus(err error) int {
+	var responseErr *azcore.ResponseError
+	if errors.As(err, &responseErr) {
+		return responseErr.StatusCode
+	}
+	return 0
+}
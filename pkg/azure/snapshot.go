@@ -0,0 +1,186 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+)
+
+// ClusterSnapshot is the managed cluster as returned by one ARM GET. A health check cycle reads the cluster
+// once, through GetClusterOperationStatus, and every consumer of the cycle reads the same snapshot.
+type ClusterSnapshot struct {
+	// FetchedAt is when the cluster was read
+	FetchedAt time.Time
+
+	Name              string
+	Location          string
+	ProvisioningState string
+
+	// KubernetesVersion is the target version; CurrentKubernetesVersion is what the control plane runs
+	KubernetesVersion        string
+	CurrentKubernetesVersion string
+
+	cluster armcontainerservice.ManagedCluster
+
+	// errors are the provisioning errors read from the raw response, which the SDK models omit
+	errors []ProvisioningError
+}
+
+// FetchClusterSnapshot reads the cluster from ARM. Use the snapshot attached to the cycle's OperationStatus
+// rather than calling this again within a cycle.
+func (c *Client) FetchClusterSnapshot(ctx context.Context) (*ClusterSnapshot, error) {
+	// Capture the raw response to read error fields the SDK models omit
+	var rawResp *http.Response
+	callCtx, cancel := c.callContext(ctx)
+	defer cancel()
+	cluster, err := c.aksClient.Get(policy.WithCaptureResponse(callCtx, &rawResp), c.resourceGroupName, c.clusterName, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster: %w", err)
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	c.lastARMRead = now
+	c.mu.Unlock()
+
+	snapshot := &ClusterSnapshot{
+		FetchedAt: now,
+		Name:      c.clusterName,
+		Location:  stringValue(cluster.Location),
+		cluster:   cluster.ManagedCluster,
+	}
+	if properties := cluster.Properties; properties != nil {
+		snapshot.ProvisioningState = stringValue(properties.ProvisioningState)
+		snapshot.KubernetesVersion = stringValue(properties.KubernetesVersion)
+		snapshot.CurrentKubernetesVersion = stringValue(properties.CurrentKubernetesVersion)
+
+		// Read provisioning error details when the operation failed or may be stuck
+		if properties.ProvisioningState != nil && snapshot.ProvisioningState != "Succeeded" {
+			snapshot.errors = extractProvisioningErrors(rawResp, &snapshot.cluster)
+		}
+	}
+	return snapshot, nil
+}
+
+// ControlPlaneVersion returns the version the control plane runs, or the target version when ARM does not
+// report it
+func (s *ClusterSnapshot) ControlPlaneVersion() string {
+	if s.CurrentKubernetesVersion != "" {
+		return s.CurrentKubernetesVersion
+	}
+	return s.KubernetesVersion
+}
+
+// OperationStatus derives the operation status from the snapshot, which it carries for later consumers
+func (s *ClusterSnapshot) OperationStatus() *OperationStatus {
+	status := &OperationStatus{
+		InProgress:    false,
+		OperationType: "",
+		Status:        "",
+		Source:        config.StatusSourceARM,
+		Snapshot:      s,
+	}
+
+	properties := s.cluster.Properties
+	if properties == nil || properties.ProvisioningState == nil {
+		return status
+	}
+	status.Status = s.ProvisioningState
+
+	// Determine if operation is in progress
+	switch s.ProvisioningState {
+	case "Upgrading", "Updating", "Scaling", "Creating", "Deleting":
+		status.InProgress = true
+		status.OperationType = s.ProvisioningState
+		status.Progress = progressSignals(properties)
+		if s.ProvisioningState == "Scaling" {
+			attribution := attributeScaleOperation(properties.AgentPoolProfiles)
+			status.Attribution = &attribution
+		}
+		if s.ProvisioningState == "Upgrading" {
+			classification := classifyUpgrade(properties)
+			status.Upgrade = &classification
+		}
+	case "Succeeded", "Failed", "Canceled":
+		status.InProgress = false
+	default:
+		// Unknown state, assume not in progress
+		status.InProgress = false
+	}
+	status.Errors = s.errors
+	return status
+}
+
+// Info returns basic information about the cluster, as GetClusterInfo reports it
+func (s *ClusterSnapshot) Info() map[string]interface{} {
+	info := map[string]interface{}{
+		"name":     s.Name,
+		"location": s.Location,
+	}
+
+	if properties := s.cluster.Properties; properties != nil {
+		info["provisioningState"] = properties.ProvisioningState
+		info["kubernetesVersion"] = properties.KubernetesVersion
+		info["currentKubernetesVersion"] = properties.CurrentKubernetesVersion
+
+		if len(properties.AgentPoolProfiles) > 0 {
+			info["nodeCount"] = properties.AgentPoolProfiles[0].Count
+		}
+	}
+	return info
+}
+
+// AgentPoolState is an agent pool as ARM reported it in the snapshot
+type AgentPoolState struct {
+	Name                       string `json:"name"`
+	Mode                       string `json:"mode,omitempty"`
+	ProvisioningState          string `json:"provisioningState,omitempty"`
+	PowerState                 string `json:"powerState,omitempty"`
+	Count                      int32  `json:"count"`
+	OrchestratorVersion        string `json:"orchestratorVersion,omitempty"`
+	CurrentOrchestratorVersion string `json:"currentOrchestratorVersion,omitempty"`
+	NodeImageVersion           string `json:"nodeImageVersion,omitempty"`
+	MaxSurge                   string `json:"maxSurge,omitempty"`
+}
+
+// AgentPools returns the state of every agent pool in the snapshot
+func (s *ClusterSnapshot) AgentPools() []AgentPoolState {
+	properties := s.cluster.Properties
+	if properties == nil {
+		return nil
+	}
+
+	pools := make([]AgentPoolState, 0, len(properties.AgentPoolProfiles))
+	for _, pool := range properties.AgentPoolProfiles {
+		if pool == nil || pool.Name == nil {
+			continue
+		}
+		state := AgentPoolState{
+			Name:                       *pool.Name,
+			ProvisioningState:          stringValue(pool.ProvisioningState),
+			OrchestratorVersion:        stringValue(pool.OrchestratorVersion),
+			CurrentOrchestratorVersion: stringValue(pool.CurrentOrchestratorVersion),
+			NodeImageVersion:           stringValue(pool.NodeImageVersion),
+		}
+		if pool.Mode != nil {
+			state.Mode = string(*pool.Mode)
+		}
+		if pool.PowerState != nil && pool.PowerState.Code != nil {
+			state.PowerState = string(*pool.PowerState.Code)
+		}
+		if pool.Count != nil {
+			state.Count = *pool.Count
+		}
+		if pool.UpgradeSettings != nil {
+			state.MaxSurge = stringValue(pool.UpgradeSettings.MaxSurge)
+		}
+		pools = append(pools, state)
+	}
+	return pools
+}
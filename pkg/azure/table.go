@@ -0,0 +1,306 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"aks-health-monitor/pkg/storage"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+)
+
+const (
+	// storageTableScope is the token scope of the Table service data plane
+	storageTableScope = "https://storage.azure.com/.default"
+
+	// storageTableAPIVersion is the Table service version; bearer tokens need 2019-02-02 or later
+	storageTableAPIVersion = "2019-02-02"
+
+	// tableMaxRetries is how many times a failed Table request is retried
+	tableMaxRetries = 3
+
+	// tableTimeFormat matches the 100ns precision of Edm.DateTime
+	tableTimeFormat = "2006-01-02T15:04:05.0000000Z"
+)
+
+// TableStore stores results in Azure Table Storage, authenticating with the client's credential. All of a
+// cluster's results share one partition; row keys count down with time so the newest results sort first.
+type TableStore struct {
+	pipeline  runtime.Pipeline
+	tableURL  string
+	partition string
+}
+
+// tableEntity is a stored result as the Table service sees it
+type tableEntity struct {
+	PartitionKey  string `json:"PartitionKey"`
+	RowKey        string `json:"RowKey"`
+	CycleTime     string `json:"CycleTime,omitempty"`
+	CycleTimeType string `json:"CycleTime@odata.type,omitempty"`
+	TraceID       string `json:"TraceId,omitempty"`
+	Data          string `json:"Data,omitempty"`
+}
+
+// NewTableStore creates a store for the table at tableURL, e.g. https://myaccount.table.core.windows.net/aksmonitor.
+// The table must exist and the identity needs Storage Table Data Contributor on it.
+func (c *Client) NewTableStore(tableURL string) *TableStore {
+	return &TableStore{
+		pipeline:  c.dataPlanePipeline(storageTableScope, tableMaxRetries),
+		tableURL:  strings.TrimSuffix(tableURL, "/"),
+		partition: c.resourceGroupName + "_" + c.clusterName,
+	}
+}
+
+// rowKey orders results newest first and keeps results of the same instant apart by trace ID
+func rowKey(timestamp time.Time, traceID string) string {
+	return fmt.Sprintf("%019d_%s", math.MaxInt64-timestamp.UnixNano(), traceID)
+}
+
+// Save implements storage.Store
+func (s *TableStore) Save(ctx context.Context, record storage.Record) error {
+	body, err := json.Marshal(tableEntity{
+		PartitionKey:  s.partition,
+		RowKey:        rowKey(record.Timestamp, record.TraceID),
+		CycleTime:     record.Timestamp.UTC().Format(tableTimeFormat),
+		CycleTimeType: "Edm.DateTime",
+		TraceID:       record.TraceID,
+		Data:          string(record.Data),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := s.newRequest(ctx, http.MethodPost, s.tableURL)
+	if err != nil {
+		return err
+	}
+	req.Raw().Header.Set("Prefer", "return-no-content")
+	if err := req.SetBody(streaming.NopCloser(bytes.NewReader(body)), "application/json"); err != nil {
+		return err
+	}
+
+	resp, err := s.pipeline.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return runtime.NewResponseError(resp)
+	}
+	return nil
+}
+
+// List implements storage.Store
+func (s *TableStore) List(ctx context.Context, limit int) ([]storage.Record, error) {
+	var records []storage.Record
+	err := s.query(ctx, s.partitionFilter(), "CycleTime,TraceId,Data", limit, func(entity tableEntity) error {
+		timestamp, err := time.Parse(time.RFC3339Nano, entity.CycleTime)
+		if err != nil {
+			return fmt.Errorf("malformed CycleTime %q: %w", entity.CycleTime, err)
+		}
+		records = append(records, storage.Record{Timestamp: timestamp, TraceID: entity.TraceID, Data: []byte(entity.Data)})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows arrive newest first
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records, nil
+}
+
+// Prune implements storage.Store. The Table service has no bulk delete, so old rows are listed and
+// deleted one at a time.
+func (s *TableStore) Prune(ctx context.Context, before time.Time) (int, error) {
+	var keys []string
+	// Row keys count down: results older than before sort above the bare key of the nanosecond before it
+	filter := fmt.Sprintf("%s and RowKey gt '%s'", s.partitionFilter(), odataEscape(rowKey(before.Add(-time.Nanosecond), "")))
+	if err := s.query(ctx, filter, "RowKey", 0, func(entity tableEntity) error {
+		keys = append(keys, entity.RowKey)
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	for deleted, key := range keys {
+		req, err := s.newRequest(ctx, http.MethodDelete, s.entityURL(s.partition, key))
+		if err != nil {
+			return deleted, err
+		}
+		req.Raw().Header.Set("If-Match", "*")
+
+		resp, err := s.pipeline.Do(req)
+		if err != nil {
+			return deleted, err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+			return deleted, runtime.NewResponseError(resp)
+		}
+	}
+	return len(keys), nil
+}
+
+// SaveState implements storage.Store. State rows live in their own partition so result queries never see them.
+func (s *TableStore) SaveState(ctx context.Context, key string, data []byte) error {
+	body, err := json.Marshal(tableEntity{
+		PartitionKey: s.statePartition(),
+		RowKey:       key,
+		Data:         string(data),
+	})
+	if err != nil {
+		return err
+	}
+
+	// PUT without If-Match is Insert Or Replace
+	req, err := s.newRequest(ctx, http.MethodPut, s.entityURL(s.statePartition(), key))
+	if err != nil {
+		return err
+	}
+	if err := req.SetBody(streaming.NopCloser(bytes.NewReader(body)), "application/json"); err != nil {
+		return err
+	}
+
+	resp, err := s.pipeline.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return runtime.NewResponseError(resp)
+	}
+	return nil
+}
+
+// LoadState implements storage.Store
+func (s *TableStore) LoadState(ctx context.Context, key string) ([]byte, error) {
+	req, err := s.newRequest(ctx, http.MethodGet, s.entityURL(s.statePartition(), key)+"?$select=Data")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.pipeline.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, runtime.NewResponseError(resp)
+	}
+
+	var entity tableEntity
+	if err := runtime.UnmarshalAsJSON(resp, &entity); err != nil {
+		return nil, err
+	}
+	return []byte(entity.Data), nil
+}
+
+// Close implements storage.Store
+func (s *TableStore) Close() error {
+	return nil
+}
+
+// query pages through the entities matching filter, calling visit for each until limit entities were
+// visited; a zero limit visits all of them
+func (s *TableStore) query(ctx context.Context, filter, selectFields string, limit int, visit func(tableEntity) error) error {
+	var nextPartition, nextRow string
+	visited := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		params := url.Values{}
+		params.Set("$filter", filter)
+		params.Set("$select", selectFields)
+		if limit > 0 {
+			params.Set("$top", fmt.Sprint(limit-visited))
+		}
+		if nextPartition != "" {
+			params.Set("NextPartitionKey", nextPartition)
+			params.Set("NextRowKey", nextRow)
+		}
+
+		req, err := s.newRequest(ctx, http.MethodGet, s.tableURL+"()?"+strings.ReplaceAll(params.Encode(), "+", "%20"))
+		if err != nil {
+			return err
+		}
+		resp, err := s.pipeline.Do(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			return runtime.NewResponseError(resp)
+		}
+
+		var page struct {
+			Value []tableEntity `json:"value"`
+		}
+		if err := runtime.UnmarshalAsJSON(resp, &page); err != nil {
+			return err
+		}
+		for _, entity := range page.Value {
+			if err := visit(entity); err != nil {
+				return err
+			}
+			visited++
+			if limit > 0 && visited >= limit {
+				return nil
+			}
+		}
+
+		nextPartition = resp.Header.Get("x-ms-continuation-NextPartitionKey")
+		nextRow = resp.Header.Get("x-ms-continuation-NextRowKey")
+		if nextPartition == "" {
+			return nil
+		}
+	}
+}
+
+// newRequest creates a Table service request with the headers every operation needs
+func (s *TableStore) newRequest(ctx context.Context, method, endpoint string) (*policy.Request, error) {
+	req, err := runtime.NewRequest(ctx, method, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	req.Raw().Header.Set("x-ms-version", storageTableAPIVersion)
+	req.Raw().Header.Set("Accept", "application/json;odata=nometadata")
+	req.Raw().Header.Set("DataServiceVersion", "3.0")
+	return req, nil
+}
+
+// entityURL addresses one entity by its keys
+func (s *TableStore) entityURL(partition, key string) string {
+	return fmt.Sprintf("%s(PartitionKey='%s',RowKey='%s')", s.tableURL,
+		url.PathEscape(odataEscape(partition)), url.PathEscape(odataEscape(key)))
+}
+
+// statePartition holds the cluster's controller state, apart from its results
+func (s *TableStore) statePartition() string {
+	return s.partition + "_state"
+}
+
+// partitionFilter selects the cluster's partition
+func (s *TableStore) partitionFilter() string {
+	return fmt.Sprintf("PartitionKey eq '%s'", odataEscape(s.partition))
+}
+
+// odataEscape escapes a string literal for an OData filter or key
+func odataEscape(value string) string {
+	return strings.ReplaceAll(value, "'", "''")
+}
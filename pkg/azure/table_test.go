@@ -0,0 +1,43 @@
+package azure_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/storage"
+	"aks-health-monitor/pkg/storage/storagetest"
+)
+
+func TestTableStore(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Store {
+		server, client := newTestClient(t, testAzureConfig())
+		// Small pages so listing and pruning follow continuation tokens
+		server.SetTablePageSize(2)
+		return client.NewTableStore(server.TableURL("aksmonitor"))
+	})
+}
+
+func TestTableStoreKeepsClusterPartitions(t *testing.T) {
+	server, client := newTestClient(t, testAzureConfig())
+	store := client.NewTableStore(server.TableURL("aksmonitor"))
+	ctx := context.Background()
+	now := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		record := storage.Record{Timestamp: now.Add(time.Duration(i) * time.Minute), TraceID: fmt.Sprintf("trace-%d", i), Data: []byte("{}")}
+		if err := store.Save(ctx, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.SaveState(ctx, "baselines", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := server.TableEntities("aksmonitor", "rg_cluster"); got != 3 {
+		t.Errorf("results partition holds %d entities, want 3", got)
+	}
+	if got := server.TableEntities("aksmonitor", "rg_cluster_state"); got != 1 {
+		t.Errorf("state partition holds %d entities, want 1", got)
+	}
+}
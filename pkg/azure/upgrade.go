@@ -0,0 +1,68 @@
+package azure
+
+import (
+	"fmt"
+	"strings"
+
+	"aks-health-monitor/pkg/config"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+)
+
+// UpgradeClassification records which part of the cluster an Upgrading operation is changing
+type UpgradeClassification struct {
+	// Class is config.OperationControlPlaneUpgrade, config.OperationNodePoolUpgrade, or empty when
+	// the evidence is inconclusive
+	Class    string `json:"class,omitempty"`
+	Evidence string `json:"evidence"`
+}
+
+// classifyUpgrade tells a control plane upgrade from a node pool upgrade. Pools that are upgrading or
+// not yet on their target version mean nodes are being replaced; otherwise a cluster whose target
+// version differs from its current version is upgrading only the managed control plane.
+func classifyUpgrade(properties *armcontainerservice.ManagedClusterProperties) UpgradeClassification {
+	if properties == nil {
+		return UpgradeClassification{Evidence: "cluster properties unavailable"}
+	}
+
+	var upgrading, pending []string
+	for _, pool := range properties.AgentPoolProfiles {
+		if pool == nil {
+			continue
+		}
+		name := stringValue(pool.Name)
+		if stringValue(pool.ProvisioningState) == "Upgrading" {
+			upgrading = append(upgrading, name)
+			continue
+		}
+		target, current := stringValue(pool.OrchestratorVersion), stringValue(pool.CurrentOrchestratorVersion)
+		if target != "" && current != "" && target != current {
+			pending = append(pending, fmt.Sprintf("%s (%s -> %s)", name, current, target))
+		}
+	}
+
+	target, current := stringValue(properties.KubernetesVersion), stringValue(properties.CurrentKubernetesVersion)
+	controlPlaneChanging := target != "" && current != "" && target != current
+
+	switch {
+	case len(upgrading) > 0:
+		return UpgradeClassification{
+			Class:    config.OperationNodePoolUpgrade,
+			Evidence: "agent pools upgrading: " + strings.Join(upgrading, ", "),
+		}
+	case controlPlaneChanging:
+		evidence := fmt.Sprintf("control plane %s -> %s with no agent pool upgrading", current, target)
+		if len(pending) > 0 {
+			// A full cluster upgrade moves the control plane first; pools follow in a later phase
+			evidence += "; pools awaiting upgrade: " + strings.Join(pending, ", ")
+		}
+		return UpgradeClassification{Class: config.OperationControlPlaneUpgrade, Evidence: evidence}
+	case len(pending) > 0:
+		return UpgradeClassification{
+			Class:    config.OperationNodePoolUpgrade,
+			Evidence: "agent pools behind their target version: " + strings.Join(pending, ", "),
+		}
+	default:
+		return UpgradeClassification{Evidence: fmt.Sprintf("control plane and agent pools at their target versions (control plane %s)", current)}
+	}
+}
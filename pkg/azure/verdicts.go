@@ -0,0 +1,154 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+)
+
+// Token scopes for the messaging data planes
+const (
+	serviceBusScope   = "https://servicebus.azure.net/.default"
+	storageQueueScope = "https://storage.azure.com/.default"
+
+	// storageQueueAPIVersion is the Queue service version; bearer tokens need 2017-11-09 or later
+	storageQueueAPIVersion = "2021-12-02"
+)
+
+// Verdict kinds
+const (
+	VerdictAborted              = "aborted"
+	VerdictSustainedDegradation = "sustained_degradation"
+	VerdictHeartbeat            = "heartbeat"
+)
+
+// Verdict is the message published for downstream automation
+type Verdict struct {
+	// MessageID is unique per verdict so consumers and Service Bus duplicate detection can drop redeliveries
+	MessageID string    `json:"messageId"`
+	Kind      string    `json:"kind"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// Cluster is the managed cluster resource ID
+	Cluster     string   `json:"cluster"`
+	Operation   string   `json:"operation"`
+	Violations  []string `json:"violations"`
+	Action      string   `json:"action"`
+	HealthState int      `json:"healthState"`
+
+	// Cycle is the number of cycles the monitor has run; only set on heartbeats
+	Cycle uint64 `json:"cycle,omitempty"`
+
+	// Guidance carries the description and runbook link configured for each violated metric that has one
+	Guidance []VerdictGuidance `json:"guidance,omitempty"`
+
+	// TraceID correlates the verdict with the cycle's logs, history entry and metric exemplars
+	TraceID string `json:"traceId"`
+}
+
+// VerdictGuidance tells the consumer of a verdict what a violation means and where its runbook is
+type VerdictGuidance struct {
+	Metric      string `json:"metric"`
+	Description string `json:"description,omitempty"`
+	RunbookURL  string `json:"runbookURL,omitempty"`
+}
+
+// VerdictPublisher sends verdicts to a Service Bus queue or topic or a Storage queue, authenticating
+// with the client's credential. Sends are retried by the pipeline; see config.VerdictsConfig.
+type VerdictPublisher struct {
+	pipeline     runtime.Pipeline
+	url          string
+	storageQueue bool
+}
+
+// NewVerdictPublisher creates a publisher for the configured destination. It must only be called when
+// cfg.Enabled() is true.
+func (c *Client) NewVerdictPublisher(cfg config.VerdictsConfig) *VerdictPublisher {
+	publisher := &VerdictPublisher{url: strings.TrimSuffix(cfg.ServiceBusURL, "/")}
+	scope := serviceBusScope
+	if cfg.StorageQueueURL != "" {
+		publisher.url = strings.TrimSuffix(cfg.StorageQueueURL, "/")
+		publisher.storageQueue = true
+		scope = storageQueueScope
+	}
+
+	publisher.pipeline = c.dataPlanePipeline(scope, cfg.MaxRetries)
+	return publisher
+}
+
+// dataPlanePipeline creates a pipeline that authenticates with the client's credential for the given
+// scope and retries failed requests maxRetries times
+func (c *Client) dataPlanePipeline(scope string, maxRetries int) runtime.Pipeline {
+	var options policy.ClientOptions
+	if c.armOptions != nil {
+		options = c.armOptions.ClientOptions
+	}
+	options.Retry.MaxRetries = int32(maxRetries)
+	if maxRetries == 0 {
+		// Zero means the SDK default; the config's zero means no retries
+		options.Retry.MaxRetries = -1
+	}
+
+	return runtime.NewPipeline("aks-health-monitor", "v1.0.0", runtime.PipelineOptions{
+		PerRetry: []policy.Policy{runtime.NewBearerTokenPolicy(c.cred, []string{scope}, nil)},
+	}, &options)
+}
+
+// Publish sends one verdict, returning the last error once retries are exhausted
+func (p *VerdictPublisher) Publish(ctx context.Context, verdict Verdict) error {
+	payload, err := json.Marshal(verdict)
+	if err != nil {
+		return fmt.Errorf("failed to encode verdict: %w", err)
+	}
+
+	req, err := runtime.NewRequest(ctx, http.MethodPost, p.url+"/messages")
+	if err != nil {
+		return err
+	}
+
+	if p.storageQueue {
+		// Queue messages are XML envelopes; base64 keeps the JSON intact for any consumer
+		body, err := xml.Marshal(struct {
+			XMLName     xml.Name `xml:"QueueMessage"`
+			MessageText string   `xml:"MessageText"`
+		}{MessageText: base64.StdEncoding.EncodeToString(payload)})
+		if err != nil {
+			return fmt.Errorf("failed to encode queue message: %w", err)
+		}
+		req.Raw().Header.Set("x-ms-version", storageQueueAPIVersion)
+		if err := req.SetBody(streaming.NopCloser(bytes.NewReader(body)), "application/xml"); err != nil {
+			return err
+		}
+	} else {
+		brokerProperties, err := json.Marshal(map[string]string{"MessageId": verdict.MessageID, "CorrelationId": verdict.TraceID})
+		if err != nil {
+			return err
+		}
+		req.Raw().Header.Set("BrokerProperties", string(brokerProperties))
+		if err := req.SetBody(streaming.NopCloser(bytes.NewReader(payload)), "application/json"); err != nil {
+			return err
+		}
+	}
+
+	resp, err := p.pipeline.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return runtime.NewResponseError(resp)
+	}
+	return nil
+}
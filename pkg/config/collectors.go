@@ -0,0 +1,156 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Failure policies of a collector
+const (
+	// CollectorFailurePolicyFail fails the whole collection, and so the cycle, when the collector fails
+	CollectorFailurePolicyFail = "fail"
+	// CollectorFailurePolicySkip leaves the collector's metrics out of the cycle and records the error
+	CollectorFailurePolicySkip = "skip"
+)
+
+// coreCollectors feed the pod, node and job thresholds every operation is judged by. They cannot be
+// disabled and their failures always fail the cycle.
+var coreCollectors = []string{"nodes", "pods", "jobs"}
+
+// optionalCollectors may be disabled, and by default a failure only leaves their metrics out
+var optionalCollectors = []string{"admission", "configReferences", "daemonSets", "usage", "flapping", "zoneRedundancy"}
+
+// CollectorConfig configures one collector. Blocks are keyed by collector name:
+//
+//	collectors:
+//	  pods:
+//	    timeout: 20s
+//	  usage:
+//	    enabled: false
+//	  admission:
+//	    failurePolicy: fail
+type CollectorConfig struct {
+	// Enabled runs the collector; blocks default to true
+	Enabled bool `yaml:"enabled"`
+
+	// Timeout bounds one run of the collector; 0 leaves it bounded by the cycle budget only
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	// FailurePolicy is "fail" or "skip"; empty uses "fail" for core collectors and "skip" for the others
+	FailurePolicy string `yaml:"failurePolicy,omitempty"`
+
+	// parseErr keeps a block that could not be decoded from failing the whole configuration
+	parseErr error
+}
+
+// UnmarshalYAML defaults Enabled to true and records a block that cannot be decoded instead of failing,
+// so it is rejected on its own
+func (c *CollectorConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	// plain has no UnmarshalYAML method, avoiding recursion
+	type plain CollectorConfig
+	block := plain{Enabled: true}
+	if err := unmarshal(&block); err != nil {
+		*c = CollectorConfig{Enabled: true, parseErr: err}
+		return nil
+	}
+	*c = CollectorConfig(block)
+	return nil
+}
+
+// IsCoreCollector reports whether the named collector is one of the core pod, node and job collectors
+func IsCoreCollector(name string) bool {
+	for _, core := range coreCollectors {
+		if name == core {
+			return true
+		}
+	}
+	return false
+}
+
+// SkipsOnFailure reports whether a failure of the named collector leaves its metrics out instead of
+// failing the cycle
+func (c CollectorConfig) SkipsOnFailure(name string) bool {
+	if c.FailurePolicy == "" {
+		return !IsCoreCollector(name)
+	}
+	return c.FailurePolicy == CollectorFailurePolicySkip
+}
+
+// validate checks one block; errors name it
+func (c CollectorConfig) validate(name string) error {
+	if c.parseErr != nil {
+		return fmt.Errorf("collectors.%s: %v", name, c.parseErr)
+	}
+	if !IsCoreCollector(name) && !isOptionalCollector(name) {
+		known := append(append([]string{}, coreCollectors...), optionalCollectors...)
+		sort.Strings(known)
+		return fmt.Errorf("collectors.%s: unknown collector, known collectors are: %s", name, strings.Join(known, ", "))
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("collectors.%s.timeout must not be negative, got: %s", name, c.Timeout)
+	}
+	if c.FailurePolicy != "" && c.FailurePolicy != CollectorFailurePolicyFail && c.FailurePolicy != CollectorFailurePolicySkip {
+		return fmt.Errorf("collectors.%s.failurePolicy must be %q or %q, got: %q", name, CollectorFailurePolicyFail, CollectorFailurePolicySkip, c.FailurePolicy)
+	}
+	if IsCoreCollector(name) {
+		if !c.Enabled {
+			return fmt.Errorf("collectors.%s.enabled: the %s collector is a core collector and cannot be disabled", name, name)
+		}
+		if c.FailurePolicy == CollectorFailurePolicySkip {
+			return fmt.Errorf("collectors.%s.failurePolicy: the %s collector is a core collector and cannot be skipped", name, name)
+		}
+	}
+	return nil
+}
+
+// isOptionalCollector reports whether the name is a collector that may be disabled
+func isOptionalCollector(name string) bool {
+	for _, optional := range optionalCollectors {
+		if name == optional {
+			return true
+		}
+	}
+	return false
+}
+
+// CollectorsConfig holds the collector blocks keyed by collector name
+type CollectorsConfig map[string]CollectorConfig
+
+// Block returns the block of the named collector, or an enabled block with defaults when none is configured
+func (c CollectorsConfig) Block(name string) CollectorConfig {
+	if block, ok := c[name]; ok {
+		return block
+	}
+	return CollectorConfig{Enabled: true}
+}
+
+// RejectedCollector is a collectors block left out of the configuration because it failed validation
+type RejectedCollector struct {
+	Name string
+	Err  error
+}
+
+// rejectInvalidCollectors removes the collectors blocks that fail validation, so a bad block of an optional
+// collector rejects that block only instead of the whole configuration
+func (c *Config) rejectInvalidCollectors() {
+	c.rejectedCollectors = nil
+	names := make([]string, 0, len(c.Collectors))
+	for name := range c.Collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := c.Collectors[name].validate(name); err != nil {
+			c.rejectedCollectors = append(c.rejectedCollectors, RejectedCollector{Name: name, Err: err})
+			delete(c.Collectors, name)
+		}
+	}
+}
+
+// RejectedCollectors returns the collectors blocks removed while loading because they failed validation
+func (c *Config) RejectedCollectors() []RejectedCollector {
+	return c.rejectedCollectors
+}
@@ -1,12 +1,17 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 // Config represents the configuration for the AKS health monitor
@@ -14,226 +19,2249 @@ type Config struct {
 	// Polling interval for checking metrics
 	PollInterval time.Duration `yaml:"pollInterval"`
 
+	// DryRun evaluates every cycle as usual but logs and records an Event for each abort instead of
+	// sending it to Azure
+	DryRun bool `yaml:"dryRun"`
+
 	// Azure configuration
 	Azure AzureConfig `yaml:"azure"`
 
-	// Thresholds configuration
-	Thresholds ThresholdsConfig `yaml:"thresholds"`
+	// Retry policy and fallback when the Azure client cannot be created at startup
+	AzureClientStartup AzureClientStartupConfig `yaml:"azureClientStartup"`
+
+	// Thresholds configuration
+	Thresholds ThresholdsConfig `yaml:"thresholds"`
+
+	// Operations to monitor, with optional per-operation overrides
+	MonitoredOperations []MonitoredOperation `yaml:"monitoredOperations"`
+
+	// ConsecutiveViolations is how many consecutive cycles a metric must violate its threshold before the
+	// operation is aborted; critical thresholds abort at once regardless
+	ConsecutiveViolations int `yaml:"consecutiveViolations"`
+
+	// AbortCooldown is how long after an abort further aborts of the same operation are suppressed. The
+	// cooldown ends early when the cluster reaches Succeeded, Canceled or Failed or another operation starts.
+	AbortCooldown time.Duration `yaml:"abortCooldown"`
+
+	// OperationGracePeriod is how long after an operation is first observed its metrics are collected and
+	// logged but thresholds are not evaluated; 0 evaluates from the first cycle. The period restarts when
+	// the operation type changes.
+	OperationGracePeriod time.Duration `yaml:"operationGracePeriod"`
+
+	// HTTP listener configuration
+	Server ServerConfig `yaml:"server"`
+
+	// Policy for Scaling operations
+	ScaleOperations ScaleOperationsConfig `yaml:"scaleOperations"`
+
+	// Identities whose operations are alerted on rather than aborted
+	TrustedInitiators TrustedInitiatorsConfig `yaml:"trustedInitiators"`
+
+	// Optional invariants checked before aborting
+	AbortSafety AbortSafetyConfig `yaml:"abortSafety"`
+
+	// Metric collection settings
+	Collection CollectionConfig `yaml:"collection"`
+
+	// Planned maintenance pre-warming
+	Maintenance MaintenanceConfig `yaml:"maintenance"`
+
+	// Detection of cycles running slower than the configured poll interval
+	Cadence CadenceConfig `yaml:"cadence"`
+
+	// Periodic notifications proving the monitor is evaluating while operations run
+	Heartbeat HeartbeatConfig `yaml:"heartbeat"`
+
+	// Periodic re-check that the abort identity still holds the abort permission
+	PermissionCheck PermissionCheckConfig `yaml:"permissionCheck"`
+
+	// When /readyz reports the controller unready
+	Readiness ReadinessConfig `yaml:"readiness"`
+
+	// Lease settings used with --enable-leader-election
+	LeaderElection LeaderElectionConfig `yaml:"leaderElection"`
+
+	// Per-collector settings keyed by collector name; collectors without a block run with defaults
+	Collectors CollectorsConfig `yaml:"collectors"`
+
+	// rejectedCollectors are the collectors blocks dropped while loading because they failed validation
+	rejectedCollectors []RejectedCollector
+
+	// Optional sink for abort and suppression decisions in the OPA decision log format
+	DecisionLog DecisionLogConfig `yaml:"decisionLog"`
+
+	// Webhooks notified of threshold violations and aborts
+	Notifications NotificationsConfig `yaml:"notifications"`
+
+	// Optional diagnostic bundle captured after an abort
+	Diagnostics DiagnosticsConfig `yaml:"diagnostics"`
+
+	// Durable record of the aborts sent, kept in a ConfigMap
+	AuditTrail AuditTrailConfig `yaml:"auditTrail"`
+
+	// Optional Service Bus or Storage queue that receives abort and sustained degradation verdicts
+	Verdicts VerdictsConfig `yaml:"verdicts"`
+
+	// Optional durable backend for health check results
+	HistoryStorage HistoryStorageConfig `yaml:"historyStorage"`
+
+	// Panics tolerated before /healthz fails so the pod is restarted
+	PanicRecovery PanicRecoveryConfig `yaml:"panicRecovery"`
+
+	// Detection of operations ARM reports in progress after they stopped making progress
+	StuckOperation StuckOperationConfig `yaml:"stuckOperation"`
+
+	// Whether ARM provisioning errors count as a violation
+	OperationErrors OperationErrorsConfig `yaml:"operationErrors"`
+
+	// Advisory threshold suggestions learned from metrics while no operation is in progress
+	Suggestions SuggestionsConfig `yaml:"suggestions"`
+
+	// Operation phases during which individual metric thresholds apply, keyed by metric type
+	MetricWindows map[string]MetricWindow `yaml:"metricWindows"`
+
+	// Thresholds that relax or tighten as an upgrade progresses, keyed by metric type
+	ProgressScaling map[string]ProgressScaling `yaml:"progressScaling"`
+
+	// Increases over the value captured when the operation was first observed that a metric may reach
+	// before it violates, keyed by metric type; listed metrics are judged against the baseline instead of
+	// their absolute threshold
+	BaselineDeltas map[string]int `yaml:"baselineDeltas"`
+
+	// Thresholds whose violation aborts the operation as soon as the metric is collected, without
+	// waiting for the rest of the cycle; only non-zero values take effect
+	CriticalThresholds ThresholdsConfig `yaml:"criticalThresholds"`
+
+	// Descriptions and runbook links attached to violations, keyed by metric type
+	ThresholdGuidance map[string]ThresholdGuidance `yaml:"thresholdGuidance"`
+
+	// Readiness check run before an operation is started
+	Precheck PrecheckConfig `yaml:"precheck"`
+}
+
+// PrecheckConfig tunes the pre-check that reports whether the cluster is healthy enough to start an
+// operation, served at /precheck and by "kubectl aks-monitor precheck"
+type PrecheckConfig struct {
+	// Thresholds override the regular thresholds for the pre-check; zero values inherit them and -1 disables a metric
+	Thresholds ThresholdsConfig `yaml:"thresholds"`
+}
+
+// MetricWindow restricts evaluation of a metric's threshold to part of an operation. Outside the
+// window the metric is still collected but its threshold is not evaluated.
+type MetricWindow struct {
+	// AppliesAfter is how long after the operation started the threshold starts applying
+	AppliesAfter time.Duration `yaml:"appliesAfter"`
+
+	// AppliesBefore ends the window, either as a time since the operation started ("45m") or as
+	// operation progress ("90%Complete"), approximated by the fraction of nodes already on the target version
+	AppliesBefore string `yaml:"appliesBefore"`
+}
+
+// ProgressScaling interpolates a metric's threshold during upgrades between Start, when no node runs the
+// target version yet, and End, once every node does. Progress is approximated like percent-complete
+// metric windows, by the fraction of nodes already on the target version.
+type ProgressScaling struct {
+	// Start is the threshold at 0% complete, and while progress cannot be determined
+	Start int `yaml:"start"`
+
+	// End is the threshold at 100% complete
+	End int `yaml:"end"`
+}
+
+// At returns the threshold at the given percent complete, clamped to 0-100; a negative percent, meaning
+// progress is unknown, returns Start
+func (s ProgressScaling) At(percentComplete int) int {
+	switch {
+	case percentComplete <= 0:
+		return s.Start
+	case percentComplete >= 100:
+		return s.End
+	}
+	return s.Start + (s.End-s.Start)*percentComplete/100
+}
+
+// percentCompleteSuffix marks an AppliesBefore value expressed as operation progress
+const percentCompleteSuffix = "%Complete"
+
+// Before parses AppliesBefore into either a duration or a percent-complete bound. Both are zero
+// when the window has no end.
+func (w MetricWindow) Before() (duration time.Duration, percentComplete int, err error) {
+	if w.AppliesBefore == "" {
+		return 0, 0, nil
+	}
+
+	if strings.HasSuffix(w.AppliesBefore, percentCompleteSuffix) {
+		percentComplete, err = strconv.Atoi(strings.TrimSuffix(w.AppliesBefore, percentCompleteSuffix))
+		if err != nil || percentComplete < 1 || percentComplete > 100 {
+			return 0, 0, fmt.Errorf("appliesBefore %q must be a duration or 1-100%s", w.AppliesBefore, percentCompleteSuffix)
+		}
+		return 0, percentComplete, nil
+	}
+
+	duration, err = time.ParseDuration(w.AppliesBefore)
+	if err != nil || duration <= 0 {
+		return 0, 0, fmt.Errorf("appliesBefore %q must be a positive duration or 1-100%s", w.AppliesBefore, percentCompleteSuffix)
+	}
+	return duration, 0, nil
+}
+
+// History storage backends
+const (
+	HistoryBackendSQLite     = "sqlite"
+	HistoryBackendAzureTable = "azureTable"
+)
+
+// HistoryStorageConfig selects a durable backend for health check results. Results are only kept in
+// memory when Backend is empty.
+type HistoryStorageConfig struct {
+	// Backend is "sqlite" or "azureTable"
+	Backend string `yaml:"backend"`
+
+	// SQLitePath is the database file of the sqlite backend, on a persistent volume
+	SQLitePath string `yaml:"sqlitePath"`
+
+	// TableURL is the table of the azureTable backend, e.g. https://myaccount.table.core.windows.net/aksmonitor
+	TableURL string `yaml:"tableURL"`
+
+	// Retention is how long results are kept before they are pruned
+	Retention time.Duration `yaml:"retention"`
+
+	// PruneInterval is how often results older than Retention are deleted
+	PruneInterval time.Duration `yaml:"pruneInterval"`
+}
+
+// PanicRecoveryConfig bounds how many recovered panics the controller tolerates. Each panic fails its cycle;
+// MaxPanics within Window fail /healthz.
+type PanicRecoveryConfig struct {
+	MaxPanics int           `yaml:"maxPanics"`
+	Window    time.Duration `yaml:"window"`
+}
+
+// StuckOperationConfig detects operations that ARM still reports in progress although none of their
+// progress signals (control plane version, agent pool versions and counts, nodes) changed for StalenessPeriod
+type StuckOperationConfig struct {
+	StalenessPeriod time.Duration `yaml:"stalenessPeriod"`
+
+	// TreatAsViolation reports a suspected stuck operation as a suspected_stuck_operation violation,
+	// subject to the same abort and alert policy as threshold violations
+	TreatAsViolation bool `yaml:"treatAsViolation"`
+}
+
+// OperationErrorsConfig controls how ARM provisioning errors, such as quota or capacity errors, are acted on.
+// They are reported as operation_error_detected every cycle either way.
+type OperationErrorsConfig struct {
+	// TreatAsViolation reports provisioning errors of a monitored operation as an operation_error_detected
+	// violation, subject to the same abort and alert policy as threshold violations
+	TreatAsViolation bool `yaml:"treatAsViolation"`
+}
+
+// SuggestionsConfig controls the advisory threshold suggestion mode. Suggestions are only reported, never applied.
+type SuggestionsConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// LearningWindow is how long metrics are observed before suggestions are reported
+	LearningWindow time.Duration `yaml:"learningWindow"`
+
+	// SampleInterval is how often metrics are collected while no operation is in progress
+	SampleInterval time.Duration `yaml:"sampleInterval"`
+
+	// MarginPercent is added on top of the observed p99 of each metric
+	MarginPercent int `yaml:"marginPercent"`
+
+	// MinSamples is how many samples a metric needs before a threshold is suggested for it
+	MinSamples int `yaml:"minSamples"`
+
+	// LogInterval is how often the current suggestions are logged
+	LogInterval time.Duration `yaml:"logInterval"`
+}
+
+// MaintenanceConfig controls pre-warming ahead of AKS planned maintenance windows
+type MaintenanceConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// LeadTime is how long before a window starts the controller switches to the faster cadence
+	LeadTime time.Duration `yaml:"leadTime"`
+
+	// PollInterval is the cadence used from LeadTime before a window until it ends
+	PollInterval time.Duration `yaml:"pollInterval"`
+
+	// RefreshInterval is how often maintenance configurations are re-listed
+	RefreshInterval time.Duration `yaml:"refreshInterval"`
+}
+
+// Validate checks the namespace selection mode and that no namespace is both included and excluded
+func (n NamespaceSelection) Validate() error {
+	switch n.Mode {
+	case "", NamespaceModeAll, NamespaceModeOptIn, NamespaceModeOptOut:
+	default:
+		return fmt.Errorf("collection.namespaces.mode must be %q, %q or %q, got: %q", NamespaceModeAll, NamespaceModeOptIn, NamespaceModeOptOut, n.Mode)
+	}
+
+	excluded := make(map[string]bool, len(n.Exclude))
+	for _, namespace := range n.Exclude {
+		excluded[namespace] = true
+	}
+	for _, namespace := range n.Include {
+		if excluded[namespace] {
+			return fmt.Errorf("collection.namespaces: namespace %q is both included and excluded", namespace)
+		}
+	}
+
+	if n.LabelCacheTTL < 0 {
+		return fmt.Errorf("collection.namespaces.labelCacheTTL must not be negative, got: %s", n.LabelCacheTTL)
+	}
+	return nil
+}
+
+// CadenceConfig controls detection of drift between the configured and observed cycle cadence
+type CadenceConfig struct {
+	// DriftFactor is how many times longer than the poll interval the average interval between cycle
+	// starts may be before the controller warns
+	DriftFactor float64 `yaml:"driftFactor"`
+
+	// Window is the number of recent intervals averaged
+	Window int `yaml:"window"`
+}
+
+// DecisionLogConfig configures delivery of decisions to an OPA-compatible decision log collector.
+// Delivery is disabled when Endpoint is empty.
+type DecisionLogConfig struct {
+	// Endpoint is the collector URL decision batches are POSTed to
+	Endpoint string `yaml:"endpoint"`
+
+	// BatchSize is the maximum number of decisions per request
+	BatchSize int `yaml:"batchSize"`
+
+	// FlushInterval is how often a partial batch is sent
+	FlushInterval time.Duration `yaml:"flushInterval"`
+
+	// MaxRetries is how many times a failed batch is retried before it is dropped
+	MaxRetries int `yaml:"maxRetries"`
+}
+
+// DefaultWebhookTimeout bounds a webhook request when its timeout is not set
+const DefaultWebhookTimeout = 10 * time.Second
+
+// NotificationsConfig configures webhooks POSTed a JSON payload when thresholds are violated and when an
+// operation is aborted
+type NotificationsConfig struct {
+	// Webhooks are the sinks notified; notifications are disabled when empty
+	Webhooks []WebhookConfig `yaml:"webhooks"`
+
+	// MaxRetries is how many times a failed delivery is retried before it is dropped
+	MaxRetries int `yaml:"maxRetries"`
+
+	// Slack posts formatted messages to a Slack channel
+	Slack SlackConfig `yaml:"slack"`
+}
+
+// Enabled reports whether any sink is configured
+func (c NotificationsConfig) Enabled() bool {
+	return len(c.Webhooks) > 0 || c.Slack.Enabled()
+}
+
+// SlackConfig posts violations and aborts to Slack, either through an incoming webhook or through
+// chat.postMessage with a bot token. Both are secrets, so they are read from files.
+type SlackConfig struct {
+	// WebhookURLFile holds the URL of an incoming webhook
+	WebhookURLFile string `yaml:"webhookURLFile"`
+
+	// BotTokenFile holds a bot token used with Channel instead of an incoming webhook
+	BotTokenFile string `yaml:"botTokenFile"`
+
+	// Channel is the channel ID or name bot token messages are posted to
+	Channel string `yaml:"channel"`
+
+	// Throttle is how long the same violated metrics of one operation are not posted again; aborts are
+	// always posted
+	Throttle time.Duration `yaml:"throttle"`
+
+	// Timeout bounds one request; 0 uses DefaultWebhookTimeout
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Enabled reports whether a Slack webhook or bot token is configured
+func (c SlackConfig) Enabled() bool {
+	return c.WebhookURLFile != "" || c.BotTokenFile != ""
+}
+
+// WebhookConfig is one webhook sink
+type WebhookConfig struct {
+	// URL receives the POST
+	URL string `yaml:"url"`
+
+	// BearerTokenFile holds the token sent as "Authorization: Bearer <token>"; empty sends none
+	BearerTokenFile string `yaml:"bearerTokenFile"`
+
+	// Timeout bounds one request; 0 uses DefaultWebhookTimeout
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// DiagnosticsConfig configures the diagnostic bundle captured after an abort: the offending pods, their
+// Warning events, their nodes' conditions and the agent pool state, packaged as one .tar.gz. Set one of
+// Path and BlobContainerURL to enable it.
+type DiagnosticsConfig struct {
+	// Path is a directory bundles are written to, e.g. an emptyDir mount
+	Path string `yaml:"path"`
+
+	// BlobContainerURL is a Blob container bundles are uploaded to with the Azure credential, e.g.
+	// https://myaccount.blob.core.windows.net/aks-monitor. The identity needs Storage Blob Data Contributor on it.
+	BlobContainerURL string `yaml:"blobContainerURL"`
+
+	// Timeout bounds capturing and storing one bundle
+	Timeout time.Duration `yaml:"timeout"`
+
+	// MaxBytes caps the uncompressed content of a bundle; objects past it are left out
+	MaxBytes int `yaml:"maxBytes"`
+
+	// MaxPods caps the offending pods whose status and events are captured
+	MaxPods int `yaml:"maxPods"`
+}
+
+// Enabled reports whether a bundle is captured after an abort
+func (d DiagnosticsConfig) Enabled() bool {
+	return d.Path != "" || d.BlobContainerURL != ""
+}
+
+// MaxAuditTrailEntries bounds auditTrail.maxEntries, keeping the audit ConfigMap well below the 1 MiB
+// object size limit
+const MaxAuditTrailEntries = 100
+
+// AuditTrailConfig configures the audit trail of aborts written to a ConfigMap in the controller namespace,
+// which survives restarts of the controller
+type AuditTrailConfig struct {
+	// MaxEntries is how many of the most recent aborts are kept
+	MaxEntries int `yaml:"maxEntries"`
+}
+
+// Heartbeat channels
+const (
+	// HeartbeatChannelEvent updates a Normal Event on the controller's pod
+	HeartbeatChannelEvent = "event"
+	// HeartbeatChannelVerdicts publishes a heartbeat verdict to the configured verdict queue
+	HeartbeatChannelVerdicts = "verdicts"
+	// HeartbeatChannelLog writes a log line
+	HeartbeatChannelLog = "log"
+)
+
+// HeartbeatConfig controls the heartbeat the run loop sends while an operation is in progress, so external
+// alerting can tell a monitor that stopped evaluating from one that has nothing to report. The
+// heartbeat_timestamp gauge is updated every cycle regardless.
+type HeartbeatConfig struct {
+	// Interval is the minimum time between two heartbeats
+	Interval time.Duration `yaml:"interval"`
+
+	// Channels are where heartbeats are sent: event, verdicts or log
+	Channels []string `yaml:"channels"`
+
+	// OutsideOperations also sends heartbeats while no operation is in progress
+	OutsideOperations bool `yaml:"outsideOperations"`
+}
+
+// MinPermissionCheckInterval bounds how often the abort permission is re-checked
+const MinPermissionCheckInterval = 5 * time.Minute
+
+// PermissionCheckConfig controls the periodic check that the identity aborts are sent with still holds
+// the abort permission on the cluster, so a removed role assignment is found before an abort fails
+type PermissionCheckConfig struct {
+	// Interval is the time between two checks
+	Interval time.Duration `yaml:"interval"`
+
+	// Skip disables the check, for identities not allowed to list their own permissions
+	Skip bool `yaml:"skip"`
+}
+
+// ReadinessConfig controls when the controller reports itself unready, so a controller that keeps
+// failing its health checks is visible to Kubernetes instead of only in the logs
+type ReadinessConfig struct {
+	// MaxConsecutiveFailures is how many health checks in a row may fail before /readyz returns 503
+	MaxConsecutiveFailures int `yaml:"maxConsecutiveFailures"`
+}
+
+// LeaderElectionConfig tunes the Lease replicas compete for when leader election is enabled. A leader that
+// cannot renew within RenewDeadline stops its poll loop; a standby takes over once LeaseDuration has passed
+// since the last renewal.
+type LeaderElectionConfig struct {
+	// LeaseName is the Lease in the controller's namespace
+	LeaseName string `yaml:"leaseName"`
+
+	// LeaseDuration is how long a standby waits after the last renewal before taking over
+	LeaseDuration time.Duration `yaml:"leaseDuration"`
+
+	// RenewDeadline is how long the leader keeps retrying a renewal before stopping
+	RenewDeadline time.Duration `yaml:"renewDeadline"`
+
+	// RetryPeriod is the time between attempts to acquire or renew the Lease
+	RetryPeriod time.Duration `yaml:"retryPeriod"`
+}
+
+// VerdictsConfig configures publishing health verdicts for downstream automation. At most one of
+// ServiceBusURL and StorageQueueURL may be set; publishing is disabled when neither is.
+type VerdictsConfig struct {
+	// ServiceBusURL is the queue or topic URL, e.g. https://mybus.servicebus.windows.net/verdicts
+	ServiceBusURL string `yaml:"serviceBusURL"`
+
+	// StorageQueueURL is the queue URL, e.g. https://myaccount.queue.core.windows.net/verdicts
+	StorageQueueURL string `yaml:"storageQueueURL"`
+
+	// SustainedCycles is how many consecutive degraded or abort-worthy cycles publish a degradation verdict
+	SustainedCycles int `yaml:"sustainedCycles"`
+
+	// MaxRetries is how many times a failed send is retried before the verdict is dead-lettered to the log
+	MaxRetries int `yaml:"maxRetries"`
+}
+
+// Enabled reports whether a verdict destination is configured
+func (v VerdictsConfig) Enabled() bool {
+	return v.ServiceBusURL != "" || v.StorageQueueURL != ""
+}
+
+// Collection modes
+const (
+	// CollectionModeFull lists every pod each cycle
+	CollectionModeFull = "full"
+	// CollectionModeChunked scans a round-robin fraction of namespaces each cycle and aggregates across the rotation
+	CollectionModeChunked = "chunked"
+)
+
+// Collection sources
+const (
+	// CollectionSourceList lists pods, nodes and jobs from the API server every cycle
+	CollectionSourceList = "list"
+	// CollectionSourceInformer reads pods, nodes and jobs from shared informer caches kept current by watches
+	CollectionSourceInformer = "informer"
+)
+
+// CollectionConfig controls how the metrics collector reads cluster state
+type CollectionConfig struct {
+	Mode string `yaml:"mode"`
+
+	// Source selects whether pods, nodes and jobs are listed every cycle or read from informer caches
+	Source string `yaml:"source"`
+
+	// CacheSyncTimeout is how long a cycle waits for the informer caches to sync before listing directly instead
+	CacheSyncTimeout time.Duration `yaml:"cacheSyncTimeout"`
+
+	// RotationLength is the number of cycles needed to scan every namespace in chunked mode
+	RotationLength int `yaml:"rotationLength"`
+
+	// AdmissionEventWindow is how far back FailedCreate events are considered for admission failure detection
+	AdmissionEventWindow time.Duration `yaml:"admissionEventWindow"`
+
+	// SchedulingLatencyMinSamples is the number of pods that must have been scheduled since the operation
+	// started before the scheduling latency percentile is reported
+	SchedulingLatencyMinSamples int `yaml:"schedulingLatencyMinSamples"`
+
+	// ZoneRedundancy enables checking that zone-spread Deployments and StatefulSets keep ready replicas in every zone
+	ZoneRedundancy bool `yaml:"zoneRedundancy"`
+
+	// NewPodMetrics adds new_pods_crashing_percent and new_pods_pending_percent, computed only over pods created
+	// since the operation started
+	NewPodMetrics bool `yaml:"newPodMetrics"`
+
+	// MaxNodeUsage adds max_node_cpu_usage_percent and max_node_memory_usage_percent, the usage of the busiest
+	// node, next to the cluster-wide usage
+	MaxNodeUsage bool `yaml:"maxNodeUsage"`
+
+	// NewPodsMinSamples is the number of pods that must have been created since the operation started before
+	// the new pod metrics are reported
+	NewPodsMinSamples int `yaml:"newPodsMinSamples"`
+
+	// Budget limits the time and Kubernetes API calls spent collecting each cycle
+	Budget CollectionBudget `yaml:"budget"`
+
+	// DiscoveryInterval is how often API discovery is re-run to disable or re-enable collectors
+	// whose resources the API server does not serve
+	DiscoveryInterval time.Duration `yaml:"discoveryInterval"`
+
+	// Namespaces selects the namespaces whose pods, jobs and workloads are health-checked
+	Namespaces NamespaceSelection `yaml:"namespaces"`
+
+	// ReimageLoop tunes detection of agent pools whose nodes are repeatedly deleted and recreated
+	ReimageLoop ReimageLoopConfig `yaml:"reimageLoop"`
+
+	// Flapping tunes detection of pods whose Ready condition keeps changing; it needs the informer source
+	Flapping FlappingConfig `yaml:"flapping"`
+
+	// DaemonSets selects the DaemonSets whose readiness daemonset_not_ready_percent reports
+	DaemonSets DaemonSetSelection `yaml:"daemonSets"`
+
+	// IgnorableTerminatedReasons lists pod status reasons of the Failed phase that are not crashes, such as
+	// pods kubelet terminated during graceful node shutdown
+	IgnorableTerminatedReasons []string `yaml:"ignorableTerminatedReasons"`
+
+	// ReportShutdownTerminatedPods adds the informational shutdown_terminated_pods metric counting those pods
+	ReportShutdownTerminatedPods bool `yaml:"reportShutdownTerminatedPods"`
+
+	// ExcludeCordonedNodes leaves nodes that are cordoned, or carry one of CordonTaints, out of the node
+	// readiness and pressure metrics, since an upgrade drains them on purpose. They are counted in the
+	// informational excluded_cordoned_nodes metric instead.
+	ExcludeCordonedNodes bool `yaml:"excludeCordonedNodes"`
+
+	// CordonTaints lists taint keys that mark a node as taken out for maintenance, like spec.unschedulable
+	CordonTaints []string `yaml:"cordonTaints"`
+
+	// ReportRestartCount adds restart_count, the container restarts since each pod was created, next to
+	// restart_rate. It only grows on long-lived clusters and is kept for configurations relying on it.
+	ReportRestartCount bool `yaml:"reportRestartCount"`
+
+	// ChurnExcludeOwnerKinds lists controlling owner kinds, such as "Job", whose pods do not count toward pod churn
+	ChurnExcludeOwnerKinds []string `yaml:"churnExcludeOwnerKinds"`
+
+	// CallTimeout bounds each Kubernetes API request, so one slow list page cannot hold up the whole cycle
+	CallTimeout time.Duration `yaml:"callTimeout"`
+
+	// PodPageSize is the number of pods requested per list page; pages are tallied as they arrive, so
+	// memory use follows the page size rather than the number of pods
+	PodPageSize int `yaml:"podPageSize"`
+
+	// NodePageSize is the number of nodes requested per list page
+	NodePageSize int `yaml:"nodePageSize"`
+
+	// JobPageSize is the number of Jobs requested per list page, of full Jobs or of Job metadata
+	JobPageSize int `yaml:"jobPageSize"`
+
+	// ExcludeSucceededPods has the API server leave pods that ran to completion out of pod listings. They
+	// then no longer count toward pod totals, restarts or churn.
+	ExcludeSucceededPods bool `yaml:"excludeSucceededPods"`
+
+	// CrashRecencyWindow, when positive, only counts a container that exited with an error, or is in
+	// CrashLoopBackOff after one, as crashing if it exited within the window, so a pod whose last crash is
+	// long past stops inflating crashing pods
+	CrashRecencyWindow time.Duration `yaml:"crashRecencyWindow"`
+
+	// OOMKillWindow is how recently a container must have been OOM-killed for its pod to count toward
+	// oom_killed_pods
+	OOMKillWindow time.Duration `yaml:"oomKillWindow"`
+
+	// FailedJobWindow is how recently a Job must have finished to count toward failed_jobs; running Jobs
+	// with failed pods always count
+	FailedJobWindow time.Duration `yaml:"failedJobWindow"`
+}
+
+// ReimageLoopConfig controls reimage loop detection. A pool is suspected when more than MaxCreations node
+// objects are created within Window while its Ready node count does not increase.
+type ReimageLoopConfig struct {
+	Window       time.Duration `yaml:"window"`
+	MaxCreations int           `yaml:"maxCreations"`
+}
+
+// FlappingConfig controls readiness flapping detection. A pod is flapping when its Ready condition changed
+// at least MinTransitions times within Window.
+type FlappingConfig struct {
+	Window         time.Duration `yaml:"window"`
+	MinTransitions int           `yaml:"minTransitions"`
+}
+
+// DaemonSetSelection restricts daemonset_not_ready_percent to system or critical DaemonSets. Left empty,
+// every DaemonSet in the monitored namespaces counts.
+type DaemonSetSelection struct {
+	// Namespace, e.g. kube-system, counts only the DaemonSets in that namespace, whether or not
+	// collection.namespaces monitors it
+	Namespace string `yaml:"namespace"`
+
+	// Selector is a label selector, e.g. "criticality=high", the DaemonSets must match
+	Selector string `yaml:"selector"`
+}
+
+// Namespace selection modes
+const (
+	// NamespaceModeAll checks every namespace not excluded by the config lists; labels are ignored
+	NamespaceModeAll = "all"
+	// NamespaceModeOptIn checks only namespaces labelled aks-monitor.azure.com/monitor: "true"
+	NamespaceModeOptIn = "optIn"
+	// NamespaceModeOptOut checks every namespace except those labelled aks-monitor.azure.com/monitor: "false"
+	NamespaceModeOptOut = "optOut"
+)
+
+// NamespaceSelection chooses which namespaces are health-checked. The config lists take precedence
+// over namespace labels: Exclude always wins, then Include, then the label according to Mode.
+type NamespaceSelection struct {
+	Mode    string   `yaml:"mode"`
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+
+	// LabelCacheTTL is how long namespace labels are cached between cycles in the label-driven modes
+	LabelCacheTTL time.Duration `yaml:"labelCacheTTL"`
+}
+
+// CollectionBudget limits one collection. Critical collectors (nodes) always run; normal and optional
+// collectors are skipped once the budget is exhausted. Zero values are unlimited.
+type CollectionBudget struct {
+	Timeout     time.Duration `yaml:"timeout"`
+	MaxAPICalls int           `yaml:"maxAPICalls"`
+}
+
+// AbortSafetyConfig configures invariants that must hold for an abort to leave the cluster in an acceptable state.
+// If any invariant fails the abort is downgraded to an alert.
+type AbortSafetyConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// MinSchedulableCapacityPercent is the minimum percentage of nodes that must be Ready and schedulable
+	MinSchedulableCapacityPercent int `yaml:"minSchedulableCapacityPercent"`
+
+	// MaxKubeletMinorSkew is the maximum number of minor versions kubelets may lag the control plane
+	MaxKubeletMinorSkew int `yaml:"maxKubeletMinorSkew"`
+}
+
+// ScaleOperationsConfig controls how Scaling operations are handled
+type ScaleOperationsConfig struct {
+	// AbortAutoscalerInitiated allows aborting scale operations attributed to the cluster autoscaler.
+	// When false (default) such violations are only alerted on, since the autoscaler would retry the scale.
+	AbortAutoscalerInitiated bool `yaml:"abortAutoscalerInitiated"`
+}
+
+// TrustedInitiatorsConfig lists the identities, such as the monitor's own or a deployment pipeline's, whose
+// operations are only alerted on. The initiator of an operation is read from the cluster's activity log.
+type TrustedInitiatorsConfig struct {
+	// Principals are the object IDs, application (client) IDs or user principal names of trusted callers
+	Principals []string `yaml:"principals"`
+
+	// Lookback is how long before the operation was first observed the activity log is searched for the
+	// request that started it
+	Lookback time.Duration `yaml:"lookback"`
+}
+
+// ServerConfig contains the bind addresses of the HTTP listeners exposed by the controller.
+// Addresses use host:port form (e.g. "0.0.0.0:8080", "[::]:8080", ":8080"); an empty address disables the listener.
+type ServerConfig struct {
+	MetricsAddress string `yaml:"metricsAddress"`
+	HealthAddress  string `yaml:"healthAddress"`
+	AdminAddress   string `yaml:"adminAddress"`
+
+	// Exemplars attaches cycle trace IDs to violation and abort counters and enables OpenMetrics
+	// negotiation on /metrics, which exemplars require
+	Exemplars bool `yaml:"exemplars"`
+
+	// UI serves the read-only status page at /ui/ on the health listener
+	UI bool `yaml:"ui"`
+
+	// Pprof serves the runtime profiles at /debug/pprof/ on the admin listener
+	Pprof bool `yaml:"pprof"`
+
+	// TLS and authentication of each listener
+	Metrics ListenerSecurityConfig `yaml:"metrics"`
+	Health  ListenerSecurityConfig `yaml:"health"`
+	Admin   ListenerSecurityConfig `yaml:"admin"`
+}
+
+// ListenerSecurityConfig contains the TLS and authentication settings of one listener
+type ListenerSecurityConfig struct {
+	// TLSCertFile and TLSKeyFile serve HTTPS with a PEM certificate and key; both or neither must be set
+	TLSCertFile string `yaml:"tlsCertFile"`
+	TLSKeyFile  string `yaml:"tlsKeyFile"`
+
+	// BearerTokenFile requires requests to carry the file's contents as a bearer token. On the health
+	// listener, /healthz and /readyz stay open to probes.
+	BearerTokenFile string `yaml:"bearerTokenFile"`
+}
+
+// validate checks that the certificate and key are set together
+func (s ListenerSecurityConfig) validate(field string) error {
+	if (s.TLSCertFile == "") != (s.TLSKeyFile == "") {
+		return fmt.Errorf("%s.tlsCertFile and %s.tlsKeyFile must be set together", field, field)
+	}
+	return nil
+}
+
+// AzureConfig contains Azure-specific configuration
+type AzureConfig struct {
+	SubscriptionID    string `yaml:"subscriptionId"`
+	ResourceGroupName string `yaml:"resourceGroupName"`
+	ClusterName       string `yaml:"clusterName"`
+	TenantID          string `yaml:"tenantId"`
+	ClientID          string `yaml:"clientId"`
+	ClientSecret      string `yaml:"clientSecret" redact:"true"`
+
+	// AuthMode selects the credential: "clientSecret" (default) authenticates as the service principal
+	// above; "managedIdentity" as the node's managed identity, user-assigned when ClientID is set;
+	// "workloadIdentity" as the federated identity of the pod's service account; "default" tries the
+	// azidentity DefaultAzureCredential chain. Only clientSecret needs TenantID, ClientID and ClientSecret.
+	AuthMode string `yaml:"authMode"`
+
+	// Cloud selects the Azure cloud authenticated against and managed through: "AzurePublic" (default),
+	// "AzureGovernment", "AzureChina", or "custom" for the endpoints in CustomCloud, such as Azure Stack Hub
+	Cloud string `yaml:"cloud"`
+
+	// CustomCloud gives the endpoints of azure.cloud "custom"
+	CustomCloud CustomCloudConfig `yaml:"customCloud"`
+
+	// StatusSource selects how provisioning state is read each cycle: "arm" (default) reads the cluster
+	// directly; "resourceGraph" reads idle clusters from Azure Resource Graph to save ARM read quota
+	// on throttled subscriptions. Aborts always go through ARM.
+	StatusSource string `yaml:"statusSource"`
+
+	// ResourceGraphMaxStaleness bounds how long Resource Graph answers are trusted before ARM is read again
+	ResourceGraphMaxStaleness time.Duration `yaml:"resourceGraphMaxStaleness"`
+
+	// WriteCircuit stops sending write requests such as aborts while ARM keeps failing them
+	WriteCircuit CircuitBreakerConfig `yaml:"writeCircuit"`
+
+	// ReadOnly declares that the identity above only has read permissions, so aborts need AbortCredential
+	ReadOnly bool `yaml:"readOnly"`
+
+	// AbortCredential is an optional higher-privileged identity used only to abort operations; every
+	// other request uses the identity above
+	AbortCredential AbortCredentialConfig `yaml:"abortCredential"`
+
+	// CallTimeout bounds each ARM and Resource Graph read, including every page of a paged list
+	CallTimeout time.Duration `yaml:"callTimeout"`
+
+	// AbortTimeout bounds an abort, from sending the request until its long-running operation completes
+	AbortTimeout time.Duration `yaml:"abortTimeout"`
+
+	// Retry controls how ARM requests answered with 408, 429 or a 5xx are retried
+	Retry ARMRetryConfig `yaml:"retry"`
+}
+
+// ARMRetryConfig retries ARM requests that were throttled or failed on the server. A 429 is retried after
+// its Retry-After; other failures after a delay that doubles with each attempt, up to MaxRetryDelay.
+// Retries of a read count toward azure.callTimeout and retries of an abort toward azure.abortTimeout.
+type ARMRetryConfig struct {
+	MaxRetries    int           `yaml:"maxRetries"`
+	RetryDelay    time.Duration `yaml:"retryDelay"`
+	MaxRetryDelay time.Duration `yaml:"maxRetryDelay"`
+}
+
+// CustomCloudConfig is the Microsoft Entra authority and ARM endpoint of a cloud the SDK does not know
+type CustomCloudConfig struct {
+	ActiveDirectoryAuthorityHost string `yaml:"activeDirectoryAuthorityHost"`
+	ResourceManagerEndpoint      string `yaml:"resourceManagerEndpoint"`
+
+	// ResourceManagerAudience is the audience of ARM tokens; it defaults to ResourceManagerEndpoint
+	ResourceManagerAudience string `yaml:"resourceManagerAudience"`
+}
+
+// validate checks that both endpoints of a custom cloud are https URLs
+func (c CustomCloudConfig) validate() error {
+	endpoints := []struct{ name, value string }{
+		{"activeDirectoryAuthorityHost", c.ActiveDirectoryAuthorityHost},
+		{"resourceManagerEndpoint", c.ResourceManagerEndpoint},
+	}
+	for _, endpoint := range endpoints {
+		if endpoint.value == "" {
+			return fmt.Errorf("azure.customCloud.%s is required with azure.cloud %q", endpoint.name, CloudCustom)
+		}
+		parsed, err := url.Parse(endpoint.value)
+		if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			return fmt.Errorf("azure.customCloud.%s must be an https URL, got: %q", endpoint.name, endpoint.value)
+		}
+	}
+	return nil
+}
+
+// AbortCredentialConfig identifies the identity that sends aborts. With a client secret it is a service
+// principal; without one it is a user-assigned managed identity attached to the pod's node or VMSS.
+type AbortCredentialConfig struct {
+	// TenantID defaults to azure.tenantId
+	TenantID     string `yaml:"tenantId"`
+	ClientID     string `yaml:"clientId"`
+	ClientSecret string `yaml:"clientSecret" redact:"true"`
+}
+
+// Configured reports whether a separate abort identity is set
+func (c AbortCredentialConfig) Configured() bool {
+	return c.ClientID != ""
+}
+
+// CircuitBreakerConfig opens a circuit after FailureThreshold consecutive requests failed with a 5xx, a
+// 429 or no response. While open, requests fail fast for OpenDuration. Then one probe request is let
+// through: if it succeeds the circuit closes, otherwise it opens again for twice as long, up to MaxOpenDuration.
+type CircuitBreakerConfig struct {
+	FailureThreshold int           `yaml:"failureThreshold"`
+	OpenDuration     time.Duration `yaml:"openDuration"`
+	MaxOpenDuration  time.Duration `yaml:"maxOpenDuration"`
+}
+
+// AzureClientStartupConfig controls how the controller reacts when the Azure client cannot be created,
+// for example while a workload identity token file has not been projected yet
+type AzureClientStartupConfig struct {
+	// Retries is how many times client creation is retried before FailureMode applies
+	Retries int `yaml:"retries"`
+
+	// Backoff is the wait before the first retry; it doubles after each attempt
+	Backoff time.Duration `yaml:"backoff"`
+
+	// FailureMode is "exit" (default) to exit the process, or "metricsOnly" to keep collecting cluster
+	// metrics without detecting or aborting operations until a client can be created
+	FailureMode string `yaml:"failureMode"`
+}
+
+// Failure modes for AzureClientStartupConfig.FailureMode
+const (
+	StartupFailureExit        = "exit"
+	StartupFailureMetricsOnly = "metricsOnly"
+)
+
+// Authentication modes for AzureConfig.AuthMode
+const (
+	AuthModeClientSecret     = "clientSecret"
+	AuthModeManagedIdentity  = "managedIdentity"
+	AuthModeWorkloadIdentity = "workloadIdentity"
+	AuthModeDefault          = "default"
+)
+
+// Clouds for AzureConfig.Cloud
+const (
+	CloudAzurePublic     = "AzurePublic"
+	CloudAzureGovernment = "AzureGovernment"
+	CloudAzureChina      = "AzureChina"
+	CloudCustom          = "custom"
+)
+
+// Status sources for AzureConfig.StatusSource
+const (
+	StatusSourceARM           = "arm"
+	StatusSourceResourceGraph = "resourceGraph"
+)
+
+// ThresholdDisabled as a threshold turns off the evaluation of its metric. The metric is still collected
+// and reported.
+const ThresholdDisabled = -1
+
+// ThresholdsConfig defines the thresholds for various metrics
+type ThresholdsConfig struct {
+	CrashingPodsPercent  int `yaml:"crashingPodsPercent"`  // Percentage of total pods
+	PendingPodsPercent   int `yaml:"pendingPodsPercent"`   // Percentage of total pods
+	NotReadyNodesPercent int `yaml:"notReadyNodesPercent"` // Percentage of total nodes
+	FailedJobs           int `yaml:"failedJobs"`           // Absolute number
+	RestartCount         int `yaml:"restartCount"`         // Absolute number, with collection.reportRestartCount
+	RestartRate          int `yaml:"restartRate"`          // Container restarts since the previous cycle
+	CpuUsagePercent      int `yaml:"cpuUsagePercent"`      // Percentage
+	MemoryUsagePercent   int `yaml:"memoryUsagePercent"`   // Percentage
+
+	// ImagePullErrorsPercent defaults to crashingPodsPercent when only that is configured, since pods failing
+	// to pull images counted as crashing before they were reported separately
+	ImagePullErrorsPercent int `yaml:"imagePullErrorsPercent"` // Percentage of total pods
+
+	WorkloadsBlockedByAdmission int `yaml:"workloadsBlockedByAdmission"` // Absolute number
+
+	CpuRequestsSaturationPercent    int `yaml:"cpuRequestsSaturationPercent"`    // Percentage of schedulable allocatable
+	MemoryRequestsSaturationPercent int `yaml:"memoryRequestsSaturationPercent"` // Percentage of schedulable allocatable
+
+	P95SchedulingLatencySeconds int `yaml:"p95SchedulingLatencySeconds"` // Seconds from creation to scheduling
+
+	WorkloadsLosingZoneRedundancy int `yaml:"workloadsLosingZoneRedundancy"` // Absolute number
+
+	PodChurnPerMinute int `yaml:"podChurnPerMinute"` // Pod creations plus deletions per minute
+
+	SuspectedNodeReimageLoops int `yaml:"suspectedNodeReimageLoops"` // Absolute number of agent pools
+
+	FlappingPods int `yaml:"flappingPods"` // Pods whose readiness flapped, with the informer source
+
+	NewPodsCrashingPercent int `yaml:"newPodsCrashingPercent"` // Percentage of pods created since the operation started
+	NewPodsPendingPercent  int `yaml:"newPodsPendingPercent"`  // Percentage of pods created since the operation started
+
+	MaxNodeCpuUsagePercent    int `yaml:"maxNodeCpuUsagePercent"`    // Percentage of the busiest node's allocatable
+	MaxNodeMemoryUsagePercent int `yaml:"maxNodeMemoryUsagePercent"` // Percentage of the busiest node's allocatable
+
+	ConfigReferenceFailures int `yaml:"configReferenceFailures"` // Pods missing a referenced ConfigMap or Secret
+
+	MemoryPressureNodesPercent     int `yaml:"memoryPressureNodesPercent"`     // Percentage of total nodes
+	DiskPressureNodesPercent       int `yaml:"diskPressureNodesPercent"`       // Percentage of total nodes
+	PIDPressureNodesPercent        int `yaml:"pidPressureNodesPercent"`        // Percentage of total nodes
+	NetworkUnavailableNodesPercent int `yaml:"networkUnavailableNodesPercent"` // Percentage of total nodes
+
+	DaemonSetNotReadyPercent int `yaml:"daemonSetNotReadyPercent"` // Percentage of daemon pods the selected DaemonSets should run
+
+	OOMKilledPods int `yaml:"oomKilledPods"` // Pods with a container OOM-killed within collection.oomKillWindow
+
+	// Warn holds the warn thresholds of metrics configured as {warn, abort}, with ThresholdDisabled for the
+	// others; nil when no metric has one. Crossing a warn threshold is reported but never aborts.
+	Warn *ThresholdsConfig `yaml:"-" json:"warn,omitempty"`
+
+	// set holds the names of the thresholds the YAML sets, including those set to 0, so Overlay can tell
+	// an override of 0 from one left out; nil when the thresholds were not decoded from YAML
+	set map[string]bool
+}
+
+// inheritImagePullThreshold applies the crashingPodsPercent set in a configuration to imagePullErrorsPercent
+// when the configuration leaves the latter out, so configurations written before image pull errors were
+// split from crashing pods keep the threshold they had for them
+func inheritImagePullThreshold(data []byte, thresholds *ThresholdsConfig) error {
+	var set struct {
+		Thresholds struct {
+			CrashingPodsPercent    *thresholdTiers `yaml:"crashingPodsPercent"`
+			ImagePullErrorsPercent *thresholdTiers `yaml:"imagePullErrorsPercent"`
+		} `yaml:"thresholds"`
+	}
+	if err := yaml.Unmarshal(data, &set); err != nil {
+		return err
+	}
+	if crashing := set.Thresholds.CrashingPodsPercent; crashing != nil && crashing.Abort != nil && set.Thresholds.ImagePullErrorsPercent == nil {
+		thresholds.ImagePullErrorsPercent = *crashing.Abort
+	}
+	return nil
+}
+
+// defaultConfig returns the configuration both loaders start from: the built-in defaults, with the Azure
+// identity taken from the environment
+func defaultConfig() *Config {
+	return &Config{
+		PollInterval: 30 * time.Second,
+		Azure: AzureConfig{
+			SubscriptionID:    os.Getenv("AZURE_SUBSCRIPTION_ID"),
+			ResourceGroupName: os.Getenv("AZURE_RESOURCE_GROUP"),
+			ClusterName:       os.Getenv("AZURE_CLUSTER_NAME"),
+			TenantID:          os.Getenv("AZURE_TENANT_ID"),
+			ClientID:          os.Getenv("AZURE_CLIENT_ID"),
+			ClientSecret:      os.Getenv("AZURE_CLIENT_SECRET"),
+			AuthMode:          getEnvOrDefault("AZURE_AUTH_MODE", AuthModeClientSecret),
+			Cloud:             getEnvOrDefault("AZURE_CLOUD", CloudAzurePublic),
+			AbortCredential: AbortCredentialConfig{
+				TenantID:     os.Getenv("AZURE_ABORT_TENANT_ID"),
+				ClientID:     os.Getenv("AZURE_ABORT_CLIENT_ID"),
+				ClientSecret: os.Getenv("AZURE_ABORT_CLIENT_SECRET"),
+			},
+			StatusSource:              StatusSourceARM,
+			ResourceGraphMaxStaleness: 5 * time.Minute,
+			CallTimeout:               30 * time.Second,
+			AbortTimeout:              10 * time.Minute,
+			WriteCircuit: CircuitBreakerConfig{
+				FailureThreshold: 3,
+				OpenDuration:     time.Minute,
+				MaxOpenDuration:  15 * time.Minute,
+			},
+			Retry: ARMRetryConfig{
+				MaxRetries:    3,
+				RetryDelay:    time.Second,
+				MaxRetryDelay: 30 * time.Second,
+			},
+		},
+		Thresholds: ThresholdsConfig{
+			CrashingPodsPercent:             10, // 10% of total pods
+			ImagePullErrorsPercent:          10, // 10% of total pods
+			PendingPodsPercent:              15, // 15% of total pods
+			NotReadyNodesPercent:            25, // 25% of total nodes
+			FailedJobs:                      3,
+			RestartCount:                    20,
+			RestartRate:                     10,
+			CpuUsagePercent:                 85,
+			MemoryUsagePercent:              90,
+			WorkloadsBlockedByAdmission:     1,
+			CpuRequestsSaturationPercent:    95,
+			MemoryRequestsSaturationPercent: 95,
+			P95SchedulingLatencySeconds:     120,
+			PodChurnPerMinute:               300,
+			FlappingPods:                    3,
+			NewPodsCrashingPercent:          10,
+			NewPodsPendingPercent:           15,
+			MaxNodeCpuUsagePercent:          95,
+			MaxNodeMemoryUsagePercent:       95,
+			MemoryPressureNodesPercent:      20,
+			DiskPressureNodesPercent:        20,
+			PIDPressureNodesPercent:         20,
+			NetworkUnavailableNodesPercent:  10,
+			DaemonSetNotReadyPercent:        10,
+			OOMKilledPods:                   3,
+		},
+		MonitoredOperations:   defaultMonitoredOperations(),
+		ConsecutiveViolations: 1,
+		AbortCooldown:         10 * time.Minute,
+		AbortSafety: AbortSafetyConfig{
+			MinSchedulableCapacityPercent: 50,
+			MaxKubeletMinorSkew:           2,
+		},
+		TrustedInitiators: TrustedInitiatorsConfig{
+			Lookback: 15 * time.Minute,
+		},
+		Collection: CollectionConfig{
+			Mode:                        CollectionModeFull,
+			Source:                      CollectionSourceList,
+			CacheSyncTimeout:            30 * time.Second,
+			RotationLength:              4,
+			AdmissionEventWindow:        10 * time.Minute,
+			OOMKillWindow:               15 * time.Minute,
+			FailedJobWindow:             time.Hour,
+			SchedulingLatencyMinSamples: 20,
+			NewPodsMinSamples:           10,
+			DiscoveryInterval:           10 * time.Minute,
+			CallTimeout:                 30 * time.Second,
+			PodPageSize:                 500,
+			NodePageSize:                500,
+			JobPageSize:                 500,
+			Namespaces: NamespaceSelection{
+				Mode:          NamespaceModeAll,
+				LabelCacheTTL: time.Minute,
+			},
+			ReimageLoop: ReimageLoopConfig{
+				Window:       30 * time.Minute,
+				MaxCreations: 10,
+			},
+			Flapping: FlappingConfig{
+				Window:         5 * time.Minute,
+				MinTransitions: 6,
+			},
+			IgnorableTerminatedReasons: []string{"Shutdown", "NodeShutdown"},
+			CordonTaints:               []string{"node.kubernetes.io/unschedulable"},
+		},
+		Maintenance: MaintenanceConfig{
+			LeadTime:        30 * time.Minute,
+			PollInterval:    10 * time.Second,
+			RefreshInterval: time.Hour,
+		},
+		Cadence: CadenceConfig{
+			DriftFactor: 2,
+			Window:      10,
+		},
+		Heartbeat: HeartbeatConfig{
+			Interval: 5 * time.Minute,
+			Channels: []string{HeartbeatChannelEvent},
+		},
+		PermissionCheck: PermissionCheckConfig{
+			Interval: time.Hour,
+		},
+		Readiness: ReadinessConfig{
+			MaxConsecutiveFailures: 3,
+		},
+		LeaderElection: LeaderElectionConfig{
+			LeaseName:     "aks-health-monitor",
+			LeaseDuration: 15 * time.Second,
+			RenewDeadline: 10 * time.Second,
+			RetryPeriod:   2 * time.Second,
+		},
+		Notifications: NotificationsConfig{
+			MaxRetries: 3,
+			Slack: SlackConfig{
+				Throttle: 30 * time.Minute,
+			},
+		},
+		Diagnostics: DiagnosticsConfig{
+			Timeout:  30 * time.Second,
+			MaxBytes: 5 << 20,
+			MaxPods:  20,
+		},
+		AuditTrail: AuditTrailConfig{
+			MaxEntries: 20,
+		},
+		DecisionLog: DecisionLogConfig{
+			BatchSize:     50,
+			FlushInterval: 10 * time.Second,
+			MaxRetries:    5,
+		},
+		Verdicts: VerdictsConfig{
+			SustainedCycles: 5,
+			MaxRetries:      5,
+		},
+		AzureClientStartup: AzureClientStartupConfig{
+			Retries:     5,
+			Backoff:     time.Second,
+			FailureMode: StartupFailureExit,
+		},
+		PanicRecovery: PanicRecoveryConfig{
+			MaxPanics: 3,
+			Window:    10 * time.Minute,
+		},
+		HistoryStorage: HistoryStorageConfig{
+			Retention:     7 * 24 * time.Hour,
+			PruneInterval: time.Hour,
+		},
+		StuckOperation: StuckOperationConfig{
+			StalenessPeriod: 2 * time.Hour,
+		},
+		Suggestions: SuggestionsConfig{
+			LearningWindow: 7 * 24 * time.Hour,
+			SampleInterval: 5 * time.Minute,
+			MarginPercent:  20,
+			MinSamples:     100,
+			LogInterval:    24 * time.Hour,
+		},
+	}
+}
+
+// LoadConfig loads configuration from a YAML file
+func LoadConfig(configPath string) (*Config, error) {
+	config := defaultConfig()
+
+	// If config file exists, load it
+	if _, err := os.Stat(configPath); err == nil {
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		if err := yaml.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+		if err := inheritImagePullThreshold(data, &config.Thresholds); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+	}
+
+	// Validate configuration; an invalid collectors block is dropped on its own
+	config.rejectInvalidCollectors()
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return config, nil
+}
+
+// LoadConfigFromConfigMap loads configuration from a ConfigMap-mounted file and environment variables
+// This function prioritizes environment variables over file configuration for cloud-native deployments
+func LoadConfigFromConfigMap(configPath string) (*Config, error) {
+	// Start from the defaults, with thresholds overridden by environment variables
+	env := &envParser{}
+	config := defaultConfig()
+	env.applyThresholds(&config.Thresholds)
+
+	// Parse poll interval from environment variable if provided
+	config.PollInterval = env.parseDurationEnvOrDefault("POLL_INTERVAL", config.PollInterval)
+
+	// Refuse malformed values rather than run with defaults nobody asked for
+	if err := env.err(); err != nil {
+		return nil, err
+	}
+
+	// If config file exists (from ConfigMap), overlay it on top of environment variables
+	if _, err := os.Stat(configPath); err == nil {
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file from ConfigMap: %w", err)
+		}
+
+		// Create a temporary config to merge file values
+		fileConfig := &Config{}
+		if err := yaml.Unmarshal(data, fileConfig); err != nil {
+			return nil, fmt.Errorf("failed to parse config file from ConfigMap: %w", err)
+		}
+
+		// Merge file config with environment-based config
+		// Environment variables take precedence over file values for Azure credentials
+		if fileConfig.PollInterval > 0 {
+			config.PollInterval = fileConfig.PollInterval
+		}
+
+		// Only use file values for Azure config if environment variables are not set
+		if config.Azure.SubscriptionID == "" && fileConfig.Azure.SubscriptionID != "" {
+			config.Azure.SubscriptionID = fileConfig.Azure.SubscriptionID
+		}
+		if config.Azure.ResourceGroupName == "" && fileConfig.Azure.ResourceGroupName != "" {
+			config.Azure.ResourceGroupName = fileConfig.Azure.ResourceGroupName
+		}
+		if config.Azure.ClusterName == "" && fileConfig.Azure.ClusterName != "" {
+			config.Azure.ClusterName = fileConfig.Azure.ClusterName
+		}
+		if config.Azure.TenantID == "" && fileConfig.Azure.TenantID != "" {
+			config.Azure.TenantID = fileConfig.Azure.TenantID
+		}
+		if config.Azure.ClientID == "" && fileConfig.Azure.ClientID != "" {
+			config.Azure.ClientID = fileConfig.Azure.ClientID
+		}
+		if config.Azure.ClientSecret == "" && fileConfig.Azure.ClientSecret != "" {
+			config.Azure.ClientSecret = fileConfig.Azure.ClientSecret
+		}
+		if os.Getenv("AZURE_AUTH_MODE") == "" && fileConfig.Azure.AuthMode != "" {
+			config.Azure.AuthMode = fileConfig.Azure.AuthMode
+		}
+		if os.Getenv("AZURE_CLOUD") == "" && fileConfig.Azure.Cloud != "" {
+			config.Azure.Cloud = fileConfig.Azure.Cloud
+		}
+		if fileConfig.Azure.CustomCloud.ActiveDirectoryAuthorityHost != "" {
+			config.Azure.CustomCloud.ActiveDirectoryAuthorityHost = fileConfig.Azure.CustomCloud.ActiveDirectoryAuthorityHost
+		}
+		if fileConfig.Azure.CustomCloud.ResourceManagerEndpoint != "" {
+			config.Azure.CustomCloud.ResourceManagerEndpoint = fileConfig.Azure.CustomCloud.ResourceManagerEndpoint
+		}
+		if fileConfig.Azure.CustomCloud.ResourceManagerAudience != "" {
+			config.Azure.CustomCloud.ResourceManagerAudience = fileConfig.Azure.CustomCloud.ResourceManagerAudience
+		}
+		if fileConfig.Azure.StatusSource != "" {
+			config.Azure.StatusSource = fileConfig.Azure.StatusSource
+		}
+		if fileConfig.Azure.ResourceGraphMaxStaleness > 0 {
+			config.Azure.ResourceGraphMaxStaleness = fileConfig.Azure.ResourceGraphMaxStaleness
+		}
+		if fileConfig.Azure.CallTimeout > 0 {
+			config.Azure.CallTimeout = fileConfig.Azure.CallTimeout
+		}
+		if fileConfig.Azure.AbortTimeout > 0 {
+			config.Azure.AbortTimeout = fileConfig.Azure.AbortTimeout
+		}
+		if fileConfig.Azure.WriteCircuit.FailureThreshold > 0 {
+			config.Azure.WriteCircuit.FailureThreshold = fileConfig.Azure.WriteCircuit.FailureThreshold
+		}
+		if fileConfig.Azure.WriteCircuit.OpenDuration > 0 {
+			config.Azure.WriteCircuit.OpenDuration = fileConfig.Azure.WriteCircuit.OpenDuration
+		}
+		if fileConfig.Azure.WriteCircuit.MaxOpenDuration > 0 {
+			config.Azure.WriteCircuit.MaxOpenDuration = fileConfig.Azure.WriteCircuit.MaxOpenDuration
+		}
+		if fileConfig.Azure.Retry.MaxRetries > 0 {
+			config.Azure.Retry.MaxRetries = fileConfig.Azure.Retry.MaxRetries
+		}
+		if fileConfig.Azure.Retry.RetryDelay > 0 {
+			config.Azure.Retry.RetryDelay = fileConfig.Azure.Retry.RetryDelay
+		}
+		if fileConfig.Azure.Retry.MaxRetryDelay > 0 {
+			config.Azure.Retry.MaxRetryDelay = fileConfig.Azure.Retry.MaxRetryDelay
+		}
+		config.Azure.ReadOnly = fileConfig.Azure.ReadOnly
+		if config.Azure.AbortCredential.TenantID == "" && fileConfig.Azure.AbortCredential.TenantID != "" {
+			config.Azure.AbortCredential.TenantID = fileConfig.Azure.AbortCredential.TenantID
+		}
+		if config.Azure.AbortCredential.ClientID == "" && fileConfig.Azure.AbortCredential.ClientID != "" {
+			config.Azure.AbortCredential.ClientID = fileConfig.Azure.AbortCredential.ClientID
+		}
+		if config.Azure.AbortCredential.ClientSecret == "" && fileConfig.Azure.AbortCredential.ClientSecret != "" {
+			config.Azure.AbortCredential.ClientSecret = fileConfig.Azure.AbortCredential.ClientSecret
+		}
+
+		// Merge threshold values (file takes precedence for thresholds). Decoding the file's thresholds onto
+		// the current ones keeps the thresholds it leaves out and applies any it sets, including 0.
+		thresholdsFile := struct {
+			Thresholds *ThresholdsConfig `yaml:"thresholds"`
+		}{Thresholds: &config.Thresholds}
+		if err := yaml.Unmarshal(data, &thresholdsFile); err != nil {
+			return nil, fmt.Errorf("failed to parse thresholds from ConfigMap: %w", err)
+		}
+		if err := inheritImagePullThreshold(data, &config.Thresholds); err != nil {
+			return nil, fmt.Errorf("failed to parse thresholds from ConfigMap: %w", err)
+		}
+
+		// Use monitored operations from file if provided
+		if len(fileConfig.MonitoredOperations) > 0 {
+			config.MonitoredOperations = fileConfig.MonitoredOperations
+		}
+		if fileConfig.ConsecutiveViolations > 0 {
+			config.ConsecutiveViolations = fileConfig.ConsecutiveViolations
+		}
+		if fileConfig.AbortCooldown > 0 {
+			config.AbortCooldown = fileConfig.AbortCooldown
+		}
+		if fileConfig.OperationGracePeriod > 0 {
+			config.OperationGracePeriod = fileConfig.OperationGracePeriod
+		}
+
+		// Listener addresses and scale policy are only configurable through the file
+		config.Server = fileConfig.Server
+		config.ScaleOperations = fileConfig.ScaleOperations
+
+		config.DryRun = fileConfig.DryRun
+
+		// Merge trusted initiators
+		if len(fileConfig.TrustedInitiators.Principals) > 0 {
+			config.TrustedInitiators.Principals = fileConfig.TrustedInitiators.Principals
+		}
+		if fileConfig.TrustedInitiators.Lookback > 0 {
+			config.TrustedInitiators.Lookback = fileConfig.TrustedInitiators.Lookback
+		}
+
+		// Merge abort safety settings
+		config.AbortSafety.Enabled = fileConfig.AbortSafety.Enabled
+		if fileConfig.AbortSafety.MinSchedulableCapacityPercent > 0 {
+			config.AbortSafety.MinSchedulableCapacityPercent = fileConfig.AbortSafety.MinSchedulableCapacityPercent
+		}
+		if fileConfig.AbortSafety.MaxKubeletMinorSkew > 0 {
+			config.AbortSafety.MaxKubeletMinorSkew = fileConfig.AbortSafety.MaxKubeletMinorSkew
+		}
+
+		// Merge collection settings
+		if fileConfig.Collection.Mode != "" {
+			config.Collection.Mode = fileConfig.Collection.Mode
+		}
+		if fileConfig.Collection.Source != "" {
+			config.Collection.Source = fileConfig.Collection.Source
+		}
+		if fileConfig.Collection.CacheSyncTimeout > 0 {
+			config.Collection.CacheSyncTimeout = fileConfig.Collection.CacheSyncTimeout
+		}
+		if fileConfig.Collection.RotationLength > 0 {
+			config.Collection.RotationLength = fileConfig.Collection.RotationLength
+		}
+		if fileConfig.Collection.AdmissionEventWindow > 0 {
+			config.Collection.AdmissionEventWindow = fileConfig.Collection.AdmissionEventWindow
+		}
+		if fileConfig.Collection.CrashRecencyWindow > 0 {
+			config.Collection.CrashRecencyWindow = fileConfig.Collection.CrashRecencyWindow
+		}
+		if fileConfig.Collection.OOMKillWindow > 0 {
+			config.Collection.OOMKillWindow = fileConfig.Collection.OOMKillWindow
+		}
+		if fileConfig.Collection.FailedJobWindow > 0 {
+			config.Collection.FailedJobWindow = fileConfig.Collection.FailedJobWindow
+		}
+		if fileConfig.Collection.SchedulingLatencyMinSamples > 0 {
+			config.Collection.SchedulingLatencyMinSamples = fileConfig.Collection.SchedulingLatencyMinSamples
+		}
+		config.Collection.ZoneRedundancy = fileConfig.Collection.ZoneRedundancy
+		config.Collection.NewPodMetrics = fileConfig.Collection.NewPodMetrics
+		config.Collection.MaxNodeUsage = fileConfig.Collection.MaxNodeUsage
+		if fileConfig.Collection.NewPodsMinSamples > 0 {
+			config.Collection.NewPodsMinSamples = fileConfig.Collection.NewPodsMinSamples
+		}
+		config.Collection.Budget = fileConfig.Collection.Budget
+		if fileConfig.Collection.DiscoveryInterval > 0 {
+			config.Collection.DiscoveryInterval = fileConfig.Collection.DiscoveryInterval
+		}
+		if fileConfig.Collection.CallTimeout > 0 {
+			config.Collection.CallTimeout = fileConfig.Collection.CallTimeout
+		}
+		if fileConfig.Collection.PodPageSize > 0 {
+			config.Collection.PodPageSize = fileConfig.Collection.PodPageSize
+		}
+		if fileConfig.Collection.NodePageSize > 0 {
+			config.Collection.NodePageSize = fileConfig.Collection.NodePageSize
+		}
+		if fileConfig.Collection.JobPageSize > 0 {
+			config.Collection.JobPageSize = fileConfig.Collection.JobPageSize
+		}
+		config.Collection.ExcludeSucceededPods = fileConfig.Collection.ExcludeSucceededPods
+		if fileConfig.Collection.Namespaces.Mode != "" {
+			config.Collection.Namespaces.Mode = fileConfig.Collection.Namespaces.Mode
+		}
+		config.Collection.Namespaces.Include = fileConfig.Collection.Namespaces.Include
+		config.Collection.Namespaces.Exclude = fileConfig.Collection.Namespaces.Exclude
+		if fileConfig.Collection.Namespaces.LabelCacheTTL > 0 {
+			config.Collection.Namespaces.LabelCacheTTL = fileConfig.Collection.Namespaces.LabelCacheTTL
+		}
+		config.Collection.ChurnExcludeOwnerKinds = fileConfig.Collection.ChurnExcludeOwnerKinds
+		if fileConfig.Collection.IgnorableTerminatedReasons != nil {
+			config.Collection.IgnorableTerminatedReasons = fileConfig.Collection.IgnorableTerminatedReasons
+		}
+		config.Collection.ReportShutdownTerminatedPods = fileConfig.Collection.ReportShutdownTerminatedPods
+		config.Collection.ExcludeCordonedNodes = fileConfig.Collection.ExcludeCordonedNodes
+		if fileConfig.Collection.CordonTaints != nil {
+			config.Collection.CordonTaints = fileConfig.Collection.CordonTaints
+		}
+		config.Collection.ReportRestartCount = fileConfig.Collection.ReportRestartCount
+		if fileConfig.Collection.ReimageLoop.Window > 0 {
+			config.Collection.ReimageLoop.Window = fileConfig.Collection.ReimageLoop.Window
+		}
+		if fileConfig.Collection.ReimageLoop.MaxCreations > 0 {
+			config.Collection.ReimageLoop.MaxCreations = fileConfig.Collection.ReimageLoop.MaxCreations
+		}
+		if fileConfig.Collection.Flapping.Window > 0 {
+			config.Collection.Flapping.Window = fileConfig.Collection.Flapping.Window
+		}
+		if fileConfig.Collection.Flapping.MinTransitions > 0 {
+			config.Collection.Flapping.MinTransitions = fileConfig.Collection.Flapping.MinTransitions
+		}
+		if fileConfig.Collection.DaemonSets.Namespace != "" {
+			config.Collection.DaemonSets.Namespace = fileConfig.Collection.DaemonSets.Namespace
+		}
+		if fileConfig.Collection.DaemonSets.Selector != "" {
+			config.Collection.DaemonSets.Selector = fileConfig.Collection.DaemonSets.Selector
+		}
+
+		// Metric windows are only configurable through the file
+		config.MetricWindows = fileConfig.MetricWindows
+
+		// Progress scaling is only configurable through the file
+		config.ProgressScaling = fileConfig.ProgressScaling
+
+		// Baseline deltas are only configurable through the file
+		config.BaselineDeltas = fileConfig.BaselineDeltas
+
+		// Critical thresholds are only configurable through the file
+		config.CriticalThresholds = fileConfig.CriticalThresholds
+
+		// Threshold guidance is only configurable through the file
+		config.ThresholdGuidance = fileConfig.ThresholdGuidance
+
+		// Pre-check thresholds are only configurable through the file
+		config.Precheck = fileConfig.Precheck
+
+		// Merge maintenance settings
+		config.Maintenance.Enabled = fileConfig.Maintenance.Enabled
+		if fileConfig.Maintenance.LeadTime > 0 {
+			config.Maintenance.LeadTime = fileConfig.Maintenance.LeadTime
+		}
+		if fileConfig.Maintenance.PollInterval > 0 {
+			config.Maintenance.PollInterval = fileConfig.Maintenance.PollInterval
+		}
+		if fileConfig.Maintenance.RefreshInterval > 0 {
+			config.Maintenance.RefreshInterval = fileConfig.Maintenance.RefreshInterval
+		}
+
+		// Merge cadence drift settings
+		if fileConfig.Cadence.DriftFactor > 0 {
+			config.Cadence.DriftFactor = fileConfig.Cadence.DriftFactor
+		}
+		if fileConfig.Cadence.Window > 0 {
+			config.Cadence.Window = fileConfig.Cadence.Window
+		}
+
+		// Merge heartbeat settings; an empty channel list leaves only the gauge
+		if fileConfig.Heartbeat.Interval > 0 {
+			config.Heartbeat.Interval = fileConfig.Heartbeat.Interval
+		}
+		if fileConfig.Heartbeat.Channels != nil {
+			config.Heartbeat.Channels = fileConfig.Heartbeat.Channels
+		}
+		config.Heartbeat.OutsideOperations = fileConfig.Heartbeat.OutsideOperations
+
+		// Merge permission check settings
+		if fileConfig.PermissionCheck.Interval > 0 {
+			config.PermissionCheck.Interval = fileConfig.PermissionCheck.Interval
+		}
+		config.PermissionCheck.Skip = fileConfig.PermissionCheck.Skip
+
+		// Merge readiness settings
+		if fileConfig.Readiness.MaxConsecutiveFailures > 0 {
+			config.Readiness.MaxConsecutiveFailures = fileConfig.Readiness.MaxConsecutiveFailures
+		}
+
+		// Merge collector blocks
+		if fileConfig.Collectors != nil {
+			config.Collectors = fileConfig.Collectors
+		}
+
+		// Merge leader election settings
+		if fileConfig.LeaderElection.LeaseName != "" {
+			config.LeaderElection.LeaseName = fileConfig.LeaderElection.LeaseName
+		}
+		if fileConfig.LeaderElection.LeaseDuration > 0 {
+			config.LeaderElection.LeaseDuration = fileConfig.LeaderElection.LeaseDuration
+		}
+		if fileConfig.LeaderElection.RenewDeadline > 0 {
+			config.LeaderElection.RenewDeadline = fileConfig.LeaderElection.RenewDeadline
+		}
+		if fileConfig.LeaderElection.RetryPeriod > 0 {
+			config.LeaderElection.RetryPeriod = fileConfig.LeaderElection.RetryPeriod
+		}
+
+		// Merge decision log settings
+		if fileConfig.DecisionLog.Endpoint != "" {
+			config.DecisionLog.Endpoint = fileConfig.DecisionLog.Endpoint
+		}
+		if fileConfig.DecisionLog.BatchSize > 0 {
+			config.DecisionLog.BatchSize = fileConfig.DecisionLog.BatchSize
+		}
+		if fileConfig.DecisionLog.FlushInterval > 0 {
+			config.DecisionLog.FlushInterval = fileConfig.DecisionLog.FlushInterval
+		}
+		if fileConfig.DecisionLog.MaxRetries > 0 {
+			config.DecisionLog.MaxRetries = fileConfig.DecisionLog.MaxRetries
+		}
+
+		// Merge notification settings
+		if fileConfig.Notifications.Webhooks != nil {
+			config.Notifications.Webhooks = fileConfig.Notifications.Webhooks
+		}
+		if fileConfig.Notifications.MaxRetries > 0 {
+			config.Notifications.MaxRetries = fileConfig.Notifications.MaxRetries
+		}
+		if fileConfig.Notifications.Slack.WebhookURLFile != "" {
+			config.Notifications.Slack.WebhookURLFile = fileConfig.Notifications.Slack.WebhookURLFile
+		}
+		if fileConfig.Notifications.Slack.BotTokenFile != "" {
+			config.Notifications.Slack.BotTokenFile = fileConfig.Notifications.Slack.BotTokenFile
+		}
+		if fileConfig.Notifications.Slack.Channel != "" {
+			config.Notifications.Slack.Channel = fileConfig.Notifications.Slack.Channel
+		}
+		if fileConfig.Notifications.Slack.Throttle > 0 {
+			config.Notifications.Slack.Throttle = fileConfig.Notifications.Slack.Throttle
+		}
+		if fileConfig.Notifications.Slack.Timeout > 0 {
+			config.Notifications.Slack.Timeout = fileConfig.Notifications.Slack.Timeout
+		}
+
+		// Merge diagnostic bundle settings
+		if fileConfig.Diagnostics.Path != "" {
+			config.Diagnostics.Path = fileConfig.Diagnostics.Path
+		}
+		if fileConfig.Diagnostics.BlobContainerURL != "" {
+			config.Diagnostics.BlobContainerURL = fileConfig.Diagnostics.BlobContainerURL
+		}
+		if fileConfig.Diagnostics.Timeout > 0 {
+			config.Diagnostics.Timeout = fileConfig.Diagnostics.Timeout
+		}
+		if fileConfig.Diagnostics.MaxBytes > 0 {
+			config.Diagnostics.MaxBytes = fileConfig.Diagnostics.MaxBytes
+		}
+		if fileConfig.Diagnostics.MaxPods > 0 {
+			config.Diagnostics.MaxPods = fileConfig.Diagnostics.MaxPods
+		}
+
+		// Merge audit trail settings
+		if fileConfig.AuditTrail.MaxEntries > 0 {
+			config.AuditTrail.MaxEntries = fileConfig.AuditTrail.MaxEntries
+		}
+
+		// Merge verdict publishing settings
+		if fileConfig.Verdicts.ServiceBusURL != "" {
+			config.Verdicts.ServiceBusURL = fileConfig.Verdicts.ServiceBusURL
+		}
+		if fileConfig.Verdicts.StorageQueueURL != "" {
+			config.Verdicts.StorageQueueURL = fileConfig.Verdicts.StorageQueueURL
+		}
+		if fileConfig.Verdicts.SustainedCycles > 0 {
+			config.Verdicts.SustainedCycles = fileConfig.Verdicts.SustainedCycles
+		}
+		if fileConfig.Verdicts.MaxRetries > 0 {
+			config.Verdicts.MaxRetries = fileConfig.Verdicts.MaxRetries
+		}
+
+		// Merge Azure client startup settings
+		if fileConfig.AzureClientStartup.Retries > 0 {
+			config.AzureClientStartup.Retries = fileConfig.AzureClientStartup.Retries
+		}
+		if fileConfig.AzureClientStartup.Backoff > 0 {
+			config.AzureClientStartup.Backoff = fileConfig.AzureClientStartup.Backoff
+		}
+		if fileConfig.AzureClientStartup.FailureMode != "" {
+			config.AzureClientStartup.FailureMode = fileConfig.AzureClientStartup.FailureMode
+		}
+
+		// Merge history storage settings
+		if fileConfig.HistoryStorage.Backend != "" {
+			config.HistoryStorage.Backend = fileConfig.HistoryStorage.Backend
+		}
+		if fileConfig.HistoryStorage.SQLitePath != "" {
+			config.HistoryStorage.SQLitePath = fileConfig.HistoryStorage.SQLitePath
+		}
+		if fileConfig.HistoryStorage.TableURL != "" {
+			config.HistoryStorage.TableURL = fileConfig.HistoryStorage.TableURL
+		}
+		if fileConfig.HistoryStorage.Retention > 0 {
+			config.HistoryStorage.Retention = fileConfig.HistoryStorage.Retention
+		}
+		if fileConfig.HistoryStorage.PruneInterval > 0 {
+			config.HistoryStorage.PruneInterval = fileConfig.HistoryStorage.PruneInterval
+		}
+
+		// Merge panic recovery settings
+		if fileConfig.PanicRecovery.MaxPanics > 0 {
+			config.PanicRecovery.MaxPanics = fileConfig.PanicRecovery.MaxPanics
+		}
+		if fileConfig.PanicRecovery.Window > 0 {
+			config.PanicRecovery.Window = fileConfig.PanicRecovery.Window
+		}
+
+		// Merge stuck operation settings
+		if fileConfig.StuckOperation.StalenessPeriod > 0 {
+			config.StuckOperation.StalenessPeriod = fileConfig.StuckOperation.StalenessPeriod
+		}
+		config.StuckOperation.TreatAsViolation = fileConfig.StuckOperation.TreatAsViolation
+
+		// Merge operation error settings
+		config.OperationErrors.TreatAsViolation = fileConfig.OperationErrors.TreatAsViolation
+
+		// Merge threshold suggestion settings
+		config.Suggestions.Enabled = fileConfig.Suggestions.Enabled
+		if fileConfig.Suggestions.LearningWindow > 0 {
+			config.Suggestions.LearningWindow = fileConfig.Suggestions.LearningWindow
+		}
+		if fileConfig.Suggestions.SampleInterval > 0 {
+			config.Suggestions.SampleInterval = fileConfig.Suggestions.SampleInterval
+		}
+		if fileConfig.Suggestions.MarginPercent > 0 {
+			config.Suggestions.MarginPercent = fileConfig.Suggestions.MarginPercent
+		}
+		if fileConfig.Suggestions.MinSamples > 0 {
+			config.Suggestions.MinSamples = fileConfig.Suggestions.MinSamples
+		}
+		if fileConfig.Suggestions.LogInterval > 0 {
+			config.Suggestions.LogInterval = fileConfig.Suggestions.LogInterval
+		}
+	}
+
+	// Validate configuration; an invalid collectors block is dropped on its own
+	config.rejectInvalidCollectors()
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return config, nil
+}
+
+// Validate checks if the configuration is valid
+func (c *Config) Validate() error {
+	if c.Azure.SubscriptionID == "" {
+		return fmt.Errorf("Azure subscription ID is required")
+	}
+	if c.Azure.ResourceGroupName == "" {
+		return fmt.Errorf("Azure resource group name is required")
+	}
+	if c.Azure.ClusterName == "" {
+		return fmt.Errorf("Azure cluster name is required")
+	}
+	switch c.Azure.AuthMode {
+	case "", AuthModeClientSecret:
+		if c.Azure.TenantID == "" || c.Azure.ClientID == "" || c.Azure.ClientSecret == "" {
+			return fmt.Errorf("Azure tenant ID, client ID and client secret are required with azure.authMode %q; use %q, %q or %q to authenticate without a secret",
+				AuthModeClientSecret, AuthModeManagedIdentity, AuthModeWorkloadIdentity, AuthModeDefault)
+		}
+	case AuthModeManagedIdentity, AuthModeWorkloadIdentity, AuthModeDefault:
+	default:
+		return fmt.Errorf("azure.authMode must be %q, %q, %q or %q, got: %q",
+			AuthModeClientSecret, AuthModeManagedIdentity, AuthModeWorkloadIdentity, AuthModeDefault, c.Azure.AuthMode)
+	}
+	switch c.Azure.Cloud {
+	case "", CloudAzurePublic, CloudAzureGovernment, CloudAzureChina:
+	case CloudCustom:
+		if err := c.Azure.CustomCloud.validate(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("azure.cloud must be %q, %q, %q or %q, got: %q",
+			CloudAzurePublic, CloudAzureGovernment, CloudAzureChina, CloudCustom, c.Azure.Cloud)
+	}
+	switch c.Azure.StatusSource {
+	case "", StatusSourceARM, StatusSourceResourceGraph:
+	default:
+		return fmt.Errorf("azure.statusSource must be %q or %q, got: %q", StatusSourceARM, StatusSourceResourceGraph, c.Azure.StatusSource)
+	}
+	if c.Azure.StatusSource == StatusSourceResourceGraph && c.Azure.ResourceGraphMaxStaleness <= 0 {
+		return fmt.Errorf("azure.resourceGraphMaxStaleness must be positive, got: %s", c.Azure.ResourceGraphMaxStaleness)
+	}
+	if c.Azure.WriteCircuit.FailureThreshold < 1 {
+		return fmt.Errorf("azure.writeCircuit.failureThreshold must be at least 1, got: %d", c.Azure.WriteCircuit.FailureThreshold)
+	}
+	if c.Azure.WriteCircuit.OpenDuration < time.Second {
+		return fmt.Errorf("azure.writeCircuit.openDuration must be at least 1 second, got: %s", c.Azure.WriteCircuit.OpenDuration)
+	}
+	if c.Azure.WriteCircuit.MaxOpenDuration < c.Azure.WriteCircuit.OpenDuration {
+		return fmt.Errorf("azure.writeCircuit.maxOpenDuration must be at least openDuration, got: %s", c.Azure.WriteCircuit.MaxOpenDuration)
+	}
+	if c.Azure.Retry.MaxRetries < 0 {
+		return fmt.Errorf("azure.retry.maxRetries must not be negative, got: %d", c.Azure.Retry.MaxRetries)
+	}
+	if c.Azure.Retry.RetryDelay <= 0 {
+		return fmt.Errorf("azure.retry.retryDelay must be positive, got: %s", c.Azure.Retry.RetryDelay)
+	}
+	if c.Azure.Retry.MaxRetryDelay < c.Azure.Retry.RetryDelay {
+		return fmt.Errorf("azure.retry.maxRetryDelay must be at least retryDelay, got: %s", c.Azure.Retry.MaxRetryDelay)
+	}
+	if c.Azure.CallTimeout <= 0 {
+		return fmt.Errorf("azure.callTimeout must be positive, got: %s", c.Azure.CallTimeout)
+	}
+	if c.Azure.AbortTimeout <= 0 {
+		return fmt.Errorf("azure.abortTimeout must be positive, got: %s", c.Azure.AbortTimeout)
+	}
+	if err := c.validateAbortCredential(); err != nil {
+		return err
+	}
+	if c.PollInterval < time.Second {
+		return fmt.Errorf("poll interval must be at least 1 second")
+	}
+
+	// Validate percentage thresholds
+	if err := c.Thresholds.Validate(); err != nil {
+		return err
+	}
+	if err := c.Thresholds.validateWarnTier(); err != nil {
+		return err
+	}
+	for _, operation := range c.MonitoredOperations {
+		if err := c.Thresholds.Overlay(operation.Thresholds).validateWarnTier(); err != nil {
+			return fmt.Errorf("monitoredOperations[%s].thresholds: %w", operation.Name, err)
+		}
+	}
 
-	// Operations to monitor
-	MonitoredOperations []string `yaml:"monitoredOperations"`
-}
+	// Validate monitored operations
+	if err := validateMonitoredOperations(c.MonitoredOperations); err != nil {
+		return err
+	}
+	if c.ConsecutiveViolations < 1 {
+		return fmt.Errorf("consecutiveViolations must be at least 1, got: %d", c.ConsecutiveViolations)
+	}
+	if c.AbortCooldown <= 0 {
+		return fmt.Errorf("abortCooldown must be positive, got: %s", c.AbortCooldown)
+	}
+	if c.OperationGracePeriod < 0 {
+		return fmt.Errorf("operationGracePeriod must not be negative, got: %s", c.OperationGracePeriod)
+	}
 
-// AzureConfig contains Azure-specific configuration
-type AzureConfig struct {
-	SubscriptionID    string `yaml:"subscriptionId"`
-	ResourceGroupName string `yaml:"resourceGroupName"`
-	ClusterName       string `yaml:"clusterName"`
-	TenantID          string `yaml:"tenantId"`
-	ClientID          string `yaml:"clientId"`
-	ClientSecret      string `yaml:"clientSecret"`
-}
+	// Validate abort safety invariants
+	if c.AbortSafety.MinSchedulableCapacityPercent < 0 || c.AbortSafety.MinSchedulableCapacityPercent > 100 {
+		return fmt.Errorf("abortSafety.minSchedulableCapacityPercent must be between 0 and 100, got: %d", c.AbortSafety.MinSchedulableCapacityPercent)
+	}
+	if c.AbortSafety.MaxKubeletMinorSkew < 0 {
+		return fmt.Errorf("abortSafety.maxKubeletMinorSkew must not be negative, got: %d", c.AbortSafety.MaxKubeletMinorSkew)
+	}
 
-// ThresholdsConfig defines the thresholds for various metrics
-type ThresholdsConfig struct {
-	CrashingPodsPercent  int `yaml:"crashingPodsPercent"`  // Percentage of total pods
-	PendingPodsPercent   int `yaml:"pendingPodsPercent"`   // Percentage of total pods
-	NotReadyNodesPercent int `yaml:"notReadyNodesPercent"` // Percentage of total nodes
-	FailedJobs           int `yaml:"failedJobs"`           // Absolute number
-	RestartCount         int `yaml:"restartCount"`         // Absolute number
-	CpuUsagePercent      int `yaml:"cpuUsagePercent"`      // Percentage
-	MemoryUsagePercent   int `yaml:"memoryUsagePercent"`   // Percentage
-}
+	// Validate trusted initiators
+	for _, principal := range c.TrustedInitiators.Principals {
+		if strings.TrimSpace(principal) == "" {
+			return fmt.Errorf("trustedInitiators.principals must not contain empty entries")
+		}
+	}
+	if c.TrustedInitiators.Lookback <= 0 {
+		return fmt.Errorf("trustedInitiators.lookback must be positive, got: %s", c.TrustedInitiators.Lookback)
+	}
 
-// LoadConfig loads configuration from a YAML file
-func LoadConfig(configPath string) (*Config, error) {
-	// Set default configuration
-	config := &Config{
-		PollInterval: 30 * time.Second,
-		Azure: AzureConfig{
-			SubscriptionID:    os.Getenv("AZURE_SUBSCRIPTION_ID"),
-			ResourceGroupName: os.Getenv("AZURE_RESOURCE_GROUP"),
-			ClusterName:       os.Getenv("AZURE_CLUSTER_NAME"),
-			TenantID:          os.Getenv("AZURE_TENANT_ID"),
-			ClientID:          os.Getenv("AZURE_CLIENT_ID"),
-			ClientSecret:      os.Getenv("AZURE_CLIENT_SECRET"),
-		},
-		Thresholds: ThresholdsConfig{
-			CrashingPodsPercent:  10, // 10% of total pods
-			PendingPodsPercent:   15, // 15% of total pods
-			NotReadyNodesPercent: 25, // 25% of total nodes
-			FailedJobs:           3,
-			RestartCount:         20,
-			CpuUsagePercent:      85,
-			MemoryUsagePercent:   90,
-		},
-		MonitoredOperations: []string{"upgrade", "update", "scale"},
+	// Validate collection settings
+	if c.Collection.Mode != CollectionModeFull && c.Collection.Mode != CollectionModeChunked {
+		return fmt.Errorf("collection.mode must be %q or %q, got: %q", CollectionModeFull, CollectionModeChunked, c.Collection.Mode)
+	}
+	if c.Collection.Source != CollectionSourceList && c.Collection.Source != CollectionSourceInformer {
+		return fmt.Errorf("collection.source must be %q or %q, got: %q", CollectionSourceList, CollectionSourceInformer, c.Collection.Source)
+	}
+	if c.Collection.CacheSyncTimeout <= 0 {
+		return fmt.Errorf("collection.cacheSyncTimeout must be positive, got: %s", c.Collection.CacheSyncTimeout)
+	}
+	if c.Collection.Mode == CollectionModeChunked && c.Collection.RotationLength < 1 {
+		return fmt.Errorf("collection.rotationLength must be at least 1 in chunked mode, got: %d", c.Collection.RotationLength)
+	}
+	if c.Collection.Budget.Timeout < 0 || c.Collection.Budget.MaxAPICalls < 0 {
+		return fmt.Errorf("collection.budget values must not be negative")
+	}
+	if c.Collection.CallTimeout <= 0 {
+		return fmt.Errorf("collection.callTimeout must be positive, got: %s", c.Collection.CallTimeout)
+	}
+	if c.Collection.CrashRecencyWindow < 0 {
+		return fmt.Errorf("collection.crashRecencyWindow must not be negative, got: %s", c.Collection.CrashRecencyWindow)
+	}
+	if c.Collection.OOMKillWindow <= 0 {
+		return fmt.Errorf("collection.oomKillWindow must be positive, got: %s", c.Collection.OOMKillWindow)
+	}
+	if c.Collection.FailedJobWindow <= 0 {
+		return fmt.Errorf("collection.failedJobWindow must be positive, got: %s", c.Collection.FailedJobWindow)
+	}
+	if c.Collection.PodPageSize < 1 {
+		return fmt.Errorf("collection.podPageSize must be at least 1, got: %d", c.Collection.PodPageSize)
+	}
+	if c.Collection.NodePageSize < 1 {
+		return fmt.Errorf("collection.nodePageSize must be at least 1, got: %d", c.Collection.NodePageSize)
+	}
+	if c.Collection.JobPageSize < 1 {
+		return fmt.Errorf("collection.jobPageSize must be at least 1, got: %d", c.Collection.JobPageSize)
+	}
+	if c.Collection.SchedulingLatencyMinSamples < 1 {
+		return fmt.Errorf("collection.schedulingLatencyMinSamples must be at least 1, got: %d", c.Collection.SchedulingLatencyMinSamples)
+	}
+	if c.Collection.NewPodsMinSamples < 1 {
+		return fmt.Errorf("collection.newPodsMinSamples must be at least 1, got: %d", c.Collection.NewPodsMinSamples)
+	}
+	if err := c.Collection.Namespaces.Validate(); err != nil {
+		return err
+	}
+	if _, err := labels.Parse(c.Collection.DaemonSets.Selector); err != nil {
+		return fmt.Errorf("collection.daemonSets.selector is not a valid label selector: %w", err)
+	}
+	if c.Collection.ReimageLoop.Window < time.Minute {
+		return fmt.Errorf("collection.reimageLoop.window must be at least 1 minute, got: %s", c.Collection.ReimageLoop.Window)
+	}
+	if c.Collection.ReimageLoop.MaxCreations < 1 {
+		return fmt.Errorf("collection.reimageLoop.maxCreations must be at least 1, got: %d", c.Collection.ReimageLoop.MaxCreations)
+	}
+	if c.Collection.Flapping.Window < time.Minute {
+		return fmt.Errorf("collection.flapping.window must be at least 1 minute, got: %s", c.Collection.Flapping.Window)
+	}
+	if c.Collection.Flapping.MinTransitions < 2 {
+		return fmt.Errorf("collection.flapping.minTransitions must be at least 2, got: %d", c.Collection.Flapping.MinTransitions)
 	}
 
-	// If config file exists, load it
-	if _, err := os.Stat(configPath); err == nil {
-		data, err := os.ReadFile(configPath)
+	// Validate metric windows
+	for metric, window := range c.MetricWindows {
+		if window.AppliesAfter < 0 {
+			return fmt.Errorf("metricWindows.%s.appliesAfter must not be negative", metric)
+		}
+		duration, _, err := window.Before()
 		if err != nil {
-			return nil, fmt.Errorf("failed to read config file: %w", err)
+			return fmt.Errorf("metricWindows.%s: %w", metric, err)
 		}
+		if duration > 0 && duration <= window.AppliesAfter {
+			return fmt.Errorf("metricWindows.%s.appliesBefore must be later than appliesAfter", metric)
+		}
+	}
 
-		if err := yaml.Unmarshal(data, config); err != nil {
-			return nil, fmt.Errorf("failed to parse config file: %w", err)
+	// Validate progress scaling
+	for metric, scaling := range c.ProgressScaling {
+		if scaling.Start < 0 || scaling.End < 0 {
+			return fmt.Errorf("progressScaling.%s: start and end must not be negative, got: %d and %d", metric, scaling.Start, scaling.End)
 		}
 	}
 
-	// Validate configuration
-	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid configuration: %w", err)
+	// Validate baseline deltas
+	for metric, delta := range c.BaselineDeltas {
+		if delta < 0 {
+			return fmt.Errorf("baselineDeltas.%s must not be negative, got: %d", metric, delta)
+		}
 	}
 
-	return config, nil
-}
+	// Validate critical thresholds
+	if c.CriticalThresholds.Warn != nil {
+		return fmt.Errorf("criticalThresholds: warn thresholds are only supported in thresholds and monitoredOperations")
+	}
+	if err := validateCriticalThresholds(c.CriticalThresholds, c.Thresholds); err != nil {
+		return err
+	}
 
-// LoadConfigFromConfigMap loads configuration from a ConfigMap-mounted file and environment variables
-// This function prioritizes environment variables over file configuration for cloud-native deployments
-func LoadConfigFromConfigMap(configPath string) (*Config, error) {
-	// Set default configuration with values from environment variables
-	config := &Config{
-		PollInterval: 30 * time.Second,
-		Azure: AzureConfig{
-			SubscriptionID:    getEnvOrDefault("AZURE_SUBSCRIPTION_ID", ""),
-			ResourceGroupName: getEnvOrDefault("AZURE_RESOURCE_GROUP", ""),
-			ClusterName:       getEnvOrDefault("AZURE_CLUSTER_NAME", ""),
-			TenantID:          getEnvOrDefault("AZURE_TENANT_ID", ""),
-			ClientID:          getEnvOrDefault("AZURE_CLIENT_ID", ""),
-			ClientSecret:      getEnvOrDefault("AZURE_CLIENT_SECRET", ""),
-		},
-		Thresholds: ThresholdsConfig{
-			CrashingPodsPercent:  parseIntEnvOrDefault("THRESHOLD_CRASHING_PODS_PERCENT", 10),
-			PendingPodsPercent:   parseIntEnvOrDefault("THRESHOLD_PENDING_PODS_PERCENT", 15),
-			NotReadyNodesPercent: parseIntEnvOrDefault("THRESHOLD_NOT_READY_NODES_PERCENT", 25),
-			FailedJobs:           parseIntEnvOrDefault("THRESHOLD_FAILED_JOBS", 3),
-			RestartCount:         parseIntEnvOrDefault("THRESHOLD_RESTART_COUNT", 20),
-			CpuUsagePercent:      parseIntEnvOrDefault("THRESHOLD_CPU_USAGE_PERCENT", 85),
-			MemoryUsagePercent:   parseIntEnvOrDefault("THRESHOLD_MEMORY_USAGE_PERCENT", 90),
-		},
-		MonitoredOperations: []string{"upgrade", "update", "scale"},
+	// Validate pre-check thresholds
+	if c.Precheck.Thresholds.Warn != nil {
+		return fmt.Errorf("precheck.thresholds: warn thresholds are only supported in thresholds and monitoredOperations")
+	}
+	if err := c.Precheck.Thresholds.Validate(); err != nil {
+		return fmt.Errorf("precheck.thresholds.%w", err)
 	}
 
-	// Parse poll interval from environment variable if provided
-	if pollIntervalStr := os.Getenv("POLL_INTERVAL"); pollIntervalStr != "" {
-		if duration, err := time.ParseDuration(pollIntervalStr); err == nil {
-			config.PollInterval = duration
+	// Validate threshold guidance
+	for metric, guidance := range c.ThresholdGuidance {
+		if err := guidance.validate(); err != nil {
+			return fmt.Errorf("thresholdGuidance.%s.%w", metric, err)
 		}
 	}
 
-	// If config file exists (from ConfigMap), overlay it on top of environment variables
-	if _, err := os.Stat(configPath); err == nil {
-		data, err := os.ReadFile(configPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read config file from ConfigMap: %w", err)
+	// Validate maintenance settings
+	if c.Maintenance.Enabled {
+		if c.Maintenance.PollInterval < time.Second {
+			return fmt.Errorf("maintenance.pollInterval must be at least 1 second")
 		}
+		if c.Maintenance.RefreshInterval < time.Minute {
+			return fmt.Errorf("maintenance.refreshInterval must be at least 1 minute")
+		}
+	}
 
-		// Create a temporary config to merge file values
-		fileConfig := &Config{}
-		if err := yaml.Unmarshal(data, fileConfig); err != nil {
-			return nil, fmt.Errorf("failed to parse config file from ConfigMap: %w", err)
+	// Validate cadence drift settings
+	if c.Cadence.DriftFactor <= 1 {
+		return fmt.Errorf("cadence.driftFactor must be greater than 1, got: %g", c.Cadence.DriftFactor)
+	}
+	if c.Cadence.Window < 1 {
+		return fmt.Errorf("cadence.window must be at least 1, got: %d", c.Cadence.Window)
+	}
+
+	if c.Readiness.MaxConsecutiveFailures < 1 {
+		return fmt.Errorf("readiness.maxConsecutiveFailures must be at least 1, got: %d", c.Readiness.MaxConsecutiveFailures)
+	}
+
+	// Validate leader election settings: the leader must give up before its Lease can expire
+	if c.LeaderElection.LeaseName == "" {
+		return fmt.Errorf("leaderElection.leaseName must not be empty")
+	}
+	if c.LeaderElection.RetryPeriod <= 0 {
+		return fmt.Errorf("leaderElection.retryPeriod must be positive, got: %s", c.LeaderElection.RetryPeriod)
+	}
+	if c.LeaderElection.RenewDeadline <= c.LeaderElection.RetryPeriod {
+		return fmt.Errorf("leaderElection.renewDeadline must be greater than leaderElection.retryPeriod, got: %s", c.LeaderElection.RenewDeadline)
+	}
+	if c.LeaderElection.LeaseDuration <= c.LeaderElection.RenewDeadline {
+		return fmt.Errorf("leaderElection.leaseDuration must be greater than leaderElection.renewDeadline, got: %s", c.LeaderElection.LeaseDuration)
+	}
+
+	// Validate heartbeat settings
+	if c.PermissionCheck.Interval < MinPermissionCheckInterval {
+		return fmt.Errorf("permissionCheck.interval must be at least %s, got: %s", MinPermissionCheckInterval, c.PermissionCheck.Interval)
+	}
+	if c.Heartbeat.Interval <= 0 {
+		return fmt.Errorf("heartbeat.interval must be positive, got: %s", c.Heartbeat.Interval)
+	}
+	for _, channel := range c.Heartbeat.Channels {
+		switch channel {
+		case HeartbeatChannelEvent, HeartbeatChannelLog:
+		case HeartbeatChannelVerdicts:
+			if !c.Verdicts.Enabled() {
+				return fmt.Errorf("heartbeat channel %q requires verdicts.serviceBusURL or verdicts.storageQueueURL", channel)
+			}
+		default:
+			return fmt.Errorf("heartbeat.channels must contain only %q, %q or %q, got: %q",
+				HeartbeatChannelEvent, HeartbeatChannelVerdicts, HeartbeatChannelLog, channel)
 		}
+	}
 
-		// Merge file config with environment-based config
-		// Environment variables take precedence over file values for Azure credentials
-		if fileConfig.PollInterval > 0 {
-			config.PollInterval = fileConfig.PollInterval
+	// Validate decision log settings
+	if c.DecisionLog.Endpoint != "" {
+		endpoint, err := url.Parse(c.DecisionLog.Endpoint)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return fmt.Errorf("decisionLog.endpoint must be an http or https URL, got: %q", c.DecisionLog.Endpoint)
+		}
+		if c.DecisionLog.BatchSize < 1 {
+			return fmt.Errorf("decisionLog.batchSize must be at least 1, got: %d", c.DecisionLog.BatchSize)
+		}
+		if c.DecisionLog.FlushInterval < time.Second {
+			return fmt.Errorf("decisionLog.flushInterval must be at least 1 second")
+		}
+		if c.DecisionLog.MaxRetries < 0 {
+			return fmt.Errorf("decisionLog.maxRetries must not be negative, got: %d", c.DecisionLog.MaxRetries)
 		}
+	}
 
-		// Only use file values for Azure config if environment variables are not set
-		if config.Azure.SubscriptionID == "" && fileConfig.Azure.SubscriptionID != "" {
-			config.Azure.SubscriptionID = fileConfig.Azure.SubscriptionID
+	// Validate notification settings
+	for i, webhook := range c.Notifications.Webhooks {
+		endpoint, err := url.Parse(webhook.URL)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return fmt.Errorf("notifications.webhooks[%d].url must be an http or https URL, got: %q", i, webhook.URL)
 		}
-		if config.Azure.ResourceGroupName == "" && fileConfig.Azure.ResourceGroupName != "" {
-			config.Azure.ResourceGroupName = fileConfig.Azure.ResourceGroupName
+		if webhook.Timeout < 0 {
+			return fmt.Errorf("notifications.webhooks[%d].timeout must not be negative, got: %s", i, webhook.Timeout)
 		}
-		if config.Azure.ClusterName == "" && fileConfig.Azure.ClusterName != "" {
-			config.Azure.ClusterName = fileConfig.Azure.ClusterName
+	}
+	if c.Notifications.MaxRetries < 0 {
+		return fmt.Errorf("notifications.maxRetries must not be negative, got: %d", c.Notifications.MaxRetries)
+	}
+	if slack := c.Notifications.Slack; slack.Enabled() {
+		if slack.WebhookURLFile != "" && slack.BotTokenFile != "" {
+			return fmt.Errorf("notifications.slack: set only one of webhookURLFile and botTokenFile")
 		}
-		if config.Azure.TenantID == "" && fileConfig.Azure.TenantID != "" {
-			config.Azure.TenantID = fileConfig.Azure.TenantID
+		if slack.BotTokenFile != "" && slack.Channel == "" {
+			return fmt.Errorf("notifications.slack.channel is required with botTokenFile")
 		}
-		if config.Azure.ClientID == "" && fileConfig.Azure.ClientID != "" {
-			config.Azure.ClientID = fileConfig.Azure.ClientID
+		if slack.Throttle < 0 {
+			return fmt.Errorf("notifications.slack.throttle must not be negative, got: %s", slack.Throttle)
 		}
-		if config.Azure.ClientSecret == "" && fileConfig.Azure.ClientSecret != "" {
-			config.Azure.ClientSecret = fileConfig.Azure.ClientSecret
+		if slack.Timeout < 0 {
+			return fmt.Errorf("notifications.slack.timeout must not be negative, got: %s", slack.Timeout)
+		}
+	}
+
+	// Validate diagnostic bundle settings
+	if c.Diagnostics.Path != "" && c.Diagnostics.BlobContainerURL != "" {
+		return fmt.Errorf("diagnostics: set only one of path and blobContainerURL")
+	}
+	if c.Diagnostics.BlobContainerURL != "" {
+		endpoint, err := url.Parse(c.Diagnostics.BlobContainerURL)
+		if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" || strings.Trim(endpoint.Path, "/") == "" {
+			return fmt.Errorf("diagnostics.blobContainerURL must be an https URL including the container name, got: %q", c.Diagnostics.BlobContainerURL)
 		}
+	}
+	if c.Diagnostics.Timeout <= 0 {
+		return fmt.Errorf("diagnostics.timeout must be positive, got: %s", c.Diagnostics.Timeout)
+	}
+	if c.Diagnostics.MaxBytes < 1 {
+		return fmt.Errorf("diagnostics.maxBytes must be at least 1, got: %d", c.Diagnostics.MaxBytes)
+	}
+	if c.Diagnostics.MaxPods < 1 {
+		return fmt.Errorf("diagnostics.maxPods must be at least 1, got: %d", c.Diagnostics.MaxPods)
+	}
 
-		// Merge threshold values (file takes precedence for thresholds)
-		if fileConfig.Thresholds.CrashingPodsPercent > 0 {
-			config.Thresholds.CrashingPodsPercent = fileConfig.Thresholds.CrashingPodsPercent
+	// Validate audit trail settings
+	if c.AuditTrail.MaxEntries < 1 || c.AuditTrail.MaxEntries > MaxAuditTrailEntries {
+		return fmt.Errorf("auditTrail.maxEntries must be between 1 and %d, got: %d", MaxAuditTrailEntries, c.AuditTrail.MaxEntries)
+	}
+
+	// Validate verdict publishing settings
+	if c.Verdicts.ServiceBusURL != "" && c.Verdicts.StorageQueueURL != "" {
+		return fmt.Errorf("verdicts: set only one of serviceBusURL and storageQueueURL")
+	}
+	if c.Verdicts.Enabled() {
+		for field, value := range map[string]string{"serviceBusURL": c.Verdicts.ServiceBusURL, "storageQueueURL": c.Verdicts.StorageQueueURL} {
+			if value == "" {
+				continue
+			}
+			endpoint, err := url.Parse(value)
+			if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" || strings.Trim(endpoint.Path, "/") == "" {
+				return fmt.Errorf("verdicts.%s must be an https URL including the queue or topic name, got: %q", field, value)
+			}
 		}
-		if fileConfig.Thresholds.PendingPodsPercent > 0 {
-			config.Thresholds.PendingPodsPercent = fileConfig.Thresholds.PendingPodsPercent
+		if c.Verdicts.SustainedCycles < 1 {
+			return fmt.Errorf("verdicts.sustainedCycles must be at least 1, got: %d", c.Verdicts.SustainedCycles)
 		}
-		if fileConfig.Thresholds.NotReadyNodesPercent > 0 {
-			config.Thresholds.NotReadyNodesPercent = fileConfig.Thresholds.NotReadyNodesPercent
+		if c.Verdicts.MaxRetries < 0 {
+			return fmt.Errorf("verdicts.maxRetries must not be negative, got: %d", c.Verdicts.MaxRetries)
 		}
-		if fileConfig.Thresholds.FailedJobs > 0 {
-			config.Thresholds.FailedJobs = fileConfig.Thresholds.FailedJobs
+	}
+
+	// Validate Azure client startup settings
+	switch c.AzureClientStartup.FailureMode {
+	case "", StartupFailureExit, StartupFailureMetricsOnly:
+	default:
+		return fmt.Errorf("azureClientStartup.failureMode must be %q or %q, got: %q", StartupFailureExit, StartupFailureMetricsOnly, c.AzureClientStartup.FailureMode)
+	}
+	if c.AzureClientStartup.Retries < 0 {
+		return fmt.Errorf("azureClientStartup.retries must not be negative, got: %d", c.AzureClientStartup.Retries)
+	}
+	if c.AzureClientStartup.Backoff < 0 {
+		return fmt.Errorf("azureClientStartup.backoff must not be negative, got: %s", c.AzureClientStartup.Backoff)
+	}
+
+	// Validate history storage settings
+	switch c.HistoryStorage.Backend {
+	case "":
+	case HistoryBackendSQLite:
+		if c.HistoryStorage.SQLitePath == "" {
+			return fmt.Errorf("historyStorage.sqlitePath is required for the %s backend", HistoryBackendSQLite)
 		}
-		if fileConfig.Thresholds.RestartCount > 0 {
-			config.Thresholds.RestartCount = fileConfig.Thresholds.RestartCount
+	case HistoryBackendAzureTable:
+		endpoint, err := url.Parse(c.HistoryStorage.TableURL)
+		if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" || strings.Trim(endpoint.Path, "/") == "" {
+			return fmt.Errorf("historyStorage.tableURL must be an https URL including the table name, got: %q", c.HistoryStorage.TableURL)
 		}
-		if fileConfig.Thresholds.CpuUsagePercent > 0 {
-			config.Thresholds.CpuUsagePercent = fileConfig.Thresholds.CpuUsagePercent
+	default:
+		return fmt.Errorf("historyStorage.backend must be %q or %q, got: %q", HistoryBackendSQLite, HistoryBackendAzureTable, c.HistoryStorage.Backend)
+	}
+	if c.HistoryStorage.Backend != "" {
+		if c.HistoryStorage.Retention < time.Hour {
+			return fmt.Errorf("historyStorage.retention must be at least 1 hour, got: %s", c.HistoryStorage.Retention)
 		}
-		if fileConfig.Thresholds.MemoryUsagePercent > 0 {
-			config.Thresholds.MemoryUsagePercent = fileConfig.Thresholds.MemoryUsagePercent
+		if c.HistoryStorage.PruneInterval < time.Minute {
+			return fmt.Errorf("historyStorage.pruneInterval must be at least 1 minute, got: %s", c.HistoryStorage.PruneInterval)
 		}
+	}
 
-		// Use monitored operations from file if provided
-		if len(fileConfig.MonitoredOperations) > 0 {
-			config.MonitoredOperations = fileConfig.MonitoredOperations
+	// Validate panic recovery settings
+	if c.PanicRecovery.MaxPanics < 1 {
+		return fmt.Errorf("panicRecovery.maxPanics must be at least 1, got: %d", c.PanicRecovery.MaxPanics)
+	}
+	if c.PanicRecovery.Window <= 0 {
+		return fmt.Errorf("panicRecovery.window must be positive, got: %s", c.PanicRecovery.Window)
+	}
+
+	// Validate stuck operation settings
+	if c.StuckOperation.StalenessPeriod < 10*time.Minute {
+		return fmt.Errorf("stuckOperation.stalenessPeriod must be at least 10 minutes, got: %s", c.StuckOperation.StalenessPeriod)
+	}
+
+	// Validate threshold suggestion settings
+	if c.Suggestions.Enabled {
+		if c.Suggestions.LearningWindow < time.Hour {
+			return fmt.Errorf("suggestions.learningWindow must be at least 1 hour, got: %s", c.Suggestions.LearningWindow)
+		}
+		if c.Suggestions.SampleInterval <= 0 {
+			return fmt.Errorf("suggestions.sampleInterval must be positive, got: %s", c.Suggestions.SampleInterval)
+		}
+		if c.Suggestions.MarginPercent < 0 || c.Suggestions.MarginPercent > 1000 {
+			return fmt.Errorf("suggestions.marginPercent must be between 0 and 1000, got: %d", c.Suggestions.MarginPercent)
+		}
+		if c.Suggestions.MinSamples < 1 {
+			return fmt.Errorf("suggestions.minSamples must be at least 1, got: %d", c.Suggestions.MinSamples)
+		}
+		if c.Suggestions.LogInterval < time.Minute {
+			return fmt.Errorf("suggestions.logInterval must be at least 1 minute, got: %s", c.Suggestions.LogInterval)
 		}
 	}
 
-	// Validate configuration
-	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid configuration: %w", err)
+	// Validate listener bind addresses
+	if err := validateBindAddress("server.metricsAddress", c.Server.MetricsAddress); err != nil {
+		return err
+	}
+	if err := validateBindAddress("server.healthAddress", c.Server.HealthAddress); err != nil {
+		return err
+	}
+	if err := validateBindAddress("server.adminAddress", c.Server.AdminAddress); err != nil {
+		return err
+	}
+	if err := c.Server.Metrics.validate("server.metrics"); err != nil {
+		return err
+	}
+	if err := c.Server.Health.validate("server.health"); err != nil {
+		return err
+	}
+	if err := c.Server.Admin.validate("server.admin"); err != nil {
+		return err
 	}
 
-	return config, nil
+	return nil
 }
 
-// Validate checks if the configuration is valid
-func (c *Config) Validate() error {
-	if c.Azure.SubscriptionID == "" {
-		return fmt.Errorf("Azure subscription ID is required")
+// validateBindAddress checks that a listener address is a valid host:port pair with an IP literal or empty host
+func validateBindAddress(field, address string) error {
+	if address == "" {
+		return nil
 	}
-	if c.Azure.ResourceGroupName == "" {
-		return fmt.Errorf("Azure resource group name is required")
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%s must be in host:port form (IPv6 hosts in brackets, e.g. [::]:8080), got: %q", field, address)
 	}
-	if c.Azure.ClusterName == "" {
-		return fmt.Errorf("Azure cluster name is required")
+	if host != "" && host != "localhost" && net.ParseIP(host) == nil {
+		return fmt.Errorf("%s host must be an IP address, \"localhost\" or empty, got: %q", field, host)
 	}
-	if c.PollInterval < time.Second {
-		return fmt.Errorf("poll interval must be at least 1 second")
+	portNumber, err := strconv.Atoi(port)
+	if err != nil || portNumber < 0 || portNumber > 65535 {
+		return fmt.Errorf("%s port must be a number between 0 and 65535, got: %q", field, port)
 	}
 
-	// Validate percentage thresholds
-	if c.Thresholds.CrashingPodsPercent < 0 || c.Thresholds.CrashingPodsPercent > 100 {
-		return fmt.Errorf("crashingPodsPercent must be between 0 and 100, got: %d", c.Thresholds.CrashingPodsPercent)
+	return nil
+}
+
+// Validate checks that threshold values are within their allowed ranges
+func (t ThresholdsConfig) Validate() error {
+	if t.CrashingPodsPercent < ThresholdDisabled || t.CrashingPodsPercent > 100 {
+		return fmt.Errorf("crashingPodsPercent must be -1 or between 0 and 100, got: %d", t.CrashingPodsPercent)
+	}
+	if t.ImagePullErrorsPercent < ThresholdDisabled || t.ImagePullErrorsPercent > 100 {
+		return fmt.Errorf("imagePullErrorsPercent must be -1 or between 0 and 100, got: %d", t.ImagePullErrorsPercent)
+	}
+	if t.PendingPodsPercent < ThresholdDisabled || t.PendingPodsPercent > 100 {
+		return fmt.Errorf("pendingPodsPercent must be -1 or between 0 and 100, got: %d", t.PendingPodsPercent)
+	}
+	if t.NotReadyNodesPercent < ThresholdDisabled || t.NotReadyNodesPercent > 100 {
+		return fmt.Errorf("notReadyNodesPercent must be -1 or between 0 and 100, got: %d", t.NotReadyNodesPercent)
+	}
+	if t.CpuUsagePercent < ThresholdDisabled || t.CpuUsagePercent > 100 {
+		return fmt.Errorf("cpuUsagePercent must be -1 or between 0 and 100, got: %d", t.CpuUsagePercent)
+	}
+	if t.MemoryUsagePercent < ThresholdDisabled || t.MemoryUsagePercent > 100 {
+		return fmt.Errorf("memoryUsagePercent must be -1 or between 0 and 100, got: %d", t.MemoryUsagePercent)
+	}
+	if t.CpuRequestsSaturationPercent < ThresholdDisabled || t.CpuRequestsSaturationPercent > 100 {
+		return fmt.Errorf("cpuRequestsSaturationPercent must be -1 or between 0 and 100, got: %d", t.CpuRequestsSaturationPercent)
+	}
+	if t.MemoryRequestsSaturationPercent < ThresholdDisabled || t.MemoryRequestsSaturationPercent > 100 {
+		return fmt.Errorf("memoryRequestsSaturationPercent must be -1 or between 0 and 100, got: %d", t.MemoryRequestsSaturationPercent)
+	}
+	if t.FailedJobs < ThresholdDisabled {
+		return fmt.Errorf("failedJobs must be at least -1, got: %d", t.FailedJobs)
+	}
+	if t.RestartCount < ThresholdDisabled {
+		return fmt.Errorf("restartCount must be at least -1, got: %d", t.RestartCount)
+	}
+	if t.RestartRate < ThresholdDisabled {
+		return fmt.Errorf("restartRate must be at least -1, got: %d", t.RestartRate)
+	}
+	if t.WorkloadsBlockedByAdmission < ThresholdDisabled {
+		return fmt.Errorf("workloadsBlockedByAdmission must be at least -1, got: %d", t.WorkloadsBlockedByAdmission)
+	}
+	if t.P95SchedulingLatencySeconds < ThresholdDisabled {
+		return fmt.Errorf("p95SchedulingLatencySeconds must be at least -1, got: %d", t.P95SchedulingLatencySeconds)
+	}
+	if t.WorkloadsLosingZoneRedundancy < ThresholdDisabled {
+		return fmt.Errorf("workloadsLosingZoneRedundancy must be at least -1, got: %d", t.WorkloadsLosingZoneRedundancy)
 	}
-	if c.Thresholds.PendingPodsPercent < 0 || c.Thresholds.PendingPodsPercent > 100 {
-		return fmt.Errorf("pendingPodsPercent must be between 0 and 100, got: %d", c.Thresholds.PendingPodsPercent)
+	if t.PodChurnPerMinute < ThresholdDisabled {
+		return fmt.Errorf("podChurnPerMinute must be at least -1, got: %d", t.PodChurnPerMinute)
 	}
-	if c.Thresholds.NotReadyNodesPercent < 0 || c.Thresholds.NotReadyNodesPercent > 100 {
-		return fmt.Errorf("notReadyNodesPercent must be between 0 and 100, got: %d", c.Thresholds.NotReadyNodesPercent)
+	if t.SuspectedNodeReimageLoops < ThresholdDisabled {
+		return fmt.Errorf("suspectedNodeReimageLoops must be at least -1, got: %d", t.SuspectedNodeReimageLoops)
 	}
-	if c.Thresholds.CpuUsagePercent < 0 || c.Thresholds.CpuUsagePercent > 100 {
-		return fmt.Errorf("cpuUsagePercent must be between 0 and 100, got: %d", c.Thresholds.CpuUsagePercent)
+	if t.FlappingPods < ThresholdDisabled {
+		return fmt.Errorf("flappingPods must be at least -1, got: %d", t.FlappingPods)
 	}
-	if c.Thresholds.MemoryUsagePercent < 0 || c.Thresholds.MemoryUsagePercent > 100 {
-		return fmt.Errorf("memoryUsagePercent must be between 0 and 100, got: %d", c.Thresholds.MemoryUsagePercent)
+	if t.NewPodsCrashingPercent < ThresholdDisabled || t.NewPodsCrashingPercent > 100 {
+		return fmt.Errorf("newPodsCrashingPercent must be -1 or between 0 and 100, got: %d", t.NewPodsCrashingPercent)
+	}
+	if t.NewPodsPendingPercent < ThresholdDisabled || t.NewPodsPendingPercent > 100 {
+		return fmt.Errorf("newPodsPendingPercent must be -1 or between 0 and 100, got: %d", t.NewPodsPendingPercent)
+	}
+	if t.MaxNodeCpuUsagePercent < ThresholdDisabled || t.MaxNodeCpuUsagePercent > 100 {
+		return fmt.Errorf("maxNodeCpuUsagePercent must be -1 or between 0 and 100, got: %d", t.MaxNodeCpuUsagePercent)
+	}
+	if t.MaxNodeMemoryUsagePercent < ThresholdDisabled || t.MaxNodeMemoryUsagePercent > 100 {
+		return fmt.Errorf("maxNodeMemoryUsagePercent must be -1 or between 0 and 100, got: %d", t.MaxNodeMemoryUsagePercent)
+	}
+	if t.ConfigReferenceFailures < ThresholdDisabled {
+		return fmt.Errorf("configReferenceFailures must be at least -1, got: %d", t.ConfigReferenceFailures)
+	}
+	if t.MemoryPressureNodesPercent < ThresholdDisabled || t.MemoryPressureNodesPercent > 100 {
+		return fmt.Errorf("memoryPressureNodesPercent must be -1 or between 0 and 100, got: %d", t.MemoryPressureNodesPercent)
+	}
+	if t.DiskPressureNodesPercent < ThresholdDisabled || t.DiskPressureNodesPercent > 100 {
+		return fmt.Errorf("diskPressureNodesPercent must be -1 or between 0 and 100, got: %d", t.DiskPressureNodesPercent)
+	}
+	if t.PIDPressureNodesPercent < ThresholdDisabled || t.PIDPressureNodesPercent > 100 {
+		return fmt.Errorf("pidPressureNodesPercent must be -1 or between 0 and 100, got: %d", t.PIDPressureNodesPercent)
+	}
+	if t.NetworkUnavailableNodesPercent < ThresholdDisabled || t.NetworkUnavailableNodesPercent > 100 {
+		return fmt.Errorf("networkUnavailableNodesPercent must be -1 or between 0 and 100, got: %d", t.NetworkUnavailableNodesPercent)
+	}
+	if t.DaemonSetNotReadyPercent < ThresholdDisabled || t.DaemonSetNotReadyPercent > 100 {
+		return fmt.Errorf("daemonSetNotReadyPercent must be -1 or between 0 and 100, got: %d", t.DaemonSetNotReadyPercent)
+	}
+	if t.OOMKilledPods < ThresholdDisabled {
+		return fmt.Errorf("oomKilledPods must be at least -1, got: %d", t.OOMKilledPods)
 	}
 
 	return nil
 }
 
+// Redacted returns a copy of the configuration with secrets masked, suitable for logging or serving
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	if redacted.Azure.ClientSecret != "" {
+		redacted.Azure.ClientSecret = redactedValue
+	}
+	if redacted.Azure.AbortCredential.ClientSecret != "" {
+		redacted.Azure.AbortCredential.ClientSecret = redactedValue
+	}
+	return &redacted
+}
+
+// redactedValue replaces secret values in redacted configurations
+const redactedValue = "REDACTED"
+
 // getEnvOrDefault returns the value of an environment variable or a default value
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -242,12 +2270,87 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
-// parseIntEnvOrDefault parses an integer from an environment variable or returns a default value
-func parseIntEnvOrDefault(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
-		}
+// envParser parses configuration from environment variables. Malformed values are collected instead of
+// falling back to the default, so loading fails naming every one of them.
+type envParser struct {
+	errs []error
+}
+
+// parseIntEnvOrDefault parses an integer from an environment variable, or returns a default value when
+// the variable is unset or empty
+func (p *envParser) parseIntEnvOrDefault(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
 	}
-	return defaultValue
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("environment variable %s must be an integer, got: %q", key, value))
+		return defaultValue
+	}
+	return intValue
+}
+
+// parseDurationEnvOrDefault parses a duration such as "30s" from an environment variable, or returns a
+// default value when the variable is unset or empty. A number without a unit is malformed.
+func (p *envParser) parseDurationEnvOrDefault(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("environment variable %s must be a duration with a unit, such as 30s, got: %q", key, value))
+		return defaultValue
+	}
+	return duration
+}
+
+// applyThresholds overrides thresholds with the THRESHOLD_* environment variables that are set.
+// imagePullErrorsPercent follows THRESHOLD_CRASHING_PODS_PERCENT unless it is set itself.
+func (p *envParser) applyThresholds(thresholds *ThresholdsConfig) {
+	overrides := []struct {
+		key   string
+		value *int
+	}{
+		{"THRESHOLD_CRASHING_PODS_PERCENT", &thresholds.CrashingPodsPercent},
+		{"THRESHOLD_PENDING_PODS_PERCENT", &thresholds.PendingPodsPercent},
+		{"THRESHOLD_NOT_READY_NODES_PERCENT", &thresholds.NotReadyNodesPercent},
+		{"THRESHOLD_FAILED_JOBS", &thresholds.FailedJobs},
+		{"THRESHOLD_RESTART_COUNT", &thresholds.RestartCount},
+		{"THRESHOLD_RESTART_RATE", &thresholds.RestartRate},
+		{"THRESHOLD_CPU_USAGE_PERCENT", &thresholds.CpuUsagePercent},
+		{"THRESHOLD_MEMORY_USAGE_PERCENT", &thresholds.MemoryUsagePercent},
+		{"THRESHOLD_WORKLOADS_BLOCKED_BY_ADMISSION", &thresholds.WorkloadsBlockedByAdmission},
+		{"THRESHOLD_CPU_REQUESTS_SATURATION_PERCENT", &thresholds.CpuRequestsSaturationPercent},
+		{"THRESHOLD_MEMORY_REQUESTS_SATURATION_PERCENT", &thresholds.MemoryRequestsSaturationPercent},
+		{"THRESHOLD_P95_SCHEDULING_LATENCY_SECONDS", &thresholds.P95SchedulingLatencySeconds},
+		{"THRESHOLD_WORKLOADS_LOSING_ZONE_REDUNDANCY", &thresholds.WorkloadsLosingZoneRedundancy},
+		{"THRESHOLD_POD_CHURN_PER_MINUTE", &thresholds.PodChurnPerMinute},
+		{"THRESHOLD_SUSPECTED_NODE_REIMAGE_LOOPS", &thresholds.SuspectedNodeReimageLoops},
+		{"THRESHOLD_FLAPPING_PODS", &thresholds.FlappingPods},
+		{"THRESHOLD_NEW_PODS_CRASHING_PERCENT", &thresholds.NewPodsCrashingPercent},
+		{"THRESHOLD_NEW_PODS_PENDING_PERCENT", &thresholds.NewPodsPendingPercent},
+		{"THRESHOLD_MAX_NODE_CPU_USAGE_PERCENT", &thresholds.MaxNodeCpuUsagePercent},
+		{"THRESHOLD_MAX_NODE_MEMORY_USAGE_PERCENT", &thresholds.MaxNodeMemoryUsagePercent},
+		{"THRESHOLD_CONFIG_REFERENCE_FAILURES", &thresholds.ConfigReferenceFailures},
+		{"THRESHOLD_MEMORY_PRESSURE_NODES_PERCENT", &thresholds.MemoryPressureNodesPercent},
+		{"THRESHOLD_DISK_PRESSURE_NODES_PERCENT", &thresholds.DiskPressureNodesPercent},
+		{"THRESHOLD_PID_PRESSURE_NODES_PERCENT", &thresholds.PIDPressureNodesPercent},
+		{"THRESHOLD_NETWORK_UNAVAILABLE_NODES_PERCENT", &thresholds.NetworkUnavailableNodesPercent},
+		{"THRESHOLD_DAEMONSET_NOT_READY_PERCENT", &thresholds.DaemonSetNotReadyPercent},
+		{"THRESHOLD_OOM_KILLED_PODS", &thresholds.OOMKilledPods},
+	}
+	for _, override := range overrides {
+		*override.value = p.parseIntEnvOrDefault(override.key, *override.value)
+	}
+	thresholds.ImagePullErrorsPercent = p.parseIntEnvOrDefault("THRESHOLD_IMAGE_PULL_ERRORS_PERCENT", thresholds.CrashingPodsPercent)
+}
+
+// err returns the malformed values found so far, or nil
+func (p *envParser) err() error {
+	if len(p.errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration from the environment: %w", errors.Join(p.errs...))
 }
@@ -0,0 +1,282 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setAzureEnv sets the Azure identity both loaders require
+func setAzureEnv(t *testing.T) {
+	t.Helper()
+	for key, value := range map[string]string{
+		"AZURE_SUBSCRIPTION_ID": "00000000-0000-0000-0000-000000000000",
+		"AZURE_RESOURCE_GROUP":  "rg",
+		"AZURE_CLUSTER_NAME":    "cluster",
+		"AZURE_TENANT_ID":       "tenant",
+		"AZURE_CLIENT_ID":       "client",
+		"AZURE_CLIENT_SECRET":   "secret",
+	} {
+		t.Setenv(key, value)
+	}
+}
+
+// writeConfig writes a configuration file and returns its path
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// loaders are both ways to load a configuration, which must agree on everything the file sets
+var loaders = map[string]func(string) (*Config, error){
+	"LoadConfig":              LoadConfig,
+	"LoadConfigFromConfigMap": LoadConfigFromConfigMap,
+}
+
+func TestLoadersShareDefaults(t *testing.T) {
+	setAzureEnv(t)
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+
+	fromFile, err := LoadConfig(missing)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	fromConfigMap, err := LoadConfigFromConfigMap(missing)
+	if err != nil {
+		t.Fatalf("LoadConfigFromConfigMap: %v", err)
+	}
+	if !reflect.DeepEqual(fromFile, fromConfigMap) {
+		t.Errorf("defaults differ between loaders:\nLoadConfig:              %+v\nLoadConfigFromConfigMap: %+v", fromFile, fromConfigMap)
+	}
+	if !reflect.DeepEqual(fromFile, withDefaultsValidated(t)) {
+		t.Errorf("LoadConfig without a file differs from defaultConfig")
+	}
+}
+
+// withDefaultsValidated returns defaultConfig after the same post-processing the loaders apply
+func withDefaultsValidated(t *testing.T) *Config {
+	t.Helper()
+	config := defaultConfig()
+	config.rejectInvalidCollectors()
+	if err := config.Validate(); err != nil {
+		t.Fatalf("defaults are invalid: %v", err)
+	}
+	return config
+}
+
+func TestThresholdEnvironmentOverridesConfigMapDefaults(t *testing.T) {
+	setAzureEnv(t)
+	t.Setenv("THRESHOLD_CRASHING_PODS_PERCENT", "30")
+	t.Setenv("THRESHOLD_FAILED_JOBS", "0")
+
+	config, err := LoadConfigFromConfigMap(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Thresholds.CrashingPodsPercent != 30 {
+		t.Errorf("crashingPodsPercent = %d, want 30", config.Thresholds.CrashingPodsPercent)
+	}
+	if config.Thresholds.ImagePullErrorsPercent != 30 {
+		t.Errorf("imagePullErrorsPercent = %d, want it to follow crashingPodsPercent (30)", config.Thresholds.ImagePullErrorsPercent)
+	}
+	if config.Thresholds.FailedJobs != 0 {
+		t.Errorf("failedJobs = %d, want 0", config.Thresholds.FailedJobs)
+	}
+	if config.Thresholds.PendingPodsPercent != defaultConfig().Thresholds.PendingPodsPercent {
+		t.Errorf("pendingPodsPercent = %d, want the default", config.Thresholds.PendingPodsPercent)
+	}
+}
+
+func TestEnvParser(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "empty poll interval", key: "POLL_INTERVAL", value: "", want: "30s"},
+		{name: "valid poll interval", key: "POLL_INTERVAL", value: "1m", want: "1m0s"},
+		{name: "poll interval without a unit", key: "POLL_INTERVAL", value: "30", wantErr: true},
+		{name: "malformed poll interval", key: "POLL_INTERVAL", value: "soon", wantErr: true},
+		{name: "empty threshold", key: "THRESHOLD_CPU_USAGE_PERCENT", value: "", want: "80"},
+		{name: "valid threshold", key: "THRESHOLD_CPU_USAGE_PERCENT", value: "90", want: "90"},
+		{name: "non-numeric threshold", key: "THRESHOLD_CPU_USAGE_PERCENT", value: "abc", wantErr: true},
+		{name: "fractional threshold", key: "THRESHOLD_FAILED_JOBS", value: "2.5", wantErr: true},
+		{name: "threshold with a unit", key: "THRESHOLD_RESTART_COUNT", value: "10%", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(test.key, test.value)
+			env := &envParser{}
+			var got string
+			if test.key == "POLL_INTERVAL" {
+				got = env.parseDurationEnvOrDefault(test.key, 30*time.Second).String()
+			} else {
+				thresholds := ThresholdsConfig{CpuUsagePercent: 80, FailedJobs: 3, RestartCount: 5}
+				env.applyThresholds(&thresholds)
+				got = map[string]string{
+					"THRESHOLD_CPU_USAGE_PERCENT": strconv.Itoa(thresholds.CpuUsagePercent),
+					"THRESHOLD_FAILED_JOBS":       strconv.Itoa(thresholds.FailedJobs),
+					"THRESHOLD_RESTART_COUNT":     strconv.Itoa(thresholds.RestartCount),
+				}[test.key]
+			}
+
+			err := env.err()
+			if test.wantErr {
+				if err == nil || !strings.Contains(err.Error(), test.key) || !strings.Contains(err.Error(), `"`+test.value+`"`) {
+					t.Errorf("error = %v, want one naming %s and %q", err, test.key, test.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("%s = %s, want %s", test.key, got, test.want)
+			}
+		})
+	}
+}
+
+func TestConfigMapLoaderReportsEveryMalformedVariable(t *testing.T) {
+	setAzureEnv(t)
+	malformed := map[string]string{"POLL_INTERVAL": "30"}
+	for _, key := range []string{
+		"THRESHOLD_CRASHING_PODS_PERCENT", "THRESHOLD_IMAGE_PULL_ERRORS_PERCENT", "THRESHOLD_PENDING_PODS_PERCENT",
+		"THRESHOLD_NOT_READY_NODES_PERCENT", "THRESHOLD_RESTART_COUNT", "THRESHOLD_RESTART_RATE",
+		"THRESHOLD_CPU_USAGE_PERCENT", "THRESHOLD_MEMORY_USAGE_PERCENT", "THRESHOLD_WORKLOADS_BLOCKED_BY_ADMISSION",
+		"THRESHOLD_CPU_REQUESTS_SATURATION_PERCENT", "THRESHOLD_MEMORY_REQUESTS_SATURATION_PERCENT",
+		"THRESHOLD_P95_SCHEDULING_LATENCY_SECONDS", "THRESHOLD_WORKLOADS_LOSING_ZONE_REDUNDANCY",
+		"THRESHOLD_POD_CHURN_PER_MINUTE", "THRESHOLD_SUSPECTED_NODE_REIMAGE_LOOPS", "THRESHOLD_FLAPPING_PODS",
+		"THRESHOLD_NEW_PODS_CRASHING_PERCENT", "THRESHOLD_NEW_PODS_PENDING_PERCENT",
+		"THRESHOLD_MAX_NODE_CPU_USAGE_PERCENT", "THRESHOLD_MAX_NODE_MEMORY_USAGE_PERCENT",
+		"THRESHOLD_CONFIG_REFERENCE_FAILURES", "THRESHOLD_MEMORY_PRESSURE_NODES_PERCENT",
+		"THRESHOLD_DISK_PRESSURE_NODES_PERCENT", "THRESHOLD_PID_PRESSURE_NODES_PERCENT",
+		"THRESHOLD_NETWORK_UNAVAILABLE_NODES_PERCENT", "THRESHOLD_DAEMONSET_NOT_READY_PERCENT",
+		"THRESHOLD_OOM_KILLED_PODS",
+	} {
+		malformed[key] = strings.ToLower(key)
+	}
+	for key, value := range malformed {
+		t.Setenv(key, value)
+	}
+	t.Setenv("THRESHOLD_FAILED_JOBS", "4")
+
+	config, err := LoadConfigFromConfigMap(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err == nil {
+		t.Fatalf("loaded %+v, want the malformed variables refused", config)
+	}
+	for key, value := range malformed {
+		if !strings.Contains(err.Error(), key) || !strings.Contains(err.Error(), `"`+value+`"`) {
+			t.Errorf("error = %v, want it to name %s=%q", err, key, value)
+		}
+	}
+	if strings.Contains(err.Error(), "THRESHOLD_FAILED_JOBS") {
+		t.Errorf("error = %v, names the valid THRESHOLD_FAILED_JOBS", err)
+	}
+}
+
+func TestValidateBindAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		wantErr bool
+	}{
+		{address: ""},
+		{address: ":8080"},
+		{address: "0.0.0.0:8080"},
+		{address: "127.0.0.1:0"},
+		{address: "[::]:8080"},
+		{address: "[::1]:8081"},
+		{address: "localhost:8082"},
+		{address: "8080", wantErr: true},
+		{address: "::1:8080", wantErr: true},
+		{address: "[::1]", wantErr: true},
+		{address: "metrics.local:8080", wantErr: true},
+		{address: "127.0.0.1:http", wantErr: true},
+		{address: "[::]:65536", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.address, func(t *testing.T) {
+			err := validateBindAddress("server.metricsAddress", test.address)
+			if (err != nil) != test.wantErr {
+				t.Errorf("validateBindAddress(%q) = %v, want error: %v", test.address, err, test.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "server.metricsAddress") {
+				t.Errorf("error = %v, want it to name the field", err)
+			}
+		})
+	}
+}
+
+func TestValidateCloud(t *testing.T) {
+	tests := []struct {
+		name    string
+		cloud   string
+		custom  CustomCloudConfig
+		wantErr bool
+	}{
+		{name: "empty", cloud: ""},
+		{name: "public", cloud: CloudAzurePublic},
+		{name: "government", cloud: CloudAzureGovernment},
+		{name: "china", cloud: CloudAzureChina},
+		{name: "unknown", cloud: "AzureGermany", wantErr: true},
+		{name: "wrong case", cloud: "azuregovernment", wantErr: true},
+		{name: "custom without endpoints", cloud: CloudCustom, wantErr: true},
+		{
+			name:    "custom with http endpoint",
+			cloud:   CloudCustom,
+			custom:  CustomCloudConfig{ActiveDirectoryAuthorityHost: "https://login.example/", ResourceManagerEndpoint: "http://management.example"},
+			wantErr: true,
+		},
+		{
+			name:   "custom",
+			cloud:  CloudCustom,
+			custom: CustomCloudConfig{ActiveDirectoryAuthorityHost: "https://login.example/", ResourceManagerEndpoint: "https://management.example"},
+		},
+	}
+
+	setAzureEnv(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := defaultConfig()
+			config.Azure.Cloud = test.cloud
+			config.Azure.CustomCloud = test.custom
+			err := config.Validate()
+			if (err != nil) != test.wantErr {
+				t.Errorf("Validate() = %v, want error: %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestDaemonSetSelection(t *testing.T) {
+	setAzureEnv(t)
+	for loaderName, load := range loaders {
+		t.Run(loaderName, func(t *testing.T) {
+			config, err := load(writeConfig(t, "collection:\n  daemonSets:\n    namespace: kube-system\n    selector: criticality in (high, critical)\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := DaemonSetSelection{Namespace: "kube-system", Selector: "criticality in (high, critical)"}
+			if config.Collection.DaemonSets != want {
+				t.Errorf("collection.daemonSets = %+v, want %+v", config.Collection.DaemonSets, want)
+			}
+
+			_, err = load(writeConfig(t, "collection:\n  daemonSets:\n    selector: \"criticality in high\"\n"))
+			if err == nil || !strings.Contains(err.Error(), "collection.daemonSets.selector") {
+				t.Errorf("error = %v, want the invalid selector rejected", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Change describes one configuration value that differs between two configurations
+type Change struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// String renders the change for logging
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %v -> %v", c.Path, c.Old, c.New)
+}
+
+// restartPaths are the configuration paths read once at startup. A reload records changes to them, but
+// they only take effect when the controller restarts.
+var restartPaths = []string{
+	"azure",
+	"azureClientStartup",
+	"server.metricsAddress",
+	"server.healthAddress",
+	"server.adminAddress",
+	"server.exemplars",
+	"server.pprof",
+	"server.metrics",
+	"server.health",
+	"server.admin",
+	"collection",
+	"decisionLog",
+	"notifications",
+	"diagnostics",
+	"verdicts",
+	"historyStorage",
+	"leaderElection",
+}
+
+// RequiresRestart reports whether the change only takes effect after a restart
+func (c Change) RequiresRestart() bool {
+	for _, path := range restartPaths {
+		if c.Path == path || strings.HasPrefix(c.Path, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff returns the changed values between two configurations, keyed by their YAML paths and
+// sorted by path. Fields tagged `redact:"true"` report REDACTED instead of their values.
+// Lists are compared as a whole.
+func Diff(old, new *Config) []Change {
+	var changes []Change
+	diffValues("", reflect.ValueOf(*old), reflect.ValueOf(*new), false, &changes)
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// durationType is special-cased so durations render as "30s" rather than nanoseconds
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// diffValues recursively compares two values of the same type
+func diffValues(path string, old, new reflect.Value, secret bool, changes *[]Change) {
+	switch {
+	case old.Kind() == reflect.Struct:
+		for i := 0; i < old.NumField(); i++ {
+			field := old.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			diffValues(joinPath(path, fieldName(field)), old.Field(i), new.Field(i),
+				secret || field.Tag.Get("redact") == "true", changes)
+		}
+
+	case old.Kind() == reflect.Map:
+		keys := make(map[string]reflect.Value)
+		for _, key := range append(old.MapKeys(), new.MapKeys()...) {
+			keys[fmt.Sprint(key.Interface())] = key
+		}
+		for name, key := range keys {
+			oldValue, newValue := old.MapIndex(key), new.MapIndex(key)
+			if !oldValue.IsValid() || !newValue.IsValid() {
+				appendChange(joinPath(path, name), oldValue, newValue, secret, changes)
+				continue
+			}
+			diffValues(joinPath(path, name), oldValue, newValue, secret, changes)
+		}
+
+	case old.Kind() == reflect.Ptr:
+		if old.IsNil() || new.IsNil() {
+			if old.IsNil() != new.IsNil() {
+				appendChange(path, old, new, secret, changes)
+			}
+			return
+		}
+		diffValues(path, old.Elem(), new.Elem(), secret, changes)
+
+	default:
+		if !reflect.DeepEqual(old.Interface(), new.Interface()) {
+			appendChange(path, old, new, secret, changes)
+		}
+	}
+}
+
+// appendChange records a leaf change, rendering values for logs and JSON
+func appendChange(path string, old, new reflect.Value, secret bool, changes *[]Change) {
+	change := Change{Path: path, Old: renderValue(old), New: renderValue(new)}
+	if secret {
+		change.Old, change.New = redactedValue, redactedValue
+	}
+	*changes = append(*changes, change)
+}
+
+// renderValue converts a reflected value into a log-friendly form
+func renderValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	return v.Interface()
+}
+
+// fieldName returns the YAML name of a struct field
+func fieldName(field reflect.StructField) string {
+	if tag := strings.Split(field.Tag.Get("yaml"), ",")[0]; tag != "" && tag != "-" {
+		return tag
+	}
+	return field.Name
+}
+
+// joinPath appends a path segment
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
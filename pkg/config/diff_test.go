@@ -0,0 +1,109 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(config *Config)
+		want   []Change
+	}{
+		{name: "no-op reload", change: func(config *Config) {}},
+		{
+			name:   "nested value",
+			change: func(config *Config) { config.Thresholds.PendingPodsPercent = 35 },
+			want:   []Change{{Path: "thresholds.pendingPodsPercent", Old: 15, New: 35}},
+		},
+		{
+			name:   "duration",
+			change: func(config *Config) { config.PollInterval = time.Minute },
+			want:   []Change{{Path: "pollInterval", Old: "30s", New: "1m0s"}},
+		},
+		{
+			name: "monitored operation list",
+			change: func(config *Config) {
+				config.MonitoredOperations = append(config.MonitoredOperations, MonitoredOperation{Name: "nodeImageUpdate"})
+			},
+			want: []Change{{Path: "monitoredOperations"}},
+		},
+		{
+			name:   "secret",
+			change: func(config *Config) { config.Azure.ClientSecret = "rotated" },
+			want:   []Change{{Path: "azure.clientSecret", Old: redactedValue, New: redactedValue}},
+		},
+		{
+			name: "several values sorted by path",
+			change: func(config *Config) {
+				config.Thresholds.FailedJobs = ThresholdDisabled
+				config.DryRun = true
+			},
+			want: []Change{
+				{Path: "dryRun", Old: false, New: true},
+				{Path: "thresholds.failedJobs", Old: 3, New: ThresholdDisabled},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			old := defaultConfig()
+			old.Azure.ClientSecret = "secret"
+			old.MonitoredOperations = []MonitoredOperation{{Name: "upgrade"}}
+			new := defaultConfig()
+			new.Azure.ClientSecret = "secret"
+			new.MonitoredOperations = []MonitoredOperation{{Name: "upgrade"}}
+			test.change(new)
+
+			changes := Diff(old, new)
+			if len(changes) != len(test.want) {
+				t.Fatalf("Diff = %v, want %v", changes, test.want)
+			}
+			for i, change := range changes {
+				want := test.want[i]
+				if change.Path != want.Path {
+					t.Errorf("change %d path = %s, want %s", i, change.Path, want.Path)
+				}
+				// Lists are compared as a whole and carry both lists
+				if want.Old == nil && want.New == nil {
+					if reflect.DeepEqual(change.Old, change.New) {
+						t.Errorf("%s: old and new are both %v", change.Path, change.Old)
+					}
+					continue
+				}
+				if !reflect.DeepEqual(change.Old, want.Old) || !reflect.DeepEqual(change.New, want.New) {
+					t.Errorf("%s = %v -> %v, want %v -> %v", change.Path, change.Old, change.New, want.Old, want.New)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffMapKeys(t *testing.T) {
+	old := defaultConfig()
+	new := defaultConfig()
+	new.MetricWindows = map[string]MetricWindow{"pod_churn_per_minute": {}}
+
+	changes := Diff(old, new)
+	if len(changes) != 1 || changes[0].Path != "metricWindows.pod_churn_per_minute" || changes[0].Old != nil {
+		t.Errorf("Diff = %v, want the added metricWindows key", changes)
+	}
+}
+
+func TestChangeRequiresRestart(t *testing.T) {
+	for path, want := range map[string]bool{
+		"azure.clusterName":             true,
+		"collection.podPageSize":        true,
+		"server.metricsAddress":         true,
+		"thresholds.pendingPodsPercent": false,
+		"pollInterval":                  false,
+		"azureExtra":                    false,
+	} {
+		if got := (Change{Path: path}).RequiresRestart(); got != want {
+			t.Errorf("RequiresRestart(%s) = %v, want %v", path, got, want)
+		}
+	}
+}
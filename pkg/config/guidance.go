@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// maxDescriptionLength bounds a rendered description so a template cannot inflate Events and notifications
+const maxDescriptionLength = 1024
+
+// ThresholdGuidance tells whoever responds to a violation what it means and what to do. It is attached
+// to the metric's violations in results, Events, verdicts and abort explanations.
+//
+//	thresholdGuidance:
+//	  not_ready_nodes_percent:
+//	    description: "{{.Value}} of nodes not ready (limit {{.Threshold}}). Check the node pool's VMSS instances."
+//	    runbookURL: https://wiki.example.com/runbooks/aks-not-ready-nodes
+type ThresholdGuidance struct {
+	// Description is a text/template rendered with the fields of ViolationDetails
+	Description string `yaml:"description"`
+
+	// RunbookURL is an absolute http or https URL
+	RunbookURL string `yaml:"runbookURL"`
+}
+
+// ViolationDetails are the values a description template can use, already formatted for display
+type ViolationDetails struct {
+	// Value is the metric value, e.g. "12% (48/400)"
+	Value string
+
+	// Threshold is the threshold the value exceeded, e.g. "10%"
+	Threshold string
+
+	// Offenders lists the resources responsible, separated by "; ", or is empty
+	Offenders string
+}
+
+// Render renders the description for a violation. Only the fields of ViolationDetails are available, and
+// the result is truncated to 1024 characters.
+func (g ThresholdGuidance) Render(details ViolationDetails) (string, error) {
+	if !strings.Contains(g.Description, "{{") {
+		return truncate(g.Description), nil
+	}
+
+	tmpl, err := template.New("description").Option("missingkey=error").Parse(g.Description)
+	if err != nil {
+		return "", err
+	}
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, details); err != nil {
+		return "", err
+	}
+	return truncate(rendered.String()), nil
+}
+
+// validate rejects descriptions that would not render and runbook URLs that are not absolute web URLs.
+// Rendering with sample values at load time catches unknown fields, which would otherwise only fail
+// when the violation is reported.
+func (g ThresholdGuidance) validate() error {
+	if _, err := g.Render(ViolationDetails{Value: "1", Threshold: "0", Offenders: "pod"}); err != nil {
+		return fmt.Errorf("description: %w", err)
+	}
+	if g.RunbookURL != "" {
+		runbook, err := url.Parse(g.RunbookURL)
+		if err != nil {
+			return fmt.Errorf("runbookURL: %w", err)
+		}
+		if (runbook.Scheme != "http" && runbook.Scheme != "https") || runbook.Host == "" {
+			return fmt.Errorf("runbookURL must be an absolute http or https URL, got: %q", g.RunbookURL)
+		}
+	}
+	return nil
+}
+
+// truncate shortens text to maxDescriptionLength characters
+func truncate(text string) string {
+	if runes := []rune(text); len(runes) > maxDescriptionLength {
+		return string(runes[:maxDescriptionLength-3]) + "..."
+	}
+	return text
+}
@@ -0,0 +1,331 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Per-operation actions taken when thresholds are violated
+const (
+	// OperationActionAbort aborts the operation (default)
+	OperationActionAbort = "abort"
+	// OperationActionAlert reports violations without aborting
+	OperationActionAlert = "alert"
+)
+
+// Upgrade classes, usable as monitoredOperations names. A class without its own entry uses the
+// settings of "upgrade".
+const (
+	OperationControlPlaneUpgrade = "controlPlaneUpgrade"
+	OperationNodePoolUpgrade     = "nodePoolUpgrade"
+)
+
+// supportedOperations are the names accepted in monitoredOperations: the operations whose provisioning
+// state is reported as in progress, and the upgrade classes
+var supportedOperations = []string{"upgrade", "update", "scale", "create", "delete", OperationControlPlaneUpgrade, OperationNodePoolUpgrade}
+
+// MonitoredOperation is an entry of monitoredOperations. In YAML it is either a plain operation name
+// ("upgrade") or an object carrying per-operation overrides:
+//
+//	monitoredOperations:
+//	  - update
+//	  - name: upgrade
+//	    gracePeriod: 5m
+//	    abortCooldown: 10m
+//	    consecutiveViolations: 3
+//	    action: abort
+//	    thresholds:
+//	      notReadyNodesPercent: 40
+type MonitoredOperation struct {
+	Name string `yaml:"name"`
+
+	// GracePeriod overrides the global operationGracePeriod for this operation, including with 0
+	GracePeriod time.Duration `yaml:"gracePeriod,omitempty"`
+
+	// AbortCooldown overrides the global abortCooldown, including with 0 for no cooldown
+	AbortCooldown time.Duration `yaml:"abortCooldown,omitempty"`
+
+	// ConsecutiveViolations overrides the global consecutiveViolations; 0 uses it
+	ConsecutiveViolations int `yaml:"consecutiveViolations,omitempty"`
+
+	// Action is "abort" or "alert"; empty means abort
+	Action string `yaml:"action,omitempty"`
+
+	// Thresholds overrides the global thresholds it sets, including to 0; -1 disables a metric
+	Thresholds ThresholdsConfig `yaml:"thresholds,omitempty"`
+
+	// set holds the duration overrides the YAML sets, including those set to 0; nil when the entry was
+	// not decoded from the object form
+	set map[string]bool
+}
+
+// durationOverrides are the keys of the overrides that may be set to 0
+var durationOverrides = []string{"gracePeriod", "abortCooldown"}
+
+// UnmarshalYAML accepts both the plain string and the object form
+func (o *MonitoredOperation) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		*o = MonitoredOperation{Name: name}
+		return nil
+	}
+
+	// plain has no UnmarshalYAML method, avoiding recursion
+	type plain MonitoredOperation
+	if err := unmarshal((*plain)(o)); err != nil {
+		return err
+	}
+
+	var keys map[string]interface{}
+	if err := unmarshal(&keys); err != nil {
+		return err
+	}
+	o.set = nil
+	for _, key := range durationOverrides {
+		if _, ok := keys[key]; ok {
+			if o.set == nil {
+				o.set = make(map[string]bool)
+			}
+			o.set[key] = true
+		}
+	}
+	return nil
+}
+
+// MarshalYAML writes entries without overrides as plain names so existing configs round-trip unchanged,
+// and keeps duration overrides set to 0
+func (o MonitoredOperation) MarshalYAML() (interface{}, error) {
+	if reflect.DeepEqual(o, MonitoredOperation{Name: o.Name}) {
+		return o.Name, nil
+	}
+
+	entry := yaml.MapSlice{{Key: "name", Value: o.Name}}
+	if o.overrides("gracePeriod", o.GracePeriod != 0) {
+		entry = append(entry, yaml.MapItem{Key: "gracePeriod", Value: o.GracePeriod})
+	}
+	if o.overrides("abortCooldown", o.AbortCooldown != 0) {
+		entry = append(entry, yaml.MapItem{Key: "abortCooldown", Value: o.AbortCooldown})
+	}
+	if o.ConsecutiveViolations != 0 {
+		entry = append(entry, yaml.MapItem{Key: "consecutiveViolations", Value: o.ConsecutiveViolations})
+	}
+	if o.Action != "" {
+		entry = append(entry, yaml.MapItem{Key: "action", Value: o.Action})
+	}
+	if !o.Thresholds.IsZero() {
+		entry = append(entry, yaml.MapItem{Key: "thresholds", Value: o.Thresholds})
+	}
+	return entry, nil
+}
+
+// overrides reports whether the entry overrides the setting with the given key: when the YAML sets it,
+// or, for entries not decoded from the object form, when its value is not 0
+func (o MonitoredOperation) overrides(key string, nonZero bool) bool {
+	if o.set != nil {
+		return o.set[key]
+	}
+	return nonZero
+}
+
+// defaultMonitoredOperations returns the operations monitored when none are configured
+func defaultMonitoredOperations() []MonitoredOperation {
+	return []MonitoredOperation{{Name: "upgrade"}, {Name: "update"}, {Name: "scale"}}
+}
+
+// validateMonitoredOperations checks names, actions, durations and threshold overrides
+func validateMonitoredOperations(operations []MonitoredOperation) error {
+	seen := make(map[string]bool, len(operations))
+	for i, operation := range operations {
+		if operation.Name == "" {
+			return fmt.Errorf("monitoredOperations[%d].name is required", i)
+		}
+		name := strings.ToLower(operation.Name)
+		if !isSupportedOperation(name) {
+			return fmt.Errorf("monitoredOperations: unknown operation %q, supported operations are: %s", operation.Name, strings.Join(supportedOperations, ", "))
+		}
+		if seen[name] {
+			return fmt.Errorf("monitoredOperations: %q is listed more than once", operation.Name)
+		}
+		seen[name] = true
+
+		if operation.Action != "" && operation.Action != OperationActionAbort && operation.Action != OperationActionAlert {
+			return fmt.Errorf("monitoredOperations[%s].action must be %q or %q, got: %q", operation.Name, OperationActionAbort, OperationActionAlert, operation.Action)
+		}
+		if operation.GracePeriod < 0 {
+			return fmt.Errorf("monitoredOperations[%s].gracePeriod must not be negative", operation.Name)
+		}
+		if operation.AbortCooldown < 0 {
+			return fmt.Errorf("monitoredOperations[%s].abortCooldown must not be negative", operation.Name)
+		}
+		if operation.ConsecutiveViolations < 0 {
+			return fmt.Errorf("monitoredOperations[%s].consecutiveViolations must not be negative", operation.Name)
+		}
+		if err := operation.Thresholds.Validate(); err != nil {
+			return fmt.Errorf("monitoredOperations[%s].thresholds: %w", operation.Name, err)
+		}
+	}
+	return nil
+}
+
+// AbortsEnabled reports whether any monitored operation may be aborted
+func (c *Config) AbortsEnabled() bool {
+	for _, operation := range c.MonitoredOperations {
+		if operation.Action == "" || operation.Action == OperationActionAbort {
+			return true
+		}
+	}
+	return false
+}
+
+// validateAbortCredential checks that a read-only identity is paired with an abort identity when aborts
+// are enabled, and that a partially configured abort identity names its client
+func (c *Config) validateAbortCredential() error {
+	abortCredential := c.Azure.AbortCredential
+	if !abortCredential.Configured() && (abortCredential.TenantID != "" || abortCredential.ClientSecret != "") {
+		return fmt.Errorf("azure.abortCredential.clientId is required when tenantId or clientSecret is set")
+	}
+	if c.Azure.ReadOnly && !abortCredential.Configured() && c.AbortsEnabled() {
+		return fmt.Errorf("azure.abortCredential is required when azure.readOnly is set and a monitored operation's action is %q; set it, or set the action to %q",
+			OperationActionAbort, OperationActionAlert)
+	}
+	return nil
+}
+
+// isSupportedOperation reports whether a lowercased name is one of supportedOperations
+func isSupportedOperation(name string) bool {
+	for _, supported := range supportedOperations {
+		if strings.ToLower(supported) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// OperationSettings are the effective settings for one operation after applying its overrides
+type OperationSettings struct {
+	Name      string
+	Monitored bool
+
+	// GracePeriod is how long after the operation is first observed its metrics are collected but
+	// thresholds are not evaluated: its gracePeriod, or the global operationGracePeriod
+	GracePeriod time.Duration
+
+	AbortCooldown         time.Duration
+	ConsecutiveViolations int
+	Action                string
+	Thresholds            ThresholdsConfig
+}
+
+// OperationSettings resolves the settings for an operation, given either its monitoredOperations name
+// ("upgrade"), the provisioning state reported while it runs ("Upgrading"), or an upgrade class
+func (c *Config) OperationSettings(operation string) OperationSettings {
+	name := OperationName(operation)
+	if name == strings.ToLower(OperationControlPlaneUpgrade) || name == strings.ToLower(OperationNodePoolUpgrade) {
+		if !c.monitorsOperation(name) {
+			settings := c.OperationSettings("upgrade")
+			settings.Name = name
+			return settings
+		}
+	}
+
+	settings := OperationSettings{
+		Name:                  name,
+		GracePeriod:           c.OperationGracePeriod,
+		AbortCooldown:         c.AbortCooldown,
+		ConsecutiveViolations: c.ConsecutiveViolations,
+		Action:                OperationActionAbort,
+		Thresholds:            c.Thresholds,
+	}
+
+	for _, monitored := range c.MonitoredOperations {
+		if strings.ToLower(monitored.Name) != name {
+			continue
+		}
+		settings.Monitored = true
+		if monitored.overrides("gracePeriod", monitored.GracePeriod > 0) {
+			settings.GracePeriod = monitored.GracePeriod
+		}
+		if monitored.overrides("abortCooldown", monitored.AbortCooldown > 0) {
+			settings.AbortCooldown = monitored.AbortCooldown
+		}
+		if monitored.ConsecutiveViolations > 0 {
+			settings.ConsecutiveViolations = monitored.ConsecutiveViolations
+		}
+		if monitored.Action != "" {
+			settings.Action = monitored.Action
+		}
+		settings.Thresholds = c.Thresholds.Overlay(monitored.Thresholds)
+		break
+	}
+
+	return settings
+}
+
+// monitorsOperation reports whether monitoredOperations has an entry for the operation name
+func (c *Config) monitorsOperation(name string) bool {
+	for _, monitored := range c.MonitoredOperations {
+		if strings.ToLower(monitored.Name) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// OperationName maps a provisioning state such as "Upgrading" or "Scaling" to its operation name
+// ("upgrade", "scale"). Names that are not in the -ing form are lowercased unchanged.
+func OperationName(operation string) string {
+	name := strings.ToLower(operation)
+	if strings.HasSuffix(name, "ing") && len(name) > len("ing") {
+		name = strings.TrimSuffix(name, "ing") + "e"
+	}
+	return name
+}
+
+// PrecheckThresholds returns the thresholds the pre-check evaluates: the regular thresholds with those set
+// in precheck.thresholds applied
+func (c *Config) PrecheckThresholds() ThresholdsConfig {
+	return c.Thresholds.Overlay(c.Precheck.Thresholds)
+}
+
+// Overlay returns t with every threshold set in overrides applied: those present in the YAML overrides
+// were decoded from, including 0, or the non-zero ones of overrides built in code. An override of
+// ThresholdDisabled turns a metric off, and thresholds left out inherit. Warn thresholds set in overrides
+// replace those of t.
+func (t ThresholdsConfig) Overlay(overrides ThresholdsConfig) ThresholdsConfig {
+	result := t
+	result.Warn = t.overlayWarnTier(overrides)
+	resultValue := reflect.ValueOf(&result).Elem()
+	overrideValue := reflect.ValueOf(overrides)
+	for i := 0; i < overrideValue.NumField(); i++ {
+		field := overrideValue.Field(i)
+		if field.Kind() == reflect.Int && overrides.isSet(thresholdName(overrideValue.Type().Field(i)), field.Int()) {
+			resultValue.Field(i).SetInt(field.Int())
+		}
+	}
+	return result
+}
+
+// validateCriticalThresholds checks that every critical threshold is at least the regular threshold of its
+// metric, so a critical violation is always also a regular one.
+func validateCriticalThresholds(critical, regular ThresholdsConfig) error {
+	criticalValue, regularValue := reflect.ValueOf(critical), reflect.ValueOf(regular)
+	for i := 0; i < criticalValue.NumField(); i++ {
+		field := criticalValue.Field(i)
+		if field.Kind() != reflect.Int || field.Int() == 0 {
+			continue
+		}
+		name := strings.Split(criticalValue.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		if field.Int() < 0 {
+			return fmt.Errorf("criticalThresholds.%s must not be negative, got: %d", name, field.Int())
+		}
+		if field.Int() < regularValue.Field(i).Int() {
+			return fmt.Errorf("criticalThresholds.%s must be at least thresholds.%s (%d), got: %d",
+				name, name, regularValue.Field(i).Int(), field.Int())
+		}
+	}
+	return nil
+}
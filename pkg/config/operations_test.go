@@ -0,0 +1,104 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestMonitoredOperationsPlainNamesRoundTrip(t *testing.T) {
+	const document = "- upgrade\n- update\n- scale\n"
+
+	var operations []MonitoredOperation
+	if err := yaml.Unmarshal([]byte(document), &operations); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(operations, defaultMonitoredOperations()) {
+		t.Errorf("parsed %+v, want %+v", operations, defaultMonitoredOperations())
+	}
+
+	out, err := yaml.Marshal(operations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != document {
+		t.Errorf("marshalled:\n%s\nwant:\n%s", out, document)
+	}
+}
+
+func TestMonitoredOperationsObjectFormRoundTrip(t *testing.T) {
+	const document = `- update
+- name: upgrade
+  gracePeriod: 0s
+  abortCooldown: 10m0s
+  consecutiveViolations: 3
+  action: alert
+  thresholds:
+    failedJobs: 0
+`
+
+	var operations []MonitoredOperation
+	if err := yaml.Unmarshal([]byte(document), &operations); err != nil {
+		t.Fatal(err)
+	}
+	out, err := yaml.Marshal(operations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != document {
+		t.Errorf("marshalled:\n%s\nwant:\n%s", out, document)
+	}
+
+	var again []MonitoredOperation
+	if err := yaml.Unmarshal(out, &again); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, operations) {
+		t.Errorf("round trip changed the entries:\n%+v\nwant:\n%+v", again, operations)
+	}
+}
+
+func TestOperationSettingsDurationOverrides(t *testing.T) {
+	tests := []struct {
+		name         string
+		overrides    string
+		wantGrace    time.Duration
+		wantCooldown time.Duration
+	}{
+		{name: "plain name inherits", wantGrace: 2 * time.Minute, wantCooldown: 15 * time.Minute},
+		{name: "absent keys inherit", overrides: "action: abort", wantGrace: 2 * time.Minute, wantCooldown: 15 * time.Minute},
+		{name: "explicit zero", overrides: "gracePeriod: 0s\n    abortCooldown: 0s", wantGrace: 0, wantCooldown: 0},
+		{name: "explicit non-zero", overrides: "gracePeriod: 5m\n    abortCooldown: 1m", wantGrace: 5 * time.Minute, wantCooldown: time.Minute},
+	}
+
+	setAzureEnv(t)
+	for loaderName, load := range loaders {
+		for _, test := range tests {
+			t.Run(loaderName+"/"+test.name, func(t *testing.T) {
+				entry := "- upgrade"
+				if test.overrides != "" {
+					entry = "- name: upgrade\n    " + test.overrides
+				}
+				config, err := load(writeConfig(t, `
+operationGracePeriod: 2m
+abortCooldown: 15m
+monitoredOperations:
+  `+entry+`
+`))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				settings := config.OperationSettings("Upgrading")
+				if settings.GracePeriod != test.wantGrace {
+					t.Errorf("gracePeriod = %s, want %s", settings.GracePeriod, test.wantGrace)
+				}
+				if settings.AbortCooldown != test.wantCooldown {
+					t.Errorf("abortCooldown = %s, want %s", settings.AbortCooldown, test.wantCooldown)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestFileThresholdsOverrideDefaults(t *testing.T) {
+	defaults := defaultConfig().Thresholds
+	tests := []struct {
+		name         string
+		file         string
+		wantFailed   int
+		wantRestarts int
+	}{
+		{
+			name:         "absent key keeps the default",
+			file:         "thresholds:\n  pendingPodsPercent: 20\n",
+			wantFailed:   defaults.FailedJobs,
+			wantRestarts: defaults.RestartCount,
+		},
+		{
+			name:         "explicit zero replaces the default",
+			file:         "thresholds:\n  failedJobs: 0\n  restartCount: 0\n",
+			wantFailed:   0,
+			wantRestarts: 0,
+		},
+		{
+			name:         "explicit non-zero replaces the default",
+			file:         "thresholds:\n  failedJobs: 7\n  restartCount: 50\n",
+			wantFailed:   7,
+			wantRestarts: 50,
+		},
+		{
+			name:         "disabled",
+			file:         "thresholds:\n  failedJobs: -1\n",
+			wantFailed:   ThresholdDisabled,
+			wantRestarts: defaults.RestartCount,
+		},
+	}
+
+	setAzureEnv(t)
+	for loaderName, load := range loaders {
+		for _, test := range tests {
+			t.Run(loaderName+"/"+test.name, func(t *testing.T) {
+				config, err := load(writeConfig(t, test.file))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if config.Thresholds.FailedJobs != test.wantFailed {
+					t.Errorf("failedJobs = %d, want %d", config.Thresholds.FailedJobs, test.wantFailed)
+				}
+				if config.Thresholds.RestartCount != test.wantRestarts {
+					t.Errorf("restartCount = %d, want %d", config.Thresholds.RestartCount, test.wantRestarts)
+				}
+			})
+		}
+	}
+}
+
+func TestOperationAndPrecheckThresholdsOverrideWithZero(t *testing.T) {
+	tests := []struct {
+		name       string
+		overrides  string
+		wantFailed int
+		wantNodes  int
+	}{
+		{name: "absent key inherits", overrides: "notReadyNodesPercent: 40", wantFailed: 3, wantNodes: 40},
+		{name: "explicit zero", overrides: "failedJobs: 0", wantFailed: 0, wantNodes: 25},
+		{name: "explicit non-zero", overrides: "failedJobs: 9", wantFailed: 9, wantNodes: 25},
+		{name: "disabled", overrides: "failedJobs: -1", wantFailed: ThresholdDisabled, wantNodes: 25},
+	}
+
+	setAzureEnv(t)
+	for loaderName, load := range loaders {
+		for _, test := range tests {
+			t.Run(loaderName+"/"+test.name, func(t *testing.T) {
+				config, err := load(writeConfig(t, `
+thresholds:
+  failedJobs: 3
+  notReadyNodesPercent: 25
+monitoredOperations:
+  - name: upgrade
+    thresholds:
+      `+test.overrides+`
+  - scale
+precheck:
+  thresholds:
+    `+test.overrides+`
+`))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				for source, thresholds := range map[string]ThresholdsConfig{
+					"upgrade":  config.OperationSettings("Upgrading").Thresholds,
+					"precheck": config.PrecheckThresholds(),
+				} {
+					if thresholds.FailedJobs != test.wantFailed {
+						t.Errorf("%s failedJobs = %d, want %d", source, thresholds.FailedJobs, test.wantFailed)
+					}
+					if thresholds.NotReadyNodesPercent != test.wantNodes {
+						t.Errorf("%s notReadyNodesPercent = %d, want %d", source, thresholds.NotReadyNodesPercent, test.wantNodes)
+					}
+				}
+
+				scale := config.OperationSettings("Scaling").Thresholds
+				if scale.FailedJobs != 3 || scale.NotReadyNodesPercent != 25 {
+					t.Errorf("scale thresholds = %d/%d, want the global 3/25", scale.FailedJobs, scale.NotReadyNodesPercent)
+				}
+			})
+		}
+	}
+}
+
+func TestOverlayOfCodeBuiltOverridesAppliesNonZero(t *testing.T) {
+	base := ThresholdsConfig{FailedJobs: 3, NotReadyNodesPercent: 25}
+	result := base.Overlay(ThresholdsConfig{NotReadyNodesPercent: 40})
+	if result.FailedJobs != 3 || result.NotReadyNodesPercent != 40 {
+		t.Errorf("Overlay = %d/%d, want 3/40", result.FailedJobs, result.NotReadyNodesPercent)
+	}
+}
+
+func TestOperationErrorsTreatAsViolation(t *testing.T) {
+	setAzureEnv(t)
+	for loaderName, load := range loaders {
+		t.Run(loaderName, func(t *testing.T) {
+			config, err := load(writeConfig(t, "pollInterval: 30s\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.OperationErrors.TreatAsViolation {
+				t.Error("operationErrors.treatAsViolation is enabled by default")
+			}
+
+			config, err = load(writeConfig(t, "operationErrors:\n  treatAsViolation: true\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !config.OperationErrors.TreatAsViolation {
+				t.Error("operationErrors.treatAsViolation was not applied")
+			}
+
+			if _, err := load(writeConfig(t, "thresholds:\n  operationErrorDetected: 0\n")); err == nil {
+				t.Error("thresholds.operationErrorDetected was accepted")
+			}
+		})
+	}
+}
+
+func TestImagePullThresholdFollowsCrashingPods(t *testing.T) {
+	defaults := defaultConfig().Thresholds
+	tests := []struct {
+		name          string
+		file          string
+		wantCrashing  int
+		wantImagePull int
+	}{
+		{name: "neither set", file: "thresholds:\n  pendingPodsPercent: 20\n",
+			wantCrashing: defaults.CrashingPodsPercent, wantImagePull: defaults.ImagePullErrorsPercent},
+		{name: "only crashing pods set", file: "thresholds:\n  crashingPodsPercent: 30\n", wantCrashing: 30, wantImagePull: 30},
+		{name: "only crashing pods disabled", file: "thresholds:\n  crashingPodsPercent: -1\n", wantCrashing: ThresholdDisabled, wantImagePull: ThresholdDisabled},
+		{name: "both set", file: "thresholds:\n  crashingPodsPercent: 30\n  imagePullErrorsPercent: 5\n", wantCrashing: 30, wantImagePull: 5},
+		{name: "image pull errors set to zero", file: "thresholds:\n  crashingPodsPercent: 30\n  imagePullErrorsPercent: 0\n", wantCrashing: 30, wantImagePull: 0},
+		{name: "only image pull errors set", file: "thresholds:\n  imagePullErrorsPercent: 40\n", wantCrashing: defaults.CrashingPodsPercent, wantImagePull: 40},
+	}
+
+	setAzureEnv(t)
+	for loaderName, load := range loaders {
+		for _, test := range tests {
+			t.Run(loaderName+"/"+test.name, func(t *testing.T) {
+				config, err := load(writeConfig(t, test.file))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if config.Thresholds.CrashingPodsPercent != test.wantCrashing {
+					t.Errorf("crashingPodsPercent = %d, want %d", config.Thresholds.CrashingPodsPercent, test.wantCrashing)
+				}
+				if config.Thresholds.ImagePullErrorsPercent != test.wantImagePull {
+					t.Errorf("imagePullErrorsPercent = %d, want %d", config.Thresholds.ImagePullErrorsPercent, test.wantImagePull)
+				}
+			})
+		}
+	}
+}
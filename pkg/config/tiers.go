@@ -0,0 +1,195 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// thresholdTiers is one threshold as written in the configuration: a single value, the abort threshold,
+// or a {warn, abort} pair
+type thresholdTiers struct {
+	Warn  *int `yaml:"warn"`
+	Abort *int `yaml:"abort"`
+
+	// parseErr lets the threshold that could not be decoded be rejected by name
+	parseErr error
+}
+
+// UnmarshalYAML accepts both the single value and the pair form, and records a value in neither form
+// instead of failing
+func (t *thresholdTiers) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var abort int
+	if err := unmarshal(&abort); err == nil {
+		*t = thresholdTiers{Abort: &abort}
+		return nil
+	}
+
+	// plain has no UnmarshalYAML method, avoiding recursion
+	type plain thresholdTiers
+	var pair plain
+	if err := unmarshal(&pair); err != nil {
+		*t = thresholdTiers{parseErr: err}
+		return nil
+	}
+	*t = thresholdTiers(pair)
+	return nil
+}
+
+// UnmarshalYAML decodes every threshold in either form. Single values set the abort threshold; pairs also
+// set the warn threshold, and a pair without abort keeps the current abort threshold. Thresholds left out
+// keep their current values, so a file decodes onto the defaults. The abort thresholds the YAML sets are
+// recorded for Overlay.
+func (t *ThresholdsConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var tiers map[string]thresholdTiers
+	if err := unmarshal(&tiers); err != nil {
+		return err
+	}
+	if _, ok := tiers["operationErrorDetected"]; ok {
+		return fmt.Errorf("operationErrorDetected is no longer a threshold: set operationErrors.treatAsViolation to true to treat ARM provisioning errors as violations")
+	}
+
+	set := make(map[string]bool, len(t.set)+len(tiers))
+	for name := range t.set {
+		set[name] = true
+	}
+	value := reflect.ValueOf(t).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		if value.Field(i).Kind() != reflect.Int {
+			continue
+		}
+		name := thresholdName(value.Type().Field(i))
+		tier, ok := tiers[name]
+		if !ok {
+			continue
+		}
+		if tier.parseErr != nil {
+			return fmt.Errorf("%s must be a number or a {warn, abort} pair: %w", name, tier.parseErr)
+		}
+		if tier.Abort != nil {
+			value.Field(i).SetInt(int64(*tier.Abort))
+			set[name] = true
+		}
+		if tier.Warn != nil {
+			warn := t.WarnTier()
+			reflect.ValueOf(&warn).Elem().Field(i).SetInt(int64(*tier.Warn))
+			t.Warn = &warn
+		}
+	}
+	t.set = set
+	return nil
+}
+
+// MarshalYAML writes the thresholds Overlay would apply: those set to a value other than 0 and those
+// recorded as set. Metrics with a warn threshold are written as {warn, abort} pairs, so the output
+// decodes to the same thresholds.
+func (t ThresholdsConfig) MarshalYAML() (interface{}, error) {
+	out := make(map[string]interface{})
+	value := reflect.ValueOf(t)
+	var warnValue reflect.Value
+	if t.Warn != nil {
+		warnValue = reflect.ValueOf(*t.Warn)
+	}
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		if field.Kind() != reflect.Int {
+			continue
+		}
+		name := thresholdName(value.Type().Field(i))
+		abort := int(field.Int())
+		if warnValue.IsValid() && warnValue.Field(i).Int() != ThresholdDisabled {
+			warn := int(warnValue.Field(i).Int())
+			out[name] = thresholdTiers{Warn: &warn, Abort: &abort}
+			continue
+		}
+		if abort != 0 || t.set[name] {
+			out[name] = abort
+		}
+	}
+	return out, nil
+}
+
+// isSet reports whether Overlay applies the named threshold with the given value: when it was recorded as
+// set, or, for thresholds not decoded from YAML, when it is not 0
+func (t ThresholdsConfig) isSet(name string, value int64) bool {
+	if t.set != nil {
+		return t.set[name]
+	}
+	return value != 0
+}
+
+// IsZero reports whether no threshold is set, neither to a value other than 0 nor explicitly
+func (t ThresholdsConfig) IsZero() bool {
+	if t.Warn != nil || len(t.set) > 0 {
+		return false
+	}
+	value := reflect.ValueOf(t)
+	for i := 0; i < value.NumField(); i++ {
+		if field := value.Field(i); field.Kind() == reflect.Int && field.Int() != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// WarnTier returns the warn thresholds, with ThresholdDisabled for every metric without one
+func (t ThresholdsConfig) WarnTier() ThresholdsConfig {
+	if t.Warn != nil {
+		return *t.Warn
+	}
+	var warn ThresholdsConfig
+	value := reflect.ValueOf(&warn).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		if value.Field(i).Kind() == reflect.Int {
+			value.Field(i).SetInt(ThresholdDisabled)
+		}
+	}
+	return warn
+}
+
+// overlayWarnTier applies the warn thresholds set in overrides onto those of t
+func (t ThresholdsConfig) overlayWarnTier(overrides ThresholdsConfig) *ThresholdsConfig {
+	if overrides.Warn == nil {
+		return t.Warn
+	}
+	warn := t.WarnTier()
+	warnValue := reflect.ValueOf(&warn).Elem()
+	overrideValue := reflect.ValueOf(*overrides.Warn)
+	for i := 0; i < overrideValue.NumField(); i++ {
+		if field := overrideValue.Field(i); field.Kind() == reflect.Int && field.Int() != ThresholdDisabled {
+			warnValue.Field(i).SetInt(field.Int())
+		}
+	}
+	return &warn
+}
+
+// validateWarnTier checks that every warn threshold is in range and below the abort threshold of its
+// metric, unless that one is disabled
+func (t ThresholdsConfig) validateWarnTier() error {
+	if t.Warn == nil {
+		return nil
+	}
+	warnValue, abortValue := reflect.ValueOf(*t.Warn), reflect.ValueOf(t)
+	for i := 0; i < warnValue.NumField(); i++ {
+		warn := warnValue.Field(i)
+		if warn.Kind() != reflect.Int || warn.Int() == ThresholdDisabled {
+			continue
+		}
+		name := thresholdName(warnValue.Type().Field(i))
+		if warn.Int() < 0 {
+			return fmt.Errorf("%s.warn must not be negative, got: %d", name, warn.Int())
+		}
+		if strings.HasSuffix(name, "Percent") && warn.Int() > 100 {
+			return fmt.Errorf("%s.warn must be between 0 and 100, got: %d", name, warn.Int())
+		}
+		if abort := abortValue.Field(i).Int(); abort != ThresholdDisabled && warn.Int() >= abort {
+			return fmt.Errorf("%s.warn must be below the abort threshold (%d), got: %d", name, abort, warn.Int())
+		}
+	}
+	return nil
+}
+
+// thresholdName returns the YAML name of a threshold field
+func thresholdName(field reflect.StructField) string {
+	return strings.Split(field.Tag.Get("yaml"), ",")[0]
+}
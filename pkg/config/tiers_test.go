@@ -0,0 +1,137 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestThresholdTierForms(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		wantAbort int
+		wantWarn  int
+	}{
+		{name: "legacy single value is abort only", file: "crashingPodsPercent: 15", wantAbort: 15, wantWarn: ThresholdDisabled},
+		{name: "legacy disabled", file: "crashingPodsPercent: -1", wantAbort: ThresholdDisabled, wantWarn: ThresholdDisabled},
+		{name: "warn and abort", file: "crashingPodsPercent: {warn: 5, abort: 15}", wantAbort: 15, wantWarn: 5},
+		{name: "block form", file: "crashingPodsPercent:\n    warn: 5\n    abort: 15", wantAbort: 15, wantWarn: 5},
+		{name: "warn only keeps the default abort", file: "crashingPodsPercent: {warn: 5}", wantAbort: 10, wantWarn: 5},
+		{name: "abort only", file: "crashingPodsPercent: {abort: 15}", wantAbort: 15, wantWarn: ThresholdDisabled},
+		{name: "warn of zero", file: "crashingPodsPercent: {warn: 0, abort: 15}", wantAbort: 15, wantWarn: 0},
+		{name: "warn with abort disabled", file: "crashingPodsPercent: {warn: 5, abort: -1}", wantAbort: ThresholdDisabled, wantWarn: 5},
+	}
+
+	setAzureEnv(t)
+	for loaderName, load := range loaders {
+		for _, test := range tests {
+			t.Run(loaderName+"/"+test.name, func(t *testing.T) {
+				// restartCount in the legacy form alongside keeps working without a warn tier
+				config, err := load(writeConfig(t, "thresholds:\n  restartCount: 50\n  "+test.file+"\n"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if config.Thresholds.CrashingPodsPercent != test.wantAbort {
+					t.Errorf("crashingPodsPercent abort = %d, want %d", config.Thresholds.CrashingPodsPercent, test.wantAbort)
+				}
+				warn := config.Thresholds.WarnTier()
+				if warn.CrashingPodsPercent != test.wantWarn {
+					t.Errorf("crashingPodsPercent warn = %d, want %d", warn.CrashingPodsPercent, test.wantWarn)
+				}
+				if config.Thresholds.RestartCount != 50 || warn.RestartCount != ThresholdDisabled {
+					t.Errorf("restartCount = %d with warn %d, want 50 abort only", config.Thresholds.RestartCount, warn.RestartCount)
+				}
+				if test.wantWarn == ThresholdDisabled && config.Thresholds.Warn != nil {
+					t.Errorf("warn tier = %+v, want none without a warn threshold", *config.Thresholds.Warn)
+				}
+			})
+		}
+	}
+}
+
+func TestThresholdTiersRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{
+			name:    "warn above abort",
+			file:    "thresholds:\n  crashingPodsPercent: {warn: 20, abort: 15}\n",
+			wantErr: "crashingPodsPercent.warn must be below the abort threshold (15), got: 20",
+		},
+		{
+			name:    "warn equal to abort",
+			file:    "thresholds:\n  failedJobs: {warn: 3, abort: 3}\n",
+			wantErr: "failedJobs.warn must be below the abort threshold (3), got: 3",
+		},
+		{
+			name:    "warn above the default abort",
+			file:    "thresholds:\n  crashingPodsPercent: {warn: 12}\n",
+			wantErr: "crashingPodsPercent.warn must be below the abort threshold (10), got: 12",
+		},
+		{
+			name:    "warn above an abort lowered later in the file",
+			file:    "thresholds:\n  restartCount: {warn: 10}\n  pendingPodsPercent: 20\n  crashingPodsPercent: {warn: 8, abort: 5}\n",
+			wantErr: "crashingPodsPercent.warn must be below the abort threshold (5), got: 8",
+		},
+		{
+			name:    "negative warn",
+			file:    "thresholds:\n  failedJobs: {warn: -2, abort: 3}\n",
+			wantErr: "failedJobs.warn must not be negative, got: -2",
+		},
+		{
+			name:    "percentage warn above 100",
+			file:    "thresholds:\n  crashingPodsPercent: {warn: 150, abort: -1}\n",
+			wantErr: "crashingPodsPercent.warn must be between 0 and 100, got: 150",
+		},
+		{
+			name:    "operation warn above the inherited abort",
+			file:    "thresholds:\n  crashingPodsPercent: 15\nmonitoredOperations:\n  - name: upgrade\n    thresholds:\n      crashingPodsPercent: {warn: 20}\n",
+			wantErr: "monitoredOperations[upgrade].thresholds: crashingPodsPercent.warn must be below the abort threshold (15), got: 20",
+		},
+		{
+			name:    "neither a value nor a pair",
+			file:    "thresholds:\n  crashingPodsPercent: high\n",
+			wantErr: "crashingPodsPercent must be a number or a {warn, abort} pair",
+		},
+	}
+
+	setAzureEnv(t)
+	for loaderName, load := range loaders {
+		for _, test := range tests {
+			t.Run(loaderName+"/"+test.name, func(t *testing.T) {
+				_, err := load(writeConfig(t, test.file))
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("error = %v, want %q", err, test.wantErr)
+				}
+			})
+		}
+	}
+}
+
+func TestThresholdTiersRoundTrip(t *testing.T) {
+	var thresholds ThresholdsConfig
+	if err := yaml.Unmarshal([]byte("crashingPodsPercent: {warn: 5, abort: 15}\nfailedJobs: 0\nrestartCount: 50\n"), &thresholds); err != nil {
+		t.Fatal(err)
+	}
+	data, err := yaml.Marshal(thresholds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "crashingPodsPercent:\n  warn: 5\n  abort: 15\nfailedJobs: 0\nrestartCount: 50\n"
+	if string(data) != want {
+		t.Errorf("marshalled =\n%s\nwant\n%s", data, want)
+	}
+
+	var decoded ThresholdsConfig
+	if err := yaml.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.CrashingPodsPercent != 15 || decoded.WarnTier().CrashingPodsPercent != 5 || decoded.RestartCount != 50 {
+		t.Errorf("decoded = %d with warn %d, restartCount %d, want 15 with warn 5, 50",
+			decoded.CrashingPodsPercent, decoded.WarnTier().CrashingPodsPercent, decoded.RestartCount)
+	}
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Watcher polls a configuration file mounted from a ConfigMap and reloads it when it changes.
+//
+// Kubelet updates a ConfigMap volume by writing the new contents to a fresh timestamped directory and
+// atomically swapping the ..data symlink to it, so the file's own inode never changes. The watcher
+// therefore resolves every symlink on the path and compares the resolved target along with its size and
+// modification time. ConfigMaps mounted with subPath are never updated by kubelet and are not reloaded.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	load     func(string) (*Config, error)
+	version  fileVersion
+}
+
+// fileVersion identifies one revision of the watched file
+type fileVersion struct {
+	target  string
+	size    int64
+	modTime time.Time
+}
+
+// NewWatcher creates a watcher for the configuration file at path, treating its current revision as
+// already loaded. Each revision is parsed and validated with LoadConfigFromConfigMap.
+func NewWatcher(path string, interval time.Duration) *Watcher {
+	w := &Watcher{path: path, interval: interval, load: LoadConfigFromConfigMap}
+	w.version, _ = w.stat()
+	return w
+}
+
+// Run checks the file every interval until ctx is done. A revision that loads and validates is passed to
+// apply; one that does not is passed to reject with the error, and the active configuration stays in
+// effect. Each revision is reported once. While the file cannot be read, for example in the middle of a
+// volume update, it is left alone until the next check.
+func (w *Watcher) Run(ctx context.Context, apply func(*Config), reject func(error)) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			version, err := w.stat()
+			if err != nil || version == w.version {
+				continue
+			}
+			w.version = version
+
+			cfg, err := w.load(w.path)
+			if err != nil {
+				reject(err)
+				continue
+			}
+			apply(cfg)
+		}
+	}
+}
+
+// stat returns the current revision of the file, following symlinks such as ConfigMap's ..data
+func (w *Watcher) stat() (fileVersion, error) {
+	target, err := filepath.EvalSymlinks(w.path)
+	if err != nil {
+		return fileVersion{}, err
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return fileVersion{}, err
+	}
+	return fileVersion{target: target, size: info.Size(), modTime: info.ModTime()}, nil
+}
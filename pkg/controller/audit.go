@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/exporter"
+	"aks-health-monitor/pkg/runtimeinfo"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// auditConfigMapName is the ConfigMap, in the controller namespace, holding the audit trail of aborts
+const auditConfigMapName = eventComponent + "-audit"
+
+// auditKeyEntries is the key of the audit ConfigMap holding the entries as a JSON array, oldest first
+const auditKeyEntries = "entries.json"
+
+// AuditEntry is the durable record of one abort sent to Azure: what the controller saw, when, and what
+// Azure answered. The request IDs let Azure support find the abort in ARM's logs.
+type AuditEntry struct {
+	Timestamp  time.Time           `json:"timestamp"`
+	TraceID    string              `json:"traceId"`
+	Controller string              `json:"controller,omitempty"`
+	Operation  string              `json:"operation"`
+	Violations []string            `json:"violations"`
+	Critical   bool                `json:"critical,omitempty"`
+	Result     string              `json:"result"`
+	Error      string              `json:"error,omitempty"`
+	Azure      azure.ARMRequestIDs `json:"azure"`
+}
+
+// newAuditEntry builds the audit entry of an abort from its decision and Azure's answer
+func newAuditEntry(identity *runtimeinfo.Info, status *azure.OperationStatus, result *HealthCheckResult, outcome AbortOutcome) AuditEntry {
+	entry := AuditEntry{
+		Timestamp:  result.Timestamp,
+		TraceID:    result.TraceID,
+		Operation:  status.OperationType,
+		Violations: result.Violations,
+		Critical:   result.CriticalAbort != nil && result.CriticalAbort.Attempted,
+		Result:     exporter.AbortSucceeded,
+		Error:      outcome.Error,
+		Azure:      outcome.Request,
+	}
+	if identity != nil {
+		entry.Controller = identity.PodName
+	}
+	if !outcome.Succeeded {
+		entry.Result = exporter.AbortFailed
+	}
+	return entry
+}
+
+// recordAudit appends an entry to the audit ConfigMap, keeping the most recent auditTrail.maxEntries. The
+// entries are decoded from the ConfigMap read for the update, which is rejected if another writer changed
+// it since, so concurrent writers retry instead of dropping each other's entries.
+func (c *Controller) recordAudit(ctx context.Context, cfg *config.Config, entry AuditEntry) {
+	trim := func(entries []AuditEntry) []AuditEntry {
+		if len(entries) > cfg.AuditTrail.MaxEntries {
+			return entries[len(entries)-cfg.AuditTrail.MaxEntries:]
+		}
+		return entries
+	}
+
+	c.mu.Lock()
+	c.auditTrail = trim(append(c.auditTrail[:len(c.auditTrail):len(c.auditTrail)], entry))
+	c.mu.Unlock()
+
+	namespace, err := c.identity.RequireNamespace("writing the audit trail")
+	if err != nil {
+		klog.Warningf("Abort %s is only kept in memory: %v", entry.TraceID, err)
+		return
+	}
+
+	var written []AuditEntry
+	err = c.writer.MutateConfigMap(ctx, namespace, auditConfigMapName, func(configMap *corev1.ConfigMap) error {
+		entries := decodeAuditEntries(configMap)
+		written = trim(append(entries, entry))
+
+		data, err := json.Marshal(written)
+		if err != nil {
+			return err
+		}
+		if configMap.Data == nil {
+			configMap.Data = make(map[string]string)
+		}
+		configMap.Data[auditKeyEntries] = string(data)
+		return nil
+	})
+	if err != nil {
+		klog.Errorf("Failed to write abort %s to the audit trail: %v", entry.TraceID, err)
+		return
+	}
+
+	// The ConfigMap may also hold entries of other replicas
+	c.mu.Lock()
+	c.auditTrail = written
+	c.mu.Unlock()
+}
+
+// loadAuditTrail reads the audit trail left by previous runs, so /status shows it from the start
+func (c *Controller) loadAuditTrail(ctx context.Context) {
+	namespace, err := c.identity.RequireNamespace("reading the audit trail")
+	if err != nil {
+		klog.V(2).Infof("Not reading the audit trail: %v", err)
+		return
+	}
+
+	configMap, err := c.kubeClient.CoreV1().ConfigMaps(namespace).Get(ctx, auditConfigMapName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return
+	}
+	if err != nil {
+		klog.Warningf("Failed to read the audit trail: %v", err)
+		return
+	}
+
+	entries := decodeAuditEntries(configMap)
+	c.mu.Lock()
+	c.auditTrail = entries
+	c.mu.Unlock()
+	klog.Infof("Loaded %d aborts from the audit trail", len(entries))
+}
+
+// decodeAuditEntries returns the entries held by the audit ConfigMap. Entries that cannot be decoded are
+// logged and dropped, so a damaged ConfigMap does not stop new aborts from being recorded.
+func decodeAuditEntries(configMap *corev1.ConfigMap) []AuditEntry {
+	data, ok := configMap.Data[auditKeyEntries]
+	if !ok {
+		return nil
+	}
+
+	var entries []AuditEntry
+	if err := json.Unmarshal([]byte(data), &entries); err != nil {
+		klog.Errorf("Discarding unreadable audit trail in ConfigMap %s/%s: %v", configMap.Namespace, configMap.Name, err)
+		return nil
+	}
+	return entries
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/metrics"
+
+	"k8s.io/klog/v2"
+)
+
+// OperationBaseline is the health of the cluster when an operation was first observed. Metrics listed in
+// baselineDeltas are judged by how far they rose above it instead of by their absolute threshold.
+type OperationBaseline struct {
+	Operation  string                     `json:"operation"`
+	CapturedAt time.Time                  `json:"capturedAt"`
+	Values     map[metrics.MetricType]int `json:"values"`
+}
+
+// captureBaseline keeps the metrics of the first collection of an operation as its baseline. Later
+// collections of the same operation leave it in place; checkHealth discards it when the operation ends.
+func (c *Controller) captureBaseline(cfg *config.Config, operation string, collected []metrics.MetricValue, now time.Time) {
+	if len(cfg.BaselineDeltas) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.baseline != nil {
+		return
+	}
+
+	baseline := &OperationBaseline{Operation: operation, CapturedAt: now, Values: make(map[metrics.MetricType]int)}
+	values := make([]string, 0, len(collected))
+	for _, metric := range collected {
+		if metric.Type.IsInformational() {
+			continue
+		}
+		baseline.Values[metric.Type] = metric.Value
+		values = append(values, fmt.Sprintf("%s %s", metric.Type, metric))
+	}
+	sort.Strings(values)
+	c.baseline = baseline
+	klog.Infof("Captured baseline of operation '%s': %s", operation, strings.Join(values, ", "))
+}
+
+// activeBaseline returns the baseline of the operation in progress, or nil before it was captured
+func (c *Controller) activeBaseline() *OperationBaseline {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.baseline
+}
+
+// baselineThreshold returns the threshold of a metric relative to the baseline: its baseline value plus
+// the configured delta. ok is false when the metric has no delta or was not collected for the baseline,
+// leaving the absolute threshold in effect.
+func baselineThreshold(cfg *config.Config, baseline *OperationBaseline, metricType metrics.MetricType) (threshold, value int, ok bool) {
+	delta, configured := cfg.BaselineDeltas[string(metricType)]
+	if !configured || baseline == nil {
+		return 0, 0, false
+	}
+	value, captured := baseline.Values[metricType]
+	if !captured {
+		return 0, 0, false
+	}
+	return value + delta, value, true
+}
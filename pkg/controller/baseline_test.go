@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"aks-health-monitor/pkg/azure/fake"
+	"aks-health-monitor/pkg/metrics"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+// setUnreadyNodes makes the first count nodes NotReady and the rest Ready
+func setUnreadyNodes(t *testing.T, kubeClient *kubefake.Clientset, count int) {
+	t.Helper()
+	nodes, err := kubeClient.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range nodes.Items {
+		ready := corev1.ConditionTrue
+		if i < count {
+			ready = corev1.ConditionFalse
+		}
+		nodes.Items[i].Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}}
+		if _, err := kubeClient.CoreV1().Nodes().UpdateStatus(context.Background(), &nodes.Items[i], metav1.UpdateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// notReadyViolation returns the not_ready_nodes_percent violation of a result, or ""
+func notReadyViolation(result HealthCheckResult) string {
+	for _, violation := range result.Violations {
+		if strings.HasPrefix(violation, string(metrics.NotReadyNodesPercentMetric)) {
+			return violation
+		}
+	}
+	return ""
+}
+
+func TestBaselineDeltas(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.DryRun = true
+	cfg.Thresholds.NotReadyNodesPercent = 10
+	cfg.BaselineDeltas = map[string]int{string(metrics.NotReadyNodesPercentMetric): 10}
+	objects := make([]runtime.Object, 0, 10)
+	for i := 0; i < 10; i++ {
+		objects = append(objects, node(fmt.Sprintf("node-%d", i), corev1.ConditionTrue))
+	}
+	kubeClient := kubefake.NewSimpleClientset(objects...)
+	c, azureClient := newTestControllerWithClient(t, cfg, kubeClient)
+
+	// A chronically unhealthy cluster: 20% of nodes NotReady, beyond the absolute 10% threshold
+	setUnreadyNodes(t, kubeClient, 2)
+	azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+	result := c.runCycle(context.Background())
+	if violation := notReadyViolation(result); violation != "" {
+		t.Errorf("first cycle: %q, want the baseline captured and not violated", violation)
+	}
+	baseline, ok := c.GetStatus()["baseline"].(*OperationBaseline)
+	if !ok || baseline.Operation != "Upgrading" || baseline.Values[metrics.NotReadyNodesPercentMetric] != 20 {
+		t.Fatalf("status baseline = %+v, want Upgrading with not ready nodes at 20%%", c.GetStatus()["baseline"])
+	}
+
+	// Worse than before the operation, but within the delta
+	setUnreadyNodes(t, kubeClient, 3)
+	if violation := notReadyViolation(c.runCycle(context.Background())); violation != "" {
+		t.Errorf("30%% against a 20%% baseline and a delta of 10: %q, want no violation", violation)
+	}
+
+	// Beyond the delta; the baseline stays the one captured at the start
+	setUnreadyNodes(t, kubeClient, 4)
+	if violation := notReadyViolation(c.runCycle(context.Background())); !strings.Contains(violation, "40% (4/10) > 30% (baseline 20%)") {
+		t.Errorf("40%% against a 20%% baseline and a delta of 10: %q, want a violation naming the baseline", violation)
+	}
+
+	// The operation ends: the baseline is discarded
+	azureClient.SetOperationStatuses(fake.Idle())
+	c.runCycle(context.Background())
+	if _, ok := c.GetStatus()["baseline"]; ok {
+		t.Errorf("baseline %+v kept after the operation ended", c.GetStatus()["baseline"])
+	}
+
+	// The next operation starts from a healthier cluster and is judged against its own baseline
+	setUnreadyNodes(t, kubeClient, 0)
+	azureClient.SetOperationStatuses(fake.InProgress("Scaling"))
+	c.runCycle(context.Background())
+	setUnreadyNodes(t, kubeClient, 2)
+	result = c.runCycle(context.Background())
+	if violation := notReadyViolation(result); !strings.Contains(violation, "20% (2/10) > 10% (baseline 0%)") {
+		t.Errorf("20%% against a new 0%% baseline: %q, want a violation", violation)
+	}
+	if baseline, ok := c.GetStatus()["baseline"].(*OperationBaseline); !ok || baseline.Operation != "Scaling" {
+		t.Errorf("status baseline = %+v, want the Scaling operation's", c.GetStatus()["baseline"])
+	}
+}
+
+func TestBaselineLeavesOtherMetricsAbsolute(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.DryRun = true
+	cfg.Thresholds.NotReadyNodesPercent = 10
+	cfg.Thresholds.PendingPodsPercent = 10
+	cfg.BaselineDeltas = map[string]int{string(metrics.NotReadyNodesPercentMetric): 50}
+	c, azureClient := newTestController(t, cfg, node("node-0", corev1.ConditionFalse), pendingPod("default", "web"))
+	azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+
+	result := c.runCycle(context.Background())
+	if violation := notReadyViolation(result); violation != "" {
+		t.Errorf("not ready nodes judged against the baseline: %q, want no violation", violation)
+	}
+	pending := false
+	for _, violation := range result.Violations {
+		pending = pending || strings.HasPrefix(violation, string(metrics.PendingPodsPercentMetric)+": 100% (1/1) > 10%")
+	}
+	if !pending {
+		t.Errorf("violations = %v, want pending pods judged against the absolute threshold", result.Violations)
+	}
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// CadenceStatus compares the observed interval between cycle starts with the configured one
+type CadenceStatus struct {
+	// Configured is the average interval the run loop scheduled over the window
+	Configured time.Duration `json:"configured"`
+	// Effective is the average observed interval between cycle starts over the window
+	Effective time.Duration `json:"effective"`
+	// Samples is the number of intervals averaged
+	Samples int `json:"samples"`
+	// Drifting is true while Effective exceeds Configured by more than the configured factor
+	Drifting bool `json:"drifting"`
+}
+
+// cadenceInterval is one observed interval between cycle starts and the interval that was scheduled
+type cadenceInterval struct {
+	observed  time.Duration
+	scheduled time.Duration
+}
+
+// cadenceTracker keeps the most recent intervals between cycle starts
+type cadenceTracker struct {
+	lastStart time.Time
+	intervals []cadenceInterval
+	status    CadenceStatus
+}
+
+// observe records a cycle start and returns the interval since the previous start, if any. scheduled is
+// the poll interval the run loop waited for before this cycle.
+func (t *cadenceTracker) observe(start time.Time, scheduled time.Duration, window int, factor float64) (time.Duration, bool) {
+	previous := t.lastStart
+	t.lastStart = start
+	if previous.IsZero() {
+		return 0, false
+	}
+
+	interval := start.Sub(previous)
+	t.intervals = append(t.intervals, cadenceInterval{observed: interval, scheduled: scheduled})
+	if len(t.intervals) > window {
+		t.intervals = t.intervals[len(t.intervals)-window:]
+	}
+
+	var observed, configured time.Duration
+	for _, sample := range t.intervals {
+		observed += sample.observed
+		configured += sample.scheduled
+	}
+	samples := len(t.intervals)
+	t.status = CadenceStatus{
+		Configured: configured / time.Duration(samples),
+		Effective:  observed / time.Duration(samples),
+		Samples:    samples,
+	}
+	t.status.Drifting = float64(t.status.Effective) > factor*float64(t.status.Configured)
+
+	return interval, true
+}
+
+// recordCycleStart measures the interval since the previous cycle started and warns when the average
+// cadence has drifted beyond the configured factor of the poll interval. Slow cycles, GC pauses and API
+// latency all stretch the cadence and reduce how quickly violations are caught.
+func (c *Controller) recordCycleStart(ctx context.Context, start time.Time, scheduled time.Duration) {
+	cfg := c.cfg()
+
+	c.mu.Lock()
+	wasDrifting := c.cadence.status.Drifting
+	interval, ok := c.cadence.observe(start, scheduled, cfg.Cadence.Window, cfg.Cadence.DriftFactor)
+	status := c.cadence.status
+	c.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	c.exporter.ObserveCycleInterval(interval)
+
+	switch {
+	case status.Drifting && !wasDrifting:
+		message := fmt.Sprintf("Average interval between health checks is %s over the last %d cycles, more than %gx the configured %s",
+			status.Effective.Round(time.Second), status.Samples, cfg.Cadence.DriftFactor, status.Configured.Round(time.Second))
+		klog.Warning(message)
+		c.recordEvent(ctx, corev1.EventTypeWarning, "CadenceDrift", message)
+	case !status.Drifting && wasDrifting:
+		klog.Infof("Health check cadence recovered: average interval %s against configured %s",
+			status.Effective.Round(time.Second), status.Configured.Round(time.Second))
+	}
+}
@@ -2,13 +2,25 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"aks-health-monitor/pkg/azure"
 	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/decisionlog"
+	"aks-health-monitor/pkg/exporter"
+	"aks-health-monitor/pkg/kube/writer"
 	"aks-health-monitor/pkg/metrics"
+	"aks-health-monitor/pkg/notify"
+	"aks-health-monitor/pkg/runtimeinfo"
+	"aks-health-monitor/pkg/storage"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
 )
@@ -18,80 +30,501 @@ import (
 type Controller struct {
 	kubeClient          kubernetes.Interface
 	metricsCollector    *metrics.Collector
-	azureClient         *azure.Client
-	config              *config.Config
+	azureClient         azure.Interface
+	activeConfig        atomic.Pointer[config.Config]
+	exporter            *exporter.Exporter
+	identity            *runtimeinfo.Info
+	writer              *writer.Writer
+	history             *resultHistory
+	mu                  sync.RWMutex
 	operationInProgress bool
 	currentOperation    string
+	currentUpgrade      *azure.UpgradeClassification
+	operationStartedAt  time.Time
+	operationErrors     []azure.ProvisioningError
+	maintenance         maintenanceTracker
+	lastDiscovery       time.Time
+	cadence             cadenceTracker
+	heartbeats          heartbeatTracker
+	permission          permissionTracker
+	override            *OverrideStatus
+	leader              *LeaderStatus
+	consecutiveFailures int
+	lastCheckError      string
+	events              eventCoalescer
+	decisions           *decisionlog.Sink
+	notifier            *notify.Notifier
+	verdicts            *azure.VerdictPublisher
+	blobs               *azure.BlobUploader
+	unhealthyCycles     int
+	metricsOnlyReason   string
+	panics              []time.Time
+	store               storage.Store
+	lastPrune           time.Time
+	suggestions         suggestionTracker
+	progress            progressTracker
+	initiator           initiatorTracker
+	configGeneration    int64
+	lastConfigChange    *ConfigChange
+	lastAbort           *AbortExplanation
+	auditTrail          []AuditEntry
+	lastAbortAt         time.Time
+	cooldown            abortCooldown
+	dryRunAborts        int
+	violationStreaks    violationStreaks
+	baseline            *OperationBaseline
 }
 
-// NewController creates a new health controller
-func NewController(kubeClient kubernetes.Interface, metricsCollector *metrics.Collector, cfg *config.Config) *Controller {
-	azureClient, err := azure.NewClient(cfg.Azure)
-	if err != nil {
-		klog.Fatalf("Failed to create Azure client: %v", err)
-	}
+// ConfigChange records a runtime configuration change
+type ConfigChange struct {
+	Generation int64           `json:"generation"`
+	Timestamp  time.Time       `json:"timestamp"`
+	Changes    []config.Change `json:"changes"`
+}
 
-	return &Controller{
+// NewController creates a new health controller. Prometheus collectors are registered with the given registerer.
+// The identity locates objects the controller writes about itself. Without an Azure client the controller
+// starts in metrics-only mode and creates one from the configuration once it can.
+func NewController(kubeClient kubernetes.Interface, metricsCollector *metrics.Collector, azureClient azure.Interface, cfg *config.Config, registerer prometheus.Registerer, identity *runtimeinfo.Info) (*Controller, error) {
+	c := &Controller{
 		kubeClient:       kubeClient,
 		metricsCollector: metricsCollector,
-		azureClient:      azureClient,
-		config:           cfg,
+		exporter:         exporter.NewExporter(registerer, cfg.Server.Exemplars),
+		identity:         identity,
+		writer:           writer.New(kubeClient),
+		history:          newResultHistory(defaultHistorySize),
+	}
+	c.activeConfig.Store(cfg)
+	c.exporter.SetLeader(true)
+	metricsCollector.ObserveDurations(c.exporter)
+	for _, rejected := range cfg.RejectedCollectors() {
+		klog.Warningf("Ignoring the collectors.%s block, the collector runs with defaults: %v", rejected.Name, rejected.Err)
+	}
+	metricsCollector.ConfigureCollectors(cfg.Collectors)
+
+	if cfg.DecisionLog.Endpoint != "" {
+		c.decisions = decisionlog.NewSink(cfg.DecisionLog)
+	}
+	if cfg.Notifications.Enabled() {
+		notifier, err := notify.New(cfg.Notifications, c.exporter.RecordNotificationFailure)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure notifications: %w", err)
+		}
+		c.notifier = notifier
 	}
+	if cfg.HistoryStorage.Backend == config.HistoryBackendSQLite {
+		ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+		store, err := storage.NewSQLiteStore(ctx, cfg.HistoryStorage.SQLitePath)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open history storage: %w", err)
+		}
+		c.store = store
+	}
+
+	if azureClient == nil {
+		c.enterMetricsOnly("the Azure client could not be created at startup")
+	} else {
+		c.useAzureClient(cfg, azureClient)
+	}
+
+	return c, nil
+}
+
+// cfg returns the configuration currently in effect
+func (c *Controller) cfg() *config.Config {
+	return c.activeConfig.Load()
+}
+
+// ApplyConfig atomically replaces the active configuration and logs what changed.
+// Reloads that change nothing are not recorded. The returned changes have secrets redacted.
+func (c *Controller) ApplyConfig(newConfig *config.Config) []config.Change {
+	oldConfig := c.activeConfig.Swap(newConfig)
+	c.metricsCollector.ConfigureCollectors(newConfig.Collectors)
+
+	changes := config.Diff(oldConfig, newConfig)
+	if len(changes) == 0 {
+		klog.V(2).Info("Configuration reloaded with no changes")
+		return nil
+	}
+
+	c.mu.Lock()
+	c.configGeneration++
+	c.lastConfigChange = &ConfigChange{
+		Generation: c.configGeneration,
+		Timestamp:  time.Now(),
+		Changes:    changes,
+	}
+	generation := c.configGeneration
+	c.mu.Unlock()
+
+	for _, change := range changes {
+		if change.RequiresRestart() {
+			klog.Warningf("Configuration change (generation %d) takes effect after a restart: %s", generation, change)
+			continue
+		}
+		klog.Infof("Configuration change (generation %d): %s", generation, change)
+	}
+
+	return changes
 }
 
 // Run starts the health monitoring loop
 func (c *Controller) Run(ctx context.Context) error {
 	klog.Info("Starting health controller")
+	if err := c.prepare(ctx); err != nil {
+		return err
+	}
+
+	// The sinks flush what is queued once ctx is cancelled; Run returns only after they have, so nothing
+	// they were delivering is lost when the process exits
+	sinks := c.startSinks(ctx)
+	defer sinks.Wait()
+	c.markMonitoringStarted(ctx)
+	c.loadAuditTrail(ctx)
 
-	ticker := time.NewTicker(c.config.PollInterval)
-	defer ticker.Stop()
+	// With informer collection, pods, nodes and jobs are watched for as long as the controller runs
+	c.metricsCollector.StartInformers(ctx)
+
+	// Discover served APIs and list planned maintenance windows up front so the first cycle uses them
+	c.refreshAPIDiscovery(ctx, time.Now())
+	if c.azureClient != nil {
+		c.refreshMaintenanceWindows(ctx, time.Now())
+	}
+
+	interval := c.pollInterval()
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			klog.Info("Stopping health controller")
 			return nil
-		case <-ticker.C:
-			if err := c.checkHealth(ctx); err != nil {
-				klog.Errorf("Health check failed: %v", err)
-			}
+		case <-timer.C:
+			c.recordCycleStart(ctx, time.Now(), interval)
+			c.runCycleSafely(ctx)
+			interval = c.pollInterval()
+			timer.Reset(interval)
+		}
+	}
+}
+
+// RunOnce performs a single health check cycle, as one tick of Run would, and returns its result once the
+// cycle's decision records and notifications are delivered. Informers, the status ConfigMap and the poll
+// loop are not started.
+func (c *Controller) RunOnce(ctx context.Context) (HealthCheckResult, error) {
+	if err := c.prepare(ctx); err != nil {
+		return HealthCheckResult{}, err
+	}
+
+	// The sinks flush what is queued when their context is cancelled, so cancel and wait before returning
+	sinkCtx, stopSinks := context.WithCancel(ctx)
+	sinks := c.startSinks(sinkCtx)
+
+	result := c.runCycle(ctx)
+	stopSinks()
+	sinks.Wait()
+	return result, nil
+}
+
+// startSinks runs the decision log and the notifier until ctx is cancelled. The returned WaitGroup is done
+// once both have delivered what was queued.
+func (c *Controller) startSinks(ctx context.Context) *sync.WaitGroup {
+	var sinks sync.WaitGroup
+	if c.decisions != nil {
+		sinks.Add(1)
+		go func() {
+			defer sinks.Done()
+			c.decisions.Run(ctx)
+		}()
+	}
+	if c.notifier != nil {
+		sinks.Add(1)
+		go func() {
+			defer sinks.Done()
+			c.notifier.Run(ctx)
+		}()
+	}
+	return &sinks
+}
+
+// prepare checks the Azure client before the first cycle
+func (c *Controller) prepare(ctx context.Context) error {
+	if c.cfg().DryRun {
+		klog.Warning("Dry-run mode: aborts are logged and recorded as Events but not sent to Azure")
+	}
+
+	// Fail fast on misconfigured Azure identifiers or permissions instead of failing every cycle.
+	// In metrics-only mode the check runs when a client is eventually created.
+	if c.azureClient != nil {
+		if err := c.azureClient.Preflight(ctx); err != nil {
+			return fmt.Errorf("Azure preflight check failed: %w", err)
 		}
+		c.checkAbortPermission(ctx, c.cfg(), time.Now())
 	}
+	return nil
 }
 
-// checkHealth performs a single health check cycle
-func (c *Controller) checkHealth(ctx context.Context) error {
+// runCycle performs one health check and records its result
+func (c *Controller) runCycle(ctx context.Context) HealthCheckResult {
+	// Each cycle is identified by a trace ID that links its log lines, history entry and metric exemplars
+	result := &HealthCheckResult{
+		Timestamp: time.Now(),
+		TraceID:   exporter.NewTraceID(),
+		Action:    ActionNone,
+	}
+	ctx = exporter.ContextWithTraceID(ctx, result.TraceID)
+	klog.V(2).Infof("Starting health check cycle (trace %s)", result.TraceID)
+
+	// Decide against one configuration snapshot so a concurrent reload cannot change the rules mid-cycle
+	cfg := c.applyOverride(ctx, c.cfg(), result.Timestamp)
+	c.refreshAPIDiscovery(ctx, result.Timestamp)
+	c.reconnectAzure(ctx, cfg)
+
+	err := c.guard("check", func() error {
+		if c.azureClient == nil {
+			return c.checkMetricsOnly(ctx, result)
+		}
+		c.refreshMaintenanceWindows(ctx, result.Timestamp)
+		c.checkAbortPermission(ctx, cfg, result.Timestamp)
+		return c.checkHealth(ctx, cfg, result)
+	})
+	if err != nil {
+		klog.Errorf("Health check failed: %v", err)
+		result.Error = err.Error()
+	}
+	c.recordCheckOutcome(result.Error)
+
+	previous, _ := c.history.last()
+	result.canonicalizeViolations()
+	result.diffViolations(previous.ViolationRefs)
+	result.diffWarnings(previous.WarningRefs)
+
+	if result.Action == ActionSuppressed {
+		c.exporter.RecordAbortSuppressed(string(result.SuppressionReason))
+	}
+	c.recordViolationEvents(ctx, result)
+	c.recordWarningEvents(ctx, result)
+	c.logDecision(result)
+	c.sendNotification(cfg, result)
+
+	result.HealthState = result.healthState()
+	operation := "none"
+	if result.OperationInProgress {
+		operation = result.OperationType
+	}
+	c.exporter.SetHealthState(operation, int(result.HealthState))
+	c.exporter.SetOperationInProgress(result.OperationType, result.OperationInProgress)
+	c.exporter.SetMetricValues(result.Metrics)
+	c.exporter.SetThresholdStates(result.thresholdStates())
+	if c.azureClient != nil {
+		c.exporter.SetWriteCircuitState(int(c.azureClient.WriteCircuitState()))
+	}
+	c.publishVerdicts(ctx, cfg, result)
+	c.heartbeat(ctx, cfg, result)
+
+	c.history.add(*result)
+	c.exportFleetStates()
+	c.persistResult(ctx, *result)
+	c.pruneHistory(ctx, cfg, result.Timestamp)
+	return *result
+}
+
+// checkHealth performs a single health check cycle against cfg, filling in the result as it goes
+func (c *Controller) checkHealth(ctx context.Context, cfg *config.Config, result *HealthCheckResult) (err error) {
 	// Check if there's an ongoing operation
 	operationStatus, err := c.azureClient.GetClusterOperationStatus(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get cluster operation status: %w", err)
 	}
 
+	c.mu.Lock()
+	failure := detectOperationFailure(c.operationInProgress, c.currentOperation, operationStatus, result.Timestamp)
+	// The start of an operation is when it is first observed; a controller restarted mid-operation
+	// measures from its first cycle. result.Timestamp is a time.Now() reading, so grace periods, cooldowns
+	// and metric windows measured from it use the monotonic clock and are unaffected by clock steps.
+	if !operationStatus.InProgress {
+		c.operationStartedAt = time.Time{}
+		c.progress = progressTracker{}
+		c.initiator = initiatorTracker{}
+		c.violationStreaks = violationStreaks{}
+		c.baseline = nil
+	} else if !c.operationInProgress || c.currentOperation != operationStatus.OperationType {
+		c.operationStartedAt = result.Timestamp
+		c.violationStreaks = violationStreaks{}
+		c.baseline = nil
+	}
+	c.cooldown.expire(operationStatus)
 	c.operationInProgress = operationStatus.InProgress
 	c.currentOperation = operationStatus.OperationType
+	c.operationErrors = operationStatus.Errors
+	operationStartedAt := c.operationStartedAt
+	upgradeChanged := operationStatus.Upgrade != nil && (c.currentUpgrade == nil || *c.currentUpgrade != *operationStatus.Upgrade)
+	c.currentUpgrade = operationStatus.Upgrade
+	c.mu.Unlock()
+
+	if upgradeChanged {
+		class := operationStatus.Upgrade.Class
+		if class == "" {
+			class = "upgrade (unclassified)"
+		}
+		klog.Infof("Classified Upgrading operation as %s: %s", class, operationStatus.Upgrade.Evidence)
+	}
+
+	c.metricsCollector.SetOperationStart(operationStartedAt)
+
+	if failure != nil {
+		result.OperationFailed = failure
+		c.reportOperationFailure(ctx, cfg, failure)
+	}
+
+	result.OperationInProgress = operationStatus.InProgress
+	result.OperationType = operationStatus.OperationType
+	result.Upgrade = operationStatus.Upgrade
+	result.StatusSource = operationStatus.Source
+	result.StatusStaleFor = operationStatus.StaleFor
+	result.ProvisioningState = operationStatus.Status
+
+	for _, provisioningErr := range operationStatus.Errors {
+		klog.Warningf("Azure reported provisioning error on %s (state %s): %s: %s",
+			provisioningErr.Source, operationStatus.Status, provisioningErr.Code, provisioningErr.Message)
+	}
+
+	// Provisioning errors are reported every cycle, also while no monitored operation runs and after one
+	// failed; cycles that collect metrics report them alongside the rest
+	result.Metrics = []metrics.MetricValue{operationErrorMetric(operationStatus)}
+
+	if !operationStatus.InProgress {
+		// Ahead of planned maintenance, capture fresh metrics so operation-time features start from good data
+		if window, ok := c.activeMaintenanceWindow(result.Timestamp); ok {
+			klog.V(2).Infof("Maintenance window %q starts %s, capturing pre-operation metrics", window.Name, window.Start.Format(time.RFC3339))
+			collectedMetrics, err := c.metricsCollector.CollectMetrics(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to collect pre-operation metrics: %w", err)
+			}
+			result.Metrics = append(collectedMetrics, operationErrorMetric(operationStatus))
+			c.learnSteadyState(ctx, cfg, collectedMetrics, result.Timestamp)
+			return nil
+		}
+
+		// While learning threshold suggestions, idle cycles sample the steady state
+		if c.suggestionSampleDue(cfg, result.Timestamp) {
+			klog.V(2).Info("No operation in progress, collecting steady-state metrics for threshold suggestions")
+			collectedMetrics, err := c.metricsCollector.CollectMetrics(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to collect steady-state metrics: %w", err)
+			}
+			result.Metrics = append(collectedMetrics, operationErrorMetric(operationStatus))
+			c.learnSteadyState(ctx, cfg, collectedMetrics, result.Timestamp)
+			return nil
+		}
 
-	if !c.operationInProgress {
 		klog.V(2).Info("No operation in progress, skipping health check")
 		return nil
 	}
 
+	settings := cfg.OperationSettings(operationStatus.OperationName())
+	if !settings.Monitored {
+		klog.V(2).Infof("Operation '%s' (%s) is not in monitoredOperations, skipping health check", c.currentOperation, settings.Name)
+		return nil
+	}
 	klog.Infof("Operation '%s' in progress, checking health metrics", c.currentOperation)
+	result.Initiator = c.attributeInitiator(ctx, cfg, operationStatus, operationStartedAt, result.Timestamp)
 
-	// Collect metrics
-	collectedMetrics, err := c.metricsCollector.CollectMetrics(ctx)
-	if err != nil {
+	if elapsed := result.Timestamp.Sub(operationStartedAt); elapsed < settings.GracePeriod {
+		return c.collectWithinGracePeriod(ctx, cfg, settings, operationStatus, result, elapsed)
+	}
+
+	// Thresholds apply in the current phase of the operation
+	var phase operationPhase
+	if len(cfg.MetricWindows) > 0 || len(cfg.ProgressScaling) > 0 {
+		phase = c.currentPhase(ctx, cfg, operationStatus, result.Timestamp)
+	}
+
+	// Collect metrics, aborting at once if a collector reports a metric beyond its critical threshold
+	critical := c.watchCriticalThresholds(ctx, cfg, settings, operationStatus, phase, result.Timestamp)
+	collectedMetrics, err := c.metricsCollector.CollectMetricsStreaming(ctx, critical.observer())
+	var collectionErr *metrics.CollectionError
+	if err != nil && !errors.As(err, &collectionErr) {
+		// An abort the critical path already issued is recorded even though the cycle fails
+		if _, abortErr := c.finishCriticalAbort(ctx, cfg, operationStatus, result, critical); abortErr != nil {
+			klog.Error(abortErr)
+		}
 		return fmt.Errorf("failed to collect metrics: %w", err)
 	}
+	if collectionErr != nil {
+		// The metrics of the collectors that succeeded are still evaluated and reported. The failed ones are
+		// listed as skipped, so the incomplete coverage holds back a regular abort, and fail the cycle.
+		defer func() {
+			if err == nil {
+				err = fmt.Errorf("failed to collect metrics: %w", collectionErr)
+			}
+		}()
+	}
+	collectedMetrics = append(collectedMetrics, operationErrorMetric(operationStatus))
+	result.SuspectedStuck = c.observeProgress(ctx, cfg, operationStatus, result.Timestamp)
+	if cfg.StuckOperation.TreatAsViolation {
+		collectedMetrics = append(collectedMetrics, stuckOperationMetric(result.SuspectedStuck))
+	}
+	result.Metrics = collectedMetrics
+	result.Coverage = c.metricsCollector.Coverage()
 
-	// Evaluate thresholds
-	violations := c.evaluateThresholds(collectedMetrics)
+	c.captureBaseline(cfg, c.currentOperation, collectedMetrics, result.Timestamp)
+	result.recordEvaluations(c.evaluateThresholds(cfg, settings.Thresholds, collectedMetrics, phase, c.activeBaseline()))
+	c.advanceViolationStreaks(result.Evaluations, settings.ConsecutiveViolations)
+	criticalHandled, criticalErr := c.finishCriticalAbort(ctx, cfg, operationStatus, result, critical)
+	violations := result.Violations
 	if len(violations) > 0 {
-		klog.Warningf("Threshold violations detected: %v", violations)
+		klog.Warningf("Threshold violations detected (trace %s, consecutive cycles %s): %v", result.TraceID, streakSummary(result.Evaluations), violations)
+		for _, evaluation := range result.Evaluations {
+			if evaluation.Violated {
+				c.exporter.RecordViolation(ctx, string(evaluation.Metric))
+			}
+		}
+
+		// The critical path already went through the abort policies and, unless one suppressed it, aborted
+		if criticalHandled {
+			return criticalErr
+		}
+
+		if detail := unsustainedViolations(result.Evaluations); detail != "" {
+			klog.Infof("Not aborting operation '%s' (%s): %s", c.currentOperation, SuppressionViolationStreak, detail)
+			result.suppress(SuppressionViolationStreak, detail)
+			return nil
+		}
+		result.consider(SuppressionViolationStreak, "")
+
+		if !result.Coverage.Complete() {
+			detail := coverageGap(result.Coverage)
+			klog.Warningf("Not aborting operation '%s' (%s): %s", c.currentOperation, SuppressionIncompleteData, detail)
+			result.suppress(SuppressionIncompleteData, detail)
+			return nil
+		}
+		result.consider(SuppressionIncompleteData, "")
+
+		if proceed, err := c.applyAbortPolicies(ctx, cfg, settings, operationStatus, result); !proceed {
+			if result.SuppressionReason == SuppressionDryRun {
+				c.reportDryRunAbort(ctx, cfg, operationStatus, result)
+			}
+			return err
+		}
 
-		// Abort the operation
-		if err := c.abortOperation(ctx); err != nil {
-			return fmt.Errorf("failed to abort operation: %w", err)
+		// Abort the operation and record why, whether or not Azure accepted it
+		request, abortErr := c.executeAbort(ctx, operationStatus, result.Timestamp)
+		if errors.Is(abortErr, azure.ErrCircuitOpen) {
+			c.reportAbortUnavailable(ctx, operationStatus, result, abortErr)
+			return nil
+		}
+		if errors.Is(abortErr, azure.ErrOperationFinished) {
+			c.reportOperationFinished(operationStatus, result, abortErr.Error())
+			return nil
 		}
+		c.recordAbortExplanation(ctx, cfg, operationStatus, result, request, abortErr)
+		if abortErr != nil {
+			return fmt.Errorf("failed to abort operation: %w", abortErr)
+		}
+		result.Action = ActionAborted
 
 		klog.Infof("Successfully aborted operation '%s' due to threshold violations", c.currentOperation)
 	} else {
@@ -101,49 +534,337 @@ func (c *Controller) checkHealth(ctx context.Context) error {
 	return nil
 }
 
-// evaluateThresholds checks if any metrics exceed their configured thresholds
-func (c *Controller) evaluateThresholds(collectedMetrics []metrics.MetricValue) []string {
-	var violations []string
+// applyAbortPolicies runs the policies that can suppress an abort, in order, recording each one on the
+// result. It reports whether the abort may proceed. The regular and the critical abort path both go
+// through it, so every policy applies to both.
+func (c *Controller) applyAbortPolicies(ctx context.Context, cfg *config.Config, settings config.OperationSettings, status *azure.OperationStatus, result *HealthCheckResult) (bool, error) {
+	if reason, detail := c.abortSuppression(cfg, status); reason != "" {
+		klog.Warningf("Not aborting operation '%s' (%s): %s", status.OperationType, reason, detail)
+		result.suppress(reason, detail)
+		return false, nil
+	}
+	result.consider(SuppressionAutoscalerInitiated, "")
+
+	if initiator := result.Initiator; initiator != nil && initiator.Initiator == azure.InitiatorTrusted {
+		detail := "operation started by a trusted initiator: " + initiator.Evidence
+		klog.Warningf("Not aborting operation '%s' (%s): %s", status.OperationType, SuppressionTrustedInitiator, detail)
+		result.suppress(SuppressionTrustedInitiator, detail)
+		return false, nil
+	}
+	result.consider(SuppressionTrustedInitiator, "")
+
+	if reason, detail := c.operationPolicySuppression(settings, result.Timestamp); reason != "" {
+		klog.Warningf("Not aborting operation '%s' (%s): %s", status.OperationType, reason, detail)
+		result.suppress(reason, detail)
+		return false, nil
+	}
+	for _, reason := range operationPolicyReasons {
+		result.consider(reason, "")
+	}
+
+	if !cfg.AbortSafety.Enabled {
+		result.consider(SuppressionUnsafeAbort, "abortSafety.enabled is false")
+	} else {
+		unsafe, err := c.evaluateAbortSafety(ctx, status, cfg.AbortSafety)
+		if err != nil {
+			return false, fmt.Errorf("failed to evaluate abort safety: %w", err)
+		}
+		if unsafe != "" {
+			klog.Warningf("Not aborting operation '%s' (%s): %s", status.OperationType, SuppressionUnsafeAbort, unsafe)
+			result.suppress(SuppressionUnsafeAbort, unsafe)
+			return false, nil
+		}
+		result.consider(SuppressionUnsafeAbort, "")
+	}
+
+	// Last, so a dry run reports exactly the aborts that would have been sent
+	if cfg.DryRun {
+		result.suppress(SuppressionDryRun, "dryRun is set")
+		return false, nil
+	}
+	result.consider(SuppressionDryRun, "")
+	return true, nil
+}
+
+// executeAbort aborts the operation and, once Azure accepted it, counts it and starts the abort cooldown.
+// Every call that reached Azure is counted as an attempt; one refused by the open circuit is not.
+//
+// The decision rests on the snapshot read at the start of the cycle, so the cluster is read once more
+// first: an operation that finished or changed while the cycle ran is reported as finished, not aborted.
+func (c *Controller) executeAbort(ctx context.Context, status *azure.OperationStatus, now time.Time) (azure.ARMRequestIDs, error) {
+	fresh, err := c.azureClient.FetchClusterSnapshot(ctx)
+	if err != nil {
+		return azure.ARMRequestIDs{}, fmt.Errorf("failed to read the cluster before aborting: %w", err)
+	}
+	if fresh.ProvisioningState != status.Status {
+		return azure.ARMRequestIDs{}, fmt.Errorf("%w: the cluster is now %s", azure.ErrOperationFinished, fresh.ProvisioningState)
+	}
+
+	request, err := c.abortOperation(ctx)
+	switch {
+	case errors.Is(err, azure.ErrCircuitOpen):
+	case errors.Is(err, azure.ErrOperationFinished):
+		c.exporter.RecordAbortAttempt(status.OperationType, exporter.AbortOperationFinished)
+	case err != nil:
+		c.exporter.RecordAbortAttempt(status.OperationType, exporter.AbortFailed)
+	default:
+		c.exporter.RecordAbortAttempt(status.OperationType, exporter.AbortSucceeded)
+	}
+	if err != nil {
+		return request, err
+	}
+	c.exporter.RecordAbort(ctx, status.OperationType)
+
+	c.mu.Lock()
+	c.lastAbortAt = now
+	c.cooldown = abortCooldown{operation: status.OperationType, since: now}
+	c.mu.Unlock()
+	return request, nil
+}
+
+// evaluateThresholds checks if any metrics exceed their configured thresholds. Metrics whose threshold is
+// disabled are not evaluated, and those whose window does not include the current phase are skipped with
+// a reason. When progress scaling or a baseline moves the abort threshold, the warn threshold moves with
+// it, keeping the configured margin below the abort threshold, so a warning still means the metric is
+// close to an abort. Evaluations are sorted by metric.
+func (c *Controller) evaluateThresholds(cfg *config.Config, thresholds config.ThresholdsConfig, collectedMetrics []metrics.MetricValue, phase operationPhase, baseline *OperationBaseline) []ThresholdEvaluation {
+	evaluations := make([]ThresholdEvaluation, 0, len(collectedMetrics))
+	var warnTier *config.ThresholdsConfig
+	if thresholds.Warn != nil {
+		warn := thresholds.WarnTier()
+		warnTier = &warn
+	}
 
 	for _, metric := range collectedMetrics {
-		threshold := c.getThresholdForMetric(metric.Type)
+		if metric.Type.IsInformational() {
+			continue
+		}
+		if metric.Type == metrics.OperationErrorDetectedMetric && !cfg.OperationErrors.TreatAsViolation {
+			continue
+		}
+		threshold := c.getThresholdForMetric(thresholds, metric.Type)
+		if threshold == config.ThresholdDisabled {
+			klog.V(2).Infof("Skipping metric %s: threshold disabled", metric.Type)
+			continue
+		}
+		configured := threshold
+		evaluation := ThresholdEvaluation{Metric: metric.Type, Value: metric.Value, Fraction: metric.Fraction, Threshold: threshold}
+		if warnTier != nil {
+			if warn := c.getThresholdForMetric(*warnTier, metric.Type); warn != config.ThresholdDisabled {
+				evaluation.WarnThreshold = &warn
+			}
+		}
+
+		// During upgrades a progress-scaled metric replaces its configured threshold with the interpolated one
+		if scaling, ok := cfg.ProgressScaling[string(metric.Type)]; ok && phase.upgrading {
+			threshold, evaluation.Scaling = scaledThreshold(metric.Type, scaling, phase)
+			evaluation.Threshold = threshold
+		}
+
+		// A metric judged against the baseline only violates once it rose more than its delta above it
+		if relative, value, ok := baselineThreshold(cfg, baseline, metric.Type); ok {
+			threshold = relative
+			evaluation.Threshold = threshold
+			evaluation.Baseline = &value
+			evaluation.Scaling = ""
+		}
+
+		if evaluation.WarnThreshold != nil && threshold != configured {
+			warn := shiftedWarnThreshold(*evaluation.WarnThreshold, configured, threshold)
+			evaluation.WarnThreshold = &warn
+		}
+
+		if window, ok := cfg.MetricWindows[string(metric.Type)]; ok {
+			if reason := windowSkipReason(window, phase); reason != "" {
+				klog.V(2).Infof("Skipping metric %s: %s", metric.Type, reason)
+				evaluation.SkipReason = reason
+				evaluations = append(evaluations, evaluation)
+				continue
+			}
+		}
+
 		if metric.Value > threshold {
-			violation := fmt.Sprintf("%s: %d > %d", metric.Type, metric.Value, threshold)
-			violations = append(violations, violation)
-			klog.Warningf("Threshold violation: %s", violation)
+			evaluation.Violated = true
+			evaluation.Severity = SeverityAbort
+			evaluation.Violation = fmt.Sprintf("%s: %s > %s", metric.Type, metric, metric.Type.FormatValue(threshold))
+			if evaluation.Baseline != nil {
+				evaluation.Violation = fmt.Sprintf("%s (baseline %s)", evaluation.Violation, metric.Type.FormatValue(*evaluation.Baseline))
+			}
+			if len(metric.Offenders) > 0 {
+				evaluation.Violation = fmt.Sprintf("%s (%s)", evaluation.Violation, strings.Join(metric.Offenders, "; "))
+			}
+			if guidance, ok := cfg.ThresholdGuidance[string(metric.Type)]; ok {
+				evaluation.Description = renderGuidance(guidance, metric, threshold)
+				evaluation.RunbookURL = guidance.RunbookURL
+			}
+			klog.Warningf("Threshold violation: %s", evaluation.Violation)
+		} else if evaluation.WarnThreshold != nil && metric.Value > *evaluation.WarnThreshold {
+			// Crossing only the warn tier is reported but never counts toward an abort
+			evaluation.Severity = SeverityWarn
+			evaluation.Warning = fmt.Sprintf("%s: %s > %s (warn)", metric.Type, metric, metric.Type.FormatValue(*evaluation.WarnThreshold))
+			klog.Warningf("Threshold warning: %s", evaluation.Warning)
 		} else {
-			klog.V(2).Infof("Metric %s: %d <= %d (OK)", metric.Type, metric.Value, threshold)
+			klog.V(2).Infof("Metric %s: %s <= %s (OK)", metric.Type, metric, metric.Type.FormatValue(threshold))
 		}
+		evaluations = append(evaluations, evaluation)
 	}
 
-	return violations
+	// Collectors finish in any order; evaluate, and so report, metrics in a canonical one
+	sort.SliceStable(evaluations, func(i, j int) bool { return evaluations[i].Metric < evaluations[j].Metric })
+	return evaluations
+}
+
+// shiftedWarnThreshold moves a warn threshold by as much as its abort threshold moved from configured to
+// effective. It does not go below 0, where any value above it would warn.
+func shiftedWarnThreshold(warn, configured, effective int) int {
+	warn += effective - configured
+	if warn < 0 {
+		return 0
+	}
+	return warn
+}
+
+// renderGuidance renders the description of a violated metric. Templates are validated when the
+// configuration is loaded, so a failure here is unexpected; the unrendered description is used instead.
+func renderGuidance(guidance config.ThresholdGuidance, metric metrics.MetricValue, threshold int) string {
+	description, err := guidance.Render(config.ViolationDetails{
+		Value:     metric.String(),
+		Threshold: metric.Type.FormatValue(threshold),
+		Offenders: strings.Join(metric.Offenders, "; "),
+	})
+	if err != nil {
+		klog.Errorf("Failed to render thresholdGuidance description of %s: %v", metric.Type, err)
+		return guidance.Description
+	}
+	return description
 }
 
 // getThresholdForMetric returns the configured threshold for a specific metric type
-func (c *Controller) getThresholdForMetric(metricType metrics.MetricType) int {
+func (c *Controller) getThresholdForMetric(thresholds config.ThresholdsConfig, metricType metrics.MetricType) int {
 	switch metricType {
 	case metrics.CrashingPodsPercentMetric:
-		return c.config.Thresholds.CrashingPodsPercent
+		return thresholds.CrashingPodsPercent
+	case metrics.ImagePullErrorsPercentMetric:
+		return thresholds.ImagePullErrorsPercent
 	case metrics.PendingPodsPercentMetric:
-		return c.config.Thresholds.PendingPodsPercent
+		return thresholds.PendingPodsPercent
 	case metrics.NotReadyNodesPercentMetric:
-		return c.config.Thresholds.NotReadyNodesPercent
+		return thresholds.NotReadyNodesPercent
+	case metrics.MemoryPressureNodesPercentMetric:
+		return thresholds.MemoryPressureNodesPercent
+	case metrics.DiskPressureNodesPercentMetric:
+		return thresholds.DiskPressureNodesPercent
+	case metrics.PIDPressureNodesPercentMetric:
+		return thresholds.PIDPressureNodesPercent
+	case metrics.NetworkUnavailableNodesPercentMetric:
+		return thresholds.NetworkUnavailableNodesPercent
+	case metrics.DaemonSetNotReadyPercentMetric:
+		return thresholds.DaemonSetNotReadyPercent
+	case metrics.OOMKilledPodsMetric:
+		return thresholds.OOMKilledPods
 	case metrics.FailedJobsMetric:
-		return c.config.Thresholds.FailedJobs
+		return thresholds.FailedJobs
 	case metrics.RestartCountMetric:
-		return c.config.Thresholds.RestartCount
+		return thresholds.RestartCount
+	case metrics.RestartRateMetric:
+		return thresholds.RestartRate
+	case metrics.FlappingPodsMetric:
+		return thresholds.FlappingPods
 	case metrics.CpuUsagePercentMetric:
-		return c.config.Thresholds.CpuUsagePercent
+		return thresholds.CpuUsagePercent
 	case metrics.MemoryUsagePercentMetric:
-		return c.config.Thresholds.MemoryUsagePercent
+		return thresholds.MemoryUsagePercent
+	case metrics.OperationErrorDetectedMetric:
+		// Only evaluated with operationErrors.treatAsViolation, where any provisioning error violates
+		return 0
+	case metrics.SuspectedStuckOperationMetric:
+		// Only reported with stuckOperation.treatAsViolation, where any suspected stuck operation violates
+		return 0
+	case metrics.WorkloadsBlockedByAdmissionMetric:
+		return thresholds.WorkloadsBlockedByAdmission
+	case metrics.CpuRequestsSaturationPercentMetric:
+		return thresholds.CpuRequestsSaturationPercent
+	case metrics.MemoryRequestsSaturationPercentMetric:
+		return thresholds.MemoryRequestsSaturationPercent
+	case metrics.P95SchedulingLatencySecondsMetric:
+		return thresholds.P95SchedulingLatencySeconds
+	case metrics.WorkloadsLosingZoneRedundancyMetric:
+		return thresholds.WorkloadsLosingZoneRedundancy
+	case metrics.PodChurnPerMinuteMetric:
+		return thresholds.PodChurnPerMinute
+	case metrics.SuspectedNodeReimageLoopsMetric:
+		return thresholds.SuspectedNodeReimageLoops
+	case metrics.NewPodsCrashingPercentMetric:
+		return thresholds.NewPodsCrashingPercent
+	case metrics.NewPodsPendingPercentMetric:
+		return thresholds.NewPodsPendingPercent
+	case metrics.MaxNodeCpuUsagePercentMetric:
+		return thresholds.MaxNodeCpuUsagePercent
+	case metrics.MaxNodeMemoryUsagePercentMetric:
+		return thresholds.MaxNodeMemoryUsagePercent
+	case metrics.ConfigReferenceFailuresMetric:
+		return thresholds.ConfigReferenceFailures
 	default:
 		klog.Warningf("Unknown metric type: %s, using default threshold of 0", metricType)
 		return 0
 	}
 }
 
+// coverageGap describes why the collection is incomplete
+func coverageGap(coverage metrics.Coverage) string {
+	var gaps []string
+	if !coverage.RotationComplete {
+		gaps = append(gaps, fmt.Sprintf("pod metrics cover %d/%d namespaces, waiting for a full rotation",
+			coverage.NamespacesSampled, coverage.NamespacesTotal))
+	}
+	for _, skipped := range coverage.Skipped {
+		gaps = append(gaps, fmt.Sprintf("%s collector skipped (%s)", skipped.Name, skipped.Reason))
+	}
+	return strings.Join(gaps, "; ")
+}
+
+// abortSuppression applies policies that can downgrade an abort to an alert.
+// It returns an empty reason when the abort may proceed.
+func (c *Controller) abortSuppression(cfg *config.Config, status *azure.OperationStatus) (SuppressionReason, string) {
+	if status.Attribution != nil {
+		attribution := status.Attribution
+		klog.Infof("Operation '%s' attributed to %s (confidence %s): %s",
+			status.OperationType, attribution.Initiator, attribution.Confidence, attribution.Evidence)
+
+		if attribution.Initiator == azure.InitiatorAutoscaler && !cfg.ScaleOperations.AbortAutoscalerInitiated {
+			return SuppressionAutoscalerInitiated, fmt.Sprintf("scale operation attributed to %s (confidence %s) and scaleOperations.abortAutoscalerInitiated is false",
+				attribution.Initiator, attribution.Confidence)
+		}
+	}
+
+	return "", ""
+}
+
+// operationErrorMetric converts the presence of ARM provisioning errors into a 0/1 metric
+func operationErrorMetric(status *azure.OperationStatus) metrics.MetricValue {
+	value := 0
+	if status.HasErrors() {
+		value = 1
+	}
+	return metrics.MetricValue{Type: metrics.OperationErrorDetectedMetric, Value: value}
+}
+
+// clusterSnapshot returns the cluster as read at the start of the cycle, so phase, safety and the other
+// consumers do not each read it again. Only a status from Resource Graph carries none; the cluster is then
+// read once and attached to the status for the rest of the cycle.
+func (c *Controller) clusterSnapshot(ctx context.Context, status *azure.OperationStatus) (*azure.ClusterSnapshot, error) {
+	if status.Snapshot == nil {
+		snapshot, err := c.azureClient.FetchClusterSnapshot(ctx)
+		if err != nil {
+			return nil, err
+		}
+		status.Snapshot = snapshot
+	}
+	return status.Snapshot, nil
+}
+
 // abortOperation aborts the current AKS operation
-func (c *Controller) abortOperation(ctx context.Context) error {
+func (c *Controller) abortOperation(ctx context.Context) (azure.ARMRequestIDs, error) {
 	klog.Warningf("Aborting operation '%s' due to health check failures", c.currentOperation)
 
 	return c.azureClient.AbortClusterOperation(ctx, c.currentOperation)
@@ -151,10 +872,122 @@ func (c *Controller) abortOperation(ctx context.Context) error {
 
 // GetStatus returns the current status of the controller
 func (c *Controller) GetStatus() map[string]interface{} {
-	return map[string]interface{}{
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	status := map[string]interface{}{
 		"operationInProgress": c.operationInProgress,
 		"currentOperation":    c.currentOperation,
-		"pollInterval":        c.config.PollInterval,
-		"thresholds":          c.config.Thresholds,
+		"operationErrors":     c.operationErrors,
+		"pollInterval":        c.cfg().PollInterval,
+		"thresholds":          c.cfg().Thresholds,
+		"configGeneration":    c.configGeneration,
+		"controller":          c.identity,
+		"mode":                "full",
+		"dryRun":              c.cfg().DryRun || c.override != nil,
+		"dryRunAborts":        c.dryRunAborts,
+	}
+
+	if c.baseline != nil {
+		status["baseline"] = c.baseline
+	}
+
+	if c.leader != nil {
+		status["leaderElection"] = c.leader
+	}
+
+	if c.metricsOnlyReason != "" {
+		status["mode"] = "metricsOnly"
+		status["metricsOnlyReason"] = c.metricsOnlyReason
+	}
+
+	if !c.operationStartedAt.IsZero() {
+		status["operationStartedAt"] = c.operationStartedAt
+	}
+
+	if c.progress.stuck != nil {
+		status["suspectedStuck"] = c.progress.stuck
+	}
+
+	if c.initiator.initiator != nil {
+		status["operationInitiator"] = c.initiator.initiator
+	}
+
+	if c.lastConfigChange != nil {
+		status["lastConfigChange"] = c.lastConfigChange
+	}
+
+	if !c.lastAbortAt.IsZero() {
+		status["lastAbortTime"] = c.lastAbortAt
+	}
+	if len(c.auditTrail) > 0 {
+		status["auditTrail"] = c.auditTrail
+	}
+
+	if !c.heartbeats.lastAt.IsZero() {
+		status["lastHeartbeat"] = c.heartbeats.lastAt
+	}
+	status["cooldownActive"] = c.cooldown.active(c.cfg(), time.Now())
+	if c.override != nil {
+		status["override"] = *c.override
+	}
+	if c.permission.status != nil {
+		status["abortPermission"] = *c.permission.status
+	}
+
+	if len(c.violationStreaks.counts) > 0 {
+		status["violationStreaks"] = c.violationStreaks.snapshot()
+	}
+
+	if len(c.maintenance.windows) > 0 {
+		status["maintenanceWindows"] = c.maintenance.windows
+	}
+
+	if c.cadence.status.Samples > 0 {
+		status["cadence"] = c.cadence.status
+	}
+
+	status["namespaces"] = c.metricsCollector.Namespaces()
+
+	if warnings := c.metricsCollector.APIWarnings(); len(warnings) > 0 {
+		status["apiWarnings"] = warnings
+	}
+
+	// Metrics of disabled collectors are missing, not zero
+	if disabled := c.metricsCollector.DisabledCollectors(); len(disabled) > 0 {
+		status["disabledCollectors"] = disabled
+	}
+	status["collectors"] = c.metricsCollector.CollectorStatuses()
+
+	if last, ok := c.history.last(); ok {
+		status["lastResult"] = last
+		status["lastSuppressionReason"] = last.SuppressionReason
+		status["lastCheckTime"] = last.Timestamp
+		status["violations"] = last.Violations
+		status["thresholdTiers"] = thresholdTiers(last.Evaluations)
+	}
+	if c.lastCheckError != "" {
+		status["lastError"] = c.lastCheckError
+	}
+	status["consecutiveFailures"] = c.consecutiveFailures
+
+	return status
+}
+
+// GetConfig returns the configuration in effect
+func (c *Controller) GetConfig() *config.Config {
+	return c.cfg()
+}
+
+// GetHistory returns the recent health check results, oldest first. With history storage configured they
+// are read from the backend, falling back to the in-memory history if it cannot be read.
+func (c *Controller) GetHistory() []HealthCheckResult {
+	if store := c.resultStore(); store != nil {
+		results, err := c.storedHistory(store)
+		if err == nil {
+			return results
+		}
+		klog.Warningf("Failed to read history storage, serving in-memory history: %v", err)
 	}
+	return c.history.list()
 }
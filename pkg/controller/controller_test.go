@@ -0,0 +1,713 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/azure/azuretest"
+	"aks-health-monitor/pkg/azure/fake"
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/metrics"
+	"aks-health-monitor/pkg/runtimeinfo"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v2"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// testConfig returns the default configuration, as loaded without a file
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	for key, value := range map[string]string{
+		"AZURE_SUBSCRIPTION_ID": "00000000-0000-0000-0000-000000000000",
+		"AZURE_RESOURCE_GROUP":  "rg",
+		"AZURE_CLUSTER_NAME":    "cluster",
+		"AZURE_TENANT_ID":       "tenant",
+		"AZURE_CLIENT_ID":       "client",
+		"AZURE_CLIENT_SECRET":   "secret",
+	} {
+		t.Setenv(key, value)
+	}
+	cfg, err := config.LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// newTestController creates a controller over a fake clientset holding objects and a fake Azure client
+func newTestController(t *testing.T, cfg *config.Config, objects ...runtime.Object) (*Controller, *fake.Client) {
+	t.Helper()
+	return newTestControllerWithClient(t, cfg, kubefake.NewSimpleClientset(objects...))
+}
+
+// newTestControllerWithClient creates a controller over kubeClient and a fake Azure client
+func newTestControllerWithClient(t *testing.T, cfg *config.Config, kubeClient kubernetes.Interface) (*Controller, *fake.Client) {
+	t.Helper()
+	azureClient := fake.NewClient()
+	c, err := NewController(kubeClient, metrics.NewCollector(kubeClient, cfg.Collection), azureClient, cfg,
+		prometheus.NewRegistry(), &runtimeinfo.Info{Namespace: "aks-monitor", PodName: "aks-monitor-0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, azureClient
+}
+
+// newARMTestController creates a controller over a clientset holding objects and a client of a fake ARM
+// server, which it returns to count the requests the controller sends
+func newARMTestController(t *testing.T, cfg *config.Config, objects ...runtime.Object) (*Controller, *azuretest.Server) {
+	t.Helper()
+	server := azuretest.NewServer()
+	t.Cleanup(server.Close)
+	azureClient, err := server.NewClient(cfg.Azure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kubeClient := kubefake.NewSimpleClientset(objects...)
+	c, err := NewController(kubeClient, metrics.NewCollector(kubeClient, cfg.Collection), azureClient, cfg,
+		prometheus.NewRegistry(), &runtimeinfo.Info{Namespace: "aks-monitor", PodName: "aks-monitor-0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, server
+}
+
+// clusterReads counts the managed cluster GETs the server received
+func clusterReads(server *azuretest.Server) int {
+	reads := 0
+	for _, request := range server.Requests() {
+		if request.Method == http.MethodGet && strings.HasSuffix(strings.ToLower(request.Path), "/managedclusters/cluster") {
+			reads++
+		}
+	}
+	return reads
+}
+
+// node returns a node whose Ready condition has the given status
+func node(name string, ready corev1.ConditionStatus) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}},
+		},
+	}
+}
+
+// unreadyNodes returns count nodes that are not ready, violating the default notReadyNodesPercent
+func unreadyNodes(count int) []runtime.Object {
+	nodes := make([]runtime.Object, 0, count)
+	for i := 0; i < count; i++ {
+		nodes = append(nodes, node(fmt.Sprintf("node-%d", i), corev1.ConditionFalse))
+	}
+	return nodes
+}
+
+func TestPerOperationGracePeriod(t *testing.T) {
+	tests := []struct {
+		name       string
+		global     time.Duration
+		operation  string
+		wantAction Action
+		wantGrace  bool
+	}{
+		{name: "global grace period", global: 10 * time.Minute, operation: "- upgrade", wantAction: ActionNone, wantGrace: true},
+		{name: "per-operation grace period", operation: "- name: upgrade\n  gracePeriod: 10m", wantAction: ActionNone, wantGrace: true},
+		{name: "per-operation zero overrides the global", global: 10 * time.Minute, operation: "- name: upgrade\n  gracePeriod: 0s", wantAction: ActionAborted},
+		{name: "no grace period", operation: "- upgrade", wantAction: ActionAborted},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.OperationGracePeriod = test.global
+			if err := yaml.Unmarshal([]byte(test.operation), &cfg.MonitoredOperations); err != nil {
+				t.Fatal(err)
+			}
+			c, azureClient := newTestController(t, cfg, unreadyNodes(2)...)
+			azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+
+			result := c.runCycle(context.Background())
+			if result.Error != "" {
+				t.Fatalf("cycle failed: %s", result.Error)
+			}
+			if result.Action != test.wantAction {
+				t.Errorf("action = %s, want %s (violations %v)", result.Action, test.wantAction, result.Violations)
+			}
+			if (result.GraceRemaining > 0) != test.wantGrace {
+				t.Errorf("graceRemaining = %s, want within the grace period: %v", result.GraceRemaining, test.wantGrace)
+			}
+			if test.wantGrace && len(result.Metrics) == 0 {
+				t.Error("metrics were not collected within the grace period")
+			}
+		})
+	}
+}
+
+// metricValue returns the value of the metric in the result, and whether it was reported
+func metricValue(result HealthCheckResult, metricType metrics.MetricType) (int, bool) {
+	for _, metric := range result.Metrics {
+		if metric.Type == metricType {
+			return metric.Value, true
+		}
+	}
+	return 0, false
+}
+
+func TestOperationErrorsReportedEveryCycle(t *testing.T) {
+	quotaError := []azure.ProvisioningError{{Source: "cluster", Code: "QuotaExceeded", Message: "quota exceeded"}}
+	withErrors := func(status *azure.OperationStatus) *azure.OperationStatus {
+		status.Errors = quotaError
+		return status
+	}
+
+	tests := []struct {
+		name             string
+		status           *azure.OperationStatus
+		treatAsViolation bool
+		wantValue        int
+		wantAction       Action
+	}{
+		{name: "idle", status: fake.Idle(), wantValue: 0, wantAction: ActionNone},
+		{name: "failed cluster", status: withErrors(&azure.OperationStatus{Status: "Failed", Source: config.StatusSourceARM}), wantValue: 1, wantAction: ActionNone},
+		{name: "failed cluster treated as violation", status: withErrors(&azure.OperationStatus{Status: "Failed", Source: config.StatusSourceARM}), treatAsViolation: true, wantValue: 1, wantAction: ActionNone},
+		{name: "unmonitored operation", status: withErrors(fake.InProgress("Creating")), wantValue: 1, wantAction: ActionNone},
+		{name: "monitored operation", status: withErrors(fake.InProgress("Upgrading")), wantValue: 1, wantAction: ActionNone},
+		{name: "monitored operation treated as violation", status: withErrors(fake.InProgress("Upgrading")), treatAsViolation: true, wantValue: 1, wantAction: ActionAborted},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.OperationErrors.TreatAsViolation = test.treatAsViolation
+			c, azureClient := newTestController(t, cfg, node("node-0", corev1.ConditionTrue))
+			azureClient.SetOperationStatuses(test.status)
+
+			result := c.runCycle(context.Background())
+			if result.Error != "" {
+				t.Fatalf("cycle failed: %s", result.Error)
+			}
+			value, ok := metricValue(result, metrics.OperationErrorDetectedMetric)
+			if !ok {
+				t.Fatalf("%s not reported, metrics: %v", metrics.OperationErrorDetectedMetric, result.Metrics)
+			}
+			if value != test.wantValue {
+				t.Errorf("%s = %d, want %d", metrics.OperationErrorDetectedMetric, value, test.wantValue)
+			}
+			if result.Action != test.wantAction {
+				t.Errorf("action = %s, want %s (violations %v)", result.Action, test.wantAction, result.Violations)
+			}
+		})
+	}
+}
+
+// pendingPod returns a pod in the Pending phase
+func pendingPod(namespace, name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, CreationTimestamp: metav1.Now()},
+		Status:     corev1.PodStatus{Phase: corev1.PodPending},
+	}
+}
+
+func TestFailedCollectorKeepsOtherMetrics(t *testing.T) {
+	kubeClient := kubefake.NewSimpleClientset(pendingPod("default", "web"))
+	kubeClient.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewServiceUnavailable("etcd leader changed")
+	})
+	c, azureClient := newTestControllerWithClient(t, testConfig(t), kubeClient)
+	azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+
+	result := c.runCycle(context.Background())
+
+	if !strings.Contains(result.Error, "nodes") {
+		t.Errorf("error = %q, want the failed nodes collector", result.Error)
+	}
+	if value, ok := metricValue(result, metrics.PendingPodsPercentMetric); !ok || value != 100 {
+		t.Errorf("pending pods = %d (reported %v), want the pods collector's 100%%", value, ok)
+	}
+	if len(result.Violations) == 0 {
+		t.Error("the pods collector's metrics were not evaluated")
+	}
+	if result.Action != ActionSuppressed || result.SuppressionReason != SuppressionIncompleteData {
+		t.Errorf("action = %s (%s), want suppressed as %s", result.Action, result.SuppressionReason, SuppressionIncompleteData)
+	}
+	if len(azureClient.Aborts()) != 0 {
+		t.Errorf("aborted %v on incomplete data", azureClient.Aborts())
+	}
+}
+
+func TestImagePullErrorsThreshold(t *testing.T) {
+	tests := []struct {
+		name          string
+		crashing      int
+		imagePull     int
+		wantViolation bool
+	}{
+		{name: "own threshold violated", crashing: config.ThresholdDisabled, imagePull: 10, wantViolation: true},
+		{name: "own threshold disabled", crashing: 10, imagePull: config.ThresholdDisabled},
+		{name: "own threshold not reached", crashing: 10, imagePull: 100},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.Thresholds.CrashingPodsPercent = test.crashing
+			cfg.Thresholds.ImagePullErrorsPercent = test.imagePull
+			cfg.Thresholds.PendingPodsPercent = config.ThresholdDisabled
+			pod := pendingPod("default", "web")
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "web", State: corev1.ContainerState{
+				Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"},
+			}}}
+			c, azureClient := newTestController(t, cfg, pod)
+			azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+
+			result := c.runCycle(context.Background())
+			if value, ok := metricValue(result, metrics.ImagePullErrorsPercentMetric); !ok || value != 100 {
+				t.Fatalf("image pull errors = %d (reported %v), want 100", value, ok)
+			}
+			violated := false
+			for _, violation := range result.Violations {
+				if strings.Contains(violation, string(metrics.ImagePullErrorsPercentMetric)) {
+					violated = true
+				}
+				if strings.Contains(violation, string(metrics.CrashingPodsPercentMetric)) {
+					t.Errorf("violation %q, want image pull errors kept out of crashing pods", violation)
+				}
+			}
+			if violated != test.wantViolation {
+				t.Errorf("image pull errors violated = %v, want %v (violations %v)", violated, test.wantViolation, result.Violations)
+			}
+		})
+	}
+}
+
+func TestChunkedCollectionAbortsOnlyOnceRotationCompletes(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Collection.Mode = config.CollectionModeChunked
+	cfg.Collection.RotationLength = 2
+	objects := []runtime.Object{node("node-0", corev1.ConditionTrue)}
+	for _, namespace := range []string{"team-a", "team-b"} {
+		objects = append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}, pendingPod(namespace, "web"))
+	}
+	c, azureClient := newTestController(t, cfg, objects...)
+	azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+
+	result := c.runCycle(context.Background())
+	if result.Action != ActionSuppressed || result.SuppressionReason != SuppressionIncompleteData {
+		t.Errorf("first cycle action = %s (%s), want suppressed as %s mid-rotation", result.Action, result.SuppressionReason, SuppressionIncompleteData)
+	}
+
+	result = c.runCycle(context.Background())
+	if result.Action != ActionAborted {
+		t.Errorf("second cycle action = %s (%s: %s), want aborted once the rotation completes", result.Action, result.SuppressionReason, result.SuppressionDetail)
+	}
+}
+
+func TestNodePressureThresholds(t *testing.T) {
+	tests := []struct {
+		condition corev1.NodeConditionType
+		metric    metrics.MetricType
+		disable   func(*config.ThresholdsConfig)
+	}{
+		{corev1.NodeMemoryPressure, metrics.MemoryPressureNodesPercentMetric, func(thresholds *config.ThresholdsConfig) {
+			thresholds.MemoryPressureNodesPercent = config.ThresholdDisabled
+		}},
+		{corev1.NodeDiskPressure, metrics.DiskPressureNodesPercentMetric, func(thresholds *config.ThresholdsConfig) {
+			thresholds.DiskPressureNodesPercent = config.ThresholdDisabled
+		}},
+		{corev1.NodePIDPressure, metrics.PIDPressureNodesPercentMetric, func(thresholds *config.ThresholdsConfig) {
+			thresholds.PIDPressureNodesPercent = config.ThresholdDisabled
+		}},
+		{corev1.NodeNetworkUnavailable, metrics.NetworkUnavailableNodesPercentMetric, func(thresholds *config.ThresholdsConfig) {
+			thresholds.NetworkUnavailableNodesPercent = config.ThresholdDisabled
+		}},
+	}
+
+	for _, test := range tests {
+		for _, disabled := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/disabled=%v", test.condition, disabled), func(t *testing.T) {
+				cfg := testConfig(t)
+				if disabled {
+					test.disable(&cfg.Thresholds)
+				}
+				// Both nodes are Ready, so only the pressure metric can be violated
+				pressured := node("node-1", corev1.ConditionTrue)
+				pressured.Status.Conditions = append(pressured.Status.Conditions, corev1.NodeCondition{Type: test.condition, Status: corev1.ConditionTrue})
+				c, azureClient := newTestController(t, cfg, node("node-0", corev1.ConditionTrue), pressured)
+				azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+
+				result := c.runCycle(context.Background())
+				if result.Error != "" {
+					t.Fatalf("cycle failed: %s", result.Error)
+				}
+				if value, ok := metricValue(result, test.metric); !ok || value != 50 {
+					t.Errorf("%s = %d (reported %v), want 50", test.metric, value, ok)
+				}
+				var violated []metrics.MetricType
+				for _, evaluation := range result.Evaluations {
+					if evaluation.Violated {
+						violated = append(violated, evaluation.Metric)
+					}
+				}
+				wantAction, wantViolated := ActionAborted, []metrics.MetricType{test.metric}
+				if disabled {
+					wantAction, wantViolated = ActionNone, nil
+				}
+				if fmt.Sprint(violated) != fmt.Sprint(wantViolated) {
+					t.Errorf("violated = %v, want %v", violated, wantViolated)
+				}
+				if result.Action != wantAction {
+					t.Errorf("action = %s, want %s", result.Action, wantAction)
+				}
+			})
+		}
+	}
+}
+
+// startedAgo moves the observed start of the current operation to d ago
+func startedAgo(c *Controller, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.operationStartedAt = time.Now().Add(-d)
+}
+
+func TestMetricWindows(t *testing.T) {
+	tests := []struct {
+		name    string
+		window  config.MetricWindow
+		started time.Duration
+		// upgraded is how many of the two nodes already run the target version
+		upgraded   int
+		wantAction Action
+		wantSkip   string
+	}{
+		{name: "before appliesAfter", window: config.MetricWindow{AppliesAfter: 10 * time.Minute}, started: 5 * time.Minute,
+			wantAction: ActionNone, wantSkip: "applies 10m0s after operation start, operation started 5m0s ago"},
+		{name: "after appliesAfter", window: config.MetricWindow{AppliesAfter: 10 * time.Minute}, started: 15 * time.Minute,
+			wantAction: ActionAborted},
+		{name: "before a duration appliesBefore", window: config.MetricWindow{AppliesBefore: "45m"}, started: 30 * time.Minute,
+			wantAction: ActionAborted},
+		{name: "after a duration appliesBefore", window: config.MetricWindow{AppliesBefore: "45m"}, started: 50 * time.Minute,
+			wantAction: ActionNone, wantSkip: "applies until 45m0s after operation start, operation started 50m0s ago"},
+		{name: "before a percent-complete appliesBefore", window: config.MetricWindow{AppliesBefore: "90%Complete"}, upgraded: 1,
+			wantAction: ActionAborted},
+		{name: "after a percent-complete appliesBefore", window: config.MetricWindow{AppliesBefore: "50%Complete"}, upgraded: 1,
+			wantAction: ActionNone, wantSkip: "applies until 50% complete, operation is 50% complete"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.MetricWindows = map[string]config.MetricWindow{string(metrics.NotReadyNodesPercentMetric): test.window}
+			nodes := unreadyNodes(2)
+			for i, object := range nodes {
+				object.(*corev1.Node).Status.NodeInfo.KubeletVersion = "v1.28.5"
+				if i < test.upgraded {
+					object.(*corev1.Node).Status.NodeInfo.KubeletVersion = "v1.29.0"
+				}
+			}
+			c, azureClient := newTestController(t, cfg, nodes...)
+			azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+			azureClient.SetVersions("1.29.0", "1.28.5")
+			c.mu.Lock()
+			c.operationInProgress, c.currentOperation = true, "Upgrading"
+			c.mu.Unlock()
+			startedAgo(c, test.started)
+
+			result := c.runCycle(context.Background())
+			if result.Error != "" {
+				t.Fatalf("cycle failed: %s", result.Error)
+			}
+			if result.Action != test.wantAction {
+				t.Errorf("action = %s, want %s (violations %v)", result.Action, test.wantAction, result.Violations)
+			}
+			var skip string
+			for _, skipped := range result.Skipped {
+				if skipped.Type == metrics.NotReadyNodesPercentMetric {
+					skip = skipped.Reason
+				}
+			}
+			if skip != test.wantSkip {
+				t.Errorf("skip reason = %q, want %q", skip, test.wantSkip)
+			}
+		})
+	}
+}
+
+func TestGraceWindowThenViolation(t *testing.T) {
+	const grace = 10 * time.Minute
+	cfg := testConfig(t)
+	cfg.OperationGracePeriod = grace
+	cfg.Thresholds.PodChurnPerMinute = config.ThresholdDisabled
+	c, azureClient := newTestController(t, cfg, unreadyNodes(2)...)
+	azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+
+	// The operation starts: metrics are collected but not evaluated
+	result := c.runCycle(context.Background())
+	if result.Action != ActionNone || len(result.Evaluations) != 0 || result.GraceRemaining <= grace-time.Minute {
+		t.Fatalf("first cycle action = %s with %d evaluations and %s of grace left, want the full grace window",
+			result.Action, len(result.Evaluations), result.GraceRemaining)
+	}
+	if value, ok := metricValue(result, metrics.NotReadyNodesPercentMetric); !ok || value != 100 {
+		t.Errorf("not ready nodes = %d (reported %v), want 100 collected within the grace window", value, ok)
+	}
+
+	// A second before the window ends, the violation is still not evaluated
+	startedAgo(c, grace-time.Second)
+	result = c.runCycle(context.Background())
+	if result.Action != ActionNone || result.GraceRemaining <= 0 || result.GraceRemaining > time.Second {
+		t.Errorf("last grace cycle action = %s with %s of grace left, want no action within the final second", result.Action, result.GraceRemaining)
+	}
+
+	// Immediately after it expires, the same violation aborts
+	startedAgo(c, grace)
+	result = c.runCycle(context.Background())
+	if result.GraceRemaining != 0 || result.Action != ActionAborted {
+		t.Errorf("action = %s with %s of grace left, want aborted once the grace window expires", result.Action, result.GraceRemaining)
+	}
+	if len(azureClient.Aborts()) != 1 {
+		t.Errorf("aborted %d times, want once", len(azureClient.Aborts()))
+	}
+}
+
+func TestGraceWindowRestartsWhenOperationChanges(t *testing.T) {
+	const grace = 10 * time.Minute
+	cfg := testConfig(t)
+	cfg.OperationGracePeriod = grace
+	setMonitoredOperations(t, cfg, "[upgrade, scale]")
+	c, azureClient := newTestController(t, cfg, unreadyNodes(2)...)
+	azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+
+	c.runCycle(context.Background())
+	startedAgo(c, grace-time.Minute)
+	if result := c.runCycle(context.Background()); result.GraceRemaining > time.Minute {
+		t.Fatalf("graceRemaining = %s, want under a minute into the upgrade", result.GraceRemaining)
+	}
+
+	// The operation type changes mid-grace: the window starts over for the new operation
+	azureClient.SetOperationStatuses(fake.InProgress("Scaling"))
+	result := c.runCycle(context.Background())
+	if result.Action != ActionNone || result.GraceRemaining <= grace-time.Minute {
+		t.Errorf("action = %s with %s of grace left, want the full window restarted for Scaling", result.Action, result.GraceRemaining)
+	}
+	if len(azureClient.Aborts()) != 0 {
+		t.Errorf("aborted %v within the grace window", azureClient.Aborts())
+	}
+}
+
+func TestDaemonSetNotReadyThreshold(t *testing.T) {
+	tests := []struct {
+		name       string
+		desired    int32
+		ready      int32
+		threshold  int
+		wantValue  int
+		wantAction Action
+	}{
+		{name: "new nodes without kube-proxy", desired: 4, ready: 2, threshold: 10, wantValue: 50, wantAction: ActionAborted},
+		{name: "all ready", desired: 4, ready: 4, threshold: 10, wantValue: 0, wantAction: ActionNone},
+		{name: "scheduled nowhere", desired: 0, ready: 0, threshold: 0, wantValue: 0, wantAction: ActionNone},
+		{name: "disabled", desired: 4, ready: 0, threshold: config.ThresholdDisabled, wantValue: 100, wantAction: ActionNone},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.Collection.DaemonSets.Namespace = "kube-system"
+			cfg.Thresholds.DaemonSetNotReadyPercent = test.threshold
+			kubeClient := kubefake.NewSimpleClientset(node("node-0", corev1.ConditionTrue), &appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "kube-proxy"},
+				Status:     appsv1.DaemonSetStatus{DesiredNumberScheduled: test.desired, NumberReady: test.ready},
+			})
+			// The fake clientset serves no APIs to discovery, which would disable the daemonSets collector
+			kubeClient.Resources = []*metav1.APIResourceList{{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{{Name: "daemonsets"}}}}
+			c, azureClient := newTestControllerWithClient(t, cfg, kubeClient)
+			azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+
+			result := c.runCycle(context.Background())
+			if result.Error != "" {
+				t.Fatalf("cycle failed: %s", result.Error)
+			}
+			if value, ok := metricValue(result, metrics.DaemonSetNotReadyPercentMetric); !ok || value != test.wantValue {
+				t.Errorf("%s = %d (reported %v), want %d", metrics.DaemonSetNotReadyPercentMetric, value, ok, test.wantValue)
+			}
+			if result.Action != test.wantAction {
+				t.Errorf("action = %s, want %s (violations %v)", result.Action, test.wantAction, result.Violations)
+			}
+		})
+	}
+}
+
+func TestOOMKilledPodsThreshold(t *testing.T) {
+	tests := []struct {
+		name       string
+		pods       int
+		threshold  int
+		wantAction Action
+	}{
+		{name: "at the threshold", pods: 3, threshold: 3, wantAction: ActionNone},
+		{name: "above the threshold", pods: 4, threshold: 3, wantAction: ActionAborted},
+		{name: "disabled", pods: 4, threshold: config.ThresholdDisabled, wantAction: ActionNone},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.Thresholds.OOMKilledPods = test.threshold
+			objects := []runtime.Object{node("node-0", corev1.ConditionTrue)}
+			for i := 0; i < test.pods; i++ {
+				objects = append(objects, &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: fmt.Sprintf("worker-%d", i)},
+					Status: corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{{
+						State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+						LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+							ExitCode: 137, Reason: "OOMKilled", FinishedAt: metav1.Now(),
+						}},
+					}}},
+				})
+			}
+			c, azureClient := newTestController(t, cfg, objects...)
+			azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+
+			result := c.runCycle(context.Background())
+			if result.Error != "" {
+				t.Fatalf("cycle failed: %s", result.Error)
+			}
+			if value, ok := metricValue(result, metrics.OOMKilledPodsMetric); !ok || value != test.pods {
+				t.Errorf("%s = %d (reported %v), want %d", metrics.OOMKilledPodsMetric, value, ok, test.pods)
+			}
+			if result.Action != test.wantAction {
+				t.Errorf("action = %s, want %s (violations %v)", result.Action, test.wantAction, result.Violations)
+			}
+		})
+	}
+}
+
+func TestOneClusterReadPerCycle(t *testing.T) {
+	// Progress-bounded windows and abort safety both read the cluster; a dry run stops short of the abort
+	cfg := testConfig(t)
+	cfg.DryRun = true
+	cfg.Thresholds.PodChurnPerMinute = config.ThresholdDisabled
+	cfg.AbortSafety.Enabled = true
+	cfg.AbortSafety.MinSchedulableCapacityPercent = 0
+	cfg.AbortSafety.MaxKubeletMinorSkew = 2
+	cfg.MetricWindows = map[string]config.MetricWindow{string(metrics.NotReadyNodesPercentMetric): {AppliesBefore: "90%Complete"}}
+	nodes := unreadyNodes(2)
+	for _, object := range nodes {
+		object.(*corev1.Node).Status.NodeInfo.KubeletVersion = "v1.28.5"
+	}
+	c, server := newARMTestController(t, cfg, nodes...)
+	server.SetProvisioningStates("Upgrading")
+	server.SetClusterProperty("kubernetesVersion", "1.29.0")
+	server.SetClusterProperty("currentKubernetesVersion", "1.28.5")
+
+	for cycle := 1; cycle <= 3; cycle++ {
+		result := c.runCycle(context.Background())
+		if result.Error != "" {
+			t.Fatalf("cycle %d failed: %s", cycle, result.Error)
+		}
+		if result.SuppressionReason != SuppressionDryRun {
+			t.Fatalf("cycle %d suppression = %q, want %q after every other policy", cycle, result.SuppressionReason, SuppressionDryRun)
+		}
+		if reads := clusterReads(server); reads != cycle {
+			t.Errorf("cluster reads after cycle %d = %d, want %d", cycle, reads, cycle)
+		}
+	}
+}
+
+func TestAbortReadsTheClusterAgain(t *testing.T) {
+	tests := []struct {
+		name       string
+		states     []string
+		wantAction Action
+		wantReason SuppressionReason
+		wantAborts int
+	}{
+		{name: "operation still running", states: []string{"Upgrading"}, wantAction: ActionAborted, wantAborts: 1},
+		{name: "operation finished during the cycle", states: []string{"Upgrading", "Succeeded"},
+			wantAction: ActionSuppressed, wantReason: SuppressionOperationFinished},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			c, server := newARMTestController(t, cfg, unreadyNodes(2)...)
+			server.SetProvisioningStates(test.states...)
+
+			result := c.runCycle(context.Background())
+			if result.Error != "" {
+				t.Fatalf("cycle failed: %s", result.Error)
+			}
+			if result.Action != test.wantAction || result.SuppressionReason != test.wantReason {
+				t.Errorf("action = %s (%s), want %s (%s)", result.Action, result.SuppressionReason, test.wantAction, test.wantReason)
+			}
+			if reads := clusterReads(server); reads != 2 {
+				t.Errorf("cluster reads = %d, want the cycle's read and a fresh one before the abort", reads)
+			}
+			if aborts := server.CountRequests(http.MethodPost, "/abort"); aborts != test.wantAborts {
+				t.Errorf("aborts = %d, want %d", aborts, test.wantAborts)
+			}
+		})
+	}
+}
+
+func TestCadenceDrift(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Cadence = config.CadenceConfig{DriftFactor: 2, Window: 3}
+	const poll = 30 * time.Second
+	kubeClient := kubefake.NewSimpleClientset()
+	c, _ := newTestControllerWithClient(t, cfg, kubeClient)
+
+	// Cycle starts on a fake clock: on time, then slowed to 90s by long cycles, then back on time
+	start := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	steps := []struct {
+		interval     time.Duration
+		wantDrifting bool
+	}{
+		{interval: 0},
+		{interval: poll},
+		{interval: poll},
+		{interval: 90 * time.Second},
+		// (30+90+90)/3 = 70s, beyond twice the poll interval
+		{interval: 90 * time.Second, wantDrifting: true},
+		{interval: 90 * time.Second, wantDrifting: true},
+		{interval: poll, wantDrifting: true},
+		// (90+30+30)/3 = 50s
+		{interval: poll},
+	}
+	for i, step := range steps {
+		start = start.Add(step.interval)
+		c.recordCycleStart(context.Background(), start, poll)
+
+		cadence, ok := c.GetStatus()["cadence"].(CadenceStatus)
+		if i == 0 {
+			if ok {
+				t.Fatalf("cadence reported after one cycle: %+v", cadence)
+			}
+			continue
+		}
+		if !ok {
+			t.Fatalf("cycle %d: no cadence in the status", i)
+		}
+		if cadence.Drifting != step.wantDrifting || cadence.Configured != poll {
+			t.Errorf("cycle %d: cadence = %+v, want drifting %v against %s", i, cadence, step.wantDrifting, poll)
+		}
+	}
+
+	// The warning is recorded once when the drift starts, not on every drifting cycle
+	if events := eventsByReason(t, kubeClient)["CadenceDrift"]; len(events) != 1 || events[0].Count != 1 {
+		t.Errorf("CadenceDrift Events = %d (count %v), want one", len(events), eventCounts(events))
+	}
+}
@@ -0,0 +1,225 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/metrics"
+
+	"k8s.io/klog/v2"
+)
+
+// CriticalAbort records the immediate abort path, taken when a metric exceeded its critical threshold as
+// soon as its collector finished, while the remaining collectors were still running
+type CriticalAbort struct {
+	Violations []string `json:"violations"`
+
+	// TriggeredAfter is how long after the cycle started the critical violation was seen
+	TriggeredAfter time.Duration `json:"triggeredAfter"`
+
+	// Attempted is true when the abort call was made, and false when a policy suppressed it; a suppressed
+	// critical abort leaves the decision to the end of the cycle, like any other violation
+	Attempted         bool              `json:"attempted"`
+	SuppressionReason SuppressionReason `json:"suppressionReason,omitempty"`
+	SuppressionDetail string            `json:"suppressionDetail,omitempty"`
+	Error             string            `json:"error,omitempty"`
+}
+
+// criticalViolation is a metric beyond its critical threshold
+type criticalViolation struct {
+	metric    metrics.MetricType
+	violation string
+}
+
+// criticalWatch evaluates critical thresholds as collectors finish and runs the abort in the background
+// the first time one is exceeded. The background goroutine owns decision, the abort outcome and policies
+// until it closes done.
+type criticalWatch struct {
+	controller *Controller
+	ctx        context.Context
+	cfg        *config.Config
+	settings   config.OperationSettings
+	status     *azure.OperationStatus
+	phase      operationPhase
+	startedAt  time.Time
+
+	once         sync.Once
+	triggered    atomic.Bool
+	done         chan struct{}
+	violations   []criticalViolation
+	decision     CriticalAbort
+	abortRequest azure.ARMRequestIDs
+	abortErr     error
+
+	// policies collects the suppression checks of the critical path; they replace the result's when it aborted
+	policies HealthCheckResult
+}
+
+// watchCriticalThresholds prepares the critical abort path for one cycle, or returns nil when no critical
+// thresholds are configured
+func (c *Controller) watchCriticalThresholds(ctx context.Context, cfg *config.Config, settings config.OperationSettings, status *azure.OperationStatus, phase operationPhase, now time.Time) *criticalWatch {
+	if cfg.CriticalThresholds.IsZero() {
+		return nil
+	}
+	return &criticalWatch{
+		controller: c,
+		ctx:        ctx,
+		cfg:        cfg,
+		settings:   settings,
+		status:     status,
+		phase:      phase,
+		startedAt:  now,
+		done:       make(chan struct{}),
+		policies:   HealthCheckResult{Timestamp: now},
+	}
+}
+
+// observer returns the callback passed to the collector, or nil when there is nothing to watch
+func (w *criticalWatch) observer() func(string, []metrics.MetricValue) {
+	if w == nil {
+		return nil
+	}
+	return w.observe
+}
+
+// observe checks one collector's metrics against the critical thresholds. It runs on the collecting
+// goroutine, so the abort itself is started in the background.
+func (w *criticalWatch) observe(collector string, collected []metrics.MetricValue) {
+	if w.triggered.Load() {
+		return
+	}
+
+	var violations []criticalViolation
+	for _, metric := range collected {
+		threshold := w.controller.getThresholdForMetric(w.cfg.CriticalThresholds, metric.Type)
+		if threshold <= 0 || metric.Value <= threshold || metric.Type.IsInformational() {
+			continue
+		}
+		if window, ok := w.cfg.MetricWindows[string(metric.Type)]; ok && windowSkipReason(window, w.phase) != "" {
+			continue
+		}
+		violations = append(violations, criticalViolation{
+			metric:    metric.Type,
+			violation: fmt.Sprintf("%s: %s > %s (critical)", metric.Type, metric, metric.Type.FormatValue(threshold)),
+		})
+	}
+	if len(violations) == 0 {
+		return
+	}
+
+	w.once.Do(func() {
+		w.violations = violations
+		w.triggered.Store(true)
+		klog.Warningf("Critical thresholds exceeded after the %s collector, aborting operation '%s' without waiting for the rest of the cycle: %v",
+			collector, w.status.OperationType, criticalViolationStrings(violations))
+		go w.abort()
+	})
+}
+
+// abort applies the abort policies and, unless one suppresses it, aborts the operation. It is bounded by
+// the cycle context.
+func (w *criticalWatch) abort() {
+	defer close(w.done)
+
+	w.decision = CriticalAbort{
+		Violations:     criticalViolationStrings(w.violations),
+		TriggeredAfter: time.Since(w.startedAt),
+	}
+
+	// The collector that reported the metric finished, but pod metrics may still be partial in chunked mode.
+	// Collectors skipped in the previous cycle do not matter here.
+	if coverage := w.controller.metricsCollector.Coverage(); !coverage.RotationComplete {
+		coverage.Skipped = nil
+		detail := coverageGap(coverage)
+		klog.Warningf("Not aborting operation '%s' on the critical path (%s): %s", w.status.OperationType, SuppressionIncompleteData, detail)
+		w.decision.SuppressionReason, w.decision.SuppressionDetail = SuppressionIncompleteData, detail
+		return
+	}
+	w.policies.consider(SuppressionIncompleteData, "")
+
+	proceed, err := w.controller.applyAbortPolicies(w.ctx, w.cfg, w.settings, w.status, &w.policies)
+	if err != nil {
+		w.decision.Error = err.Error()
+		return
+	}
+	if !proceed {
+		w.decision.SuppressionReason, w.decision.SuppressionDetail = w.policies.SuppressionReason, w.policies.SuppressionDetail
+		return
+	}
+
+	w.abortRequest, err = w.controller.executeAbort(w.ctx, w.status, w.startedAt)
+	if errors.Is(err, azure.ErrCircuitOpen) {
+		// Not sent; the regular path at the end of the cycle reports the abort as unavailable
+		w.decision.SuppressionReason, w.decision.SuppressionDetail = SuppressionAbortUnavailable, err.Error()
+		return
+	}
+	if errors.Is(err, azure.ErrOperationFinished) {
+		// Nothing left to abort; finishCriticalAbort reports it so the regular path does not send it again
+		w.decision.Attempted = true
+		w.decision.SuppressionReason, w.decision.SuppressionDetail = SuppressionOperationFinished, err.Error()
+		return
+	}
+	w.decision.Attempted = true
+	if err != nil {
+		w.decision.Error = err.Error()
+		w.abortErr = err
+	}
+}
+
+// finishCriticalAbort waits for the critical path of the cycle, if it was taken, and records it on the
+// result. It reports whether the critical path made the abort decision, in which case the returned error
+// is that of the abort call.
+func (c *Controller) finishCriticalAbort(ctx context.Context, cfg *config.Config, status *azure.OperationStatus, result *HealthCheckResult, w *criticalWatch) (bool, error) {
+	if w == nil || !w.triggered.Load() {
+		return false, nil
+	}
+	<-w.done
+
+	decision := w.decision
+	result.CriticalAbort = &decision
+	if decision.SuppressionReason == SuppressionOperationFinished {
+		c.reportOperationFinished(status, result, decision.SuppressionDetail)
+		return true, nil
+	}
+	if !decision.Attempted {
+		// Suppressed, or the policies could not be evaluated: the regular path decides with the full picture
+		return false, nil
+	}
+
+	// A per-operation override can raise a regular threshold above the critical one
+	violated := make(map[metrics.MetricType]bool, len(result.Evaluations))
+	for _, evaluation := range result.Evaluations {
+		violated[evaluation.Metric] = evaluation.Violated
+	}
+	for _, v := range w.violations {
+		if !violated[v.metric] {
+			result.Violations = append(result.Violations, v.violation)
+			result.ViolationRefs = append(result.ViolationRefs, newViolationRef(v.metric, ruleCritical))
+		}
+	}
+	result.SuppressionChecks = w.policies.SuppressionChecks
+
+	c.recordAbortExplanation(ctx, cfg, status, result, w.abortRequest, w.abortErr)
+	if w.abortErr != nil {
+		return true, fmt.Errorf("failed to abort operation: %w", w.abortErr)
+	}
+	result.Action = ActionAborted
+	klog.Infof("Successfully aborted operation '%s' on the critical path, %s into the cycle",
+		status.OperationType, decision.TriggeredAfter.Round(time.Millisecond))
+	return true, nil
+}
+
+// criticalViolationStrings renders critical violations for messages
+func criticalViolationStrings(violations []criticalViolation) []string {
+	rendered := make([]string, 0, len(violations))
+	for _, v := range violations {
+		rendered = append(rendered, v.violation)
+	}
+	return rendered
+}
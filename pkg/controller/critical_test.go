@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/azure/fake"
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/metrics"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// slowPods returns a clientset holding objects whose pod lists block until release is closed. listing is
+// closed once the first pod list starts, by which time the critical tier's collectors have finished.
+func slowPods(objects []runtime.Object) (client *kubefake.Clientset, listing <-chan struct{}, release chan struct{}) {
+	client = kubefake.NewSimpleClientset(objects...)
+	started := make(chan struct{})
+	release = make(chan struct{})
+	var once sync.Once
+	client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		once.Do(func() { close(started) })
+		<-release
+		return false, nil, nil
+	})
+	return client, started, release
+}
+
+// criticalConfig returns a configuration aborting at once above 60% not ready nodes
+func criticalConfig(t *testing.T) *config.Config {
+	cfg := testConfig(t)
+	cfg.CriticalThresholds.NotReadyNodesPercent = 60
+	cfg.Thresholds.PodChurnPerMinute = config.ThresholdDisabled
+	return cfg
+}
+
+// runCycleAsync runs one cycle in the background and returns the channel its result is sent on
+func runCycleAsync(c *Controller) <-chan HealthCheckResult {
+	results := make(chan HealthCheckResult, 1)
+	go func() { results <- c.runCycle(context.Background()) }()
+	return results
+}
+
+func TestCriticalAbortPrecedesSlowCollectors(t *testing.T) {
+	kubeClient, listing, release := slowPods(unreadyNodes(4))
+	c, azureClient := newTestControllerWithClient(t, criticalConfig(t), kubeClient)
+	azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+
+	results := runCycleAsync(c)
+	<-listing
+	// The pod collectors are held, so an abort now was issued ahead of them
+	for deadline := time.Now().Add(5 * time.Second); len(azureClient.Aborts()) == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			close(release)
+			t.Fatal("no abort while the pod collectors were still running")
+		}
+	}
+	close(release)
+	result := <-results
+
+	if result.Action != ActionAborted {
+		t.Errorf("action = %s (%s: %s), want aborted", result.Action, result.SuppressionReason, result.SuppressionDetail)
+	}
+	if result.CriticalAbort == nil || !result.CriticalAbort.Attempted || len(result.CriticalAbort.Violations) != 1 {
+		t.Errorf("critical abort = %+v, want the attempted fast path with the nodes violation", result.CriticalAbort)
+	}
+	if got := azureClient.Aborts(); len(got) != 1 {
+		t.Errorf("aborts = %v, want one: the regular path does not abort again", got)
+	}
+	// The rest of the cycle still completes for the record
+	if _, ok := metricValue(result, metrics.PendingPodsPercentMetric); !ok {
+		t.Errorf("%s not collected after the critical abort, metrics: %v", metrics.PendingPodsPercentMetric, result.Metrics)
+	}
+	if explanation := c.GetLastAbortExplanation(); explanation == nil || explanation.CriticalAbort == nil {
+		t.Errorf("abort explanation = %+v, want the critical path recorded", explanation)
+	}
+}
+
+func TestCriticalAbortRespectsPolicies(t *testing.T) {
+	tests := []struct {
+		name       string
+		configure  func(t *testing.T, c *Controller, cfg *config.Config)
+		wantReason SuppressionReason
+	}{
+		{
+			name:       "dry run",
+			configure:  func(t *testing.T, c *Controller, cfg *config.Config) { cfg.DryRun = true },
+			wantReason: SuppressionDryRun,
+		},
+		{
+			name: "alert only",
+			configure: func(t *testing.T, c *Controller, cfg *config.Config) {
+				setMonitoredOperations(t, cfg, "- name: upgrade\n  action: alert")
+			},
+			wantReason: SuppressionAlertOnly,
+		},
+		{
+			name: "abort cooldown",
+			configure: func(t *testing.T, c *Controller, cfg *config.Config) {
+				c.cooldown = abortCooldown{operation: "Upgrading", since: time.Now()}
+			},
+			wantReason: SuppressionAbortCooldown,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := criticalConfig(t)
+			kubeClient, listing, release := slowPods(unreadyNodes(4))
+			c, azureClient := newTestControllerWithClient(t, cfg, kubeClient)
+			test.configure(t, c, cfg)
+			azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+
+			results := runCycleAsync(c)
+			<-listing
+			close(release)
+			result := <-results
+
+			if got := azureClient.Aborts(); len(got) != 0 {
+				t.Errorf("aborts = %v, want none", got)
+			}
+			if result.CriticalAbort == nil || result.CriticalAbort.Attempted || result.CriticalAbort.SuppressionReason != test.wantReason {
+				t.Errorf("critical abort = %+v, want suppressed as %s", result.CriticalAbort, test.wantReason)
+			}
+			if result.Action != ActionSuppressed || result.SuppressionReason != test.wantReason {
+				t.Errorf("action = %s (%s), want suppressed as %s by the regular path too", result.Action, result.SuppressionReason, test.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"fmt"
+
+	"aks-health-monitor/pkg/decisionlog"
+	"aks-health-monitor/pkg/metrics"
+)
+
+// decisionPath names abort decisions in the decision log, in the place of an OPA policy path
+const decisionPath = "aks_monitor/abort"
+
+// DecisionInput is what an abort decision was made from
+type DecisionInput struct {
+	Operation DecisionOperation     `json:"operation"`
+	Metrics   []metrics.MetricValue `json:"metrics"`
+	Coverage  metrics.Coverage      `json:"coverage"`
+}
+
+// DecisionOperation identifies the operation a decision was about
+type DecisionOperation struct {
+	Type              string `json:"type"`
+	ProvisioningState string `json:"provisioningState"`
+}
+
+// DecisionResult is the decision and the rules and reason codes behind it, copied from the cycle result
+type DecisionResult struct {
+	Action            Action                `json:"action"`
+	SuppressionReason SuppressionReason     `json:"suppressionReason,omitempty"`
+	SuppressionDetail string                `json:"suppressionDetail,omitempty"`
+	Violations        []string              `json:"violations"`
+	Evaluations       []ThresholdEvaluation `json:"evaluations"`
+	SuppressionChecks []SuppressionCheck    `json:"suppressionChecks,omitempty"`
+	Error             string                `json:"error,omitempty"`
+}
+
+// logDecision sends the cycle's abort or suppression decision to the decision log, if one is configured.
+// Cycles without violations made no decision and are not logged.
+func (c *Controller) logDecision(result *HealthCheckResult) {
+	if c.decisions == nil || len(result.Violations) == 0 {
+		return
+	}
+
+	c.mu.RLock()
+	generation := c.configGeneration
+	c.mu.RUnlock()
+
+	cfg := c.cfg()
+	c.decisions.Log(decisionlog.Decision{
+		DecisionID: result.TraceID,
+		Path:       decisionPath,
+		Labels: map[string]string{
+			"app":     eventComponent,
+			"id":      c.identity.PodName,
+			"cluster": fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ContainerService/managedClusters/%s", cfg.Azure.SubscriptionID, cfg.Azure.ResourceGroupName, cfg.Azure.ClusterName),
+		},
+		// The configuration generation plays the role of the policy bundle revision
+		Bundles: map[string]decisionlog.Bundle{
+			eventComponent: {Revision: fmt.Sprintf("generation-%d", generation)},
+		},
+		Input: DecisionInput{
+			Operation: DecisionOperation{Type: result.OperationType, ProvisioningState: result.ProvisioningState},
+			Metrics:   result.Metrics,
+			Coverage:  result.Coverage,
+		},
+		Result: DecisionResult{
+			Action:            result.Action,
+			SuppressionReason: result.SuppressionReason,
+			SuppressionDetail: result.SuppressionDetail,
+			Violations:        result.Violations,
+			Evaluations:       result.Evaluations,
+			SuppressionChecks: result.SuppressionChecks,
+			Error:             result.Error,
+		},
+		Timestamp: result.Timestamp,
+	})
+}
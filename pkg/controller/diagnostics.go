@@ -0,0 +1,335 @@
+package controller
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/config"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/klog/v2"
+)
+
+// Statuses of a diagnostic bundle
+const (
+	bundlePending = "pending"
+	bundleWritten = "written"
+	bundleFailed  = "failed"
+)
+
+// diagnosticEventTimeout bounds recording the Event that reports a bundle's outcome
+const diagnosticEventTimeout = 10 * time.Second
+
+// agentPoolLabel names the agent pool of an AKS node
+const agentPoolLabel = "kubernetes.azure.com/agentpool"
+
+// DiagnosticBundle records where the evidence of an abort was captured. The location is known when the
+// abort is recorded; the status moves from pending to written or failed once the capture finishes.
+type DiagnosticBundle struct {
+	Location  string `json:"location"`
+	Status    string `json:"status"`
+	Objects   int    `json:"objects,omitempty"`
+	Bytes     int    `json:"bytes,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// diagnosticBundle names the bundle of an abort, or returns nil when diagnostics are disabled or the Blob
+// container cannot be reached
+func (c *Controller) diagnosticBundle(cfg *config.Config, decidedAt time.Time, traceID string) (*DiagnosticBundle, string) {
+	settings := cfg.Diagnostics
+	if !settings.Enabled() {
+		return nil, ""
+	}
+
+	name := fmt.Sprintf("%s-%s", cfg.Azure.ClusterName, decidedAt.UTC().Format("20060102T150405Z"))
+	if traceID != "" {
+		name += "-" + traceID
+	}
+	name += ".tar.gz"
+
+	if settings.Path != "" {
+		return &DiagnosticBundle{Location: filepath.Join(settings.Path, name), Status: bundlePending}, name
+	}
+	if c.blobs == nil {
+		klog.Warning("Not capturing a diagnostic bundle: the Azure client cannot reach Blob storage")
+		return nil, ""
+	}
+	return &DiagnosticBundle{Location: c.blobs.URL(name), Status: bundlePending}, name
+}
+
+// captureDiagnosticBundle gathers the evidence of an abort and stores it as the named bundle, within the
+// configured timeout. It runs after the abort was sent, in its own goroutine, and its failures are only
+// reported: they never affect the abort.
+func (c *Controller) captureDiagnosticBundle(settings config.DiagnosticsConfig, snapshot *azure.ClusterSnapshot, explanation *AbortExplanation, name, traceID string) {
+	outcome := *explanation.DiagnosticBundle
+	err := c.guard("diagnostics", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), settings.Timeout)
+		defer cancel()
+
+		archive, err := c.buildDiagnosticBundle(ctx, settings, snapshot, explanation, traceID)
+		if err != nil {
+			return err
+		}
+		outcome.Objects = archive.objects
+		outcome.Bytes = archive.buf.Len()
+		outcome.Truncated = len(archive.omitted) > 0
+		return c.storeDiagnosticBundle(ctx, settings, name, archive.buf.Bytes())
+	})
+
+	eventCtx, cancel := context.WithTimeout(context.Background(), diagnosticEventTimeout)
+	defer cancel()
+	if err != nil {
+		outcome.Status = bundleFailed
+		outcome.Error = err.Error()
+		klog.Errorf("Failed to capture the diagnostic bundle %s: %v", outcome.Location, err)
+		c.recordEvent(eventCtx, corev1.EventTypeWarning, "DiagnosticBundleFailed",
+			fmt.Sprintf("Failed to capture the diagnostic bundle of the abort at %s: %v", explanation.DecidedAt.Format(time.RFC3339), err))
+	} else {
+		outcome.Status = bundleWritten
+		klog.Infof("Captured the diagnostic bundle %s: %d objects, %d bytes", outcome.Location, outcome.Objects, outcome.Bytes)
+		message := fmt.Sprintf("Captured the diagnostic bundle of the abort at %s: %s", explanation.DecidedAt.Format(time.RFC3339), outcome.Location)
+		if outcome.Truncated {
+			message += fmt.Sprintf(" (truncated at %d bytes)", settings.MaxBytes)
+		}
+		c.recordEvent(eventCtx, corev1.EventTypeNormal, "DiagnosticBundleCaptured", message)
+	}
+
+	// The record may be read concurrently, so it is replaced rather than updated
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastAbort == explanation {
+		updated := *explanation
+		updated.DiagnosticBundle = &outcome
+		c.lastAbort = &updated
+	}
+}
+
+// bundleManifest describes a bundle's contents and what was left out
+type bundleManifest struct {
+	Cluster    string    `json:"cluster"`
+	TraceID    string    `json:"traceId,omitempty"`
+	DecidedAt  time.Time `json:"decidedAt"`
+	CapturedAt time.Time `json:"capturedAt"`
+	Files      []string  `json:"files"`
+	Omitted    []string  `json:"omitted,omitempty"`
+	Errors     []string  `json:"errors,omitempty"`
+}
+
+// bundledCluster is the cluster and agent pool state ARM reported at decision time
+type bundledCluster struct {
+	Name                     string                 `json:"name"`
+	Location                 string                 `json:"location"`
+	ProvisioningState        string                 `json:"provisioningState"`
+	KubernetesVersion        string                 `json:"kubernetesVersion"`
+	CurrentKubernetesVersion string                 `json:"currentKubernetesVersion,omitempty"`
+	FetchedAt                time.Time              `json:"fetchedAt"`
+	AgentPools               []azure.AgentPoolState `json:"agentPools"`
+}
+
+// bundledNode is the part of a node that tells why it could not host workloads
+type bundledNode struct {
+	Name           string                 `json:"name"`
+	AgentPool      string                 `json:"agentPool,omitempty"`
+	Unschedulable  bool                   `json:"unschedulable,omitempty"`
+	Taints         []corev1.Taint         `json:"taints,omitempty"`
+	KubeletVersion string                 `json:"kubeletVersion"`
+	HostsOffenders bool                   `json:"hostsOffenders,omitempty"`
+	Conditions     []corev1.NodeCondition `json:"conditions"`
+}
+
+// bundleArchive writes JSON files into a gzipped tar, leaving out files past the size cap
+type bundleArchive struct {
+	buf      bytes.Buffer
+	gzip     *gzip.Writer
+	tar      *tar.Writer
+	modTime  time.Time
+	maxBytes int
+	size     int
+	objects  int
+	files    []string
+	omitted  []string
+}
+
+// newBundleArchive starts an archive whose uncompressed content is capped at maxBytes
+func newBundleArchive(maxBytes int) *bundleArchive {
+	archive := &bundleArchive{modTime: time.Now(), maxBytes: maxBytes}
+	archive.gzip = gzip.NewWriter(&archive.buf)
+	archive.tar = tar.NewWriter(archive.gzip)
+	return archive
+}
+
+// add writes v as the named JSON file, counting objects toward the bundle's objects. A file that would
+// take the content past the cap is omitted instead.
+func (a *bundleArchive) add(name string, v interface{}, objects int) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	if a.size+len(data) > a.maxBytes {
+		a.omitted = append(a.omitted, name)
+		return nil
+	}
+	if err := a.write(name, data); err != nil {
+		return err
+	}
+	a.size += len(data)
+	a.objects += objects
+	a.files = append(a.files, name)
+	return nil
+}
+
+// write adds one file to the tar
+func (a *bundleArchive) write(name string, data []byte) error {
+	header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: a.modTime}
+	if err := a.tar.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := a.tar.Write(data)
+	return err
+}
+
+// close finishes the archive with its manifest, which is written whatever the cap
+func (a *bundleArchive) close(manifest bundleManifest) error {
+	manifest.Files = a.files
+	manifest.Omitted = a.omitted
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest.json: %w", err)
+	}
+	if err := a.write("manifest.json", data); err != nil {
+		return err
+	}
+	if err := a.tar.Close(); err != nil {
+		return err
+	}
+	return a.gzip.Close()
+}
+
+// buildDiagnosticBundle gathers the decision record, the cluster and agent pool state, the offending pods
+// with their Warning events and the node conditions. Objects that cannot be read are listed in the
+// manifest rather than failing the bundle.
+func (c *Controller) buildDiagnosticBundle(ctx context.Context, settings config.DiagnosticsConfig, snapshot *azure.ClusterSnapshot, explanation *AbortExplanation, traceID string) (*bundleArchive, error) {
+	archive := newBundleArchive(settings.MaxBytes)
+	manifest := bundleManifest{
+		TraceID:    traceID,
+		DecidedAt:  explanation.DecidedAt,
+		CapturedAt: archive.modTime,
+	}
+	if explanation.Config != nil {
+		manifest.Cluster = explanation.Config.Azure.ClusterName
+	}
+
+	if err := archive.add("decision.json", explanation, 1); err != nil {
+		return nil, err
+	}
+
+	if snapshot != nil {
+		cluster := bundledCluster{
+			Name:                     snapshot.Name,
+			Location:                 snapshot.Location,
+			ProvisioningState:        snapshot.ProvisioningState,
+			KubernetesVersion:        snapshot.KubernetesVersion,
+			CurrentKubernetesVersion: snapshot.CurrentKubernetesVersion,
+			FetchedAt:                snapshot.FetchedAt,
+			AgentPools:               snapshot.AgentPools(),
+		}
+		if err := archive.add("cluster.json", cluster, 1+len(cluster.AgentPools)); err != nil {
+			return nil, err
+		}
+	}
+
+	offenderNodes := make(map[string]bool)
+	pods, err := c.metricsCollector.OffendingPods(ctx, settings.MaxPods)
+	if err != nil {
+		manifest.Errors = append(manifest.Errors, fmt.Sprintf("offending pods: %v", err))
+	}
+	for _, pod := range pods {
+		pod.ManagedFields = nil
+		if pod.Spec.NodeName != "" {
+			offenderNodes[pod.Spec.NodeName] = true
+		}
+		if err := archive.add(fmt.Sprintf("pods/%s/%s.json", pod.Namespace, pod.Name), pod, 1); err != nil {
+			return nil, err
+		}
+
+		selector := fields.Set{
+			"involvedObject.kind": "Pod",
+			"involvedObject.name": pod.Name,
+			"type":                corev1.EventTypeWarning,
+		}.AsSelector().String()
+		events, err := c.kubeClient.CoreV1().Events(pod.Namespace).List(ctx, metav1.ListOptions{FieldSelector: selector})
+		if err != nil {
+			manifest.Errors = append(manifest.Errors, fmt.Sprintf("events of pod %s/%s: %v", pod.Namespace, pod.Name, err))
+			continue
+		}
+		if len(events.Items) == 0 {
+			continue
+		}
+		for i := range events.Items {
+			events.Items[i].ManagedFields = nil
+		}
+		if err := archive.add(fmt.Sprintf("events/%s/%s.json", pod.Namespace, pod.Name), events.Items, len(events.Items)); err != nil {
+			return nil, err
+		}
+	}
+
+	nodes, err := c.kubeClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		manifest.Errors = append(manifest.Errors, fmt.Sprintf("nodes: %v", err))
+	} else {
+		bundled := make([]bundledNode, 0, len(nodes.Items))
+		for _, node := range nodes.Items {
+			bundled = append(bundled, bundledNode{
+				Name:           node.Name,
+				AgentPool:      node.Labels[agentPoolLabel],
+				Unschedulable:  node.Spec.Unschedulable,
+				Taints:         node.Spec.Taints,
+				KubeletVersion: node.Status.NodeInfo.KubeletVersion,
+				HostsOffenders: offenderNodes[node.Name],
+				Conditions:     node.Status.Conditions,
+			})
+		}
+		if err := archive.add("nodes.json", bundled, len(bundled)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.close(manifest); err != nil {
+		return nil, fmt.Errorf("failed to write the bundle: %w", err)
+	}
+	return archive, nil
+}
+
+// storeDiagnosticBundle writes the bundle to the configured directory or uploads it to the Blob container
+func (c *Controller) storeDiagnosticBundle(ctx context.Context, settings config.DiagnosticsConfig, name string, data []byte) error {
+	if settings.Path == "" {
+		if err := c.blobs.Upload(ctx, name, "application/gzip", data); err != nil {
+			return fmt.Errorf("failed to upload the bundle: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(settings.Path, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", settings.Path, err)
+	}
+	// Write under a temporary name so a partial bundle is never mistaken for a complete one
+	path := filepath.Join(settings.Path, name)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return fmt.Errorf("failed to write the bundle: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write the bundle: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// refreshAPIDiscovery re-runs API discovery when the discovery interval has elapsed so collectors
+// whose resources are not served are disabled, and re-enabled once the resources appear
+func (c *Controller) refreshAPIDiscovery(ctx context.Context, now time.Time) {
+	if !c.lastDiscovery.IsZero() && now.Sub(c.lastDiscovery) < c.cfg().Collection.DiscoveryInterval {
+		return
+	}
+
+	if err := c.metricsCollector.Discover(ctx); err != nil {
+		// Keep the previous state; retry on the next cycle
+		klog.Warningf("API discovery failed: %v", err)
+		return
+	}
+	c.lastDiscovery = now
+}
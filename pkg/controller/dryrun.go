@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/config"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// eventReasonDryRunAbort is the reason of the Events recorded for aborts held back by dryRun
+const eventReasonDryRunAbort = "AbortDryRun"
+
+// reportDryRunAbort records an abort that every policy allowed but dryRun held back. The Event is
+// coalesced like violation Events, since the violations, and so the would-be abort, repeat every cycle
+// until the operation ends.
+func (c *Controller) reportDryRunAbort(ctx context.Context, cfg *config.Config, status *azure.OperationStatus, result *HealthCheckResult) {
+	message := fmt.Sprintf("Dry run: would have aborted %s operation on cluster %s/%s: %s",
+		status.OperationType, cfg.Azure.ResourceGroupName, cfg.Azure.ClusterName, strings.Join(result.guidedViolations(), "; "))
+	klog.Warning(message)
+
+	c.mu.Lock()
+	c.dryRunAborts++
+	c.mu.Unlock()
+
+	c.recordCoalescedEvent(ctx, corev1.EventTypeWarning, eventReasonDryRunAbort, violationKey(result), message)
+}
@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// eventComponent identifies the controller as the source of the Events it creates
+const eventComponent = "aks-health-monitor"
+
+// Event reasons used for coalesced violation series
+const (
+	eventReasonViolation = "ThresholdViolation"
+	eventReasonWarning   = "ThresholdWarning"
+	eventReasonRecovered = "ThresholdsRecovered"
+	eventReasonAborted   = "OperationAborted"
+)
+
+// eventSeries is the most recent Event of a coalesced series and the key identifying what it reports
+type eventSeries struct {
+	key   string
+	event *corev1.Event
+}
+
+// eventCoalescer remembers the open series per reason so repeats update one Event
+type eventCoalescer struct {
+	mu     sync.Mutex
+	series map[string]*eventSeries
+}
+
+// recordEvent creates an Event on the controller's own pod. Events are best effort: when the controller's
+// namespace is unknown the Event is skipped with a log line instead.
+func (c *Controller) recordEvent(ctx context.Context, eventType, reason, message string) {
+	if event := c.newEvent(eventType, reason, message); event != nil {
+		if err := c.writer.CreateEvent(ctx, event); err != nil {
+			klog.Errorf("Failed to record %s Event: %v", reason, err)
+		}
+	}
+}
+
+// recordCoalescedEvent records an Event that repeats every cycle while a condition persists. While key
+// is unchanged the open Event's count, message and last timestamp are updated instead of creating a new
+// Event, so a violation lasting an hour is one Event rather than one per cycle. A different key, such as
+// a new set of violated metrics, starts a new Event.
+func (c *Controller) recordCoalescedEvent(ctx context.Context, eventType, reason, key, message string) {
+	c.events.mu.Lock()
+	defer c.events.mu.Unlock()
+
+	if open := c.events.series[reason]; open != nil && open.key == key {
+		updated := open.event.DeepCopy()
+		updated.Count++
+		updated.Message = message
+		updated.LastTimestamp = metav1.NewTime(time.Now())
+
+		err := c.writer.UpdateEvent(ctx, updated)
+		if err == nil {
+			open.event = updated
+			return
+		}
+		if !apierrors.IsNotFound(err) {
+			klog.Errorf("Failed to update %s Event: %v", reason, err)
+			return
+		}
+		// The Event expired; start a new one
+	}
+
+	event := c.newEvent(eventType, reason, message)
+	if event == nil {
+		return
+	}
+	if err := c.writer.CreateEvent(ctx, event); err != nil {
+		klog.Errorf("Failed to record %s Event: %v", reason, err)
+		return
+	}
+	if c.events.series == nil {
+		c.events.series = make(map[string]*eventSeries)
+	}
+	c.events.series[reason] = &eventSeries{key: key, event: event}
+}
+
+// closeEventSeries ends the open series for reason, reporting whether one was open
+func (c *Controller) closeEventSeries(reason string) bool {
+	c.events.mu.Lock()
+	defer c.events.mu.Unlock()
+
+	_, open := c.events.series[reason]
+	delete(c.events.series, reason)
+	return open
+}
+
+// newEvent builds an Event on the controller's own pod, or returns nil when its namespace is unknown
+func (c *Controller) newEvent(eventType, reason, message string) *corev1.Event {
+	namespace, err := c.identity.RequireNamespace("recording Events")
+	if err != nil {
+		klog.V(2).Infof("Skipping %s Event: %v", reason, err)
+		return nil
+	}
+
+	now := metav1.NewTime(time.Now())
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%x", c.identity.PodName, now.UnixNano()),
+			Namespace: namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Namespace:  namespace,
+			Name:       c.identity.PodName,
+		},
+		Reason:              reason,
+		Message:             message,
+		Type:                eventType,
+		Source:              corev1.EventSource{Component: eventComponent},
+		ReportingController: eventComponent,
+		ReportingInstance:   c.identity.PodName,
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+	}
+}
+
+// recordViolationEvents reports the cycle's violations as one coalesced Warning Event per set of violated
+// metrics. Aborts and recovery always get Events of their own and end the open series.
+func (c *Controller) recordViolationEvents(ctx context.Context, result *HealthCheckResult) {
+	switch {
+	case result.Action == ActionAborted:
+		c.closeEventSeries(eventReasonViolation)
+		path := ""
+		if result.CriticalAbort != nil && result.CriticalAbort.Attempted {
+			path = fmt.Sprintf(" on the critical path after %s", result.CriticalAbort.TriggeredAfter.Round(time.Millisecond))
+		}
+		c.recordEvent(ctx, corev1.EventTypeWarning, eventReasonAborted,
+			fmt.Sprintf("Aborted %s operation%s: %s", result.OperationType, path, strings.Join(result.guidedViolations(), "; ")))
+	case len(result.Violations) > 0:
+		c.recordCoalescedEvent(ctx, corev1.EventTypeWarning, eventReasonViolation, violationKey(result),
+			fmt.Sprintf("Thresholds violated during %s operation: %s", result.OperationType, strings.Join(result.guidedViolations(), "; ")))
+	case result.Error == "":
+		c.closeEventSeries(eventReasonDryRunAbort)
+		if c.closeEventSeries(eventReasonViolation) {
+			c.recordEvent(ctx, corev1.EventTypeNormal, eventReasonRecovered, "All thresholds are within limits again")
+		}
+	}
+}
+
+// recordWarningEvents reports metrics beyond their warn threshold only as one coalesced Warning Event per
+// set of warned metrics, and ends the series once none is
+func (c *Controller) recordWarningEvents(ctx context.Context, result *HealthCheckResult) {
+	if len(result.Warnings) == 0 {
+		c.closeEventSeries(eventReasonWarning)
+		return
+	}
+
+	var warned []string
+	for _, ref := range result.WarningRefs {
+		warned = append(warned, string(ref.Metric))
+	}
+	c.recordCoalescedEvent(ctx, corev1.EventTypeWarning, eventReasonWarning, result.OperationType+"/"+strings.Join(warned, ","),
+		fmt.Sprintf("Warn thresholds crossed during %s operation: %s", result.OperationType, strings.Join(result.Warnings, "; ")))
+}
+
+// violationKey identifies the operation and the set of violated metrics of a result, so coalesced Events
+// start a new series when either changes
+func violationKey(result *HealthCheckResult) string {
+	var violated []string
+	for _, evaluation := range result.Evaluations {
+		if evaluation.Violated {
+			violated = append(violated, string(evaluation.Metric))
+		}
+	}
+	sort.Strings(violated)
+	return result.OperationType + "/" + strings.Join(violated, ",")
+}
@@ -0,0 +1,220 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/azure/fake"
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/metrics"
+	"aks-health-monitor/pkg/notify"
+	"aks-health-monitor/pkg/runtimeinfo"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// eventsByReason returns the Events recorded in the controller's namespace, keyed by reason
+func eventsByReason(t *testing.T, kubeClient *kubefake.Clientset) map[string][]corev1.Event {
+	t.Helper()
+	events, err := kubeClient.CoreV1().Events("aks-monitor").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byReason := make(map[string][]corev1.Event)
+	for _, event := range events.Items {
+		byReason[event.Reason] = append(byReason[event.Reason], event)
+	}
+	return byReason
+}
+
+// setNodesReady sets the Ready condition of every node
+func setNodesReady(t *testing.T, kubeClient *kubefake.Clientset, ready corev1.ConditionStatus) {
+	t.Helper()
+	nodes, err := kubeClient.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range nodes.Items {
+		nodes.Items[i].Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}}
+		if _, err := kubeClient.CoreV1().Nodes().UpdateStatus(context.Background(), &nodes.Items[i], metav1.UpdateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSustainedViolationCoalescesEvents(t *testing.T) {
+	cfg := testConfig(t)
+	setMonitoredOperations(t, cfg, "- name: upgrade\n  action: alert")
+	// Cycles run back to back, so a single new pod would read as a burst of churn
+	cfg.Thresholds.PodChurnPerMinute = config.ThresholdDisabled
+	kubeClient := kubefake.NewSimpleClientset(unreadyNodes(2)...)
+	c, azureClient := newTestControllerWithClient(t, cfg, kubeClient)
+	azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+
+	// An hour of 30s cycles with the same violation is one Event counting every cycle
+	const cycles = 120
+	for i := 0; i < cycles; i++ {
+		c.runCycle(context.Background())
+	}
+	violations := eventsByReason(t, kubeClient)[eventReasonViolation]
+	if len(violations) != 1 || violations[0].Count != cycles {
+		t.Fatalf("violation Events = %d (count %v), want one counting %d cycles", len(violations), eventCounts(violations), cycles)
+	}
+
+	// A new violated metric starts a new series
+	if _, err := kubeClient.CoreV1().Pods("default").Create(context.Background(), pendingPod("default", "web"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		c.runCycle(context.Background())
+	}
+	violations = eventsByReason(t, kubeClient)[eventReasonViolation]
+	if counts := eventCounts(violations); len(counts) != 2 || counts[0]+counts[1] != cycles+3 {
+		t.Fatalf("violation Event counts = %v, want the first series and a new one of 3", counts)
+	}
+
+	// Recovery is an Event of its own, once
+	if err := kubeClient.CoreV1().Pods("default").Delete(context.Background(), "web", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	setNodesReady(t, kubeClient, corev1.ConditionTrue)
+	for i := 0; i < 3; i++ {
+		c.runCycle(context.Background())
+	}
+	events := eventsByReason(t, kubeClient)
+	if len(events[eventReasonRecovered]) != 1 {
+		t.Errorf("recovery Events = %d, want 1", len(events[eventReasonRecovered]))
+	}
+
+	// The same violation returning after recovery starts a new series rather than resuming the old one
+	setNodesReady(t, kubeClient, corev1.ConditionFalse)
+	c.runCycle(context.Background())
+	if violations := eventsByReason(t, kubeClient)[eventReasonViolation]; len(violations) != 3 {
+		t.Errorf("violation Events = %d, want a third series after recovery", len(violations))
+	}
+}
+
+func TestAbortEventEndsViolationSeries(t *testing.T) {
+	cfg := testConfig(t)
+	setMonitoredOperations(t, cfg, "- name: upgrade\n  consecutiveViolations: 3")
+	kubeClient := kubefake.NewSimpleClientset(unreadyNodes(2)...)
+	c, azureClient := newTestControllerWithClient(t, cfg, kubeClient)
+	azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+
+	for i := 0; i < 3; i++ {
+		c.runCycle(context.Background())
+	}
+	events := eventsByReason(t, kubeClient)
+	if len(azureClient.Aborts()) != 1 {
+		t.Fatalf("aborts = %v, want one after 3 consecutive violations", azureClient.Aborts())
+	}
+	if counts := eventCounts(events[eventReasonViolation]); len(counts) != 1 || counts[0] != 2 {
+		t.Errorf("violation Event counts = %v, want one series for the 2 cycles before the abort", counts)
+	}
+	if len(events[eventReasonAborted]) != 1 {
+		t.Errorf("abort Events = %d, want 1", len(events[eventReasonAborted]))
+	}
+}
+
+// eventCounts returns the count of each Event
+func eventCounts(events []corev1.Event) []int32 {
+	counts := make([]int32, 0, len(events))
+	for _, event := range events {
+		counts = append(counts, event.Count)
+	}
+	return counts
+}
+
+func TestOperationEndingInFailedState(t *testing.T) {
+	failed := fake.Idle()
+	failed.Status = "Failed"
+	failed.Errors = []azure.ProvisioningError{{Source: "agentPool/nodepool1", Code: "QuotaExceeded", Message: "Operation could not be completed as it results in exceeding approved cores quota."}}
+	succeeded := fake.Idle()
+
+	tests := []struct {
+		name     string
+		statuses []*azure.OperationStatus
+		// eventsRejected makes the API server refuse Events, which must not lose the failure
+		eventsRejected bool
+		wantFailure    bool
+	}{
+		{name: "in progress to failed", statuses: []*azure.OperationStatus{fake.InProgress("Upgrading"), failed}, wantFailure: true},
+		{name: "failure Event rejected", statuses: []*azure.OperationStatus{fake.InProgress("Upgrading"), failed}, eventsRejected: true, wantFailure: true},
+		{name: "in progress to succeeded", statuses: []*azure.OperationStatus{fake.InProgress("Upgrading"), succeeded}},
+		{name: "failed without an observed operation", statuses: []*azure.OperationStatus{failed, failed}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			release := make(chan struct{})
+			close(release)
+			url, received := webhookSink(t, release)
+			cfg := testConfig(t)
+			cfg.Notifications.Webhooks = []config.WebhookConfig{{URL: url, Timeout: time.Minute}}
+			kubeClient := kubefake.NewSimpleClientset(node("node-0", corev1.ConditionTrue))
+			if test.eventsRejected {
+				kubeClient.PrependReactor("create", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, apierrors.NewForbidden(corev1.Resource("events"), "", nil)
+				})
+			}
+			registry := prometheus.NewRegistry()
+			azureClient := fake.NewClient()
+			azureClient.SetOperationStatuses(test.statuses...)
+			c, err := NewController(kubeClient, metrics.NewCollector(kubeClient, cfg.Collection), azureClient, cfg,
+				registry, &runtimeinfo.Info{Namespace: "aks-monitor", PodName: "aks-monitor-0"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c.runCycle(context.Background())
+			result := c.runCycle(context.Background())
+			c.notifier.Flush(context.Background())
+
+			if (result.OperationFailed != nil) != test.wantFailure {
+				t.Fatalf("operationFailed = %+v, want a failure: %v", result.OperationFailed, test.wantFailure)
+			}
+			if last, _ := c.history.last(); (last.OperationFailed != nil) != test.wantFailure {
+				t.Errorf("history operationFailed = %+v, want a failure: %v", last.OperationFailed, test.wantFailure)
+			}
+			want := ""
+			if test.wantFailure {
+				want = "# HELP aks_monitor_operations_failed_total Number of observed operations that ended in the Failed provisioning state, by operation type.\n" +
+					"# TYPE aks_monitor_operations_failed_total counter\n" +
+					"aks_monitor_operations_failed_total{operation=\"Upgrading\"} 1\n"
+			}
+			if err := testutil.GatherAndCompare(registry, strings.NewReader(want), "aks_monitor_operations_failed_total"); err != nil {
+				t.Error(err)
+			}
+
+			events := eventsByReason(t, kubeClient)["OperationFailed"]
+			if wantEvents := test.wantFailure && !test.eventsRejected; (len(events) == 1) != wantEvents {
+				t.Errorf("OperationFailed Events = %d, want one: %v", len(events), wantEvents)
+			} else if wantEvents && !strings.Contains(events[0].Message, "QuotaExceeded") {
+				t.Errorf("Event message = %q, want the provisioning error", events[0].Message)
+			}
+
+			var failures []notify.Notification
+			for len(received) > 0 {
+				if notification := <-received; notification.Event == notify.EventOperationFailed {
+					failures = append(failures, notification)
+				}
+			}
+			if (len(failures) == 1) != test.wantFailure {
+				t.Fatalf("operationFailed notifications = %+v, want one: %v", failures, test.wantFailure)
+			}
+			if test.wantFailure && (failures[0].Operation != "Upgrading" || !strings.Contains(failures[0].Reason, "QuotaExceeded")) {
+				t.Errorf("notification = %+v, want the failed Upgrading operation with its provisioning error", failures[0])
+			}
+		})
+	}
+}
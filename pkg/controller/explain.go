@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/metrics"
+
+	"k8s.io/klog/v2"
+)
+
+// AbortExplanation is a self-contained record of why an abort was issued, assembled at decision time
+type AbortExplanation struct {
+	DecidedAt         time.Time             `json:"decidedAt"`
+	Config            *config.Config        `json:"config"`
+	Operation         ExplainedOperation    `json:"operation"`
+	Metrics           []ExplainedMetric     `json:"metrics"`
+	Coverage          metrics.Coverage      `json:"coverage"`
+	Evaluations       []ThresholdEvaluation `json:"evaluations"`
+	SuppressionChecks []SuppressionCheck    `json:"suppressionChecks"`
+	Action            Action                `json:"action"`
+	CriticalAbort     *CriticalAbort        `json:"criticalAbort,omitempty"`
+	AzureResult       AbortOutcome          `json:"azureResult"`
+	DiagnosticBundle  *DiagnosticBundle     `json:"diagnosticBundle,omitempty"`
+}
+
+// ExplainedOperation describes the operation that was aborted
+type ExplainedOperation struct {
+	Type              string                       `json:"type"`
+	ProvisioningState string                       `json:"provisioningState"`
+	StartedAt         time.Time                    `json:"startedAt,omitempty"`
+	Attribution       *azure.Attribution           `json:"attribution,omitempty"`
+	Upgrade           *azure.UpgradeClassification `json:"upgrade,omitempty"`
+	Errors            []azure.ProvisioningError    `json:"errors,omitempty"`
+}
+
+// ExplainedMetric is a metric value together with where it was read from
+type ExplainedMetric struct {
+	Type      metrics.MetricType `json:"type"`
+	Value     int                `json:"value"`
+	Fraction  *metrics.Fraction  `json:"fraction,omitempty"`
+	Source    string             `json:"source"`
+	Offenders []string           `json:"offenders,omitempty"`
+}
+
+// AbortOutcome is the result of the abort request to Azure
+type AbortOutcome struct {
+	Succeeded bool                `json:"succeeded"`
+	Error     string              `json:"error,omitempty"`
+	Request   azure.ARMRequestIDs `json:"request"`
+}
+
+// metricSources describes where each metric is read from
+var metricSources = map[metrics.MetricType]string{
+	metrics.CrashingPodsPercentMetric:             "Kubernetes pods: phase and container waiting reasons",
+	metrics.ImagePullErrorsPercentMetric:          "Kubernetes pods: container waiting reasons ImagePullBackOff, ErrImagePull and InvalidImageName",
+	metrics.PendingPodsPercentMetric:              "Kubernetes pods: phase",
+	metrics.RestartCountMetric:                    "Kubernetes pods: container restart counts",
+	metrics.FlappingPodsMetric:                    "Kubernetes pod informer: Ready condition transitions within the flapping window",
+	metrics.OOMKilledPodsMetric:                   "Kubernetes pods: OOMKilled container terminations and their finish times",
+	metrics.RestartRateMetric:                     "Kubernetes pods: container restart counts compared with the previous full listing",
+	metrics.NotReadyNodesPercentMetric:            "Kubernetes nodes: Ready condition",
+	metrics.MemoryPressureNodesPercentMetric:      "Kubernetes nodes: MemoryPressure condition",
+	metrics.DiskPressureNodesPercentMetric:        "Kubernetes nodes: DiskPressure condition",
+	metrics.PIDPressureNodesPercentMetric:         "Kubernetes nodes: PIDPressure condition",
+	metrics.NetworkUnavailableNodesPercentMetric:  "Kubernetes nodes: NetworkUnavailable condition",
+	metrics.DaemonSetNotReadyPercentMetric:        "Kubernetes daemonsets: desired scheduled and ready pod counts",
+	metrics.FailedJobsMetric:                      "Kubernetes jobs: failed status",
+	metrics.CpuUsagePercentMetric:                 "metrics-server: node CPU usage; Kubernetes nodes: allocatable",
+	metrics.MemoryUsagePercentMetric:              "metrics-server: node memory usage; Kubernetes nodes: allocatable",
+	metrics.MaxNodeCpuUsagePercentMetric:          "metrics-server: node CPU usage; Kubernetes nodes: allocatable",
+	metrics.MaxNodeMemoryUsagePercentMetric:       "metrics-server: node memory usage; Kubernetes nodes: allocatable",
+	metrics.CpuRequestsSaturationPercentMetric:    "Kubernetes pods: CPU requests; nodes: schedulable allocatable",
+	metrics.MemoryRequestsSaturationPercentMetric: "Kubernetes pods: memory requests; nodes: schedulable allocatable",
+	metrics.WorkloadsBlockedByAdmissionMetric:     "Kubernetes events: FailedCreate admission denials",
+	metrics.P95SchedulingLatencySecondsMetric:     "Kubernetes pods: PodScheduled condition of pods created during the operation",
+	metrics.OperationErrorDetectedMetric:          "Azure Resource Manager: managed cluster provisioning errors",
+	metrics.SuspectedStuckOperationMetric:         "Azure Resource Manager: control plane and agent pool versions and counts; Kubernetes nodes",
+	metrics.WorkloadsLosingZoneRedundancyMetric:   "Kubernetes deployments, statefulsets, pods and node zone labels",
+	metrics.SuspectedNodeReimageLoopsMetric:       "Kubernetes nodes: UIDs and Ready counts per agent pool across cycles",
+	metrics.ShutdownTerminatedPodsMetric:          "Kubernetes pods: Failed phase with an ignorable status reason",
+	metrics.PodChurnPerMinuteMetric:               "Kubernetes pods: UIDs added and removed between consecutive listings",
+	metrics.DeprecationWarningsObservedMetric:     "Kubernetes API server: Warning headers on the controller's requests",
+	metrics.NewPodsCrashingPercentMetric:          "Kubernetes pods created during the operation: phase and container waiting reasons",
+	metrics.NewPodsPendingPercentMetric:           "Kubernetes pods created during the operation: phase",
+	metrics.ConfigReferenceFailuresMetric:         "Kubernetes pods: CreateContainerConfigError; events: FailedMount of Pending pods",
+}
+
+// recordAbortExplanation assembles the decision record for an abort, keeps it for the explain endpoint
+// and writes it to the log as the audit trail. When diagnostics are enabled it then starts capturing the
+// diagnostic bundle, whose location the record and the result carry from the start.
+func (c *Controller) recordAbortExplanation(ctx context.Context, cfg *config.Config, status *azure.OperationStatus, result *HealthCheckResult, request azure.ARMRequestIDs, abortErr error) {
+	c.mu.RLock()
+	startedAt := c.operationStartedAt
+	c.mu.RUnlock()
+
+	explanation := &AbortExplanation{
+		DecidedAt: result.Timestamp,
+		Config:    cfg.Redacted(),
+		Operation: ExplainedOperation{
+			Type:              status.OperationType,
+			ProvisioningState: status.Status,
+			StartedAt:         startedAt,
+			Attribution:       status.Attribution,
+			Upgrade:           status.Upgrade,
+			Errors:            status.Errors,
+		},
+		Coverage:          result.Coverage,
+		Evaluations:       result.Evaluations,
+		SuppressionChecks: result.SuppressionChecks,
+		Action:            ActionAborted,
+		CriticalAbort:     result.CriticalAbort,
+		AzureResult:       AbortOutcome{Succeeded: abortErr == nil, Request: request},
+	}
+	if abortErr != nil {
+		explanation.AzureResult.Error = abortErr.Error()
+		result.AbortError = abortErr.Error()
+	}
+
+	for _, metric := range result.Metrics {
+		explanation.Metrics = append(explanation.Metrics, ExplainedMetric{
+			Type:      metric.Type,
+			Value:     metric.Value,
+			Fraction:  metric.Fraction,
+			Source:    metricSources[metric.Type],
+			Offenders: metric.Offenders,
+		})
+	}
+
+	bundle, bundleName := c.diagnosticBundle(cfg, result.Timestamp, result.TraceID)
+	if bundle != nil {
+		explanation.DiagnosticBundle = bundle
+		result.DiagnosticBundle = bundle.Location
+	}
+
+	c.mu.Lock()
+	c.lastAbort = explanation
+	c.mu.Unlock()
+	if bundle != nil {
+		go c.captureDiagnosticBundle(cfg.Diagnostics, status.Snapshot, explanation, bundleName, result.TraceID)
+	}
+
+	if data, err := json.Marshal(explanation); err == nil {
+		klog.Infof("Abort decision record: %s", data)
+	} else {
+		klog.Errorf("Failed to encode abort decision record: %v", err)
+	}
+
+	c.recordAudit(ctx, cfg, newAuditEntry(c.identity, status, result, explanation.AzureResult))
+}
+
+// GetLastAbortExplanation returns the decision record of the most recent abort, or nil if none was issued
+func (c *Controller) GetLastAbortExplanation() *AbortExplanation {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastAbort
+}
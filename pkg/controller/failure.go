@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/config"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// provisioningStateFailed is the provisioning state ARM reports when an operation fails
+const provisioningStateFailed = "Failed"
+
+// OperationFailure records an operation that ended in the Failed provisioning state
+type OperationFailure struct {
+	OperationType string                    `json:"operationType"`
+	DetectedAt    time.Time                 `json:"detectedAt"`
+	Errors        []azure.ProvisioningError `json:"errors,omitempty"`
+}
+
+// detectOperationFailure reports a failure when the previous cycle saw an operation in progress and ARM
+// now reports Failed. It is independent of the threshold pipeline, so operations that fail on the Azure
+// side while workload metrics stay healthy are still surfaced.
+func detectOperationFailure(wasInProgress bool, previousOperation string, status *azure.OperationStatus, now time.Time) *OperationFailure {
+	if !wasInProgress || status.InProgress || status.Status != provisioningStateFailed {
+		return nil
+	}
+
+	return &OperationFailure{
+		OperationType: previousOperation,
+		DetectedAt:    now,
+		Errors:        status.Errors,
+	}
+}
+
+// details lists the provisioning errors ARM reported for the failure, or is empty when it reported none
+func (f *OperationFailure) details() string {
+	details := make([]string, 0, len(f.Errors))
+	for _, provisioningErr := range f.Errors {
+		details = append(details, fmt.Sprintf("%s: %s: %s", provisioningErr.Source, provisioningErr.Code, provisioningErr.Message))
+	}
+	return strings.Join(details, "; ")
+}
+
+// reportOperationFailure logs, counts and records an Event for a failed operation
+func (c *Controller) reportOperationFailure(ctx context.Context, cfg *config.Config, failure *OperationFailure) {
+	message := fmt.Sprintf("Operation '%s' on cluster %s finished in Failed state", failure.OperationType, cfg.Azure.ClusterName)
+	if details := failure.details(); details != "" {
+		message = fmt.Sprintf("%s: %s", message, details)
+	}
+
+	klog.Error(message)
+	c.exporter.RecordOperationFailed(failure.OperationType)
+	c.recordEvent(ctx, corev1.EventTypeWarning, "OperationFailed", message)
+}
+
+// reportOperationFinished records an abort ARM refused because the operation had already finished. There
+// is nothing left to abort, so it is logged rather than treated as a failed abort.
+func (c *Controller) reportOperationFinished(status *azure.OperationStatus, result *HealthCheckResult, detail string) {
+	klog.Infof("Not aborting operation '%s' (%s): %s", status.OperationType, SuppressionOperationFinished, detail)
+	result.suppress(SuppressionOperationFinished, detail)
+}
+
+// reportAbortUnavailable records an abort that was not sent because the Azure write circuit is open. The
+// violations are still there next cycle, which retries the abort once the circuit lets a request through.
+func (c *Controller) reportAbortUnavailable(ctx context.Context, status *azure.OperationStatus, result *HealthCheckResult, err error) {
+	klog.Warningf("Not aborting operation '%s' (%s): %v", status.OperationType, SuppressionAbortUnavailable, err)
+	result.suppress(SuppressionAbortUnavailable, err.Error())
+	c.recordEvent(ctx, corev1.EventTypeWarning, "AbortUnavailable",
+		fmt.Sprintf("Could not abort %s operation, Azure aborts are temporarily unavailable: %v", status.OperationType, err))
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"time"
+
+	"aks-health-monitor/pkg/exporter"
+)
+
+// Fleet states of a cluster. The health states are reported as HealthState.String(); the others cover
+// clusters whose latest cycle did not produce a verdict.
+const (
+	// FleetStateError means the latest cycle failed or the controller cannot reach Azure
+	FleetStateError = "error"
+	// FleetStatePending means no cycle has completed yet
+	FleetStatePending = "pending"
+)
+
+// ClusterSummary is one cluster's entry in the fleet view
+type ClusterSummary struct {
+	Cluster             string        `json:"cluster"`
+	ResourceGroup       string        `json:"resourceGroup"`
+	SubscriptionID      string        `json:"subscriptionId"`
+	State               string        `json:"state"`
+	Reason              string        `json:"reason,omitempty"`
+	OperationInProgress bool          `json:"operationInProgress"`
+	CurrentOperation    string        `json:"currentOperation,omitempty"`
+	LastCheck           *time.Time    `json:"lastCheck,omitempty"`
+	LastAbort           *ClusterAbort `json:"lastAbort,omitempty"`
+}
+
+// ClusterAbort is the most recent abort issued for a cluster
+type ClusterAbort struct {
+	At        time.Time `json:"at"`
+	Operation string    `json:"operation"`
+	Succeeded bool      `json:"succeeded"`
+}
+
+// FleetStatus summarizes every cluster the controller monitors, one entry per cluster. It is built from
+// state cached by the check loop, so it is cheap to call and never triggers a collection. A cluster whose
+// loop is failing is listed with the error state and the reason rather than left out.
+func (c *Controller) FleetStatus() []ClusterSummary {
+	cfg := c.cfg()
+	summary := ClusterSummary{
+		Cluster:        cfg.Azure.ClusterName,
+		ResourceGroup:  cfg.Azure.ResourceGroupName,
+		SubscriptionID: cfg.Azure.SubscriptionID,
+		State:          FleetStatePending,
+	}
+
+	c.mu.RLock()
+	summary.OperationInProgress = c.operationInProgress
+	summary.CurrentOperation = c.currentOperation
+	metricsOnlyReason := c.metricsOnlyReason
+	if c.lastAbort != nil {
+		summary.LastAbort = &ClusterAbort{
+			At:        c.lastAbort.DecidedAt,
+			Operation: c.lastAbort.Operation.Type,
+			Succeeded: c.lastAbort.AzureResult.Succeeded,
+		}
+	}
+	c.mu.RUnlock()
+
+	if last, ok := c.history.last(); ok {
+		lastCheck := last.Timestamp
+		summary.LastCheck = &lastCheck
+		summary.State = last.HealthState.String()
+		if last.Error != "" {
+			summary.State = FleetStateError
+			summary.Reason = last.Error
+		}
+	}
+	if metricsOnlyReason != "" {
+		summary.State = FleetStateError
+		summary.Reason = "metrics-only mode: " + metricsOnlyReason
+	}
+
+	return []ClusterSummary{summary}
+}
+
+// exportFleetStates publishes the fleet view as gauges labelled by cluster
+func (c *Controller) exportFleetStates() {
+	fleet := c.FleetStatus()
+	states := make([]exporter.ClusterState, 0, len(fleet))
+	for _, summary := range fleet {
+		state := exporter.ClusterState{Cluster: summary.Cluster, ResourceGroup: summary.ResourceGroup, State: summary.State}
+		if summary.LastCheck != nil {
+			state.LastCheck = *summary.LastCheck
+		}
+		if summary.LastAbort != nil {
+			state.LastAbort = summary.LastAbort.At
+		}
+		states = append(states, state)
+	}
+	c.exporter.SetFleetStates(states)
+}
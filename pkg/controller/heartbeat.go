@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/config"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// eventReasonHeartbeat is the reason of the coalesced Event updated by each heartbeat
+const eventReasonHeartbeat = "MonitorHeartbeat"
+
+// heartbeatTracker counts cycles and remembers when the last heartbeat was sent
+type heartbeatTracker struct {
+	cycles uint64
+	lastAt time.Time
+}
+
+// heartbeat is called by the run loop at the end of every cycle. It always updates the heartbeat gauge
+// and, at most once per interval while an operation is in progress, or always when configured, sends a
+// heartbeat to the configured channels. Because the loop itself sends it, a wedged loop stops the
+// heartbeat rather than leaving a timer to vouch for it.
+func (c *Controller) heartbeat(ctx context.Context, cfg *config.Config, result *HealthCheckResult) {
+	c.exporter.SetHeartbeat(result.Timestamp)
+
+	c.mu.Lock()
+	c.heartbeats.cycles++
+	cycles := c.heartbeats.cycles
+	due := (result.OperationInProgress || cfg.Heartbeat.OutsideOperations) &&
+		(c.heartbeats.lastAt.IsZero() || result.Timestamp.Sub(c.heartbeats.lastAt) >= cfg.Heartbeat.Interval)
+	if due {
+		c.heartbeats.lastAt = result.Timestamp
+	}
+	c.mu.Unlock()
+
+	if !due || len(cfg.Heartbeat.Channels) == 0 {
+		return
+	}
+
+	operation := "no operation in progress"
+	if result.OperationInProgress {
+		operation = fmt.Sprintf("operation '%s' in progress", result.OperationType)
+	}
+	message := fmt.Sprintf("Monitor is evaluating: %s, cycle %d, health state %s", operation, cycles, result.HealthState)
+
+	for _, channel := range cfg.Heartbeat.Channels {
+		switch channel {
+		case config.HeartbeatChannelEvent:
+			// One Event per operation, its count and message updated by each heartbeat
+			c.recordCoalescedEvent(ctx, corev1.EventTypeNormal, eventReasonHeartbeat, result.OperationType, message)
+		case config.HeartbeatChannelLog:
+			klog.Info(message)
+		case config.HeartbeatChannelVerdicts:
+			if c.verdicts == nil {
+				continue
+			}
+			c.deliverVerdict(ctx, azure.Verdict{
+				MessageID:   fmt.Sprintf("%s-%s", result.TraceID, azure.VerdictHeartbeat),
+				Kind:        azure.VerdictHeartbeat,
+				Timestamp:   result.Timestamp,
+				Cluster:     clusterResourceID(cfg),
+				Operation:   result.OperationType,
+				Violations:  result.Violations,
+				Action:      string(result.Action),
+				HealthState: int(result.HealthState),
+				Cycle:       cycles,
+				TraceID:     result.TraceID,
+			})
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/config"
+
+	"k8s.io/klog/v2"
+)
+
+// initiatorRetryInterval is how often the activity log is read again while an operation's initiator is
+// unknown; events reach the activity log some minutes after the request
+const initiatorRetryInterval = time.Minute
+
+// OperationInitiator is the attribution of the operation in progress to a trusted or untrusted caller,
+// read from the cluster's activity log
+type OperationInitiator struct {
+	azure.Attribution
+	Caller    *azure.OperationCaller `json:"caller,omitempty"`
+	CheckedAt time.Time              `json:"checkedAt"`
+}
+
+// initiatorTracker remembers the initiator of the operation in progress, so the activity log is read once
+// per operation rather than every cycle
+type initiatorTracker struct {
+	operation string
+	initiator *OperationInitiator
+}
+
+// attributeInitiator attributes the operation in progress to a trusted or untrusted caller. It returns
+// nil when no trusted initiators are configured. An attribution that could not be made is unknown and
+// retried; a known one is kept until the operation ends.
+func (c *Controller) attributeInitiator(ctx context.Context, cfg *config.Config, status *azure.OperationStatus, operationStartedAt, now time.Time) *OperationInitiator {
+	if len(cfg.TrustedInitiators.Principals) == 0 {
+		return nil
+	}
+
+	c.mu.RLock()
+	tracker := c.initiator
+	c.mu.RUnlock()
+	if tracker.operation == status.OperationType && tracker.initiator != nil &&
+		(tracker.initiator.Initiator != azure.InitiatorUnknown || now.Sub(tracker.initiator.CheckedAt) < initiatorRetryInterval) {
+		return tracker.initiator
+	}
+
+	caller, err := c.azureClient.GetOperationCaller(ctx, operationStartedAt.Add(-cfg.TrustedInitiators.Lookback))
+	initiator := &OperationInitiator{
+		Attribution: attributeCaller(caller, err, cfg.TrustedInitiators.Principals),
+		Caller:      caller,
+		CheckedAt:   now,
+	}
+	if tracker.operation != status.OperationType || tracker.initiator == nil || tracker.initiator.Attribution != initiator.Attribution {
+		klog.Infof("Operation '%s' attributed to %s initiator: %s", status.OperationType, initiator.Initiator, initiator.Evidence)
+	}
+
+	c.mu.Lock()
+	c.initiator = initiatorTracker{operation: status.OperationType, initiator: initiator}
+	c.mu.Unlock()
+	return initiator
+}
+
+// attributeCaller matches the caller read from the activity log against the trusted principals
+func attributeCaller(caller *azure.OperationCaller, err error, principals []string) azure.Attribution {
+	if err != nil {
+		return azure.Attribution{
+			Initiator:  azure.InitiatorUnknown,
+			Confidence: azure.ConfidenceLow,
+			Evidence:   fmt.Sprintf("failed to read the activity log: %v", err),
+		}
+	}
+	if caller == nil {
+		return azure.Attribution{
+			Initiator:  azure.InitiatorUnknown,
+			Confidence: azure.ConfidenceLow,
+			Evidence:   "the activity log records no request that could have started the operation",
+		}
+	}
+
+	for _, identity := range caller.Identities() {
+		for _, principal := range principals {
+			if strings.EqualFold(identity, strings.TrimSpace(principal)) {
+				return azure.Attribution{
+					Initiator:  azure.InitiatorTrusted,
+					Confidence: azure.ConfidenceHigh,
+					Evidence: fmt.Sprintf("%s by %s at %s matches trusted principal %s",
+						caller.Operation, caller.Caller, caller.Timestamp.Format(time.RFC3339), principal),
+				}
+			}
+		}
+	}
+	return azure.Attribution{
+		Initiator:  azure.InitiatorUntrusted,
+		Confidence: azure.ConfidenceHigh,
+		Evidence: fmt.Sprintf("%s by %s at %s matches no trusted principal",
+			caller.Operation, caller.Caller, caller.Timestamp.Format(time.RFC3339)),
+	}
+}
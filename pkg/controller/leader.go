@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+)
+
+// ErrLeadershipLost is returned by RunWithLeaderElection when the Lease could not be renewed. The poll loop
+// has stopped by then; the process should exit and rejoin the election as a standby.
+var ErrLeadershipLost = errors.New("leader election lost")
+
+// LeaderStatus describes the controller's part in leader election
+type LeaderStatus struct {
+	Lease   string    `json:"lease"`
+	Leading bool      `json:"leading"`
+	Holder  string    `json:"holder,omitempty"`
+	Since   time.Time `json:"since,omitempty"`
+}
+
+// RunWithLeaderElection runs the controller only while it holds the Lease in its namespace, so several
+// replicas can run without more than one polling Azure or sending aborts. A standby waits until the Lease
+// expires. On shutdown the poll loop is stopped before the Lease is released, so another replica only
+// takes over once this one has stopped acting.
+func (c *Controller) RunWithLeaderElection(ctx context.Context) error {
+	namespace, err := c.identity.RequireNamespace("leader election")
+	if err != nil {
+		return err
+	}
+	settings := c.cfg().LeaderElection
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Namespace: namespace, Name: settings.LeaseName},
+		Client:     c.kubeClient.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: c.identity.PodName},
+	}
+	lease := namespace + "/" + settings.LeaseName
+	c.setLeaderStatus(LeaderStatus{Lease: lease})
+	c.exporter.SetLeader(false)
+
+	// The elector starts the loop in a goroutine; electionMu decides whether it started before the election
+	// ended, and so whether there is a loop to wait for
+	var (
+		electionMu   sync.Mutex
+		electionDone bool
+		started      bool
+		runErr       error
+	)
+	loopStopped := make(chan struct{})
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: settings.LeaseDuration,
+		RenewDeadline: settings.RenewDeadline,
+		RetryPeriod:   settings.RetryPeriod,
+		Name:          lease,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				electionMu.Lock()
+				if electionDone {
+					electionMu.Unlock()
+					return
+				}
+				started = true
+				electionMu.Unlock()
+				defer close(loopStopped)
+
+				klog.Infof("Acquired the leader election Lease %s, starting the poll loop", lease)
+				c.setLeaderStatus(LeaderStatus{Lease: lease, Leading: true, Holder: c.identity.PodName, Since: time.Now()})
+				c.exporter.SetLeader(true)
+				runErr = c.Run(leaderCtx)
+			},
+			OnStoppedLeading: func() {
+				c.exporter.SetLeader(false)
+			},
+			OnNewLeader: func(holder string) {
+				if holder == c.identity.PodName {
+					return
+				}
+				klog.Infof("Standing by: %s holds the leader election Lease %s", holder, lease)
+				c.setLeaderStatus(LeaderStatus{Lease: lease, Holder: holder, Since: time.Now()})
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("invalid leader election settings: %w", err)
+	}
+
+	// The elector cancels the loop's context when the Lease is lost or ctx is cancelled, then returns; the
+	// Lease is released below only once the loop has returned
+	elector.Run(ctx)
+	electionMu.Lock()
+	electionDone = true
+	electionMu.Unlock()
+	if started {
+		<-loopStopped
+	}
+	c.setLeaderStatus(LeaderStatus{Lease: lease})
+	c.exporter.SetLeader(false)
+
+	if runErr != nil {
+		return runErr
+	}
+	if ctx.Err() == nil {
+		klog.Errorf("Lost the leader election Lease %s; the poll loop has stopped", lease)
+		return ErrLeadershipLost
+	}
+	if started {
+		c.releaseLease(namespace, settings.LeaseName)
+	}
+	return nil
+}
+
+// leaseReleaseTimeout bounds releasing the Lease on shutdown
+const leaseReleaseTimeout = 5 * time.Second
+
+// releaseLease gives up the Lease if this replica still holds it, so a standby takes over without waiting
+// for it to expire
+func (c *Controller) releaseLease(namespace, name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), leaseReleaseTimeout)
+	defer cancel()
+
+	leases := c.kubeClient.CoordinationV1().Leases(namespace)
+	current, err := leases.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			klog.Warningf("Failed to release the leader election Lease %s/%s: %v", namespace, name, err)
+		}
+		return
+	}
+	if current.Spec.HolderIdentity == nil || *current.Spec.HolderIdentity != c.identity.PodName {
+		return
+	}
+
+	// As client-go releases a Lease: no holder, and a one second duration so a standby acquires it at once
+	now := metav1.NewMicroTime(time.Now())
+	released := current.DeepCopy()
+	released.Spec = coordinationv1.LeaseSpec{
+		HolderIdentity:       new(string),
+		LeaseDurationSeconds: new(int32),
+		AcquireTime:          &now,
+		RenewTime:            &now,
+		LeaseTransitions:     current.Spec.LeaseTransitions,
+	}
+	*released.Spec.LeaseDurationSeconds = 1
+	if _, err := leases.Update(ctx, released, metav1.UpdateOptions{}); err != nil {
+		klog.Warningf("Failed to release the leader election Lease %s/%s: %v", namespace, name, err)
+		return
+	}
+	klog.Infof("Released the leader election Lease %s/%s", namespace, name)
+}
+
+// setLeaderStatus records the controller's part in leader election for /status
+func (c *Controller) setLeaderStatus(status LeaderStatus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.leader = &status
+}
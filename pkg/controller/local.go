@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/metrics"
+)
+
+// EvaluateLocally collects metrics and evaluates them against cfg's default thresholds as if an operation
+// had just started. Azure is never contacted and nothing is recorded or acted on, so it is safe to run
+// from a workstation against a cluster that a controller is already monitoring.
+func EvaluateLocally(ctx context.Context, collector *metrics.Collector, cfg *config.Config) HealthCheckResult {
+	c := &Controller{metricsCollector: collector}
+	c.activeConfig.Store(cfg)
+
+	result := HealthCheckResult{Timestamp: time.Now(), Action: ActionNone}
+	collectedMetrics, err := collector.CollectMetrics(ctx)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to collect metrics: %v", err)
+	} else {
+		result.Metrics = collectedMetrics
+		result.Coverage = collector.Coverage()
+		result.recordEvaluations(c.evaluateThresholds(cfg, cfg.Thresholds, collectedMetrics, operationPhase{percentComplete: -1}, nil))
+	}
+	result.HealthState = result.healthState()
+	return result
+}
@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+
+	"k8s.io/klog/v2"
+)
+
+// maintenanceTracker caches the cluster's upcoming planned maintenance windows
+type maintenanceTracker struct {
+	windows     []azure.MaintenanceWindow
+	lastRefresh time.Time
+}
+
+// refreshMaintenanceWindows re-lists maintenance configurations when the refresh interval has elapsed
+func (c *Controller) refreshMaintenanceWindows(ctx context.Context, now time.Time) {
+	if !c.cfg().Maintenance.Enabled {
+		return
+	}
+	if !c.maintenance.lastRefresh.IsZero() && now.Sub(c.maintenance.lastRefresh) < c.cfg().Maintenance.RefreshInterval {
+		return
+	}
+
+	windows, err := c.azureClient.ListMaintenanceWindows(ctx, now)
+	if err != nil {
+		// Keep the previous windows; a transient failure should not cancel pre-warming
+		klog.Warningf("Failed to refresh maintenance windows: %v", err)
+		return
+	}
+
+	c.mu.Lock()
+	c.maintenance.windows = windows
+	c.maintenance.lastRefresh = now
+	c.mu.Unlock()
+
+	for _, window := range windows {
+		klog.Infof("Planned maintenance %q next window: %s for %s", window.Name, window.Start.Format(time.RFC3339), window.Duration)
+	}
+}
+
+// activeMaintenanceWindow returns the window that starts within the lead time or is in progress, if any
+func (c *Controller) activeMaintenanceWindow(now time.Time) (azure.MaintenanceWindow, bool) {
+	if !c.cfg().Maintenance.Enabled {
+		return azure.MaintenanceWindow{}, false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, window := range c.maintenance.windows {
+		if now.After(window.Start.Add(-c.cfg().Maintenance.LeadTime)) && now.Before(window.End()) {
+			return window, true
+		}
+	}
+	return azure.MaintenanceWindow{}, false
+}
+
+// pollInterval returns the interval until the next cycle, switching to the faster cadence around maintenance windows
+func (c *Controller) pollInterval() time.Duration {
+	if _, ok := c.activeMaintenanceWindow(time.Now()); ok {
+		return c.cfg().Maintenance.PollInterval
+	}
+	return c.cfg().PollInterval
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/metrics"
+	"aks-health-monitor/pkg/notify"
+)
+
+// sendNotification queues a notification for an aborted operation or a failed abort, or for thresholds
+// newly violated in this cycle, and another for metrics that newly crossed only their warn threshold.
+// Violations and warnings that persist from the previous cycle are not sent again. An operation that
+// ended in the Failed state gets a notification of its own. Delivery runs in the background and never
+// delays the cycle or the abort.
+func (c *Controller) sendNotification(cfg *config.Config, result *HealthCheckResult) {
+	if c.notifier == nil {
+		return
+	}
+
+	if failure := result.OperationFailed; failure != nil {
+		notification := c.notification(cfg, result, notify.EventOperationFailed, SeverityAbort)
+		notification.Operation = failure.OperationType
+		notification.Reason = failure.details()
+		c.notifier.Notify(notification)
+	}
+
+	switch {
+	case result.Action == ActionAborted || result.AbortError != "":
+		c.notify(cfg, result, notify.EventAbort, SeverityAbort)
+	case len(result.NewViolations) > 0:
+		c.notify(cfg, result, notify.EventViolation, SeverityAbort)
+	}
+	if len(result.NewWarnings) > 0 {
+		c.notify(cfg, result, notify.EventWarning, SeverityWarn)
+	}
+}
+
+// notify queues one notification listing the evaluations of the given severity
+func (c *Controller) notify(cfg *config.Config, result *HealthCheckResult, event string, severity Severity) {
+	c.notifier.Notify(c.notification(cfg, result, event, severity))
+}
+
+// notification builds a notification of the result listing the evaluations of the given severity
+func (c *Controller) notification(cfg *config.Config, result *HealthCheckResult, event string, severity Severity) notify.Notification {
+	var violations []notify.Violation
+	for _, evaluation := range result.Evaluations {
+		if evaluation.Severity != severity {
+			continue
+		}
+		violation := notify.Violation{
+			Metric:    string(evaluation.Metric),
+			Value:     metrics.MetricValue{Type: evaluation.Metric, Value: evaluation.Value, Fraction: evaluation.Fraction}.String(),
+			Threshold: evaluation.Metric.FormatValue(evaluation.Threshold),
+			Message:   evaluation.Violation,
+		}
+		if severity == SeverityWarn {
+			violation.Threshold = evaluation.Metric.FormatValue(*evaluation.WarnThreshold)
+			violation.Message = evaluation.Warning
+		}
+		violations = append(violations, violation)
+	}
+
+	c.mu.RLock()
+	startedAt := c.operationStartedAt
+	c.mu.RUnlock()
+
+	notification := notify.Notification{
+		Event:         event,
+		Cluster:       cfg.Azure.ClusterName,
+		ResourceGroup: cfg.Azure.ResourceGroupName,
+		Operation:     result.OperationType,
+		Violations:    violations,
+		TraceID:       result.TraceID,
+		Timestamp:     result.Timestamp,
+
+		OperationStartedAt: startedAt,
+	}
+	if event == notify.EventAbort {
+		notification.AbortError = result.AbortError
+		notification.DiagnosticBundle = result.DiagnosticBundle
+	}
+	return notification
+}
@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/azure/fake"
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/notify"
+)
+
+// webhookSink starts a webhook endpoint that holds each request until release is closed, and returns
+// the notifications it received
+func webhookSink(t *testing.T, release <-chan struct{}) (string, <-chan notify.Notification) {
+	t.Helper()
+	received := make(chan notify.Notification, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var notification notify.Notification
+		if err := json.NewDecoder(r.Body).Decode(&notification); err != nil {
+			t.Error(err)
+		}
+		<-release
+		received <- notification
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, received
+}
+
+func TestAbortNotificationDoesNotDelayAbort(t *testing.T) {
+	release := make(chan struct{})
+	url, received := webhookSink(t, release)
+	cfg := testConfig(t)
+	cfg.Thresholds.PodChurnPerMinute = config.ThresholdDisabled
+	cfg.Notifications.Webhooks = []config.WebhookConfig{{URL: url, Timeout: time.Minute}}
+	c, azureClient := newTestController(t, cfg, unreadyNodes(2)...)
+	azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.notifier.Run(ctx)
+
+	// The webhook holds the delivery until released, so the abort must not wait on it
+	result := c.runCycle(context.Background())
+	if result.Action != ActionAborted || len(azureClient.Aborts()) != 1 {
+		t.Fatalf("action = %s with %d aborts, want one abort while the webhook hangs", result.Action, len(azureClient.Aborts()))
+	}
+	// The violations persist, so the next cycle sends nothing new
+	c.runCycle(context.Background())
+	close(release)
+
+	var notification notify.Notification
+	select {
+	case notification = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the abort notification was not delivered")
+	}
+	if notification.Event != notify.EventAbort || notification.Cluster != "cluster" || notification.ResourceGroup != "rg" ||
+		notification.Operation != "Upgrading" || notification.TraceID != result.TraceID {
+		t.Errorf("notification = %+v, want the abort of Upgrading on rg/cluster in trace %s", notification, result.TraceID)
+	}
+	if len(notification.Violations) != 1 || notification.Violations[0].Metric != "not_ready_nodes_percent" ||
+		notification.Violations[0].Value != "100% (2/2)" {
+		t.Errorf("violations = %+v, want not_ready_nodes_percent at 100%% (2/2)", notification.Violations)
+	}
+
+	select {
+	case extra := <-received:
+		t.Errorf("sent %s notification %+v for persisting violations", extra.Event, extra)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// OverrideConfigMapName is the ConfigMap in the controller's namespace that overrides the configured
+// behavior, as an off-switch faster than editing the configuration
+const OverrideConfigMapName = "aks-monitor-override"
+
+// OverrideModeObserve in the override ConfigMap's mode key makes the controller behave as dryRun
+const OverrideModeObserve = "observe"
+
+// Reasons of the Events recorded when the override takes and stops taking effect
+const (
+	eventReasonOverrideActive  = "OverrideObserveMode"
+	eventReasonOverrideRemoved = "OverrideRemoved"
+)
+
+// OverrideStatus describes the override in effect
+type OverrideStatus struct {
+	Mode  string    `json:"mode"`
+	Since time.Time `json:"since"`
+}
+
+// applyOverride reads the override ConfigMap at the start of a cycle and returns the configuration the
+// cycle runs with: cfg itself, or a copy with dryRun set while the ConfigMap sets mode: observe. A read that
+// fails keeps the override as it was, so an unreachable API server cannot turn aborts back on.
+func (c *Controller) applyOverride(ctx context.Context, cfg *config.Config, now time.Time) *config.Config {
+	mode, err := c.readOverrideMode(ctx)
+
+	c.mu.Lock()
+	previous := c.override
+	if err != nil {
+		c.mu.Unlock()
+		klog.Warningf("Failed to read the %s ConfigMap, keeping the override as it was: %v", OverrideConfigMapName, err)
+	} else {
+		switch {
+		case mode == OverrideModeObserve && previous == nil:
+			c.override = &OverrideStatus{Mode: mode, Since: now}
+		case mode != OverrideModeObserve:
+			c.override = nil
+		}
+		current := c.override
+		c.mu.Unlock()
+
+		switch {
+		case current != nil && previous == nil:
+			message := fmt.Sprintf("The %s ConfigMap sets mode: observe; aborts are disabled as with dryRun until it is removed", OverrideConfigMapName)
+			klog.Warning(message)
+			c.recordEvent(ctx, corev1.EventTypeWarning, eventReasonOverrideActive, message)
+		case current == nil && previous != nil:
+			message := fmt.Sprintf("The %s ConfigMap no longer sets mode: observe; the configured behavior is restored", OverrideConfigMapName)
+			klog.Warning(message)
+			c.recordEvent(ctx, corev1.EventTypeNormal, eventReasonOverrideRemoved, message)
+		}
+		previous = current
+	}
+
+	c.exporter.SetOverrideActive(previous != nil)
+	if previous == nil || cfg.DryRun {
+		return cfg
+	}
+	observed := *cfg
+	observed.DryRun = true
+	return &observed
+}
+
+// overrideReadTimeout bounds the read of the override ConfigMap, so it cannot hold up the cycle
+const overrideReadTimeout = 5 * time.Second
+
+// readOverrideMode returns the mode set by the override ConfigMap, or "" when there is none
+func (c *Controller) readOverrideMode(ctx context.Context) (string, error) {
+	namespace, err := c.identity.RequireNamespace("reading the override ConfigMap")
+	if err != nil {
+		klog.V(2).Infof("Not reading the override ConfigMap: %v", err)
+		return "", nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, overrideReadTimeout)
+	defer cancel()
+	configMap, err := c.kubeClient.CoreV1().ConfigMaps(namespace).Get(ctx, OverrideConfigMapName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	mode := configMap.Data["mode"]
+	if mode != "" && mode != OverrideModeObserve {
+		klog.Warningf("Ignoring unknown mode %q in the %s ConfigMap; the only mode is %q", mode, OverrideConfigMapName, OverrideModeObserve)
+		return "", nil
+	}
+	return mode, nil
+}
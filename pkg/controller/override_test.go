@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"aks-health-monitor/pkg/azure/fake"
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/metrics"
+	"aks-health-monitor/pkg/runtimeinfo"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// overrideConfigMap returns an override ConfigMap in namespace with the given data
+func overrideConfigMap(namespace string, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: OverrideConfigMapName}, Data: data}
+}
+
+// overrideGauge returns the override_observe_mode gauge in registry
+func overrideGauge(t *testing.T, registry *prometheus.Registry) float64 {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() == "aks_monitor_override_observe_mode" {
+			return family.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	t.Fatal("aks_monitor_override_observe_mode is not registered")
+	return 0
+}
+
+// overrideTestConfig returns a configuration that aborts every cycle the nodes are not ready, without cooldown
+func overrideTestConfig(t *testing.T) *config.Config {
+	cfg := testConfig(t)
+	cfg.Thresholds.PodChurnPerMinute = config.ThresholdDisabled
+	setMonitoredOperations(t, cfg, "- name: upgrade\n  abortCooldown: 0s")
+	return cfg
+}
+
+func TestOverridePrecedence(t *testing.T) {
+	tests := []struct {
+		name       string
+		dryRun     bool
+		configMap  *corev1.ConfigMap
+		wantAction Action
+	}{
+		{name: "no override", wantAction: ActionAborted},
+		{name: "observe", configMap: overrideConfigMap("aks-monitor", map[string]string{"mode": "observe"}), wantAction: ActionSuppressed},
+		{name: "observe with dryRun", dryRun: true, configMap: overrideConfigMap("aks-monitor", map[string]string{"mode": "observe"}), wantAction: ActionSuppressed},
+		{name: "dryRun without override", dryRun: true, wantAction: ActionSuppressed},
+		{name: "unknown mode is ignored", configMap: overrideConfigMap("aks-monitor", map[string]string{"mode": "off"}), wantAction: ActionAborted},
+		{name: "no mode", configMap: overrideConfigMap("aks-monitor", map[string]string{"reason": "incident"}), wantAction: ActionAborted},
+		{name: "other namespace", configMap: overrideConfigMap("default", map[string]string{"mode": "observe"}), wantAction: ActionAborted},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := overrideTestConfig(t)
+			cfg.DryRun = test.dryRun
+			objects := unreadyNodes(2)
+			if test.configMap != nil {
+				objects = append(objects, test.configMap)
+			}
+			c, azureClient := newTestController(t, cfg, objects...)
+			azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+
+			result := c.runCycle(context.Background())
+			if result.Action != test.wantAction {
+				t.Errorf("action = %s (%s), want %s", result.Action, result.SuppressionReason, test.wantAction)
+			}
+			if test.wantAction == ActionSuppressed && result.SuppressionReason != SuppressionDryRun {
+				t.Errorf("suppression reason = %s, want %s", result.SuppressionReason, SuppressionDryRun)
+			}
+			if wantAborts := map[bool]int{true: 1}[test.wantAction == ActionAborted]; len(azureClient.Aborts()) != wantAborts {
+				t.Errorf("aborts = %v, want %d", azureClient.Aborts(), wantAborts)
+			}
+			if cfg.DryRun != test.dryRun {
+				t.Error("the override changed the shared configuration")
+			}
+		})
+	}
+}
+
+func TestOverrideTakesEffectWithinOneCycle(t *testing.T) {
+	cfg := overrideTestConfig(t)
+	kubeClient := kubefake.NewSimpleClientset(unreadyNodes(2)...)
+	registry := prometheus.NewRegistry()
+	azureClient := fake.NewClient()
+	azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+	c, err := NewController(kubeClient, metrics.NewCollector(kubeClient, cfg.Collection), azureClient, cfg,
+		registry, &runtimeinfo.Info{Namespace: "aks-monitor", PodName: "aks-monitor-0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	configMaps := kubeClient.CoreV1().ConfigMaps("aks-monitor")
+
+	steps := []struct {
+		name       string
+		change     func() error
+		wantAction Action
+		wantActive bool
+	}{
+		{name: "configured behavior", wantAction: ActionAborted},
+		{
+			name: "override created",
+			change: func() error {
+				_, err := configMaps.Create(context.Background(), overrideConfigMap("aks-monitor", map[string]string{"mode": "observe"}), metav1.CreateOptions{})
+				return err
+			},
+			wantAction: ActionSuppressed,
+			wantActive: true,
+		},
+		{name: "override kept", wantAction: ActionSuppressed, wantActive: true},
+		{
+			name: "override changed to an unknown mode",
+			change: func() error {
+				_, err := configMaps.Update(context.Background(), overrideConfigMap("aks-monitor", map[string]string{"mode": "disabled"}), metav1.UpdateOptions{})
+				return err
+			},
+			wantAction: ActionAborted,
+		},
+		{
+			name: "override set again",
+			change: func() error {
+				_, err := configMaps.Update(context.Background(), overrideConfigMap("aks-monitor", map[string]string{"mode": "observe"}), metav1.UpdateOptions{})
+				return err
+			},
+			wantAction: ActionSuppressed,
+			wantActive: true,
+		},
+		{
+			name: "override removed",
+			change: func() error {
+				return configMaps.Delete(context.Background(), OverrideConfigMapName, metav1.DeleteOptions{})
+			},
+			wantAction: ActionAborted,
+		},
+	}
+
+	for _, step := range steps {
+		if step.change != nil {
+			if err := step.change(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// The first cycle after the change already runs with it
+		result := c.runCycle(context.Background())
+		if result.Action != step.wantAction {
+			t.Errorf("%s: action = %s (%s), want %s", step.name, result.Action, result.SuppressionReason, step.wantAction)
+		}
+		status := c.GetStatus()
+		if _, active := status["override"]; active != step.wantActive || status["dryRun"] != step.wantActive {
+			t.Errorf("%s: status override = %v, dryRun = %v, want active: %v", step.name, status["override"], status["dryRun"], step.wantActive)
+		}
+		if gauge := overrideGauge(t, registry); gauge != map[bool]float64{true: 1}[step.wantActive] {
+			t.Errorf("%s: override_observe_mode = %v, want active: %v", step.name, gauge, step.wantActive)
+		}
+	}
+
+	events := eventsByReason(t, kubeClient)
+	// Set twice and removed twice
+	if len(events[eventReasonOverrideActive]) != 2 || len(events[eventReasonOverrideRemoved]) != 2 {
+		t.Errorf("events = %d %s and %d %s, want two of each: an Event per change, not per cycle",
+			len(events[eventReasonOverrideActive]), eventReasonOverrideActive, len(events[eventReasonOverrideRemoved]), eventReasonOverrideRemoved)
+	}
+}
+
+func TestOverrideKeptWhenUnreadable(t *testing.T) {
+	cfg := overrideTestConfig(t)
+	kubeClient := kubefake.NewSimpleClientset(append(unreadyNodes(2), overrideConfigMap("aks-monitor", map[string]string{"mode": "observe"}))...)
+	c, azureClient := newTestControllerWithClient(t, cfg, kubeClient)
+	azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+
+	if result := c.runCycle(context.Background()); result.Action != ActionSuppressed {
+		t.Fatalf("action = %s, want suppressed by the override", result.Action)
+	}
+	kubeClient.PrependReactor("get", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewServiceUnavailable("etcd leader changed")
+	})
+	if result := c.runCycle(context.Background()); result.Action != ActionSuppressed || len(azureClient.Aborts()) != 0 {
+		t.Errorf("action = %s, aborts %v, want the override kept while the ConfigMap cannot be read", result.Action, azureClient.Aborts())
+	}
+}
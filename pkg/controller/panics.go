@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"aks-health-monitor/pkg/metrics"
+
+	"k8s.io/klog/v2"
+)
+
+// guard runs fn, converting a panic into an error so a bug triggered by unusual cluster state fails one
+// cycle instead of stopping protection. Panics recovered by collectors are counted under their own name.
+func (c *Controller) guard(component string, fn func() error) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			c.recordPanic(component, recovered, debug.Stack())
+			err = fmt.Errorf("panic in %s: %v", component, recovered)
+		}
+	}()
+
+	err = fn()
+	var panicErr *metrics.PanicError
+	if errors.As(err, &panicErr) {
+		c.recordPanic("collector/"+panicErr.Collector, panicErr.Value, panicErr.Stack)
+	}
+	return err
+}
+
+// runCycleSafely runs one cycle, recovering panics outside the guarded health check so the loop keeps running
+func (c *Controller) runCycleSafely(ctx context.Context) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			c.recordPanic("cycle", recovered, debug.Stack())
+		}
+	}()
+	c.runCycle(ctx)
+}
+
+// recordPanic logs a recovered panic with its stack and counts it toward the liveness limit
+func (c *Controller) recordPanic(component string, value interface{}, stack []byte) {
+	klog.Errorf("Recovered panic in %s: %v\n%s", component, value, stack)
+	c.exporter.RecordPanic(component)
+
+	now := time.Now()
+	c.mu.Lock()
+	c.panics = append(trimTimes(c.panics, now.Add(-c.cfg().PanicRecovery.Window)), now)
+	c.mu.Unlock()
+}
+
+// Healthy reports whether the controller is fit to keep running. It fails once the configured number of
+// panics were recovered within the window, so the pod is restarted from a clean state.
+func (c *Controller) Healthy() (bool, string) {
+	recovery := c.cfg().PanicRecovery
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	recent := len(trimTimes(c.panics, time.Now().Add(-recovery.Window)))
+	if recent >= recovery.MaxPanics {
+		return false, fmt.Sprintf("%d panics recovered in the last %s", recent, recovery.Window)
+	}
+	return true, ""
+}
+
+// trimTimes drops times before the cutoff
+func trimTimes(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && times[i].Before(cutoff) {
+		i++
+	}
+	return times[i:]
+}
@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/azure/fake"
+	"aks-health-monitor/pkg/metrics"
+	"aks-health-monitor/pkg/runtimeinfo"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// panicsRecorded returns the panics_total count of component in registry
+func panicsRecorded(t *testing.T, registry *prometheus.Registry, component string) float64 {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "aks_monitor_panics_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "component" && label.GetValue() == component {
+					return metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestLoopSurvivesPanickingCollector(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.PollInterval = 10 * time.Millisecond
+	cfg.PanicRecovery.MaxPanics = 2
+	cfg.PanicRecovery.Window = time.Hour
+
+	// The node list panics, as a collector dereferencing an unusual object would, on the first two cycles
+	var mu sync.Mutex
+	panics := 2
+	kubeClient := kubefake.NewSimpleClientset(node("node-0", corev1.ConditionTrue))
+	kubeClient.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if panics > 0 {
+			panics--
+			panic("nil pointer dereference")
+		}
+		return false, nil, nil
+	})
+	registry := prometheus.NewRegistry()
+	azureClient := fake.NewClient()
+	azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+	c, err := NewController(kubeClient, metrics.NewCollector(kubeClient, cfg.Collection), azureClient, cfg,
+		registry, &runtimeinfo.Info{Namespace: "aks-monitor", PodName: "aks-monitor-0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Run(ctx) }()
+
+	// The loop keeps running after the panics: wait for a cycle that completes cleanly
+	var history []HealthCheckResult
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		history = c.GetHistory()
+		if len(history) > 2 && history[len(history)-1].Error == "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatalf("no clean cycle after the panics, history: %+v", history)
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, result := range history[:2] {
+		if !strings.Contains(result.Error, "panic") {
+			t.Errorf("cycle error = %q, want the recovered panic to fail the cycle", result.Error)
+		}
+		if result.Action == ActionAborted {
+			t.Error("aborted on a cycle failed by a panic")
+		}
+	}
+	if recorded := panicsRecorded(t, registry, "collector/nodes"); recorded != 2 {
+		t.Errorf("panics_total{component=\"collector/nodes\"} = %v, want 2", recorded)
+	}
+	if healthy, reason := c.Healthy(); healthy || !strings.Contains(reason, "2 panics") {
+		t.Errorf("Healthy() = %v, %q, want unhealthy after panicRecovery.maxPanics panics", healthy, reason)
+	}
+	if len(azureClient.Aborts()) != 0 {
+		t.Errorf("aborted %v", azureClient.Aborts())
+	}
+}
+
+func TestHealthyForgetsPanicsOutsideWindow(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.PanicRecovery.MaxPanics = 1
+	cfg.PanicRecovery.Window = time.Minute
+	c, _ := newTestController(t, cfg)
+
+	c.recordPanic("cycle", "nil map", nil)
+	if healthy, _ := c.Healthy(); healthy {
+		t.Fatal("healthy after panicRecovery.maxPanics panics within the window")
+	}
+	c.mu.Lock()
+	c.panics[0] = time.Now().Add(-2 * time.Minute)
+	c.mu.Unlock()
+	if healthy, reason := c.Healthy(); !healthy {
+		t.Errorf("unhealthy (%s) although the panic is outside the window", reason)
+	}
+}
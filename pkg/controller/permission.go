@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// Reasons of the Events recorded when the abort permission is lost and restored
+const (
+	eventReasonPermissionLost     = "AbortPermissionLost"
+	eventReasonPermissionRestored = "AbortPermissionRestored"
+)
+
+// AbortPermissionStatus is the result of the last abort permission check
+type AbortPermissionStatus struct {
+	OK        bool      `json:"ok"`
+	CheckedAt time.Time `json:"checkedAt"`
+
+	// LastError is why the most recent check could not be made; OK and CheckedAt keep the last result
+	LastError string `json:"lastError,omitempty"`
+}
+
+// permissionTracker rate-limits the abort permission check and remembers its last result
+type permissionTracker struct {
+	lastAttempt time.Time
+	status      *AbortPermissionStatus
+}
+
+// checkAbortPermission re-checks, at most once per interval, that the identity aborts are sent with still
+// holds the abort permission on the cluster. Losing it is logged and recorded as a Warning Event while the
+// cluster is otherwise healthy, since it would only surface when an abort fails. A check that cannot be
+// made keeps the last result.
+func (c *Controller) checkAbortPermission(ctx context.Context, cfg *config.Config, now time.Time) {
+	if cfg.PermissionCheck.Skip {
+		return
+	}
+
+	c.mu.RLock()
+	lastAttempt := c.permission.lastAttempt
+	c.mu.RUnlock()
+	if !lastAttempt.IsZero() && now.Sub(lastAttempt) < cfg.PermissionCheck.Interval {
+		return
+	}
+
+	allowed, err := c.azureClient.HasAbortPermission(ctx)
+
+	c.mu.Lock()
+	c.permission.lastAttempt = now
+	previous := c.permission.status
+	if err != nil {
+		if previous != nil {
+			c.permission.status = &AbortPermissionStatus{OK: previous.OK, CheckedAt: previous.CheckedAt, LastError: err.Error()}
+		} else {
+			c.permission.status = &AbortPermissionStatus{LastError: err.Error()}
+		}
+		c.mu.Unlock()
+		klog.Warningf("Failed to check the abort permission: %v", err)
+		return
+	}
+	c.permission.status = &AbortPermissionStatus{OK: allowed, CheckedAt: now}
+	c.mu.Unlock()
+
+	c.exporter.SetAbortPermission(allowed)
+	switch {
+	case !allowed && (previous == nil || previous.CheckedAt.IsZero() || previous.OK):
+		message := "The identity aborts are sent with is no longer allowed Microsoft.ContainerService/managedClusters/abort/action on the cluster; aborts will fail until its role assignment is restored"
+		klog.Error(message)
+		c.recordEvent(ctx, corev1.EventTypeWarning, eventReasonPermissionLost, message)
+	case allowed && previous != nil && !previous.CheckedAt.IsZero() && !previous.OK:
+		message := "The identity aborts are sent with is allowed to abort operations on the cluster again"
+		klog.Info(message)
+		c.recordEvent(ctx, corev1.EventTypeNormal, eventReasonPermissionRestored, message)
+	}
+}
@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/metrics"
+
+	"k8s.io/klog/v2"
+)
+
+// SkippedMetric records a metric whose threshold was not evaluated in a cycle
+type SkippedMetric struct {
+	Type   metrics.MetricType `json:"type"`
+	Reason string             `json:"reason"`
+}
+
+// operationPhase describes how far the current operation has progressed
+type operationPhase struct {
+	// Elapsed is the time since the operation was first observed
+	elapsed time.Duration
+
+	// percentComplete approximates progress as the percentage of nodes on the target version; -1 when unknown
+	percentComplete int
+
+	// upgrading is set during upgrades, the only operations whose thresholds progress scaling adjusts
+	upgrading bool
+}
+
+// currentPhase computes the phase of the current operation. Node progress is only looked up when a
+// configured window needs it.
+func (c *Controller) currentPhase(ctx context.Context, cfg *config.Config, status *azure.OperationStatus, now time.Time) operationPhase {
+	c.mu.RLock()
+	startedAt := c.operationStartedAt
+	c.mu.RUnlock()
+
+	phase := operationPhase{percentComplete: -1, upgrading: status.OperationType == "Upgrading"}
+	if !startedAt.IsZero() {
+		phase.elapsed = now.Sub(startedAt)
+	}
+
+	scaling := phase.upgrading && len(cfg.ProgressScaling) > 0
+	if !windowsNeedProgress(cfg.MetricWindows) && !scaling {
+		return phase
+	}
+
+	percent, err := c.upgradedNodePercent(ctx, status)
+	if err != nil {
+		klog.Warningf("Cannot determine operation progress, percent-complete metric windows will not end early and progress-scaled thresholds use their start values: %v", err)
+		return phase
+	}
+	phase.percentComplete = percent
+	return phase
+}
+
+// windowsNeedProgress reports whether any window is bounded by percent complete
+func windowsNeedProgress(windows map[string]config.MetricWindow) bool {
+	for _, window := range windows {
+		if _, percent, err := window.Before(); err == nil && percent > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// upgradedNodePercent returns the percentage of nodes whose kubelet already runs the cluster's target version
+func (c *Controller) upgradedNodePercent(ctx context.Context, status *azure.OperationStatus) (int, error) {
+	snapshot, err := c.clusterSnapshot(ctx, status)
+	if err != nil {
+		return 0, err
+	}
+	targetVersion := snapshot.KubernetesVersion
+	if targetVersion == "" {
+		return 0, fmt.Errorf("cluster reports no target Kubernetes version")
+	}
+
+	summary, err := c.metricsCollector.CollectNodeSummary(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to collect node summary: %w", err)
+	}
+
+	return upgradedPercent(targetVersion, summary.KubeletVersions), nil
+}
+
+// upgradedPercent returns the percentage of kubelets matching the target version. The target may omit
+// the patch version (e.g. "1.28"), in which case any patch matches.
+func upgradedPercent(targetVersion string, kubeletVersions map[string]string) int {
+	if len(kubeletVersions) == 0 {
+		return 0
+	}
+
+	target := strings.TrimPrefix(targetVersion, "v")
+	upgraded := 0
+	for _, version := range kubeletVersions {
+		version = strings.TrimPrefix(version, "v")
+		if version == target || strings.HasPrefix(version, target+".") {
+			upgraded++
+		}
+	}
+
+	return (upgraded * 100) / len(kubeletVersions)
+}
+
+// windowSkipReason returns why a metric's threshold does not apply in this phase, or "" when it applies.
+// Windows whose progress bound cannot be determined keep applying so protection is not lost.
+func windowSkipReason(window config.MetricWindow, phase operationPhase) string {
+	if phase.elapsed < window.AppliesAfter {
+		return fmt.Sprintf("applies %s after operation start, operation started %s ago",
+			window.AppliesAfter, phase.elapsed.Round(time.Second))
+	}
+
+	duration, percent, err := window.Before()
+	if err != nil {
+		return ""
+	}
+	if duration > 0 && phase.elapsed >= duration {
+		return fmt.Sprintf("applies until %s after operation start, operation started %s ago",
+			duration, phase.elapsed.Round(time.Second))
+	}
+	if percent > 0 && phase.percentComplete >= percent {
+		return fmt.Sprintf("applies until %d%% complete, operation is %d%% complete", percent, phase.percentComplete)
+	}
+
+	return ""
+}
+
+// scaledThreshold returns the threshold of a progress-scaled metric in this phase and describes the scaling
+// for the evaluation. An upgrade whose progress is unknown uses the start value.
+func scaledThreshold(metricType metrics.MetricType, scaling config.ProgressScaling, phase operationPhase) (int, string) {
+	threshold := scaling.At(phase.percentComplete)
+	if phase.percentComplete < 0 {
+		klog.Infof("Threshold of %s is %s, the start of its progress scaling: upgrade progress is unknown", metricType, metricType.FormatValue(threshold))
+		return threshold, fmt.Sprintf("scaled from %s to %s, progress unknown", metricType.FormatValue(scaling.Start), metricType.FormatValue(scaling.End))
+	}
+	klog.Infof("Threshold of %s is %s at %d%% complete (scaled from %s to %s)", metricType, metricType.FormatValue(threshold),
+		phase.percentComplete, metricType.FormatValue(scaling.Start), metricType.FormatValue(scaling.End))
+	return threshold, fmt.Sprintf("scaled from %s to %s at %d%% complete", metricType.FormatValue(scaling.Start), metricType.FormatValue(scaling.End), phase.percentComplete)
+}
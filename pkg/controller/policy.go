@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/config"
+
+	"k8s.io/klog/v2"
+)
+
+// operationPolicyReasons are the suppression reasons checked by operationPolicySuppression, in order
+var operationPolicyReasons = []SuppressionReason{SuppressionAbortCooldown, SuppressionAlertOnly}
+
+// operationPolicySuppression applies the per-operation abort cooldown and action. The grace period needs
+// no check here: thresholds are not evaluated within it. It returns an empty reason when the abort may proceed.
+func (c *Controller) operationPolicySuppression(settings config.OperationSettings, now time.Time) (SuppressionReason, string) {
+	c.mu.RLock()
+	cooldown := c.cooldown
+	c.mu.RUnlock()
+
+	if !cooldown.since.IsZero() {
+		if since := now.Sub(cooldown.since); since < settings.AbortCooldown {
+			return SuppressionAbortCooldown, fmt.Sprintf("%s was aborted %s ago, within the %s abort cooldown for %s",
+				cooldown.operation, since.Round(time.Second), settings.AbortCooldown, settings.Name)
+		}
+	}
+
+	if settings.Action == config.OperationActionAlert {
+		return SuppressionAlertOnly, fmt.Sprintf("monitoredOperations action for %s is %q", settings.Name, config.OperationActionAlert)
+	}
+
+	return "", ""
+}
+
+// abortCooldown is the cooldown started by the last abort. It holds back further aborts of the same
+// operation, which ARM can still report in progress with the same violations for a few cycles.
+type abortCooldown struct {
+	operation string
+	since     time.Time
+}
+
+// expire ends the cooldown once the cluster reaches a terminal provisioning state or another operation
+// starts, so a new operation is never held back by the previous one's abort
+func (a *abortCooldown) expire(status *azure.OperationStatus) {
+	if a.since.IsZero() {
+		return
+	}
+	switch {
+	case status.Status == "Succeeded" || status.Status == "Canceled" || status.Status == "Failed":
+		klog.Infof("Abort cooldown for '%s' ended: cluster reached %s", a.operation, status.Status)
+	case status.InProgress && status.OperationType != a.operation:
+		klog.Infof("Abort cooldown for '%s' ended: operation '%s' started", a.operation, status.OperationType)
+	default:
+		return
+	}
+	*a = abortCooldown{}
+}
+
+// active reports whether the cooldown still holds back aborts at now
+func (a abortCooldown) active(cfg *config.Config, now time.Time) bool {
+	return !a.since.IsZero() && now.Sub(a.since) < cfg.OperationSettings(a.operation).AbortCooldown
+}
+
+// collectWithinGracePeriod collects and logs the metrics of a cycle within the operation's grace period,
+// without evaluating thresholds, so the expected disruption at the start of an operation neither aborts it
+// nor starts violation streaks
+func (c *Controller) collectWithinGracePeriod(ctx context.Context, cfg *config.Config, settings config.OperationSettings, status *azure.OperationStatus, result *HealthCheckResult, elapsed time.Duration) error {
+	collectedMetrics, err := c.metricsCollector.CollectMetrics(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to collect metrics: %w", err)
+	}
+	collectedMetrics = append(collectedMetrics, operationErrorMetric(status))
+	result.Metrics = collectedMetrics
+	result.Coverage = c.metricsCollector.Coverage()
+	result.GraceRemaining = settings.GracePeriod - elapsed
+	c.captureBaseline(cfg, status.OperationType, collectedMetrics, result.Timestamp)
+
+	values := make([]string, 0, len(collectedMetrics))
+	for _, metric := range collectedMetrics {
+		values = append(values, fmt.Sprintf("%s %s", metric.Type, metric))
+	}
+	klog.Infof("Operation '%s' started %s ago, within its %s grace period; not evaluating thresholds: %s",
+		status.OperationType, elapsed.Round(time.Second), settings.GracePeriod, strings.Join(values, ", "))
+	return nil
+}
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/metrics"
+)
+
+// Checks made by the pre-check
+const (
+	precheckNoOperation      = "no_operation_in_progress"
+	precheckNodesReady       = "nodes_ready"
+	precheckDisruptionBudget = "disruption_budgets"
+	precheckThresholds       = "thresholds"
+)
+
+// PrecheckResult is the verdict on whether the cluster is healthy enough to start an operation. It fails
+// when any check fails, and Reasons lists why.
+type PrecheckResult struct {
+	Timestamp   time.Time               `json:"timestamp"`
+	Passed      bool                    `json:"passed"`
+	Reasons     []string                `json:"reasons,omitempty"`
+	Checks      []PrecheckCheck         `json:"checks"`
+	Thresholds  config.ThresholdsConfig `json:"thresholds"`
+	Metrics     []metrics.MetricValue   `json:"metrics,omitempty"`
+	Evaluations []ThresholdEvaluation   `json:"evaluations,omitempty"`
+}
+
+// PrecheckCheck is the outcome of one pre-check
+type PrecheckCheck struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// check records the outcome of one pre-check. The detail only explains failures and is dropped otherwise.
+func (r *PrecheckResult) check(name string, passed bool, detail string) {
+	if passed {
+		r.Checks = append(r.Checks, PrecheckCheck{Name: name, Passed: true})
+		return
+	}
+	r.Checks = append(r.Checks, PrecheckCheck{Name: name, Detail: detail})
+	r.Passed = false
+	r.Reasons = append(r.Reasons, fmt.Sprintf("%s: %s", name, detail))
+}
+
+// Precheck runs the pre-check with the controller's Kubernetes and Azure clients. Metrics are collected
+// by a collector of its own, so the check does not disturb the state the health check cycles keep.
+func (c *Controller) Precheck(ctx context.Context) PrecheckResult {
+	cfg := c.cfg()
+	return RunPrecheck(ctx, metrics.NewCollector(c.kubeClient, cfg.Collection), c.azureClient, cfg)
+}
+
+// RunPrecheck reports whether the cluster is healthy enough to start an operation: no operation is in
+// progress, no node is NotReady, every PodDisruptionBudget allows a disruption and no metric exceeds the
+// pre-check thresholds. Without an Azure client the operation check fails, since it cannot be made.
+// Nothing is recorded or acted on.
+func RunPrecheck(ctx context.Context, collector *metrics.Collector, azureClient azure.Interface, cfg *config.Config) PrecheckResult {
+	result := PrecheckResult{Timestamp: time.Now(), Passed: true, Thresholds: cfg.PrecheckThresholds()}
+
+	if azureClient == nil {
+		result.check(precheckNoOperation, false, "the Azure client is unavailable")
+	} else if status, err := azureClient.GetClusterOperationStatus(ctx); err != nil {
+		result.check(precheckNoOperation, false, err.Error())
+	} else if status.InProgress {
+		result.check(precheckNoOperation, false, fmt.Sprintf("operation '%s' is in progress", status.OperationType))
+	} else {
+		result.check(precheckNoOperation, true, "")
+	}
+
+	collected, err := collector.CollectMetrics(ctx)
+	if err != nil {
+		result.check(precheckThresholds, false, fmt.Sprintf("failed to collect metrics: %v", err))
+		return result
+	}
+	result.Metrics = collected
+	notReady := notReadyNodes(collected)
+	result.check(precheckNodesReady, notReady == 0, fmt.Sprintf("NotReady nodes: %d", notReady))
+
+	if blocking, err := collector.BlockingDisruptionBudgets(ctx); err != nil {
+		result.check(precheckDisruptionBudget, false, err.Error())
+	} else {
+		result.check(precheckDisruptionBudget, len(blocking) == 0, strings.Join(blocking, "; "))
+	}
+
+	c := &Controller{metricsCollector: collector}
+	c.activeConfig.Store(cfg)
+	result.Evaluations = c.evaluateThresholds(cfg, result.Thresholds, collected, operationPhase{percentComplete: -1}, nil)
+	var violations []string
+	for _, evaluation := range result.Evaluations {
+		if evaluation.Violated {
+			violations = append(violations, evaluation.Violation)
+		}
+	}
+	result.check(precheckThresholds, len(violations) == 0, strings.Join(violations, "; "))
+	return result
+}
+
+// notReadyNodes returns the number of NotReady nodes among the collected metrics
+func notReadyNodes(collected []metrics.MetricValue) int64 {
+	for _, metric := range collected {
+		if metric.Type == metrics.NotReadyNodesPercentMetric && metric.Fraction != nil {
+			return metric.Fraction.Numerator
+		}
+	}
+	return 0
+}
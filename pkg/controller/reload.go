@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"aks-health-monitor/pkg/config"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// ReloadConfig applies a configuration revision picked up by the config watcher
+func (c *Controller) ReloadConfig(ctx context.Context, newConfig *config.Config) {
+	c.keepRejectedCollectors(ctx, newConfig)
+	c.exporter.RecordConfigReload("applied")
+	changes := c.ApplyConfig(newConfig)
+	if len(changes) > 0 {
+		c.recordEvent(ctx, corev1.EventTypeNormal, "ConfigReloaded", fmt.Sprintf("Applied %d configuration change(s) from the ConfigMap", len(changes)))
+	}
+}
+
+// RejectConfig records a configuration revision that failed to load or validate. The active
+// configuration stays in effect.
+func (c *Controller) RejectConfig(ctx context.Context, err error) {
+	c.exporter.RecordConfigReload("rejected")
+	klog.Errorf("Rejected configuration reload, keeping the active configuration: %v", err)
+	c.recordEvent(ctx, corev1.EventTypeWarning, "ConfigReloadRejected", fmt.Sprintf("Rejected configuration reload, keeping the active configuration: %v", err))
+}
+
+// keepRejectedCollectors keeps the active block of each collector whose new block failed validation, so a
+// bad block is rejected on its own while the rest of the revision, and every other collector, is applied
+func (c *Controller) keepRejectedCollectors(ctx context.Context, newConfig *config.Config) {
+	rejected := newConfig.RejectedCollectors()
+	if len(rejected) == 0 {
+		return
+	}
+
+	active := c.cfg().Collectors
+	collectors := make(config.CollectorsConfig, len(newConfig.Collectors)+len(rejected))
+	for name, block := range newConfig.Collectors {
+		collectors[name] = block
+	}
+	for _, block := range rejected {
+		if previous, ok := active[block.Name]; ok {
+			collectors[block.Name] = previous
+		}
+		message := fmt.Sprintf("Rejected the collectors.%s block, keeping its active settings: %v", block.Name, block.Err)
+		klog.Warning(message)
+		c.recordEvent(ctx, corev1.EventTypeWarning, "CollectorConfigRejected", message)
+	}
+	newConfig.Collectors = collectors
+}
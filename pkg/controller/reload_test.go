@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"testing"
+)
+
+func TestApplyConfigTracksGenerations(t *testing.T) {
+	cfg := testConfig(t)
+	c, _ := newTestController(t, cfg)
+
+	// A reload that changes nothing leaves no trace
+	unchanged := *cfg
+	if changes := c.ApplyConfig(&unchanged); len(changes) != 0 {
+		t.Errorf("no-op reload changes = %v, want none", changes)
+	}
+	status := c.GetStatus()
+	if status["configGeneration"] != int64(0) || status["lastConfigChange"] != nil {
+		t.Errorf("status after a no-op reload = generation %v, last change %v, want 0 and none", status["configGeneration"], status["lastConfigChange"])
+	}
+
+	changed := *cfg
+	changed.Thresholds.PendingPodsPercent = 35
+	changed.Azure.ClientSecret = "rotated"
+	changes := c.ApplyConfig(&changed)
+	if len(changes) != 2 {
+		t.Fatalf("changes = %v, want the threshold and the secret", changes)
+	}
+	if c.GetConfig().Thresholds.PendingPodsPercent != 35 {
+		t.Error("the changed configuration is not in effect")
+	}
+
+	status = c.GetStatus()
+	last, ok := status["lastConfigChange"].(*ConfigChange)
+	if status["configGeneration"] != int64(1) || !ok || last.Generation != 1 {
+		t.Fatalf("status = generation %v, last change %+v, want generation 1", status["configGeneration"], status["lastConfigChange"])
+	}
+	for _, change := range last.Changes {
+		if change.Path == "azure.clientSecret" && (change.Old != "REDACTED" || change.New != "REDACTED") {
+			t.Errorf("secret change = %v, want it redacted", change)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"aks-health-monitor/pkg/azure/fake"
+	"aks-health-monitor/pkg/metrics"
+	"aks-health-monitor/pkg/notify"
+	"aks-health-monitor/pkg/runtimeinfo"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files under testdata")
+
+// assertGolden compares got with the golden file testdata/name, rewriting the file with -update
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file (rerun with -update if the change is intended)\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// runningPod returns a Running pod
+func runningPod(namespace, name string) *corev1.Pod {
+	pod := pendingPod(namespace, name)
+	pod.Status.Phase = corev1.PodRunning
+	return pod
+}
+
+func TestRawCountsInOutputs(t *testing.T) {
+	cfg := testConfig(t)
+	setMonitoredOperations(t, cfg, "- name: upgrade\n  action: alert")
+	objects := []runtime.Object{node("ready-0", corev1.ConditionTrue), node("ready-1", corev1.ConditionTrue)}
+	objects = append(objects, unreadyNodes(2)...)
+	for i := 0; i < 8; i++ {
+		if i < 3 {
+			objects = append(objects, pendingPod("default", fmt.Sprintf("web-%d", i)))
+		} else {
+			objects = append(objects, runningPod("default", fmt.Sprintf("web-%d", i)))
+		}
+	}
+	kubeClient := kubefake.NewSimpleClientset(objects...)
+	registry := prometheus.NewRegistry()
+	azureClient := fake.NewClient()
+	azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+	c, err := NewController(kubeClient, metrics.NewCollector(kubeClient, cfg.Collection), azureClient, cfg,
+		registry, &runtimeinfo.Info{Namespace: "aks-monitor", PodName: "aks-monitor-0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := c.runCycle(context.Background())
+
+	var rendered strings.Builder
+	fmt.Fprintln(&rendered, "# Violations")
+	for _, violation := range result.Violations {
+		fmt.Fprintln(&rendered, violation)
+	}
+	fmt.Fprintln(&rendered, "# Events")
+	for _, event := range eventsByReason(t, kubeClient)[eventReasonViolation] {
+		fmt.Fprintln(&rendered, event.Message)
+	}
+	fmt.Fprintln(&rendered, "# Notification violations")
+	notification := c.notification(cfg, &result, notify.EventViolation, SeverityAbort)
+	violations, err := json.MarshalIndent(notification.Violations, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintln(&rendered, string(violations))
+	assertGolden(t, "raw_counts.golden", []byte(rendered.String()))
+
+	exposition, err := os.Open(filepath.Join("testdata", "raw_counts.prom"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exposition.Close()
+	err = testutil.GatherAndCompare(registry, exposition,
+		"aks_monitor_metric_value_count", "aks_monitor_metric_value_total")
+	if err != nil {
+		t.Error(err)
+	}
+}
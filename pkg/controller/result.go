@@ -0,0 +1,318 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/exporter"
+	"aks-health-monitor/pkg/metrics"
+)
+
+// Action is the outcome of a health check cycle
+type Action string
+
+const (
+	// ActionNone means no violations were found or no operation was in progress
+	ActionNone Action = "none"
+	// ActionAborted means the operation was aborted
+	ActionAborted Action = "aborted"
+	// ActionSuppressed means violations were found but the abort was suppressed
+	ActionSuppressed Action = "suppressed"
+)
+
+// Severity is the tier of threshold a metric crossed
+type Severity string
+
+const (
+	// SeverityWarn means the metric crossed its warn threshold but not its abort threshold; it is reported
+	// but never aborts
+	SeverityWarn Severity = "warn"
+	// SeverityAbort means the metric violated its abort threshold
+	SeverityAbort Severity = "abort"
+)
+
+// SuppressionReason is a machine-readable code explaining why violations did not lead to an abort
+type SuppressionReason string
+
+const (
+	// SuppressionAutoscalerInitiated means the Scaling operation was attributed to the cluster autoscaler
+	SuppressionAutoscalerInitiated SuppressionReason = "autoscaler_initiated"
+	// SuppressionTrustedInitiator means the activity log attributes the operation to a trusted principal
+	SuppressionTrustedInitiator SuppressionReason = "trusted_initiator"
+	// SuppressionUnsafeAbort means an abort safety invariant failed
+	SuppressionUnsafeAbort SuppressionReason = "abort_unsafe"
+	// SuppressionIncompleteData means chunked collection has not yet covered every namespace
+	SuppressionIncompleteData SuppressionReason = "incomplete_data"
+	// SuppressionAbortCooldown means the previous abort was too recent
+	SuppressionAbortCooldown SuppressionReason = "abort_cooldown"
+	// SuppressionAlertOnly means the operation is configured to alert instead of abort
+	SuppressionAlertOnly SuppressionReason = "alert_only"
+	// SuppressionAbortUnavailable means the abort failed fast because the Azure write circuit is open
+	SuppressionAbortUnavailable SuppressionReason = "abort_unavailable"
+	// SuppressionOperationFinished means ARM answered the abort with 409 Conflict: the operation finished
+	// before the abort could take effect
+	SuppressionOperationFinished SuppressionReason = "operation_finished"
+	// SuppressionViolationStreak means no metric has violated for the required number of consecutive cycles
+	SuppressionViolationStreak SuppressionReason = "violation_streak"
+	// SuppressionDryRun means every policy allowed the abort but dryRun is set
+	SuppressionDryRun SuppressionReason = "dry_run"
+)
+
+// HealthState summarises a cycle for dashboards and alerting
+type HealthState int
+
+const (
+	// HealthHealthy means no thresholds were violated and nothing went wrong
+	HealthHealthy HealthState = 0
+	// HealthDegraded means the cluster needs attention but the action policy does not call for an abort:
+	// violations on an alert-only operation, short of consecutiveViolations or attributed to the autoscaler or a trusted initiator,
+	// metrics beyond only their warn threshold, a failed operation, or a cycle that could not be evaluated
+	HealthDegraded HealthState = 1
+	// HealthAbortWorthy means violations the action policy would abort for, whether the abort was issued,
+	// failed, or held back by a safeguard (incomplete data, failed safety invariant or abort cooldown)
+	HealthAbortWorthy HealthState = 2
+)
+
+// String returns the state's name as documented for the health state gauge
+func (s HealthState) String() string {
+	switch s {
+	case HealthHealthy:
+		return "healthy"
+	case HealthDegraded:
+		return "degraded"
+	case HealthAbortWorthy:
+		return "abort-worthy"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// HealthCheckResult captures everything observed and decided during one health check cycle
+type HealthCheckResult struct {
+	Timestamp           time.Time                    `json:"timestamp"`
+	TraceID             string                       `json:"traceId,omitempty"`
+	MetricsOnly         bool                         `json:"metricsOnly,omitempty"`
+	OperationInProgress bool                         `json:"operationInProgress"`
+	OperationType       string                       `json:"operationType,omitempty"`
+	Upgrade             *azure.UpgradeClassification `json:"upgrade,omitempty"`
+	Initiator           *OperationInitiator          `json:"initiator,omitempty"`
+	ProvisioningState   string                       `json:"provisioningState,omitempty"`
+	StatusSource        string                       `json:"statusSource,omitempty"`
+	StatusStaleFor      time.Duration                `json:"statusStaleFor,omitempty"`
+	GraceRemaining      time.Duration                `json:"graceRemaining,omitempty"` // Left of the operation's grace period; thresholds were not evaluated
+	Metrics             []metrics.MetricValue        `json:"metrics,omitempty"`
+	Coverage            metrics.Coverage             `json:"coverage"`
+	Evaluations         []ThresholdEvaluation        `json:"evaluations,omitempty"`
+	Violations          []string                     `json:"violations,omitempty"`
+	Warnings            []string                     `json:"warnings,omitempty"` // Metrics beyond their warn threshold only
+	WarningRefs         []ViolationRef               `json:"warningRefs,omitempty"`
+	NewWarnings         []ViolationRef               `json:"newWarnings,omitempty"` // Warned now but not in the previous cycle
+	ViolationRefs       []ViolationRef               `json:"violationRefs,omitempty"`
+	NewViolations       []ViolationRef               `json:"newViolations,omitempty"`      // Violated now but not in the previous cycle
+	ResolvedViolations  []ViolationRef               `json:"resolvedViolations,omitempty"` // Violated in the previous cycle but not now
+	Skipped             []SkippedMetric              `json:"skipped,omitempty"`
+	SuppressionChecks   []SuppressionCheck           `json:"suppressionChecks,omitempty"`
+	Action              Action                       `json:"action"`
+	SuppressionReason   SuppressionReason            `json:"suppressionReason,omitempty"`
+	SuppressionDetail   string                       `json:"suppressionDetail,omitempty"`
+	CriticalAbort       *CriticalAbort               `json:"criticalAbort,omitempty"`
+	DiagnosticBundle    string                       `json:"diagnosticBundle,omitempty"` // Location of the bundle captured after an abort
+	AbortError          string                       `json:"abortError,omitempty"`       // Why Azure did not accept the abort
+	OperationFailed     *OperationFailure            `json:"operationFailed,omitempty"`
+	SuspectedStuck      *StuckOperation              `json:"suspectedStuck,omitempty"`
+	MonitoringStopped   string                       `json:"monitoringStopped,omitempty"` // Reason, on the entry written at shutdown
+	Error               string                       `json:"error,omitempty"`
+	HealthState         HealthState                  `json:"healthState"`
+}
+
+// ThresholdEvaluation is the outcome of comparing one metric against its threshold
+type ThresholdEvaluation struct {
+	Metric     metrics.MetricType `json:"metric"`
+	Value      int                `json:"value"`
+	Fraction   *metrics.Fraction  `json:"fraction,omitempty"`
+	Threshold  int                `json:"threshold"`
+	Violated   bool               `json:"violated"`
+	Violation  string             `json:"violation,omitempty"`
+	SkipReason string             `json:"skipReason,omitempty"`
+
+	// Severity is the tier the metric crossed, warn or abort; empty while the metric is within its thresholds
+	Severity Severity `json:"severity,omitempty"`
+
+	// WarnThreshold is the metric's warn threshold, when it has one, and Warning describes a crossing of it
+	WarnThreshold *int   `json:"warnThreshold,omitempty"`
+	Warning       string `json:"warning,omitempty"`
+
+	// Scaling describes how progress scaling derived the threshold during an upgrade
+	Scaling string `json:"scaling,omitempty"`
+
+	// Baseline is the metric's value when the operation was first observed, set when the threshold is
+	// the baseline plus the metric's baselineDelta
+	Baseline *int `json:"baseline,omitempty"`
+
+	// Streak is how many consecutive cycles the metric has violated, including this one, out of the
+	// StreakRequired before it counts toward an abort; both are only set on violations
+	Streak         int `json:"streak,omitempty"`
+	StreakRequired int `json:"streakRequired,omitempty"`
+
+	// Description and RunbookURL come from the metric's thresholdGuidance and are only set on violations
+	Description string `json:"description,omitempty"`
+	RunbookURL  string `json:"runbookURL,omitempty"`
+}
+
+// SuppressionCheck records a suppression policy considered before aborting
+type SuppressionCheck struct {
+	Reason     SuppressionReason `json:"reason"`
+	Suppressed bool              `json:"suppressed"`
+	Detail     string            `json:"detail,omitempty"`
+}
+
+// healthState derives the cycle's health state from its violations, warnings and action policy outcome
+func (r *HealthCheckResult) healthState() HealthState {
+	if len(r.Violations) == 0 {
+		if r.Error != "" || r.OperationFailed != nil || len(r.Warnings) > 0 {
+			return HealthDegraded
+		}
+		return HealthHealthy
+	}
+
+	if r.Action == ActionSuppressed {
+		switch r.SuppressionReason {
+		case SuppressionAlertOnly, SuppressionAutoscalerInitiated, SuppressionTrustedInitiator, SuppressionViolationStreak:
+			return HealthDegraded
+		}
+	}
+	return HealthAbortWorthy
+}
+
+// recordEvaluations stores threshold evaluations and derives the violation, warning and skip lists from them
+func (r *HealthCheckResult) recordEvaluations(evaluations []ThresholdEvaluation) {
+	r.Evaluations = evaluations
+	for _, evaluation := range evaluations {
+		switch {
+		case evaluation.SkipReason != "":
+			r.Skipped = append(r.Skipped, SkippedMetric{Type: evaluation.Metric, Reason: evaluation.SkipReason})
+		case evaluation.Violated:
+			r.Violations = append(r.Violations, evaluation.Violation)
+			r.ViolationRefs = append(r.ViolationRefs, newViolationRef(evaluation.Metric, ruleThreshold))
+		case evaluation.Severity == SeverityWarn:
+			r.Warnings = append(r.Warnings, evaluation.Warning)
+			r.WarningRefs = append(r.WarningRefs, newViolationRef(evaluation.Metric, ruleWarn))
+		}
+	}
+}
+
+// thresholdStates converts the cycle's evaluations for the violation and threshold gauges. Metrics outside
+// their window were not evaluated and are left out. Violations short of their consecutive violations are
+// not active, since the controller does not act on them.
+func (r *HealthCheckResult) thresholdStates() []exporter.ThresholdState {
+	states := make([]exporter.ThresholdState, 0, len(r.Evaluations))
+	for _, evaluation := range r.Evaluations {
+		if evaluation.SkipReason != "" {
+			continue
+		}
+		states = append(states, exporter.ThresholdState{
+			Metric:    string(evaluation.Metric),
+			Threshold: evaluation.Threshold,
+			Active:    evaluation.Violated && evaluation.Streak >= evaluation.StreakRequired,
+		})
+	}
+	return states
+}
+
+// guidedViolations returns the violations with the description and runbook link of each violated metric
+// appended, for messages read by whoever responds to them
+func (r *HealthCheckResult) guidedViolations() []string {
+	var violations []string
+	for _, evaluation := range r.Evaluations {
+		if !evaluation.Violated {
+			continue
+		}
+		violation := evaluation.Violation
+		if evaluation.Description != "" {
+			violation = fmt.Sprintf("%s: %s", violation, evaluation.Description)
+		}
+		if evaluation.RunbookURL != "" {
+			violation = fmt.Sprintf("%s (runbook: %s)", violation, evaluation.RunbookURL)
+		}
+		violations = append(violations, violation)
+	}
+	return violations
+}
+
+// consider records a suppression policy that was checked and did not suppress the abort
+func (r *HealthCheckResult) consider(reason SuppressionReason, detail string) {
+	r.SuppressionChecks = append(r.SuppressionChecks, SuppressionCheck{Reason: reason, Detail: detail})
+}
+
+// suppress marks the result as having violations that were not acted on
+func (r *HealthCheckResult) suppress(reason SuppressionReason, detail string) {
+	r.Action = ActionSuppressed
+	r.SuppressionReason = reason
+	r.SuppressionDetail = detail
+	r.SuppressionChecks = append(r.SuppressionChecks, SuppressionCheck{Reason: reason, Suppressed: true, Detail: detail})
+}
+
+// defaultHistorySize is the number of recent results kept in memory
+const defaultHistorySize = 100
+
+// resultHistory is a bounded, concurrency-safe buffer of recent health check results
+type resultHistory struct {
+	mu      sync.RWMutex
+	results []HealthCheckResult
+	size    int
+}
+
+// newResultHistory creates a history buffer holding at most size results
+func newResultHistory(size int) *resultHistory {
+	return &resultHistory{size: size}
+}
+
+// add appends a result, evicting the oldest one when full
+func (h *resultHistory) add(result HealthCheckResult) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.results = append(h.results, result)
+	if len(h.results) > h.size {
+		h.results = h.results[len(h.results)-h.size:]
+	}
+}
+
+// list returns a copy of the results, oldest first
+func (h *resultHistory) list() []HealthCheckResult {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	results := make([]HealthCheckResult, len(h.results))
+	copy(results, h.results)
+	return results
+}
+
+// last returns the most recent result, if any
+func (h *resultHistory) last() (HealthCheckResult, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if len(h.results) == 0 {
+		return HealthCheckResult{}, false
+	}
+	return h.results[len(h.results)-1], true
+}
+
+// thresholdTiers returns the tier each metric is in after an evaluation: ok, warn or abort. Metrics outside
+// their window were not evaluated and are left out.
+func thresholdTiers(evaluations []ThresholdEvaluation) map[metrics.MetricType]string {
+	tiers := make(map[metrics.MetricType]string, len(evaluations))
+	for _, evaluation := range evaluations {
+		if evaluation.SkipReason != "" {
+			continue
+		}
+		tiers[evaluation.Metric] = "ok"
+		if evaluation.Severity != "" {
+			tiers[evaluation.Metric] = string(evaluation.Severity)
+		}
+	}
+	return tiers
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/metrics"
+)
+
+// evaluateAbortSafety checks the configured invariants and returns a description of the first one that fails.
+// An empty string means aborting is considered safe.
+func (c *Controller) evaluateAbortSafety(ctx context.Context, status *azure.OperationStatus, safety config.AbortSafetyConfig) (string, error) {
+	summary, err := c.metricsCollector.CollectNodeSummary(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to collect node summary: %w", err)
+	}
+
+	if err := checkSchedulableCapacity(summary, safety.MinSchedulableCapacityPercent); err != nil {
+		return err.Error(), nil
+	}
+
+	snapshot, err := c.clusterSnapshot(ctx, status)
+	if err != nil {
+		return "", err
+	}
+	if err := checkVersionSkew(snapshot.ControlPlaneVersion(), summary.KubeletVersions, safety.MaxKubeletMinorSkew); err != nil {
+		return err.Error(), nil
+	}
+
+	return "", nil
+}
+
+// checkSchedulableCapacity verifies that at least minPercent of nodes are Ready and schedulable
+func checkSchedulableCapacity(summary *metrics.NodeSummary, minPercent int) error {
+	if summary.TotalNodes == 0 {
+		return fmt.Errorf("no nodes found, cannot verify schedulable capacity")
+	}
+
+	schedulablePercent := (summary.SchedulableNodes * 100) / summary.TotalNodes
+	if schedulablePercent < minPercent {
+		return fmt.Errorf("only %d%% of nodes (%d/%d) are schedulable, below the required %d%%",
+			schedulablePercent, summary.SchedulableNodes, summary.TotalNodes, minPercent)
+	}
+
+	return nil
+}
+
+// checkVersionSkew verifies that leaving the cluster as-is would not violate the kubelet version skew policy:
+// kubelets must not be newer than the control plane nor more than maxMinorSkew minor versions older
+func checkVersionSkew(controlPlaneVersion string, kubeletVersions map[string]string, maxMinorSkew int) error {
+	cpMajor, cpMinor, err := parseMinorVersion(controlPlaneVersion)
+	if err != nil {
+		return fmt.Errorf("cannot verify version skew: control plane version: %v", err)
+	}
+
+	// Sort node names so the reported offender is deterministic
+	nodeNames := make([]string, 0, len(kubeletVersions))
+	for name := range kubeletVersions {
+		nodeNames = append(nodeNames, name)
+	}
+	sort.Strings(nodeNames)
+
+	for _, name := range nodeNames {
+		major, minor, err := parseMinorVersion(kubeletVersions[name])
+		if err != nil {
+			return fmt.Errorf("cannot verify version skew: node %s: %v", name, err)
+		}
+		if major != cpMajor || minor > cpMinor {
+			return fmt.Errorf("node %s kubelet %s is newer than control plane %s", name, kubeletVersions[name], controlPlaneVersion)
+		}
+		if cpMinor-minor > maxMinorSkew {
+			return fmt.Errorf("node %s kubelet %s is %d minor versions behind control plane %s (max %d)",
+				name, kubeletVersions[name], cpMinor-minor, controlPlaneVersion, maxMinorSkew)
+		}
+	}
+
+	return nil
+}
+
+// parseMinorVersion extracts the major and minor components from versions like "v1.28.3" or "1.28"
+func parseMinorVersion(version string) (int, int, error) {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("invalid version %q", version)
+	}
+
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid major version in %q", version)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid minor version in %q", version)
+	}
+
+	return major, minor, nil
+}
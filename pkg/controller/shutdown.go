@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"aks-health-monitor/pkg/exporter"
+	"aks-health-monitor/pkg/notify"
+	"aks-health-monitor/pkg/storage"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// ShutdownTimeout bounds the final writes made when the controller stops
+const ShutdownTimeout = 5 * time.Second
+
+// statusConfigMapName is the ConfigMap, in the controller namespace, holding whether monitoring is running
+// and the last status snapshot written on shutdown
+const statusConfigMapName = eventComponent + "-status"
+
+// Keys of the status ConfigMap
+const (
+	statusKeyMonitoringStopped = "monitoringStopped"
+	statusKeyReason            = "reason"
+	statusKeyStartedAt         = "startedAt"
+	statusKeyStoppedAt         = "stoppedAt"
+	statusKeySnapshot          = "status.json"
+)
+
+// markMonitoringStarted clears the stopped marker left in the status ConfigMap by a previous shutdown
+func (c *Controller) markMonitoringStarted(ctx context.Context) {
+	c.writeStatusConfigMap(ctx, func(data map[string]string) {
+		data[statusKeyMonitoringStopped] = "false"
+		data[statusKeyStartedAt] = time.Now().UTC().Format(time.RFC3339)
+		delete(data, statusKeyReason)
+		delete(data, statusKeyStoppedAt)
+	})
+}
+
+// RunUntilSignal calls run and, on the first signal received from signals, calls stop to cancel run's
+// context. Once run has returned, Shutdown records the final status with the signal as the reason, so the
+// final writes are done when RunUntilSignal returns and the process may exit. An error of run is returned
+// without a shutdown.
+func (c *Controller) RunUntilSignal(ctx context.Context, stop context.CancelFunc, signals <-chan os.Signal, run func(context.Context) error) error {
+	reasons := make(chan string, 1)
+	go func() {
+		select {
+		case sig := <-signals:
+			klog.Infof("Received shutdown signal %s", sig)
+			reasons <- "received " + sig.String()
+			stop()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := run(ctx); err != nil {
+		return err
+	}
+
+	// The reason is sent before the context is cancelled, so it is there whenever a signal stopped run
+	reason := "context cancelled"
+	select {
+	case reason = <-reasons:
+	default:
+	}
+	c.Shutdown(reason)
+	return nil
+}
+
+// Shutdown records the controller's final view before the process exits: a history entry carrying the
+// reason, the status ConfigMap marked as stopped with a status snapshot, and, when an operation is still
+// in progress, a MonitoringStopped Event and a low-priority monitoringStopped notification, since that
+// operation is no longer guarded. It is called after Run returns and is bounded by ShutdownTimeout, which
+// also bounds delivering the notification.
+func (c *Controller) Shutdown(reason string) {
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+
+	klog.Infof("Monitoring stopped (%s), recording final status", reason)
+	result := c.finalResult(reason)
+	c.history.add(result)
+
+	// Run waited for its notifier to stop, so deliver the notification alongside the final writes
+	if result.OperationInProgress && c.notifier != nil {
+		notification := c.notification(c.cfg(), &result, notify.EventMonitoringStopped, SeverityAbort)
+		notification.Priority = notify.PriorityLow
+		notification.Reason = reason
+		c.notifier.Notify(notification)
+
+		flushed := make(chan struct{})
+		go func() {
+			defer close(flushed)
+			c.notifier.Flush(ctx)
+		}()
+		defer func() { <-flushed }()
+	}
+
+	c.saveFinalResult(ctx, result)
+
+	snapshot, err := json.Marshal(c.GetStatus())
+	if err != nil {
+		klog.Errorf("Failed to encode final status snapshot: %v", err)
+	}
+	c.writeStatusConfigMap(ctx, func(data map[string]string) {
+		data[statusKeyMonitoringStopped] = "true"
+		data[statusKeyReason] = reason
+		data[statusKeyStoppedAt] = result.Timestamp.UTC().Format(time.RFC3339)
+		if snapshot != nil {
+			data[statusKeySnapshot] = string(snapshot)
+		}
+	})
+
+	if result.OperationInProgress {
+		message := fmt.Sprintf("Monitoring of operation '%s' on cluster %s stopped (%s); the operation is no longer guarded",
+			result.OperationType, c.cfg().Azure.ClusterName, reason)
+		if len(result.Violations) > 0 {
+			message += fmt.Sprintf(". Unresolved violations: %s", strings.Join(result.Violations, ", "))
+		}
+		c.recordEvent(ctx, corev1.EventTypeNormal, "MonitoringStopped", message)
+	}
+}
+
+// finalResult builds the last history entry from the current operation and the most recent result's
+// metrics and violations, which are unresolved as far as the monitor knows
+func (c *Controller) finalResult(reason string) HealthCheckResult {
+	result := HealthCheckResult{
+		Timestamp:         time.Now(),
+		TraceID:           exporter.NewTraceID(),
+		Action:            ActionNone,
+		MonitoringStopped: reason,
+	}
+	if last, ok := c.history.last(); ok {
+		result.Metrics = last.Metrics
+		result.Evaluations = last.Evaluations
+		result.Violations = last.Violations
+		result.ViolationRefs = last.ViolationRefs
+		result.Coverage = last.Coverage
+		result.HealthState = last.HealthState
+	}
+
+	c.mu.RLock()
+	result.MetricsOnly = c.metricsOnlyReason != ""
+	result.OperationInProgress = c.operationInProgress
+	result.OperationType = c.currentOperation
+	c.mu.RUnlock()
+
+	return result
+}
+
+// saveFinalResult saves the final result to the durable store synchronously, unlike persistResult, so it
+// is written before the process exits
+func (c *Controller) saveFinalResult(ctx context.Context, result HealthCheckResult) {
+	store := c.resultStore()
+	if store == nil {
+		return
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		klog.Errorf("Failed to encode final result for history storage: %v", err)
+		return
+	}
+	if err := store.Save(ctx, storage.Record{Timestamp: result.Timestamp, TraceID: result.TraceID, Data: data}); err != nil {
+		klog.Warningf("Failed to save final result %s to history storage: %v", result.TraceID, err)
+	}
+}
+
+// writeStatusConfigMap applies update to the data of the status ConfigMap, creating it if needed
+func (c *Controller) writeStatusConfigMap(ctx context.Context, update func(map[string]string)) {
+	namespace, err := c.identity.RequireNamespace("writing the status ConfigMap")
+	if err != nil {
+		klog.V(2).Infof("Not writing the status ConfigMap: %v", err)
+		return
+	}
+
+	err = c.writer.MutateConfigMap(ctx, namespace, statusConfigMapName, func(configMap *corev1.ConfigMap) error {
+		if configMap.Data == nil {
+			configMap.Data = make(map[string]string)
+		}
+		update(configMap.Data)
+		return nil
+	})
+	if err != nil {
+		klog.Errorf("Failed to write the status ConfigMap: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/azure/fake"
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/notify"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSignalRecordsFinalStatusBeforeReturning(t *testing.T) {
+	var mu sync.Mutex
+	var notifications []notify.Notification
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A slow sink: the notification must still be delivered before the controller returns
+		time.Sleep(200 * time.Millisecond)
+		var notification notify.Notification
+		if err := json.NewDecoder(r.Body).Decode(&notification); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		notifications = append(notifications, notification)
+		mu.Unlock()
+	}))
+	defer webhook.Close()
+
+	cfg := testConfig(t)
+	cfg.Notifications.Webhooks = []config.WebhookConfig{{URL: webhook.URL}}
+	kubeClient := kubefake.NewSimpleClientset(node("node-0", corev1.ConditionTrue))
+	c, azureClient := newTestControllerWithClient(t, cfg, kubeClient)
+	azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+	if result := c.runCycle(context.Background()); !result.OperationInProgress {
+		t.Fatalf("operation not detected: %+v", result)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() { done <- c.RunUntilSignal(ctx, cancel, signals, c.Run) }()
+
+	// Signal once Run has marked monitoring as started
+	statusData := func() map[string]string {
+		configMap, err := kubeClient.CoreV1().ConfigMaps("aks-monitor").Get(context.Background(), statusConfigMapName, metav1.GetOptions{})
+		if err != nil {
+			return nil
+		}
+		return configMap.Data
+	}
+	for deadline := time.Now().Add(5 * time.Second); statusData()[statusKeyMonitoringStopped] != "false"; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Run did not mark monitoring as started")
+		}
+	}
+	signals <- syscall.SIGTERM
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(ShutdownTimeout + 5*time.Second):
+		t.Fatal("RunUntilSignal did not return after the signal")
+	}
+
+	// Everything below was written before RunUntilSignal returned, that is before the process would exit
+	data := statusData()
+	if data[statusKeyMonitoringStopped] != "true" || data[statusKeyReason] != "received terminated" {
+		t.Errorf("status ConfigMap = %v, want monitoringStopped with the signal as reason", data)
+	}
+	history := c.GetHistory()
+	if last := history[len(history)-1]; last.MonitoringStopped != "received terminated" || last.OperationType != "Upgrading" {
+		t.Errorf("last history entry = %+v, want the stop of the Upgrading operation", last)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(notifications) != 1 {
+		t.Fatalf("delivered %d notifications, want the monitoringStopped one", len(notifications))
+	}
+	notification := notifications[0]
+	if notification.Event != notify.EventMonitoringStopped || notification.Priority != notify.PriorityLow ||
+		notification.Reason != "received terminated" || notification.Operation != "Upgrading" {
+		t.Errorf("notification = %+v, want a low-priority monitoringStopped for Upgrading", notification)
+	}
+}
+
+func TestShutdownWithoutOperationSendsNoNotification(t *testing.T) {
+	var mu sync.Mutex
+	var delivered int
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		delivered++
+		mu.Unlock()
+	}))
+	defer webhook.Close()
+
+	cfg := testConfig(t)
+	cfg.Notifications.Webhooks = []config.WebhookConfig{{URL: webhook.URL}}
+	c, azureClient := newTestController(t, cfg, node("node-0", corev1.ConditionTrue))
+	azureClient.SetOperationStatuses(fake.Idle())
+	c.runCycle(context.Background())
+
+	c.Shutdown("received interrupt")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if delivered != 0 {
+		t.Errorf("delivered %d notifications, want none without an operation in progress", delivered)
+	}
+}
@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/config"
+
+	"k8s.io/klog/v2"
+)
+
+// newAzureClient creates the Azure client; tests replace it to simulate construction failures
+var newAzureClient = azure.NewClient
+
+// CreateAzureClient creates the Azure client, retrying with exponential backoff according to the startup policy
+func CreateAzureClient(azureConfig config.AzureConfig, startup config.AzureClientStartupConfig) (*azure.Client, error) {
+	backoff := startup.Backoff
+	for attempt := 0; ; attempt++ {
+		client, err := newAzureClient(azureConfig)
+		if err == nil {
+			return client, nil
+		}
+		if attempt >= startup.Retries {
+			return nil, err
+		}
+		klog.Warningf("Failed to create Azure client (attempt %d of %d), retrying in %s: %v", attempt+1, startup.Retries+1, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// dataPlaneClient is implemented by Azure clients that can reach the Azure data plane, where verdicts are
+// published and results may be stored. Fakes of azure.Interface need not implement it.
+type dataPlaneClient interface {
+	NewVerdictPublisher(cfg config.VerdictsConfig) *azure.VerdictPublisher
+	NewTableStore(tableURL string) *azure.TableStore
+	NewBlobUploader(containerURL string) *azure.BlobUploader
+}
+
+// useAzureClient installs the Azure client and the components that depend on it
+func (c *Controller) useAzureClient(cfg *config.Config, client azure.Interface) {
+	c.azureClient = client
+	needsDataPlane := cfg.Verdicts.Enabled() || cfg.HistoryStorage.Backend == config.HistoryBackendAzureTable ||
+		cfg.Diagnostics.BlobContainerURL != ""
+	if dataPlane, ok := client.(dataPlaneClient); ok {
+		if cfg.Verdicts.Enabled() {
+			c.verdicts = dataPlane.NewVerdictPublisher(cfg.Verdicts)
+		}
+		if cfg.HistoryStorage.Backend == config.HistoryBackendAzureTable {
+			c.setResultStore(dataPlane.NewTableStore(cfg.HistoryStorage.TableURL))
+		}
+		if cfg.Diagnostics.BlobContainerURL != "" {
+			c.blobs = dataPlane.NewBlobUploader(cfg.Diagnostics.BlobContainerURL)
+		}
+	} else if needsDataPlane {
+		klog.Warning("The Azure client cannot reach the Azure data plane; verdicts, Azure Table history storage and diagnostic bundle uploads are disabled")
+	}
+
+	c.mu.Lock()
+	c.metricsOnlyReason = ""
+	c.mu.Unlock()
+	c.exporter.SetMetricsOnly(false)
+}
+
+// enterMetricsOnly records that the controller runs without an Azure client
+func (c *Controller) enterMetricsOnly(reason string) {
+	c.mu.Lock()
+	c.metricsOnlyReason = reason
+	c.mu.Unlock()
+	c.exporter.SetMetricsOnly(true)
+}
+
+// MetricsOnly reports whether the controller is running without an Azure client, and why. In this mode
+// cluster metrics are collected but operations cannot be detected or aborted.
+func (c *Controller) MetricsOnly() (bool, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.metricsOnlyReason != "", c.metricsOnlyReason
+}
+
+// Ready reports whether the controller can detect and abort operations: it is not in metrics-only mode and
+// fewer than readiness.maxConsecutiveFailures health checks in a row failed, as they do when the Azure
+// credential expired or the API server is unreachable
+func (c *Controller) Ready() (bool, string) {
+	maxFailures := c.cfg().Readiness.MaxConsecutiveFailures
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.metricsOnlyReason != "" {
+		return false, "metrics-only mode: " + c.metricsOnlyReason
+	}
+	if c.consecutiveFailures >= maxFailures {
+		return false, fmt.Sprintf("%d consecutive health checks failed, last: %s", c.consecutiveFailures, c.lastCheckError)
+	}
+	return true, ""
+}
+
+// recordCheckOutcome counts consecutive failed health checks for readiness; a successful check resets the count
+func (c *Controller) recordCheckOutcome(checkErr string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if checkErr == "" {
+		c.consecutiveFailures = 0
+		return
+	}
+	c.consecutiveFailures++
+	c.lastCheckError = checkErr
+}
+
+// reconnectAzure tries once to create the Azure client and pass the preflight check while in metrics-only
+// mode. On success the controller returns to full mode from this cycle on.
+func (c *Controller) reconnectAzure(ctx context.Context, cfg *config.Config) {
+	if c.azureClient != nil {
+		return
+	}
+
+	client, err := newAzureClient(cfg.Azure)
+	if err != nil {
+		klog.V(2).Infof("Azure client still unavailable, staying in metrics-only mode: %v", err)
+		c.enterMetricsOnly(fmt.Sprintf("failed to create Azure client: %v", err))
+		return
+	}
+	if err := client.Preflight(ctx); err != nil {
+		klog.Warningf("Azure client created but preflight failed, staying in metrics-only mode: %v", err)
+		c.enterMetricsOnly(fmt.Sprintf("Azure preflight check failed: %v", err))
+		return
+	}
+
+	klog.Info("Azure client created, leaving metrics-only mode")
+	c.useAzureClient(cfg, client)
+}
+
+// checkMetricsOnly collects cluster metrics without an Azure client. Thresholds are not evaluated
+// since no operation can be detected.
+func (c *Controller) checkMetricsOnly(ctx context.Context, result *HealthCheckResult) error {
+	result.MetricsOnly = true
+
+	collectedMetrics, err := c.metricsCollector.CollectMetrics(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to collect metrics: %w", err)
+	}
+	result.Metrics = collectedMetrics
+	result.Coverage = c.metricsCollector.Coverage()
+	return nil
+}
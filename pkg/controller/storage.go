@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/storage"
+
+	"k8s.io/klog/v2"
+)
+
+// storeTimeout bounds one history store operation
+const storeTimeout = 30 * time.Second
+
+// resultStore returns the durable history store, or nil when none is configured or it is not available yet
+func (c *Controller) resultStore() storage.Store {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.store
+}
+
+// setResultStore installs the durable history store
+func (c *Controller) setResultStore(store storage.Store) {
+	c.mu.Lock()
+	c.store = store
+	c.mu.Unlock()
+}
+
+// persistResult saves the cycle's result to the durable store in the background, so a slow backend never
+// delays the next cycle. Failed saves are logged; the result stays in the in-memory history.
+func (c *Controller) persistResult(ctx context.Context, result HealthCheckResult) {
+	store := c.resultStore()
+	if store == nil {
+		return
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		klog.Errorf("Failed to encode result for history storage: %v", err)
+		return
+	}
+	record := storage.Record{Timestamp: result.Timestamp, TraceID: result.TraceID, Data: data}
+
+	go func() {
+		saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), storeTimeout)
+		defer cancel()
+		if err := store.Save(saveCtx, record); err != nil {
+			klog.Warningf("Failed to save result %s to history storage: %v", record.TraceID, err)
+		}
+	}()
+}
+
+// pruneHistory deletes stored results older than the retention once per prune interval, in the background
+func (c *Controller) pruneHistory(ctx context.Context, cfg *config.Config, now time.Time) {
+	store := c.resultStore()
+	if store == nil || now.Sub(c.lastPrune) < cfg.HistoryStorage.PruneInterval {
+		return
+	}
+	c.lastPrune = now
+
+	before := now.Add(-cfg.HistoryStorage.Retention)
+	go func() {
+		pruneCtx, cancel := context.WithTimeout(ctx, cfg.HistoryStorage.PruneInterval)
+		defer cancel()
+		deleted, err := store.Prune(pruneCtx, before)
+		if err != nil {
+			klog.Warningf("Failed to prune history storage after %d results: %v", deleted, err)
+			return
+		}
+		if deleted > 0 {
+			klog.V(2).Infof("Pruned %d results older than %s from history storage", deleted, before.Format(time.RFC3339))
+		}
+	}()
+}
+
+// storedHistory reads the most recent results from the durable store, oldest first
+func (c *Controller) storedHistory(store storage.Store) ([]HealthCheckResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	return LoadHistory(ctx, store, defaultHistorySize)
+}
+
+// LoadHistory reads at most limit of the most recent results from a history store, oldest first.
+// Results that cannot be decoded are skipped.
+func LoadHistory(ctx context.Context, store storage.Store, limit int) ([]HealthCheckResult, error) {
+	records, err := store.List(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]HealthCheckResult, 0, len(records))
+	for _, record := range records {
+		var result HealthCheckResult
+		if err := json.Unmarshal(record.Data, &result); err != nil {
+			klog.Warningf("Skipping undecodable stored result %s: %v", record.TraceID, err)
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"aks-health-monitor/pkg/metrics"
+)
+
+// violationStreaks counts, per metric, the consecutive cycles of the current operation in which the
+// metric violated its threshold. It is reset when the operation ends or changes.
+type violationStreaks struct {
+	counts   map[metrics.MetricType]int
+	required int
+}
+
+// ViolationStreak is a metric's current streak as reported in /status
+type ViolationStreak struct {
+	Consecutive int `json:"consecutive"`
+	Required    int `json:"required"`
+}
+
+// advanceViolationStreaks extends the streak of every violated metric and ends the others, including
+// metrics that were skipped or not collected, then records each streak on its evaluation
+func (c *Controller) advanceViolationStreaks(evaluations []ThresholdEvaluation, required int) {
+	if required < 1 {
+		required = 1
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := make(map[metrics.MetricType]int)
+	for i := range evaluations {
+		evaluation := &evaluations[i]
+		if !evaluation.Violated {
+			continue
+		}
+		counts[evaluation.Metric] = c.violationStreaks.counts[evaluation.Metric] + 1
+		evaluation.Streak = counts[evaluation.Metric]
+		evaluation.StreakRequired = required
+	}
+	c.violationStreaks = violationStreaks{counts: counts, required: required}
+}
+
+// snapshot copies the streaks for /status; the caller holds c.mu
+func (s violationStreaks) snapshot() map[metrics.MetricType]ViolationStreak {
+	snapshot := make(map[metrics.MetricType]ViolationStreak, len(s.counts))
+	for metric, count := range s.counts {
+		snapshot[metric] = ViolationStreak{Consecutive: count, Required: s.required}
+	}
+	return snapshot
+}
+
+// unsustainedViolations describes the violations when none has lasted the required number of consecutive
+// cycles, or returns "" when at least one has and the abort may proceed
+func unsustainedViolations(evaluations []ThresholdEvaluation) string {
+	for _, evaluation := range evaluations {
+		if evaluation.Violated && evaluation.Streak >= evaluation.StreakRequired {
+			return ""
+		}
+	}
+	return fmt.Sprintf("no metric has violated for the required number of consecutive cycles yet (%s)", streakSummary(evaluations))
+}
+
+// streakSummary renders the streak of each violated metric, e.g. "crashing_pods_percent 2/3"
+func streakSummary(evaluations []ThresholdEvaluation) string {
+	var streaks []string
+	for _, evaluation := range evaluations {
+		if evaluation.Violated {
+			streaks = append(streaks, fmt.Sprintf("%s %d/%d", evaluation.Metric, evaluation.Streak, evaluation.StreakRequired))
+		}
+	}
+	sort.Strings(streaks)
+	return strings.Join(streaks, ", ")
+}
@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/metrics"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// Progress signal names. Each agent pool is its own signal, named agentPool/<pool>.
+const (
+	signalKubernetesVersion = "currentKubernetesVersion"
+	signalAgentPoolPrefix   = "agentPool/"
+	signalNodes             = "nodes"
+)
+
+// StuckOperation describes an operation ARM still reports in progress although none of its progress
+// signals changed for the staleness period
+type StuckOperation struct {
+	Operation      string            `json:"operation"`
+	LastProgressAt time.Time         `json:"lastProgressAt"`
+	StaleFor       time.Duration     `json:"staleFor"`
+	Signals        map[string]string `json:"signals"`
+}
+
+// progressTracker remembers the progress signals of the operation in progress and when one last changed
+type progressTracker struct {
+	operation    string
+	signals      map[string]string
+	lastProgress time.Time
+	stuck        *StuckOperation
+}
+
+// progressSignals flattens the signals of one cycle into named values. ARM signals are only present when
+// the status was read from ARM, and the node fingerprint once nodes were listed; a source that was not read
+// is left out, and reported in the returned flags, rather than treated as a change.
+func progressSignals(status *azure.OperationStatus, nodeFingerprint string) (signals map[string]string, armRead, nodesRead bool) {
+	signals = make(map[string]string)
+	if status.Progress != nil {
+		armRead = true
+		signals[signalKubernetesVersion] = status.Progress.CurrentKubernetesVersion
+		for pool, value := range status.Progress.AgentPools {
+			signals[signalAgentPoolPrefix+pool] = value
+		}
+	}
+	if nodeFingerprint != "" {
+		nodesRead = true
+		signals[signalNodes] = nodeFingerprint
+	}
+	return signals, armRead, nodesRead
+}
+
+// changedSignals returns the sorted names of the signals whose values differ between previous and current.
+// A signal present on only one side, such as an added or removed agent pool, counts as changed.
+func changedSignals(previous, current map[string]string) []string {
+	var changed []string
+	for name, value := range current {
+		if old, ok := previous[name]; !ok || old != value {
+			changed = append(changed, name)
+		}
+	}
+	for name := range previous {
+		if _, ok := current[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// observe records the signals of one cycle of operation. The first cycle of an operation counts as
+// progress, so a controller restarted mid-operation waits a full staleness period before flagging it.
+// It returns the stuck operation, if any, whether it was flagged this cycle, and the signals whose change
+// ended a previous flag.
+func (t *progressTracker) observe(operation string, signals map[string]string, armRead, nodesRead bool, now time.Time, staleness time.Duration) (stuck *StuckOperation, flagged bool, resumed []string) {
+	if operation != t.operation || t.signals == nil {
+		*t = progressTracker{operation: operation, signals: signals, lastProgress: now}
+		return nil, false, nil
+	}
+
+	// Carry over the values of sources not read this cycle so their absence is not mistaken for progress
+	for name, value := range t.signals {
+		armSignal := name == signalKubernetesVersion || strings.HasPrefix(name, signalAgentPoolPrefix)
+		if (armSignal && !armRead) || (name == signalNodes && !nodesRead) {
+			signals[name] = value
+		}
+	}
+
+	changed := changedSignals(t.signals, signals)
+	t.signals = signals
+	if len(changed) > 0 {
+		if t.stuck != nil {
+			resumed = changed
+		}
+		t.lastProgress = now
+		t.stuck = nil
+		return nil, false, resumed
+	}
+
+	staleFor := now.Sub(t.lastProgress)
+	if staleFor < staleness {
+		return nil, false, nil
+	}
+	flagged = t.stuck == nil
+	t.stuck = &StuckOperation{
+		Operation:      operation,
+		LastProgressAt: t.lastProgress,
+		StaleFor:       staleFor,
+		Signals:        signals,
+	}
+	return t.stuck, flagged, nil
+}
+
+// observeProgress compares the progress signals of the operation in progress with earlier cycles and
+// reports an operation that stopped making progress. Must be called after this cycle's metrics were
+// collected, so the node fingerprint is current.
+func (c *Controller) observeProgress(ctx context.Context, cfg *config.Config, status *azure.OperationStatus, now time.Time) *StuckOperation {
+	signals, armRead, nodesRead := progressSignals(status, c.metricsCollector.NodeProgressFingerprint())
+
+	c.mu.Lock()
+	stuck, flagged, resumed := c.progress.observe(status.OperationType, signals, armRead, nodesRead, now, cfg.StuckOperation.StalenessPeriod)
+	c.mu.Unlock()
+
+	if flagged {
+		message := fmt.Sprintf("Operation '%s' on cluster %s is still reported in progress but none of its progress signals changed since %s (%s)",
+			stuck.Operation, cfg.Azure.ClusterName, stuck.LastProgressAt.Format(time.RFC3339), stuck.StaleFor.Round(time.Minute))
+		klog.Warning(message)
+		c.recordEvent(ctx, corev1.EventTypeWarning, "OperationSuspectedStuck", message)
+	}
+	if len(resumed) > 0 {
+		message := fmt.Sprintf("Operation '%s' on cluster %s is making progress again: %s changed",
+			status.OperationType, cfg.Azure.ClusterName, strings.Join(resumed, ", "))
+		klog.Info(message)
+		c.recordEvent(ctx, corev1.EventTypeNormal, "OperationProgressResumed", message)
+	}
+	return stuck
+}
+
+// stuckOperationMetric converts a suspected stuck operation into a 0/1 metric
+func stuckOperationMetric(stuck *StuckOperation) metrics.MetricValue {
+	value := metrics.MetricValue{Type: metrics.SuspectedStuckOperationMetric}
+	if stuck != nil {
+		value.Value = 1
+		value.Offenders = []string{fmt.Sprintf("%s without progress since %s", stuck.Operation, stuck.LastProgressAt.Format(time.RFC3339))}
+	}
+	return value
+}
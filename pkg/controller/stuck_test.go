@@ -0,0 +1,202 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/metrics"
+
+	corev1 "k8s.io/api/core/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestProgressSignals(t *testing.T) {
+	progress := &azure.ProgressSignals{
+		CurrentKubernetesVersion: "1.27.7",
+		AgentPools:               map[string]string{"system": "1.27.7/AKSUbuntu-2204gen2-202310.04.0/3"},
+	}
+	tests := []struct {
+		name          string
+		status        *azure.OperationStatus
+		fingerprint   string
+		wantSignals   map[string]string
+		wantARMRead   bool
+		wantNodesRead bool
+	}{
+		{
+			name:        "ARM and nodes read",
+			status:      &azure.OperationStatus{Progress: progress},
+			fingerprint: "9f2c",
+			wantSignals: map[string]string{
+				"currentKubernetesVersion": "1.27.7",
+				"agentPool/system":         "1.27.7/AKSUbuntu-2204gen2-202310.04.0/3",
+				"nodes":                    "9f2c",
+			},
+			wantARMRead: true, wantNodesRead: true,
+		},
+		{
+			name:        "status from Resource Graph",
+			status:      &azure.OperationStatus{},
+			fingerprint: "9f2c",
+			wantSignals: map[string]string{"nodes": "9f2c"}, wantNodesRead: true,
+		},
+		{
+			name:   "nodes not listed yet",
+			status: &azure.OperationStatus{Progress: progress},
+			wantSignals: map[string]string{
+				"currentKubernetesVersion": "1.27.7",
+				"agentPool/system":         "1.27.7/AKSUbuntu-2204gen2-202310.04.0/3",
+			},
+			wantARMRead: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			signals, armRead, nodesRead := progressSignals(test.status, test.fingerprint)
+			if !reflect.DeepEqual(signals, test.wantSignals) {
+				t.Errorf("signals = %v, want %v", signals, test.wantSignals)
+			}
+			if armRead != test.wantARMRead || nodesRead != test.wantNodesRead {
+				t.Errorf("read ARM %v and nodes %v, want %v and %v", armRead, nodesRead, test.wantARMRead, test.wantNodesRead)
+			}
+		})
+	}
+}
+
+func TestChangedSignals(t *testing.T) {
+	previous := map[string]string{"currentKubernetesVersion": "1.27.7", "agentPool/system": "1.27.7/img/3", "nodes": "9f2c"}
+	tests := []struct {
+		name    string
+		current map[string]string
+		want    []string
+	}{
+		{name: "nothing changed", current: map[string]string{"currentKubernetesVersion": "1.27.7", "agentPool/system": "1.27.7/img/3", "nodes": "9f2c"}},
+		{name: "control plane upgraded", current: map[string]string{"currentKubernetesVersion": "1.28.3", "agentPool/system": "1.27.7/img/3", "nodes": "9f2c"},
+			want: []string{"currentKubernetesVersion"}},
+		{name: "pool surged and nodes changed", current: map[string]string{"currentKubernetesVersion": "1.27.7", "agentPool/system": "1.27.7/img/4", "nodes": "a01b"},
+			want: []string{"agentPool/system", "nodes"}},
+		{name: "pool added", current: map[string]string{"currentKubernetesVersion": "1.27.7", "agentPool/system": "1.27.7/img/3", "agentPool/user": "1.27.7/img/2", "nodes": "9f2c"},
+			want: []string{"agentPool/user"}},
+		{name: "pool removed", current: map[string]string{"currentKubernetesVersion": "1.27.7", "nodes": "9f2c"},
+			want: []string{"agentPool/system"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := changedSignals(previous, test.current); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("changed = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestProgressTracker(t *testing.T) {
+	const staleness = time.Hour
+	start := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	// signals returns the signals of a cycle with the control plane at version and the nodes at fingerprint
+	signals := func(version, fingerprint string) map[string]string {
+		return map[string]string{"currentKubernetesVersion": version, "agentPool/system": version + "/img/3", "nodes": fingerprint}
+	}
+	steps := []struct {
+		name        string
+		operation   string
+		signals     map[string]string
+		armRead     bool
+		nodesRead   bool
+		at          time.Duration
+		wantStuck   bool
+		wantFlagged bool
+		wantResumed []string
+	}{
+		{name: "first cycle counts as progress", operation: "Upgrading", signals: signals("1.27.7", "a"), armRead: true, nodesRead: true},
+		{name: "unchanged within the staleness period", operation: "Upgrading", signals: signals("1.27.7", "a"), armRead: true, nodesRead: true, at: 59 * time.Minute},
+		{name: "unchanged for the staleness period", operation: "Upgrading", signals: signals("1.27.7", "a"), armRead: true, nodesRead: true, at: time.Hour,
+			wantStuck: true, wantFlagged: true},
+		{name: "still stuck is not flagged again", operation: "Upgrading", signals: signals("1.27.7", "a"), armRead: true, nodesRead: true, at: 2 * time.Hour,
+			wantStuck: true},
+		{name: "ARM not read is not progress", operation: "Upgrading", signals: map[string]string{"nodes": "a"}, nodesRead: true, at: 150 * time.Minute,
+			wantStuck: true},
+		{name: "nodes changed", operation: "Upgrading", signals: signals("1.27.7", "b"), armRead: true, nodesRead: true, at: 3 * time.Hour,
+			wantResumed: []string{"nodes"}},
+		{name: "stale again", operation: "Upgrading", signals: signals("1.27.7", "b"), armRead: true, nodesRead: true, at: 4 * time.Hour,
+			wantStuck: true, wantFlagged: true},
+		{name: "another operation starts over", operation: "Scaling", signals: signals("1.27.7", "b"), armRead: true, nodesRead: true, at: 5 * time.Hour},
+		{name: "new operation within its staleness period", operation: "Scaling", signals: signals("1.27.7", "b"), armRead: true, nodesRead: true, at: 5*time.Hour + 30*time.Minute},
+	}
+
+	var tracker progressTracker
+	for _, step := range steps {
+		stuck, flagged, resumed := tracker.observe(step.operation, step.signals, step.armRead, step.nodesRead, start.Add(step.at), staleness)
+		if (stuck != nil) != step.wantStuck || flagged != step.wantFlagged {
+			t.Errorf("%s: stuck = %+v (flagged %v), want stuck %v (flagged %v)", step.name, stuck, flagged, step.wantStuck, step.wantFlagged)
+		}
+		if !reflect.DeepEqual(resumed, step.wantResumed) {
+			t.Errorf("%s: resumed = %v, want %v", step.name, resumed, step.wantResumed)
+		}
+	}
+}
+
+func TestSuspectedStuckOperation(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.StuckOperation.TreatAsViolation = true
+	c, server := newARMTestController(t, cfg, node("node-1", corev1.ConditionTrue))
+	kubeClient := c.kubeClient.(*kubefake.Clientset)
+	server.SetProvisioningStates("Upgrading")
+	server.SetClusterProperty("currentKubernetesVersion", "1.27.7")
+	server.SetClusterProperty("agentPoolProfiles", []interface{}{
+		map[string]interface{}{"name": "system", "count": 3, "currentOrchestratorVersion": "1.27.7", "nodeImageVersion": "AKSUbuntu-2204gen2-202310.04.0"},
+	})
+
+	// cycle runs one cycle and returns its suspected stuck operation and the suspected_stuck_operation value
+	cycle := func() (*StuckOperation, int) {
+		t.Helper()
+		result := c.runCycle(context.Background())
+		if result.Error != "" {
+			t.Fatalf("cycle failed: %s", result.Error)
+		}
+		for _, metric := range result.Metrics {
+			if metric.Type == metrics.SuspectedStuckOperationMetric {
+				return result.SuspectedStuck, metric.Value
+			}
+		}
+		t.Fatalf("%s was not reported", metrics.SuspectedStuckOperationMetric)
+		return nil, 0
+	}
+
+	if stuck, value := cycle(); stuck != nil || value != 0 {
+		t.Fatalf("first cycle: stuck = %+v (metric %d), want progress", stuck, value)
+	}
+
+	// Nothing moved since the first cycle, which is now beyond the staleness period
+	c.mu.Lock()
+	c.progress.lastProgress = c.progress.lastProgress.Add(-cfg.StuckOperation.StalenessPeriod)
+	c.mu.Unlock()
+	stuck, value := cycle()
+	if stuck == nil || stuck.Operation != "Upgrading" || value != 1 {
+		t.Fatalf("stale cycle: stuck = %+v (metric %d), want Upgrading suspected stuck", stuck, value)
+	}
+	if status, ok := c.GetStatus()["suspectedStuck"].(*StuckOperation); !ok || status != stuck {
+		t.Errorf("status suspectedStuck = %v, want %+v", c.GetStatus()["suspectedStuck"], stuck)
+	}
+	if events := eventsByReason(t, kubeClient)["OperationSuspectedStuck"]; len(events) != 1 {
+		t.Errorf("OperationSuspectedStuck events = %d, want 1", len(events))
+	}
+
+	// The node pool moves on to the next node image
+	server.SetClusterProperty("agentPoolProfiles", []interface{}{
+		map[string]interface{}{"name": "system", "count": 3, "currentOrchestratorVersion": "1.27.7", "nodeImageVersion": "AKSUbuntu-2204gen2-202311.07.0"},
+	})
+	if stuck, value := cycle(); stuck != nil || value != 0 {
+		t.Errorf("resumed cycle: stuck = %+v (metric %d), want progress", stuck, value)
+	}
+	if _, ok := c.GetStatus()["suspectedStuck"]; ok {
+		t.Error("status still reports a suspected stuck operation")
+	}
+	if events := eventsByReason(t, kubeClient)["OperationProgressResumed"]; len(events) != 1 {
+		t.Errorf("OperationProgressResumed events = %d, want 1", len(events))
+	}
+}
@@ -0,0 +1,373 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/metrics"
+	"aks-health-monitor/pkg/timeutil"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// sketchExactBuckets is how many small values get a bucket of their own, covering every percentage exactly
+	sketchExactBuckets = 128
+
+	// sketchStepsPerDoubling splits each doubling above the exact range into geometric buckets, bounding
+	// the relative error of larger values to about 9%
+	sketchStepsPerDoubling = 8
+
+	// sketchBuckets covers values up to 128<<20; larger values share the last bucket
+	sketchBuckets = sketchExactBuckets + 20*sketchStepsPerDoubling
+
+	// suggestionStateKey names the learned distributions in the history store
+	suggestionStateKey = "threshold_suggestions"
+
+	// maxLearningAge bounds how long ago a restored learning window may plausibly have started
+	maxLearningAge = 5 * 365 * 24 * time.Hour
+
+	// suggestionStateVersion changes whenever the bucket layout does, discarding incompatible saved state
+	suggestionStateVersion = 1
+)
+
+// sketch is a fixed-size histogram of a metric's values. Memory does not grow with the number of samples.
+type sketch struct {
+	counts [sketchBuckets]uint64
+	total  uint64
+}
+
+// bucketIndex maps a value to its bucket
+func bucketIndex(value int) int {
+	if value < 0 {
+		return 0
+	}
+	if value < sketchExactBuckets {
+		return value
+	}
+	step := int(math.Log2(float64(value)/sketchExactBuckets) * sketchStepsPerDoubling)
+	return min(sketchExactBuckets+step, sketchBuckets-1)
+}
+
+// bucketUpperBound is the largest value reported for a bucket. Geometric buckets report their upper
+// edge, so quantiles err on the high side.
+func bucketUpperBound(index int) int {
+	if index < sketchExactBuckets {
+		return index
+	}
+	steps := float64(index-sketchExactBuckets+1) / sketchStepsPerDoubling
+	return int(math.Ceil(sketchExactBuckets * math.Pow(2, steps)))
+}
+
+// add records one sample
+func (s *sketch) add(value int) {
+	s.counts[bucketIndex(value)]++
+	s.total++
+}
+
+// quantile returns the value below or at which the fraction q of samples fall
+func (s *sketch) quantile(q float64) int {
+	if s.total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(s.total)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen uint64
+	for i, count := range s.counts {
+		seen += count
+		if seen >= rank {
+			return bucketUpperBound(i)
+		}
+	}
+	return bucketUpperBound(sketchBuckets - 1)
+}
+
+// suggestThreshold returns the observed p99 plus marginPercent, and always at least one more than the
+// p99 so a metric that sat at zero does not get a threshold violated by any nonzero value. Percentages
+// are capped at 100.
+func suggestThreshold(p99, marginPercent int, percent bool) int {
+	suggested := int(math.Ceil(float64(p99) * float64(100+marginPercent) / 100))
+	if suggested <= p99 {
+		suggested = p99 + 1
+	}
+	if percent && suggested > 100 {
+		suggested = 100
+	}
+	return suggested
+}
+
+// suggestionTracker accumulates metric distributions while no operation is in progress
+type suggestionTracker struct {
+	mu         sync.Mutex
+	since      time.Time
+	sketches   map[metrics.MetricType]*sketch
+	lastSample time.Time
+	lastLog    time.Time
+	saveMu     sync.Mutex
+
+	// loaded is set once saved state was merged in; until then nothing is saved, so a store
+	// that was briefly unreadable does not lose what was learned before the restart
+	loaded bool
+}
+
+// suggestionState is the persisted form of the tracker, with only the non-empty buckets of each sketch
+type suggestionState struct {
+	Version int                                   `json:"version"`
+	Since   time.Time                             `json:"since"`
+	Buckets map[metrics.MetricType]map[int]uint64 `json:"buckets"`
+}
+
+// ThresholdSuggestion is the advisory threshold for one metric
+type ThresholdSuggestion struct {
+	Metric           metrics.MetricType `json:"metric"`
+	Samples          uint64             `json:"samples"`
+	P50              int                `json:"p50"`
+	P99              int                `json:"p99"`
+	CurrentThreshold int                `json:"currentThreshold"`
+
+	// SuggestedThreshold is unset while the learning window is running or the metric has too few samples
+	SuggestedThreshold *int `json:"suggestedThreshold,omitempty"`
+}
+
+// SuggestionReport lists the advisory thresholds. They are never applied automatically.
+type SuggestionReport struct {
+	LearningSince    time.Time             `json:"learningSince,omitempty"`
+	LearningWindow   string                `json:"learningWindow"`
+	LearningComplete bool                  `json:"learningComplete"`
+	MarginPercent    int                   `json:"marginPercent"`
+	MinSamples       int                   `json:"minSamples"`
+	Suggestions      []ThresholdSuggestion `json:"suggestions"`
+}
+
+// suggestionSampleDue reports whether an idle cycle should collect metrics for threshold suggestions
+func (c *Controller) suggestionSampleDue(cfg *config.Config, now time.Time) bool {
+	if !cfg.Suggestions.Enabled {
+		return false
+	}
+	c.suggestions.mu.Lock()
+	defer c.suggestions.mu.Unlock()
+	return now.Sub(c.suggestions.lastSample) >= cfg.Suggestions.SampleInterval
+}
+
+// learnSteadyState adds metrics collected while no operation is in progress to the distributions,
+// at most once per sample interval, then saves them and logs the suggestions when due
+func (c *Controller) learnSteadyState(ctx context.Context, cfg *config.Config, collected []metrics.MetricValue, now time.Time) {
+	if !c.suggestionSampleDue(cfg, now) {
+		return
+	}
+	c.loadSuggestionState(ctx, now)
+
+	c.suggestions.mu.Lock()
+	if c.suggestions.sketches == nil {
+		c.suggestions.sketches = make(map[metrics.MetricType]*sketch)
+	}
+	if c.suggestions.since.IsZero() {
+		c.suggestions.since = now
+	}
+	for _, metric := range collected {
+		if metric.Type.IsInformational() || metric.Type == metrics.OperationErrorDetectedMetric {
+			continue
+		}
+		s := c.suggestions.sketches[metric.Type]
+		if s == nil {
+			s = &sketch{}
+			c.suggestions.sketches[metric.Type] = s
+		}
+		s.add(metric.Value)
+	}
+	c.suggestions.lastSample = now
+	logDue := now.Sub(c.suggestions.lastLog) >= cfg.Suggestions.LogInterval
+	if logDue {
+		c.suggestions.lastLog = now
+	}
+	c.suggestions.mu.Unlock()
+
+	c.saveSuggestionState(ctx)
+	if logDue {
+		c.logSuggestions(cfg, now)
+	}
+}
+
+// loadSuggestionState merges the distributions saved before a restart into the tracker, once
+func (c *Controller) loadSuggestionState(ctx context.Context, now time.Time) {
+	c.suggestions.mu.Lock()
+	loaded := c.suggestions.loaded
+	c.suggestions.mu.Unlock()
+	if loaded {
+		return
+	}
+
+	store := c.resultStore()
+	var state suggestionState
+	if store != nil {
+		loadCtx, cancel := context.WithTimeout(ctx, storeTimeout)
+		data, err := store.LoadState(loadCtx, suggestionStateKey)
+		cancel()
+		if err != nil {
+			klog.Warningf("Failed to load threshold suggestion state, retrying next sample: %v", err)
+			return
+		}
+		if data != nil {
+			if err := json.Unmarshal(data, &state); err != nil {
+				klog.Warningf("Discarding undecodable threshold suggestion state: %v", err)
+				state = suggestionState{}
+			} else if state.Version != suggestionStateVersion {
+				klog.Infof("Discarding threshold suggestion state of version %d", state.Version)
+				state = suggestionState{}
+			}
+		}
+	}
+
+	c.suggestions.mu.Lock()
+	defer c.suggestions.mu.Unlock()
+	if c.suggestions.sketches == nil {
+		c.suggestions.sketches = make(map[metrics.MetricType]*sketch)
+	}
+	for metricType, buckets := range state.Buckets {
+		s := c.suggestions.sketches[metricType]
+		if s == nil {
+			s = &sketch{}
+			c.suggestions.sketches[metricType] = s
+		}
+		for index, count := range buckets {
+			if index < 0 || index >= sketchBuckets {
+				continue
+			}
+			s.counts[index] += count
+			s.total += count
+		}
+	}
+	// The saved start is wall time from before the restart; one from a far-off clock would end the learning
+	// window early or never, so it is replaced by the current time
+	if state.Since.After(now) || (!state.Since.IsZero() && !timeutil.Plausible("threshold suggestion learning start", state.Since, now, maxLearningAge)) {
+		state.Since = now
+	}
+	if !state.Since.IsZero() && (c.suggestions.since.IsZero() || state.Since.Before(c.suggestions.since)) {
+		c.suggestions.since = state.Since
+	}
+	c.suggestions.loaded = true
+	if len(state.Buckets) > 0 {
+		klog.Infof("Restored threshold suggestion state learned since %s", state.Since.Format(time.RFC3339))
+	}
+}
+
+// saveSuggestionState writes the distributions to the history store in the background. Saves are
+// serialised and each takes its snapshot when it runs, so a slow save never overwrites a newer one.
+func (c *Controller) saveSuggestionState(ctx context.Context) {
+	store := c.resultStore()
+	if store == nil {
+		return
+	}
+
+	go func() {
+		c.suggestions.saveMu.Lock()
+		defer c.suggestions.saveMu.Unlock()
+
+		data, ok, err := c.suggestions.snapshot()
+		if err != nil {
+			klog.Errorf("Failed to encode threshold suggestion state: %v", err)
+			return
+		}
+		if !ok {
+			return
+		}
+
+		saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), storeTimeout)
+		defer cancel()
+		if err := store.SaveState(saveCtx, suggestionStateKey, data); err != nil {
+			klog.Warningf("Failed to save threshold suggestion state: %v", err)
+		}
+	}()
+}
+
+// snapshot encodes the tracker for the store. It reports false until saved state was loaded.
+func (t *suggestionTracker) snapshot() ([]byte, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.loaded {
+		return nil, false, nil
+	}
+
+	state := suggestionState{
+		Version: suggestionStateVersion,
+		Since:   t.since,
+		Buckets: make(map[metrics.MetricType]map[int]uint64, len(t.sketches)),
+	}
+	for metricType, s := range t.sketches {
+		buckets := make(map[int]uint64)
+		for index, count := range s.counts {
+			if count > 0 {
+				buckets[index] = count
+			}
+		}
+		state.Buckets[metricType] = buckets
+	}
+	data, err := json.Marshal(state)
+	return data, true, err
+}
+
+// logSuggestions logs the current suggestions once the learning window is complete
+func (c *Controller) logSuggestions(cfg *config.Config, now time.Time) {
+	report := c.suggestionReport(cfg, now)
+	if !report.LearningComplete {
+		klog.V(2).Infof("Learning steady-state metrics for threshold suggestions since %s", report.LearningSince.Format(time.RFC3339))
+		return
+	}
+	for _, suggestion := range report.Suggestions {
+		if suggestion.SuggestedThreshold == nil {
+			continue
+		}
+		klog.Infof("Suggested threshold for %s: %s (steady-state p99 %s over %d samples, current threshold %s)",
+			suggestion.Metric, suggestion.Metric.FormatValue(*suggestion.SuggestedThreshold),
+			suggestion.Metric.FormatValue(suggestion.P99), suggestion.Samples, suggestion.Metric.FormatValue(suggestion.CurrentThreshold))
+	}
+}
+
+// suggestionReport computes the suggestions from the distributions learned so far
+func (c *Controller) suggestionReport(cfg *config.Config, now time.Time) *SuggestionReport {
+	c.suggestions.mu.Lock()
+	defer c.suggestions.mu.Unlock()
+
+	report := &SuggestionReport{
+		LearningSince:    c.suggestions.since,
+		LearningWindow:   cfg.Suggestions.LearningWindow.String(),
+		LearningComplete: !c.suggestions.since.IsZero() && timeutil.Elapsed("threshold suggestion learning", c.suggestions.since, now) >= cfg.Suggestions.LearningWindow,
+		MarginPercent:    cfg.Suggestions.MarginPercent,
+		MinSamples:       cfg.Suggestions.MinSamples,
+		Suggestions:      make([]ThresholdSuggestion, 0, len(c.suggestions.sketches)),
+	}
+	for metricType, s := range c.suggestions.sketches {
+		suggestion := ThresholdSuggestion{
+			Metric:           metricType,
+			Samples:          s.total,
+			P50:              s.quantile(0.50),
+			P99:              s.quantile(0.99),
+			CurrentThreshold: c.getThresholdForMetric(cfg.Thresholds, metricType),
+		}
+		if report.LearningComplete && s.total >= uint64(cfg.Suggestions.MinSamples) {
+			suggested := suggestThreshold(suggestion.P99, cfg.Suggestions.MarginPercent, metricType.IsPercent())
+			suggestion.SuggestedThreshold = &suggested
+		}
+		report.Suggestions = append(report.Suggestions, suggestion)
+	}
+	sort.Slice(report.Suggestions, func(i, j int) bool {
+		return report.Suggestions[i].Metric < report.Suggestions[j].Metric
+	})
+	return report
+}
+
+// GetSuggestions returns the advisory thresholds learned so far, or nil when suggestions are disabled
+func (c *Controller) GetSuggestions() *SuggestionReport {
+	cfg := c.cfg()
+	if !cfg.Suggestions.Enabled {
+		return nil
+	}
+	return c.suggestionReport(cfg, time.Now())
+}
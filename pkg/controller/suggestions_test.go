@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/storage"
+)
+
+func TestRestoredLearningStartWithSkewedClock(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		since     time.Time
+		wantSince time.Time
+	}{
+		{name: "recent", since: now.Add(-2 * time.Hour), wantSince: now.Add(-2 * time.Hour)},
+		{name: "in the future", since: now.Add(time.Hour), wantSince: now},
+		{name: "years in the past", since: now.AddDate(-10, 0, 0), wantSince: now},
+		{name: "not saved", since: time.Time{}, wantSince: time.Time{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, _ := newTestController(t, testConfig(t))
+			store, err := storage.NewSQLiteStore(context.Background(), filepath.Join(t.TempDir(), "history.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { store.Close() })
+			c.setResultStore(store)
+			data, err := json.Marshal(suggestionState{Version: suggestionStateVersion, Since: test.since})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.SaveState(context.Background(), suggestionStateKey, data); err != nil {
+				t.Fatal(err)
+			}
+
+			c.loadSuggestionState(context.Background(), now)
+			if !c.suggestions.since.Equal(test.wantSince) {
+				t.Errorf("learning since = %s, want %s", c.suggestions.since, test.wantSince)
+			}
+		})
+	}
+}
+
+func TestLearningCompleteWithSkewedClock(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	cfg := testConfig(t)
+	cfg.Suggestions.LearningWindow = 24 * time.Hour
+	tests := []struct {
+		name  string
+		since time.Time
+		want  bool
+	}{
+		{name: "window elapsed", since: now.Add(-25 * time.Hour), want: true},
+		{name: "window running", since: now.Add(-time.Hour), want: false},
+		{name: "start after now", since: now.Add(48 * time.Hour), want: false},
+		{name: "not started", since: time.Time{}, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, _ := newTestController(t, cfg)
+			c.suggestions.since = test.since
+			if got := c.suggestionReport(cfg, now).LearningComplete; got != test.want {
+				t.Errorf("learning complete = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/azure/fake"
+	"aks-health-monitor/pkg/config"
+
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestSuppressionReasons(t *testing.T) {
+	tests := []struct {
+		reason SuppressionReason
+		// setup scripts the cycle; every test has two NotReady nodes, violating notReadyNodesPercent
+		setup func(t *testing.T, cfg *config.Config, c *Controller, kubeClient *kubefake.Clientset, azureClient *fake.Client)
+		// wantAbortCalled is whether the abort reached Azure before being suppressed
+		wantAbortCalled bool
+	}{
+		{
+			reason: SuppressionViolationStreak,
+			setup: func(t *testing.T, cfg *config.Config, c *Controller, kubeClient *kubefake.Clientset, azureClient *fake.Client) {
+				setMonitoredOperations(t, cfg, "- name: upgrade\n  consecutiveViolations: 2")
+			},
+		},
+		{
+			reason: SuppressionIncompleteData,
+			setup: func(t *testing.T, cfg *config.Config, c *Controller, kubeClient *kubefake.Clientset, azureClient *fake.Client) {
+				kubeClient.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, apierrors.NewServiceUnavailable("etcd leader changed")
+				})
+			},
+		},
+		{
+			reason: SuppressionAutoscalerInitiated,
+			setup: func(t *testing.T, cfg *config.Config, c *Controller, kubeClient *kubefake.Clientset, azureClient *fake.Client) {
+				status := fake.InProgress("Scaling")
+				status.Attribution = &azure.Attribution{Initiator: azure.InitiatorAutoscaler, Confidence: azure.ConfidenceHigh, Evidence: "pool within its autoscaler bounds"}
+				azureClient.SetOperationStatuses(status)
+			},
+		},
+		{
+			reason: SuppressionTrustedInitiator,
+			setup: func(t *testing.T, cfg *config.Config, c *Controller, kubeClient *kubefake.Clientset, azureClient *fake.Client) {
+				cfg.TrustedInitiators.Principals = []string{"pipeline@contoso.com"}
+				azureClient.SetOperationCaller(&azure.OperationCaller{Caller: "pipeline@contoso.com", Operation: "upgrade"}, nil)
+			},
+		},
+		{
+			reason: SuppressionAbortCooldown,
+			setup: func(t *testing.T, cfg *config.Config, c *Controller, kubeClient *kubefake.Clientset, azureClient *fake.Client) {
+				c.cooldown = abortCooldown{operation: "Upgrading", since: time.Now().Add(-time.Minute)}
+			},
+		},
+		{
+			reason: SuppressionAlertOnly,
+			setup: func(t *testing.T, cfg *config.Config, c *Controller, kubeClient *kubefake.Clientset, azureClient *fake.Client) {
+				setMonitoredOperations(t, cfg, "- name: upgrade\n  action: alert")
+			},
+		},
+		{
+			reason: SuppressionUnsafeAbort,
+			setup: func(t *testing.T, cfg *config.Config, c *Controller, kubeClient *kubefake.Clientset, azureClient *fake.Client) {
+				cfg.AbortSafety = config.AbortSafetyConfig{Enabled: true, MinSchedulableCapacityPercent: 50, MaxKubeletMinorSkew: 2}
+			},
+		},
+		{
+			reason: SuppressionDryRun,
+			setup: func(t *testing.T, cfg *config.Config, c *Controller, kubeClient *kubefake.Clientset, azureClient *fake.Client) {
+				cfg.DryRun = true
+			},
+		},
+		{
+			reason: SuppressionAbortUnavailable,
+			setup: func(t *testing.T, cfg *config.Config, c *Controller, kubeClient *kubefake.Clientset, azureClient *fake.Client) {
+				azureClient.SetAbortError(fmt.Errorf("%w after 3 consecutive write failures", azure.ErrCircuitOpen))
+			},
+			wantAbortCalled: true,
+		},
+		{
+			reason: SuppressionOperationFinished,
+			setup: func(t *testing.T, cfg *config.Config, c *Controller, kubeClient *kubefake.Clientset, azureClient *fake.Client) {
+				azureClient.SetAbortError(fmt.Errorf("%w: 409 Conflict", azure.ErrOperationFinished))
+			},
+			wantAbortCalled: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.reason), func(t *testing.T) {
+			cfg := testConfig(t)
+			kubeClient := kubefake.NewSimpleClientset(unreadyNodes(2)...)
+			c, azureClient := newTestControllerWithClient(t, cfg, kubeClient)
+			azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+			test.setup(t, cfg, c, kubeClient, azureClient)
+
+			result := c.runCycle(context.Background())
+
+			if result.Action != ActionSuppressed || result.SuppressionReason != test.reason {
+				t.Fatalf("action = %s (%s: %s), want suppressed as %s; error %q",
+					result.Action, result.SuppressionReason, result.SuppressionDetail, test.reason, result.Error)
+			}
+			if result.SuppressionDetail == "" {
+				t.Error("the suppression has no detail")
+			}
+			if len(result.Violations) == 0 {
+				t.Error("a suppressed cycle reports no violations")
+			}
+			if called := azureClient.CountCalls(fake.MethodAbortClusterOperation) > 0; called != test.wantAbortCalled {
+				t.Errorf("abort sent to Azure: %v, want %v", called, test.wantAbortCalled)
+			}
+			if last, ok := c.history.last(); !ok || last.SuppressionReason != test.reason {
+				t.Errorf("history entry suppression = %s, want %s", last.SuppressionReason, test.reason)
+			}
+		})
+	}
+}
+
+func TestViolationsAbortWithoutSuppression(t *testing.T) {
+	c, azureClient := newTestController(t, testConfig(t), unreadyNodes(2)...)
+	azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+
+	result := c.runCycle(context.Background())
+	if result.Action != ActionAborted || result.SuppressionReason != "" {
+		t.Errorf("action = %s (%s), want aborted: the cycle TestSuppressionReasons scripts aborts unless suppressed",
+			result.Action, result.SuppressionReason)
+	}
+}
+
+// setMonitoredOperations replaces the monitored operations with the YAML list document
+func setMonitoredOperations(t *testing.T, cfg *config.Config, document string) {
+	t.Helper()
+	cfg.MonitoredOperations = nil
+	if err := yaml.Unmarshal([]byte(document), &cfg.MonitoredOperations); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAbortAutoscalerInitiated(t *testing.T) {
+	tests := []struct {
+		name       string
+		initiator  azure.Initiator
+		abort      bool
+		wantAction Action
+	}{
+		{name: "autoscaler scale is not aborted by default", initiator: azure.InitiatorAutoscaler, wantAction: ActionSuppressed},
+		{name: "autoscaler scale is aborted when configured", initiator: azure.InitiatorAutoscaler, abort: true, wantAction: ActionAborted},
+		{name: "manual scale is aborted", initiator: azure.InitiatorManual, wantAction: ActionAborted},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.ScaleOperations.AbortAutoscalerInitiated = test.abort
+			c, azureClient := newTestController(t, cfg, unreadyNodes(2)...)
+			status := fake.InProgress("Scaling")
+			status.Attribution = &azure.Attribution{Initiator: test.initiator, Confidence: azure.ConfidenceHigh, Evidence: "test pool"}
+			azureClient.SetOperationStatuses(status)
+
+			result := c.runCycle(context.Background())
+			if result.Action != test.wantAction {
+				t.Fatalf("action = %s (%s: %s), want %s", result.Action, result.SuppressionReason, result.SuppressionDetail, test.wantAction)
+			}
+			if aborts := len(azureClient.Aborts()); (aborts > 0) != (test.wantAction == ActionAborted) {
+				t.Errorf("aborts = %d with action %s", aborts, result.Action)
+			}
+			for _, check := range result.SuppressionChecks {
+				if check.Reason == SuppressionAutoscalerInitiated && check.Suppressed != (test.wantAction == ActionSuppressed) {
+					t.Errorf("autoscaler check suppressed = %v, want %v", check.Suppressed, test.wantAction == ActionSuppressed)
+				}
+			}
+		})
+	}
+}
+
+func TestTrustedInitiators(t *testing.T) {
+	const objectID = "6f1c2b3a-0000-4000-8000-000000000001"
+	pipeline := &azure.OperationCaller{Caller: objectID, ObjectID: objectID, AppID: "0c7a9e2d-0000-4000-8000-000000000002",
+		Operation: "Microsoft.ContainerService/managedClusters/agentPools/upgradeNodeImageVersion/action", Timestamp: time.Now().Add(-5 * time.Minute)}
+	oncall := &azure.OperationCaller{Caller: "oncall@contoso.com", Operation: "Microsoft.ContainerService/managedClusters/write", Timestamp: time.Now().Add(-5 * time.Minute)}
+	tests := []struct {
+		name          string
+		principals    []string
+		caller        *azure.OperationCaller
+		callerErr     error
+		wantInitiator azure.Initiator
+		wantAction    Action
+	}{
+		{name: "trusted object ID", principals: []string{objectID}, caller: pipeline, wantInitiator: azure.InitiatorTrusted, wantAction: ActionSuppressed},
+		{name: "trusted application ID", principals: []string{"0C7A9E2D-0000-4000-8000-000000000002"}, caller: pipeline, wantInitiator: azure.InitiatorTrusted, wantAction: ActionSuppressed},
+		{name: "trusted user principal name", principals: []string{objectID, " OnCall@contoso.com "}, caller: oncall, wantInitiator: azure.InitiatorTrusted, wantAction: ActionSuppressed},
+		{name: "untrusted caller", principals: []string{objectID}, caller: oncall, wantInitiator: azure.InitiatorUntrusted, wantAction: ActionAborted},
+		{name: "no request in the activity log", principals: []string{objectID}, wantInitiator: azure.InitiatorUnknown, wantAction: ActionAborted},
+		{name: "activity log unreadable", principals: []string{objectID}, callerErr: fmt.Errorf("AuthorizationFailed"), wantInitiator: azure.InitiatorUnknown, wantAction: ActionAborted},
+		{name: "no trusted initiators", caller: pipeline, wantAction: ActionAborted},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.TrustedInitiators.Principals = test.principals
+			c, azureClient := newTestController(t, cfg, unreadyNodes(2)...)
+			azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+			azureClient.SetOperationCaller(test.caller, test.callerErr)
+
+			result := c.runCycle(context.Background())
+			if result.Action != test.wantAction {
+				t.Errorf("action = %s (%s: %s), want %s", result.Action, result.SuppressionReason, result.SuppressionDetail, test.wantAction)
+			}
+			if test.wantInitiator == "" {
+				if result.Initiator != nil || azureClient.CountCalls(fake.MethodGetOperationCaller) != 0 {
+					t.Errorf("initiator = %+v after %d activity log reads, want none read", result.Initiator, azureClient.CountCalls(fake.MethodGetOperationCaller))
+				}
+				return
+			}
+			if result.Initiator == nil || result.Initiator.Initiator != test.wantInitiator {
+				t.Fatalf("initiator = %+v, want %s", result.Initiator, test.wantInitiator)
+			}
+			if status, ok := c.GetStatus()["operationInitiator"].(*OperationInitiator); !ok || status.Initiator != test.wantInitiator {
+				t.Errorf("status operationInitiator = %v, want %s", c.GetStatus()["operationInitiator"], test.wantInitiator)
+			}
+		})
+	}
+}
+
+func TestUnknownInitiatorIsRetried(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.TrustedInitiators.Principals = []string{"pipeline@contoso.com"}
+	c, azureClient := newTestController(t, cfg, node("node-1", corev1.ConditionTrue))
+	azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+
+	// cycle runs one cycle and returns the initiator it attributed and the activity log reads so far
+	cycle := func() (azure.Initiator, int) {
+		t.Helper()
+		result := c.runCycle(context.Background())
+		if result.Initiator == nil {
+			t.Fatalf("cycle attributed no initiator (error %q)", result.Error)
+		}
+		return result.Initiator.Initiator, azureClient.CountCalls(fake.MethodGetOperationCaller)
+	}
+
+	// The activity log has not caught up with the request yet
+	if initiator, reads := cycle(); initiator != azure.InitiatorUnknown || reads != 1 {
+		t.Fatalf("first cycle: initiator %s after %d reads, want unknown after 1", initiator, reads)
+	}
+	if initiator, reads := cycle(); initiator != azure.InitiatorUnknown || reads != 1 {
+		t.Fatalf("cycle within the retry interval: initiator %s after %d reads, want unknown without another read", initiator, reads)
+	}
+
+	azureClient.SetOperationCaller(&azure.OperationCaller{Caller: "pipeline@contoso.com", Operation: "Microsoft.ContainerService/managedClusters/write"}, nil)
+	c.mu.Lock()
+	c.initiator.initiator.CheckedAt = c.initiator.initiator.CheckedAt.Add(-initiatorRetryInterval)
+	c.mu.Unlock()
+	if initiator, reads := cycle(); initiator != azure.InitiatorTrusted || reads != 2 {
+		t.Fatalf("retried cycle: initiator %s after %d reads, want trusted after 2", initiator, reads)
+	}
+	if initiator, reads := cycle(); initiator != azure.InitiatorTrusted || reads != 2 {
+		t.Errorf("known initiator: %s after %d reads, want trusted kept without another read", initiator, reads)
+	}
+}
+
+func TestUpgradeClassSettings(t *testing.T) {
+	tests := []struct {
+		name       string
+		operations string
+		class      string
+		wantAction Action
+	}{
+		{name: "control plane upgrade alert only", operations: "- upgrade\n- name: controlPlaneUpgrade\n  action: alert",
+			class: config.OperationControlPlaneUpgrade, wantAction: ActionSuppressed},
+		{name: "node pool upgrade aborts", operations: "- upgrade\n- name: controlPlaneUpgrade\n  action: alert",
+			class: config.OperationNodePoolUpgrade, wantAction: ActionAborted},
+		{name: "unclassified upgrade uses upgrade", operations: "- upgrade\n- name: controlPlaneUpgrade\n  action: alert",
+			wantAction: ActionAborted},
+		{name: "class without an entry uses upgrade", operations: "- name: upgrade\n  action: alert",
+			class: config.OperationNodePoolUpgrade, wantAction: ActionSuppressed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			setMonitoredOperations(t, cfg, test.operations)
+			c, azureClient := newTestController(t, cfg, unreadyNodes(2)...)
+			status := fake.InProgress("Upgrading")
+			status.Upgrade = &azure.UpgradeClassification{Class: test.class, Evidence: "test"}
+			azureClient.SetOperationStatuses(status)
+
+			result := c.runCycle(context.Background())
+			if result.Action != test.wantAction {
+				t.Fatalf("action = %s (%s), want %s; error %q", result.Action, result.SuppressionReason, test.wantAction, result.Error)
+			}
+			if test.wantAction == ActionSuppressed && result.SuppressionReason != SuppressionAlertOnly {
+				t.Errorf("suppression = %s, want %s", result.SuppressionReason, SuppressionAlertOnly)
+			}
+			if result.Upgrade == nil || result.Upgrade.Class != test.class {
+				t.Errorf("result upgrade = %+v, want class %q", result.Upgrade, test.class)
+			}
+		})
+	}
+}
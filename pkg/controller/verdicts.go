@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"aks-health-monitor/pkg/azure"
+	"aks-health-monitor/pkg/config"
+
+	"k8s.io/klog/v2"
+)
+
+// verdictTimeout bounds one verdict delivery including retries
+const verdictTimeout = 2 * time.Minute
+
+// publishVerdicts enqueues a verdict when the cycle aborted, and once per episode when the cluster has been
+// degraded or abort-worthy for the configured number of consecutive cycles. Delivery runs in the
+// background; a verdict that still fails after retries is written to the log as a dead letter.
+func (c *Controller) publishVerdicts(ctx context.Context, cfg *config.Config, result *HealthCheckResult) {
+	if c.verdicts == nil {
+		return
+	}
+
+	c.mu.Lock()
+	if result.HealthState == HealthHealthy {
+		c.unhealthyCycles = 0
+	} else {
+		c.unhealthyCycles++
+	}
+	sustained := c.unhealthyCycles == cfg.Verdicts.SustainedCycles
+	c.mu.Unlock()
+
+	var kinds []string
+	if result.Action == ActionAborted {
+		kinds = append(kinds, azure.VerdictAborted)
+	}
+	if sustained {
+		kinds = append(kinds, azure.VerdictSustainedDegradation)
+	}
+
+	for _, kind := range kinds {
+		verdict := azure.Verdict{
+			MessageID:   fmt.Sprintf("%s-%s", result.TraceID, kind),
+			Kind:        kind,
+			Timestamp:   result.Timestamp,
+			Cluster:     clusterResourceID(cfg),
+			Operation:   result.OperationType,
+			Violations:  result.Violations,
+			Action:      string(result.Action),
+			HealthState: int(result.HealthState),
+			TraceID:     result.TraceID,
+		}
+		for _, evaluation := range result.Evaluations {
+			if evaluation.Violated && (evaluation.Description != "" || evaluation.RunbookURL != "") {
+				verdict.Guidance = append(verdict.Guidance, azure.VerdictGuidance{
+					Metric:      string(evaluation.Metric),
+					Description: evaluation.Description,
+					RunbookURL:  evaluation.RunbookURL,
+				})
+			}
+		}
+
+		c.deliverVerdict(ctx, verdict)
+	}
+}
+
+// deliverVerdict publishes a verdict in the background. It outlives the cycle and shutdown so a verdict
+// in flight is still delivered or dead-lettered.
+func (c *Controller) deliverVerdict(ctx context.Context, verdict azure.Verdict) {
+	go func() {
+		sendCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), verdictTimeout)
+		defer cancel()
+
+		if err := c.verdicts.Publish(sendCtx, verdict); err != nil {
+			payload, _ := json.Marshal(verdict)
+			klog.Errorf("Dead-lettering verdict %s after retries: %v: %s", verdict.MessageID, err, payload)
+			return
+		}
+		klog.Infof("Published %s verdict %s", verdict.Kind, verdict.MessageID)
+	}()
+}
+
+// clusterResourceID returns the managed cluster resource ID that verdicts identify the cluster by
+func clusterResourceID(cfg *config.Config) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ContainerService/managedClusters/%s",
+		cfg.Azure.SubscriptionID, cfg.Azure.ResourceGroupName, cfg.Azure.ClusterName)
+}
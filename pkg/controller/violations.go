@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"aks-health-monitor/pkg/metrics"
+)
+
+// Rules a violation can break
+const (
+	ruleThreshold = "threshold"
+	ruleCritical  = "critical"
+	ruleWarn      = "warn"
+)
+
+// ViolationRef identifies a violation across cycles. The ID depends only on the metric and the rule it
+// broke, not on the value or offenders, so the same violation keeps its ID while it persists.
+type ViolationRef struct {
+	ID     string             `json:"id"`
+	Metric metrics.MetricType `json:"metric"`
+	Rule   string             `json:"rule"`
+}
+
+// newViolationRef builds the reference of a violation of rule by metric
+func newViolationRef(metric metrics.MetricType, rule string) ViolationRef {
+	sum := sha256.Sum256([]byte(rule + "/" + string(metric)))
+	return ViolationRef{ID: hex.EncodeToString(sum[:8]), Metric: metric, Rule: rule}
+}
+
+// sortViolationRefs orders references by metric, then rule
+func sortViolationRefs(refs []ViolationRef) {
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Metric != refs[j].Metric {
+			return refs[i].Metric < refs[j].Metric
+		}
+		return refs[i].Rule < refs[j].Rule
+	})
+}
+
+// canonicalizeViolations sorts the violation lists by metric so identical inputs serialize identically,
+// whatever order the collectors finished in. Violation texts start with their metric's name.
+func (r *HealthCheckResult) canonicalizeViolations() {
+	sortViolationRefs(r.ViolationRefs)
+	sort.SliceStable(r.Violations, func(i, j int) bool {
+		return violationMetric(r.Violations[i]) < violationMetric(r.Violations[j])
+	})
+	sortViolationRefs(r.WarningRefs)
+	sort.SliceStable(r.Warnings, func(i, j int) bool {
+		return violationMetric(r.Warnings[i]) < violationMetric(r.Warnings[j])
+	})
+}
+
+// diffViolations records the violations that are new since the previous cycle and those that resolved.
+// On the first cycle every violation is new.
+func (r *HealthCheckResult) diffViolations(previous []ViolationRef) {
+	r.NewViolations = subtractViolationRefs(r.ViolationRefs, previous)
+	r.ResolvedViolations = subtractViolationRefs(previous, r.ViolationRefs)
+}
+
+// diffWarnings records the warnings that are new since the previous cycle
+func (r *HealthCheckResult) diffWarnings(previous []ViolationRef) {
+	r.NewWarnings = subtractViolationRefs(r.WarningRefs, previous)
+}
+
+// subtractViolationRefs returns the references in refs that are not in other, keeping their order
+func subtractViolationRefs(refs, other []ViolationRef) []ViolationRef {
+	seen := make(map[string]bool, len(other))
+	for _, ref := range other {
+		seen[ref.ID] = true
+	}
+	var remaining []ViolationRef
+	for _, ref := range refs {
+		if !seen[ref.ID] {
+			remaining = append(remaining, ref)
+		}
+	}
+	return remaining
+}
+
+// violationMetric returns the metric name a violation text starts with
+func violationMetric(violation string) string {
+	metric, _, _ := strings.Cut(violation, ":")
+	return metric
+}
@@ -0,0 +1,284 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"aks-health-monitor/pkg/azure/fake"
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/metrics"
+	"aks-health-monitor/pkg/runtimeinfo"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+// serializedViolations returns the violation lists of a result as they are serialized
+func serializedViolations(t *testing.T, result HealthCheckResult) []byte {
+	t.Helper()
+	data, err := json.Marshal(struct {
+		Evaluations   []ThresholdEvaluation
+		Violations    []string
+		ViolationRefs []ViolationRef
+		Warnings      []string
+		WarningRefs   []ViolationRef
+		NewViolations []ViolationRef
+	}{result.Evaluations, result.Violations, result.ViolationRefs, result.Warnings, result.WarningRefs, result.NewViolations})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestViolationListsSerializeIdenticallyWhateverTheEvaluationOrder(t *testing.T) {
+	evaluations := []ThresholdEvaluation{
+		{Metric: metrics.PendingPodsPercentMetric, Violated: true, Violation: "pending_pods_percent: 40% > 15%"},
+		{Metric: metrics.NotReadyNodesPercentMetric, Violated: true, Violation: "not_ready_nodes_percent: 50% > 25%"},
+		{Metric: metrics.FailedJobsMetric, Severity: SeverityWarn, Warning: "failed_jobs: 2 >= 2 (warn)"},
+		{Metric: metrics.CrashingPodsPercentMetric, Violated: true, Violation: "crashing_pods_percent: 30% > 10%"},
+		{Metric: metrics.RestartCountMetric, Severity: SeverityWarn, Warning: "restart_count: 8 >= 5 (warn)"},
+	}
+
+	var want []byte
+	// Each rotation stands for collectors finishing in another order
+	for shift := range evaluations {
+		rotated := append(append([]ThresholdEvaluation(nil), evaluations[shift:]...), evaluations[:shift]...)
+		var result HealthCheckResult
+		result.recordEvaluations(rotated)
+		result.canonicalizeViolations()
+		result.Evaluations = nil
+
+		got := serializedViolations(t, result)
+		if want == nil {
+			want = got
+			continue
+		}
+		if string(got) != string(want) {
+			t.Errorf("evaluations rotated by %d serialize as\n%s\nwant\n%s", shift, got, want)
+		}
+	}
+}
+
+func TestCyclesOverIdenticalClustersSerializeIdentically(t *testing.T) {
+	objects := append(unreadyNodes(3), node("ready-0", corev1.ConditionTrue))
+	for i := 0; i < 4; i++ {
+		objects = append(objects, pendingPod("default", fmt.Sprintf("web-%d", i)))
+	}
+
+	var want []byte
+	for run := 0; run < 10; run++ {
+		cfg := testConfig(t)
+		cfg.Thresholds.PodChurnPerMinute = config.ThresholdDisabled
+		// Fresh objects each run, since the fake clientset keeps the ones it is given
+		copies := make([]runtime.Object, len(objects))
+		for i, object := range objects {
+			copies[i] = object.DeepCopyObject()
+		}
+		c, azureClient := newTestController(t, cfg, copies...)
+		azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+
+		result := c.runCycle(context.Background())
+		if len(result.Violations) < 2 {
+			t.Fatalf("violations = %v, want several", result.Violations)
+		}
+		got := serializedViolations(t, result)
+		if want == nil {
+			want = got
+			continue
+		}
+		if string(got) != string(want) {
+			t.Fatalf("run %d serialized\n%s\nwant\n%s", run, got, want)
+		}
+	}
+}
+
+func TestViolationIDs(t *testing.T) {
+	ref := newViolationRef(metrics.NotReadyNodesPercentMetric, ruleThreshold)
+	// Downstream systems store IDs, so they must not change between releases
+	if ref.ID != "b0e4492908410f39" {
+		t.Errorf("ID = %q, want b0e4492908410f39", ref.ID)
+	}
+	for _, other := range []ViolationRef{
+		newViolationRef(metrics.NotReadyNodesPercentMetric, ruleCritical),
+		newViolationRef(metrics.NotReadyNodesPercentMetric, ruleWarn),
+		newViolationRef(metrics.PendingPodsPercentMetric, ruleThreshold),
+	} {
+		if other.ID == ref.ID {
+			t.Errorf("%s/%s shares the ID of %s/%s", other.Metric, other.Rule, ref.Metric, ref.Rule)
+		}
+	}
+}
+
+func TestNewAndResolvedViolations(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Thresholds.PodChurnPerMinute = config.ThresholdDisabled
+	kubeClient := kubefake.NewSimpleClientset(unreadyNodes(2)...)
+	c, azureClient := newTestControllerWithClient(t, cfg, kubeClient)
+	setMonitoredOperations(t, cfg, "- name: upgrade\n  action: alert")
+	azureClient.SetOperationStatuses(fake.InProgress("Upgrading"))
+	nodesViolated := []ViolationRef{newViolationRef(metrics.NotReadyNodesPercentMetric, ruleThreshold)}
+
+	steps := []struct {
+		name         string
+		ready        corev1.ConditionStatus
+		wantNew      []ViolationRef
+		wantResolved []ViolationRef
+	}{
+		{name: "first violation", ready: corev1.ConditionFalse, wantNew: nodesViolated},
+		{name: "persisting", ready: corev1.ConditionFalse},
+		{name: "recovered", ready: corev1.ConditionTrue, wantResolved: nodesViolated},
+		{name: "healthy", ready: corev1.ConditionTrue},
+		{name: "violated again", ready: corev1.ConditionFalse, wantNew: nodesViolated},
+	}
+	for _, step := range steps {
+		setNodesReady(t, kubeClient, step.ready)
+		result := c.runCycle(context.Background())
+		if got, want := fmt.Sprint(result.NewViolations), fmt.Sprint(step.wantNew); got != want {
+			t.Errorf("%s: new violations = %s, want %s", step.name, got, want)
+		}
+		if got, want := fmt.Sprint(result.ResolvedViolations), fmt.Sprint(step.wantResolved); got != want {
+			t.Errorf("%s: resolved violations = %s, want %s", step.name, got, want)
+		}
+	}
+}
+
+func TestHealthState(t *testing.T) {
+	violated := []string{"not_ready_nodes_percent: 100% (2/2) > 25%"}
+	// suppressed returns a result whose violations the policy suppressed for reason
+	suppressed := func(reason SuppressionReason) HealthCheckResult {
+		return HealthCheckResult{Violations: violated, Action: ActionSuppressed, SuppressionReason: reason}
+	}
+	tests := []struct {
+		name   string
+		result HealthCheckResult
+		want   HealthState
+	}{
+		{name: "nothing wrong", want: HealthHealthy},
+		{name: "cycle failed", result: HealthCheckResult{Error: "failed to list nodes"}, want: HealthDegraded},
+		{name: "operation failed", result: HealthCheckResult{OperationFailed: &OperationFailure{OperationType: "Upgrading"}}, want: HealthDegraded},
+		{name: "warnings only", result: HealthCheckResult{Warnings: violated}, want: HealthDegraded},
+		{name: "alert only", result: suppressed(SuppressionAlertOnly), want: HealthDegraded},
+		{name: "autoscaler initiated", result: suppressed(SuppressionAutoscalerInitiated), want: HealthDegraded},
+		{name: "trusted initiator", result: suppressed(SuppressionTrustedInitiator), want: HealthDegraded},
+		{name: "violation streak", result: suppressed(SuppressionViolationStreak), want: HealthDegraded},
+		{name: "incomplete data", result: suppressed(SuppressionIncompleteData), want: HealthAbortWorthy},
+		{name: "unsafe abort", result: suppressed(SuppressionUnsafeAbort), want: HealthAbortWorthy},
+		{name: "abort cooldown", result: suppressed(SuppressionAbortCooldown), want: HealthAbortWorthy},
+		{name: "dry run", result: suppressed(SuppressionDryRun), want: HealthAbortWorthy},
+		{name: "abort unavailable", result: suppressed(SuppressionAbortUnavailable), want: HealthAbortWorthy},
+		{name: "aborted", result: HealthCheckResult{Violations: violated, Action: ActionAborted}, want: HealthAbortWorthy},
+		{name: "abort failed", result: HealthCheckResult{Violations: violated, AbortError: "409 Conflict"}, want: HealthAbortWorthy},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.result.healthState(); got != test.want {
+				t.Errorf("health state = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestHealthStateGauge(t *testing.T) {
+	cfg := testConfig(t)
+	setMonitoredOperations(t, cfg, "- name: upgrade\n  action: alert")
+	cfg.Thresholds.PodChurnPerMinute = config.ThresholdDisabled
+	kubeClient := kubefake.NewSimpleClientset(unreadyNodes(2)...)
+	registry := prometheus.NewRegistry()
+	azureClient := fake.NewClient()
+	c, err := NewController(kubeClient, metrics.NewCollector(kubeClient, cfg.Collection), azureClient, cfg,
+		registry, &runtimeinfo.Info{Namespace: "aks-monitor", PodName: "aks-monitor-0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each cycle replaces the series, so the gauge has exactly one, labelled with the operation or none
+	for _, step := range []struct {
+		status    string
+		operation string
+		want      HealthState
+	}{
+		{status: "Succeeded", operation: "none", want: HealthHealthy},
+		{status: "Upgrading", operation: "Upgrading", want: HealthDegraded},
+		{status: "Succeeded", operation: "none", want: HealthHealthy},
+	} {
+		if step.status == "Succeeded" {
+			azureClient.SetOperationStatuses(fake.Idle())
+		} else {
+			azureClient.SetOperationStatuses(fake.InProgress(step.status))
+		}
+		result := c.runCycle(context.Background())
+		if result.HealthState != step.want {
+			t.Errorf("%s cycle health state = %s, want %s", step.status, result.HealthState, step.want)
+		}
+		want := fmt.Sprintf(`# HELP aks_monitor_cluster_health_state Cluster health from the most recent cycle: 0 healthy, 1 degraded, 2 abort-worthy. Labelled with the operation in progress, or none.
+# TYPE aks_monitor_cluster_health_state gauge
+aks_monitor_cluster_health_state{operation=%q} %d
+`, step.operation, step.want)
+		if err := testutil.GatherAndCompare(registry, strings.NewReader(want), "aks_monitor_cluster_health_state"); err != nil {
+			t.Errorf("%s cycle: %v", step.status, err)
+		}
+	}
+}
+
+func TestWarnTierMovesWithTheAbortTier(t *testing.T) {
+	crashing := string(metrics.CrashingPodsPercentMetric)
+	tests := []struct {
+		name      string
+		deltas    map[string]int
+		baseline  int
+		scaling   *config.ProgressScaling
+		progress  int
+		value     int
+		wantAbort int
+		wantWarn  int
+		want      Severity
+	}{
+		{name: "configured tiers", value: 12, wantAbort: 15, wantWarn: 10, want: SeverityWarn},
+		{name: "baseline at the warn threshold", deltas: map[string]int{crashing: 5}, baseline: 12, value: 12, wantAbort: 17, wantWarn: 12},
+		{name: "rise above the baseline", deltas: map[string]int{crashing: 5}, baseline: 12, value: 13, wantAbort: 17, wantWarn: 12, want: SeverityWarn},
+		{name: "rise beyond the delta", deltas: map[string]int{crashing: 5}, baseline: 12, value: 18, wantAbort: 17, wantWarn: 12, want: SeverityAbort},
+		{name: "scaled up late in the upgrade", scaling: &config.ProgressScaling{Start: 15, End: 30}, progress: 50, value: 16, wantAbort: 22, wantWarn: 17},
+		{name: "beyond the scaled warn threshold", scaling: &config.ProgressScaling{Start: 15, End: 30}, progress: 50, value: 18, wantAbort: 22, wantWarn: 17, want: SeverityWarn},
+		{name: "lowered below the margin", deltas: map[string]int{crashing: 2}, value: 1, wantAbort: 2, wantWarn: 0, want: SeverityWarn},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			thresholds := cfg.Thresholds
+			thresholds.CrashingPodsPercent = 15
+			warn := thresholds.WarnTier()
+			warn.CrashingPodsPercent = 10
+			thresholds.Warn = &warn
+			cfg.BaselineDeltas = test.deltas
+			var baseline *OperationBaseline
+			if test.deltas != nil {
+				baseline = &OperationBaseline{Operation: "Upgrading", Values: map[metrics.MetricType]int{metrics.CrashingPodsPercentMetric: test.baseline}}
+			}
+			if test.scaling != nil {
+				cfg.ProgressScaling = map[string]config.ProgressScaling{crashing: *test.scaling}
+			}
+			c, _ := newTestController(t, cfg)
+
+			phase := operationPhase{percentComplete: test.progress, upgrading: true}
+			evaluations := c.evaluateThresholds(cfg, thresholds, []metrics.MetricValue{{Type: metrics.CrashingPodsPercentMetric, Value: test.value}}, phase, baseline)
+			if len(evaluations) != 1 {
+				t.Fatalf("evaluations = %+v, want one", evaluations)
+			}
+			evaluation := evaluations[0]
+			if evaluation.Threshold != test.wantAbort || evaluation.WarnThreshold == nil || *evaluation.WarnThreshold != test.wantWarn {
+				t.Errorf("thresholds = abort %d, warn %v, want abort %d, warn %d", evaluation.Threshold, evaluation.WarnThreshold, test.wantAbort, test.wantWarn)
+			}
+			if evaluation.Severity != test.want {
+				t.Errorf("severity = %q, want %q", evaluation.Severity, test.want)
+			}
+		})
+	}
+}
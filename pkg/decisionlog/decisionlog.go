@@ -0,0 +1,152 @@
+// Package decisionlog ships automated decisions to an HTTP collector in the OPA decision log format,
+// so they can flow through the same pipeline as policy decisions made by OPA.
+package decisionlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+
+	"k8s.io/klog/v2"
+)
+
+// Decision is one decision log entry. Field names follow the OPA decision log schema.
+type Decision struct {
+	DecisionID string            `json:"decision_id"`
+	Path       string            `json:"path"`
+	Labels     map[string]string `json:"labels"`
+	Bundles    map[string]Bundle `json:"bundles,omitempty"`
+	Input      interface{}       `json:"input"`
+	Result     interface{}       `json:"result"`
+	Timestamp  time.Time         `json:"timestamp"`
+}
+
+// Bundle identifies the policy revision that produced a decision
+type Bundle struct {
+	Revision string `json:"revision"`
+}
+
+// Sink buffers decisions and posts them in batches as a gzipped JSON array, as OPA does
+type Sink struct {
+	endpoint      string
+	client        *http.Client
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+	queue         chan Decision
+}
+
+// NewSink creates a sink for the configured endpoint. Call Run to start delivery.
+func NewSink(cfg config.DecisionLogConfig) *Sink {
+	return &Sink{
+		endpoint:      cfg.Endpoint,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+		maxRetries:    cfg.MaxRetries,
+		queue:         make(chan Decision, 10*cfg.BatchSize),
+	}
+}
+
+// Log queues a decision without blocking. Decisions are dropped with a warning when the buffer is full,
+// so a slow collector never delays a health check.
+func (s *Sink) Log(decision Decision) {
+	select {
+	case s.queue <- decision:
+	default:
+		klog.Warningf("Decision log buffer full, dropping decision %s", decision.DecisionID)
+	}
+}
+
+// Run delivers queued decisions until ctx is cancelled, then flushes what is left
+func (s *Sink) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	var batch []Decision
+	for {
+		select {
+		case decision := <-s.queue:
+			batch = append(batch, decision)
+			if len(batch) >= s.batchSize {
+				s.deliver(ctx, batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				s.deliver(ctx, batch)
+				batch = nil
+			}
+		case <-ctx.Done():
+			for len(s.queue) > 0 {
+				batch = append(batch, <-s.queue)
+			}
+			if len(batch) > 0 {
+				// The run context is gone; give the final flush its own short deadline
+				flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				s.deliver(flushCtx, batch)
+				cancel()
+			}
+			return
+		}
+	}
+}
+
+// deliver posts a batch, retrying with exponential backoff. Batches that still fail are logged and dropped.
+func (s *Sink) deliver(ctx context.Context, batch []Decision) {
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		err := s.post(ctx, batch)
+		if err == nil {
+			klog.V(2).Infof("Delivered %d decisions to decision log", len(batch))
+			return
+		}
+		if attempt >= s.maxRetries || ctx.Err() != nil {
+			klog.Errorf("Dropping %d decisions after %d attempts: %v", len(batch), attempt+1, err)
+			return
+		}
+
+		klog.Warningf("Decision log delivery failed (attempt %d), retrying in %s: %v", attempt+1, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+		backoff *= 2
+	}
+}
+
+// post sends one batch to the collector
+func (s *Sink) post(ctx context.Context, batch []Decision) error {
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	if err := json.NewEncoder(gz).Encode(batch); err != nil {
+		return fmt.Errorf("failed to encode decisions: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress decisions: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("collector answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,429 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"aks-health-monitor/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Namespace is the Prometheus namespace shared by every series the monitor exports
+const Namespace = "aks_monitor"
+
+// Exporter holds the Prometheus collectors describing the controller's view and decisions
+type Exporter struct {
+	abortsSuppressed *prometheus.CounterVec
+	operationsFailed *prometheus.CounterVec
+	violations       *prometheus.CounterVec
+	aborts           *prometheus.CounterVec
+	abortAttempts    *prometheus.CounterVec
+	inProgress       *prometheus.GaugeVec
+	healthState      *prometheus.GaugeVec
+	cycleInterval    prometheus.Histogram
+	collection       prometheus.Histogram
+	collectorRuns    *prometheus.HistogramVec
+	metricsOnly      prometheus.Gauge
+	overrideActive   prometheus.Gauge
+	leader           prometheus.Gauge
+	panics           *prometheus.CounterVec
+	metricValues     *prometheus.GaugeVec
+	metricCounts     *prometheus.GaugeVec
+	metricTotals     *prometheus.GaugeVec
+	violationActive  *prometheus.GaugeVec
+	thresholds       *prometheus.GaugeVec
+	writeCircuit     prometheus.Gauge
+	heartbeat        prometheus.Gauge
+	abortPermission  prometheus.Gauge
+	configReloads    *prometheus.CounterVec
+	notifyFailures   *prometheus.CounterVec
+	fleetState       *prometheus.GaugeVec
+	fleetLastCheck   *prometheus.GaugeVec
+	fleetLastAbort   *prometheus.GaugeVec
+
+	// exemplars attaches the cycle's trace ID to violation and abort samples
+	exemplars bool
+}
+
+// NewExporter creates the controller's Prometheus collectors and registers them with the given registerer.
+// Registering against a registerer that already holds equivalent collectors reuses them instead of panicking,
+// so embedding applications can construct several exporters against one registry. When exemplars is true,
+// violation and abort samples carry the trace ID of the cycle that produced them; exemplars are only
+// exposed when the scrape negotiates OpenMetrics.
+func NewExporter(registerer prometheus.Registerer, exemplars bool) *Exporter {
+	e := &Exporter{
+		exemplars: exemplars,
+		abortsSuppressed: registerCounterVec(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "aborts_suppressed_total",
+			Help:      "Number of health check cycles with threshold violations that did not abort, by reason.",
+		}, []string{"reason"})),
+		operationsFailed: registerCounterVec(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "operations_failed_total",
+			Help:      "Number of observed operations that ended in the Failed provisioning state, by operation type.",
+		}, []string{"operation"})),
+		violations: registerCounterVec(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "threshold_violations_total",
+			Help:      "Number of threshold violations observed during operations, by metric.",
+		}, []string{"metric"})),
+		aborts: registerCounterVec(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "aborts_total",
+			Help:      "Number of operations aborted by the monitor, by operation type.",
+		}, []string{"operation"})),
+		abortAttempts: registerCounterVec(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "abort_attempts_total",
+			Help:      "Number of abort calls sent to Azure, by operation type and result: succeeded or failed.",
+		}, []string{"operation", "result"})),
+		inProgress: registerGaugeVec(registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "operation_in_progress",
+			Help:      "1 while an operation is in progress on the cluster, 0 otherwise. Labelled with the operation type, or none.",
+		}, []string{"operation"})),
+		healthState: registerGaugeVec(registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "cluster_health_state",
+			Help:      "Cluster health from the most recent cycle: 0 healthy, 1 degraded, 2 abort-worthy. Labelled with the operation in progress, or none.",
+		}, []string{"operation"})),
+		cycleInterval: register(registerer, prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "cycle_interval_seconds",
+			Help:      "Observed time between the starts of consecutive health check cycles.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 11),
+		})).(prometheus.Histogram),
+		collection: register(registerer, prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "collection_duration_seconds",
+			Help:      "Time taken by one collection of cluster metrics, from the first collector started to the last finished.",
+			Buckets:   prometheus.ExponentialBuckets(0.25, 2, 10),
+		})).(prometheus.Histogram),
+		collectorRuns: register(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "collector_duration_seconds",
+			Help:      "Time taken by one run of a collector, by collector.",
+			Buckets:   prometheus.ExponentialBuckets(0.05, 2, 12),
+		}, []string{"collector"})).(*prometheus.HistogramVec),
+		metricsOnly: register(registerer, prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "metrics_only_mode",
+			Help:      "1 while the controller runs without an Azure client and cannot detect or abort operations, 0 otherwise.",
+		})).(prometheus.Gauge),
+		overrideActive: register(registerer, prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "override_observe_mode",
+			Help:      "1 while the aks-monitor-override ConfigMap sets mode: observe and aborts are disabled, 0 otherwise.",
+		})).(prometheus.Gauge),
+		leader: register(registerer, prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "leader",
+			Help:      "1 while this replica holds the leader election Lease and runs the poll loop, 0 while it stands by. Always 1 without leader election.",
+		})).(prometheus.Gauge),
+		panics: registerCounterVec(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "panics_total",
+			Help:      "Number of panics recovered by the controller, by component.",
+		}, []string{"component"})),
+		metricValues: registerGaugeVec(registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "metric_value",
+			Help:      "Health metric values collected in the most recent cycle, by metric. Absent when the cycle collected none.",
+		}, []string{"metric"})),
+		metricCounts: registerGaugeVec(registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "metric_value_count",
+			Help:      "Numerator of percentage health metrics in the most recent cycle, e.g. crashing pods, by metric.",
+		}, []string{"metric"})),
+		metricTotals: registerGaugeVec(registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "metric_value_total",
+			Help:      "Denominator of percentage health metrics in the most recent cycle, e.g. all pods, by metric.",
+		}, []string{"metric"})),
+		violationActive: registerGaugeVec(registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "violation_active",
+			Help:      "1 when a metric violated its threshold in the most recent cycle and the violation counts toward an abort, 0 otherwise, by metric. Absent for metrics that were not evaluated.",
+		}, []string{"metric"})),
+		thresholds: registerGaugeVec(registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "threshold",
+			Help:      "Threshold in effect for each metric evaluated in the most recent cycle, after per-operation overrides, by metric.",
+		}, []string{"metric"})),
+		writeCircuit: register(registerer, prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "azure_write_circuit_state",
+			Help:      "State of the circuit breaker guarding Azure aborts: 0 closed, 1 half-open, 2 open.",
+		})).(prometheus.Gauge),
+		heartbeat: register(registerer, prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "heartbeat_timestamp",
+			Help:      "Unix time at which the run loop last completed a health check cycle.",
+		})).(prometheus.Gauge),
+		abortPermission: register(registerer, prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "abort_permission_ok",
+			Help:      "1 when the last permission check found the abort identity may abort operations on the cluster, 0 when it found it may not.",
+		})).(prometheus.Gauge),
+		configReloads: registerCounterVec(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "config_reloads_total",
+			Help:      "Number of configuration file revisions picked up at runtime, by result: applied or rejected.",
+		}, []string{"result"})),
+		notifyFailures: registerCounterVec(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "notifications_failed_total",
+			Help:      "Number of violation and abort notifications dropped after their retries or a full queue, by webhook host.",
+		}, []string{"sink"})),
+		fleetState: registerGaugeVec(registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "fleet_cluster_state",
+			Help:      "1 for the current fleet state of each monitored cluster: healthy, degraded, abort-worthy, error or pending. Labelled with the cluster, its resource group and the state.",
+		}, []string{"cluster", "resource_group", "state"})),
+		fleetLastCheck: registerGaugeVec(registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "fleet_last_check_timestamp_seconds",
+			Help:      "Unix time of the most recent health check cycle of each monitored cluster.",
+		}, []string{"cluster", "resource_group"})),
+		fleetLastAbort: registerGaugeVec(registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "fleet_last_abort_timestamp_seconds",
+			Help:      "Unix time of the most recent abort issued for each monitored cluster. Absent until one is issued.",
+		}, []string{"cluster", "resource_group"})),
+	}
+
+	return e
+}
+
+// RecordAbortSuppressed counts a cycle whose violations did not lead to an abort
+func (e *Exporter) RecordAbortSuppressed(reason string) {
+	e.abortsSuppressed.WithLabelValues(reason).Inc()
+}
+
+// RecordOperationFailed counts an operation that ended in the Failed provisioning state
+func (e *Exporter) RecordOperationFailed(operation string) {
+	e.operationsFailed.WithLabelValues(operation).Inc()
+}
+
+// RecordViolation counts a threshold violation
+func (e *Exporter) RecordViolation(ctx context.Context, metric string) {
+	e.inc(ctx, e.violations.WithLabelValues(metric))
+}
+
+// RecordAbort counts an abort issued by the monitor
+func (e *Exporter) RecordAbort(ctx context.Context, operation string) {
+	e.inc(ctx, e.aborts.WithLabelValues(operation))
+}
+
+// Results of an abort call sent to Azure
+const (
+	AbortSucceeded = "succeeded"
+	AbortFailed    = "failed"
+	// AbortOperationFinished means ARM answered 409 Conflict: the operation had finished
+	AbortOperationFinished = "operation_finished"
+)
+
+// RecordAbortAttempt counts an abort call sent to Azure by its result
+func (e *Exporter) RecordAbortAttempt(operation, result string) {
+	e.abortAttempts.WithLabelValues(operation, result).Inc()
+}
+
+// SetOperationInProgress publishes whether an operation is in progress. Like the health state, the gauge
+// always has exactly one series.
+func (e *Exporter) SetOperationInProgress(operation string, inProgress bool) {
+	e.inProgress.Reset()
+	if !inProgress {
+		e.inProgress.WithLabelValues("none").Set(0)
+		return
+	}
+	e.inProgress.WithLabelValues(operation).Set(1)
+}
+
+// SetHealthState publishes the health state of the latest cycle. Previous operation labels are dropped
+// so the gauge always has exactly one series.
+func (e *Exporter) SetHealthState(operation string, state int) {
+	e.healthState.Reset()
+	e.healthState.WithLabelValues(operation).Set(float64(state))
+}
+
+// SetMetricValues publishes the metrics collected in the latest cycle, replacing those of earlier cycles
+// so metrics that were not collected do not linger
+func (e *Exporter) SetMetricValues(values []metrics.MetricValue) {
+	e.metricValues.Reset()
+	e.metricCounts.Reset()
+	e.metricTotals.Reset()
+	for _, value := range values {
+		metric := string(value.Type)
+		e.metricValues.WithLabelValues(metric).Set(float64(value.Value))
+		if value.Fraction != nil {
+			e.metricCounts.WithLabelValues(metric).Set(float64(value.Fraction.Numerator))
+			e.metricTotals.WithLabelValues(metric).Set(float64(value.Fraction.Denominator))
+		}
+	}
+}
+
+// ThresholdState is the controller's evaluation of one metric in a cycle
+type ThresholdState struct {
+	Metric    string
+	Threshold int
+
+	// Active is true when the metric violated Threshold and the violation counts toward an abort
+	Active bool
+}
+
+// SetThresholdStates publishes the evaluations of the latest cycle, replacing those of earlier cycles so
+// metrics that were not evaluated do not linger
+func (e *Exporter) SetThresholdStates(states []ThresholdState) {
+	e.violationActive.Reset()
+	e.thresholds.Reset()
+	for _, state := range states {
+		active := 0.0
+		if state.Active {
+			active = 1
+		}
+		e.violationActive.WithLabelValues(state.Metric).Set(active)
+		e.thresholds.WithLabelValues(state.Metric).Set(float64(state.Threshold))
+	}
+}
+
+// ClusterState is one cluster's entry in the fleet gauges
+type ClusterState struct {
+	Cluster       string
+	ResourceGroup string
+	State         string
+
+	// LastCheck and LastAbort are zero when there was none
+	LastCheck time.Time
+	LastAbort time.Time
+}
+
+// SetFleetStates publishes the state of every monitored cluster, replacing earlier states so each
+// cluster has exactly one state series
+func (e *Exporter) SetFleetStates(states []ClusterState) {
+	e.fleetState.Reset()
+	e.fleetLastCheck.Reset()
+	e.fleetLastAbort.Reset()
+	for _, state := range states {
+		e.fleetState.WithLabelValues(state.Cluster, state.ResourceGroup, state.State).Set(1)
+		if !state.LastCheck.IsZero() {
+			e.fleetLastCheck.WithLabelValues(state.Cluster, state.ResourceGroup).Set(float64(state.LastCheck.Unix()))
+		}
+		if !state.LastAbort.IsZero() {
+			e.fleetLastAbort.WithLabelValues(state.Cluster, state.ResourceGroup).Set(float64(state.LastAbort.Unix()))
+		}
+	}
+}
+
+// RecordPanic counts a recovered panic
+func (e *Exporter) RecordPanic(component string) {
+	e.panics.WithLabelValues(component).Inc()
+}
+
+// SetOverrideActive publishes whether the override ConfigMap disables aborts
+func (e *Exporter) SetOverrideActive(active bool) {
+	if active {
+		e.overrideActive.Set(1)
+	} else {
+		e.overrideActive.Set(0)
+	}
+}
+
+// SetLeader publishes whether this replica runs the poll loop
+func (e *Exporter) SetLeader(leader bool) {
+	if leader {
+		e.leader.Set(1)
+	} else {
+		e.leader.Set(0)
+	}
+}
+
+// SetMetricsOnly publishes whether the controller is running in metrics-only mode
+func (e *Exporter) SetMetricsOnly(metricsOnly bool) {
+	if metricsOnly {
+		e.metricsOnly.Set(1)
+	} else {
+		e.metricsOnly.Set(0)
+	}
+}
+
+// SetWriteCircuitState publishes the state of the Azure write circuit breaker
+func (e *Exporter) SetWriteCircuitState(state int) {
+	e.writeCircuit.Set(float64(state))
+}
+
+// SetHeartbeat publishes the time at which the run loop last completed a cycle
+func (e *Exporter) SetHeartbeat(at time.Time) {
+	e.heartbeat.Set(float64(at.Unix()))
+}
+
+// SetAbortPermission publishes the result of the last abort permission check
+func (e *Exporter) SetAbortPermission(ok bool) {
+	if ok {
+		e.abortPermission.Set(1)
+	} else {
+		e.abortPermission.Set(0)
+	}
+}
+
+// RecordConfigReload counts a configuration file revision seen by the watcher
+func (e *Exporter) RecordConfigReload(result string) {
+	e.configReloads.WithLabelValues(result).Inc()
+}
+
+// RecordNotificationFailure counts a notification a webhook did not receive
+func (e *Exporter) RecordNotificationFailure(sink string) {
+	e.notifyFailures.WithLabelValues(sink).Inc()
+}
+
+// ObserveCycleInterval records the time between the starts of two consecutive cycles
+func (e *Exporter) ObserveCycleInterval(interval time.Duration) {
+	e.cycleInterval.Observe(interval.Seconds())
+}
+
+// ObserveCollectionDuration records the time one collection of cluster metrics took
+func (e *Exporter) ObserveCollectionDuration(duration time.Duration) {
+	e.collection.Observe(duration.Seconds())
+}
+
+// ObserveCollectorDuration records the time one run of a collector took
+func (e *Exporter) ObserveCollectorDuration(collector string, duration time.Duration) {
+	e.collectorRuns.WithLabelValues(collector).Observe(duration.Seconds())
+}
+
+// inc increments a counter, attaching the context's trace ID as an exemplar when enabled
+func (e *Exporter) inc(ctx context.Context, counter prometheus.Counter) {
+	if e.exemplars {
+		if traceID := TraceIDFromContext(ctx); traceID != "" {
+			if adder, ok := counter.(prometheus.ExemplarAdder); ok {
+				adder.AddWithExemplar(1, prometheus.Labels{"trace_id": traceID})
+				return
+			}
+		}
+	}
+	counter.Inc()
+}
+
+// register registers a collector, returning the already registered equivalent if there is one
+func register(registerer prometheus.Registerer, collector prometheus.Collector) prometheus.Collector {
+	if err := registerer.Register(collector); err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if errors.As(err, &alreadyRegistered) {
+			return alreadyRegistered.ExistingCollector
+		}
+		panic(err)
+	}
+	return collector
+}
+
+// registerCounterVec registers a counter vector, reusing an existing registration
+func registerCounterVec(registerer prometheus.Registerer, counter *prometheus.CounterVec) *prometheus.CounterVec {
+	return register(registerer, counter).(*prometheus.CounterVec)
+}
+
+// registerGaugeVec registers a gauge vector, reusing an existing registration
+func registerGaugeVec(registerer prometheus.Registerer, gauge *prometheus.GaugeVec) *prometheus.GaugeVec {
+	return register(registerer, gauge).(*prometheus.GaugeVec)
+}
@@ -0,0 +1,113 @@
+package exporter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// counterSample returns the sample of the counter family name whose labels include label=value
+func counterSample(t *testing.T, registry *prometheus.Registry, name, label, value string) *dto.Counter {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, pair := range metric.GetLabel() {
+				if pair.GetName() == label && pair.GetValue() == value {
+					return metric.GetCounter()
+				}
+			}
+		}
+	}
+	t.Fatalf("%s{%s=%q} was not gathered", name, label, value)
+	return nil
+}
+
+// exemplarTraceID returns the trace_id label of the counter's exemplar, or "" when it has none
+func exemplarTraceID(counter *dto.Counter) string {
+	for _, pair := range counter.GetExemplar().GetLabel() {
+		if pair.GetName() == "trace_id" {
+			return pair.GetValue()
+		}
+	}
+	return ""
+}
+
+func TestViolationAndAbortExemplars(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	tests := []struct {
+		name        string
+		exemplars   bool
+		ctx         context.Context
+		wantTraceID string
+	}{
+		{name: "enabled with a trace", exemplars: true, ctx: ContextWithTraceID(context.Background(), traceID), wantTraceID: traceID},
+		{name: "enabled without a trace", exemplars: true, ctx: context.Background()},
+		{name: "disabled", ctx: ContextWithTraceID(context.Background(), traceID)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			registry := prometheus.NewRegistry()
+			exporter := NewExporter(registry, test.exemplars)
+
+			exporter.RecordViolation(test.ctx, "not_ready_nodes_percent")
+			exporter.RecordAbort(test.ctx, "Upgrading")
+
+			for _, sample := range []struct{ name, label, value string }{
+				{"aks_monitor_threshold_violations_total", "metric", "not_ready_nodes_percent"},
+				{"aks_monitor_aborts_total", "operation", "Upgrading"},
+			} {
+				counter := counterSample(t, registry, sample.name, sample.label, sample.value)
+				if counter.GetValue() != 1 {
+					t.Errorf("%s = %v, want 1", sample.name, counter.GetValue())
+				}
+				if got := exemplarTraceID(counter); got != test.wantTraceID {
+					t.Errorf("%s exemplar trace_id = %q, want %q", sample.name, got, test.wantTraceID)
+				}
+			}
+		})
+	}
+}
+
+func TestExemplarsInOpenMetricsExposition(t *testing.T) {
+	const traceID = "0af7651916cd43dd8448eb211c80319c"
+	registry := prometheus.NewRegistry()
+	exporter := NewExporter(registry, true)
+	exporter.RecordViolation(ContextWithTraceID(context.Background(), traceID), "crashing_pods_percent")
+
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+	defer server.Close()
+	request, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `aks_monitor_threshold_violations_total{metric="crashing_pods_percent"} 1.0 # {trace_id="` + traceID + `"} 1.0`
+	if !strings.Contains(string(body), want) {
+		t.Errorf("exposition does not contain %q:\n%s", want, body)
+	}
+}
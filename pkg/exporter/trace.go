@@ -0,0 +1,30 @@
+package exporter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// traceIDKey is the context key holding the trace ID of the current health check cycle
+type traceIDKey struct{}
+
+// NewTraceID returns a random W3C trace-context compatible trace ID (32 lowercase hex characters)
+func NewTraceID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id[:])
+}
+
+// ContextWithTraceID returns a context carrying the trace ID
+func ContextWithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceIDFromContext returns the trace ID carried by the context, or "" if there is none
+func TraceIDFromContext(ctx context.Context) string {
+	traceID, _ := ctx.Value(traceIDKey{}).(string)
+	return traceID
+}
@@ -0,0 +1,145 @@
+package writer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+// DefaultForbiddenWarningInterval is how often a repeated RBAC denial for the same write is logged
+const DefaultForbiddenWarningInterval = 10 * time.Minute
+
+// Writer performs every Kubernetes write the controller makes. Updates are retried on conflict and
+// RBAC denials are logged as rate-limited warnings and swallowed, so missing optional permissions
+// do not turn into per-cycle error spam.
+type Writer struct {
+	kubeClient      kubernetes.Interface
+	warningInterval time.Duration
+
+	mu         sync.Mutex
+	lastWarned map[string]time.Time
+	now        func() time.Time
+}
+
+// New creates a new Writer
+func New(kubeClient kubernetes.Interface) *Writer {
+	return &Writer{
+		kubeClient:      kubeClient,
+		warningInterval: DefaultForbiddenWarningInterval,
+		lastWarned:      make(map[string]time.Time),
+		now:             time.Now,
+	}
+}
+
+// CreateEvent creates a Kubernetes Event
+func (w *Writer) CreateEvent(ctx context.Context, event *corev1.Event) error {
+	_, err := w.kubeClient.CoreV1().Events(event.Namespace).Create(ctx, event, metav1.CreateOptions{})
+	return w.handle("create event in "+event.Namespace, err)
+}
+
+// UpdateEvent updates an existing Kubernetes Event, for example to bump its count
+func (w *Writer) UpdateEvent(ctx context.Context, event *corev1.Event) error {
+	_, err := w.kubeClient.CoreV1().Events(event.Namespace).Update(ctx, event, metav1.UpdateOptions{})
+	return w.handle("update event in "+event.Namespace, err)
+}
+
+// MutateConfigMap applies mutate to the named ConfigMap, creating it if it does not exist.
+// The read-modify-write is retried when the update conflicts with a concurrent writer.
+func (w *Writer) MutateConfigMap(ctx context.Context, namespace, name string, mutate func(*corev1.ConfigMap) error) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMaps := w.kubeClient.CoreV1().ConfigMaps(namespace)
+
+		configMap, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			configMap = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			}
+			if err := mutate(configMap); err != nil {
+				return err
+			}
+			_, err = configMaps.Create(ctx, configMap, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// Lost a creation race; retry as an update
+				return apierrors.NewConflict(corev1.Resource("configmaps"), name, err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := mutate(configMap); err != nil {
+			return err
+		}
+		_, err = configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+		return err
+	})
+
+	return w.handle(fmt.Sprintf("update configmap %s/%s", namespace, name), err)
+}
+
+// PatchNodeAnnotations merges annotations into a node. A nil value removes the annotation.
+func (w *Writer) PatchNodeAnnotations(ctx context.Context, name string, annotations map[string]*string) error {
+	patch, err := annotationPatch(annotations)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.kubeClient.CoreV1().Nodes().Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return w.handle("patch node "+name, err)
+}
+
+// PatchNamespaceAnnotations merges annotations into a namespace. A nil value removes the annotation.
+func (w *Writer) PatchNamespaceAnnotations(ctx context.Context, name string, annotations map[string]*string) error {
+	patch, err := annotationPatch(annotations)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.kubeClient.CoreV1().Namespaces().Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return w.handle("patch namespace "+name, err)
+}
+
+// annotationPatch builds a JSON merge patch that sets or removes annotations
+func annotationPatch(annotations map[string]*string) ([]byte, error) {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build annotation patch: %w", err)
+	}
+	return patch, nil
+}
+
+// handle converts RBAC denials into rate-limited warnings and wraps other errors with the operation
+func (w *Writer) handle(operation string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if apierrors.IsForbidden(err) {
+		w.mu.Lock()
+		last, warned := w.lastWarned[operation]
+		now := w.now()
+		if !warned || now.Sub(last) >= w.warningInterval {
+			w.lastWarned[operation] = now
+			klog.Warningf("Permission denied to %s, skipping (check RBAC): %v", operation, err)
+		}
+		w.mu.Unlock()
+		return nil
+	}
+
+	return fmt.Errorf("failed to %s: %w", operation, err)
+}
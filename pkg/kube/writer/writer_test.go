@@ -0,0 +1,216 @@
+package writer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var configMapsResource = schema.GroupResource{Resource: "configmaps"}
+
+// failFirst returns a reactor that fails the first count matching actions with err and lets the rest
+// through to the object tracker
+func failFirst(count int, err error) k8stesting.ReactionFunc {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if count == 0 {
+			return false, nil, nil
+		}
+		count--
+		return true, nil, err
+	}
+}
+
+// countActions returns how many actions of the verb on the resource the clientset received
+func countActions(kubeClient *fake.Clientset, verb, resource string) int {
+	count := 0
+	for _, action := range kubeClient.Actions() {
+		if action.Matches(verb, resource) {
+			count++
+		}
+	}
+	return count
+}
+
+func existingConfigMap() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "aks-monitor", Name: "status"},
+		Data:       map[string]string{"startedAt": "2026-10-15T09:00:00Z"},
+	}
+}
+
+// setStopped is a mutation that marks monitoring as stopped, counting its calls
+func setStopped(calls *int) func(*corev1.ConfigMap) error {
+	return func(configMap *corev1.ConfigMap) error {
+		*calls++
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data["monitoringStopped"] = "true"
+		return nil
+	}
+}
+
+func TestMutateConfigMap(t *testing.T) {
+	tests := []struct {
+		name        string
+		existing    bool
+		reactors    map[string]k8stesting.ReactionFunc
+		wantCalls   int
+		wantUpdates int
+		wantCreates int
+	}{
+		{name: "creates a missing ConfigMap", wantCalls: 1, wantCreates: 1},
+		{name: "updates an existing ConfigMap", existing: true, wantCalls: 1, wantUpdates: 1},
+		{
+			name:        "retries an update conflict",
+			existing:    true,
+			reactors:    map[string]k8stesting.ReactionFunc{"update": failFirst(2, apierrors.NewConflict(configMapsResource, "status", nil))},
+			wantCalls:   3,
+			wantUpdates: 3,
+		},
+		{
+			name:     "retries a lost creation race as an update",
+			existing: true,
+			reactors: map[string]k8stesting.ReactionFunc{
+				// The first read misses the ConfigMap another replica creates before this one does
+				"get":    failFirst(1, apierrors.NewNotFound(configMapsResource, "status")),
+				"create": failFirst(1, apierrors.NewAlreadyExists(configMapsResource, "status")),
+			},
+			wantCalls:   2,
+			wantCreates: 1,
+			wantUpdates: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var objects []runtime.Object
+			if test.existing {
+				objects = append(objects, existingConfigMap())
+			}
+			kubeClient := fake.NewSimpleClientset(objects...)
+			for verb, reactor := range test.reactors {
+				kubeClient.PrependReactor(verb, "configmaps", reactor)
+			}
+
+			var calls int
+			if err := New(kubeClient).MutateConfigMap(context.Background(), "aks-monitor", "status", setStopped(&calls)); err != nil {
+				t.Fatal(err)
+			}
+
+			configMap, err := kubeClient.CoreV1().ConfigMaps("aks-monitor").Get(context.Background(), "status", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if configMap.Data["monitoringStopped"] != "true" {
+				t.Errorf("data = %v, want the mutation applied", configMap.Data)
+			}
+			if test.existing && configMap.Data["startedAt"] == "" {
+				t.Errorf("data = %v, want the existing keys kept", configMap.Data)
+			}
+			if calls != test.wantCalls {
+				t.Errorf("mutate called %d times, want %d", calls, test.wantCalls)
+			}
+			if updates := countActions(kubeClient, "update", "configmaps"); updates != test.wantUpdates {
+				t.Errorf("%d updates, want %d", updates, test.wantUpdates)
+			}
+			if creates := countActions(kubeClient, "create", "configmaps"); creates != test.wantCreates {
+				t.Errorf("%d creates, want %d", creates, test.wantCreates)
+			}
+		})
+	}
+}
+
+func TestMutateConfigMapGivesUpOnPersistentConflict(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(existingConfigMap())
+	kubeClient.PrependReactor("update", "configmaps", failFirst(100, apierrors.NewConflict(configMapsResource, "status", nil)))
+
+	var calls int
+	err := New(kubeClient).MutateConfigMap(context.Background(), "aks-monitor", "status", setStopped(&calls))
+	if !apierrors.IsConflict(err) {
+		t.Fatalf("error = %v, want the conflict", err)
+	}
+	if updates := countActions(kubeClient, "update", "configmaps"); updates < 2 || updates >= 100 {
+		t.Errorf("%d updates, want a bounded number of retries", updates)
+	}
+}
+
+func TestForbiddenWritesAreSwallowedWithRateLimitedWarning(t *testing.T) {
+	forbidden := apierrors.NewForbidden(schema.GroupResource{Resource: "events"}, "", nil)
+	kubeClient := fake.NewSimpleClientset()
+	kubeClient.PrependReactor("create", "events", failFirst(100, forbidden))
+	kubeClient.PrependReactor("patch", "nodes", failFirst(100, forbidden))
+
+	now := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	w := New(kubeClient)
+	w.now = func() time.Time { return now }
+	event := &corev1.Event{ObjectMeta: metav1.ObjectMeta{Namespace: "aks-monitor", Name: "aborted"}}
+	value := "true"
+
+	if err := w.CreateEvent(context.Background(), event); err != nil {
+		t.Fatalf("CreateEvent = %v, want the denial swallowed", err)
+	}
+	if err := w.PatchNodeAnnotations(context.Background(), "node-0", map[string]*string{"monitored": &value}); err != nil {
+		t.Fatalf("PatchNodeAnnotations = %v, want the denial swallowed", err)
+	}
+	first := w.lastWarned["create event in aks-monitor"]
+	if first != now || w.lastWarned["patch node node-0"] != now {
+		t.Fatalf("warnings = %v, want one per operation", w.lastWarned)
+	}
+
+	// A repeated denial within the interval is not logged again
+	now = now.Add(DefaultForbiddenWarningInterval / 2)
+	if err := w.CreateEvent(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if w.lastWarned["create event in aks-monitor"] != first {
+		t.Error("warned again within the interval")
+	}
+
+	now = now.Add(DefaultForbiddenWarningInterval)
+	if err := w.CreateEvent(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if w.lastWarned["create event in aks-monitor"] != now {
+		t.Error("did not warn again once the interval passed")
+	}
+}
+
+func TestOtherWriteErrorsAreReturned(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	kubeClient.PrependReactor("patch", "namespaces", failFirst(1, apierrors.NewServiceUnavailable("etcd leader changed")))
+
+	err := New(kubeClient).PatchNamespaceAnnotations(context.Background(), "default", map[string]*string{"monitored": nil})
+	if !apierrors.IsServiceUnavailable(err) {
+		t.Errorf("error = %v, want the unavailable error wrapped", err)
+	}
+}
+
+func TestPatchNodeAnnotationsSetsAndRemoves(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:        "node-0",
+		Annotations: map[string]string{"stale": "1", "kept": "1"},
+	}})
+	value := "true"
+
+	err := New(kubeClient).PatchNodeAnnotations(context.Background(), "node-0", map[string]*string{"monitored": &value, "stale": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := kubeClient.CoreV1().Nodes().Get(context.Background(), "node-0", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"monitored": "true", "kept": "1"}
+	if len(node.Annotations) != len(want) || node.Annotations["monitored"] != "true" || node.Annotations["kept"] != "1" {
+		t.Errorf("annotations = %v, want %v", node.Annotations, want)
+	}
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// admissionDenialMarkers are lowercase fragments that identify pod creation rejected by admission.
+// Messages vary across API server versions and webhook implementations, so matching is deliberately loose.
+var admissionDenialMarkers = []string{
+	"forbidden",
+	"admission webhook",
+	"denied the request",
+	"violates podsecurity",
+}
+
+// collectAdmissionMetrics counts workloads whose pods are being rejected at admission
+func (c *Collector) collectAdmissionMetrics(ctx context.Context) ([]MetricValue, error) {
+	callCtx, cancel := c.callContext(ctx)
+	events, err := c.kubeClient.CoreV1().Events("").List(callCtx, metav1.ListOptions{
+		FieldSelector: "reason=FailedCreate",
+	})
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	cutoff := time.Now().Add(-c.config.AdmissionEventWindow)
+
+	// Keyed by namespace/kind/name so repeated events for one workload count once
+	blocked := make(map[string]string)
+	for _, event := range events.Items {
+		kind := event.InvolvedObject.Kind
+		if kind != "ReplicaSet" && kind != "DaemonSet" {
+			continue
+		}
+		if !c.monitorsNamespace(event.InvolvedObject.Namespace) {
+			continue
+		}
+		if eventTime(event).Before(cutoff) || !isAdmissionDenial(event.Message) {
+			continue
+		}
+		key := fmt.Sprintf("%s/%s/%s", event.InvolvedObject.Namespace, kind, event.InvolvedObject.Name)
+		blocked[key] = event.Message
+	}
+
+	if len(blocked) > 0 {
+		if err := c.attributeToDeployments(ctx, blocked); err != nil {
+			return nil, err
+		}
+	}
+
+	offenders := make([]string, 0, len(blocked))
+	for key, message := range blocked {
+		offenders = append(offenders, fmt.Sprintf("%s: %s", key, message))
+	}
+	sort.Strings(offenders)
+
+	return []MetricValue{
+		{Type: WorkloadsBlockedByAdmissionMetric, Value: len(blocked), Offenders: offenders},
+	}, nil
+}
+
+// attributeToDeployments replaces blocked ReplicaSets with their owning Deployment when the Deployment
+// is short of its desired replicas, annotating the entry with the replica deficit
+func (c *Collector) attributeToDeployments(ctx context.Context, blocked map[string]string) error {
+	callCtx, cancel := c.callContext(ctx)
+	deployments, err := c.kubeClient.AppsV1().Deployments("").List(callCtx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to list deployments: %w", err)
+	}
+
+	for _, deployment := range deployments.Items {
+		desired := int32(1)
+		if deployment.Spec.Replicas != nil {
+			desired = *deployment.Spec.Replicas
+		}
+		if deployment.Status.Replicas >= desired {
+			continue
+		}
+
+		prefix := fmt.Sprintf("%s/ReplicaSet/%s-", deployment.Namespace, deployment.Name)
+		for key, message := range blocked {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			delete(blocked, key)
+			deploymentKey := fmt.Sprintf("%s/Deployment/%s", deployment.Namespace, deployment.Name)
+			blocked[deploymentKey] = fmt.Sprintf("%d/%d replicas exist: %s", deployment.Status.Replicas, desired, message)
+		}
+	}
+
+	return nil
+}
+
+// isAdmissionDenial reports whether a FailedCreate message indicates an admission rejection
+func isAdmissionDenial(message string) bool {
+	lower := strings.ToLower(message)
+	for _, marker := range admissionDenialMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventTime returns the most recent timestamp available on an event
+func eventTime(event corev1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
@@ -0,0 +1,367 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"k8s.io/klog/v2"
+)
+
+// Priority orders collectors when the per-cycle budget is tight
+type Priority int
+
+const (
+	// PriorityCritical collectors always run, even when the budget is exhausted
+	PriorityCritical Priority = iota
+	// PriorityNormal collectors run while budget remains
+	PriorityNormal
+	// PriorityOptional collectors run last and are the first to be skipped
+	PriorityOptional
+)
+
+// String returns the priority name
+func (p Priority) String() string {
+	switch p {
+	case PriorityCritical:
+		return "critical"
+	case PriorityNormal:
+		return "normal"
+	case PriorityOptional:
+		return "optional"
+	default:
+		return fmt.Sprintf("Priority(%d)", int(p))
+	}
+}
+
+// SkipReasonBudgetExhausted marks a collector skipped because the cycle ran out of time or API calls
+const SkipReasonBudgetExhausted = "budget exhausted"
+
+// SkipReasonFailed prefixes the error of a collector whose failure policy is skip
+const SkipReasonFailed = "failed"
+
+// SkippedCollector records a collector that did not contribute to a collection
+type SkippedCollector struct {
+	Name     string `json:"name"`
+	Priority string `json:"priority"`
+	Reason   string `json:"reason"`
+}
+
+// APICallCounter counts requests sent to the Kubernetes API server. Install it on the client's
+// rest.Config with WrapTransport so the collector can enforce an API-call budget.
+type APICallCounter struct {
+	calls atomic.Int64
+}
+
+// WrapTransport returns a round tripper that counts every request passing through it
+func (c *APICallCounter) WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		c.calls.Add(1)
+		return rt.RoundTrip(req)
+	})
+}
+
+// Calls returns the number of requests counted so far
+func (c *APICallCounter) Calls() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.calls.Load()
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// cycleBudget tracks the time and API calls consumed by one collection
+type cycleBudget struct {
+	deadline   time.Time
+	maxCalls   int64
+	counter    *APICallCounter
+	startCalls int64
+}
+
+// newCycleBudget starts a budget; zero limits are unlimited
+func newCycleBudget(timeout time.Duration, maxCalls int, counter *APICallCounter) *cycleBudget {
+	b := &cycleBudget{maxCalls: int64(maxCalls), counter: counter, startCalls: counter.Calls()}
+	if timeout > 0 {
+		b.deadline = time.Now().Add(timeout)
+	}
+	return b
+}
+
+// exhausted reports whether the cycle has used up its time or API calls
+func (b *cycleBudget) exhausted() bool {
+	if !b.deadline.IsZero() && !time.Now().Before(b.deadline) {
+		return true
+	}
+	return b.maxCalls > 0 && b.counter != nil && b.counter.Calls()-b.startCalls >= b.maxCalls
+}
+
+// pastDeadline reports whether the budget's time has run out
+func (b *cycleBudget) pastDeadline() bool {
+	return !b.deadline.IsZero() && !time.Now().Before(b.deadline)
+}
+
+// bound limits a non-critical collector to the time left in the budget
+func (b *cycleBudget) bound(ctx context.Context) (context.Context, context.CancelFunc) {
+	if b.deadline.IsZero() {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, b.deadline)
+}
+
+// registeredCollector is one step of a collection, run in priority order
+type registeredCollector struct {
+	name     string
+	priority Priority
+	requires []apiResource
+	collect  func(ctx context.Context, state *collectionState) ([]MetricValue, error)
+
+	// after names a collector of the same priority whose state this one reads; it starts once that one finished
+	after string
+
+	// disabled is set when API discovery found a required resource missing or the collectors configuration
+	// disables the collector
+	disabled string
+
+	// timeout bounds one run; 0 leaves it bounded by the budget only
+	timeout time.Duration
+
+	// skipOnFailure leaves the collector's metrics out when it fails instead of failing the collection
+	skipOnFailure bool
+}
+
+// PanicError reports a panic recovered from a collector
+type PanicError struct {
+	Collector string
+	Value     interface{}
+	Stack     []byte
+}
+
+// Error implements error
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s collector panicked: %v", e.Collector, e.Value)
+}
+
+// invoke runs the collector, converting a panic into a PanicError so one unusual object cannot stop the controller
+func (r registeredCollector) invoke(ctx context.Context, state *collectionState) (metrics []MetricValue, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = &PanicError{Collector: r.name, Value: recovered, Stack: debug.Stack()}
+		}
+	}()
+	return r.collect(ctx, state)
+}
+
+// collectionState carries intermediate results between collectors of one cycle
+type collectionState struct {
+	pods     *podTally
+	capacity *nodeCapacity
+}
+
+// CollectorError is the failure of a collector whose failure policy is fail
+type CollectorError struct {
+	Collector string
+	Err       error
+}
+
+// Error implements error
+func (e *CollectorError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Collector, e.Err)
+}
+
+// Unwrap returns the collector's error
+func (e *CollectorError) Unwrap() error {
+	return e.Err
+}
+
+// CollectionError reports the collectors that failed a collection. The collection still returns the
+// metrics of every other collector.
+type CollectionError struct {
+	Failures []*CollectorError
+}
+
+// Error implements error
+func (e *CollectionError) Error() string {
+	failures := make([]string, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		failures = append(failures, failure.Error())
+	}
+	return strings.Join(failures, "; ")
+}
+
+// Unwrap returns the error of every failed collector
+func (e *CollectionError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		errs = append(errs, failure)
+	}
+	return errs
+}
+
+// runCollectors runs the collectors tier by tier in priority order, and the collectors of a tier
+// concurrently within the budget. Critical collectors always run; others are skipped once the budget is
+// exhausted or when they run out of budgeted time. A failing collector does not stop the others: it is
+// listed as skipped with its error and, unless its failure policy is skip, also returned in a
+// *CollectionError alongside the metrics of every other collector. Metrics and skips are returned in
+// registration order. Each collector's metrics are passed to onCollected, when set, as soon as it
+// finishes, and every run is passed to record, when set; neither is called concurrently. A cancelled ctx
+// returns its error and no metrics.
+func runCollectors(ctx context.Context, collectors []registeredCollector, budget *cycleBudget, onCollected func(string, []MetricValue), record func(name string, started time.Time, err error)) ([]MetricValue, []SkippedCollector, error) {
+	var metrics []MetricValue
+	var skipped []SkippedCollector
+	var failures []*CollectorError
+	state := &collectionState{}
+
+	for _, priority := range []Priority{PriorityCritical, PriorityNormal, PriorityOptional} {
+		// Collectors disabled by API discovery are not skips: their data cannot exist
+		var tier []registeredCollector
+		for _, collector := range collectors {
+			if collector.priority == priority && collector.disabled == "" {
+				tier = append(tier, collector)
+			}
+		}
+		if len(tier) == 0 {
+			continue
+		}
+
+		// A cancelled cycle returns before starting more work whose results would be discarded
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		outcomes, err := runTier(ctx, tier, budget, state, onCollected, record)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, outcome := range outcomes {
+			metrics = append(metrics, outcome.metrics...)
+			if outcome.skipped != nil {
+				skipped = append(skipped, *outcome.skipped)
+			}
+			if outcome.err != nil {
+				failures = append(failures, &CollectorError{Collector: tier[i].name, Err: outcome.err})
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return metrics, skipped, &CollectionError{Failures: failures}
+	}
+	return metrics, skipped, nil
+}
+
+// collectorOutcome is what one collector contributed to a collection
+type collectorOutcome struct {
+	metrics []MetricValue
+	skipped *SkippedCollector
+
+	// err is the failure of a collector whose failure policy is fail
+	err error
+}
+
+// runTier runs the collectors of one priority concurrently in an errgroup. Normal and optional tiers share
+// the budget's deadline; critical ones are bounded by ctx only. A collector with after set starts once that
+// collector of the tier finished, so it can read the state it left. Every collector runs to completion
+// whatever its siblings do, and an outcome is returned for each; the only error is ctx's, once cancelled.
+func runTier(ctx context.Context, tier []registeredCollector, budget *cycleBudget, state *collectionState, onCollected func(string, []MetricValue), record func(name string, started time.Time, err error)) ([]collectorOutcome, error) {
+	var tierCtx context.Context
+	var cancel context.CancelFunc
+	if tier[0].priority == PriorityCritical {
+		tierCtx, cancel = context.WithCancel(ctx)
+	} else {
+		tierCtx, cancel = budget.bound(ctx)
+	}
+	defer cancel()
+	group, groupCtx := errgroup.WithContext(tierCtx)
+
+	finished := make(map[string]chan struct{}, len(tier))
+	for _, collector := range tier {
+		finished[collector.name] = make(chan struct{})
+	}
+
+	var mu sync.Mutex
+	outcomes := make([]collectorOutcome, len(tier))
+	for i, collector := range tier {
+		i, collector := i, collector
+		group.Go(func() error {
+			defer close(finished[collector.name])
+			if dependency, ok := finished[collector.after]; ok {
+				select {
+				case <-dependency:
+				case <-groupCtx.Done():
+				}
+			}
+
+			outcomes[i] = runCollector(groupCtx, collector, budget, state, func(started time.Time, err error, collected []MetricValue) {
+				mu.Lock()
+				defer mu.Unlock()
+				if record != nil {
+					record(collector.name, started, err)
+				}
+				if err == nil && onCollected != nil {
+					onCollected(collector.name, collected)
+				}
+			})
+			// Only the cycle's cancellation stops the tier; a failure is reported in the outcome
+			return ctx.Err()
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+	return outcomes, nil
+}
+
+// runCollector runs one collector within the budget and reports its run to finish. ctx is the tier's
+// context, which carries the budget's deadline for non-critical collectors.
+func runCollector(ctx context.Context, collector registeredCollector, budget *cycleBudget, state *collectionState, finish func(started time.Time, err error, collected []MetricValue)) collectorOutcome {
+	skip := &SkippedCollector{Name: collector.name, Priority: collector.priority.String(), Reason: SkipReasonBudgetExhausted}
+	if collector.priority != PriorityCritical && budget.exhausted() {
+		klog.Warningf("Skipping %s collector (%s): %s", collector.name, collector.priority, SkipReasonBudgetExhausted)
+		return collectorOutcome{skipped: skip}
+	}
+
+	collectCtx, cancel := ctx, context.CancelFunc(func() {})
+	if collector.timeout > 0 {
+		collectCtx, cancel = context.WithTimeout(ctx, collector.timeout)
+	}
+	started := time.Now()
+	collected, err := collector.invoke(collectCtx, state)
+	timedOut := errors.Is(collectCtx.Err(), context.DeadlineExceeded)
+	cancel()
+
+	if err != nil && timedOut && collector.timeout > 0 && !budget.pastDeadline() {
+		err = fmt.Errorf("timed out after %s: %w", collector.timeout, err)
+	}
+	finish(started, err, collected)
+
+	if err == nil {
+		return collectorOutcome{metrics: collected}
+	}
+	if collector.priority != PriorityCritical && timedOut && budget.pastDeadline() {
+		klog.Warningf("Skipping %s collector (%s): %s while collecting", collector.name, collector.priority, SkipReasonBudgetExhausted)
+		return collectorOutcome{skipped: skip}
+	}
+
+	skip.Reason = fmt.Sprintf("%s: %v", SkipReasonFailed, err)
+	var panicErr *PanicError
+	if collector.skipOnFailure && !errors.As(err, &panicErr) {
+		klog.Warningf("Skipping %s collector (%s), its failure policy is skip: %v", collector.name, collector.priority, err)
+		return collectorOutcome{skipped: skip}
+	}
+	klog.Errorf("Failed to collect %s metrics: %v", collector.name, err)
+	return collectorOutcome{skipped: skip, err: err}
+}
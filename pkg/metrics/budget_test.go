@@ -0,0 +1,194 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixedCollector returns a collector reporting one metric with the given value after delay, or failing with
+// err once it has waited
+func fixedCollector(name string, priority Priority, metricType MetricType, value int, delay time.Duration, err error) registeredCollector {
+	return registeredCollector{name: name, priority: priority, collect: func(ctx context.Context, state *collectionState) ([]MetricValue, error) {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []MetricValue{{Type: metricType, Value: value}}, nil
+	}}
+}
+
+func TestRunCollectorsReturnsPartialResults(t *testing.T) {
+	listErr := errors.New("the server is currently unable to handle the request")
+	collectors := []registeredCollector{
+		fixedCollector("nodes", PriorityCritical, NotReadyNodesPercentMetric, 10, 0, nil),
+		fixedCollector("pods", PriorityNormal, PendingPodsPercentMetric, 20, 100*time.Millisecond, nil),
+		fixedCollector("jobs", PriorityNormal, FailedJobsMetric, 0, 0, listErr),
+	}
+
+	metrics, skipped, err := runCollectors(context.Background(), collectors, newCycleBudget(0, 0, nil), nil, nil)
+
+	var collectionErr *CollectionError
+	if !errors.As(err, &collectionErr) {
+		t.Fatalf("error = %v, want a *CollectionError", err)
+	}
+	if len(collectionErr.Failures) != 1 || collectionErr.Failures[0].Collector != "jobs" || !errors.Is(err, listErr) {
+		t.Errorf("failures = %v, want only jobs with the list error", collectionErr)
+	}
+	if got := metricTypes(metrics); got != "not_ready_nodes_percent,pending_pods_percent" {
+		t.Errorf("metrics = %s, want the nodes and the slow pods metrics", got)
+	}
+	if len(skipped) != 1 || skipped[0].Name != "jobs" || !strings.HasPrefix(skipped[0].Reason, SkipReasonFailed) {
+		t.Errorf("skipped = %+v, want jobs as failed", skipped)
+	}
+}
+
+func TestRunCollectorsRunsTierConcurrently(t *testing.T) {
+	const delay = 200 * time.Millisecond
+	collectors := []registeredCollector{
+		fixedCollector("pods", PriorityNormal, PendingPodsPercentMetric, 1, delay, nil),
+		fixedCollector("jobs", PriorityNormal, FailedJobsMetric, 1, delay, nil),
+		fixedCollector("admission", PriorityNormal, WorkloadsBlockedByAdmissionMetric, 1, delay, nil),
+	}
+
+	started := time.Now()
+	metrics, _, err := runCollectors(context.Background(), collectors, newCycleBudget(0, 0, nil), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed >= 2*delay {
+		t.Errorf("collection took %s, want the tier to run concurrently in about %s", elapsed, delay)
+	}
+	if len(metrics) != len(collectors) {
+		t.Errorf("got %d metrics, want %d", len(metrics), len(collectors))
+	}
+}
+
+func TestRunCollectorsSharesBudgetDeadline(t *testing.T) {
+	collectors := []registeredCollector{
+		fixedCollector("nodes", PriorityCritical, NotReadyNodesPercentMetric, 1, 0, nil),
+		fixedCollector("pods", PriorityNormal, PendingPodsPercentMetric, 1, 10*time.Millisecond, nil),
+		fixedCollector("jobs", PriorityNormal, FailedJobsMetric, 1, time.Minute, nil),
+		fixedCollector("usage", PriorityOptional, CpuUsagePercentMetric, 1, 0, nil),
+	}
+
+	started := time.Now()
+	metrics, skipped, err := runCollectors(context.Background(), collectors, newCycleBudget(100*time.Millisecond, 0, nil), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("collection took %s, want it cut off at the budget", elapsed)
+	}
+	if got := metricTypes(metrics); got != "not_ready_nodes_percent,pending_pods_percent" {
+		t.Errorf("metrics = %s, want those collected within the budget", got)
+	}
+	var names []string
+	for _, skip := range skipped {
+		if skip.Reason != SkipReasonBudgetExhausted {
+			t.Errorf("%s skipped for %q, want %q", skip.Name, skip.Reason, SkipReasonBudgetExhausted)
+		}
+		names = append(names, skip.Name)
+	}
+	if strings.Join(names, ",") != "jobs,usage" {
+		t.Errorf("skipped = %v, want the slow jobs collector and the optional tier", names)
+	}
+}
+
+func TestRunCollectorsFailurePolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		skipOnFailure bool
+		collect       func(ctx context.Context, state *collectionState) ([]MetricValue, error)
+		wantErr       bool
+	}{
+		{
+			name: "fail",
+			collect: func(ctx context.Context, state *collectionState) ([]MetricValue, error) {
+				return nil, errors.New("forbidden")
+			},
+			wantErr: true,
+		},
+		{
+			name:          "skip",
+			skipOnFailure: true,
+			collect: func(ctx context.Context, state *collectionState) ([]MetricValue, error) {
+				return nil, errors.New("forbidden")
+			},
+		},
+		{
+			name:          "panic fails even with skip",
+			skipOnFailure: true,
+			collect:       func(ctx context.Context, state *collectionState) ([]MetricValue, error) { panic("unexpected object") },
+			wantErr:       true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			collectors := []registeredCollector{
+				fixedCollector("pods", PriorityNormal, PendingPodsPercentMetric, 1, 0, nil),
+				{name: "admission", priority: PriorityNormal, collect: test.collect, skipOnFailure: test.skipOnFailure},
+			}
+			metrics, skipped, err := runCollectors(context.Background(), collectors, newCycleBudget(0, 0, nil), nil, nil)
+			if (err != nil) != test.wantErr {
+				t.Errorf("error = %v, want error: %v", err, test.wantErr)
+			}
+			if len(metrics) != 1 {
+				t.Errorf("metrics = %v, want the pods metric", metrics)
+			}
+			if len(skipped) != 1 || skipped[0].Name != "admission" {
+				t.Errorf("skipped = %+v, want admission", skipped)
+			}
+		})
+	}
+}
+
+func TestRunCollectorsRecordsPanic(t *testing.T) {
+	collectors := []registeredCollector{
+		{name: "daemonSets", priority: PriorityNormal, collect: func(ctx context.Context, state *collectionState) ([]MetricValue, error) {
+			panic("nil pointer")
+		}},
+	}
+	_, _, err := runCollectors(context.Background(), collectors, newCycleBudget(0, 0, nil), nil, nil)
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || panicErr.Collector != "daemonSets" || len(panicErr.Stack) == 0 {
+		t.Errorf("error = %v, want a PanicError of daemonSets with its stack", err)
+	}
+}
+
+func TestRunCollectorsWaitsForDependency(t *testing.T) {
+	var sawPods bool
+	collectors := []registeredCollector{
+		{name: "configReferences", priority: PriorityNormal, after: "pods", collect: func(ctx context.Context, state *collectionState) ([]MetricValue, error) {
+			sawPods = state.pods != nil
+			return nil, nil
+		}},
+		{name: "pods", priority: PriorityNormal, collect: func(ctx context.Context, state *collectionState) ([]MetricValue, error) {
+			time.Sleep(20 * time.Millisecond)
+			state.pods = &podTally{}
+			return nil, nil
+		}},
+	}
+	if _, _, err := runCollectors(context.Background(), collectors, newCycleBudget(0, 0, nil), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !sawPods {
+		t.Error("configReferences started before pods finished")
+	}
+}
+
+// metricTypes lists the types of metrics in order, comma-separated
+func metricTypes(metrics []MetricValue) string {
+	types := make([]string, 0, len(metrics))
+	for _, metric := range metrics {
+		types = append(types, string(metric.Type))
+	}
+	return strings.Join(types, ",")
+}
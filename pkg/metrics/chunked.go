@@ -0,0 +1,182 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// Coverage describes how fresh the aggregated pod data is when collecting in chunked mode
+type Coverage struct {
+	Chunked           bool          `json:"chunked"`
+	NamespacesSampled int           `json:"namespacesSampled"`
+	NamespacesTotal   int           `json:"namespacesTotal"`
+	RotationComplete  bool          `json:"rotationComplete"`
+	OldestSampleAge   time.Duration `json:"oldestSampleAge"`
+
+	// Skipped lists collectors that did not run in the most recent collection
+	Skipped []SkippedCollector `json:"skipped,omitempty"`
+
+	// Disabled lists collectors turned off because the API server does not serve their resources.
+	// Their metrics are absent rather than zero, but they do not make the coverage incomplete.
+	Disabled []DisabledCollector `json:"disabled,omitempty"`
+}
+
+// namespaceSample is the most recent pod tally observed for a namespace
+type namespaceSample struct {
+	tally     podTally
+	sampledAt time.Time
+	cycle     int
+}
+
+// namespaceRotation tracks round-robin progress through namespaces and the per-namespace samples
+// that make up the aggregate
+type namespaceRotation struct {
+	mu       sync.Mutex
+	length   int
+	cycle    int
+	samples  map[string]namespaceSample
+	coverage Coverage
+	now      func() time.Time
+}
+
+// newNamespaceRotation creates a rotation that scans every namespace once per length cycles
+func newNamespaceRotation(length int) *namespaceRotation {
+	if length < 1 {
+		length = 1
+	}
+	return &namespaceRotation{
+		length:  length,
+		samples: make(map[string]namespaceSample),
+		now:     time.Now,
+	}
+}
+
+// collectChunkedPodMetrics scans a fraction of namespaces and returns the aggregate across all of them
+func (c *Collector) collectChunkedPodMetrics(ctx context.Context) (podTally, error) {
+	callCtx, cancel := c.callContext(ctx)
+	namespaceList, err := c.kubeClient.CoreV1().Namespaces().List(callCtx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		return podTally{}, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	namespaces := make([]string, 0, len(namespaceList.Items))
+	for _, ns := range namespaceList.Items {
+		namespaces = append(namespaces, ns.Name)
+	}
+	sort.Strings(namespaces)
+
+	r := c.rotation
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	chunk := r.chunk(namespaces)
+	for _, namespace := range chunk {
+		// Stop between namespaces once the cycle is cancelled; samples taken so far are kept
+		if err := ctx.Err(); err != nil {
+			return podTally{}, err
+		}
+		var tally podTally
+		err := c.listPods(ctx, namespace, func(pods []corev1.Pod) {
+			tally.add(c.tallyPods(pods))
+		})
+		if err != nil {
+			return podTally{}, err
+		}
+		r.samples[namespace] = namespaceSample{
+			tally:     tally,
+			sampledAt: r.now(),
+			cycle:     r.cycle,
+		}
+	}
+
+	aggregate := r.aggregate(namespaces)
+	klog.V(2).Infof("Chunked collection scanned %d/%d namespaces (cycle %d of rotation length %d)",
+		len(chunk), len(namespaces), r.cycle%r.length, r.length)
+	r.cycle++
+
+	return aggregate, nil
+}
+
+// chunk returns the namespaces scheduled for the current cycle. Namespaces are interleaved
+// across the rotation so each one is scanned exactly once per rotation.
+func (r *namespaceRotation) chunk(namespaces []string) []string {
+	index := r.cycle % r.length
+
+	var chunk []string
+	for i, namespace := range namespaces {
+		if i%r.length == index {
+			chunk = append(chunk, namespace)
+		}
+	}
+	return chunk
+}
+
+// aggregate sums the latest sample of every current namespace, dropping namespaces that no longer exist
+// and samples that were not refreshed within two rotations, and updates the coverage indicator
+func (r *namespaceRotation) aggregate(namespaces []string) podTally {
+	current := make(map[string]bool, len(namespaces))
+	for _, namespace := range namespaces {
+		current[namespace] = true
+	}
+
+	var aggregate podTally
+	var oldest time.Time
+	for namespace, sample := range r.samples {
+		if !current[namespace] || r.cycle-sample.cycle >= 2*r.length {
+			delete(r.samples, namespace)
+			continue
+		}
+		aggregate.add(sample.tally)
+		if oldest.IsZero() || sample.sampledAt.Before(oldest) {
+			oldest = sample.sampledAt
+		}
+	}
+
+	r.coverage = Coverage{
+		Chunked:           true,
+		NamespacesSampled: len(r.samples),
+		NamespacesTotal:   len(namespaces),
+		RotationComplete:  len(r.samples) == len(namespaces),
+	}
+	if !oldest.IsZero() {
+		r.coverage.OldestSampleAge = r.now().Sub(oldest)
+	}
+
+	return aggregate
+}
+
+// Coverage returns the freshness of the most recent collection. In full collection mode every
+// pod aggregate is complete; collectors skipped for budget are listed in either mode.
+func (c *Collector) Coverage() Coverage {
+	coverage := Coverage{RotationComplete: true}
+	if c.config.Mode == config.CollectionModeChunked {
+		c.rotation.mu.Lock()
+		coverage = c.rotation.coverage
+		c.rotation.mu.Unlock()
+	}
+
+	c.mu.Lock()
+	coverage.Skipped = append([]SkippedCollector(nil), c.skipped...)
+	c.mu.Unlock()
+
+	if disabled := c.DisabledCollectors(); len(disabled) > 0 {
+		coverage.Disabled = disabled
+	}
+
+	return coverage
+}
+
+// Complete reports whether the collection covered every namespace and ran every collector
+func (c Coverage) Complete() bool {
+	return c.RotationComplete && len(c.Skipped) == 0
+}
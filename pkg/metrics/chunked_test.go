@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// namespacedPods returns count namespaces, the i-th holding i+1 pending pods
+func namespacedPods(count int) []runtime.Object {
+	var objects []runtime.Object
+	for i := 0; i < count; i++ {
+		namespace := fmt.Sprintf("team-%d", i)
+		objects = append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})
+		for j := 0; j <= i; j++ {
+			objects = append(objects, pendingPod(namespace, fmt.Sprintf("web-%d", j)))
+		}
+	}
+	return objects
+}
+
+func TestChunkedAggregateConvergesOverRotation(t *testing.T) {
+	objects := namespacedPods(5)
+	kubeClient := fake.NewSimpleClientset(objects...)
+
+	fullConfig := testCollectionConfig()
+	want, err := NewCollector(kubeClient, fullConfig).collectPodMetrics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := testCollectionConfig()
+	cfg.Mode = config.CollectionModeChunked
+	cfg.RotationLength = 3
+	collector := NewCollector(kubeClient, cfg)
+	now := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	collector.rotation.now = func() time.Time { return now }
+
+	for cycle := 1; cycle <= cfg.RotationLength; cycle++ {
+		tally, err := collector.collectPodMetrics(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		coverage := collector.Coverage()
+		complete := cycle == cfg.RotationLength
+		if coverage.RotationComplete != complete || coverage.NamespacesTotal != 5 {
+			t.Errorf("cycle %d: coverage = %+v, want rotation complete: %v", cycle, coverage, complete)
+		}
+		if complete {
+			if tally.totalPods != want.totalPods || tally.pendingPods != want.pendingPods {
+				t.Errorf("aggregate after a full rotation = %d/%d pods pending, want the full scan's %d/%d",
+					tally.pendingPods, tally.totalPods, want.pendingPods, want.totalPods)
+			}
+			if coverage.OldestSampleAge != time.Duration(cfg.RotationLength-1)*time.Minute {
+				t.Errorf("oldest sample age = %s, want the first cycle's %s", coverage.OldestSampleAge, time.Duration(cfg.RotationLength-1)*time.Minute)
+			}
+		} else if tally.totalPods >= want.totalPods {
+			t.Errorf("cycle %d: aggregate = %d pods, want a partial count below %d", cycle, tally.totalPods, want.totalPods)
+		}
+		now = now.Add(time.Minute)
+	}
+}
+
+func TestChunkedAggregateTracksChanges(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(namespacedPods(4)...)
+	cfg := testCollectionConfig()
+	cfg.Mode = config.CollectionModeChunked
+	cfg.RotationLength = 2
+	collector := NewCollector(kubeClient, cfg)
+	collect := func() podTally {
+		t.Helper()
+		tally, err := collector.collectPodMetrics(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tally
+	}
+	for i := 0; i < cfg.RotationLength; i++ {
+		collect()
+	}
+
+	// A deleted namespace drops out of the aggregate at once; new pods are seen within one rotation
+	if err := kubeClient.CoreV1().Namespaces().Delete(context.Background(), "team-3", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kubeClient.CoreV1().Pods("team-0").Create(context.Background(), pendingPod("team-0", "web-new"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var tally podTally
+	for i := 0; i < cfg.RotationLength; i++ {
+		tally = collect()
+	}
+
+	// team-0..team-2 hold 1+2+3 pods, plus the new one
+	if tally.totalPods != 7 || tally.pendingPods != 7 {
+		t.Errorf("aggregate = %d/%d pods pending, want 7/7 after the changes", tally.pendingPods, tally.totalPods)
+	}
+	if coverage := collector.Coverage(); !coverage.RotationComplete || coverage.NamespacesTotal != 3 {
+		t.Errorf("coverage = %+v, want a complete rotation over the 3 remaining namespaces", coverage)
+	}
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// churnTracker remembers the pods seen in the previous full listing to count creations and deletions
+// between cycles. UIDs are stored as 64-bit hashes to bound memory on large clusters; a collision only
+// makes one churn event go uncounted.
+type churnTracker struct {
+	mu         sync.Mutex
+	uids       map[uint64]struct{}
+	observedAt time.Time
+}
+
+// churnListing is the set of hashed pod UIDs seen by one full listing, built up page by page
+type churnListing map[uint64]struct{}
+
+// add records the pod unless its controlling owner kind is excluded
+func (l churnListing) add(pod *corev1.Pod, excludeOwnerKinds []string) {
+	if !ownedByKind(pod, excludeOwnerKinds) {
+		l[hashUID(string(pod.UID))] = struct{}{}
+	}
+}
+
+// observe compares a listing with the previous one and returns creations plus deletions per minute.
+// The first observation has nothing to compare against and returns false.
+func (t *churnTracker) observe(current churnListing, now time.Time) (int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	previous, previousAt := t.uids, t.observedAt
+	t.uids, t.observedAt = current, now
+	if previous == nil || !now.After(previousAt) {
+		return 0, false
+	}
+
+	var churn int
+	for uid := range current {
+		if _, ok := previous[uid]; !ok {
+			churn++
+		}
+	}
+	for uid := range previous {
+		if _, ok := current[uid]; !ok {
+			churn++
+		}
+	}
+
+	return int(float64(churn) / now.Sub(previousAt).Minutes()), true
+}
+
+// ownedByKind reports whether the pod's controlling owner is one of the given kinds
+func ownedByKind(pod *corev1.Pod, kinds []string) bool {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return false
+	}
+	for _, kind := range kinds {
+		if owner.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// hashUID hashes a pod UID for the churn set
+func hashUID(uid string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(uid))
+	return h.Sum64()
+}
@@ -2,11 +2,20 @@ package metrics
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"aks-health-monitor/pkg/config"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
 	"k8s.io/klog/v2"
 )
 
@@ -19,172 +28,895 @@ const (
 	NotReadyNodesPercentMetric MetricType = "not_ready_nodes_percent"
 	FailedJobsMetric           MetricType = "failed_jobs"
 	RestartCountMetric         MetricType = "restart_count"
-	CpuUsagePercentMetric      MetricType = "cpu_usage_percent"
-	MemoryUsagePercentMetric   MetricType = "memory_usage_percent"
+
+	// Percentages of nodes reporting a pressure condition as True, which usually shows before nodes go
+	// NotReady. A node counts in every condition it reports, and in not_ready_nodes_percent when also NotReady.
+	MemoryPressureNodesPercentMetric     MetricType = "memory_pressure_nodes_percent"
+	DiskPressureNodesPercentMetric       MetricType = "disk_pressure_nodes_percent"
+	PIDPressureNodesPercentMetric        MetricType = "pid_pressure_nodes_percent"
+	NetworkUnavailableNodesPercentMetric MetricType = "network_unavailable_nodes_percent"
+
+	// RestartRateMetric is the number of container restarts since the previous full pod listing, usually one
+	// poll interval. Unlike restart_count, restarts long past do not keep adding to it.
+	RestartRateMetric MetricType = "restart_rate"
+
+	// ImagePullErrorsPercentMetric is the percentage of pods whose images cannot be pulled, which points at
+	// the registry or the image reference rather than at the application
+	ImagePullErrorsPercentMetric MetricType = "image_pull_errors_percent"
+
+	// Node CPU and memory usage reported by metrics-server as a percentage of allocatable, summed over nodes
+	CpuUsagePercentMetric    MetricType = "cpu_usage_percent"
+	MemoryUsagePercentMetric MetricType = "memory_usage_percent"
+
+	// OperationErrorDetectedMetric is 1 when ARM reports a provisioning error for the operation, 0 otherwise
+	OperationErrorDetectedMetric MetricType = "operation_error_detected"
+
+	// WorkloadsBlockedByAdmissionMetric counts workloads whose pods are rejected by admission control
+	WorkloadsBlockedByAdmissionMetric MetricType = "workloads_blocked_by_admission"
+
+	// Sum of Running and Pending pod requests as a percentage of schedulable node allocatable
+	CpuRequestsSaturationPercentMetric    MetricType = "cpu_requests_saturation_percent"
+	MemoryRequestsSaturationPercentMetric MetricType = "memory_requests_saturation_percent"
+
+	// P95SchedulingLatencySecondsMetric is the 95th percentile of creation-to-scheduled time for pods created
+	// during the current operation
+	P95SchedulingLatencySecondsMetric MetricType = "p95_scheduling_latency_seconds"
+
+	// SuspectedNodeReimageLoopsMetric is the number of agent pools whose nodes keep being recreated without
+	// the pool's Ready count increasing
+	SuspectedNodeReimageLoopsMetric MetricType = "suspected_node_reimage_loops"
+
+	// ShutdownTerminatedPodsMetric is the number of Failed pods whose reason is ignorable, such as pods
+	// terminated by graceful node shutdown. It is informational and has no threshold.
+	ShutdownTerminatedPodsMetric MetricType = "shutdown_terminated_pods"
+
+	// PodChurnPerMinuteMetric is pod creations plus deletions per minute between full pod listings
+	PodChurnPerMinuteMetric MetricType = "pod_churn_per_minute"
+
+	// SuspectedStuckOperationMetric is 1 when ARM still reports the operation in progress although none of
+	// its progress signals changed for the staleness period, 0 otherwise
+	SuspectedStuckOperationMetric MetricType = "suspected_stuck_operation"
+
+	// WorkloadsLosingZoneRedundancyMetric counts zone-spread workloads whose ready replicas are concentrated
+	// beyond their allowed skew or absent from a zone
+	WorkloadsLosingZoneRedundancyMetric MetricType = "workloads_losing_zone_redundancy"
+
+	// Crashing and pending pods among those created since the operation started, to tell failures the
+	// operation causes apart from pre-existing ones
+	NewPodsCrashingPercentMetric MetricType = "new_pods_crashing_percent"
+	NewPodsPendingPercentMetric  MetricType = "new_pods_pending_percent"
+
+	// Highest CPU and memory usage of a single node as a percentage of its allocatable, reported by
+	// metrics-server, so one saturated node stands out when the cluster-wide usage looks fine
+	MaxNodeCpuUsagePercentMetric    MetricType = "max_node_cpu_usage_percent"
+	MaxNodeMemoryUsagePercentMetric MetricType = "max_node_memory_usage_percent"
+
+	// DeprecationWarningsObservedMetric is the number of distinct warnings, such as deprecated API use, the
+	// API server returned to the controller since the previous collection. It is informational.
+	DeprecationWarningsObservedMetric MetricType = "deprecation_warnings_observed"
+
+	// FlappingPodsMetric counts pods whose Ready condition changed at least collection.flapping.minTransitions
+	// times within collection.flapping.window, which point-in-time counts miss
+	FlappingPodsMetric MetricType = "flapping_pods"
+
+	// ConfigReferenceFailuresMetric counts pods that cannot start because a ConfigMap or Secret they
+	// reference is missing, typically one a chart upgrade renamed or removed
+	ConfigReferenceFailuresMetric MetricType = "config_reference_failures"
+
+	// OOMKilledPodsMetric counts pods with a container OOM-killed within collection.oomKillWindow, which
+	// shows memory pressure before node usage does
+	OOMKilledPodsMetric MetricType = "oom_killed_pods"
+
+	// ExcludedCordonedNodesMetric is the number of nodes left out of the node metrics because they are
+	// cordoned for maintenance, with collection.excludeCordonedNodes. It is informational.
+	ExcludedCordonedNodesMetric MetricType = "excluded_cordoned_nodes"
+
+	// DaemonSetNotReadyPercentMetric is the percentage of daemon pods the selected DaemonSets should have
+	// scheduled that are not ready, such as kube-proxy or the CNI missing on new nodes
+	DaemonSetNotReadyPercentMetric MetricType = "daemonset_not_ready_percent"
 )
 
 // MetricValue represents a metric with its value
 type MetricValue struct {
-	Type  MetricType
-	Value int
+	Type  MetricType `json:"type"`
+	Value int        `json:"value"`
+
+	// Offenders optionally identifies the objects contributing to the value
+	Offenders []string `json:"offenders,omitempty"`
+
+	// Fraction carries the raw count and total of percentage metrics, so 8% can be told apart as 4/50 or 400/5000
+	Fraction *Fraction `json:"fraction,omitempty"`
+
+	// Window is the time a count since the previous collection covers, such as "30s"
+	Window string `json:"window,omitempty"`
+}
+
+// Fraction is the numerator and denominator a percentage metric was computed from
+type Fraction struct {
+	Numerator   int64 `json:"numerator"`
+	Denominator int64 `json:"denominator"`
+}
+
+// percentMetric builds a percentage metric from its numerator and denominator
+func percentMetric(metricType MetricType, numerator, denominator int64) MetricValue {
+	return MetricValue{
+		Type:     metricType,
+		Value:    percentOf(numerator, denominator),
+		Fraction: &Fraction{Numerator: numerator, Denominator: denominator},
+	}
+}
+
+// IsInformational reports whether the metric type is reported without a threshold
+func (t MetricType) IsInformational() bool {
+	return t == ShutdownTerminatedPodsMetric || t == DeprecationWarningsObservedMetric || t == ExcludedCordonedNodesMetric
+}
+
+// IsPercent reports whether the metric type is a percentage
+func (t MetricType) IsPercent() bool {
+	return strings.HasSuffix(string(t), "_percent")
+}
+
+// FormatValue renders a value or threshold of this metric type, with a percent sign for percentages
+func (t MetricType) FormatValue(value int) string {
+	if t.IsPercent() {
+		return fmt.Sprintf("%d%%", value)
+	}
+	return strconv.Itoa(value)
+}
+
+// String renders the value for messages, followed by the count and total for percentages, e.g. "12% (48/400)"
+func (m MetricValue) String() string {
+	formatted := m.Type.FormatValue(m.Value)
+	if m.Fraction != nil {
+		formatted = fmt.Sprintf("%s (%d/%d)", formatted, m.Fraction.Numerator, m.Fraction.Denominator)
+	}
+	if m.Window != "" {
+		formatted = fmt.Sprintf("%s in %s", formatted, m.Window)
+	}
+	return formatted
 }
 
 // Collector collects various Kubernetes metrics
 type Collector struct {
 	kubeClient kubernetes.Interface
+	metadata   metadata.Interface
+	config     config.CollectionConfig
+	rotation   *namespaceRotation
+
+	apiCalls  *APICallCounter
+	apis      apiAvailability
+	warnings  *WarningRecorder
+	durations DurationObserver
+
+	namespaces namespaceFilter
+	churn      churnTracker
+	restarts   restartTracker
+	reimage    reimageTracker
+	jobs       jobCache
+
+	// informers is set when collection.source is informer
+	informers *informerCache
+
+	mu              sync.Mutex
+	blocks          config.CollectorsConfig
+	runs            map[string]collectorRun
+	operationStart  time.Time
+	fromCache       bool
+	skipped         []SkippedCollector
+	nodeFingerprint string
+	apiWarnings     []APIWarning
 }
 
 // NewCollector creates a new metrics collector
-func NewCollector(kubeClient kubernetes.Interface) *Collector {
-	return &Collector{
+func NewCollector(kubeClient kubernetes.Interface, cfg config.CollectionConfig) *Collector {
+	c := &Collector{
 		kubeClient: kubeClient,
+		config:     cfg,
+		rotation:   newNamespaceRotation(cfg.RotationLength),
+	}
+	if cfg.Source == config.CollectionSourceInformer {
+		c.informers = newInformerCache(kubeClient, cfg)
 	}
+	return c
 }
 
-// CollectMetrics collects all configured metrics
+// callContext bounds a single Kubernetes API request by the configured per-call timeout
+func (c *Collector) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.config.CallTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.config.CallTimeout)
+}
+
+// SetOperationStart sets the start of the operation being monitored. Only pods created at or after it
+// contribute to scheduling latency and the new pod metrics; the zero time disables both.
+func (c *Collector) SetOperationStart(start time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.operationStart = start
+}
+
+// operationStarted returns the start of the operation being monitored
+func (c *Collector) operationStarted() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.operationStart
+}
+
+// CollectMetrics collects all configured metrics. Collectors run in priority order within the
+// configured budget; those skipped are reported through Coverage. When collectors whose failure policy is
+// fail failed, the metrics of the others are returned with a *CollectionError naming them.
 func (c *Collector) CollectMetrics(ctx context.Context) ([]MetricValue, error) {
-	var metrics []MetricValue
+	return c.CollectMetricsStreaming(ctx, nil)
+}
 
-	// Collect pod-related metrics
-	podMetrics, err := c.collectPodMetrics(ctx)
-	if err != nil {
-		klog.Errorf("Failed to collect pod metrics: %v", err)
+// CollectMetricsStreaming collects metrics like CollectMetrics, and also passes each collector's metrics
+// to onCollected as soon as that collector finishes, before the next one runs. onCollected is called on
+// the collecting goroutine and must not block; nil disables it.
+func (c *Collector) CollectMetricsStreaming(ctx context.Context, onCollected func(collector string, metrics []MetricValue)) ([]MetricValue, error) {
+	started := time.Now()
+	budget := newCycleBudget(c.config.Budget.Timeout, c.config.Budget.MaxAPICalls, c.apiCalls)
+
+	// The whole cycle reads either the informer caches or the API server, never a mix
+	fromCache := c.waitForCacheSync(ctx)
+	c.mu.Lock()
+	c.fromCache = fromCache
+	c.mu.Unlock()
+
+	if err := c.refreshNamespaces(ctx); err != nil {
 		return nil, err
 	}
-	metrics = append(metrics, podMetrics...)
 
-	// Collect node-related metrics
-	nodeMetrics, err := c.collectNodeMetrics(ctx)
-	if err != nil {
-		klog.Errorf("Failed to collect node metrics: %v", err)
-		return nil, err
+	collectors := c.collectors()
+	blocks := c.collectorBlocks()
+	for i := range collectors {
+		block := blocks.Block(collectors[i].name)
+		collectors[i].disabled = c.disabledReason(collectors[i].name)
+		if collectors[i].disabled == "" && !block.Enabled {
+			collectors[i].disabled = collectorDisabledByConfig
+		}
+		collectors[i].timeout = block.Timeout
+		collectors[i].skipOnFailure = block.SkipsOnFailure(collectors[i].name)
 	}
-	metrics = append(metrics, nodeMetrics...)
 
-	// Collect job-related metrics
-	jobMetrics, err := c.collectJobMetrics(ctx)
-	if err != nil {
-		klog.Errorf("Failed to collect job metrics: %v", err)
+	metrics, skipped, err := runCollectors(ctx, collectors, budget, onCollected, c.recordRun)
+	if c.durations != nil {
+		c.durations.ObserveCollectionDuration(time.Since(started))
+	}
+	var collectionErr *CollectionError
+	if err != nil && !errors.As(err, &collectionErr) {
 		return nil, err
 	}
-	metrics = append(metrics, jobMetrics...)
 
-	return metrics, nil
+	c.mu.Lock()
+	c.skipped = skipped
+	c.mu.Unlock()
+
+	if c.warnings != nil {
+		warnings, dropped := c.warnings.drain()
+		c.mu.Lock()
+		c.apiWarnings = warnings
+		c.mu.Unlock()
+		metrics = append(metrics, warningsMetric(warnings, dropped))
+	}
+
+	return metrics, err
+}
+
+// collectors returns the registry of collectors for this configuration
+func (c *Collector) collectors() []registeredCollector {
+	collectors := []registeredCollector{
+		{name: "nodes", priority: PriorityCritical, collect: func(ctx context.Context, state *collectionState) ([]MetricValue, error) {
+			nodeMetrics, capacity, err := c.collectNodeMetrics(ctx)
+			if err != nil {
+				return nil, err
+			}
+			state.capacity = &capacity
+			return nodeMetrics, nil
+		}},
+		{name: "pods", priority: PriorityNormal, collect: func(ctx context.Context, state *collectionState) ([]MetricValue, error) {
+			pods, err := c.collectPodMetrics(ctx)
+			if err != nil {
+				return nil, err
+			}
+			state.pods = &pods
+
+			metrics := pods.metrics()
+			if c.config.ReportRestartCount {
+				metrics = append(metrics, MetricValue{Type: RestartCountMetric, Value: pods.totalRestarts})
+			}
+			if c.config.ReportShutdownTerminatedPods {
+				metrics = append(metrics, MetricValue{Type: ShutdownTerminatedPodsMetric, Value: pods.shutdownTerminatedPods})
+			}
+			metrics = append(metrics, oomKilledMetric(pods, c.config.OOMKillWindow))
+			metrics = append(metrics, schedulingLatencyMetrics(pods, c.config.SchedulingLatencyMinSamples)...)
+			if c.config.NewPodMetrics {
+				metrics = append(metrics, newPodMetrics(pods, c.config.NewPodsMinSamples)...)
+			}
+
+			// Derive request saturation from pod requests and schedulable node allocatable
+			if state.capacity != nil {
+				metrics = append(metrics, saturationMetrics(pods, *state.capacity)...)
+			}
+			return metrics, nil
+		}},
+		{name: "jobs", priority: PriorityNormal, requires: []apiResource{{"batch/v1", "jobs"}}, collect: func(ctx context.Context, state *collectionState) ([]MetricValue, error) {
+			return c.collectJobMetrics(ctx)
+		}},
+		{name: "admission", priority: PriorityNormal, requires: []apiResource{{"apps/v1", "deployments"}}, collect: func(ctx context.Context, state *collectionState) ([]MetricValue, error) {
+			return c.collectAdmissionMetrics(ctx)
+		}},
+		{name: "configReferences", priority: PriorityNormal, after: "pods", collect: func(ctx context.Context, state *collectionState) ([]MetricValue, error) {
+			return c.collectConfigReferenceMetrics(ctx, state.pods)
+		}},
+		{name: "daemonSets", priority: PriorityNormal, requires: []apiResource{{"apps/v1", "daemonsets"}}, collect: func(ctx context.Context, state *collectionState) ([]MetricValue, error) {
+			return c.collectDaemonSetMetrics(ctx)
+		}},
+	}
+
+	collectors = append(collectors, registeredCollector{name: "usage", priority: PriorityOptional, requires: []apiResource{{"metrics.k8s.io/v1beta1", "nodes"}}, collect: func(ctx context.Context, state *collectionState) ([]MetricValue, error) {
+		return c.collectUsageMetrics(ctx, state.capacity)
+	}})
+
+	if c.informers != nil {
+		collectors = append(collectors, registeredCollector{name: "flapping", priority: PriorityNormal, collect: func(ctx context.Context, state *collectionState) ([]MetricValue, error) {
+			return c.collectFlappingMetrics(ctx)
+		}})
+	}
+
+	if c.config.ZoneRedundancy {
+		collectors = append(collectors, registeredCollector{name: "zoneRedundancy", priority: PriorityOptional, requires: []apiResource{{"apps/v1", "deployments"}, {"apps/v1", "statefulsets"}}, collect: func(ctx context.Context, state *collectionState) ([]MetricValue, error) {
+			return c.collectZoneRedundancyMetrics(ctx)
+		}})
+	}
+
+	return collectors
+}
+
+// CountAPICalls enables the API-call budget using a counter installed on the Kubernetes client transport
+func (c *Collector) CountAPICalls(counter *APICallCounter) {
+	c.apiCalls = counter
 }
 
-// collectPodMetrics collects pod-related metrics
-func (c *Collector) collectPodMetrics(ctx context.Context) ([]MetricValue, error) {
-	pods, err := c.kubeClient.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+// ObserveDurations reports how long each collection and each collector run took to observer
+func (c *Collector) ObserveDurations(observer DurationObserver) {
+	c.durations = observer
+}
+
+// RecordWarnings enables the deprecation_warnings_observed metric using a recorder installed as the
+// Kubernetes client's warning handler
+func (c *Collector) RecordWarnings(recorder *WarningRecorder) {
+	c.warnings = recorder
+}
+
+// APIWarnings returns the distinct API server warnings reported by the most recent collection
+func (c *Collector) APIWarnings() []APIWarning {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]APIWarning(nil), c.apiWarnings...)
+}
+
+// collectPodMetrics collects pod-related counts
+func (c *Collector) collectPodMetrics(ctx context.Context) (podTally, error) {
+	if c.config.Mode == config.CollectionModeChunked {
+		return c.collectChunkedPodMetrics(ctx)
+	}
+
+	// Pages are tallied as they arrive, so memory stays bounded by the page size rather than the cluster.
+	// Churn and the restart rate need consecutive listings of the same pods, so they are only measured in
+	// full mode.
+	var tally podTally
+	listing := make(churnListing)
+	restarts := make(restartListing)
+	err := c.listPods(ctx, "", func(pods []corev1.Pod) {
+		tally.add(c.tallyPods(pods))
+		for i := range pods {
+			if c.monitorsNamespace(pods[i].Namespace) {
+				listing.add(&pods[i], c.config.ChurnExcludeOwnerKinds)
+				restarts.add(&pods[i])
+			}
+		}
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list pods: %w", err)
+		return podTally{}, err
 	}
 
-	var crashingPods, pendingPods, totalRestarts int
-	totalPods := len(pods.Items)
+	now := time.Now()
+	tally.churnPerMinute, tally.churnObserved = c.churn.observe(listing, now)
+	tally.restartRate, tally.restartWindow, tally.restartRateObserved = c.restarts.observe(restarts, now)
+	if tally.restartRateObserved {
+		klog.V(2).Infof("Container restarts: %d in the last %s", tally.restartRate, tally.restartWindow.Round(time.Second))
+	}
+	return tally, nil
+}
+
+// succeededPodsSelector leaves pods that ran to completion out of pod listings
+const succeededPodsSelector = "status.phase!=" + string(corev1.PodSucceeded)
+
+// listPods lists the pods of a namespace, or of all namespaces when it is empty, in pages of the
+// configured size, passing each page to visit before requesting the next
+func (c *Collector) listPods(ctx context.Context, namespace string, visit func(pods []corev1.Pod)) error {
+	if c.readsCache() {
+		return c.cachedPods(namespace, visit)
+	}
+
+	options := metav1.ListOptions{Limit: int64(c.config.PodPageSize)}
+	if c.config.ExcludeSucceededPods {
+		options.FieldSelector = succeededPodsSelector
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		callCtx, cancel := c.callContext(ctx)
+		page, err := c.kubeClient.CoreV1().Pods(namespace).List(callCtx, options)
+		cancel()
+		if err != nil {
+			if namespace != "" {
+				return fmt.Errorf("failed to list pods in namespace %s: %w", namespace, err)
+			}
+			return fmt.Errorf("failed to list pods: %w", err)
+		}
+		visit(page.Items)
+
+		if page.Continue == "" {
+			return nil
+		}
+		options.Continue = page.Continue
+	}
+}
+
+// podTally holds raw pod counts from which pod metrics are derived
+type podTally struct {
+	totalPods     int
+	crashingPods  int
+	imagePullPods int
+	pendingPods   int
+	totalRestarts int
+
+	// Failed pods with an ignorable reason, which do not count as crashing
+	shutdownTerminatedPods int
+
+	// Resource requests of Running and Pending pods
+	cpuRequestMillis   int64
+	memoryRequestBytes int64
+
+	// Scheduling latency of pods created since the operation started
+	schedulingLatency latencyHistogram
+
+	// Counts restricted to monitored pods created since the operation started
+	newPods         int
+	newCrashingPods int
+	newPendingPods  int
+
+	// Pod creations plus deletions per minute; only observed from the second full listing on
+	churnPerMinute int
+	churnObserved  bool
+
+	// Container restarts since the previous full listing and the time since it; only observed from the
+	// second full listing on
+	restartRate         int
+	restartWindow       time.Duration
+	restartRateObserved bool
+
+	// Monitored pods waiting with CreateContainerConfigError, keyed namespace/name, with what is missing,
+	// and the keys of monitored Pending pods, against which FailedMount events are checked
+	configReferenceFailures map[string]string
+	pendingPodKeys          map[string]struct{}
+
+	// Monitored pods, as namespace/name, with a container OOM-killed within the OOM kill window
+	oomKilledPods []string
+}
+
+// tallyPods counts crashing, pending and restarting pods in monitored namespaces. Requests of every pod
+// count toward saturation, since unmonitored pods still occupy node capacity.
+func (c *Collector) tallyPods(pods []corev1.Pod) podTally {
+	var tally podTally
+	operationStart := c.operationStarted()
+	oomKilledSince := time.Now().Add(-c.config.OOMKillWindow)
+
+	for _, pod := range pods {
+		// Sum requests of pods that hold or are waiting for capacity
+		if pod.Status.Phase == corev1.PodRunning || pod.Status.Phase == corev1.PodPending {
+			cpu, memory := podRequests(pod)
+			tally.cpuRequestMillis += cpu
+			tally.memoryRequestBytes += memory
+		}
+
+		if !c.monitorsNamespace(pod.Namespace) {
+			continue
+		}
+		tally.totalPods++
 
-	for _, pod := range pods.Items {
 		// Count crashing pods (CrashLoopBackOff, Error, etc.)
-		if c.isPodCrashing(pod) {
-			crashingPods++
+		crashing := false
+		if c.isShutdownTerminated(pod) {
+			tally.shutdownTerminatedPods++
+		} else if c.isPodCrashing(pod) {
+			tally.crashingPods++
+			crashing = true
+		} else if isPodFailingImagePull(pod) {
+			tally.imagePullPods++
 		}
 
 		// Count pending pods
-		if pod.Status.Phase == corev1.PodPending {
-			pendingPods++
+		pending := pod.Status.Phase == corev1.PodPending
+		if pending {
+			tally.pendingPods++
+			if tally.pendingPodKeys == nil {
+				tally.pendingPodKeys = make(map[string]struct{})
+			}
+			tally.pendingPodKeys[pod.Namespace+"/"+pod.Name] = struct{}{}
+		}
+		if failure, ok := podConfigReferenceFailure(pod); ok {
+			if tally.configReferenceFailures == nil {
+				tally.configReferenceFailures = make(map[string]string)
+			}
+			tally.configReferenceFailures[pod.Namespace+"/"+pod.Name] = failure
+		}
+		if podOOMKilledSince(pod, oomKilledSince) {
+			tally.oomKilledPods = append(tally.oomKilledPods, pod.Namespace+"/"+pod.Name)
+		}
+
+		if !operationStart.IsZero() && !pod.CreationTimestamp.Time.Before(operationStart) {
+			tally.newPods++
+			if crashing {
+				tally.newCrashingPods++
+			}
+			if pending {
+				tally.newPendingPods++
+			}
 		}
 
 		// Count restart counts
 		for _, containerStatus := range pod.Status.ContainerStatuses {
-			totalRestarts += int(containerStatus.RestartCount)
+			tally.totalRestarts += int(containerStatus.RestartCount)
+		}
+
+		tally.schedulingLatency.observeSchedulingLatency(pod, operationStart)
+	}
+
+	return tally
+}
+
+// add merges another tally into this one
+func (t *podTally) add(other podTally) {
+	t.totalPods += other.totalPods
+	t.crashingPods += other.crashingPods
+	t.imagePullPods += other.imagePullPods
+	t.pendingPods += other.pendingPods
+	t.shutdownTerminatedPods += other.shutdownTerminatedPods
+	t.totalRestarts += other.totalRestarts
+	t.cpuRequestMillis += other.cpuRequestMillis
+	t.memoryRequestBytes += other.memoryRequestBytes
+	t.schedulingLatency.add(other.schedulingLatency)
+	t.newPods += other.newPods
+	t.newCrashingPods += other.newCrashingPods
+	t.newPendingPods += other.newPendingPods
+	for key, failure := range other.configReferenceFailures {
+		if t.configReferenceFailures == nil {
+			t.configReferenceFailures = make(map[string]string)
+		}
+		t.configReferenceFailures[key] = failure
+	}
+	for key := range other.pendingPodKeys {
+		if t.pendingPodKeys == nil {
+			t.pendingPodKeys = make(map[string]struct{})
 		}
+		t.pendingPodKeys[key] = struct{}{}
+	}
+	t.oomKilledPods = append(t.oomKilledPods, other.oomKilledPods...)
+}
+
+// metrics converts the tally into metric values
+func (t podTally) metrics() []MetricValue {
+	metrics := []MetricValue{
+		percentMetric(CrashingPodsPercentMetric, int64(t.crashingPods), int64(t.totalPods)),
+		percentMetric(ImagePullErrorsPercentMetric, int64(t.imagePullPods), int64(t.totalPods)),
+		percentMetric(PendingPodsPercentMetric, int64(t.pendingPods), int64(t.totalPods)),
+	}
+	if t.restartRateObserved {
+		metrics = append(metrics, MetricValue{Type: RestartRateMetric, Value: t.restartRate, Window: t.restartWindow.Round(time.Second).String()})
 	}
+	if t.churnObserved {
+		metrics = append(metrics, MetricValue{Type: PodChurnPerMinuteMetric, Value: t.churnPerMinute})
+	}
+	return metrics
+}
 
-	// Calculate percentages (avoid division by zero)
-	crashingPodsPercent := 0
-	pendingPodsPercent := 0
-	if totalPods > 0 {
-		crashingPodsPercent = (crashingPods * 100) / totalPods
-		pendingPodsPercent = (pendingPods * 100) / totalPods
+// newPodMetrics reports crashing and pending percentages over pods created since the operation started, or
+// nothing when fewer than minSamples such pods exist, so a single early pod cannot make up 100%
+func newPodMetrics(pods podTally, minSamples int) []MetricValue {
+	if pods.newPods == 0 || pods.newPods < minSamples {
+		return nil
 	}
 
 	return []MetricValue{
-		{Type: CrashingPodsPercentMetric, Value: crashingPodsPercent},
-		{Type: PendingPodsPercentMetric, Value: pendingPodsPercent},
-		{Type: RestartCountMetric, Value: totalRestarts},
-	}, nil
+		percentMetric(NewPodsCrashingPercentMetric, int64(pods.newCrashingPods), int64(pods.newPods)),
+		percentMetric(NewPodsPendingPercentMetric, int64(pods.newPendingPods), int64(pods.newPods)),
+	}
 }
 
-// collectNodeMetrics collects node-related metrics
-func (c *Collector) collectNodeMetrics(ctx context.Context) ([]MetricValue, error) {
-	nodes, err := c.kubeClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+// oomKilledMetric reports the pods with a container OOM-killed within the window, naming them as offenders
+func oomKilledMetric(pods podTally, window time.Duration) MetricValue {
+	offenders := append([]string(nil), pods.oomKilledPods...)
+	sort.Strings(offenders)
+	return MetricValue{Type: OOMKilledPodsMetric, Value: len(offenders), Offenders: offenders, Window: window.String()}
+}
+
+// podOOMKilledSince reports whether a container or init container of a pod was OOM-killed at or after
+// since, in its current or its last termination. A termination without a finish time is not counted, since
+// it could be arbitrarily old.
+func podOOMKilledSince(pod corev1.Pod, since time.Time) bool {
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			for _, terminated := range []*corev1.ContainerStateTerminated{status.State.Terminated, status.LastTerminationState.Terminated} {
+				if terminated != nil && terminated.Reason == "OOMKilled" && !terminated.FinishedAt.IsZero() && !terminated.FinishedAt.Time.Before(since) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// isShutdownTerminated checks if a pod is in the Failed phase with an ignorable reason. Graceful node
+// shutdown leaves such pods behind until they are garbage collected; they say nothing about workload health.
+func (c *Collector) isShutdownTerminated(pod corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodFailed {
+		return false
+	}
+	for _, reason := range c.config.IgnorableTerminatedReasons {
+		if pod.Status.Reason == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeCapacity is the allocatable capacity of schedulable nodes
+type nodeCapacity struct {
+	cpuAllocatableMillis   int64
+	memoryAllocatableBytes int64
+
+	// nodes holds the allocatable of every node by name, schedulable or not, for usage metrics
+	nodes map[string]nodeResources
+}
+
+// nodeResources is an amount of CPU in millicores and memory in bytes
+type nodeResources struct {
+	cpuMillis   int64
+	memoryBytes int64
+}
+
+// nodePressureMetrics are the node conditions reported as metrics when True, in reporting order
+var nodePressureMetrics = []struct {
+	condition corev1.NodeConditionType
+	metric    MetricType
+}{
+	{corev1.NodeMemoryPressure, MemoryPressureNodesPercentMetric},
+	{corev1.NodeDiskPressure, DiskPressureNodesPercentMetric},
+	{corev1.NodePIDPressure, PIDPressureNodesPercentMetric},
+	{corev1.NodeNetworkUnavailable, NetworkUnavailableNodesPercentMetric},
+}
+
+// collectNodeMetrics collects node-related metrics and the allocatable capacity of schedulable nodes
+func (c *Collector) collectNodeMetrics(ctx context.Context) ([]MetricValue, nodeCapacity, error) {
+	var capacity nodeCapacity
+
+	nodes, err := c.listNodes(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list nodes: %w", err)
+		return nil, capacity, err
 	}
 
-	var notReadyNodes int
-	totalNodes := len(nodes.Items)
+	var notReadyNodes, totalNodes int
+	var cordonedNodes []string
+	pressuredNodes := make([]int, len(nodePressureMetrics))
+	capacity.nodes = make(map[string]nodeResources, len(nodes))
+
+	for _, node := range nodes {
+		if c.config.ExcludeCordonedNodes && isNodeCordoned(node, c.config.CordonTaints) {
+			cordonedNodes = append(cordonedNodes, node.Name)
+		} else {
+			totalNodes++
+			if !c.isNodeReady(node) {
+				notReadyNodes++
+			}
+			for i, pressure := range nodePressureMetrics {
+				if nodeConditionTrue(node, pressure.condition) {
+					pressuredNodes[i]++
+				}
+			}
+		}
 
-	for _, node := range nodes.Items {
-		if !c.isNodeReady(node) {
-			notReadyNodes++
+		capacity.nodes[node.Name] = nodeResources{
+			cpuMillis:   node.Status.Allocatable.Cpu().MilliValue(),
+			memoryBytes: node.Status.Allocatable.Memory().Value(),
+		}
+
+		if !node.Spec.Unschedulable {
+			capacity.cpuAllocatableMillis += node.Status.Allocatable.Cpu().MilliValue()
+			capacity.memoryAllocatableBytes += node.Status.Allocatable.Memory().Value()
 		}
 	}
 
-	// Calculate percentage (avoid division by zero)
-	notReadyNodesPercent := 0
-	if totalNodes > 0 {
-		notReadyNodesPercent = (notReadyNodes * 100) / totalNodes
+	fingerprint := nodeProgressFingerprint(nodes, c.isNodeReady)
+	c.mu.Lock()
+	c.nodeFingerprint = fingerprint
+	c.mu.Unlock()
+
+	suspectedLoops := c.reimage.observe(nodes, c.isNodeReady, time.Now(), c.config.ReimageLoop.Window, c.config.ReimageLoop.MaxCreations)
+
+	values := []MetricValue{
+		percentMetric(NotReadyNodesPercentMetric, int64(notReadyNodes), int64(totalNodes)),
+	}
+	for i, pressure := range nodePressureMetrics {
+		values = append(values, percentMetric(pressure.metric, int64(pressuredNodes[i]), int64(totalNodes)))
 	}
+	values = append(values, MetricValue{Type: SuspectedNodeReimageLoopsMetric, Value: len(suspectedLoops), Offenders: suspectedLoops})
+	if c.config.ExcludeCordonedNodes {
+		values = append(values, MetricValue{Type: ExcludedCordonedNodesMetric, Value: len(cordonedNodes), Offenders: cordonedNodes})
+	}
+	return values, capacity, nil
+}
 
-	return []MetricValue{
-		{Type: NotReadyNodesPercentMetric, Value: notReadyNodesPercent},
-	}, nil
+// isNodeCordoned reports whether a node is cordoned or carries one of the maintenance taints
+func isNodeCordoned(node corev1.Node, taints []string) bool {
+	if node.Spec.Unschedulable {
+		return true
+	}
+	for _, taint := range node.Spec.Taints {
+		for _, key := range taints {
+			if taint.Key == key {
+				return true
+			}
+		}
+	}
+	return false
 }
 
-// collectJobMetrics collects job-related metrics
-func (c *Collector) collectJobMetrics(ctx context.Context) ([]MetricValue, error) {
-	jobs, err := c.kubeClient.BatchV1().Jobs("").List(ctx, metav1.ListOptions{})
+// listNodes returns every node, from the informer cache when the cycle reads it. Nodes are listed in
+// pages, so no single request has to return the whole cluster.
+func (c *Collector) listNodes(ctx context.Context) ([]corev1.Node, error) {
+	if c.readsCache() {
+		return c.cachedNodes()
+	}
+
+	var nodes []corev1.Node
+	options := metav1.ListOptions{Limit: int64(c.config.NodePageSize)}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		callCtx, cancel := c.callContext(ctx)
+		page, err := c.kubeClient.CoreV1().Nodes().List(callCtx, options)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list nodes: %w", err)
+		}
+		nodes = append(nodes, page.Items...)
+
+		if page.Continue == "" {
+			return nodes, nil
+		}
+		options.Continue = page.Continue
+	}
+}
+
+// readsCache reports whether the current cycle reads pods, nodes and jobs from the informer caches
+func (c *Collector) readsCache() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.fromCache
+}
+
+// NodeSummary summarizes node readiness, schedulability and kubelet versions
+type NodeSummary struct {
+	TotalNodes       int
+	ReadyNodes       int
+	SchedulableNodes int // Ready and not cordoned
+	KubeletVersions  map[string]string
+}
+
+// CollectNodeSummary lists nodes and summarizes their capacity-related state
+func (c *Collector) CollectNodeSummary(ctx context.Context) (*NodeSummary, error) {
+	nodes, err := c.listNodes(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list jobs: %w", err)
+		return nil, err
 	}
 
-	var failedJobs int
+	summary := &NodeSummary{
+		TotalNodes:      len(nodes),
+		KubeletVersions: make(map[string]string, len(nodes)),
+	}
 
-	for _, job := range jobs.Items {
-		if job.Status.Failed > 0 {
-			failedJobs++
+	for _, node := range nodes {
+		summary.KubeletVersions[node.Name] = node.Status.NodeInfo.KubeletVersion
+		if !c.isNodeReady(node) {
+			continue
+		}
+		summary.ReadyNodes++
+		if !node.Spec.Unschedulable {
+			summary.SchedulableNodes++
 		}
 	}
 
-	return []MetricValue{
-		{Type: FailedJobsMetric, Value: failedJobs},
-	}, nil
+	return summary, nil
 }
 
-// isPodCrashing checks if a pod is in a crashing state
+// isPodCrashing checks if a pod is in a crashing state. Pods that ran to completion are not, and neither
+// are Failed Job pods or Job pods whose containers exited and are not restarted: failed_jobs reports those,
+// and they would otherwise count as crashing for as long as the Job keeps them.
 func (c *Collector) isPodCrashing(pod corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodSucceeded {
+		return false
+	}
+	jobPod := isJobPod(pod)
+
 	// Check if pod is in Error or Failed phase
 	if pod.Status.Phase == corev1.PodFailed {
-		return true
+		return !jobPod
 	}
 
 	// Check container statuses for crash loops
 	for _, containerStatus := range pod.Status.ContainerStatuses {
 		if containerStatus.State.Waiting != nil {
-			reason := containerStatus.State.Waiting.Reason
-			if reason == "CrashLoopBackOff" || reason == "ImagePullBackOff" ||
-				reason == "ErrImagePull" || reason == "CreateContainerError" {
+			switch containerStatus.State.Waiting.Reason {
+			case "CrashLoopBackOff":
+				// A crash loop is judged by the crash that put it in back-off
+				if last := containerStatus.LastTerminationState.Terminated; last != nil && !c.crashedWithinWindow(last) {
+					continue
+				}
+				return true
+			case "CreateContainerError":
 				return true
 			}
 		}
 
-		if containerStatus.State.Terminated != nil {
-			if containerStatus.State.Terminated.ExitCode != 0 {
+		terminated := containerStatus.State.Terminated
+		if terminated == nil || terminated.ExitCode == 0 {
+			continue
+		}
+		if jobPod && pod.Spec.RestartPolicy != corev1.RestartPolicyAlways {
+			continue
+		}
+		if !c.crashedWithinWindow(terminated) {
+			continue
+		}
+		return true
+	}
+
+	return false
+}
+
+// crashedWithinWindow reports whether a container termination is recent enough to count as a crash: always
+// without a crash recency window, and for a termination without a finish time, since its age is unknown
+func (c *Collector) crashedWithinWindow(terminated *corev1.ContainerStateTerminated) bool {
+	return c.config.CrashRecencyWindow <= 0 || terminated.FinishedAt.IsZero() ||
+		time.Since(terminated.FinishedAt.Time) <= c.config.CrashRecencyWindow
+}
+
+// isJobPod reports whether a pod is controlled by a Job
+func isJobPod(pod corev1.Pod) bool {
+	owner := metav1.GetControllerOf(&pod)
+	return owner != nil && owner.Kind == "Job"
+}
+
+// isPodFailingImagePull checks if a container or init container of a pod is waiting on an image it
+// cannot pull
+func isPodFailingImagePull(pod corev1.Pod) bool {
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, containerStatus := range statuses {
+			if containerStatus.State.Waiting == nil {
+				continue
+			}
+			switch containerStatus.State.Waiting.Reason {
+			case "ImagePullBackOff", "ErrImagePull", "InvalidImageName":
 				return true
 			}
 		}
 	}
-
 	return false
 }
 
@@ -197,3 +929,13 @@ func (c *Collector) isNodeReady(node corev1.Node) bool {
 	}
 	return false
 }
+
+// nodeConditionTrue reports whether the node reports the condition with status True
+func nodeConditionTrue(node corev1.Node, conditionType corev1.NodeConditionType) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,1144 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+
+	"go.uber.org/goleak"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// testCollectionConfig returns the collection defaults of a configuration loaded without a file
+func testCollectionConfig() config.CollectionConfig {
+	return config.CollectionConfig{
+		Mode:                        config.CollectionModeFull,
+		Source:                      config.CollectionSourceList,
+		RotationLength:              4,
+		AdmissionEventWindow:        10 * time.Minute,
+		OOMKillWindow:               15 * time.Minute,
+		FailedJobWindow:             time.Hour,
+		SchedulingLatencyMinSamples: 20,
+		NewPodsMinSamples:           10,
+		CallTimeout:                 30 * time.Second,
+		PodPageSize:                 500,
+		NodePageSize:                500,
+		JobPageSize:                 500,
+		Namespaces:                  config.NamespaceSelection{Mode: config.NamespaceModeAll, LabelCacheTTL: time.Minute},
+		IgnorableTerminatedReasons:  []string{"Shutdown", "NodeShutdown"},
+		CordonTaints:                []string{"node.kubernetes.io/unschedulable"},
+	}
+}
+
+// readyNode returns a node whose Ready condition has the given status
+func readyNode(name string, ready corev1.ConditionStatus) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}}},
+	}
+}
+
+// pendingPod returns a pod in the Pending phase
+func pendingPod(namespace, name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, CreationTimestamp: metav1.Now()},
+		Status:     corev1.PodStatus{Phase: corev1.PodPending},
+	}
+}
+
+// findMetric returns the metric of the given type, and whether it was collected
+func findMetric(metrics []MetricValue, metricType MetricType) (MetricValue, bool) {
+	for _, metric := range metrics {
+		if metric.Type == metricType {
+			return metric, true
+		}
+	}
+	return MetricValue{}, false
+}
+
+func TestCollectMetricsReportsFailedListAlongsideOtherMetrics(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(readyNode("node-0", corev1.ConditionTrue), pendingPod("default", "web"))
+	kubeClient.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewServiceUnavailable("etcd leader changed")
+	})
+	collector := NewCollector(kubeClient, testCollectionConfig())
+
+	metrics, err := collector.CollectMetrics(context.Background())
+
+	var collectionErr *CollectionError
+	if !errors.As(err, &collectionErr) {
+		t.Fatalf("error = %v, want a *CollectionError", err)
+	}
+	if len(collectionErr.Failures) != 1 || collectionErr.Failures[0].Collector != "nodes" || !apierrors.IsServiceUnavailable(err) {
+		t.Errorf("failures = %v, want only nodes with the list error", err)
+	}
+	if pending, ok := findMetric(metrics, PendingPodsPercentMetric); !ok || pending.Value != 100 {
+		t.Errorf("pending pods = %+v (collected %v), want 100%% from the pods collector", pending, ok)
+	}
+	if _, ok := findMetric(metrics, NotReadyNodesPercentMetric); ok {
+		t.Error("not ready nodes reported although the node list failed")
+	}
+	if coverage := collector.Coverage(); coverage.Complete() {
+		t.Errorf("coverage = %+v, want the failed nodes collector to leave it incomplete", coverage)
+	}
+}
+
+// apiServer starts an API server answering every request with handle and returns a clientset talking to it
+func apiServer(t *testing.T, handle func(w http.ResponseWriter, r *http.Request)) kubernetes.Interface {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(handle))
+	t.Cleanup(server.Close)
+	kubeClient, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kubeClient
+}
+
+// writeJSON writes object as the JSON response
+func writeJSON(t *testing.T, w http.ResponseWriter, object interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(object); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestListNodesFollowsPages(t *testing.T) {
+	cfg := testCollectionConfig()
+	cfg.NodePageSize = 2
+	var mu sync.Mutex
+	var limits []string
+	kubeClient := apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		limits = append(limits, r.URL.Query().Get("limit"))
+		mu.Unlock()
+		page := corev1.NodeList{TypeMeta: metav1.TypeMeta{Kind: "NodeList", APIVersion: "v1"}}
+		switch r.URL.Query().Get("continue") {
+		case "":
+			page.Items = []corev1.Node{*readyNode("node-0", corev1.ConditionTrue), *readyNode("node-1", corev1.ConditionTrue)}
+			page.Continue = "page-2"
+		case "page-2":
+			page.Items = []corev1.Node{*readyNode("node-2", corev1.ConditionFalse)}
+		default:
+			http.Error(w, "unexpected continue token", http.StatusGone)
+			return
+		}
+		writeJSON(t, w, page)
+	})
+
+	nodes, err := NewCollector(kubeClient, cfg).listNodes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 3 {
+		t.Errorf("listed %d nodes, want 3", len(nodes))
+	}
+	if strings.Join(limits, ",") != "2,2" {
+		t.Errorf("page limits = %v, want two pages of collection.nodePageSize (2)", limits)
+	}
+}
+
+func TestCollectPodMetricsCountsAcrossPages(t *testing.T) {
+	cfg := testCollectionConfig()
+	cfg.PodPageSize = 2
+	cfg.ExcludeSucceededPods = true
+	var mu sync.Mutex
+	var requests []string
+	kubeClient := apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		mu.Lock()
+		requests = append(requests, query.Get("limit")+"/"+query.Get("continue")+"/"+query.Get("fieldSelector"))
+		mu.Unlock()
+		running := pendingPod("default", "api")
+		running.Status.Phase = corev1.PodRunning
+		page := corev1.PodList{TypeMeta: metav1.TypeMeta{Kind: "PodList", APIVersion: "v1"}}
+		switch query.Get("continue") {
+		case "":
+			page.Items = []corev1.Pod{*pendingPod("default", "web-0"), *running}
+			page.Continue = "page-2"
+		case "page-2":
+			page.Items = []corev1.Pod{*pendingPod("default", "web-1"), *running}
+			page.Continue = "page-3"
+		case "page-3":
+			page.Items = []corev1.Pod{*pendingPod("kube-system", "dns")}
+		default:
+			http.Error(w, "unexpected continue token", http.StatusGone)
+			return
+		}
+		writeJSON(t, w, page)
+	})
+
+	tally, err := NewCollector(kubeClient, cfg).collectPodMetrics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tally.totalPods != 5 || tally.pendingPods != 3 {
+		t.Errorf("total/pending pods = %d/%d, want 5/3 summed across the pages", tally.totalPods, tally.pendingPods)
+	}
+	want := "2//status.phase!=Succeeded,2/page-2/status.phase!=Succeeded,2/page-3/status.phase!=Succeeded"
+	if got := strings.Join(requests, ","); got != want {
+		t.Errorf("requests (limit/continue/fieldSelector) = %s, want %s", got, want)
+	}
+}
+
+func TestCollectMetricsReturnsPromptlyWhenCancelled(t *testing.T) {
+	// Registered first so it runs after the API server and its connections are closed
+	ignore := goleak.IgnoreCurrent()
+	t.Cleanup(func() { goleak.VerifyNone(t, ignore) })
+
+	var mu sync.Mutex
+	var paths []string
+	nodesListed := make(chan struct{})
+	kubeClient := apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == "/api/v1/nodes" {
+			// Hold the node list until the client gives up on it
+			close(nodesListed)
+			<-r.Context().Done()
+			return
+		}
+		http.NotFound(w, r)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-nodesListed
+		cancel()
+	}()
+
+	started := time.Now()
+	metrics, err := NewCollector(kubeClient, testCollectionConfig()).CollectMetrics(ctx)
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("collection returned %s after it was cancelled, want promptly", elapsed)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	if metrics != nil {
+		t.Errorf("metrics = %v, want none from a cancelled collection", metrics)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(paths, ",") != "/api/v1/nodes" {
+		t.Errorf("requests = %v, want only the node list: no tier starts after cancellation", paths)
+	}
+}
+
+func TestListPodsStopsBetweenPagesWhenCancelled(t *testing.T) {
+	// Registered first so it runs after the API server and its connections are closed
+	ignore := goleak.IgnoreCurrent()
+	t.Cleanup(func() { goleak.VerifyNone(t, ignore) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var mu sync.Mutex
+	var pages int
+	kubeClient := apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		pages++
+		mu.Unlock()
+		writeJSON(t, w, corev1.PodList{
+			TypeMeta: metav1.TypeMeta{Kind: "PodList", APIVersion: "v1"},
+			ListMeta: metav1.ListMeta{Continue: "next"},
+			Items:    []corev1.Pod{*pendingPod("default", "web")},
+		})
+	})
+
+	err := NewCollector(kubeClient, testCollectionConfig()).listPods(ctx, "", func(pods []corev1.Pod) {
+		// The caller gives up after the first page arrives
+		cancel()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if pages != 1 {
+		t.Errorf("requested %d pages, want 1: cancellation is checked before each page", pages)
+	}
+}
+
+// ownedPod returns a pod controlled by an object of kind, or a bare pod when kind is empty
+func ownedPod(kind string, restartPolicy corev1.RestartPolicy, phase corev1.PodPhase, states ...corev1.ContainerState) corev1.Pod {
+	pod := *pendingPod("default", "worker")
+	pod.Spec.RestartPolicy = restartPolicy
+	pod.Status.Phase = phase
+	if kind != "" {
+		controller := true
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: kind, Name: "worker", Controller: &controller}}
+	}
+	for _, state := range states {
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{State: state})
+	}
+	return pod
+}
+
+// exited returns the state of a container that exited with code at finishedAt
+func exited(code int32, finishedAt time.Time) corev1.ContainerState {
+	return corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: code, FinishedAt: metav1.NewTime(finishedAt)}}
+}
+
+// waiting returns the state of a container waiting for reason
+func waiting(reason string) corev1.ContainerState {
+	return corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason}}
+}
+
+// crashLooping returns a deployment pod in CrashLoopBackOff whose last termination is lastCrash
+func crashLooping(lastCrash corev1.ContainerState) corev1.Pod {
+	pod := ownedPod("ReplicaSet", corev1.RestartPolicyAlways, corev1.PodRunning, waiting("CrashLoopBackOff"))
+	pod.Status.ContainerStatuses[0].LastTerminationState = lastCrash
+	return pod
+}
+
+func TestIsPodCrashing(t *testing.T) {
+	now := time.Now()
+	running := corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	tests := []struct {
+		name   string
+		pod    corev1.Pod
+		window time.Duration
+		want   bool
+	}{
+		{name: "failed Job pod", pod: ownedPod("Job", corev1.RestartPolicyNever, corev1.PodFailed, exited(1, now.Add(-48*time.Hour))), want: false},
+		{name: "Job pod with a failed container not restarted", pod: ownedPod("Job", corev1.RestartPolicyNever, corev1.PodRunning, exited(1, now), running), want: false},
+		{name: "Job pod with a failed container restarted on failure", pod: ownedPod("Job", corev1.RestartPolicyOnFailure, corev1.PodRunning, exited(2, now)), want: false},
+		{name: "Job pod in crash loop", pod: ownedPod("Job", corev1.RestartPolicyOnFailure, corev1.PodRunning, waiting("CrashLoopBackOff")), want: true},
+		{name: "succeeded Job pod", pod: ownedPod("Job", corev1.RestartPolicyNever, corev1.PodSucceeded, exited(0, now)), want: false},
+		{name: "succeeded pod with a failed sidecar", pod: ownedPod("", corev1.RestartPolicyNever, corev1.PodSucceeded, exited(0, now), exited(1, now)), want: false},
+		{name: "failed bare pod", pod: ownedPod("", corev1.RestartPolicyNever, corev1.PodFailed, exited(1, now)), want: true},
+		{name: "bare pod with a failed container", pod: ownedPod("", corev1.RestartPolicyNever, corev1.PodRunning, exited(1, now), running), want: true},
+		{name: "deployment pod in crash loop", pod: ownedPod("ReplicaSet", corev1.RestartPolicyAlways, corev1.PodRunning, waiting("CrashLoopBackOff")), want: true},
+		{name: "deployment pod that cannot create its container", pod: ownedPod("ReplicaSet", corev1.RestartPolicyAlways, corev1.PodPending, waiting("CreateContainerError")), want: true},
+		{name: "deployment pod crashed recently", pod: ownedPod("ReplicaSet", corev1.RestartPolicyAlways, corev1.PodRunning, exited(137, now.Add(-2*time.Minute))), window: 10 * time.Minute, want: true},
+		{name: "deployment pod crashed beyond the window", pod: ownedPod("ReplicaSet", corev1.RestartPolicyAlways, corev1.PodRunning, exited(137, now.Add(-2*time.Hour))), window: 10 * time.Minute, want: false},
+		{name: "deployment pod crashed long ago without a window", pod: ownedPod("ReplicaSet", corev1.RestartPolicyAlways, corev1.PodRunning, exited(137, now.Add(-2*time.Hour))), want: true},
+		{name: "crash loop whose last crash is recent", pod: crashLooping(exited(137, now.Add(-2*time.Minute))), window: 10 * time.Minute, want: true},
+		{name: "crash loop whose last crash is beyond the window", pod: crashLooping(exited(137, now.Add(-2*time.Hour))), window: 10 * time.Minute, want: false},
+		{name: "crash loop whose last crash is long ago without a window", pod: crashLooping(exited(137, now.Add(-2*time.Hour))), want: true},
+		{name: "crash loop without a last termination", pod: crashLooping(corev1.ContainerState{}), window: 10 * time.Minute, want: true},
+		{name: "crash without a finish time", pod: ownedPod("ReplicaSet", corev1.RestartPolicyAlways, corev1.PodRunning, exited(1, time.Time{})), window: 10 * time.Minute, want: true},
+		{name: "clean exit", pod: ownedPod("ReplicaSet", corev1.RestartPolicyAlways, corev1.PodRunning, exited(0, now)), want: false},
+		{name: "healthy", pod: ownedPod("ReplicaSet", corev1.RestartPolicyAlways, corev1.PodRunning, running), want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := testCollectionConfig()
+			cfg.CrashRecencyWindow = test.window
+			collector := NewCollector(fake.NewSimpleClientset(), cfg)
+			if got := collector.isPodCrashing(test.pod); got != test.want {
+				t.Errorf("isPodCrashing = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPodWaitingReasons(t *testing.T) {
+	tests := []struct {
+		reason        string
+		init          bool
+		wantCrashing  int
+		wantImagePull int
+	}{
+		{reason: "CrashLoopBackOff", wantCrashing: 100},
+		{reason: "CreateContainerError", wantCrashing: 100},
+		{reason: "ImagePullBackOff", wantImagePull: 100},
+		{reason: "ErrImagePull", wantImagePull: 100},
+		{reason: "InvalidImageName", wantImagePull: 100},
+		{reason: "ImagePullBackOff", init: true, wantImagePull: 100},
+		{reason: "ContainerCreating"},
+		{reason: "PodInitializing"},
+	}
+
+	for _, test := range tests {
+		name := test.reason
+		if test.init {
+			name = "init container " + name
+		}
+		t.Run(name, func(t *testing.T) {
+			pod := ownedPod("ReplicaSet", corev1.RestartPolicyAlways, corev1.PodPending)
+			status := corev1.ContainerStatus{Name: "app", State: waiting(test.reason)}
+			if test.init {
+				pod.Status.InitContainerStatuses = []corev1.ContainerStatus{status}
+			} else {
+				pod.Status.ContainerStatuses = []corev1.ContainerStatus{status}
+			}
+
+			metrics, err := NewCollector(fake.NewSimpleClientset(&pod), testCollectionConfig()).CollectMetrics(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			crashing, _ := findMetric(metrics, CrashingPodsPercentMetric)
+			imagePull, ok := findMetric(metrics, ImagePullErrorsPercentMetric)
+			if !ok {
+				t.Fatalf("%s was not collected", ImagePullErrorsPercentMetric)
+			}
+			if crashing.Value != test.wantCrashing || imagePull.Value != test.wantImagePull {
+				t.Errorf("crashing = %d%%, image pull errors = %d%%, want %d%% and %d%%",
+					crashing.Value, imagePull.Value, test.wantCrashing, test.wantImagePull)
+			}
+		})
+	}
+}
+
+func TestCrashingPodsPercentLeavesOutJobPods(t *testing.T) {
+	objects := []runtime.Object{}
+	for i, pod := range []corev1.Pod{
+		ownedPod("Job", corev1.RestartPolicyNever, corev1.PodFailed, exited(1, time.Now().Add(-24*time.Hour))),
+		ownedPod("Job", corev1.RestartPolicyOnFailure, corev1.PodRunning, exited(1, time.Now())),
+		ownedPod("ReplicaSet", corev1.RestartPolicyAlways, corev1.PodRunning, waiting("CrashLoopBackOff")),
+		ownedPod("ReplicaSet", corev1.RestartPolicyAlways, corev1.PodRunning, corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}),
+	} {
+		pod := pod
+		pod.Name = fmt.Sprintf("worker-%d", i)
+		objects = append(objects, &pod)
+	}
+
+	metrics, err := NewCollector(fake.NewSimpleClientset(objects...), testCollectionConfig()).CollectMetrics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	crashing, ok := findMetric(metrics, CrashingPodsPercentMetric)
+	if !ok || crashing.Value != 25 {
+		t.Errorf("crashing pods = %s (collected %v), want 25%%: only the deployment pod of 4", crashing, ok)
+	}
+}
+
+// shutdownPod returns a deployment pod in the status kubelet writes when it fails the pod for a node
+// shutdown: Failed phase with reason and message, and each container killed by the shutdown
+func shutdownPod(name, reason, message string, containers int) *corev1.Pod {
+	pod := ownedPod("ReplicaSet", corev1.RestartPolicyAlways, corev1.PodFailed)
+	pod.Name = name
+	pod.Status.Reason = reason
+	pod.Status.Message = message
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionFalse, Reason: "PodFailed"}}
+	for i := 0; i < containers; i++ {
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+			Name:  fmt.Sprintf("app-%d", i),
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 137, Reason: "Error", FinishedAt: metav1.NewTime(time.Now().Add(-time.Hour))}},
+		})
+	}
+	return &pod
+}
+
+func TestShutdownTerminatedPods(t *testing.T) {
+	// The graceful shutdown manager terminates running pods; admission rejects pods bound while shutting down
+	objects := []runtime.Object{
+		shutdownPod("terminated", "Shutdown", "Shutting down, pod was terminated", 2),
+		shutdownPod("rejected", "NodeShutdown", "Pod was rejected as the node is shutting down.", 0),
+		shutdownPod("terminated-newer-kubelet", "Terminated", "Pod was terminated in response to imminent node shutdown.", 1),
+		shutdownPod("evicted", "Evicted", "The node was low on resource: memory.", 1),
+	}
+	tests := []struct {
+		name         string
+		reasons      []string
+		report       bool
+		wantCrashing int
+		wantShutdown int
+		wantReported bool
+	}{
+		{name: "default reasons", reasons: []string{"Shutdown", "NodeShutdown"}, wantCrashing: 50},
+		{name: "default reasons reported", reasons: []string{"Shutdown", "NodeShutdown"}, report: true, wantCrashing: 50, wantShutdown: 2, wantReported: true},
+		{name: "custom reasons", reasons: []string{"Shutdown", "NodeShutdown", "Terminated"}, report: true, wantCrashing: 25, wantShutdown: 3, wantReported: true},
+		{name: "no ignorable reasons", report: true, wantCrashing: 100, wantReported: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := testCollectionConfig()
+			cfg.IgnorableTerminatedReasons = test.reasons
+			cfg.ReportShutdownTerminatedPods = test.report
+			metrics, err := NewCollector(fake.NewSimpleClientset(objects...), cfg).CollectMetrics(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			crashing, ok := findMetric(metrics, CrashingPodsPercentMetric)
+			if !ok || crashing.Value != test.wantCrashing {
+				t.Errorf("crashing pods = %s (collected %v), want %v%%", crashing, ok, test.wantCrashing)
+			}
+			shutdown, ok := findMetric(metrics, ShutdownTerminatedPodsMetric)
+			if ok != test.wantReported {
+				t.Fatalf("%s collected = %v, want %v", ShutdownTerminatedPodsMetric, ok, test.wantReported)
+			}
+			if ok && shutdown.Value != test.wantShutdown {
+				t.Errorf("%s = %v, want %v", ShutdownTerminatedPodsMetric, shutdown.Value, test.wantShutdown)
+			}
+		})
+	}
+}
+
+// conditionedNode returns a node with the given Ready status and each of conditions True
+func conditionedNode(name string, ready corev1.ConditionStatus, conditions ...corev1.NodeConditionType) *corev1.Node {
+	node := readyNode(name, ready)
+	for _, condition := range conditions {
+		node.Status.Conditions = append(node.Status.Conditions, corev1.NodeCondition{Type: condition, Status: corev1.ConditionTrue})
+	}
+	return node
+}
+
+func TestNodePressureMetricsWithMixedConditions(t *testing.T) {
+	nodes := []runtime.Object{
+		conditionedNode("healthy", corev1.ConditionTrue),
+		// NotReady and pressured: counted in both
+		conditionedNode("not-ready-memory", corev1.ConditionFalse, corev1.NodeMemoryPressure),
+		conditionedNode("memory-disk", corev1.ConditionTrue, corev1.NodeMemoryPressure, corev1.NodeDiskPressure),
+		conditionedNode("pid-network", corev1.ConditionUnknown, corev1.NodePIDPressure, corev1.NodeNetworkUnavailable),
+		// Conditions reported as False or Unknown are not pressure
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "cleared"},
+			Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+				{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionFalse},
+				{Type: corev1.NodeDiskPressure, Status: corev1.ConditionUnknown},
+			}},
+		},
+	}
+
+	metrics, _, err := NewCollector(fake.NewSimpleClientset(nodes...), testCollectionConfig()).collectNodeMetrics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for metricType, want := range map[MetricType]string{
+		NotReadyNodesPercentMetric:           "40% (2/5)",
+		MemoryPressureNodesPercentMetric:     "40% (2/5)",
+		DiskPressureNodesPercentMetric:       "20% (1/5)",
+		PIDPressureNodesPercentMetric:        "20% (1/5)",
+		NetworkUnavailableNodesPercentMetric: "20% (1/5)",
+	} {
+		metric, ok := findMetric(metrics, metricType)
+		if !ok {
+			t.Errorf("%s not reported", metricType)
+			continue
+		}
+		if got := metric.String(); got != want {
+			t.Errorf("%s = %s, want %s", metricType, got, want)
+		}
+	}
+}
+
+func TestNodePressureMetricsWithoutNodes(t *testing.T) {
+	metrics, _, err := NewCollector(fake.NewSimpleClientset(), testCollectionConfig()).collectNodeMetrics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pressure := range nodePressureMetrics {
+		if metric, ok := findMetric(metrics, pressure.metric); !ok || metric.Value != 0 {
+			t.Errorf("%s = %s (reported %v), want 0 with no nodes", pressure.metric, metric, ok)
+		}
+	}
+}
+
+// oomKilled returns the state of a container OOM-killed at finishedAt
+func oomKilled(finishedAt time.Time) *corev1.ContainerStateTerminated {
+	return &corev1.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled", FinishedAt: metav1.NewTime(finishedAt)}
+}
+
+func TestPodOOMKilledSince(t *testing.T) {
+	now := time.Now()
+	since := now.Add(-15 * time.Minute)
+	running := corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	tests := []struct {
+		name   string
+		status corev1.PodStatus
+		want   bool
+	}{
+		{name: "no containers", want: false},
+		{
+			name:   "running",
+			status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{State: running}}},
+			want:   false,
+		},
+		{
+			name:   "OOM-killed now",
+			status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{State: corev1.ContainerState{Terminated: oomKilled(now)}}}},
+			want:   true,
+		},
+		{
+			name: "restarted after a recent OOM kill",
+			status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+				{State: running, LastTerminationState: corev1.ContainerState{Terminated: oomKilled(now.Add(-time.Minute))}},
+			}},
+			want: true,
+		},
+		{
+			name: "restarted after an OOM kill before the window",
+			status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+				{State: running, LastTerminationState: corev1.ContainerState{Terminated: oomKilled(now.Add(-24 * time.Hour))}},
+			}},
+			want: false,
+		},
+		{
+			name:   "OOM-killed at the start of the window",
+			status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{State: corev1.ContainerState{Terminated: oomKilled(since)}}}},
+			want:   true,
+		},
+		{
+			name:   "OOM-killed just before the window",
+			status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{State: corev1.ContainerState{Terminated: oomKilled(since.Add(-time.Second))}}}},
+			want:   false,
+		},
+		{
+			name:   "OOM-killed without a finish time",
+			status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{State: corev1.ContainerState{Terminated: oomKilled(time.Time{})}}}},
+			want:   false,
+		},
+		{
+			name: "terminated for another reason",
+			status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+				{State: running, LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 137, Reason: "Error", FinishedAt: metav1.NewTime(now)}}},
+			}},
+			want: false,
+		},
+		{
+			name: "OOM-killed sidecar",
+			status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+				{State: running},
+				{State: running, LastTerminationState: corev1.ContainerState{Terminated: oomKilled(now)}},
+			}},
+			want: true,
+		},
+		{
+			name: "OOM-killed init container",
+			status: corev1.PodStatus{
+				InitContainerStatuses: []corev1.ContainerStatus{{State: corev1.ContainerState{Terminated: oomKilled(now)}}},
+				ContainerStatuses:     []corev1.ContainerStatus{{State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "PodInitializing"}}}},
+			},
+			want: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := corev1.Pod{Status: test.status}
+			if got := podOOMKilledSince(pod, since); got != test.want {
+				t.Errorf("podOOMKilledSince = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestOOMKilledPodsCountsDistinctPods(t *testing.T) {
+	now := time.Now()
+	pod := func(namespace, name string, terminations ...*corev1.ContainerStateTerminated) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		for _, terminated := range terminations {
+			pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+				State:                corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				LastTerminationState: corev1.ContainerState{Terminated: terminated},
+			})
+		}
+		return pod
+	}
+	kubeClient := fake.NewSimpleClientset(
+		// Two containers OOM-killed count the pod once
+		pod("shop", "cart", oomKilled(now), oomKilled(now.Add(-time.Minute))),
+		pod("shop", "api", oomKilled(now.Add(-5*time.Minute))),
+		pod("shop", "search", oomKilled(now.Add(-time.Hour))),
+		pod("shop", "web"),
+		pod("sandbox", "leaky", oomKilled(now)),
+	)
+	cfg := testCollectionConfig()
+	cfg.Namespaces.Exclude = []string{"sandbox"}
+
+	metrics, err := NewCollector(kubeClient, cfg).CollectMetrics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	oom, ok := findMetric(metrics, OOMKilledPodsMetric)
+	if !ok {
+		t.Fatalf("%s not reported", OOMKilledPodsMetric)
+	}
+	if oom.Value != 2 || strings.Join(oom.Offenders, ",") != "shop/api,shop/cart" {
+		t.Errorf("%s = %d %v, want 2: shop/api and shop/cart", OOMKilledPodsMetric, oom.Value, oom.Offenders)
+	}
+	if oom.Window != "15m0s" {
+		t.Errorf("window = %q, want collection.oomKillWindow 15m0s", oom.Window)
+	}
+}
+
+// zonedNode returns a ready node in zone
+func zonedNode(name, zone string) *corev1.Node {
+	node := readyNode(name, corev1.ConditionTrue)
+	node.Labels = map[string]string{zoneLabel: zone}
+	return node
+}
+
+// zonalDeployment returns a Deployment of app spread across zones by a topology spread constraint with
+// maxSkew, or by pod anti-affinity when maxSkew is 0
+func zonalDeployment(app string, replicas int32, maxSkew int32) *appsv1.Deployment {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: app},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": app}},
+		},
+	}
+	spec := &deployment.Spec.Template.Spec
+	if maxSkew > 0 {
+		spec.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{{MaxSkew: maxSkew, TopologyKey: zoneLabel}}
+	} else {
+		spec.Affinity = &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{TopologyKey: zoneLabel}},
+		}}
+	}
+	return deployment
+}
+
+// replicaPods returns count pods of app on node, ready or not
+func replicaPods(app, node string, count int, ready bool) []runtime.Object {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	pods := make([]runtime.Object, 0, count)
+	for i := 0; i < count; i++ {
+		pod := pendingPod("default", fmt.Sprintf("%s-%s-%d-%v", app, node, i, ready))
+		pod.Labels = map[string]string{"app": app}
+		pod.Spec.NodeName = node
+		pod.Status.Phase = corev1.PodRunning
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: status}}
+		pods = append(pods, pod)
+	}
+	return pods
+}
+
+func TestZoneRedundancy(t *testing.T) {
+	tests := []struct {
+		name     string
+		replicas int32
+		maxSkew  int32
+		// ready and unready are the replicas per zone, in zones 1, 2 and 3
+		ready    []int
+		unready  []int
+		wantLoss string
+	}{
+		{name: "balanced", replicas: 3, maxSkew: 1, ready: []int{1, 1, 1}},
+		{name: "all replicas in one zone", replicas: 3, maxSkew: 1, ready: []int{3, 0, 0},
+			wantLoss: "ready replicas in 1 of 3 zones, none in eastus-2, eastus-3"},
+		{name: "unready replicas do not cover a zone", replicas: 3, maxSkew: 1, ready: []int{2, 1, 0}, unready: []int{0, 0, 1},
+			wantLoss: "ready replicas in 2 of 3 zones, none in eastus-3"},
+		{name: "skew beyond maxSkew", replicas: 6, maxSkew: 1, ready: []int{3, 2, 1},
+			wantLoss: "zone eastus-1 hosts 3 ready replicas, skew 2 exceeds maxSkew 1"},
+		{name: "skew within maxSkew", replicas: 6, maxSkew: 2, ready: []int{3, 2, 1}},
+		{name: "two replicas need only two zones", replicas: 2, maxSkew: 1, ready: []int{1, 0, 1}},
+		{name: "anti-affinity on the draining zone", replicas: 2, ready: []int{2, 0, 0},
+			wantLoss: "ready replicas in 1 of 3 zones, none in eastus-2, eastus-3"},
+		{name: "single-zone cluster", replicas: 3, maxSkew: 1, ready: []int{3}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objects := []runtime.Object{zonalDeployment("web", test.replicas, test.maxSkew)}
+			for i, count := range test.ready {
+				node := fmt.Sprintf("node-%d", i+1)
+				objects = append(objects, zonedNode(node, fmt.Sprintf("eastus-%d", i+1)))
+				objects = append(objects, replicaPods("web", node, count, true)...)
+				if i < len(test.unready) {
+					objects = append(objects, replicaPods("web", node, test.unready[i], false)...)
+				}
+			}
+			// A single-replica Deployment has no redundancy to lose
+			objects = append(objects, zonalDeployment("singleton", 1, 1))
+			objects = append(objects, replicaPods("singleton", "node-1", 1, true)...)
+
+			metrics, err := NewCollector(fake.NewSimpleClientset(objects...), testCollectionConfig()).collectZoneRedundancyMetrics(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			metric, ok := findMetric(metrics, WorkloadsLosingZoneRedundancyMetric)
+			if !ok {
+				t.Fatalf("%s not reported", WorkloadsLosingZoneRedundancyMetric)
+			}
+			var want []string
+			if test.wantLoss != "" {
+				want = []string{"default/Deployment/web: " + test.wantLoss}
+			}
+			if metric.Value != len(want) || fmt.Sprint(metric.Offenders) != fmt.Sprint(want) {
+				t.Errorf("%s = %d %v, want %d %v", WorkloadsLosingZoneRedundancyMetric, metric.Value, metric.Offenders, len(want), want)
+			}
+		})
+	}
+}
+
+// servedResources lists the API resources every collector needs, leaving out the group versions in missing
+func servedResources(missing ...string) []*metav1.APIResourceList {
+	all := []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "nodes"}, {Name: "pods"}, {Name: "events"}}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{{Name: "deployments"}, {Name: "daemonsets"}, {Name: "statefulsets"}}},
+		{GroupVersion: "batch/v1", APIResources: []metav1.APIResource{{Name: "jobs"}}},
+		{GroupVersion: "metrics.k8s.io/v1beta1", APIResources: []metav1.APIResource{{Name: "nodes"}, {Name: "pods"}}},
+	}
+	skip := make(map[string]bool, len(missing))
+	for _, groupVersion := range missing {
+		skip[groupVersion] = true
+	}
+	served := make([]*metav1.APIResourceList, 0, len(all))
+	for _, list := range all {
+		if !skip[list.GroupVersion] {
+			served = append(served, list)
+		}
+	}
+	return served
+}
+
+func TestDiscoveryDisablesAndReenablesCollectors(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(readyNode("node-0", corev1.ConditionTrue))
+	kubeClient.Resources = servedResources("batch/v1", "metrics.k8s.io/v1beta1")
+	collector := NewCollector(kubeClient, testCollectionConfig())
+
+	if err := collector.Discover(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []DisabledCollector{
+		{Name: "jobs", Reason: "API not served: batch/v1/jobs"},
+		{Name: "usage", Reason: "API not served: metrics.k8s.io/v1beta1/nodes"},
+	}
+	if disabled := collector.DisabledCollectors(); fmt.Sprint(disabled) != fmt.Sprint(want) {
+		t.Fatalf("disabled collectors = %v, want %v", disabled, want)
+	}
+
+	// The rest of the collection runs, and the metrics of disabled collectors are missing rather than zero
+	metrics, err := collector.CollectMetrics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := findMetric(metrics, NotReadyNodesPercentMetric); !ok {
+		t.Errorf("%s not reported", NotReadyNodesPercentMetric)
+	}
+	if metric, ok := findMetric(metrics, FailedJobsMetric); ok {
+		t.Errorf("%s = %d reported by a disabled collector", FailedJobsMetric, metric.Value)
+	}
+	for _, action := range kubeClient.Actions() {
+		if action.GetResource().Resource == "jobs" {
+			t.Errorf("disabled jobs collector sent %s jobs", action.GetVerb())
+		}
+	}
+
+	// The APIs appear, metrics-server installed mid-lifecycle, and rediscovery re-enables the collectors
+	kubeClient.Resources = servedResources()
+	if err := collector.Discover(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if disabled := collector.DisabledCollectors(); len(disabled) != 0 {
+		t.Errorf("disabled collectors = %v after the APIs appeared, want none", disabled)
+	}
+}
+
+func TestDiscoveryErrorKeepsPreviousState(t *testing.T) {
+	var mu sync.Mutex
+	failing := false
+	kubeClient := apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, list := range servedResources("metrics.k8s.io/v1beta1") {
+			if r.URL.Path == "/apis/"+list.GroupVersion || r.URL.Path == "/api/"+list.GroupVersion {
+				if failing {
+					http.Error(w, "etcd leader changed", http.StatusServiceUnavailable)
+					return
+				}
+				writeJSON(t, w, list)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	})
+	collector := NewCollector(kubeClient, testCollectionConfig())
+
+	if err := collector.Discover(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []DisabledCollector{{Name: "usage", Reason: "API not served: metrics.k8s.io/v1beta1/nodes"}}
+	if disabled := collector.DisabledCollectors(); fmt.Sprint(disabled) != fmt.Sprint(want) {
+		t.Fatalf("disabled collectors = %v, want %v", disabled, want)
+	}
+
+	mu.Lock()
+	failing = true
+	mu.Unlock()
+	if err := collector.Discover(context.Background()); err == nil {
+		t.Fatal("discovery against an unavailable API server succeeded")
+	}
+	if disabled := collector.DisabledCollectors(); fmt.Sprint(disabled) != fmt.Sprint(want) {
+		t.Errorf("disabled collectors = %v after a failed discovery, want the previous %v", disabled, want)
+	}
+}
+
+// poolNode returns a node of an AKS VMSS pool, identified by uid, whose Ready condition is ready
+func poolNode(pool string, instance int, uid string, ready bool) corev1.Node {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	node := readyNode(fmt.Sprintf("aks-%s-12345678-vmss%06x", pool, instance), status)
+	node.UID = types.UID(uid)
+	return *node
+}
+
+func TestNodePool(t *testing.T) {
+	labelled := poolNode("nodepool1", 0, "a", true)
+	labelled.Labels = map[string]string{AgentPoolLabel: "system"}
+	for _, test := range []struct {
+		node corev1.Node
+		want string
+	}{
+		{node: poolNode("nodepool1", 10, "a", true), want: "nodepool1"},
+		{node: labelled, want: "system"},
+		{node: *readyNode("worker-0", corev1.ConditionTrue), want: ""},
+	} {
+		if got := nodePool(test.node); got != test.want {
+			t.Errorf("pool of %s = %q, want %q", test.node.Name, got, test.want)
+		}
+	}
+}
+
+func TestReimageLoopDetection(t *testing.T) {
+	const window = 10 * time.Minute
+	const maxCreations = 2
+	isReady := func(node corev1.Node) bool { return nodeConditionTrue(node, corev1.NodeReady) }
+
+	tests := []struct {
+		name string
+		// cycles are the node listings observed a minute apart
+		cycles        [][]corev1.Node
+		wantSuspected []string
+	}{
+		{
+			name: "same instance recreated every cycle without becoming ready",
+			cycles: [][]corev1.Node{
+				{poolNode("nodepool1", 0, "a", true), poolNode("nodepool1", 1, "b0", false)},
+				{poolNode("nodepool1", 0, "a", true), poolNode("nodepool1", 1, "b1", false)},
+				{poolNode("nodepool1", 0, "a", true), poolNode("nodepool1", 1, "b2", false)},
+				{poolNode("nodepool1", 0, "a", true), poolNode("nodepool1", 1, "b3", false)},
+			},
+			wantSuspected: []string{"nodepool1: 3 node creations in 10m0s, ready 1 -> 1"},
+		},
+		{
+			name: "new instance names that never stabilize",
+			cycles: [][]corev1.Node{
+				{poolNode("nodepool1", 0, "a", true), poolNode("nodepool1", 1, "b", true)},
+				{poolNode("nodepool1", 0, "a", true), poolNode("nodepool1", 2, "c", false)},
+				{poolNode("nodepool1", 0, "a", true), poolNode("nodepool1", 3, "d", false)},
+				{poolNode("nodepool1", 0, "a", true), poolNode("nodepool1", 4, "e", false)},
+			},
+			wantSuspected: []string{"nodepool1: 3 node creations in 10m0s, ready 2 -> 1"},
+		},
+		{
+			name: "scale up adds ready nodes",
+			cycles: [][]corev1.Node{
+				{poolNode("nodepool1", 0, "a", true)},
+				{poolNode("nodepool1", 0, "a", true), poolNode("nodepool1", 1, "b", true)},
+				{poolNode("nodepool1", 0, "a", true), poolNode("nodepool1", 1, "b", true), poolNode("nodepool1", 2, "c", true)},
+				{poolNode("nodepool1", 0, "a", true), poolNode("nodepool1", 1, "b", true), poolNode("nodepool1", 2, "c", true), poolNode("nodepool1", 3, "d", true)},
+			},
+		},
+		{
+			name: "surge upgrade replaces each node once",
+			cycles: [][]corev1.Node{
+				{poolNode("nodepool1", 0, "a", true), poolNode("nodepool1", 1, "b", true)},
+				{poolNode("nodepool1", 0, "a", true), poolNode("nodepool1", 1, "b", true), poolNode("nodepool1", 2, "c", true)},
+				{poolNode("nodepool1", 1, "b", true), poolNode("nodepool1", 2, "c", true), poolNode("nodepool1", 3, "d", true)},
+				{poolNode("nodepool1", 2, "c", true), poolNode("nodepool1", 3, "d", true)},
+			},
+		},
+		{
+			name: "only the looping pool is reported",
+			cycles: [][]corev1.Node{
+				{poolNode("system", 0, "s", true), poolNode("user", 0, "u0", false)},
+				{poolNode("system", 0, "s", true), poolNode("user", 0, "u1", false)},
+				{poolNode("system", 0, "s", true), poolNode("user", 0, "u2", false)},
+				{poolNode("system", 0, "s", true), poolNode("user", 0, "u3", false)},
+			},
+			wantSuspected: []string{"user: 3 node creations in 10m0s, ready 0 -> 0"},
+		},
+		{
+			name: "nodes present in the first listing are not creations",
+			cycles: [][]corev1.Node{
+				{poolNode("nodepool1", 0, "a", false), poolNode("nodepool1", 1, "b", false), poolNode("nodepool1", 2, "c", false)},
+				{poolNode("nodepool1", 0, "a", false), poolNode("nodepool1", 1, "b", false), poolNode("nodepool1", 2, "c", false)},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var tracker reimageTracker
+			now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+			var suspected []string
+			for _, nodes := range test.cycles {
+				suspected = tracker.observe(nodes, isReady, now, window, maxCreations)
+				now = now.Add(time.Minute)
+			}
+			if fmt.Sprint(suspected) != fmt.Sprint(test.wantSuspected) {
+				t.Errorf("suspected = %v, want %v", suspected, test.wantSuspected)
+			}
+		})
+	}
+}
+
+func TestReimageLoopCreationsAgeOutOfTheWindow(t *testing.T) {
+	isReady := func(node corev1.Node) bool { return nodeConditionTrue(node, corev1.NodeReady) }
+
+	// A node recreated every 4 minutes: its three creations span 8 minutes, so a 5m window never holds
+	// more than two of them
+	for window, want := range map[time.Duration]int{5 * time.Minute: 0, 10 * time.Minute: 1} {
+		var tracker reimageTracker
+		now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+		var suspected []string
+		for i := 0; i < 4; i++ {
+			suspected = tracker.observe([]corev1.Node{poolNode("nodepool1", 0, fmt.Sprint(i), false)}, isReady, now, window, 2)
+			now = now.Add(4 * time.Minute)
+		}
+		if len(suspected) != want {
+			t.Errorf("suspected with a %s window = %v, want %d pools", window, suspected, want)
+		}
+	}
+}
+
+func TestNodeProgressFingerprint(t *testing.T) {
+	collector := NewCollector(fake.NewSimpleClientset(), testCollectionConfig())
+	// nodes returns two ready nodes at kubelet v1.27.7, with change applied to the second
+	nodes := func(change func(*corev1.Node)) []corev1.Node {
+		nodes := []corev1.Node{poolNode("nodepool1", 0, "a", true), poolNode("nodepool1", 1, "b", true)}
+		for i := range nodes {
+			nodes[i].Status.NodeInfo.KubeletVersion = "v1.27.7"
+		}
+		if change != nil {
+			change(&nodes[1])
+		}
+		return nodes
+	}
+	baseline := nodeProgressFingerprint(nodes(nil), collector.isNodeReady)
+
+	tests := []struct {
+		name        string
+		nodes       []corev1.Node
+		wantChanged bool
+	}{
+		{name: "listed in another order", nodes: func() []corev1.Node { n := nodes(nil); return []corev1.Node{n[1], n[0]} }()},
+		{name: "label added", nodes: nodes(func(node *corev1.Node) { node.Labels = map[string]string{"team": "payments"} })},
+		{name: "node reimaged", nodes: nodes(func(node *corev1.Node) { node.UID = "c" }), wantChanged: true},
+		{name: "surge node added", nodes: append(nodes(nil), poolNode("nodepool1", 2, "c", false)), wantChanged: true},
+		{name: "node removed", nodes: nodes(nil)[:1], wantChanged: true},
+		{name: "kubelet upgraded", nodes: nodes(func(node *corev1.Node) { node.Status.NodeInfo.KubeletVersion = "v1.28.3" }), wantChanged: true},
+		{name: "node cordoned", nodes: nodes(func(node *corev1.Node) { node.Spec.Unschedulable = true }), wantChanged: true},
+		{name: "node not ready", nodes: nodes(func(node *corev1.Node) { node.Status.Conditions[0].Status = corev1.ConditionFalse }), wantChanged: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changed := nodeProgressFingerprint(test.nodes, collector.isNodeReady) != baseline
+			if changed != test.wantChanged {
+				t.Errorf("fingerprint changed = %v, want %v", changed, test.wantChanged)
+			}
+		})
+	}
+}
+
+func TestConfigReferenceFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{name: "missing secret", message: `secret "tls-cert" not found`, want: "secret payments/tls-cert missing"},
+		{name: "missing configmap", message: `configmap "app-settings" not found`, want: "configmap payments/app-settings missing"},
+		{name: "missing secret key", message: "couldn't find key tls.crt in Secret payments/tls-cert",
+			want: "key tls.crt missing from secret payments/tls-cert"},
+		{name: "mount of a missing secret", message: `MountVolume.SetUp failed for volume "tls" : secret "tls-cert" not found`,
+			want: "secret payments/tls-cert missing"},
+		{name: "mount of a missing configmap", message: `MountVolume.SetUp failed for volume "settings" : configmap "app-settings" not found`,
+			want: "configmap payments/app-settings missing"},
+		{name: "mount timeout", message: "Unable to attach or mount volumes: unmounted volumes=[data], unattached volumes=[data]: timed out waiting for the condition"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := configReferenceFailure("payments", test.message)
+			if got != test.want || ok != (test.want != "") {
+				t.Errorf("configReferenceFailure = %q, %v, want %q", got, ok, test.want)
+			}
+		})
+	}
+}
+
+// failedMount returns a FailedMount event for a pod last seen at the given time
+func failedMount(namespace, pod, message string, at time.Time) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Namespace: namespace, Name: pod + ".failedmount"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: namespace, Name: pod},
+		Reason:         "FailedMount",
+		Message:        message,
+		LastTimestamp:  metav1.NewTime(at),
+	}
+}
+
+func TestConfigReferenceFailures(t *testing.T) {
+	// configError returns a pending pod whose container is waiting with CreateContainerConfigError
+	configError := func(name, message string) *corev1.Pod {
+		pod := pendingPod("payments", name)
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "app", State: corev1.ContainerState{
+			Waiting: &corev1.ContainerStateWaiting{Reason: "CreateContainerConfigError", Message: message},
+		}}}
+		return pod
+	}
+	running := pendingPod("payments", "api-running")
+	running.Status.Phase = corev1.PodRunning
+	now := time.Now()
+	const missingSecret = `MountVolume.SetUp failed for volume "tls" : secret "tls-cert" not found`
+
+	kubeClient := fake.NewSimpleClientset(
+		// Environment references
+		configError("api-env", `secret "tls-cert" not found`),
+		configError("worker-env", "couldn't find key DATABASE_URL in ConfigMap payments/app-settings"),
+		// Volume references, from events
+		pendingPod("payments", "api-mount"),
+		failedMount("payments", "api-mount", missingSecret, now.Add(-time.Minute)),
+		pendingPod("payments", "api-stale-event"),
+		failedMount("payments", "api-stale-event", missingSecret, now.Add(-time.Hour)),
+		running,
+		failedMount("payments", "api-running", missingSecret, now.Add(-time.Minute)),
+		pendingPod("payments", "api-slow-mount"),
+		failedMount("payments", "api-slow-mount", "Unable to attach or mount volumes: unmounted volumes=[data]: timed out waiting for the condition", now),
+	)
+
+	metrics, err := NewCollector(kubeClient, testCollectionConfig()).CollectMetrics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	failures, ok := findMetric(metrics, ConfigReferenceFailuresMetric)
+	if !ok {
+		t.Fatalf("%s was not collected", ConfigReferenceFailuresMetric)
+	}
+	want := []string{
+		"key DATABASE_URL missing from configmap payments/app-settings (1 pod)",
+		"secret payments/tls-cert missing (2 pods)",
+	}
+	if failures.Value != 3 || !reflect.DeepEqual(failures.Offenders, want) {
+		t.Errorf("%s = %d %v, want 3 %v", ConfigReferenceFailuresMetric, failures.Value, failures.Offenders, want)
+	}
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+)
+
+// collectorDisabledByConfig is the disabled reason of a collector whose block sets enabled: false
+const collectorDisabledByConfig = "disabled by the collectors configuration"
+
+// collectorRun is the outcome of a collector's most recent run
+type collectorRun struct {
+	at       time.Time
+	duration time.Duration
+	err      string
+}
+
+// DurationObserver receives the duration of every collection and of every collector run
+type DurationObserver interface {
+	ObserveCollectionDuration(duration time.Duration)
+	ObserveCollectorDuration(collector string, duration time.Duration)
+}
+
+// CollectorStatus describes a collector registered for this configuration and its most recent run
+type CollectorStatus struct {
+	Name           string     `json:"name"`
+	Priority       string     `json:"priority"`
+	Enabled        bool       `json:"enabled"`
+	DisabledReason string     `json:"disabledReason,omitempty"`
+	FailurePolicy  string     `json:"failurePolicy"`
+	Timeout        string     `json:"timeout,omitempty"`
+	LastRun        *time.Time `json:"lastRun,omitempty"`
+	LastDuration   string     `json:"lastDuration,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+}
+
+// ConfigureCollectors applies the collectors blocks of a loaded or reloaded configuration from the next
+// collection on
+func (c *Collector) ConfigureCollectors(blocks config.CollectorsConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blocks = blocks
+}
+
+// collectorBlocks returns the collectors blocks in effect
+func (c *Collector) collectorBlocks() config.CollectorsConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.blocks
+}
+
+// recordRun keeps the outcome of a collector's run for CollectorStatuses
+func (c *Collector) recordRun(name string, started time.Time, err error) {
+	run := collectorRun{at: started, duration: time.Since(started)}
+	if err != nil {
+		run.err = err.Error()
+	}
+	if c.durations != nil {
+		c.durations.ObserveCollectorDuration(name, run.duration)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.runs == nil {
+		c.runs = make(map[string]collectorRun)
+	}
+	c.runs[name] = run
+}
+
+// CollectorStatuses lists every collector registered for this configuration with its settings and most
+// recent run, sorted by name
+func (c *Collector) CollectorStatuses() []CollectorStatus {
+	blocks := c.collectorBlocks()
+	collectors := c.collectors()
+
+	statuses := make([]CollectorStatus, 0, len(collectors))
+	for _, collector := range collectors {
+		block := blocks.Block(collector.name)
+		status := CollectorStatus{
+			Name:           collector.name,
+			Priority:       collector.priority.String(),
+			DisabledReason: c.disabledReason(collector.name),
+			FailurePolicy:  config.CollectorFailurePolicyFail,
+		}
+		if status.DisabledReason == "" && !block.Enabled {
+			status.DisabledReason = collectorDisabledByConfig
+		}
+		status.Enabled = status.DisabledReason == ""
+		if block.SkipsOnFailure(collector.name) {
+			status.FailurePolicy = config.CollectorFailurePolicySkip
+		}
+		if block.Timeout > 0 {
+			status.Timeout = block.Timeout.String()
+		}
+
+		c.mu.Lock()
+		run, ran := c.runs[collector.name]
+		c.mu.Unlock()
+		if ran {
+			at := run.at
+			status.LastRun = &at
+			status.LastDuration = run.duration.Round(time.Millisecond).String()
+			status.LastError = run.err
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
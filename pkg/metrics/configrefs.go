@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// createContainerConfigError is the waiting reason kubelet reports when a container's environment
+// references a ConfigMap or Secret, or a key of one, that does not exist
+const createContainerConfigError = "CreateContainerConfigError"
+
+// Kubelet messages naming a missing ConfigMap or Secret, e.g. `secret "tls-cert" not found` in a
+// CreateContainerConfigError or in a FailedMount event, or a missing key such as
+// `couldn't find key tls.crt in Secret payments/tls-cert`
+var (
+	missingObjectPattern = regexp.MustCompile(`(?i)\b(secret|configmap)s? "([^"]+)" not found`)
+	missingKeyPattern    = regexp.MustCompile(`(?i)couldn't find key (\S+) in (secret|configmap) ([^/\s]+)/(\S+)`)
+)
+
+// configReferenceFailure returns what a kubelet message says is missing, such as
+// "secret payments/tls-cert missing", or false when it names no missing ConfigMap or Secret
+func configReferenceFailure(namespace, message string) (string, bool) {
+	if match := missingKeyPattern.FindStringSubmatch(message); match != nil {
+		return fmt.Sprintf("key %s missing from %s %s/%s", match[1], strings.ToLower(match[2]), match[3], strings.TrimRight(match[4], ".,;")), true
+	}
+	if match := missingObjectPattern.FindStringSubmatch(message); match != nil {
+		return fmt.Sprintf("%s %s/%s missing", strings.ToLower(match[1]), namespace, match[2]), true
+	}
+	return "", false
+}
+
+// podConfigReferenceFailure returns what is missing for a pod whose container is waiting with
+// CreateContainerConfigError. A message that names no object is reported as is.
+func podConfigReferenceFailure(pod corev1.Pod) (string, bool) {
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		waiting := status.State.Waiting
+		if waiting == nil || waiting.Reason != createContainerConfigError {
+			continue
+		}
+		if failure, ok := configReferenceFailure(pod.Namespace, waiting.Message); ok {
+			return failure, true
+		}
+		return fmt.Sprintf("%s: %s", createContainerConfigError, waiting.Message), true
+	}
+	return "", false
+}
+
+// collectConfigReferenceMetrics counts pods that cannot start because a ConfigMap or Secret they reference
+// is missing: pods waiting with CreateContainerConfigError, from the pod tally, and pods still Pending whose
+// recent FailedMount events name a missing ConfigMap or Secret. Offenders name each missing object.
+func (c *Collector) collectConfigReferenceMetrics(ctx context.Context, pods *podTally) ([]MetricValue, error) {
+	if pods == nil {
+		// Events are only counted for pods the tally shows still Pending
+		return nil, nil
+	}
+
+	failures := make(map[string]string, len(pods.configReferenceFailures))
+	for pod, failure := range pods.configReferenceFailures {
+		failures[pod] = failure
+	}
+
+	callCtx, cancel := c.callContext(ctx)
+	events, err := c.kubeClient.CoreV1().Events("").List(callCtx, metav1.ListOptions{
+		FieldSelector: "reason=FailedMount,involvedObject.kind=Pod",
+	})
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	cutoff := time.Now().Add(-c.config.AdmissionEventWindow)
+	for _, event := range events.Items {
+		key := event.InvolvedObject.Namespace + "/" + event.InvolvedObject.Name
+		if _, pending := pods.pendingPodKeys[key]; !pending || eventTime(event).Before(cutoff) {
+			continue
+		}
+		if _, counted := failures[key]; counted {
+			continue
+		}
+		if failure, ok := configReferenceFailure(event.InvolvedObject.Namespace, event.Message); ok {
+			failures[key] = failure
+		}
+	}
+
+	return []MetricValue{configReferenceMetric(failures)}, nil
+}
+
+// configReferenceMetric builds the metric from failures keyed by pod, with one offender per missing
+// object listing how many pods it blocks
+func configReferenceMetric(failures map[string]string) MetricValue {
+	podsPerFailure := make(map[string]int)
+	for _, failure := range failures {
+		podsPerFailure[failure]++
+	}
+
+	offenders := make([]string, 0, len(podsPerFailure))
+	for failure, count := range podsPerFailure {
+		if count == 1 {
+			offenders = append(offenders, failure+" (1 pod)")
+		} else {
+			offenders = append(offenders, fmt.Sprintf("%s (%d pods)", failure, count))
+		}
+	}
+	sort.Strings(offenders)
+
+	return MetricValue{Type: ConfigReferenceFailuresMetric, Value: len(failures), Offenders: offenders}
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// collectDaemonSetMetrics reports the percentage of daemon pods that should be scheduled but are not ready,
+// across the DaemonSets selected by collection.daemonSets. kube-proxy or a CNI DaemonSet failing to become
+// ready on new nodes is what breaks networking during node image upgrades.
+func (c *Collector) collectDaemonSetMetrics(ctx context.Context) ([]MetricValue, error) {
+	selection := c.config.DaemonSets
+
+	callCtx, cancel := c.callContext(ctx)
+	daemonSets, err := c.kubeClient.AppsV1().DaemonSets(selection.Namespace).List(callCtx, metav1.ListOptions{LabelSelector: selection.Selector})
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list daemonsets: %w", err)
+	}
+
+	selected := daemonSets.Items[:0]
+	for _, daemonSet := range daemonSets.Items {
+		// A configured namespace is counted even when collection.namespaces does not monitor it
+		if selection.Namespace == "" && !c.monitorsNamespace(daemonSet.Namespace) {
+			continue
+		}
+		selected = append(selected, daemonSet)
+	}
+	return []MetricValue{daemonSetReadiness(selected)}, nil
+}
+
+// daemonSetReadiness compares each DaemonSet's ready pods with the number it should have scheduled. A
+// DaemonSet whose node selector matches no node should schedule none and adds nothing to either count.
+func daemonSetReadiness(daemonSets []appsv1.DaemonSet) MetricValue {
+	var desired, notReady int64
+	var offenders []string
+	for _, daemonSet := range daemonSets {
+		scheduled := int64(daemonSet.Status.DesiredNumberScheduled)
+		missing := scheduled - int64(daemonSet.Status.NumberReady)
+		if scheduled <= 0 {
+			continue
+		}
+		desired += scheduled
+		if missing > 0 {
+			notReady += missing
+			offenders = append(offenders, fmt.Sprintf("%s/DaemonSet/%s: %d of %d pods ready", daemonSet.Namespace, daemonSet.Name, daemonSet.Status.NumberReady, scheduled))
+		}
+	}
+	sort.Strings(offenders)
+
+	metric := percentMetric(DaemonSetNotReadyPercentMetric, notReady, desired)
+	metric.Offenders = offenders
+	return metric
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"aks-health-monitor/pkg/config"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// daemonSet returns a DaemonSet that should schedule desired pods, of which ready are ready
+func daemonSet(namespace, name string, desired, ready int32, labels map[string]string) *appsv1.DaemonSet {
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+		Status:     appsv1.DaemonSetStatus{DesiredNumberScheduled: desired, NumberReady: ready},
+	}
+}
+
+func TestDaemonSetReadiness(t *testing.T) {
+	tests := []struct {
+		name          string
+		daemonSets    []*appsv1.DaemonSet
+		want          string
+		wantOffenders []string
+	}{
+		{name: "none", want: "0% (0/0)"},
+		{
+			name:       "scheduled nowhere",
+			daemonSets: []*appsv1.DaemonSet{daemonSet("kube-system", "gpu-driver", 0, 0, nil)},
+			want:       "0% (0/0)",
+		},
+		{
+			name: "scheduled nowhere with stale ready pods",
+			// A DaemonSet whose nodes just went away may still count ready pods
+			daemonSets: []*appsv1.DaemonSet{daemonSet("kube-system", "gpu-driver", 0, 2, nil), daemonSet("kube-system", "kube-proxy", 4, 4, nil)},
+			want:       "0% (0/4)",
+		},
+		{
+			name:       "all ready",
+			daemonSets: []*appsv1.DaemonSet{daemonSet("kube-system", "kube-proxy", 4, 4, nil), daemonSet("kube-system", "azure-cns", 4, 4, nil)},
+			want:       "0% (0/8)",
+		},
+		{
+			name: "new nodes not ready",
+			daemonSets: []*appsv1.DaemonSet{
+				daemonSet("kube-system", "kube-proxy", 5, 4, nil),
+				daemonSet("kube-system", "azure-cns", 5, 2, nil),
+				daemonSet("kube-system", "gpu-driver", 0, 0, nil),
+			},
+			want:          "40% (4/10)",
+			wantOffenders: []string{"kube-system/DaemonSet/azure-cns: 2 of 5 pods ready", "kube-system/DaemonSet/kube-proxy: 4 of 5 pods ready"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			daemonSets := make([]appsv1.DaemonSet, 0, len(test.daemonSets))
+			for _, daemonSet := range test.daemonSets {
+				daemonSets = append(daemonSets, *daemonSet)
+			}
+			metric := daemonSetReadiness(daemonSets)
+			if got := metric.String(); got != test.want {
+				t.Errorf("daemonset_not_ready_percent = %s, want %s", got, test.want)
+			}
+			if strings.Join(metric.Offenders, "; ") != strings.Join(test.wantOffenders, "; ") {
+				t.Errorf("offenders = %v, want %v", metric.Offenders, test.wantOffenders)
+			}
+		})
+	}
+}
+
+func TestCollectDaemonSetMetricsSelection(t *testing.T) {
+	critical := map[string]string{"criticality": "high"}
+	objects := []runtime.Object{
+		daemonSet("kube-system", "kube-proxy", 4, 2, critical),
+		daemonSet("kube-system", "log-shipper", 4, 0, nil),
+		daemonSet("monitoring", "node-exporter", 4, 3, critical),
+		daemonSet("sandbox", "debug-agent", 4, 0, nil),
+	}
+
+	tests := []struct {
+		name      string
+		selection config.DaemonSetSelection
+		want      string
+	}{
+		{name: "monitored namespaces", want: "62% (5/8)"},
+		{name: "kube-system, though not monitored", selection: config.DaemonSetSelection{Namespace: "kube-system"}, want: "75% (6/8)"},
+		{name: "critical in monitored namespaces", selection: config.DaemonSetSelection{Selector: "criticality=high"}, want: "25% (1/4)"},
+		{name: "critical in kube-system", selection: config.DaemonSetSelection{Namespace: "kube-system", Selector: "criticality=high"}, want: "50% (2/4)"},
+		{name: "no match", selection: config.DaemonSetSelection{Namespace: "kube-system", Selector: "criticality=low"}, want: "0% (0/0)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := testCollectionConfig()
+			cfg.Namespaces.Exclude = []string{"kube-system"}
+			cfg.DaemonSets = test.selection
+			metrics, err := NewCollector(fake.NewSimpleClientset(objects...), cfg).collectDaemonSetMetrics(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(metrics) != 1 || metrics[0].Type != DaemonSetNotReadyPercentMetric {
+				t.Fatalf("metrics = %v, want daemonset_not_ready_percent alone", metrics)
+			}
+			if got := metrics[0].String(); got != test.want {
+				t.Errorf("daemonset_not_ready_percent = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
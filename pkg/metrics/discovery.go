@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+)
+
+// apiResource identifies a resource a collector needs
+type apiResource struct {
+	groupVersion string
+	resource     string
+}
+
+// String renders the resource as group/version/resource
+func (r apiResource) String() string {
+	return r.groupVersion + "/" + r.resource
+}
+
+// apiAvailability tracks which collectors are disabled because the API server does not serve
+// the resources they need
+type apiAvailability struct {
+	mu       sync.Mutex
+	disabled map[string]string
+}
+
+// DisabledCollector reports a collector turned off because a required API is not served
+type DisabledCollector struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// Discover checks which API resources the server serves and enables or disables collectors accordingly.
+// Changes are logged once when they happen, so collectors re-enable when an API appears later.
+// Discovery errors other than a missing group leave the previous state in place.
+func (c *Collector) Discover(ctx context.Context) error {
+	served := make(map[string]map[string]bool)
+	disabled := make(map[string]string)
+
+	for _, collector := range c.collectors() {
+		var missing []string
+		for _, required := range collector.requires {
+			resources, ok := served[required.groupVersion]
+			if !ok {
+				// Discovery requests take no context, so cancellation is checked between them
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				list, err := c.kubeClient.Discovery().ServerResourcesForGroupVersion(required.groupVersion)
+				switch {
+				case apierrors.IsNotFound(err):
+					resources = map[string]bool{}
+				case err != nil:
+					return fmt.Errorf("failed to discover %s: %w", required.groupVersion, err)
+				default:
+					resources = make(map[string]bool, len(list.APIResources))
+					for _, resource := range list.APIResources {
+						resources[resource.Name] = true
+					}
+				}
+				served[required.groupVersion] = resources
+			}
+			if !resources[required.resource] {
+				missing = append(missing, required.String())
+			}
+		}
+		if len(missing) > 0 {
+			disabled[collector.name] = "API not served: " + strings.Join(missing, ", ")
+		}
+	}
+
+	c.apis.mu.Lock()
+	defer c.apis.mu.Unlock()
+
+	for name, reason := range disabled {
+		if _, was := c.apis.disabled[name]; !was {
+			klog.Warningf("Disabling %s collector: %s", name, reason)
+		}
+	}
+	for name := range c.apis.disabled {
+		if _, still := disabled[name]; !still {
+			klog.Infof("Re-enabling %s collector: required APIs are now served", name)
+		}
+	}
+	c.apis.disabled = disabled
+
+	return nil
+}
+
+// disabledReason returns why a collector is disabled, or "" if it is enabled
+func (c *Collector) disabledReason(name string) string {
+	c.apis.mu.Lock()
+	defer c.apis.mu.Unlock()
+	return c.apis.disabled[name]
+}
+
+// DisabledCollectors lists collectors disabled by API discovery, sorted by name
+func (c *Collector) DisabledCollectors() []DisabledCollector {
+	c.apis.mu.Lock()
+	defer c.apis.mu.Unlock()
+
+	disabled := make([]DisabledCollector, 0, len(c.apis.disabled))
+	for name, reason := range c.apis.disabled {
+		disabled = append(disabled, DisabledCollector{Name: name, Reason: reason})
+	}
+	sort.Slice(disabled, func(i, j int) bool { return disabled[i].Name < disabled[j].Name })
+	return disabled
+}
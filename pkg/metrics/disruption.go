@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BlockingDisruptionBudgets lists the PodDisruptionBudgets in monitored namespaces that currently allow no
+// disruption, so a node drain would stall on their pods. Budgets selecting no pods are left out. Each
+// entry names the budget as namespace/name with its healthy and desired pod counts, sorted by name.
+func (c *Collector) BlockingDisruptionBudgets(ctx context.Context) ([]string, error) {
+	callCtx, cancel := c.callContext(ctx)
+	budgets, err := c.kubeClient.PolicyV1().PodDisruptionBudgets("").List(callCtx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pod disruption budgets: %w", err)
+	}
+
+	var blocking []string
+	for _, budget := range budgets.Items {
+		if !c.monitorsNamespace(budget.Namespace) {
+			continue
+		}
+		if budget.Status.ExpectedPods == 0 || budget.Status.DisruptionsAllowed > 0 {
+			continue
+		}
+		blocking = append(blocking, fmt.Sprintf("%s/%s: no disruptions allowed (%d healthy, %d desired)",
+			budget.Namespace, budget.Name, budget.Status.CurrentHealthy, budget.Status.DesiredHealthy))
+	}
+	sort.Strings(blocking)
+	return blocking, nil
+}
@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// maxTransitionsPerPod bounds the transitions remembered per pod; a pod past it is flapping anyway
+const maxTransitionsPerPod = 64
+
+// flapTracker counts the Ready condition transitions of pods within a sliding window, fed by the pod
+// informer's update events. Transitions older than the window are dropped as new ones arrive and when
+// counted, and pods without recent transitions are forgotten, so state is bounded by the pods that changed
+// readiness within the window.
+type flapTracker struct {
+	mu          sync.Mutex
+	window      time.Duration
+	transitions map[string][]time.Time
+	now         func() time.Time
+}
+
+// newFlapTracker creates a tracker for the configured window
+func newFlapTracker(cfg config.FlappingConfig) *flapTracker {
+	return &flapTracker{window: cfg.Window, transitions: make(map[string][]time.Time), now: time.Now}
+}
+
+// handler returns the informer event handler feeding the tracker
+func (t *flapTracker) handler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPod, okOld := oldObj.(*corev1.Pod)
+			newPod, okNew := newObj.(*corev1.Pod)
+			if okOld && okNew && isPodReady(*oldPod) != isPodReady(*newPod) {
+				t.record(newPod.Namespace+"/"+newPod.Name, t.now())
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj); err == nil {
+				t.forget(key)
+			}
+		},
+	}
+}
+
+// record adds a transition of the pod at the given time
+func (t *flapTracker) record(key string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	times := append(t.recent(t.transitions[key], at), at)
+	if len(times) > maxTransitionsPerPod {
+		times = times[len(times)-maxTransitionsPerPod:]
+	}
+	t.transitions[key] = times
+}
+
+// forget drops a deleted pod
+func (t *flapTracker) forget(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.transitions, key)
+}
+
+// flapping returns the number of transitions within the window of each pod that had at least
+// minTransitions, and forgets pods with none
+func (t *flapTracker) flapping(now time.Time, minTransitions int) map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	flapping := make(map[string]int)
+	for key, times := range t.transitions {
+		times = t.recent(times, now)
+		if len(times) == 0 {
+			delete(t.transitions, key)
+			continue
+		}
+		t.transitions[key] = times
+		if len(times) >= minTransitions {
+			flapping[key] = len(times)
+		}
+	}
+	return flapping
+}
+
+// recent returns the transitions within the window before now
+func (t *flapTracker) recent(times []time.Time, now time.Time) []time.Time {
+	cutoff := now.Add(-t.window)
+	for len(times) > 0 && !times[0].After(cutoff) {
+		times = times[1:]
+	}
+	return times
+}
+
+// collectFlappingMetrics reports the monitored pods whose readiness flapped within the window, most
+// transitions first. It reports nothing until the informers have synced, since transitions are only seen
+// from then on.
+func (c *Collector) collectFlappingMetrics(ctx context.Context) ([]MetricValue, error) {
+	if !c.cacheSynced() {
+		return nil, nil
+	}
+
+	var pods []string
+	counts := make(map[string]int)
+	for key, count := range c.informers.flaps.flapping(c.informers.flaps.now(), c.config.Flapping.MinTransitions) {
+		namespace, _, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil || !c.monitorsNamespace(namespace) {
+			continue
+		}
+		pods = append(pods, key)
+		counts[key] = count
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		if counts[pods[i]] != counts[pods[j]] {
+			return counts[pods[i]] > counts[pods[j]]
+		}
+		return pods[i] < pods[j]
+	})
+
+	offenders := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		offenders = append(offenders, fmt.Sprintf("%s (%d transitions)", pod, counts[pod]))
+	}
+	return []MetricValue{{Type: FlappingPodsMetric, Value: len(pods), Offenders: offenders}}, nil
+}
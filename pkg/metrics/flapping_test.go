@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+// readinessPod returns a pod of the payments namespace with the given Ready status
+func readinessPod(name string, ready corev1.ConditionStatus) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: name},
+		Status:     corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}}},
+	}
+}
+
+// flapFixture drives a flap tracker's informer handler on a fake clock
+type flapFixture struct {
+	tracker *flapTracker
+	handler cache.ResourceEventHandler
+	now     time.Time
+}
+
+func newFlapFixture(window time.Duration) *flapFixture {
+	f := &flapFixture{now: time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)}
+	f.tracker = newFlapTracker(config.FlappingConfig{Window: window, MinTransitions: 6})
+	f.tracker.now = func() time.Time { return f.now }
+	f.handler = f.tracker.handler()
+	return f
+}
+
+// flap delivers transitions updates of the pod, one every interval, alternating its readiness
+func (f *flapFixture) flap(name string, transitions int, interval time.Duration) {
+	ready := corev1.ConditionTrue
+	for i := 0; i < transitions; i++ {
+		next := corev1.ConditionFalse
+		if ready == corev1.ConditionFalse {
+			next = corev1.ConditionTrue
+		}
+		f.now = f.now.Add(interval)
+		f.handler.OnUpdate(readinessPod(name, ready), readinessPod(name, next))
+		ready = next
+	}
+}
+
+func TestFlapTrackerCountsTransitions(t *testing.T) {
+	f := newFlapFixture(5 * time.Minute)
+
+	f.flap("api-0", 8, 20*time.Second)
+	f.flap("api-1", 3, 20*time.Second)
+	// Updates that leave readiness alone, such as a status heartbeat or a label change, are not transitions
+	for i := 0; i < 10; i++ {
+		f.handler.OnUpdate(readinessPod("api-2", corev1.ConditionTrue), readinessPod("api-2", corev1.ConditionTrue))
+	}
+
+	if got, want := f.tracker.flapping(f.now, 6), map[string]int{"payments/api-0": 8}; !reflect.DeepEqual(got, want) {
+		t.Errorf("flapping = %v, want %v", got, want)
+	}
+	if got, want := f.tracker.flapping(f.now, 2), map[string]int{"payments/api-0": 8, "payments/api-1": 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("flapping with 2 transitions = %v, want %v", got, want)
+	}
+}
+
+func TestFlapTrackerExpiry(t *testing.T) {
+	f := newFlapFixture(5 * time.Minute)
+
+	// Six transitions a minute apart: the first falls out of the window as the sixth arrives
+	f.flap("api-0", 6, time.Minute)
+	if got := f.tracker.flapping(f.now, 6); len(got) != 0 {
+		t.Errorf("flapping = %v, want none: only 5 transitions within the 5m window", got)
+	}
+	if got := f.tracker.flapping(f.now, 5); got["payments/api-0"] != 5 {
+		t.Errorf("flapping = %v, want payments/api-0 with 5 transitions", got)
+	}
+
+	// The pod settles: its transitions age out and the pod is forgotten
+	f.now = f.now.Add(5 * time.Minute)
+	if got := f.tracker.flapping(f.now, 1); len(got) != 0 {
+		t.Errorf("flapping after the window = %v, want none", got)
+	}
+	if len(f.tracker.transitions) != 0 {
+		t.Errorf("tracker remembers %d pods after their transitions expired, want 0", len(f.tracker.transitions))
+	}
+}
+
+func TestFlapTrackerBoundsState(t *testing.T) {
+	f := newFlapFixture(time.Hour)
+
+	f.flap("api-0", 3*maxTransitionsPerPod, time.Second)
+	if got := len(f.tracker.transitions["payments/api-0"]); got != maxTransitionsPerPod {
+		t.Errorf("transitions kept = %d, want %d", got, maxTransitionsPerPod)
+	}
+
+	f.flap("api-1", 10, time.Second)
+	f.handler.OnDelete(readinessPod("api-0", corev1.ConditionTrue))
+	f.handler.OnDelete(cache.DeletedFinalStateUnknown{Key: "payments/api-1", Obj: readinessPod("api-1", corev1.ConditionTrue)})
+	if len(f.tracker.transitions) != 0 {
+		t.Errorf("tracker remembers %v after the pods were deleted, want nothing", f.tracker.transitions)
+	}
+}
+
+func TestCollectFlappingMetrics(t *testing.T) {
+	cfg := testCollectionConfig()
+	cfg.Source = config.CollectionSourceInformer
+	cfg.CacheSyncTimeout = 10 * time.Second
+	cfg.Flapping = config.FlappingConfig{Window: 5 * time.Minute, MinTransitions: 6}
+	collector := NewCollector(fake.NewSimpleClientset(), cfg)
+
+	// Transitions are only seen once the informers run, so nothing is reported before
+	if metrics, err := collector.collectFlappingMetrics(context.Background()); err != nil || metrics != nil {
+		t.Errorf("before the informers started: %v (error %v), want nothing", metrics, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	collector.StartInformers(ctx)
+	if !collector.waitForCacheSync(ctx) {
+		t.Fatal("informer caches did not sync")
+	}
+	now := time.Now()
+	collector.informers.flaps.now = func() time.Time { return now }
+	handler := collector.informers.flaps.handler()
+	for _, pod := range []struct {
+		name        string
+		transitions int
+	}{{"web-0", 6}, {"api-0", 9}, {"api-1", 6}, {"worker-0", 2}} {
+		for i := 0; i < pod.transitions; i++ {
+			now = now.Add(10 * time.Second)
+			handler.OnUpdate(readinessPod(pod.name, corev1.ConditionTrue), readinessPod(pod.name, corev1.ConditionFalse))
+		}
+	}
+
+	metrics, err := collector.collectFlappingMetrics(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flapping, ok := findMetric(metrics, FlappingPodsMetric)
+	want := []string{"payments/api-0 (9 transitions)", "payments/api-1 (6 transitions)", "payments/web-0 (6 transitions)"}
+	if !ok || flapping.Value != 3 || !reflect.DeepEqual(flapping.Offenders, want) {
+		t.Errorf("%s = %d %v (collected %v), want 3 %v", FlappingPodsMetric, flapping.Value, flapping.Offenders, ok, want)
+	}
+}
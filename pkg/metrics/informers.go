@@ -0,0 +1,194 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	batchlisters "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// informerCache serves pods, nodes and jobs from shared informers, so cycles read local caches kept
+// current by watches instead of listing the cluster during the upgrade that already loads the API server.
+// Until the informers are started and synced, the collector lists directly.
+type informerCache struct {
+	factory     informers.SharedInformerFactory
+	pods        corelisters.PodLister
+	nodes       corelisters.NodeLister
+	jobs        batchlisters.JobLister
+	hasSynced   []cache.InformerSynced
+	syncTimeout time.Duration
+
+	// flaps counts pod readiness transitions from the pod informer's update events
+	flaps *flapTracker
+
+	mu      sync.Mutex
+	started bool
+}
+
+// newInformerCache registers the pod, node and job informers without starting them
+func newInformerCache(kubeClient kubernetes.Interface, cfg config.CollectionConfig) *informerCache {
+	factory := informers.NewSharedInformerFactory(kubeClient, 0)
+	podInformer := factory.Core().V1().Pods()
+	nodeInformer := factory.Core().V1().Nodes()
+	jobInformer := factory.Batch().V1().Jobs()
+
+	caches := &informerCache{
+		factory:     factory,
+		pods:        podInformer.Lister(),
+		nodes:       nodeInformer.Lister(),
+		jobs:        jobInformer.Lister(),
+		syncTimeout: cfg.CacheSyncTimeout,
+		flaps:       newFlapTracker(cfg.Flapping),
+	}
+	if _, err := podInformer.Informer().AddEventHandler(caches.flaps.handler()); err != nil {
+		klog.Warningf("Failed to register the readiness flapping handler: %v", err)
+	}
+	for _, informer := range []interface {
+		SetTransform(handler cache.TransformFunc) error
+		HasSynced() bool
+	}{podInformer.Informer(), nodeInformer.Informer(), jobInformer.Informer()} {
+		if err := informer.SetTransform(stripManagedFields); err != nil {
+			klog.Warningf("Failed to set informer transform: %v", err)
+		}
+		caches.hasSynced = append(caches.hasSynced, informer.HasSynced)
+	}
+	return caches
+}
+
+// stripManagedFields drops managed fields, which the collector never reads, before objects are cached
+func stripManagedFields(obj interface{}) (interface{}, error) {
+	if accessor, err := meta.Accessor(obj); err == nil {
+		accessor.SetManagedFields(nil)
+	}
+	return obj, nil
+}
+
+// StartInformers starts the pod, node and job informers when collection.source is informer. They run until
+// ctx is cancelled. Collections before the caches have synced list directly.
+func (c *Collector) StartInformers(ctx context.Context) {
+	if c.informers == nil {
+		return
+	}
+	c.informers.mu.Lock()
+	defer c.informers.mu.Unlock()
+	if c.informers.started {
+		return
+	}
+	c.informers.started = true
+	klog.Info("Starting pod, node and job informers")
+	c.informers.factory.Start(ctx.Done())
+}
+
+// waitForCacheSync waits up to the configured timeout for the informer caches to sync and reports
+// whether they did. It returns false at once when the informers were never started.
+func (c *Collector) waitForCacheSync(ctx context.Context) bool {
+	if c.informers == nil {
+		return false
+	}
+	c.informers.mu.Lock()
+	started := c.informers.started
+	c.informers.mu.Unlock()
+	if !started {
+		return false
+	}
+	if c.cacheSynced() {
+		return true
+	}
+
+	syncCtx, cancel := context.WithTimeout(ctx, c.informers.syncTimeout)
+	defer cancel()
+	if !cache.WaitForCacheSync(syncCtx.Done(), c.informers.hasSynced...) {
+		klog.Warningf("Informer caches did not sync within %s, listing directly this cycle", c.informers.syncTimeout)
+		return false
+	}
+	return true
+}
+
+// cacheSynced reports, without waiting, whether the collector reads from synced informer caches
+func (c *Collector) cacheSynced() bool {
+	if c.informers == nil {
+		return false
+	}
+	c.informers.mu.Lock()
+	started := c.informers.started
+	c.informers.mu.Unlock()
+	if !started {
+		return false
+	}
+	for _, synced := range c.informers.hasSynced {
+		if !synced() {
+			return false
+		}
+	}
+	return true
+}
+
+// cachedPods passes the cached pods of a namespace, or of all namespaces when it is empty, to visit in
+// batches of the configured page size, leaving out Succeeded pods when those are excluded from listings
+func (c *Collector) cachedPods(namespace string, visit func(pods []corev1.Pod)) error {
+	var cached []*corev1.Pod
+	var err error
+	if namespace == "" {
+		cached, err = c.informers.pods.List(labels.Everything())
+	} else {
+		cached, err = c.informers.pods.Pods(namespace).List(labels.Everything())
+	}
+	if err != nil {
+		return err
+	}
+
+	batch := make([]corev1.Pod, 0, c.config.PodPageSize)
+	for _, pod := range cached {
+		if c.config.ExcludeSucceededPods && pod.Status.Phase == corev1.PodSucceeded {
+			continue
+		}
+		batch = append(batch, *pod)
+		if len(batch) == c.config.PodPageSize {
+			visit(batch)
+			batch = make([]corev1.Pod, 0, c.config.PodPageSize)
+		}
+	}
+	if len(batch) > 0 {
+		visit(batch)
+	}
+	return nil
+}
+
+// cachedNodes returns the cached nodes
+func (c *Collector) cachedNodes() ([]corev1.Node, error) {
+	cached, err := c.informers.nodes.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]corev1.Node, 0, len(cached))
+	for _, node := range cached {
+		nodes = append(nodes, *node)
+	}
+	return nodes, nil
+}
+
+// cachedJobs classifies the cached Jobs in monitored namespaces
+func (c *Collector) cachedJobs() (map[string]cachedJob, error) {
+	cached, err := c.informers.jobs.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	jobs := make(map[string]cachedJob)
+	for _, job := range cached {
+		if c.monitorsNamespace(job.Namespace) {
+			jobs[job.Namespace+"/"+job.Name] = classifyJob(job)
+		}
+	}
+	return jobs, nil
+}
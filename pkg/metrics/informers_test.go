@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// informerCollectionConfig returns the test collection config reading from informer caches
+func informerCollectionConfig(syncTimeout time.Duration) config.CollectionConfig {
+	cfg := testCollectionConfig()
+	cfg.Source = config.CollectionSourceInformer
+	cfg.CacheSyncTimeout = syncTimeout
+	return cfg
+}
+
+// listCalls counts the list requests for resource the fake clientset received
+func listCalls(kubeClient *fake.Clientset, resource string) int {
+	calls := 0
+	for _, action := range kubeClient.Actions() {
+		if action.GetVerb() == "list" && action.GetResource().Resource == resource {
+			calls++
+		}
+	}
+	return calls
+}
+
+// informerFixture returns one NotReady node of two and one crash-looping pod of two
+func informerFixture() []runtime.Object {
+	crashing := ownedPod("ReplicaSet", corev1.RestartPolicyAlways, corev1.PodRunning, waiting("CrashLoopBackOff"))
+	crashing.Name = "web-crashing"
+	healthy := ownedPod("ReplicaSet", corev1.RestartPolicyAlways, corev1.PodRunning, corev1.ContainerState{Running: &corev1.ContainerStateRunning{}})
+	healthy.Name = "web-healthy"
+	return []runtime.Object{readyNode("node-0", corev1.ConditionTrue), readyNode("node-1", corev1.ConditionFalse), &crashing, &healthy}
+}
+
+// collectPercents collects once and returns the not ready nodes and crashing pods percentages
+func collectPercents(t *testing.T, collector *Collector) (notReady, crashing int) {
+	t.Helper()
+	metrics, err := collector.CollectMetrics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, _ := findMetric(metrics, NotReadyNodesPercentMetric)
+	pods, _ := findMetric(metrics, CrashingPodsPercentMetric)
+	return nodes.Value, pods.Value
+}
+
+func TestInformerSourceMatchesListing(t *testing.T) {
+	listed := NewCollector(fake.NewSimpleClientset(informerFixture()...), testCollectionConfig())
+	wantNotReady, wantCrashing := collectPercents(t, listed)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	kubeClient := fake.NewSimpleClientset(informerFixture()...)
+	collector := NewCollector(kubeClient, informerCollectionConfig(10*time.Second))
+	collector.StartInformers(ctx)
+
+	notReady, crashing := collectPercents(t, collector)
+	if notReady != wantNotReady || crashing != wantCrashing {
+		t.Errorf("informer source: not ready %d%%, crashing %d%%, want %d%% and %d%% as listed", notReady, crashing, wantNotReady, wantCrashing)
+	}
+	if !collector.readsCache() {
+		t.Error("cycle listed directly, want it read from the synced caches")
+	}
+
+	// Later cycles read the caches, which watches keep current, without listing again
+	podLists, nodeLists := listCalls(kubeClient, "pods"), listCalls(kubeClient, "nodes")
+	node, err := kubeClient.CoreV1().Nodes().Get(ctx, "node-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	node.Status.Conditions[0].Status = corev1.ConditionTrue
+	if _, err := kubeClient.CoreV1().Nodes().UpdateStatus(ctx, node, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	err = wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 5*time.Second, true, func(context.Context) (bool, error) {
+		cached, err := collector.informers.nodes.Get("node-1")
+		return err == nil && nodeConditionTrue(*cached, corev1.NodeReady), nil
+	})
+	if err != nil {
+		t.Fatalf("the node cache did not observe node-1 becoming Ready: %v", err)
+	}
+	if notReady, _ := collectPercents(t, collector); notReady != 0 {
+		t.Errorf("not ready nodes = %d%% after node-1 became Ready, want 0%%", notReady)
+	}
+	if pods, nodes := listCalls(kubeClient, "pods"), listCalls(kubeClient, "nodes"); pods != podLists || nodes != nodeLists {
+		t.Errorf("lists after the caches synced: %d pods and %d nodes, want %d and %d", pods, nodes, podLists, nodeLists)
+	}
+}
+
+func TestInformerSourceListsUntilStarted(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(informerFixture()...)
+	collector := NewCollector(kubeClient, informerCollectionConfig(10*time.Second))
+
+	if notReady, crashing := collectPercents(t, collector); notReady != 50 || crashing != 50 {
+		t.Errorf("not ready %d%%, crashing %d%%, want 50%% and 50%%", notReady, crashing)
+	}
+	if collector.readsCache() || listCalls(kubeClient, "pods") == 0 || listCalls(kubeClient, "nodes") == 0 {
+		t.Errorf("read cache %v with %d pod and %d node lists, want direct lists before the informers start",
+			collector.readsCache(), listCalls(kubeClient, "pods"), listCalls(kubeClient, "nodes"))
+	}
+}
+
+func TestInformerSyncTimeoutFallsBackToListing(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(informerFixture()...)
+	// The informer's initial pod list, the first one made, fails; the reflector backs off for most of a
+	// second before listing again, so the cache is not synced when the cycle's 100ms wait ends
+	var podLists atomic.Int32
+	kubeClient.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if podLists.Add(1) == 1 {
+			return true, nil, errors.New("etcd leader changed")
+		}
+		return false, nil, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	collector := NewCollector(kubeClient, informerCollectionConfig(100*time.Millisecond))
+	collector.StartInformers(ctx)
+
+	started := time.Now()
+	notReady, crashing := collectPercents(t, collector)
+	if notReady != 50 || crashing != 50 {
+		t.Errorf("not ready %d%%, crashing %d%%, want 50%% and 50%% from direct lists", notReady, crashing)
+	}
+	if collector.readsCache() {
+		t.Error("cycle read the unsynced caches, want direct lists")
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("cycle took %s, want it to give up on the caches after the 100ms sync timeout", elapsed)
+	}
+}
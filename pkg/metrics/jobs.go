@@ -0,0 +1,219 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/metadata"
+	"k8s.io/klog/v2"
+)
+
+// maxJobGets is the number of new or changed Jobs fetched one by one in a cycle; beyond it a full list is
+// cheaper than the individual requests
+const maxJobGets = 50
+
+// jobsResource is listed for Job metadata
+var jobsResource = batchv1.SchemeGroupVersion.WithResource("jobs")
+
+// jobCache remembers the classification of each Job by namespace and name. A Job with the same UID and
+// resourceVersion has the same status, so on clusters with thousands of finished Jobs a cycle only lists
+// metadata and fetches the few Jobs that are new or changed. It is nil until the first collection.
+type jobCache struct {
+	mu   sync.Mutex
+	jobs map[string]cachedJob
+}
+
+// cachedJob is what the collector needs to know about a Job at one resourceVersion
+type cachedJob struct {
+	uid             types.UID
+	resourceVersion string
+	failed          bool
+
+	// finishedAt is when the Job completed or failed; zero while it runs
+	finishedAt time.Time
+}
+
+// UseMetadataClient lists Job metadata with client, so that Jobs unchanged since the previous cycle are
+// classified from the cache. Without it every Job is listed in full each cycle.
+func (c *Collector) UseMetadataClient(client metadata.Interface) {
+	c.metadata = client
+}
+
+// collectJobMetrics counts the Jobs in monitored namespaces that have failed pods and did not finish
+// before collection.failedJobWindow, reading them from the informer cache when the cycle uses it.
+// Otherwise, with a metadata client, unchanged Jobs are classified from the cache; without one, or when
+// too many Jobs changed since the previous cycle, every Job is listed in pages.
+func (c *Collector) collectJobMetrics(ctx context.Context) ([]MetricValue, error) {
+	since := time.Now().Add(-c.config.FailedJobWindow)
+	if c.readsCache() {
+		jobs, err := c.cachedJobs()
+		if err != nil {
+			return nil, err
+		}
+		return failedJobsMetrics(jobs, since), nil
+	}
+
+	c.jobs.mu.Lock()
+	previous := c.jobs.jobs
+	c.jobs.mu.Unlock()
+
+	// The first collection has nothing cached and lists full Jobs directly
+	err := fmt.Errorf("no Jobs cached yet")
+	var jobs map[string]cachedJob
+	if previous != nil {
+		jobs, err = c.classifyCachedJobs(ctx, previous)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		klog.V(2).Infof("Listing full Jobs: %v", err)
+		jobs, err = c.classifyAllJobs(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.jobs.mu.Lock()
+	c.jobs.jobs = jobs
+	c.jobs.mu.Unlock()
+
+	return failedJobsMetrics(jobs, since), nil
+}
+
+// failedJobsMetrics counts the classified Jobs that have failed pods and are running or finished at or
+// after since. Cached Jobs age out of the count without being fetched again.
+func failedJobsMetrics(jobs map[string]cachedJob, since time.Time) []MetricValue {
+	failedJobs := 0
+	for _, job := range jobs {
+		if job.failed && (job.finishedAt.IsZero() || !job.finishedAt.Before(since)) {
+			failedJobs++
+		}
+	}
+
+	return []MetricValue{
+		{Type: FailedJobsMetric, Value: failedJobs},
+	}
+}
+
+// classifyCachedJobs lists Job metadata and classifies the Jobs in monitored namespaces, fetching those
+// not in the cache at their current resourceVersion. It returns an error when metadata lists are not
+// available or more than maxJobGets Jobs would have to be fetched.
+func (c *Collector) classifyCachedJobs(ctx context.Context, previous map[string]cachedJob) (map[string]cachedJob, error) {
+	items, err := c.listJobMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make(map[string]cachedJob, len(items))
+	var changed []metav1.PartialObjectMetadata
+	for _, item := range items {
+		if !c.monitorsNamespace(item.Namespace) {
+			continue
+		}
+		key := item.Namespace + "/" + item.Name
+		if cached, ok := previous[key]; ok && cached.uid == item.UID && cached.resourceVersion == item.ResourceVersion {
+			jobs[key] = cached
+			continue
+		}
+		changed = append(changed, item)
+	}
+	if len(changed) > maxJobGets {
+		return nil, fmt.Errorf("%d Jobs changed since the previous cycle", len(changed))
+	}
+
+	for _, item := range changed {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		callCtx, cancel := c.callContext(ctx)
+		job, err := c.kubeClient.BatchV1().Jobs(item.Namespace).Get(callCtx, item.Name, metav1.GetOptions{})
+		cancel()
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get job %s/%s: %w", item.Namespace, item.Name, err)
+		}
+		jobs[item.Namespace+"/"+item.Name] = classifyJob(job)
+	}
+	return jobs, nil
+}
+
+// listJobMetadata lists the metadata of every Job in pages, leaving out specs and pod templates
+func (c *Collector) listJobMetadata(ctx context.Context) ([]metav1.PartialObjectMetadata, error) {
+	if c.metadata == nil {
+		return nil, fmt.Errorf("no metadata client")
+	}
+
+	var items []metav1.PartialObjectMetadata
+	options := metav1.ListOptions{Limit: int64(c.config.JobPageSize)}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		callCtx, cancel := c.callContext(ctx)
+		page, err := c.metadata.Resource(jobsResource).List(callCtx, options)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list job metadata: %w", err)
+		}
+		items = append(items, page.Items...)
+
+		if page.Continue == "" {
+			return items, nil
+		}
+		options.Continue = page.Continue
+	}
+}
+
+// classifyAllJobs lists every Job in pages and classifies those in monitored namespaces
+func (c *Collector) classifyAllJobs(ctx context.Context) (map[string]cachedJob, error) {
+	jobs := make(map[string]cachedJob)
+	options := metav1.ListOptions{Limit: int64(c.config.JobPageSize)}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		callCtx, cancel := c.callContext(ctx)
+		page, err := c.kubeClient.BatchV1().Jobs("").List(callCtx, options)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list jobs: %w", err)
+		}
+		for i := range page.Items {
+			job := &page.Items[i]
+			if c.monitorsNamespace(job.Namespace) {
+				jobs[job.Namespace+"/"+job.Name] = classifyJob(job)
+			}
+		}
+
+		if page.Continue == "" {
+			return jobs, nil
+		}
+		options.Continue = page.Continue
+	}
+}
+
+// classifyJob records whether a Job has failed pods and when it finished: at status.completionTime, or,
+// for a Job that failed and so has none, at the transition of its Failed condition
+func classifyJob(job *batchv1.Job) cachedJob {
+	cached := cachedJob{uid: job.UID, resourceVersion: job.ResourceVersion, failed: job.Status.Failed > 0}
+	if job.Status.CompletionTime != nil {
+		cached.finishedAt = job.Status.CompletionTime.Time
+		return cached
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			cached.finishedAt = condition.LastTransitionTime.Time
+		}
+	}
+	return cached
+}
@@ -0,0 +1,405 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/metadata"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// job returns a Job with failed failed pods; finished, when not zero, is when it failed
+func job(namespace, name string, failed int32, finished time.Time) *batchv1.Job {
+	job := &batchv1.Job{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "batch/v1", Kind: "Job"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, UID: types.UID(namespace + "-" + name), ResourceVersion: "1"},
+		Status:     batchv1.JobStatus{Failed: failed},
+	}
+	if !finished.IsZero() {
+		job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, LastTransitionTime: metav1.NewTime(finished)}}
+	}
+	return job
+}
+
+// completed marks a Job as completed at completedAt
+func completed(job *batchv1.Job, completedAt time.Time) *batchv1.Job {
+	completion := metav1.NewTime(completedAt)
+	job.Status.CompletionTime = &completion
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue, LastTransitionTime: completion}}
+	return job
+}
+
+// jobMetadata returns the metadata a metadata-only list reports for job
+func jobMetadata(job *batchv1.Job) *metav1.PartialObjectMetadata {
+	return &metav1.PartialObjectMetadata{TypeMeta: job.TypeMeta, ObjectMeta: job.ObjectMeta}
+}
+
+// failedJobs returns the failed_jobs value of metrics
+func failedJobs(t *testing.T, metrics []MetricValue) int {
+	t.Helper()
+	metric, ok := findMetric(metrics, FailedJobsMetric)
+	if !ok {
+		t.Fatalf("%s not reported", FailedJobsMetric)
+	}
+	return metric.Value
+}
+
+func TestFailedJobWindow(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name string
+		job  *batchv1.Job
+		want int
+	}{
+		{name: "running with failed pods", job: job("ci", "build", 2, time.Time{}), want: 1},
+		{name: "failed within the window", job: job("ci", "build", 6, now.Add(-10*time.Minute)), want: 1},
+		{name: "failed at the start of the window", job: job("ci", "build", 6, now.Add(-time.Hour+time.Minute)), want: 1},
+		{name: "failed before the window", job: job("ci", "build", 6, now.Add(-2*time.Hour)), want: 0},
+		{name: "completed after retries within the window", job: completed(job("ci", "build", 1, time.Time{}), now.Add(-5*time.Minute)), want: 1},
+		{name: "completed after retries before the window", job: completed(job("ci", "build", 1, time.Time{}), now.Add(-24*time.Hour)), want: 0},
+		{name: "completed without failures", job: completed(job("ci", "build", 0, time.Time{}), now), want: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			collector := NewCollector(fake.NewSimpleClientset(test.job), testCollectionConfig())
+			metrics, err := collector.collectJobMetrics(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := failedJobs(t, metrics); got != test.want {
+				t.Errorf("failed_jobs = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCachedJobsAgeOutOfTheWindow(t *testing.T) {
+	jobs := map[string]cachedJob{
+		"ci/old":     {failed: true, finishedAt: time.Now().Add(-2 * time.Hour)},
+		"ci/recent":  {failed: true, finishedAt: time.Now().Add(-50 * time.Minute)},
+		"ci/running": {failed: true},
+	}
+	since := time.Now().Add(-time.Hour)
+	if got := failedJobsMetrics(jobs, since)[0].Value; got != 2 {
+		t.Errorf("failed_jobs = %d, want 2: the recent and the running Job", got)
+	}
+	if got := failedJobsMetrics(jobs, since.Add(30*time.Minute))[0].Value; got != 1 {
+		t.Errorf("failed_jobs half an hour later = %d, want 1: the recent Job no longer counts", got)
+	}
+}
+
+// jobClients returns a clientset and a metadata client holding jobs, and counts the Gets of single Jobs
+func jobClients(t *testing.T, jobs ...*batchv1.Job) (*fake.Clientset, *metadatafake.FakeMetadataClient, *int) {
+	t.Helper()
+	objects := make([]runtime.Object, 0, len(jobs))
+	metadataObjects := make([]runtime.Object, 0, len(jobs))
+	for _, job := range jobs {
+		objects = append(objects, job)
+		metadataObjects = append(metadataObjects, jobMetadata(job))
+	}
+	scheme := metadatafake.NewTestScheme()
+	if err := metav1.AddMetaToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	kubeClient := fake.NewSimpleClientset(objects...)
+	gets := new(int)
+	kubeClient.PrependReactor("get", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		*gets++
+		return false, nil, nil
+	})
+	return kubeClient, metadatafake.NewSimpleMetadataClient(scheme, metadataObjects...), gets
+}
+
+// listed counts the full Job lists kubeClient served
+func listed(kubeClient *fake.Clientset) int {
+	lists := 0
+	for _, action := range kubeClient.Actions() {
+		if action.Matches("list", "jobs") {
+			lists++
+		}
+	}
+	return lists
+}
+
+func TestJobCacheFetchesOnlyChangedJobs(t *testing.T) {
+	now := time.Now()
+	kubeClient, metadataClient, gets := jobClients(t,
+		job("ci", "build-1", 3, now.Add(-time.Minute)),
+		completed(job("ci", "build-2", 0, time.Time{}), now),
+		job("ci", "build-3", 0, time.Time{}),
+	)
+	collector := NewCollector(kubeClient, testCollectionConfig())
+	collector.UseMetadataClient(metadataClient)
+	collect := func() int {
+		t.Helper()
+		metrics, err := collector.collectJobMetrics(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return failedJobs(t, metrics)
+	}
+
+	// The first cycle has nothing cached and lists full Jobs
+	if got := collect(); got != 1 || listed(kubeClient) != 1 {
+		t.Fatalf("first cycle failed_jobs = %d after %d full lists, want 1 after one", got, listed(kubeClient))
+	}
+
+	// Nothing changed: metadata only
+	if got := collect(); got != 1 || listed(kubeClient) != 1 || *gets != 0 {
+		t.Errorf("unchanged cycle failed_jobs = %d after %d full lists and %d gets, want 1 from the cache", got, listed(kubeClient), *gets)
+	}
+
+	// build-3 fails: only it is fetched again
+	failing := job("ci", "build-3", 1, time.Time{})
+	failing.ResourceVersion = "2"
+	if _, err := kubeClient.BatchV1().Jobs("ci").Update(context.Background(), failing, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := metadataClient.Tracker().Update(jobsResource, jobMetadata(failing), "ci"); err != nil {
+		t.Fatal(err)
+	}
+	if got := collect(); got != 2 || listed(kubeClient) != 1 || *gets != 1 {
+		t.Errorf("changed cycle failed_jobs = %d after %d full lists and %d gets, want 2 with one get", got, listed(kubeClient), *gets)
+	}
+}
+
+func TestJobCacheFallsBackToFullList(t *testing.T) {
+	kubeClient, metadataClient, _ := jobClients(t, job("ci", "build", 3, time.Time{}))
+	metadataClient.PrependReactor("list", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("the server could not find the requested resource")
+	})
+	collector := NewCollector(kubeClient, testCollectionConfig())
+	collector.UseMetadataClient(metadataClient)
+
+	for cycle := 1; cycle <= 2; cycle++ {
+		metrics, err := collector.collectJobMetrics(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := failedJobs(t, metrics); got != 1 {
+			t.Errorf("cycle %d failed_jobs = %d, want 1", cycle, got)
+		}
+	}
+	if got := listed(kubeClient); got != 2 {
+		t.Errorf("full lists = %d, want one per cycle while metadata lists fail", got)
+	}
+}
+
+func TestJobListsFollowPageSize(t *testing.T) {
+	server := jobAPIServer(t, ciJobs(5))
+	cfg := testCollectionConfig()
+	cfg.JobPageSize = 2
+	collector := NewCollector(server.kubeClient, cfg)
+	collector.UseMetadataClient(server.metadataClient)
+
+	for _, want := range []string{
+		"full limit=2 continue=, full limit=2 continue=2, full limit=2 continue=4",
+		"metadata limit=2 continue=, metadata limit=2 continue=2, metadata limit=2 continue=4",
+	} {
+		metrics, err := collector.collectJobMetrics(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := failedJobs(t, metrics); got != 1 {
+			t.Errorf("failed_jobs = %d, want 1", got)
+		}
+		if got := server.listed(); got != want {
+			t.Errorf("lists = %s, want %s", got, want)
+		}
+	}
+}
+
+// jobServer is an API server listing Jobs
+type jobServer struct {
+	kubeClient     kubernetes.Interface
+	metadataClient metadata.Interface
+
+	// sent counts the response bytes sent
+	sent atomic.Int64
+
+	mu sync.Mutex
+	// lists records each list request as "full" or "metadata" with its limit and continue token
+	lists []string
+}
+
+// jobAPIServer starts an API server listing jobs in pages, as full Jobs or, when asked for, as metadata
+// only
+func jobAPIServer(tb testing.TB, jobs []batchv1.Job) *jobServer {
+	tb.Helper()
+	s := &jobServer{}
+	var mu sync.Mutex
+	pages := make(map[string][]byte)
+	page := func(metadataOnly bool, limit, offset int) []byte {
+		key := fmt.Sprint(metadataOnly, limit, offset)
+		mu.Lock()
+		defer mu.Unlock()
+		if data, ok := pages[key]; ok {
+			return data
+		}
+		end := offset + limit
+		if limit == 0 || end > len(jobs) {
+			end = len(jobs)
+		}
+		listMeta := metav1.ListMeta{ResourceVersion: "1"}
+		if end < len(jobs) {
+			listMeta.Continue = strconv.Itoa(end)
+		}
+		var list interface{}
+		if metadataOnly {
+			items := make([]metav1.PartialObjectMetadata, 0, end-offset)
+			for _, job := range jobs[offset:end] {
+				items = append(items, metav1.PartialObjectMetadata{TypeMeta: metav1.TypeMeta{APIVersion: "meta.k8s.io/v1", Kind: "PartialObjectMetadata"}, ObjectMeta: job.ObjectMeta})
+			}
+			list = metav1.PartialObjectMetadataList{TypeMeta: metav1.TypeMeta{APIVersion: "meta.k8s.io/v1", Kind: "PartialObjectMetadataList"}, ListMeta: listMeta, Items: items}
+		} else {
+			list = batchv1.JobList{TypeMeta: metav1.TypeMeta{APIVersion: "batch/v1", Kind: "JobList"}, ListMeta: listMeta, Items: jobs[offset:end]}
+		}
+		data, err := json.Marshal(list)
+		if err != nil {
+			tb.Error(err)
+		}
+		pages[key] = data
+		return data
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/batch/v1/jobs" {
+			http.NotFound(w, r)
+			return
+		}
+		query := r.URL.Query()
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		offset, _ := strconv.Atoi(query.Get("continue"))
+		metadataOnly := strings.Contains(r.Header.Get("Accept"), "as=PartialObjectMetadataList")
+		kind := "full"
+		if metadataOnly {
+			kind = "metadata"
+		}
+		s.mu.Lock()
+		s.lists = append(s.lists, fmt.Sprintf("%s limit=%s continue=%s", kind, query.Get("limit"), query.Get("continue")))
+		s.mu.Unlock()
+
+		data := page(metadataOnly, limit, offset)
+		w.Header().Set("Content-Type", "application/json")
+		n, _ := w.Write(data)
+		s.sent.Add(int64(n))
+	}))
+	tb.Cleanup(server.Close)
+
+	// Without client-side throttling, a cycle's pages are timed as fast as the server answers
+	config := &rest.Config{Host: server.URL, QPS: 1e6, Burst: 1e6}
+	var err error
+	if s.kubeClient, err = kubernetes.NewForConfig(config); err != nil {
+		tb.Fatal(err)
+	}
+	if s.metadataClient, err = metadata.NewForConfig(config); err != nil {
+		tb.Fatal(err)
+	}
+	return s
+}
+
+// listed returns the list requests received since the previous call
+func (s *jobServer) listed() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lists := strings.Join(s.lists, ", ")
+	s.lists = nil
+	return lists
+}
+
+// ciJobs returns count finished CI Jobs with realistic pod templates, one in fifty failed
+func ciJobs(count int) []batchv1.Job {
+	finished := metav1.NewTime(time.Now().Add(-10 * time.Minute))
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "ci-runner", "pipeline": "build"}},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{{
+				Name:    "build",
+				Image:   "registry.example.com/ci/runner:2024.06.1",
+				Command: []string{"/bin/sh", "-c", "make test && make package && ./scripts/publish-artifacts.sh"},
+				Env: []corev1.EnvVar{
+					{Name: "CI", Value: "true"}, {Name: "GOFLAGS", Value: "-mod=readonly"},
+					{Name: "ARTIFACT_BUCKET", Value: "https://artifacts.example.com/ci"},
+				},
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("1Gi"),
+				}},
+				VolumeMounts: []corev1.VolumeMount{{Name: "workspace", MountPath: "/workspace"}},
+			}},
+			Volumes: []corev1.Volume{{Name: "workspace", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}},
+		},
+	}
+	jobs := make([]batchv1.Job, count)
+	for i := range jobs {
+		name := fmt.Sprintf("build-%05d", i)
+		jobs[i] = batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: name, UID: types.UID("uid-" + name), ResourceVersion: "1"},
+			Spec:       batchv1.JobSpec{Template: template},
+			Status:     batchv1.JobStatus{Succeeded: 1, CompletionTime: &finished},
+		}
+		if i%50 == 0 {
+			jobs[i].Status = batchv1.JobStatus{Failed: 1, Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, LastTransitionTime: finished}}}
+		}
+	}
+	return jobs
+}
+
+// BenchmarkCollectJobMetrics reports the bytes the API server sends per cycle for 20,000 finished Jobs,
+// listing full Jobs every cycle as before the cache, and listing metadata against the cache
+func BenchmarkCollectJobMetrics(b *testing.B) {
+	jobs := ciJobs(20000)
+	for _, bench := range []struct {
+		name     string
+		metadata bool
+	}{
+		{name: "full list"},
+		{name: "metadata and cache", metadata: true},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			server := jobAPIServer(b, jobs)
+			collector := NewCollector(server.kubeClient, testCollectionConfig())
+			if bench.metadata {
+				collector.UseMetadataClient(server.metadataClient)
+			}
+			// The first cycle lists full Jobs either way
+			if _, err := collector.collectJobMetrics(context.Background()); err != nil {
+				b.Fatal(err)
+			}
+			server.sent.Store(0)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				metrics, err := collector.collectJobMetrics(context.Background())
+				if err != nil {
+					b.Fatal(err)
+				}
+				if metrics[0].Value != 400 {
+					b.Fatalf("failed_jobs = %d, want 400", metrics[0].Value)
+				}
+			}
+			b.ReportMetric(float64(server.sent.Load())/float64(b.N), "bytes/cycle")
+		})
+	}
+}
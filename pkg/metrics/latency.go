@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"math"
+	"time"
+
+	"aks-health-monitor/pkg/timeutil"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// latencyBucketBounds are the upper bounds, in seconds, of the scheduling latency histogram buckets.
+// Samples above the last bound fall into an overflow bucket.
+var latencyBucketBounds = [...]float64{1, 2, 5, 10, 15, 30, 45, 60, 90, 120, 180, 300, 600, 900, 1800, 3600}
+
+// latencyHistogram is a fixed-size histogram of latencies. It uses constant memory regardless of the
+// number of samples and can be merged, so chunked collection aggregates it like the other pod counts.
+type latencyHistogram struct {
+	counts [len(latencyBucketBounds) + 1]int
+	total  int
+}
+
+// observe records one latency sample
+func (h *latencyHistogram) observe(latency time.Duration) {
+	seconds := latency.Seconds()
+	bucket := len(latencyBucketBounds)
+	for i, bound := range latencyBucketBounds {
+		if seconds <= bound {
+			bucket = i
+			break
+		}
+	}
+	h.counts[bucket]++
+	h.total++
+}
+
+// add merges another histogram into this one
+func (h *latencyHistogram) add(other latencyHistogram) {
+	for i := range h.counts {
+		h.counts[i] += other.counts[i]
+	}
+	h.total += other.total
+}
+
+// quantile estimates the q-quantile in seconds by interpolating linearly within the bucket holding it.
+// Values in the overflow bucket are reported as the last bound.
+func (h latencyHistogram) quantile(q float64) float64 {
+	if h.total == 0 {
+		return 0
+	}
+
+	rank := q * float64(h.total)
+	cumulative := 0
+	for i, count := range h.counts {
+		if float64(cumulative+count) < rank || count == 0 {
+			cumulative += count
+			continue
+		}
+		if i == len(latencyBucketBounds) {
+			break
+		}
+		lower := 0.0
+		if i > 0 {
+			lower = latencyBucketBounds[i-1]
+		}
+		upper := latencyBucketBounds[i]
+		return lower + (upper-lower)*(rank-float64(cumulative))/float64(count)
+	}
+
+	return latencyBucketBounds[len(latencyBucketBounds)-1]
+}
+
+// observeSchedulingLatency records the creation-to-scheduled latency of a pod created at or after since.
+// Pods that are not yet scheduled are skipped.
+func (h *latencyHistogram) observeSchedulingLatency(pod corev1.Pod, since time.Time) {
+	if since.IsZero() || pod.CreationTimestamp.Time.Before(since) {
+		return
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type != corev1.PodScheduled || condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		// The scheduler and the API server stamp these with different clocks
+		h.observe(timeutil.Elapsed("pod scheduling latency", pod.CreationTimestamp.Time, condition.LastTransitionTime.Time))
+		return
+	}
+}
+
+// schedulingLatencyMetrics reports the p95 scheduling latency, or nothing when fewer than minSamples
+// pods were scheduled since the operation started
+func schedulingLatencyMetrics(pods podTally, minSamples int) []MetricValue {
+	if pods.schedulingLatency.total == 0 || pods.schedulingLatency.total < minSamples {
+		return nil
+	}
+
+	return []MetricValue{
+		{Type: P95SchedulingLatencySecondsMetric, Value: int(math.Ceil(pods.schedulingLatency.quantile(0.95)))},
+	}
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// scheduledPod returns a pod created at created and scheduled at scheduled
+func scheduledPod(created, scheduled time.Time) corev1.Pod {
+	pod := *pendingPod("default", "web")
+	pod.CreationTimestamp = metav1.NewTime(created)
+	pod.Status.Conditions = []corev1.PodCondition{{
+		Type: corev1.PodScheduled, Status: corev1.ConditionTrue, LastTransitionTime: metav1.NewTime(scheduled),
+	}}
+	return pod
+}
+
+func TestObserveSchedulingLatencyWithSkewedClocks(t *testing.T) {
+	since := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		created     time.Time
+		scheduled   time.Time
+		wantSamples int
+		wantP50     float64
+	}{
+		{name: "scheduled after creation", created: since.Add(time.Minute), scheduled: since.Add(time.Minute + 4*time.Second), wantSamples: 1, wantP50: 3.5},
+		{name: "scheduler clock behind", created: since.Add(time.Minute), scheduled: since.Add(30 * time.Second), wantSamples: 1, wantP50: 0.5},
+		{name: "scheduler clock years behind", created: since.Add(time.Minute), scheduled: since.AddDate(-2, 0, 0), wantSamples: 1, wantP50: 0.5},
+		{name: "scheduler clock years ahead", created: since.Add(time.Minute), scheduled: since.AddDate(2, 0, 0), wantSamples: 1, wantP50: latencyBucketBounds[len(latencyBucketBounds)-1]},
+		{name: "created before the window", created: since.Add(-time.Second), scheduled: since, wantSamples: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var histogram latencyHistogram
+			histogram.observeSchedulingLatency(scheduledPod(test.created, test.scheduled), since)
+			if histogram.total != test.wantSamples {
+				t.Fatalf("samples = %d, want %d", histogram.total, test.wantSamples)
+			}
+			if got := histogram.quantile(0.5); got != test.wantP50 {
+				t.Errorf("p50 = %v, want %v", got, test.wantP50)
+			}
+		})
+	}
+}
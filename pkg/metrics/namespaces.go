@@ -0,0 +1,145 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NamespaceMonitorLabel opts a namespace into ("true") or out of ("false") health checks in the
+// label-driven namespace modes
+const NamespaceMonitorLabel = "aks-monitor.azure.com/monitor"
+
+// NamespaceStatus is the effective namespace selection of the most recent collection
+type NamespaceStatus struct {
+	Mode string `json:"mode"`
+	// Monitored lists the checked namespaces; it is omitted in "all" mode, where every namespace not
+	// in Excluded is checked
+	Monitored []string `json:"monitored,omitempty"`
+	Excluded  []string `json:"excluded,omitempty"`
+	// LabelsFetchedAt is when namespace labels were last listed
+	LabelsFetchedAt *time.Time `json:"labelsFetchedAt,omitempty"`
+}
+
+// namespaceFilter caches the monitored set built from namespace labels between cycles
+type namespaceFilter struct {
+	mu        sync.Mutex
+	monitored map[string]bool
+	fetchedAt time.Time
+}
+
+// usesLabels reports whether the selection depends on namespace labels
+func usesLabels(selection config.NamespaceSelection) bool {
+	return selection.Mode == config.NamespaceModeOptIn || selection.Mode == config.NamespaceModeOptOut
+}
+
+// namespaceMonitored decides whether a namespace is checked. Config lists win over labels.
+func namespaceMonitored(selection config.NamespaceSelection, name string, labels map[string]string) bool {
+	for _, excluded := range selection.Exclude {
+		if excluded == name {
+			return false
+		}
+	}
+	for _, included := range selection.Include {
+		if included == name {
+			return true
+		}
+	}
+
+	switch selection.Mode {
+	case config.NamespaceModeOptIn:
+		return labels[NamespaceMonitorLabel] == "true"
+	case config.NamespaceModeOptOut:
+		return labels[NamespaceMonitorLabel] != "false"
+	default:
+		return true
+	}
+}
+
+// refreshNamespaces re-lists namespace labels when the cache has expired. A failed refresh keeps the
+// previous set so a transient error does not widen or narrow protection.
+func (c *Collector) refreshNamespaces(ctx context.Context) error {
+	selection := c.config.Namespaces
+	if !usesLabels(selection) {
+		return nil
+	}
+
+	c.namespaces.mu.Lock()
+	fresh := c.namespaces.monitored != nil && time.Since(c.namespaces.fetchedAt) < selection.LabelCacheTTL
+	c.namespaces.mu.Unlock()
+	if fresh {
+		return nil
+	}
+
+	callCtx, cancel := c.callContext(ctx)
+	list, err := c.kubeClient.CoreV1().Namespaces().List(callCtx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		c.namespaces.mu.Lock()
+		defer c.namespaces.mu.Unlock()
+		if c.namespaces.monitored != nil {
+			return nil
+		}
+		return fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	monitored := make(map[string]bool, len(list.Items))
+	for _, namespace := range list.Items {
+		monitored[namespace.Name] = namespaceMonitored(selection, namespace.Name, namespace.Labels)
+	}
+
+	c.namespaces.mu.Lock()
+	c.namespaces.monitored = monitored
+	c.namespaces.fetchedAt = time.Now()
+	c.namespaces.mu.Unlock()
+	return nil
+}
+
+// monitorsNamespace reports whether pods, jobs and workloads in the namespace are checked. Namespaces
+// created since labels were last listed are decided without labels until the next refresh.
+func (c *Collector) monitorsNamespace(namespace string) bool {
+	selection := c.config.Namespaces
+	if usesLabels(selection) {
+		c.namespaces.mu.Lock()
+		monitored, known := c.namespaces.monitored[namespace]
+		c.namespaces.mu.Unlock()
+		if known {
+			return monitored
+		}
+	}
+	return namespaceMonitored(selection, namespace, nil)
+}
+
+// Namespaces returns the effective namespace selection
+func (c *Collector) Namespaces() NamespaceStatus {
+	selection := c.config.Namespaces
+	status := NamespaceStatus{Mode: selection.Mode}
+	if !usesLabels(selection) {
+		status.Excluded = append([]string(nil), selection.Exclude...)
+		sort.Strings(status.Excluded)
+		return status
+	}
+
+	c.namespaces.mu.Lock()
+	defer c.namespaces.mu.Unlock()
+	for namespace, monitored := range c.namespaces.monitored {
+		if monitored {
+			status.Monitored = append(status.Monitored, namespace)
+		} else {
+			status.Excluded = append(status.Excluded, namespace)
+		}
+	}
+	sort.Strings(status.Monitored)
+	sort.Strings(status.Excluded)
+	if !c.namespaces.fetchedAt.IsZero() {
+		fetchedAt := c.namespaces.fetchedAt
+		status.LabelsFetchedAt = &fetchedAt
+	}
+	return status
+}
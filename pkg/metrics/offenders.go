@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"context"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// OffendingPods returns the pods of monitored namespaces that count as crashing, failing image pulls or
+// pending, crashing pods first, at most limit of them
+func (c *Collector) OffendingPods(ctx context.Context, limit int) ([]corev1.Pod, error) {
+	type offender struct {
+		pod  corev1.Pod
+		rank int
+	}
+	var offenders []offender
+
+	err := c.listPods(ctx, "", func(pods []corev1.Pod) {
+		for _, pod := range pods {
+			if !c.monitorsNamespace(pod.Namespace) || c.isShutdownTerminated(pod) {
+				continue
+			}
+			switch {
+			case c.isPodCrashing(pod):
+				offenders = append(offenders, offender{pod: pod, rank: 0})
+			case isPodFailingImagePull(pod):
+				offenders = append(offenders, offender{pod: pod, rank: 1})
+			case pod.Status.Phase == corev1.PodPending:
+				offenders = append(offenders, offender{pod: pod, rank: 2})
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(offenders, func(i, j int) bool { return offenders[i].rank < offenders[j].rank })
+	if len(offenders) > limit {
+		offenders = offenders[:limit]
+	}
+	pods := make([]corev1.Pod, 0, len(offenders))
+	for _, o := range offenders {
+		pods = append(pods, o.pod)
+	}
+	return pods, nil
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// nodeProgressFingerprint summarises the node facts an operation changes as it progresses: which nodes
+// exist, their kubelet versions, readiness and cordoning. Any surge node, drain, reimage or upgraded
+// kubelet changes the fingerprint.
+func nodeProgressFingerprint(nodes []corev1.Node, isReady func(corev1.Node) bool) string {
+	entries := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		entries = append(entries, fmt.Sprintf("%s %s %t %t",
+			node.UID, node.Status.NodeInfo.KubeletVersion, isReady(node), node.Spec.Unschedulable))
+	}
+	sort.Strings(entries)
+
+	hash := fnv.New64a()
+	for _, entry := range entries {
+		hash.Write([]byte(entry))
+		hash.Write([]byte{0})
+	}
+	return strconv.FormatUint(hash.Sum64(), 16)
+}
+
+// NodeProgressFingerprint returns the fingerprint of the nodes from the latest node listing, or "" before
+// the first one. It changes whenever a node is added, removed, upgraded, cordoned or changes readiness.
+func (c *Collector) NodeProgressFingerprint() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nodeFingerprint
+}
@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// AgentPoolLabel is the label AKS sets on nodes with the name of their agent pool
+const AgentPoolLabel = "kubernetes.azure.com/agentpool"
+
+// vmssNodeName matches AKS VMSS node names such as aks-nodepool1-12345678-vmss00000a
+var vmssNodeName = regexp.MustCompile(`^aks-([a-z0-9]+)-\d+-vmss[0-9a-z]+$`)
+
+// nodePool returns the agent pool a node belongs to, from its label or its VMSS instance name
+func nodePool(node corev1.Node) string {
+	if pool := node.Labels[AgentPoolLabel]; pool != "" {
+		return pool
+	}
+	if match := vmssNodeName.FindStringSubmatch(node.Name); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// readySample is a pool's Ready node count at one observation
+type readySample struct {
+	at    time.Time
+	ready int
+}
+
+// poolHistory holds one pool's node creations and Ready counts within the detection window
+type poolHistory struct {
+	creations []time.Time
+	ready     []readySample
+}
+
+// reimageTracker correlates node objects across cycles to find agent pools whose nodes keep being
+// deleted and recreated. A node deleted and recreated under the same name gets a new UID, so nodes
+// are identified by UID and grouped by pool.
+type reimageTracker struct {
+	mu    sync.Mutex
+	nodes map[types.UID]struct{}
+	pools map[string]*poolHistory
+}
+
+// observe records a node listing and returns the pools suspected of a reimage loop: more than
+// maxCreations node objects created within the window while the pool's Ready count did not increase
+// over it. Nodes present in the first listing are not counted as creations.
+func (t *reimageTracker) observe(nodes []corev1.Node, isReady func(corev1.Node) bool, now time.Time, window time.Duration, maxCreations int) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	first := t.nodes == nil
+	if t.pools == nil {
+		t.pools = make(map[string]*poolHistory)
+	}
+
+	current := make(map[types.UID]struct{}, len(nodes))
+	ready := make(map[string]int)
+	for _, node := range nodes {
+		pool := nodePool(node)
+		if pool == "" {
+			continue
+		}
+		current[node.UID] = struct{}{}
+		if _, ok := ready[pool]; !ok {
+			ready[pool] = 0
+		}
+		if isReady(node) {
+			ready[pool]++
+		}
+		if _, seen := t.nodes[node.UID]; !seen && !first {
+			t.history(pool).creations = append(t.history(pool).creations, now)
+		}
+	}
+	t.nodes = current
+
+	for pool, count := range ready {
+		history := t.history(pool)
+		history.ready = append(history.ready, readySample{at: now, ready: count})
+	}
+
+	var suspected []string
+	cutoff := now.Add(-window)
+	for pool, history := range t.pools {
+		history.creations = trimTimes(history.creations, cutoff)
+		history.ready = trimSamples(history.ready, cutoff)
+		if len(history.creations) == 0 && len(history.ready) == 0 {
+			delete(t.pools, pool)
+			continue
+		}
+
+		if len(history.creations) <= maxCreations || len(history.ready) == 0 {
+			continue
+		}
+		// A pool that lost every node has no current sample; its Ready count did not increase
+		oldest, latest := history.ready[0], history.ready[len(history.ready)-1]
+		if _, listed := ready[pool]; !listed {
+			latest = readySample{at: now}
+		}
+		if latest.ready <= oldest.ready {
+			suspected = append(suspected, fmt.Sprintf("%s: %d node creations in %s, ready %d -> %d",
+				pool, len(history.creations), window, oldest.ready, latest.ready))
+		}
+	}
+
+	sort.Strings(suspected)
+	return suspected
+}
+
+// history returns the pool's history, creating it if needed
+func (t *reimageTracker) history(pool string) *poolHistory {
+	history, ok := t.pools[pool]
+	if !ok {
+		history = &poolHistory{}
+		t.pools[pool] = history
+	}
+	return history
+}
+
+// trimTimes drops times before the cutoff
+func trimTimes(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && times[i].Before(cutoff) {
+		i++
+	}
+	return times[i:]
+}
+
+// trimSamples drops samples before the cutoff
+func trimSamples(samples []readySample, cutoff time.Time) []readySample {
+	i := 0
+	for i < len(samples) && samples[i].at.Before(cutoff) {
+		i++
+	}
+	return samples[i:]
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// restartTracker remembers the container restart counts of the pods seen in the previous full listing, so
+// a collection reports the restarts since the one before rather than since each pod was created. Pods are
+// keyed by hashed UID: a recreated pod, whose counts start from zero again, is a different pod.
+type restartTracker struct {
+	mu         sync.Mutex
+	counts     map[uint64]int32
+	observedAt time.Time
+}
+
+// restartSample is one pod's restart count in a listing
+type restartSample struct {
+	restarts int32
+	created  time.Time
+}
+
+// restartListing is the restart count of every monitored pod seen by one full listing, built up page by page
+type restartListing map[uint64]restartSample
+
+// add records the pod's container restart count
+func (l restartListing) add(pod *corev1.Pod) {
+	var restarts int32
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		restarts += containerStatus.RestartCount
+	}
+	l[hashUID(string(pod.UID))] = restartSample{restarts: restarts, created: pod.CreationTimestamp.Time}
+}
+
+// observe compares a listing with the previous one and returns the restarts between them and the window
+// they cover. Pods seen both times contribute the increase of their count; pods created since the previous
+// listing contribute all their restarts; pods already running but not seen before contribute nothing, since
+// when their restarts happened is unknown. The first observation has nothing to compare against and
+// returns false.
+func (t *restartTracker) observe(current restartListing, now time.Time) (int, time.Duration, bool) {
+	counts := make(map[uint64]int32, len(current))
+	for uid, sample := range current {
+		counts[uid] = sample.restarts
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	previous, previousAt := t.counts, t.observedAt
+	t.counts, t.observedAt = counts, now
+	if previous == nil || !now.After(previousAt) {
+		return 0, 0, false
+	}
+
+	var restarts int
+	for uid, sample := range current {
+		if before, ok := previous[uid]; ok {
+			if sample.restarts > before {
+				restarts += int(sample.restarts - before)
+			}
+		} else if sample.created.After(previousAt) {
+			restarts += int(sample.restarts)
+		}
+	}
+	return restarts, now.Sub(previousAt), true
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// saturationMetrics compares pod requests against schedulable node allocatable
+func saturationMetrics(pods podTally, capacity nodeCapacity) []MetricValue {
+	return []MetricValue{
+		percentMetric(CpuRequestsSaturationPercentMetric, pods.cpuRequestMillis, capacity.cpuAllocatableMillis),
+		percentMetric(MemoryRequestsSaturationPercentMetric, pods.memoryRequestBytes, capacity.memoryAllocatableBytes),
+	}
+}
+
+// podRequests returns the effective CPU (millicores) and memory (bytes) requests of a pod, following the
+// scheduler's rule: the larger of the sum of app containers and the largest init container. Containers
+// without requests count as zero.
+func podRequests(pod corev1.Pod) (int64, int64) {
+	var cpu, memory int64
+	for _, container := range pod.Spec.Containers {
+		cpu += container.Resources.Requests.Cpu().MilliValue()
+		memory += container.Resources.Requests.Memory().Value()
+	}
+
+	for _, container := range pod.Spec.InitContainers {
+		if initCPU := container.Resources.Requests.Cpu().MilliValue(); initCPU > cpu {
+			cpu = initCPU
+		}
+		if initMemory := container.Resources.Requests.Memory().Value(); initMemory > memory {
+			memory = initMemory
+		}
+	}
+
+	return cpu, memory
+}
+
+// percentOf returns used as a whole percentage of total, or 0 when total is zero
+func percentOf(used, total int64) int {
+	if total <= 0 {
+		return 0
+	}
+	return int((used * 100) / total)
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// nodeMetricsPath lists the usage of every node from metrics-server
+const nodeMetricsPath = "/apis/metrics.k8s.io/v1beta1/nodes"
+
+// nodeMetricsList is the part of the metrics.k8s.io NodeMetricsList the collector reads
+type nodeMetricsList struct {
+	Items []struct {
+		Metadata metav1.ObjectMeta   `json:"metadata"`
+		Usage    corev1.ResourceList `json:"usage"`
+	} `json:"items"`
+}
+
+// collectUsageMetrics compares node usage from metrics-server with node allocatable. metrics-server
+// being registered but unavailable is common, so a failed read is logged and the usage metrics are left
+// out instead of failing the collection.
+func (c *Collector) collectUsageMetrics(ctx context.Context, capacity *nodeCapacity) ([]MetricValue, error) {
+	if capacity == nil {
+		return nil, nil
+	}
+
+	usage, err := c.nodeUsage(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		klog.Warningf("Skipping CPU and memory usage metrics, metrics-server is unavailable: %v", err)
+		return nil, nil
+	}
+
+	return usageMetrics(usage, capacity.nodes, c.config.MaxNodeUsage), nil
+}
+
+// nodeUsage reads the current usage of every node from the metrics.k8s.io API
+func (c *Collector) nodeUsage(ctx context.Context) (map[string]nodeResources, error) {
+	client := c.kubeClient.Discovery().RESTClient()
+	if client == nil {
+		return nil, fmt.Errorf("no REST client for the metrics.k8s.io API")
+	}
+
+	callCtx, cancel := c.callContext(ctx)
+	data, err := client.Get().AbsPath(nodeMetricsPath).DoRaw(callCtx)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list node metrics: %w", err)
+	}
+	var list nodeMetricsList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to decode node metrics: %w", err)
+	}
+
+	usage := make(map[string]nodeResources, len(list.Items))
+	for _, item := range list.Items {
+		usage[item.Metadata.Name] = nodeResources{
+			cpuMillis:   item.Usage.Cpu().MilliValue(),
+			memoryBytes: item.Usage.Memory().Value(),
+		}
+	}
+	return usage, nil
+}
+
+// usageMetrics sums usage over the nodes that report it and divides by their allocatable. Nodes without
+// usage, typically NotReady ones, are left out of both sums. With perNode, the highest single-node
+// percentages are reported too, naming the node.
+func usageMetrics(usage, allocatable map[string]nodeResources, perNode bool) []MetricValue {
+	var used, total nodeResources
+	var maxCPU, maxMemory MetricValue
+
+	for name, nodeUsage := range usage {
+		nodeAllocatable, ok := allocatable[name]
+		if !ok {
+			continue
+		}
+		used.cpuMillis += nodeUsage.cpuMillis
+		used.memoryBytes += nodeUsage.memoryBytes
+		total.cpuMillis += nodeAllocatable.cpuMillis
+		total.memoryBytes += nodeAllocatable.memoryBytes
+
+		keepHighest(&maxCPU, percentMetric(MaxNodeCpuUsagePercentMetric, nodeUsage.cpuMillis, nodeAllocatable.cpuMillis), name)
+		keepHighest(&maxMemory, percentMetric(MaxNodeMemoryUsagePercentMetric, nodeUsage.memoryBytes, nodeAllocatable.memoryBytes), name)
+	}
+
+	if total.cpuMillis == 0 && total.memoryBytes == 0 {
+		return nil
+	}
+
+	metrics := []MetricValue{
+		percentMetric(CpuUsagePercentMetric, used.cpuMillis, total.cpuMillis),
+		percentMetric(MemoryUsagePercentMetric, used.memoryBytes, total.memoryBytes),
+	}
+	if perNode {
+		metrics = append(metrics, maxCPU, maxMemory)
+	}
+	return metrics
+}
+
+// keepHighest replaces highest with candidate, naming the node, when candidate is higher. Ties go to the
+// first node by name so the reported node does not change between cycles.
+func keepHighest(highest *MetricValue, candidate MetricValue, node string) {
+	if highest.Fraction != nil && (candidate.Value < highest.Value || candidate.Value == highest.Value && node > highest.Offenders[0]) {
+		return
+	}
+	candidate.Offenders = []string{node}
+	*highest = candidate
+}
@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+// Limits that keep warning tracking bounded however many warnings the API server sends
+const (
+	// maxWarningsPerCycle is the number of distinct warnings kept between two collections
+	maxWarningsPerCycle = 50
+	// maxWarningLength truncates long warning texts
+	maxWarningLength = 512
+	// maxLoggedWarnings is the number of distinct warnings remembered so each is only logged once
+	maxLoggedWarnings = 1000
+)
+
+// APIWarning is a distinct warning returned by the API server, such as the use of a deprecated API
+type APIWarning struct {
+	Text  string `json:"text"`
+	Count int    `json:"count"`
+}
+
+// WarningRecorder collects the warnings the API server returns in Warning headers, such as deprecation
+// and admission warnings. Install it as the WarningHandler of the Kubernetes client's rest.Config; it
+// replaces client-go's default handler, which logs every occurrence.
+type WarningRecorder struct {
+	mu      sync.Mutex
+	pending map[string]int
+	dropped int
+	logged  map[uint64]struct{}
+}
+
+// NewWarningRecorder creates an empty recorder
+func NewWarningRecorder() *WarningRecorder {
+	return &WarningRecorder{
+		pending: make(map[string]int),
+		logged:  make(map[uint64]struct{}),
+	}
+}
+
+// HandleWarningHeader implements rest.WarningHandler. Only warnings with code 299 are meaningful; each
+// distinct text is logged the first time it is seen.
+func (r *WarningRecorder) HandleWarningHeader(code int, agent string, text string) {
+	if code != 299 || text == "" {
+		return
+	}
+	if len(text) > maxWarningLength {
+		text = text[:maxWarningLength]
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.pending[text]; ok || len(r.pending) < maxWarningsPerCycle {
+		r.pending[text]++
+	} else {
+		r.dropped++
+	}
+
+	hash := hashUID(text)
+	if _, ok := r.logged[hash]; !ok {
+		if len(r.logged) >= maxLoggedWarnings {
+			r.logged = make(map[uint64]struct{})
+		}
+		r.logged[hash] = struct{}{}
+		klog.Warningf("Kubernetes API warning: %s", text)
+	}
+}
+
+// drain returns the distinct warnings seen since the previous drain, most frequent first, and how many
+// distinct warnings were dropped over the cap
+func (r *WarningRecorder) drain() ([]APIWarning, int) {
+	if r == nil {
+		return nil, 0
+	}
+	r.mu.Lock()
+	pending, dropped := r.pending, r.dropped
+	r.pending, r.dropped = make(map[string]int), 0
+	r.mu.Unlock()
+
+	warnings := make([]APIWarning, 0, len(pending))
+	for text, count := range pending {
+		warnings = append(warnings, APIWarning{Text: text, Count: count})
+	}
+	sort.Slice(warnings, func(i, j int) bool {
+		if warnings[i].Count != warnings[j].Count {
+			return warnings[i].Count > warnings[j].Count
+		}
+		return warnings[i].Text < warnings[j].Text
+	})
+	return warnings, dropped
+}
+
+// warningsMetric reports the number of distinct API warnings, naming them
+func warningsMetric(warnings []APIWarning, dropped int) MetricValue {
+	offenders := make([]string, 0, len(warnings))
+	for _, warning := range warnings {
+		offenders = append(offenders, warning.Text)
+	}
+	return MetricValue{Type: DeprecationWarningsObservedMetric, Value: len(warnings) + dropped, Offenders: offenders}
+}
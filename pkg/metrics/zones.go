@@ -0,0 +1,234 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// zoneLabel is the well-known node label holding the node's availability zone
+const zoneLabel = "topology.kubernetes.io/zone"
+
+// zonalWorkload is a Deployment or StatefulSet that asks the scheduler to spread replicas across zones
+type zonalWorkload struct {
+	key      string
+	replicas int
+	selector labels.Selector
+
+	// maxSkew is the tightest zone topology spread skew, or 0 when only anti-affinity spreads the workload
+	maxSkew int
+}
+
+// collectZoneRedundancyMetrics counts zone-spread workloads whose ready replicas are currently
+// concentrated beyond their allowed skew or missing from a zone they should cover
+func (c *Collector) collectZoneRedundancyMetrics(ctx context.Context) ([]MetricValue, error) {
+	nodes, err := c.listNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeZones := make(map[string]string, len(nodes))
+	zoneSet := make(map[string]bool)
+	for _, node := range nodes {
+		if zone := node.Labels[zoneLabel]; zone != "" {
+			nodeZones[node.Name] = zone
+			zoneSet[zone] = true
+		}
+	}
+	zones := make([]string, 0, len(zoneSet))
+	for zone := range zoneSet {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+
+	// Zone redundancy is meaningless on single-zone clusters
+	if len(zones) < 2 {
+		return []MetricValue{{Type: WorkloadsLosingZoneRedundancyMetric, Value: 0}}, nil
+	}
+
+	workloads, err := c.listZonalWorkloads(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pods []corev1.Pod
+	if err := c.listPods(ctx, "", func(page []corev1.Pod) { pods = append(pods, page...) }); err != nil {
+		return nil, err
+	}
+
+	offenders := zoneRedundancyOffenders(workloads, pods, nodeZones, zones)
+
+	return []MetricValue{
+		{Type: WorkloadsLosingZoneRedundancyMetric, Value: len(offenders), Offenders: offenders},
+	}, nil
+}
+
+// listZonalWorkloads returns multi-replica Deployments and StatefulSets spread across zones
+func (c *Collector) listZonalWorkloads(ctx context.Context) ([]zonalWorkload, error) {
+	var workloads []zonalWorkload
+
+	callCtx, cancel := c.callContext(ctx)
+	deployments, err := c.kubeClient.AppsV1().Deployments("").List(callCtx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	for _, deployment := range deployments.Items {
+		if !c.monitorsNamespace(deployment.Namespace) {
+			continue
+		}
+		key := fmt.Sprintf("%s/Deployment/%s", deployment.Namespace, deployment.Name)
+		if workload, ok := newZonalWorkload(key, deployment.Spec.Replicas, deployment.Spec.Selector, deployment.Spec.Template.Spec); ok {
+			workloads = append(workloads, workload)
+		}
+	}
+
+	callCtx, cancel = c.callContext(ctx)
+	statefulSets, err := c.kubeClient.AppsV1().StatefulSets("").List(callCtx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list statefulsets: %w", err)
+	}
+	for _, statefulSet := range statefulSets.Items {
+		if !c.monitorsNamespace(statefulSet.Namespace) {
+			continue
+		}
+		key := fmt.Sprintf("%s/StatefulSet/%s", statefulSet.Namespace, statefulSet.Name)
+		if workload, ok := newZonalWorkload(key, statefulSet.Spec.Replicas, statefulSet.Spec.Selector, statefulSet.Spec.Template.Spec); ok {
+			workloads = append(workloads, workload)
+		}
+	}
+
+	return workloads, nil
+}
+
+// newZonalWorkload checks whether a pod template spreads across zones and has more than one replica
+func newZonalWorkload(key string, replicas *int32, selector *metav1.LabelSelector, spec corev1.PodSpec) (zonalWorkload, bool) {
+	desired := 1
+	if replicas != nil {
+		desired = int(*replicas)
+	}
+	if desired < 2 || selector == nil {
+		return zonalWorkload{}, false
+	}
+
+	podSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil || podSelector.Empty() {
+		return zonalWorkload{}, false
+	}
+
+	workload := zonalWorkload{key: key, replicas: desired, selector: podSelector}
+	spread := false
+	for _, constraint := range spec.TopologySpreadConstraints {
+		if constraint.TopologyKey != zoneLabel {
+			continue
+		}
+		spread = true
+		if workload.maxSkew == 0 || int(constraint.MaxSkew) < workload.maxSkew {
+			workload.maxSkew = int(constraint.MaxSkew)
+		}
+	}
+	if !spread && !hasZoneAntiAffinity(spec.Affinity) {
+		return zonalWorkload{}, false
+	}
+
+	return workload, true
+}
+
+// hasZoneAntiAffinity reports whether pod anti-affinity spreads the pods across zones
+func hasZoneAntiAffinity(affinity *corev1.Affinity) bool {
+	if affinity == nil || affinity.PodAntiAffinity == nil {
+		return false
+	}
+	for _, term := range affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+		if term.TopologyKey == zoneLabel {
+			return true
+		}
+	}
+	for _, term := range affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+		if term.PodAffinityTerm.TopologyKey == zoneLabel {
+			return true
+		}
+	}
+	return false
+}
+
+// zoneRedundancyOffenders describes every workload whose ready replicas exceed the allowed zone skew
+// or leave a zone empty although the workload has enough replicas to cover it
+func zoneRedundancyOffenders(workloads []zonalWorkload, pods []corev1.Pod, nodeZones map[string]string, zones []string) []string {
+	var offenders []string
+
+	for _, workload := range workloads {
+		namespace := strings.SplitN(workload.key, "/", 2)[0]
+
+		perZone := make(map[string]int, len(zones))
+		for _, pod := range pods {
+			if pod.Namespace != namespace || pod.DeletionTimestamp != nil || !isPodReady(pod) {
+				continue
+			}
+			if !workload.selector.Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+			if zone, ok := nodeZones[pod.Spec.NodeName]; ok {
+				perZone[zone]++
+			}
+		}
+
+		if reason := zoneRedundancyLoss(workload, perZone, zones); reason != "" {
+			offenders = append(offenders, fmt.Sprintf("%s: %s", workload.key, reason))
+		}
+	}
+
+	sort.Strings(offenders)
+	return offenders
+}
+
+// zoneRedundancyLoss returns why a workload's ready replica distribution has lost zone redundancy, or ""
+func zoneRedundancyLoss(workload zonalWorkload, perZone map[string]int, zones []string) string {
+	coverable := workload.replicas
+	if coverable > len(zones) {
+		coverable = len(zones)
+	}
+
+	covered := 0
+	var empty []string
+	minCount, maxCount := -1, 0
+	maxZone := ""
+	for _, zone := range zones {
+		count := perZone[zone]
+		if count > 0 {
+			covered++
+		} else {
+			empty = append(empty, zone)
+		}
+		if minCount < 0 || count < minCount {
+			minCount = count
+		}
+		if count > maxCount {
+			maxCount, maxZone = count, zone
+		}
+	}
+
+	if covered < coverable {
+		return fmt.Sprintf("ready replicas in %d of %d zones, none in %s", covered, len(zones), strings.Join(empty, ", "))
+	}
+	if workload.maxSkew > 0 && maxCount-minCount > workload.maxSkew {
+		return fmt.Sprintf("zone %s hosts %d ready replicas, skew %d exceeds maxSkew %d", maxZone, maxCount, maxCount-minCount, workload.maxSkew)
+	}
+	return ""
+}
+
+// isPodReady checks the pod's Ready condition
+func isPodReady(pod corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
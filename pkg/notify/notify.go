@@ -0,0 +1,334 @@
+// Package notify posts threshold violations and aborts to webhooks and Slack, so they reach an alerting
+// pipeline as they happen instead of when someone reads the Events.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+
+	"k8s.io/klog/v2"
+)
+
+// Events a notification reports
+const (
+	// EventViolation reports thresholds newly violated during an operation
+	EventViolation = "violation"
+	// EventWarning reports metrics that newly crossed their warn threshold, but not their abort threshold
+	EventWarning = "warning"
+	// EventAbort reports an operation the monitor aborted
+	EventAbort = "abort"
+	// EventMonitoringStopped reports that the monitor shut down while an operation was in progress
+	EventMonitoringStopped = "monitoringStopped"
+	// EventOperationFailed reports an operation that ended in the Failed provisioning state
+	EventOperationFailed = "operationFailed"
+)
+
+// PriorityLow marks notifications that need no immediate action, so pipelines can route them away from paging
+const PriorityLow = "low"
+
+// queueSize bounds the notifications waiting for one webhook
+const queueSize = 100
+
+// defaultRetryDelay is the wait before the first retry of a failed delivery; it doubles with each retry
+const defaultRetryDelay = time.Second
+
+// Notification is the JSON payload POSTed to each webhook
+type Notification struct {
+	Event         string      `json:"event"`
+	Priority      string      `json:"priority,omitempty"`
+	Cluster       string      `json:"cluster"`
+	ResourceGroup string      `json:"resourceGroup"`
+	Operation     string      `json:"operation"`
+	Violations    []Violation `json:"violations"`
+	TraceID       string      `json:"traceId"`
+	Timestamp     time.Time   `json:"timestamp"`
+
+	// OperationStartedAt is when the monitor first observed the operation
+	OperationStartedAt time.Time `json:"operationStartedAt,omitempty"`
+
+	// AbortError is set on abort notifications when Azure did not accept the abort
+	AbortError string `json:"abortError,omitempty"`
+
+	// DiagnosticBundle is where the evidence of an abort is captured, when diagnostics are enabled
+	DiagnosticBundle string `json:"diagnosticBundle,omitempty"`
+
+	// Reason is why monitoring stopped, on monitoringStopped notifications, and the provisioning errors
+	// ARM reported, on operationFailed notifications
+	Reason string `json:"reason,omitempty"`
+}
+
+// Violation is a metric beyond its threshold; on warning notifications, beyond its warn threshold
+type Violation struct {
+	Metric    string `json:"metric"`
+	Value     string `json:"value"`
+	Threshold string `json:"threshold"`
+	Message   string `json:"message"`
+}
+
+// Notifier delivers notifications to every configured webhook and to Slack. Each sink has its own queue
+// and goroutine, so a slow or failing one delays neither the others nor the health check.
+type Notifier struct {
+	webhooks []*webhook
+}
+
+// webhook delivers to one sink
+type webhook struct {
+	url        string
+	sink       string
+	token      string
+	client     *http.Client
+	maxRetries int
+	retryDelay time.Duration
+	queue      chan Notification
+	failed     func(sink string)
+
+	// encode builds the request body; nil sends the notification as JSON
+	encode func(Notification) ([]byte, error)
+	// check rejects a successful response whose body reports a failure; nil accepts every 2xx response
+	check func(body []byte) error
+	// throttle holds back repeated violation notifications; nil sends every one
+	throttle *violationThrottle
+
+	// pending counts the notifications queued or being delivered; idle is closed when it drops to 0, so
+	// Flush can wait for a delivery Run has already taken off the queue
+	mu      sync.Mutex
+	pending int
+	idle    chan struct{}
+}
+
+// New creates a notifier for the configured webhooks. Bearer tokens are read once, and an unreadable token
+// file is an error. failed is called with the webhook's host for every notification dropped after its
+// retries. Call Run to start delivery.
+func New(cfg config.NotificationsConfig, failed func(sink string)) (*Notifier, error) {
+	n := &Notifier{}
+	for i, webhookConfig := range cfg.Webhooks {
+		endpoint, err := url.Parse(webhookConfig.URL)
+		if err != nil {
+			return nil, fmt.Errorf("notifications.webhooks[%d].url: %w", i, err)
+		}
+
+		var token string
+		if webhookConfig.BearerTokenFile != "" {
+			data, err := os.ReadFile(webhookConfig.BearerTokenFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read notifications.webhooks[%d].bearerTokenFile: %w", i, err)
+			}
+			token = strings.TrimSpace(string(data))
+		}
+
+		timeout := webhookConfig.Timeout
+		if timeout == 0 {
+			timeout = config.DefaultWebhookTimeout
+		}
+
+		n.webhooks = append(n.webhooks, &webhook{
+			url:        webhookConfig.URL,
+			sink:       endpoint.Host,
+			token:      token,
+			client:     &http.Client{Timeout: timeout},
+			maxRetries: cfg.MaxRetries,
+			retryDelay: defaultRetryDelay,
+			queue:      make(chan Notification, queueSize),
+			failed:     failed,
+		})
+	}
+
+	if cfg.Slack.Enabled() {
+		slack, err := newSlackSink(cfg.Slack, cfg.MaxRetries, failed)
+		if err != nil {
+			return nil, err
+		}
+		n.webhooks = append(n.webhooks, slack)
+	}
+	return n, nil
+}
+
+// Notify queues a notification for every webhook without blocking. A webhook whose queue is full drops it
+// with a warning.
+func (n *Notifier) Notify(notification Notification) {
+	for _, w := range n.webhooks {
+		if w.throttle != nil && !w.throttle.allow(notification, time.Now()) {
+			klog.V(2).Infof("Not sending %s notification %s to %s: the same violations were sent recently", notification.Event, notification.TraceID, w.sink)
+			continue
+		}
+		if !w.enqueue(notification) {
+			klog.Warningf("Notification queue of %s full, dropping %s notification %s", w.sink, notification.Event, notification.TraceID)
+			w.dropped()
+		}
+	}
+}
+
+// enqueue queues a notification unless the queue is full
+func (w *webhook) enqueue(notification Notification) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	select {
+	case w.queue <- notification:
+		if w.pending == 0 {
+			w.idle = make(chan struct{})
+		}
+		w.pending++
+		return true
+	default:
+		return false
+	}
+}
+
+// done records that a notification taken off the queue was delivered or dropped
+func (w *webhook) done() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending--
+	if w.pending == 0 {
+		close(w.idle)
+	}
+}
+
+// Run delivers queued notifications until ctx is cancelled, then delivers what is left
+func (n *Notifier) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, w := range n.webhooks {
+		wg.Add(1)
+		go func(w *webhook) {
+			defer wg.Done()
+			w.run(ctx)
+		}(w)
+	}
+	wg.Wait()
+}
+
+// run delivers one webhook's queue
+func (w *webhook) run(ctx context.Context) {
+	for {
+		select {
+		case notification := <-w.queue:
+			w.deliver(ctx, notification)
+			w.done()
+		case <-ctx.Done():
+			// The run context is gone; give the final deliveries their own short deadline
+			flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			w.flush(flushCtx)
+			return
+		}
+	}
+}
+
+// Flush delivers what is queued for every webhook and returns once it is delivered, including deliveries
+// Run has already started. Notifications
+// queued after Run returned, such as on shutdown, are only delivered by Flush; ctx bounds the deliveries.
+func (n *Notifier) Flush(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, w := range n.webhooks {
+		wg.Add(1)
+		go func(w *webhook) {
+			defer wg.Done()
+			w.flush(ctx)
+		}(w)
+	}
+	wg.Wait()
+}
+
+// flush delivers one webhook's queue until nothing is queued or being delivered, or ctx is done
+func (w *webhook) flush(ctx context.Context) {
+	for {
+		w.mu.Lock()
+		idle, pending := w.idle, w.pending
+		w.mu.Unlock()
+		if pending == 0 {
+			return
+		}
+
+		select {
+		case notification := <-w.queue:
+			w.deliver(ctx, notification)
+			w.done()
+		case <-idle:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver posts a notification, retrying with exponential backoff. Notifications that still fail are
+// logged, counted and dropped.
+func (w *webhook) deliver(ctx context.Context, notification Notification) {
+	backoff := w.retryDelay
+	for attempt := 0; ; attempt++ {
+		err := w.post(ctx, notification)
+		if err == nil {
+			klog.V(2).Infof("Delivered %s notification %s to %s", notification.Event, notification.TraceID, w.sink)
+			return
+		}
+		if attempt >= w.maxRetries || ctx.Err() != nil {
+			klog.Errorf("Dropping %s notification %s to %s after %d attempts: %v", notification.Event, notification.TraceID, w.sink, attempt+1, err)
+			w.dropped()
+			return
+		}
+
+		klog.Warningf("Notification to %s failed (attempt %d), retrying in %s: %v", w.sink, attempt+1, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+		backoff *= 2
+	}
+}
+
+// post sends one notification
+func (w *webhook) post(ctx context.Context, notification Notification) error {
+	encode := w.encode
+	if encode == nil {
+		encode = func(notification Notification) ([]byte, error) { return json.Marshal(notification) }
+	}
+	body, err := encode(notification)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.token)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	if w.check != nil {
+		answer, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+		return w.check(answer)
+	}
+	return nil
+}
+
+// dropped reports a notification that was not delivered
+func (w *webhook) dropped() {
+	if w.failed != nil {
+		w.failed(w.sink)
+	}
+}
@@ -0,0 +1,412 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+)
+
+var notifiedAt = time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+// abortNotification is a notification of an aborted upgrade
+func abortNotification() Notification {
+	return Notification{
+		Event:         EventAbort,
+		Cluster:       "cluster",
+		ResourceGroup: "rg",
+		Operation:     "Upgrading",
+		Violations: []Violation{{
+			Metric:    "not_ready_nodes_percent",
+			Value:     "50% (2/4)",
+			Threshold: "25%",
+			Message:   "not_ready_nodes_percent: 50% (2/4) > 25%",
+		}},
+		TraceID:            "4bf92f3577b34da6a3ce929d0e0e4736",
+		Timestamp:          notifiedAt,
+		OperationStartedAt: notifiedAt.Add(-40 * time.Minute),
+		DiagnosticBundle:   "/diagnostics/4bf92f3577b34da6a3ce929d0e0e4736.tar.gz",
+	}
+}
+
+// TestNotificationSchema pins the payload receivers parse; encoding/json escapes < and > in strings
+func TestNotificationSchema(t *testing.T) {
+	tests := []struct {
+		name         string
+		notification func() Notification
+		want         string
+	}{
+		{
+			name:         "abort",
+			notification: abortNotification,
+			want: `{"event":"abort","cluster":"cluster","resourceGroup":"rg","operation":"Upgrading",` +
+				`"violations":[{"metric":"not_ready_nodes_percent","value":"50% (2/4)","threshold":"25%","message":"not_ready_nodes_percent: 50% (2/4) \u003e 25%"}],` +
+				`"traceId":"4bf92f3577b34da6a3ce929d0e0e4736","timestamp":"2026-10-15T12:00:00Z","operationStartedAt":"2026-10-15T11:20:00Z",` +
+				`"diagnosticBundle":"/diagnostics/4bf92f3577b34da6a3ce929d0e0e4736.tar.gz"}`,
+		},
+		{
+			name: "failed abort",
+			notification: func() Notification {
+				notification := abortNotification()
+				notification.DiagnosticBundle = ""
+				notification.AbortError = "azure write circuit is open"
+				return notification
+			},
+			want: `{"event":"abort","cluster":"cluster","resourceGroup":"rg","operation":"Upgrading",` +
+				`"violations":[{"metric":"not_ready_nodes_percent","value":"50% (2/4)","threshold":"25%","message":"not_ready_nodes_percent: 50% (2/4) \u003e 25%"}],` +
+				`"traceId":"4bf92f3577b34da6a3ce929d0e0e4736","timestamp":"2026-10-15T12:00:00Z","operationStartedAt":"2026-10-15T11:20:00Z",` +
+				`"abortError":"azure write circuit is open"}`,
+		},
+		{
+			name: "low priority warning",
+			notification: func() Notification {
+				return Notification{
+					Event: EventWarning, Priority: PriorityLow, Cluster: "cluster", ResourceGroup: "rg", Operation: "Upgrading",
+					Violations: []Violation{{Metric: "failed_jobs", Value: "2", Threshold: "2", Message: "failed_jobs: 2 >= 2 (warn)"}},
+					TraceID:    "0af7651916cd43dd8448eb211c80319c", Timestamp: notifiedAt,
+				}
+			},
+			want: `{"event":"warning","priority":"low","cluster":"cluster","resourceGroup":"rg","operation":"Upgrading",` +
+				`"violations":[{"metric":"failed_jobs","value":"2","threshold":"2","message":"failed_jobs: 2 \u003e= 2 (warn)"}],` +
+				`"traceId":"0af7651916cd43dd8448eb211c80319c","timestamp":"2026-10-15T12:00:00Z","operationStartedAt":"0001-01-01T00:00:00Z"}`,
+		},
+		{
+			name: "monitoring stopped",
+			notification: func() Notification {
+				return Notification{Event: EventMonitoringStopped, Cluster: "cluster", ResourceGroup: "rg", Operation: "Upgrading", Timestamp: notifiedAt, Reason: "SIGTERM"}
+			},
+			want: `{"event":"monitoringStopped","cluster":"cluster","resourceGroup":"rg","operation":"Upgrading","violations":null,` +
+				`"traceId":"","timestamp":"2026-10-15T12:00:00Z","operationStartedAt":"0001-01-01T00:00:00Z","reason":"SIGTERM"}`,
+		},
+		{
+			name: "operation failed",
+			notification: func() Notification {
+				return Notification{Event: EventOperationFailed, Cluster: "cluster", ResourceGroup: "rg", Operation: "Upgrading",
+					TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", Timestamp: notifiedAt, Reason: "cluster: QuotaExceeded: cores quota exceeded"}
+			},
+			want: `{"event":"operationFailed","cluster":"cluster","resourceGroup":"rg","operation":"Upgrading","violations":null,` +
+				`"traceId":"4bf92f3577b34da6a3ce929d0e0e4736","timestamp":"2026-10-15T12:00:00Z","operationStartedAt":"0001-01-01T00:00:00Z",` +
+				`"reason":"cluster: QuotaExceeded: cores quota exceeded"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := json.Marshal(test.notification())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != test.want {
+				t.Errorf("payload =\n%s\nwant\n%s", data, test.want)
+			}
+		})
+	}
+}
+
+// sink is a webhook endpoint answering each request with the next status of its script, then 200
+type sink struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+	received chan struct{}
+}
+
+// newSink starts a webhook endpoint answering with statuses in turn
+func newSink(t *testing.T, statuses ...int) *sink {
+	s := &sink{statuses: statuses, received: make(chan struct{}, 100)}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, body)
+		status := http.StatusOK
+		if len(s.statuses) > 0 {
+			status, s.statuses = s.statuses[0], s.statuses[1:]
+		}
+		s.mu.Unlock()
+		w.WriteHeader(status)
+		s.received <- struct{}{}
+	}))
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// attempts returns how many requests the sink received
+func (s *sink) attempts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+// failures counts the notifications each sink dropped
+type failures struct {
+	mu     sync.Mutex
+	bySink map[string]int
+}
+
+func (f *failures) record(sink string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.bySink == nil {
+		f.bySink = map[string]int{}
+	}
+	f.bySink[sink]++
+}
+
+func (f *failures) count(sink string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.bySink[sink]
+}
+
+// newTestNotifier creates a notifier for cfg that retries after a millisecond instead of a second
+func newTestNotifier(t *testing.T, cfg config.NotificationsConfig, failed func(string)) *Notifier {
+	t.Helper()
+	n, err := New(cfg, failed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range n.webhooks {
+		w.retryDelay = time.Millisecond
+	}
+	return n
+}
+
+// host returns the host of the sink's URL, which failures are counted by
+func host(t *testing.T, s *sink) string {
+	t.Helper()
+	endpoint, err := url.Parse(s.server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return endpoint.Host
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		statuses     []int
+		tokenFile    string
+		wantAttempts int
+		wantFailures int
+	}{
+		{name: "delivered", wantAttempts: 1},
+		{name: "delivered with a bearer token", tokenFile: tokenFile, wantAttempts: 1},
+		{name: "delivered after retries", statuses: []int{http.StatusServiceUnavailable, http.StatusInternalServerError}, wantAttempts: 3},
+		{name: "dropped after retries", statuses: []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, wantAttempts: 3, wantFailures: 1},
+		{name: "rejected", statuses: []int{http.StatusBadRequest, http.StatusBadRequest, http.StatusBadRequest}, wantAttempts: 3, wantFailures: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newSink(t, test.statuses...)
+			var failed failures
+			n := newTestNotifier(t, config.NotificationsConfig{
+				Webhooks:   []config.WebhookConfig{{URL: s.server.URL, BearerTokenFile: test.tokenFile}},
+				MaxRetries: 2,
+			}, failed.record)
+
+			n.Notify(abortNotification())
+			n.Flush(context.Background())
+
+			if got := s.attempts(); got != test.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, test.wantAttempts)
+			}
+			if got := failed.count(host(t, s)); got != test.wantFailures {
+				t.Errorf("failures counted = %d, want %d", got, test.wantFailures)
+			}
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			request := s.requests[len(s.requests)-1]
+			if request.Method != http.MethodPost || request.Header.Get("Content-Type") != "application/json" {
+				t.Errorf("request = %s with Content-Type %q, want a JSON POST", request.Method, request.Header.Get("Content-Type"))
+			}
+			wantAuthorization := ""
+			if test.tokenFile != "" {
+				wantAuthorization = "Bearer s3cret"
+			}
+			if got := request.Header.Get("Authorization"); got != wantAuthorization {
+				t.Errorf("Authorization = %q, want %q", got, wantAuthorization)
+			}
+			var received Notification
+			if err := json.Unmarshal(s.bodies[len(s.bodies)-1], &received); err != nil {
+				t.Fatal(err)
+			}
+			if received.Event != EventAbort || received.TraceID != abortNotification().TraceID || len(received.Violations) != 1 {
+				t.Errorf("payload = %+v, want the abort notification", received)
+			}
+		})
+	}
+}
+
+func TestFailingWebhookDoesNotDelayOthers(t *testing.T) {
+	hung := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-hung:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(slow.Close)
+	t.Cleanup(func() { close(hung) })
+	fast := newSink(t)
+	n := newTestNotifier(t, config.NotificationsConfig{
+		Webhooks: []config.WebhookConfig{{URL: slow.URL, Timeout: time.Minute}, {URL: fast.server.URL}},
+	}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.Run(ctx)
+	n.Notify(abortNotification())
+
+	select {
+	case <-fast.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the second webhook was not notified while the first hung")
+	}
+}
+
+func TestNotifyDropsWhenQueueIsFull(t *testing.T) {
+	s := newSink(t)
+	var failed failures
+	n := newTestNotifier(t, config.NotificationsConfig{Webhooks: []config.WebhookConfig{{URL: s.server.URL}}}, failed.record)
+
+	// Nothing delivers, so the queue fills; Notify must still return at once
+	started := time.Now()
+	for i := 0; i < queueSize+10; i++ {
+		n.Notify(abortNotification())
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("queueing took %s, want Notify never to block", elapsed)
+	}
+	if got := failed.count(host(t, s)); got != 10 {
+		t.Errorf("failures counted = %d, want the 10 notifications beyond the queue", got)
+	}
+}
+
+func TestFlushWaitsForDeliveryInFlight(t *testing.T) {
+	release := make(chan struct{})
+	arrived := make(chan struct{}, 1)
+	var delivered sync.WaitGroup
+	delivered.Add(1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+		delivered.Done()
+	}))
+	t.Cleanup(server.Close)
+	n := newTestNotifier(t, config.NotificationsConfig{Webhooks: []config.WebhookConfig{{URL: server.URL}}}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.Run(ctx)
+	n.Notify(abortNotification())
+	// Run has taken the notification off the queue and is delivering it
+	<-arrived
+
+	flushed := make(chan struct{})
+	go func() {
+		n.Flush(context.Background())
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+		t.Fatal("Flush returned while a delivery was in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-flushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Flush did not return once the delivery completed")
+	}
+	delivered.Wait()
+}
+
+func TestSlackThrottlesRepeatedViolations(t *testing.T) {
+	var mu sync.Mutex
+	posts := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		posts++
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	urlFile := filepath.Join(t.TempDir(), "webhook-url")
+	if err := os.WriteFile(urlFile, []byte(server.URL+"/services/T000/B000/XXXX\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	const period = 10 * time.Minute
+	n := newTestNotifier(t, config.NotificationsConfig{Slack: config.SlackConfig{WebhookURLFile: urlFile, Throttle: period}}, nil)
+	slack := n.webhooks[0]
+	slack.client = server.Client()
+
+	// notification returns a notification of event naming metrics during the operation started at startedAt
+	notification := func(event string, startedAt time.Time, metrics ...string) Notification {
+		notification := abortNotification()
+		notification.Event = event
+		notification.OperationStartedAt = startedAt
+		notification.Violations = nil
+		for _, metric := range metrics {
+			notification.Violations = append(notification.Violations, Violation{Metric: metric, Value: "50%", Threshold: "25%"})
+		}
+		return notification
+	}
+	started := notifiedAt.Add(-40 * time.Minute)
+	steps := []struct {
+		name         string
+		notification Notification
+		// elapsed is how much time passes since the previous step
+		elapsed   time.Duration
+		wantPosts int
+	}{
+		{name: "first violation", notification: notification(EventViolation, started, "not_ready_nodes_percent"), wantPosts: 1},
+		{name: "same violation at once", notification: notification(EventViolation, started, "not_ready_nodes_percent"), wantPosts: 1},
+		{name: "same violation within the interval", notification: notification(EventViolation, started, "not_ready_nodes_percent"),
+			elapsed: period - time.Minute, wantPosts: 1},
+		{name: "other metrics violated", notification: notification(EventViolation, started, "crashing_pods_percent", "not_ready_nodes_percent"), wantPosts: 2},
+		{name: "warning on the same metric", notification: notification(EventWarning, started, "not_ready_nodes_percent"), wantPosts: 3},
+		{name: "abort", notification: notification(EventAbort, started, "not_ready_nodes_percent"), wantPosts: 4},
+		{name: "abort again", notification: notification(EventAbort, started, "not_ready_nodes_percent"), wantPosts: 5},
+		{name: "same violation after the interval", notification: notification(EventViolation, started, "not_ready_nodes_percent"),
+			elapsed: time.Minute, wantPosts: 6},
+		{name: "same violation during the next operation", notification: notification(EventViolation, notifiedAt, "not_ready_nodes_percent"), wantPosts: 7},
+	}
+
+	for _, step := range steps {
+		// The throttle reads the wall clock; age what it sent instead of waiting
+		slack.throttle.mu.Lock()
+		for key, sent := range slack.throttle.sent {
+			slack.throttle.sent[key] = sent.Add(-step.elapsed)
+		}
+		slack.throttle.mu.Unlock()
+
+		n.Notify(step.notification)
+		n.Flush(context.Background())
+		mu.Lock()
+		got := posts
+		mu.Unlock()
+		if got != step.wantPosts {
+			t.Errorf("%s: posts = %d, want %d", step.name, got, step.wantPosts)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+)
+
+// slackPostMessageURL is the Web API method bot token messages are posted with
+const slackPostMessageURL = "https://slack.com/api/chat.postMessage"
+
+// newSlackSink creates the sink posting to Slack, through an incoming webhook or chat.postMessage. The
+// webhook URL or bot token is read once, and an unreadable file is an error.
+func newSlackSink(cfg config.SlackConfig, maxRetries int, failed func(sink string)) (*webhook, error) {
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = config.DefaultWebhookTimeout
+	}
+	w := &webhook{
+		client:     &http.Client{Timeout: timeout},
+		maxRetries: maxRetries,
+		retryDelay: defaultRetryDelay,
+		queue:      make(chan Notification, queueSize),
+		failed:     failed,
+		throttle:   &violationThrottle{period: cfg.Throttle},
+	}
+
+	if cfg.BotTokenFile != "" {
+		token, err := os.ReadFile(cfg.BotTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read notifications.slack.botTokenFile: %w", err)
+		}
+		w.url = slackPostMessageURL
+		w.token = strings.TrimSpace(string(token))
+		w.encode = func(notification Notification) ([]byte, error) {
+			return slackMessage(notification, cfg.Channel)
+		}
+		// chat.postMessage answers 200 OK to failed calls and reports the failure in the body
+		w.check = checkSlackResponse
+	} else {
+		data, err := os.ReadFile(cfg.WebhookURLFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read notifications.slack.webhookURLFile: %w", err)
+		}
+		w.url = strings.TrimSpace(string(data))
+		w.encode = func(notification Notification) ([]byte, error) {
+			return slackMessage(notification, "")
+		}
+	}
+
+	endpoint, err := url.Parse(w.url)
+	if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+		return nil, fmt.Errorf("notifications.slack: the Slack URL must be an https URL")
+	}
+	w.sink = endpoint.Host
+	return w, nil
+}
+
+// slackMessage formats a notification as a Slack message in mrkdwn. channel is only set for chat.postMessage;
+// incoming webhooks post to the channel they were created for.
+func slackMessage(notification Notification, channel string) ([]byte, error) {
+	var text strings.Builder
+	switch {
+	case notification.Event == EventAbort && notification.AbortError != "":
+		fmt.Fprintf(&text, ":x: *Failed to abort %s on %s* (%s): %s\n", notification.Operation, notification.Cluster,
+			notification.ResourceGroup, notification.AbortError)
+	case notification.Event == EventAbort:
+		fmt.Fprintf(&text, ":octagonal_sign: *Aborted %s on %s* (%s)\n", notification.Operation, notification.Cluster, notification.ResourceGroup)
+	case notification.Event == EventMonitoringStopped:
+		fmt.Fprintf(&text, ":pause_button: *Monitoring of %s on %s stopped* (%s): %s\n", notification.Operation, notification.Cluster,
+			notification.ResourceGroup, notification.Reason)
+	case notification.Event == EventOperationFailed && notification.Reason != "":
+		fmt.Fprintf(&text, ":red_circle: *%s failed on %s* (%s): %s\n", notification.Operation, notification.Cluster,
+			notification.ResourceGroup, notification.Reason)
+	case notification.Event == EventOperationFailed:
+		fmt.Fprintf(&text, ":red_circle: *%s failed on %s* (%s)\n", notification.Operation, notification.Cluster, notification.ResourceGroup)
+	case notification.Event == EventWarning:
+		fmt.Fprintf(&text, ":large_yellow_circle: *Warn thresholds crossed during %s on %s* (%s)\n", notification.Operation, notification.Cluster, notification.ResourceGroup)
+	default:
+		fmt.Fprintf(&text, ":warning: *Thresholds violated during %s on %s* (%s)\n", notification.Operation, notification.Cluster, notification.ResourceGroup)
+	}
+	for _, violation := range notification.Violations {
+		fmt.Fprintf(&text, "• `%s`: %s (threshold %s)\n", violation.Metric, violation.Value, violation.Threshold)
+	}
+	if notification.DiagnosticBundle != "" {
+		fmt.Fprintf(&text, "Diagnostic bundle: %s\n", notification.DiagnosticBundle)
+	}
+	fmt.Fprintf(&text, "Trace `%s` at %s", notification.TraceID, notification.Timestamp.UTC().Format(time.RFC3339))
+
+	return json.Marshal(struct {
+		Channel string `json:"channel,omitempty"`
+		Text    string `json:"text"`
+	}{Channel: channel, Text: text.String()})
+}
+
+// checkSlackResponse reports the error of a chat.postMessage call Slack answered with ok: false
+func checkSlackResponse(body []byte) error {
+	var answer struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &answer); err != nil {
+		return fmt.Errorf("failed to decode Slack response: %w", err)
+	}
+	if !answer.OK {
+		return fmt.Errorf("chat.postMessage failed: %s", answer.Error)
+	}
+	return nil
+}
+
+// violationThrottle holds back violation and warning notifications naming the same metrics as one of the
+// same event sent for the same operation less than period ago. Abort, monitoringStopped and
+// operationFailed notifications always pass.
+type violationThrottle struct {
+	period time.Duration
+
+	mu        sync.Mutex
+	operation string
+	sent      map[string]time.Time
+}
+
+// allow reports whether the notification may be sent, and records it when it may
+func (t *violationThrottle) allow(notification Notification, now time.Time) bool {
+	if notification.Event == EventAbort || notification.Event == EventMonitoringStopped || notification.Event == EventOperationFailed ||
+		t.period <= 0 {
+		return true
+	}
+
+	// A new operation starts with nothing sent
+	operation := notification.Operation + "@" + notification.OperationStartedAt.Format(time.RFC3339Nano)
+	metrics := make([]string, 0, len(notification.Violations))
+	for _, violation := range notification.Violations {
+		metrics = append(metrics, violation.Metric)
+	}
+	sort.Strings(metrics)
+	key := notification.Event + "/" + strings.Join(metrics, ",")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.operation != operation {
+		t.operation = operation
+		t.sent = make(map[string]time.Time)
+	}
+	if last, ok := t.sent[key]; ok && now.Sub(last) < t.period {
+		return false
+	}
+	t.sent[key] = now
+	return true
+}
@@ -0,0 +1,61 @@
+package output
+
+import (
+	"fmt"
+
+	"aks-health-monitor/pkg/controller"
+	"aks-health-monitor/pkg/metrics"
+)
+
+// Explain renders the GET /explain/last-abort decision record: the operation, the metrics and thresholds
+// that led to the abort, the suppression policies considered and the Azure result. Wide adds where each
+// metric was read from.
+func (p *Printer) Explain(explanation *controller.AbortExplanation) error {
+	if ok, err := p.structured(explanation); ok {
+		return err
+	}
+
+	tw := p.summary()
+	field(tw, "Decided", timestamp(explanation.DecidedAt))
+	operation := explanation.Operation.Type
+	if explanation.Operation.ProvisioningState != "" {
+		operation = fmt.Sprintf("%s (state %s)", operation, explanation.Operation.ProvisioningState)
+	}
+	if !explanation.Operation.StartedAt.IsZero() {
+		operation = fmt.Sprintf("%s since %s", operation, timestamp(explanation.Operation.StartedAt))
+	}
+	field(tw, "Operation", operation)
+	field(tw, "Action", string(explanation.Action))
+	if explanation.AzureResult.Succeeded {
+		field(tw, "Azure", p.paint(colorGreen, "abort succeeded"))
+	} else {
+		field(tw, "Azure", p.paint(colorRed, "abort failed: "+explanation.AzureResult.Error))
+	}
+	for _, provisioningErr := range explanation.Operation.Errors {
+		field(tw, "Provisioning error", fmt.Sprintf("%s: %s: %s", provisioningErr.Source, provisioningErr.Code, provisioningErr.Message))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	values := make([]metrics.MetricValue, 0, len(explanation.Metrics))
+	sources := make(map[metrics.MetricType]string, len(explanation.Metrics))
+	for _, metric := range explanation.Metrics {
+		values = append(values, metrics.MetricValue{Type: metric.Type, Value: metric.Value, Fraction: metric.Fraction, Offenders: metric.Offenders})
+		sources[metric.Type] = metric.Source
+	}
+	fmt.Fprintln(p.out)
+	if err := p.printMetrics(metricRows(values, explanation.Evaluations), sources); err != nil {
+		return err
+	}
+
+	if len(explanation.SuppressionChecks) == 0 {
+		return nil
+	}
+	fmt.Fprintln(p.out)
+	tw = p.table("SUPPRESSION CHECK", "SUPPRESSED", "DETAIL")
+	for _, check := range explanation.SuppressionChecks {
+		row(tw, string(check.Reason), fmt.Sprint(check.Suppressed), orDash(check.Detail))
+	}
+	return tw.Flush()
+}
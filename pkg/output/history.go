@@ -0,0 +1,49 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+
+	"aks-health-monitor/pkg/controller"
+)
+
+// History renders GET /history results, one row per cycle, oldest first. Wide adds the trace ID,
+// provisioning state and the violations or error of each cycle.
+func (p *Printer) History(results []controller.HealthCheckResult) error {
+	if ok, err := p.structured(results); ok {
+		return err
+	}
+	if len(results) == 0 {
+		_, err := fmt.Fprintln(p.out, "No health check results")
+		return err
+	}
+
+	header := []string{"TIME", p.paint(colorDefault, "HEALTH"), "OPERATION", "ACTION", "VIOLATIONS"}
+	if p.wide() {
+		header = append(header, "TRACE", "STATE", "DETAIL")
+	}
+	tw := p.table(header...)
+	for i := range results {
+		result := &results[i]
+		action := string(result.Action)
+		if result.Action == controller.ActionSuppressed {
+			action = fmt.Sprintf("suppressed (%s)", result.SuppressionReason)
+		}
+		cells := []string{
+			timestamp(result.Timestamp),
+			p.paint(healthColor(result.HealthState), result.HealthState.String()),
+			describeOperation(result),
+			action,
+			fmt.Sprint(len(result.Violations)),
+		}
+		if p.wide() {
+			detail := strings.Join(result.Violations, "; ")
+			if result.Error != "" {
+				detail = "error: " + result.Error
+			}
+			cells = append(cells, orDash(result.TraceID), orDash(result.ProvisioningState), orDash(detail))
+		}
+		row(tw, cells...)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,153 @@
+// Package output renders the controller's status, history and abort explanations for the command line,
+// as tables for people or as JSON and YAML for scripts.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Format selects how documents are rendered
+type Format string
+
+const (
+	// FormatTable renders the most useful columns as aligned tables
+	FormatTable Format = "table"
+	// FormatWide adds trace IDs, sources, offenders and skip reasons to the tables
+	FormatWide Format = "wide"
+	// FormatJSON renders the documents as the controller's API returns them
+	FormatJSON Format = "json"
+	// FormatYAML renders the same documents as YAML
+	FormatYAML Format = "yaml"
+)
+
+// ParseFormat parses an -o flag value
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(value); format {
+	case FormatTable, FormatWide, FormatJSON, FormatYAML:
+		return format, nil
+	case "":
+		return FormatTable, nil
+	default:
+		return "", fmt.Errorf("output format must be table, wide, json or yaml, got: %q", value)
+	}
+}
+
+// ANSI colors used for states and threshold markers
+const (
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorGray   = "\x1b[90m"
+
+	// colorDefault leaves the text as it is; headers of painted columns use it so they are as wide as the cells
+	colorDefault = "\x1b[39m"
+
+	colorReset = "\x1b[0m"
+)
+
+// Threshold comparison markers
+const (
+	markerOK       = "✓"
+	markerViolated = "✗"
+	markerSkipped  = "-"
+)
+
+// Printer renders documents to one writer in one format
+type Printer struct {
+	out    io.Writer
+	format Format
+	color  bool
+}
+
+// NewPrinter creates a printer. Colors are only used by the table formats.
+func NewPrinter(out io.Writer, format Format, color bool) *Printer {
+	return &Printer{out: out, format: format, color: color}
+}
+
+// ColorEnabled reports whether colors suit the file: it must be a terminal and NO_COLOR must be unset
+func ColorEnabled(file *os.File) bool {
+	if _, set := os.LookupEnv("NO_COLOR"); set {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// structured reports whether the format is JSON or YAML, and if so writes doc in it
+func (p *Printer) structured(doc interface{}) (bool, error) {
+	switch p.format {
+	case FormatJSON:
+		encoder := json.NewEncoder(p.out)
+		encoder.SetIndent("", "  ")
+		return true, encoder.Encode(doc)
+	case FormatYAML:
+		// Round-trip through JSON so field names follow the JSON tags, as in the API
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return true, err
+		}
+		var generic interface{}
+		if err := json.Unmarshal(data, &generic); err != nil {
+			return true, err
+		}
+		data, err = yaml.Marshal(generic)
+		if err != nil {
+			return true, err
+		}
+		_, err = p.out.Write(data)
+		return true, err
+	default:
+		return false, nil
+	}
+}
+
+// wide reports whether the extra columns are wanted
+func (p *Printer) wide() bool {
+	return p.format == FormatWide
+}
+
+// paint colors text when colors are enabled. Every cell of a column, header included, must be painted or
+// none, since tabwriter counts the escape codes towards the column width. All colors are five bytes long.
+func (p *Printer) paint(color, text string) string {
+	if !p.color || color == "" {
+		return text
+	}
+	return color + text + colorReset
+}
+
+// table starts an aligned table with the given header
+func (p *Printer) table(header ...string) *tabwriter.Writer {
+	tw := tabwriter.NewWriter(p.out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	return tw
+}
+
+// summary starts a block of "Name: value" lines with aligned values
+func (p *Printer) summary() *tabwriter.Writer {
+	return tabwriter.NewWriter(p.out, 0, 0, 1, ' ', 0)
+}
+
+// row writes one table row
+func row(tw *tabwriter.Writer, cells ...string) {
+	fmt.Fprintln(tw, strings.Join(cells, "\t"))
+}
+
+// field writes one "Name: value" line of a summary block
+func field(tw *tabwriter.Writer, name, value string) {
+	fmt.Fprintf(tw, "%s:\t%s\n", name, value)
+}
+
+// orDash renders empty values as a dash so table columns stay aligned
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
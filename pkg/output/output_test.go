@@ -0,0 +1,222 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/controller"
+	"aks-health-monitor/pkg/metrics"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files under testdata")
+
+// assertGolden compares got with the golden file testdata/name, rewriting the file with -update
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file (rerun with -update if the change is intended)\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+var checkedAt = time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+// abortedResult is a cycle that aborted an upgrade: one metric violated, one within its threshold, one
+// skipped and one informational
+func abortedResult() controller.HealthCheckResult {
+	return controller.HealthCheckResult{
+		Timestamp:           checkedAt,
+		TraceID:             "4bf92f3577b34da6a3ce929d0e0e4736",
+		OperationInProgress: true,
+		OperationType:       "Upgrading",
+		ProvisioningState:   "Upgrading",
+		StatusSource:        "arm",
+		Metrics: []metrics.MetricValue{
+			{Type: metrics.NotReadyNodesPercentMetric, Value: 50, Fraction: &metrics.Fraction{Numerator: 2, Denominator: 4}, Offenders: []string{"node-0", "node-1"}},
+			{Type: metrics.PendingPodsPercentMetric, Value: 10, Fraction: &metrics.Fraction{Numerator: 4, Denominator: 40}},
+			{Type: metrics.FailedJobsMetric, Value: 1},
+			{Type: metrics.CpuUsagePercentMetric, Value: 63},
+		},
+		Evaluations: []controller.ThresholdEvaluation{
+			{Metric: metrics.NotReadyNodesPercentMetric, Value: 50, Threshold: 25, Violated: true, RunbookURL: "https://runbooks.example.com/nodes"},
+			{Metric: metrics.PendingPodsPercentMetric, Value: 10, Threshold: 15},
+			{Metric: metrics.FailedJobsMetric, Value: 1, Threshold: 3, SkipReason: "disabled for Upgrading"},
+		},
+		Violations:  []string{"not_ready_nodes_percent: 50% (2/4) exceeds 25%"},
+		Action:      controller.ActionAborted,
+		HealthState: controller.HealthAbortWorthy,
+	}
+}
+
+// history is an idle cycle, the aborted cycle and a suppressed cycle that failed to collect a metric
+func history() []controller.HealthCheckResult {
+	suppressed := abortedResult()
+	suppressed.Timestamp = checkedAt.Add(time.Minute)
+	suppressed.TraceID = "0af7651916cd43dd8448eb211c80319c"
+	suppressed.Action = controller.ActionSuppressed
+	suppressed.SuppressionReason = controller.SuppressionIncompleteData
+	suppressed.Error = "nodes: the server is currently unable to handle the request"
+	return []controller.HealthCheckResult{
+		{Timestamp: checkedAt.Add(-time.Minute), Action: controller.ActionNone, HealthState: controller.HealthHealthy},
+		abortedResult(),
+		suppressed,
+	}
+}
+
+// statusDocument is a GET /status document of a controller watching the aborted upgrade
+func statusDocument(t *testing.T) map[string]interface{} {
+	t.Helper()
+	last := abortedResult()
+	data, err := json.Marshal(map[string]interface{}{
+		"mode":                "full",
+		"operationInProgress": true,
+		"currentOperation":    "Upgrading",
+		"operationStartedAt":  checkedAt.Add(-40 * time.Minute),
+		"suspectedStuck": controller.StuckOperation{
+			Operation: "Upgrading", LastProgressAt: checkedAt.Add(-25 * time.Minute), StaleFor: 25 * time.Minute,
+		},
+		"configGeneration": 3,
+		"lastResult":       &last,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func explanation() *controller.AbortExplanation {
+	result := abortedResult()
+	return &controller.AbortExplanation{
+		DecidedAt: checkedAt,
+		Operation: controller.ExplainedOperation{Type: "Upgrading", ProvisioningState: "Upgrading", StartedAt: checkedAt.Add(-40 * time.Minute)},
+		Metrics: []controller.ExplainedMetric{
+			{Type: metrics.NotReadyNodesPercentMetric, Value: 50, Fraction: &metrics.Fraction{Numerator: 2, Denominator: 4}, Source: "Kubernetes nodes: Ready condition"},
+			{Type: metrics.PendingPodsPercentMetric, Value: 10, Fraction: &metrics.Fraction{Numerator: 4, Denominator: 40}, Source: "Kubernetes pods: phase"},
+		},
+		Evaluations: result.Evaluations[:2],
+		SuppressionChecks: []controller.SuppressionCheck{
+			{Reason: controller.SuppressionAutoscalerInitiated},
+			{Reason: controller.SuppressionAbortCooldown, Detail: "no abort in the last 30m"},
+		},
+		Action:      controller.ActionAborted,
+		AzureResult: controller.AbortOutcome{Succeeded: true},
+	}
+}
+
+func precheck() *controller.PrecheckResult {
+	result := abortedResult()
+	return &controller.PrecheckResult{
+		Timestamp: checkedAt,
+		Checks: []controller.PrecheckCheck{
+			{Name: "provisioningState", Passed: true},
+			{Name: "thresholds", Detail: "not_ready_nodes_percent: 50% (2/4) exceeds 25%"},
+		},
+		Metrics:     result.Metrics[:2],
+		Evaluations: result.Evaluations[:2],
+	}
+}
+
+func TestGoldenOutput(t *testing.T) {
+	documents := []struct {
+		name  string
+		print func(p *Printer) error
+	}{
+		{name: "status", print: func(p *Printer) error { return p.Status(statusDocument(t)) }},
+		{name: "status-no-result", print: func(p *Printer) error { return p.Status(map[string]interface{}{"mode": ModeLocal}) }},
+		{name: "history", print: func(p *Printer) error { return p.History(history()) }},
+		{name: "explain", print: func(p *Printer) error { return p.Explain(explanation()) }},
+		{name: "precheck", print: func(p *Printer) error { return p.Precheck(precheck()) }},
+	}
+	formats := []struct {
+		name   string
+		format Format
+		color  bool
+	}{
+		{name: "table", format: FormatTable},
+		{name: "wide", format: FormatWide},
+		{name: "color", format: FormatTable, color: true},
+		{name: "json", format: FormatJSON},
+		{name: "yaml", format: FormatYAML},
+	}
+
+	for _, document := range documents {
+		for _, format := range formats {
+			name := document.name + "." + format.name
+			t.Run(name, func(t *testing.T) {
+				var out bytes.Buffer
+				if err := document.print(NewPrinter(&out, format.format, format.color)); err != nil {
+					t.Fatal(err)
+				}
+				assertGolden(t, name+".golden", out.Bytes())
+			})
+		}
+	}
+}
+
+func TestStructuredFormatsIgnoreColor(t *testing.T) {
+	for _, format := range []Format{FormatJSON, FormatYAML} {
+		var out bytes.Buffer
+		if err := NewPrinter(&out, format, true).History(history()); err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(out.Bytes(), []byte("\x1b[")) {
+			t.Errorf("%s output contains color escapes:\n%s", format, out.Bytes())
+		}
+	}
+}
+
+func TestEmptyHistory(t *testing.T) {
+	var out bytes.Buffer
+	if err := NewPrinter(&out, FormatTable, false).History(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "No health check results\n" {
+		t.Errorf("output = %q, want the no results message", got)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Format
+		wantErr bool
+	}{
+		{value: "", want: FormatTable},
+		{value: "table", want: FormatTable},
+		{value: "wide", want: FormatWide},
+		{value: "json", want: FormatJSON},
+		{value: "yaml", want: FormatYAML},
+		{value: "YAML", wantErr: true},
+		{value: "csv", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := ParseFormat(test.value)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error = %v, want error: %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("ParseFormat(%q) = %q, want %q", test.value, got, test.want)
+			}
+		})
+	}
+}
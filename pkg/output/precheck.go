@@ -0,0 +1,45 @@
+package output
+
+import (
+	"fmt"
+
+	"aks-health-monitor/pkg/controller"
+)
+
+// Precheck renders a GET /precheck result: the verdict, each check with the reason it failed, and the
+// metrics against the pre-check thresholds
+func (p *Printer) Precheck(result *controller.PrecheckResult) error {
+	if ok, err := p.structured(result); ok {
+		return err
+	}
+
+	tw := p.summary()
+	verdict := p.paint(colorGreen, "passed")
+	if !result.Passed {
+		verdict = p.paint(colorRed, "failed")
+	}
+	field(tw, "Pre-check", verdict)
+	field(tw, "Checked", timestamp(result.Timestamp))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(p.out)
+	tw = p.table("CHECK", p.paint(colorDefault, "RESULT"), "DETAIL")
+	for _, check := range result.Checks {
+		outcome := p.paint(colorGreen, markerOK)
+		if !check.Passed {
+			outcome = p.paint(colorRed, markerViolated)
+		}
+		row(tw, check.Name, outcome, orDash(check.Detail))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(result.Metrics) == 0 {
+		return nil
+	}
+	fmt.Fprintln(p.out)
+	return p.printMetrics(metricRows(result.Metrics, result.Evaluations), nil)
+}
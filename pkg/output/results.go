@@ -0,0 +1,129 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"aks-health-monitor/pkg/controller"
+	"aks-health-monitor/pkg/metrics"
+)
+
+// metricRow is one metric of a result with its threshold comparison
+type metricRow struct {
+	metric    metrics.MetricType
+	value     string
+	threshold string
+	marker    string
+	color     string
+	note      string
+}
+
+// metricRows pairs each collected metric with its evaluation. Metrics that were not evaluated, such as
+// informational metrics or those of idle cycles, have no threshold or marker.
+func metricRows(values []metrics.MetricValue, evaluations []controller.ThresholdEvaluation) []metricRow {
+	byMetric := make(map[metrics.MetricType]controller.ThresholdEvaluation, len(evaluations))
+	for _, evaluation := range evaluations {
+		byMetric[evaluation.Metric] = evaluation
+	}
+
+	rows := make([]metricRow, 0, len(values))
+	for _, value := range values {
+		r := metricRow{metric: value.Type, value: value.String(), threshold: "-", marker: markerSkipped, color: colorGray}
+		if evaluation, ok := byMetric[value.Type]; ok {
+			r.threshold = value.Type.FormatValue(evaluation.Threshold)
+			switch {
+			case evaluation.SkipReason != "":
+				r.note = evaluation.SkipReason
+			case evaluation.Violated:
+				r.marker, r.color = markerViolated, colorRed
+			default:
+				r.marker, r.color = markerOK, colorGreen
+			}
+		}
+		if r.note == "" && len(value.Offenders) > 0 {
+			r.note = strings.Join(value.Offenders, "; ")
+		}
+		if evaluation, ok := byMetric[value.Type]; ok && evaluation.RunbookURL != "" {
+			r.note = strings.TrimPrefix(r.note+"; runbook: "+evaluation.RunbookURL, "; ")
+		}
+		rows = append(rows, r)
+	}
+	return rows
+}
+
+// printMetrics writes the metric table of a result; wide adds each metric's note, or the source when given
+func (p *Printer) printMetrics(rows []metricRow, sources map[metrics.MetricType]string) error {
+	if len(rows) == 0 {
+		_, err := fmt.Fprintln(p.out, "No metrics collected")
+		return err
+	}
+
+	header := []string{p.paint(colorDefault, " "), "METRIC", "VALUE", "THRESHOLD"}
+	if p.wide() {
+		if sources != nil {
+			header = append(header, "SOURCE")
+		}
+		header = append(header, "NOTE")
+	}
+	tw := p.table(header...)
+	for _, r := range rows {
+		cells := []string{p.paint(r.color, r.marker), string(r.metric), r.value, r.threshold}
+		if p.wide() {
+			if sources != nil {
+				cells = append(cells, orDash(sources[r.metric]))
+			}
+			cells = append(cells, orDash(r.note))
+		}
+		row(tw, cells...)
+	}
+	return tw.Flush()
+}
+
+// healthColor colors a health state like a traffic light
+func healthColor(state controller.HealthState) string {
+	switch state {
+	case controller.HealthHealthy:
+		return colorGreen
+	case controller.HealthDegraded:
+		return colorYellow
+	default:
+		return colorRed
+	}
+}
+
+// describeAction renders the action of a result, with the suppression reason when it was suppressed
+func describeAction(result *controller.HealthCheckResult) string {
+	if result.Action == controller.ActionAborted && result.CriticalAbort != nil && result.CriticalAbort.Attempted {
+		return "aborted (critical path)"
+	}
+	if result.Action != controller.ActionSuppressed {
+		return string(result.Action)
+	}
+	if result.SuppressionDetail != "" {
+		return fmt.Sprintf("suppressed (%s: %s)", result.SuppressionReason, result.SuppressionDetail)
+	}
+	return fmt.Sprintf("suppressed (%s)", result.SuppressionReason)
+}
+
+// describeOperation renders the operation a result observed
+func describeOperation(result *controller.HealthCheckResult) string {
+	switch {
+	case result.MetricsOnly:
+		return "unknown (metrics-only)"
+	case !result.OperationInProgress:
+		return "none"
+	case result.Upgrade != nil && result.Upgrade.Class != "":
+		return fmt.Sprintf("%s (%s)", result.OperationType, result.Upgrade.Class)
+	default:
+		return result.OperationType
+	}
+}
+
+// timestamp renders a time for tables
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
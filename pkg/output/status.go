@@ -0,0 +1,89 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"aks-health-monitor/pkg/controller"
+)
+
+// ModeLocal is the status mode of a local evaluation, which has no controller behind it
+const ModeLocal = "local"
+
+// status is the part of the GET /status document the table formats render
+type status struct {
+	Mode                string                        `json:"mode"`
+	MetricsOnlyReason   string                        `json:"metricsOnlyReason"`
+	OperationInProgress bool                          `json:"operationInProgress"`
+	CurrentOperation    string                        `json:"currentOperation"`
+	OperationStartedAt  time.Time                     `json:"operationStartedAt"`
+	SuspectedStuck      *controller.StuckOperation    `json:"suspectedStuck"`
+	ConfigGeneration    int64                         `json:"configGeneration"`
+	LastResult          *controller.HealthCheckResult `json:"lastResult"`
+}
+
+// Status renders a GET /status document. JSON and YAML reproduce the whole document; the tables
+// summarise the controller and compare the last result's metrics against their thresholds.
+func (p *Printer) Status(doc map[string]interface{}) error {
+	if ok, err := p.structured(doc); ok {
+		return err
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	var s status
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("unexpected status document: %w", err)
+	}
+
+	tw := p.summary()
+	field(tw, "Mode", orDash(s.Mode))
+	if s.MetricsOnlyReason != "" {
+		field(tw, "Reason", s.MetricsOnlyReason)
+	}
+	operation := "none"
+	switch {
+	case s.Mode == ModeLocal:
+		operation = "not checked, thresholds evaluated as if one had started"
+	case s.OperationInProgress:
+		operation = s.CurrentOperation
+		if !s.OperationStartedAt.IsZero() {
+			operation = fmt.Sprintf("%s since %s", operation, timestamp(s.OperationStartedAt))
+		}
+	}
+	field(tw, "Operation", operation)
+	if stuck := s.SuspectedStuck; stuck != nil {
+		field(tw, "Suspected stuck", p.paint(colorYellow, fmt.Sprintf("no progress since %s (%s)", timestamp(stuck.LastProgressAt), stuck.StaleFor.Round(time.Minute))))
+	}
+	if p.wide() {
+		field(tw, "Config generation", fmt.Sprint(s.ConfigGeneration))
+	}
+
+	last := s.LastResult
+	if last == nil {
+		field(tw, "Last check", "none yet")
+		return tw.Flush()
+	}
+	field(tw, "Health", p.paint(healthColor(last.HealthState), last.HealthState.String()))
+	field(tw, "Action", describeAction(last))
+	checked := timestamp(last.Timestamp)
+	if p.wide() && last.TraceID != "" {
+		checked = fmt.Sprintf("%s (trace %s)", checked, last.TraceID)
+	}
+	field(tw, "Last check", checked)
+	if p.wide() && last.StatusSource != "" {
+		field(tw, "Status source", last.StatusSource)
+	}
+	if last.Error != "" {
+		field(tw, "Error", p.paint(colorRed, last.Error))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(p.out)
+	return p.printMetrics(metricRows(last.Metrics, last.Evaluations), nil)
+}
@@ -0,0 +1,79 @@
+// Package runtimeinfo resolves the identity of the running controller (its namespace and pod name)
+// so that objects the controller writes about itself land in a consistent place.
+package runtimeinfo
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ServiceAccountNamespaceFile is where Kubernetes mounts the namespace of the pod's service account
+const ServiceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// Info is the identity of the running controller
+type Info struct {
+	// Namespace the controller runs in, or "" if it could not be determined
+	Namespace string `json:"namespace"`
+
+	// PodName is the controller's pod name, falling back to the hostname
+	PodName string `json:"podName"`
+
+	// NamespaceSource records where Namespace came from, for diagnostics
+	NamespaceSource string `json:"namespaceSource"`
+}
+
+// Options controls how the identity is resolved
+type Options struct {
+	// Namespace overrides every other source when set (the --namespace flag)
+	Namespace string
+
+	// DefaultNamespace is used when no other source yields a namespace, e.g. the namespace of the
+	// kubeconfig context when running out of cluster
+	DefaultNamespace string
+
+	// ServiceAccountNamespaceFile overrides the service account namespace file path; empty uses the default
+	ServiceAccountNamespaceFile string
+}
+
+// Resolve determines the controller's identity. The namespace is taken, in order, from the override,
+// the POD_NAMESPACE environment variable (downward API), the service account namespace file and the
+// default namespace. The pod name comes from POD_NAME (downward API) or the hostname.
+func Resolve(options Options) *Info {
+	info := &Info{}
+
+	switch {
+	case options.Namespace != "":
+		info.Namespace, info.NamespaceSource = options.Namespace, "flag"
+	case os.Getenv("POD_NAMESPACE") != "":
+		info.Namespace, info.NamespaceSource = os.Getenv("POD_NAMESPACE"), "POD_NAMESPACE"
+	default:
+		path := options.ServiceAccountNamespaceFile
+		if path == "" {
+			path = ServiceAccountNamespaceFile
+		}
+		if data, err := os.ReadFile(path); err == nil && strings.TrimSpace(string(data)) != "" {
+			info.Namespace, info.NamespaceSource = strings.TrimSpace(string(data)), "service account"
+		} else if options.DefaultNamespace != "" {
+			info.Namespace, info.NamespaceSource = options.DefaultNamespace, "default"
+		}
+	}
+
+	info.PodName = os.Getenv("POD_NAME")
+	if info.PodName == "" {
+		if hostname, err := os.Hostname(); err == nil {
+			info.PodName = hostname
+		}
+	}
+
+	return info
+}
+
+// RequireNamespace returns the controller's namespace, or an error explaining how to set it.
+// Features that write objects about the controller itself call this instead of reading Namespace.
+func (i *Info) RequireNamespace(feature string) (string, error) {
+	if i == nil || i.Namespace == "" {
+		return "", fmt.Errorf("%s requires the controller namespace: set --namespace, the POD_NAMESPACE environment variable, or a namespace on the kubeconfig context", feature)
+	}
+	return i.Namespace, nil
+}
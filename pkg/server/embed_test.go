@@ -0,0 +1,101 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"aks-health-monitor/pkg/azure/fake"
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/controller"
+	"aks-health-monitor/pkg/metrics"
+	"aks-health-monitor/pkg/runtimeinfo"
+	"aks-health-monitor/pkg/server"
+
+	"github.com/prometheus/client_golang/prometheus"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+// embeddedController creates a controller the way an embedding application would, against its own registry
+func embeddedController(t *testing.T, registry prometheus.Registerer) *controller.Controller {
+	t.Helper()
+	for key, value := range map[string]string{
+		"AZURE_SUBSCRIPTION_ID": "00000000-0000-0000-0000-000000000000",
+		"AZURE_RESOURCE_GROUP":  "rg",
+		"AZURE_CLUSTER_NAME":    "cluster",
+		"AZURE_TENANT_ID":       "tenant",
+		"AZURE_CLIENT_ID":       "client",
+		"AZURE_CLIENT_SECRET":   "secret",
+	} {
+		t.Setenv(key, value)
+	}
+	cfg, err := config.LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kubeClient := kubefake.NewSimpleClientset()
+	c, err := controller.NewController(kubeClient, metrics.NewCollector(kubeClient, cfg.Collection), fake.NewClient(), cfg,
+		registry, &runtimeinfo.Info{Namespace: "aks-monitor", PodName: "aks-monitor-0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// monitorSeries returns the names of the monitor's metric families gathered from gatherer
+func monitorSeries(t *testing.T, gatherer prometheus.Gatherer) []string {
+	t.Helper()
+	families, err := gatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, family := range families {
+		if strings.HasPrefix(family.GetName(), "aks_monitor_") {
+			names = append(names, family.GetName())
+		}
+	}
+	return names
+}
+
+func TestEmbeddingOnCustomMuxAndRegistry(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	// Initializing twice against one registry, as a restarted embedded component does, must not panic
+	embeddedController(t, registry)
+	c := embeddedController(t, registry)
+	if _, err := c.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/monitor/status", server.StatusHandler(c))
+	mux.Handle("/monitor/history", server.HistoryHandler(c))
+	mux.Handle("/monitor/admin/", http.StripPrefix("/monitor/admin", server.AdminHandler(c)))
+
+	for path, check := range map[string]func(body []byte) bool{
+		"/monitor/status": func(body []byte) bool {
+			var status map[string]interface{}
+			return json.Unmarshal(body, &status) == nil
+		},
+		"/monitor/history":      func(body []byte) bool { return strings.HasPrefix(string(body), "[") },
+		"/monitor/admin/config": func(body []byte) bool { return strings.Contains(string(body), "REDACTED") },
+	} {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		if recorder.Code != http.StatusOK || !check(recorder.Body.Bytes()) {
+			t.Errorf("GET %s = %d %s", path, recorder.Code, recorder.Body.String())
+		}
+	}
+
+	if series := monitorSeries(t, registry); len(series) == 0 {
+		t.Error("no monitor series registered with the custom registry")
+	}
+	if series := monitorSeries(t, prometheus.DefaultGatherer); len(series) != 0 {
+		t.Errorf("monitor series %v registered with the global registry", series)
+	}
+}
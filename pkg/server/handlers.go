@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"aks-health-monitor/pkg/config"
+	"aks-health-monitor/pkg/controller"
+
+	"k8s.io/klog/v2"
+)
+
+// StatusProvider exposes the controller's current status
+type StatusProvider interface {
+	GetStatus() map[string]interface{}
+}
+
+// HistoryProvider exposes the controller's recent health check results
+type HistoryProvider interface {
+	GetHistory() []controller.HealthCheckResult
+}
+
+// ConfigProvider exposes the configuration currently in effect
+type ConfigProvider interface {
+	GetConfig() *config.Config
+}
+
+// ExplainProvider exposes the decision record of the most recent abort
+type ExplainProvider interface {
+	GetLastAbortExplanation() *controller.AbortExplanation
+}
+
+// SuggestionsProvider exposes the advisory thresholds learned from steady-state metrics
+type SuggestionsProvider interface {
+	GetSuggestions() *controller.SuggestionReport
+}
+
+// PrecheckProvider runs the readiness check made before starting an operation
+type PrecheckProvider interface {
+	Precheck(ctx context.Context) controller.PrecheckResult
+}
+
+// FleetProvider exposes a summary of every monitored cluster
+type FleetProvider interface {
+	FleetStatus() []controller.ClusterSummary
+}
+
+// LivenessProvider reports whether the controller should keep running
+type LivenessProvider interface {
+	Healthy() (bool, string)
+}
+
+// HealthzHandler serves 200 while the controller is healthy, and 503 with the reason once it should be restarted
+func HealthzHandler(provider LivenessProvider) http.Handler {
+	return getOnly(func(w http.ResponseWriter, r *http.Request) {
+		if healthy, reason := provider.Healthy(); !healthy {
+			http.Error(w, reason, http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("ok\n"))
+	})
+}
+
+// ReadinessProvider reports whether the controller can fulfil its purpose
+type ReadinessProvider interface {
+	Ready() (bool, string)
+}
+
+// ReadyHandler serves 200 when the controller can detect and abort operations, and 503 with the reason
+// while it runs in metrics-only mode
+func ReadyHandler(provider ReadinessProvider) http.Handler {
+	return getOnly(func(w http.ResponseWriter, r *http.Request) {
+		if ready, reason := provider.Ready(); !ready {
+			http.Error(w, reason, http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("ok\n"))
+	})
+}
+
+// StatusHandler serves the controller status as JSON
+func StatusHandler(provider StatusProvider) http.Handler {
+	return getOnly(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, provider.GetStatus())
+	})
+}
+
+// HistoryHandler serves recent health check results as JSON, oldest first
+func HistoryHandler(provider HistoryProvider) http.Handler {
+	return getOnly(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, provider.GetHistory())
+	})
+}
+
+// ExplainHandler serves the decision record of the most recent abort as JSON, or 404 if there was none
+func ExplainHandler(provider ExplainProvider) http.Handler {
+	return getOnly(func(w http.ResponseWriter, r *http.Request) {
+		explanation := provider.GetLastAbortExplanation()
+		if explanation == nil {
+			http.Error(w, "no abort has been issued", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, explanation)
+	})
+}
+
+// SuggestionsHandler serves the advisory threshold suggestions as JSON, or 404 if suggestions are disabled
+func SuggestionsHandler(provider SuggestionsProvider) http.Handler {
+	return getOnly(func(w http.ResponseWriter, r *http.Request) {
+		report := provider.GetSuggestions()
+		if report == nil {
+			http.Error(w, "threshold suggestions are disabled", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	})
+}
+
+// FleetHandler serves one summary per monitored cluster as a JSON array. Summaries are read from cached
+// state, so the endpoint stays fast and never triggers a collection.
+func FleetHandler(provider FleetProvider) http.Handler {
+	return getOnly(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, provider.FleetStatus())
+	})
+}
+
+// PrecheckHandler runs the pre-check and serves its result as JSON, with 200 when it passed and 412 when
+// it failed, so a pipeline can gate an operation on the status code alone
+func PrecheckHandler(provider PrecheckProvider) http.Handler {
+	return getOnly(func(w http.ResponseWriter, r *http.Request) {
+		result := provider.Precheck(r.Context())
+		statusCode := http.StatusOK
+		if !result.Passed {
+			statusCode = http.StatusPreconditionFailed
+		}
+		writeJSON(w, statusCode, result)
+	})
+}
+
+// AdminHandler serves administrative endpoints. Paths are relative to where the handler is mounted.
+func AdminHandler(provider ConfigProvider) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/config", getOnly(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, provider.GetConfig().Redacted())
+	}))
+	mux.Handle("/thresholds", getOnly(func(w http.ResponseWriter, r *http.Request) {
+		cfg := provider.GetConfig()
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"thresholds":         cfg.Thresholds,
+			"criticalThresholds": cfg.CriticalThresholds,
+		})
+	}))
+	return mux
+}
+
+// getOnly rejects requests that are not GET or HEAD
+func getOnly(handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler(w, r)
+	})
+}
+
+// writeJSON writes a JSON response body with the given status code
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		klog.Errorf("Failed to encode response: %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+
+	"k8s.io/klog/v2"
+)
+
+// DefaultShutdownTimeout bounds how long each listener is given to drain on shutdown
+const DefaultShutdownTimeout = 5 * time.Second
+
+// listener is a named HTTP server managed by the Manager
+type listener struct {
+	name   string
+	server *http.Server
+}
+
+// Manager owns the lifecycle of every HTTP listener the controller exposes so that
+// startup errors are reported consistently and shutdown happens in a fixed order
+type Manager struct {
+	listeners       []*listener
+	shutdownTimeout time.Duration
+}
+
+// NewManager creates a new listener lifecycle manager
+func NewManager(shutdownTimeout time.Duration) *Manager {
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = DefaultShutdownTimeout
+	}
+	return &Manager{
+		shutdownTimeout: shutdownTimeout,
+	}
+}
+
+// Add registers a listener. An empty address disables the listener, so none of its routes are served.
+// The listener serves TLS and requires a bearer token as its security settings configure; requests to the
+// open paths are served without the token. Certificates and tokens are read once, here.
+func (m *Manager) Add(name, address string, handler http.Handler, security config.ListenerSecurityConfig, open ...string) error {
+	if address == "" {
+		klog.V(2).Infof("Listener %s disabled (no bind address configured)", name)
+		return nil
+	}
+
+	if security.BearerTokenFile != "" {
+		data, err := os.ReadFile(security.BearerTokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read %s listener bearer token: %w", name, err)
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return fmt.Errorf("%s listener bearer token file %s is empty", name, security.BearerTokenFile)
+		}
+		handler = requireBearerToken(handler, token, open)
+	}
+
+	server := &http.Server{
+		Addr:              address,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if security.TLSCertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(security.TLSCertFile, security.TLSKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load %s listener certificate: %w", name, err)
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12}
+	}
+
+	m.listeners = append(m.listeners, &listener{name: name, server: server})
+	return nil
+}
+
+// Len returns the number of registered listeners
+func (m *Manager) Len() int {
+	return len(m.listeners)
+}
+
+// Run binds every registered listener, serves until the context is cancelled and then
+// shuts the listeners down in reverse registration order. Bind failures are returned
+// before any listener starts serving.
+func (m *Manager) Run(ctx context.Context) error {
+	if len(m.listeners) == 0 {
+		return nil
+	}
+
+	// Bind all sockets first so a malformed or busy address fails startup cleanly
+	netListeners := make([]net.Listener, 0, len(m.listeners))
+	for _, l := range m.listeners {
+		netListener, err := net.Listen("tcp", l.server.Addr)
+		if err != nil {
+			for _, bound := range netListeners {
+				bound.Close()
+			}
+			return fmt.Errorf("failed to bind %s listener on %s: %w", l.name, l.server.Addr, err)
+		}
+		if l.server.TLSConfig != nil {
+			netListener = tls.NewListener(netListener, l.server.TLSConfig)
+		}
+		netListeners = append(netListeners, netListener)
+	}
+
+	errCh := make(chan error, len(m.listeners))
+	var wg sync.WaitGroup
+	for i, l := range m.listeners {
+		wg.Add(1)
+		go func(l *listener, netListener net.Listener) {
+			defer wg.Done()
+			klog.Infof("Starting %s listener on %s", l.name, netListener.Addr())
+			if err := l.server.Serve(netListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errCh <- fmt.Errorf("%s listener failed: %w", l.name, err)
+			}
+		}(l, netListeners[i])
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+	case runErr = <-errCh:
+	}
+
+	m.shutdown()
+	wg.Wait()
+
+	return runErr
+}
+
+// shutdown stops the listeners in reverse registration order
+func (m *Manager) shutdown() {
+	for i := len(m.listeners) - 1; i >= 0; i-- {
+		l := m.listeners[i]
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), m.shutdownTimeout)
+		if err := l.server.Shutdown(shutdownCtx); err != nil {
+			klog.Errorf("Failed to shut down %s listener: %v", l.name, err)
+		}
+		cancel()
+		klog.Infof("Stopped %s listener", l.name)
+	}
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/config"
+)
+
+// freeAddress returns an address on the loopback host no listener is bound to, skipping the test when the
+// host has no such address family
+func freeAddress(t *testing.T, host string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		t.Skipf("loopback %s unavailable: %v", host, err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	return address
+}
+
+// get fetches url, retrying while the listener is still binding, and returns the response body
+func get(t *testing.T, url string) string {
+	t.Helper()
+	client := &http.Client{Timeout: 5 * time.Second}
+	var lastErr error
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		resp, err := client.Get(url)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+	t.Fatalf("GET %s: %v", url, lastErr)
+	return ""
+}
+
+// named answers every request with the listener's name
+func named(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, name)
+	})
+}
+
+func TestManagerServesIPv4AndIPv6Loopback(t *testing.T) {
+	for _, host := range []string{"127.0.0.1", "::1"} {
+		t.Run(host, func(t *testing.T) {
+			metricsAddress, healthAddress := freeAddress(t, host), freeAddress(t, host)
+			manager := NewManager(time.Second)
+			if err := manager.Add("metrics", metricsAddress, named("metrics"), config.ListenerSecurityConfig{}); err != nil {
+				t.Fatal(err)
+			}
+			if err := manager.Add("health", healthAddress, named("health"), config.ListenerSecurityConfig{}); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() { done <- manager.Run(ctx) }()
+
+			for address, want := range map[string]string{metricsAddress: "metrics", healthAddress: "health"} {
+				if body := get(t, "http://"+address+"/"); body != want {
+					t.Errorf("GET on %s = %q, want the %s listener", address, body, want)
+				}
+			}
+
+			cancel()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Errorf("Run = %v, want nil after cancellation", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Run did not return after cancellation")
+			}
+			if _, err := net.DialTimeout("tcp", metricsAddress, time.Second); err == nil {
+				t.Errorf("%s still accepts connections after shutdown", metricsAddress)
+			}
+		})
+	}
+}
+
+func TestManagerBindFailureServesNothing(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+
+	metricsAddress := freeAddress(t, "127.0.0.1")
+	manager := NewManager(time.Second)
+	if err := manager.Add("metrics", metricsAddress, named("metrics"), config.ListenerSecurityConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Add("admin", busy.Addr().String(), named("admin"), config.ListenerSecurityConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	err = manager.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "admin listener") {
+		t.Fatalf("Run = %v, want the admin bind failure", err)
+	}
+	if _, err := net.DialTimeout("tcp", metricsAddress, time.Second); err == nil {
+		t.Errorf("the metrics listener on %s was left bound after the admin listener failed", metricsAddress)
+	}
+}
+
+func TestManagerSkipsListenerWithoutAddress(t *testing.T) {
+	manager := NewManager(0)
+	if err := manager.Add("admin", "", named("admin"), config.ListenerSecurityConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if manager.Len() != 0 {
+		t.Errorf("registered %d listeners, want the one without an address disabled", manager.Len())
+	}
+	if err := manager.Run(context.Background()); err != nil {
+		t.Errorf("Run = %v, want nil with no listeners", err)
+	}
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// uiAssets are the page, script and stylesheet of the status UI; nothing is loaded from elsewhere
+//
+//go:embed ui
+var uiAssets embed.FS
+
+// uiContentSecurityPolicy only lets the page load its own assets and call the endpoints next to it
+const uiContentSecurityPolicy = "default-src 'self'; frame-ancestors 'none'; base-uri 'none'; form-action 'none'"
+
+// UIHandler serves the status UI. Paths are relative to where the handler is mounted. The page renders
+// GET /status, /history and /explain/last-abort, which must be served from the parent path, and has no
+// actions, so it needs no more access than those endpoints. It responds 404 unless server.ui is enabled
+// in the configuration currently in effect.
+func UIHandler(provider ConfigProvider) http.Handler {
+	assets, err := fs.Sub(uiAssets, "ui")
+	if err != nil {
+		panic(err) // the embedded directory is fixed at build time
+	}
+	files := http.FileServer(http.FS(assets))
+
+	return getOnly(func(w http.ResponseWriter, r *http.Request) {
+		if !provider.GetConfig().Server.UI {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Security-Policy", uiContentSecurityPolicy)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "no-cache")
+		files.ServeHTTP(w, r)
+	})
+}
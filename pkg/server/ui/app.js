@@ -0,0 +1,215 @@
+// Renders the controller's /status, /history and /explain/last-abort documents and polls them for
+// changes. Values from the controller are only ever inserted as text, never as markup.
+"use strict";
+
+const healthStates = [
+  { name: "healthy", className: "ok", color: "#1a7f37" },
+  { name: "degraded", className: "warn", color: "#9a6700" },
+  { name: "abort-worthy", className: "bad", color: "#cf222e" },
+];
+const minRefreshMs = 5000;
+const nanosecondsPerMs = 1e6;
+
+let refreshMs = 10000;
+
+function el(tag, text, className) {
+  const node = document.createElement(tag);
+  if (text !== undefined) {
+    node.textContent = text;
+  }
+  if (className) {
+    node.className = className;
+  }
+  return node;
+}
+
+function healthState(value) {
+  return healthStates[value] || { name: "unknown", className: "muted", color: "#656d76" };
+}
+
+function formatValue(metric, value) {
+  return metric.endsWith("_percent") ? `${value}%` : String(value);
+}
+
+function formatTime(value) {
+  if (!value || value.startsWith("0001-")) {
+    return "-";
+  }
+  return new Date(value).toLocaleString();
+}
+
+function formatDuration(nanoseconds) {
+  const minutes = Math.round(nanoseconds / nanosecondsPerMs / 60000);
+  return minutes < 60 ? `${minutes}m` : `${Math.floor(minutes / 60)}h${minutes % 60}m`;
+}
+
+function fillList(list, entries) {
+  list.replaceChildren();
+  for (const [name, value, className] of entries) {
+    list.append(el("dt", name), el("dd", value, className));
+  }
+}
+
+async function fetchJSON(path) {
+  const response = await fetch(path, { cache: "no-store" });
+  if (response.status === 404) {
+    return null;
+  }
+  if (!response.ok) {
+    throw new Error(`${path} returned ${response.status}`);
+  }
+  return response.json();
+}
+
+function renderSummary(status) {
+  const entries = [["Mode", status.metricsOnlyReason ? `${status.mode} (${status.metricsOnlyReason})` : status.mode]];
+  let operation = "none";
+  if (status.operationInProgress) {
+    operation = status.currentOperation;
+    if (status.operationStartedAt) {
+      operation += ` since ${formatTime(status.operationStartedAt)}`;
+    }
+  }
+  entries.push(["Operation", operation]);
+  if (status.suspectedStuck) {
+    const stuck = status.suspectedStuck;
+    entries.push(["Suspected stuck", `no progress since ${formatTime(stuck.lastProgressAt)} (${formatDuration(stuck.staleFor)})`, "warn"]);
+  }
+
+  const last = status.lastResult;
+  if (last) {
+    const state = healthState(last.healthState);
+    entries.push(["Health", state.name, state.className]);
+    let action = last.action;
+    if (action === "suppressed") {
+      action += ` (${last.suppressionReason}${last.suppressionDetail ? ": " + last.suppressionDetail : ""})`;
+    }
+    entries.push(["Action", action], ["Last check", formatTime(last.timestamp)]);
+    if (last.error) {
+      entries.push(["Error", last.error, "bad"]);
+    }
+  } else {
+    entries.push(["Last check", "none yet"]);
+  }
+  fillList(document.getElementById("summary"), entries);
+}
+
+function renderMetrics(last) {
+  const body = document.querySelector("#metrics tbody");
+  body.replaceChildren();
+  const values = (last && last.metrics) || [];
+  document.getElementById("metrics-empty").hidden = values.length > 0;
+  document.getElementById("metrics").hidden = values.length === 0;
+
+  const evaluations = new Map(((last && last.evaluations) || []).map((evaluation) => [evaluation.metric, evaluation]));
+  for (const metric of values) {
+    const evaluation = evaluations.get(metric.type);
+    let marker = el("td", "-", "muted");
+    let threshold = "-";
+    const notes = [];
+    if (evaluation) {
+      threshold = formatValue(metric.type, evaluation.threshold);
+      if (evaluation.skipReason) {
+        notes.push(evaluation.skipReason);
+      } else if (evaluation.violated) {
+        marker = el("td", "✗", "bad");
+      } else {
+        marker = el("td", "✓", "ok");
+      }
+      if (evaluation.description) {
+        notes.push(evaluation.description);
+      }
+    }
+    if (metric.offenders && metric.offenders.length > 0) {
+      notes.push(metric.offenders.join("; "));
+    }
+
+    let value = formatValue(metric.type, metric.value);
+    if (metric.fraction) {
+      value += ` (${metric.fraction.numerator}/${metric.fraction.denominator})`;
+    }
+
+    const note = el("td", notes.join(" — "));
+    if (evaluation && evaluation.runbookURL) {
+      const link = el("a", "runbook");
+      link.href = evaluation.runbookURL;
+      link.rel = "noopener noreferrer";
+      note.append(notes.length > 0 ? " " : "", link);
+    }
+
+    const row = el("tr");
+    row.append(marker, el("td", metric.type), el("td", value), el("td", threshold), note);
+    body.append(row);
+  }
+}
+
+function renderHistory(results) {
+  const svg = document.getElementById("sparkline");
+  svg.replaceChildren();
+  results = results || [];
+  document.getElementById("history-count").textContent = String(results.length);
+
+  const namespace = "http://www.w3.org/2000/svg";
+  const width = 600 / Math.max(results.length, 1);
+  results.forEach((result, i) => {
+    const state = healthState(result.healthState);
+    const height = 20 * ((result.healthState || 0) + 1);
+    const bar = document.createElementNS(namespace, "rect");
+    bar.setAttribute("x", String(i * width));
+    bar.setAttribute("y", String(60 - height));
+    bar.setAttribute("width", String(Math.max(width - 1, 1)));
+    bar.setAttribute("height", String(height));
+    bar.setAttribute("fill", state.color);
+    const title = document.createElementNS(namespace, "title");
+    title.textContent = `${formatTime(result.timestamp)}: ${state.name}, ${result.action}`;
+    bar.append(title);
+    svg.append(bar);
+  });
+}
+
+function renderAbort(explanation) {
+  const list = document.getElementById("abort");
+  document.getElementById("abort-none").hidden = explanation !== null;
+  list.hidden = explanation === null;
+  if (explanation === null) {
+    return;
+  }
+
+  const violations = (explanation.evaluations || []).filter((evaluation) => evaluation.violated).map((evaluation) => evaluation.violation);
+  const azure = explanation.azureResult.succeeded ? ["succeeded", "ok"] : [`failed: ${explanation.azureResult.error}`, "bad"];
+  fillList(list, [
+    ["Decided", formatTime(explanation.decidedAt)],
+    ["Operation", explanation.operation.type],
+    ["Violations", violations.join("; ") || "-"],
+    ["Azure abort", azure[0], azure[1]],
+  ]);
+}
+
+async function refresh() {
+  const error = document.getElementById("error");
+  try {
+    const [status, history, explanation] = await Promise.all([
+      fetchJSON("../status"),
+      fetchJSON("../history"),
+      fetchJSON("../explain/last-abort"),
+    ]);
+    if (status === null) {
+      throw new Error("the status endpoint is not served next to the UI");
+    }
+    renderSummary(status);
+    renderMetrics(status.lastResult);
+    renderHistory(history);
+    renderAbort(explanation);
+    if (status.pollInterval) {
+      refreshMs = Math.max(minRefreshMs, status.pollInterval / nanosecondsPerMs);
+    }
+    error.hidden = true;
+    document.getElementById("refreshed").textContent = `updated ${new Date().toLocaleTimeString()}`;
+  } catch (err) {
+    error.textContent = `Failed to refresh: ${err.message}`;
+    error.hidden = false;
+  }
+  setTimeout(refresh, refreshMs);
+}
+
+refresh();
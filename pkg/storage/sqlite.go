@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	// Registers the pure-Go "sqlite" driver, so the controller image needs no cgo
+	_ "modernc.org/sqlite"
+)
+
+// migrations are applied in order; the database's user_version is the number already applied.
+// Append new migrations, never edit released ones.
+var migrations = []string{
+	`CREATE TABLE results (
+		id        INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp INTEGER NOT NULL,
+		trace_id  TEXT    NOT NULL,
+		data      BLOB    NOT NULL
+	)`,
+	`CREATE INDEX results_timestamp ON results (timestamp)`,
+	`CREATE TABLE state (
+		key     TEXT    PRIMARY KEY,
+		updated INTEGER NOT NULL,
+		data    BLOB    NOT NULL
+	)`,
+}
+
+// SQLiteStore stores results in an embedded SQLite database file
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens or creates the database at path and applies pending schema migrations
+func NewSQLiteStore(ctx context.Context, path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database %s: %w", path, err)
+	}
+	// SQLite allows one writer; a single connection serialises access instead of failing with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate SQLite database %s: %w", path, err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// migrate applies the migrations the database has not seen yet, each in its own transaction
+func migrate(ctx context.Context, db *sql.DB) error {
+	var version int
+	if err := db.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("database schema version %d is newer than this controller supports (%d)", version, len(migrations))
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		// PRAGMA does not accept bound parameters
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Save implements Store
+func (s *SQLiteStore) Save(ctx context.Context, record Record) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO results (timestamp, trace_id, data) VALUES (?, ?, ?)`,
+		record.Timestamp.UnixNano(), record.TraceID, record.Data)
+	return err
+}
+
+// List implements Store
+func (s *SQLiteStore) List(ctx context.Context, limit int) ([]Record, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT timestamp, trace_id, data FROM results ORDER BY timestamp DESC, id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var record Record
+		var timestamp int64
+		if err := rows.Scan(&timestamp, &record.TraceID, &record.Data); err != nil {
+			return nil, err
+		}
+		record.Timestamp = time.Unix(0, timestamp).UTC()
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	reverse(records)
+	return records, nil
+}
+
+// Prune implements Store
+func (s *SQLiteStore) Prune(ctx context.Context, before time.Time) (int, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM results WHERE timestamp < ?`, before.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
+
+// SaveState implements Store
+func (s *SQLiteStore) SaveState(ctx context.Context, key string, data []byte) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO state (key, updated, data) VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET updated = excluded.updated, data = excluded.data`,
+		key, time.Now().UnixNano(), data)
+	return err
+}
+
+// LoadState implements Store
+func (s *SQLiteStore) LoadState(ctx context.Context, key string) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, `SELECT data FROM state WHERE key = ?`, key).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// Close implements Store
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// reverse turns a newest-first listing into the oldest-first order List returns
+func reverse(records []Record) {
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+}
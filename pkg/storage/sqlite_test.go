@@ -0,0 +1,68 @@
+package storage_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/storage"
+	"aks-health-monitor/pkg/storage/storagetest"
+)
+
+// openSQLite opens the database at path, closing it when the test ends
+func openSQLite(t *testing.T, path string) *storage.SQLiteStore {
+	t.Helper()
+	store, err := storage.NewSQLiteStore(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSQLiteStore(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Store {
+		return openSQLite(t, filepath.Join(t.TempDir(), "history.db"))
+	})
+}
+
+func TestSQLiteStoreSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	store := openSQLite(t, path)
+	now := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	if err := store.Save(context.Background(), storage.Record{Timestamp: now, TraceID: "trace-0", Data: []byte("{}")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopening applies no migration twice and keeps the results of the previous run
+	records, err := openSQLite(t, path).List(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].TraceID != "trace-0" || !records[0].Timestamp.Equal(now) {
+		t.Errorf("records after reopening = %+v, want the saved result", records)
+	}
+}
+
+func TestSQLiteStoreRefusesNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`PRAGMA user_version = 99`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	_, err = storage.NewSQLiteStore(context.Background(), path)
+	if err == nil || !strings.Contains(err.Error(), "newer than this controller supports") {
+		t.Errorf("error = %v, want the newer schema refused", err)
+	}
+}
@@ -0,0 +1,39 @@
+// Package storage persists health check results beyond the controller's in-memory history, so they
+// survive restarts and can be analysed over long periods. It also keeps the small pieces of learned
+// controller state that must survive restarts.
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// Record is one stored health check result
+type Record struct {
+	Timestamp time.Time
+	TraceID   string
+
+	// Data is the JSON-encoded result; the store does not interpret it
+	Data []byte
+}
+
+// Store persists health check results. Implementations must be safe for concurrent use.
+type Store interface {
+	// Save stores one result
+	Save(ctx context.Context, record Record) error
+
+	// List returns the most recent results, at most limit of them, oldest first
+	List(ctx context.Context, limit int) ([]Record, error)
+
+	// Prune deletes results older than before and returns how many were deleted
+	Prune(ctx context.Context, before time.Time) (int, error)
+
+	// SaveState stores a small named blob of controller state, replacing any earlier value
+	SaveState(ctx context.Context, key string, data []byte) error
+
+	// LoadState returns the named state blob, or nil when it was never saved
+	LoadState(ctx context.Context, key string) ([]byte, error)
+
+	// Close releases the store's resources
+	Close() error
+}
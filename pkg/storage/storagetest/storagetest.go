@@ -0,0 +1,117 @@
+// Package storagetest checks that a storage.Store implementation behaves as the controller relies on,
+// so every backend is held to the same contract.
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"aks-health-monitor/pkg/storage"
+)
+
+// Run exercises a store implementation. newStore returns an empty store; each subtest gets its own.
+func Run(t *testing.T, newStore func(t *testing.T) storage.Store) {
+	t.Run("list returns the most recent records oldest first", func(t *testing.T) {
+		store := newStore(t)
+		base := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+		for i := 0; i < 5; i++ {
+			save(t, store, record(base.Add(time.Duration(i)*time.Minute), fmt.Sprintf("trace-%d", i)))
+		}
+
+		records := list(t, store, 3)
+		if got := traceIDs(records); got != "trace-2,trace-3,trace-4" {
+			t.Errorf("List(3) = %s, want the 3 most recent oldest first", got)
+		}
+		if !records[0].Timestamp.Equal(base.Add(2*time.Minute)) || string(records[0].Data) != `{"traceId":"trace-2"}` {
+			t.Errorf("record = %s %s, want the saved timestamp and data", records[0].Timestamp, records[0].Data)
+		}
+		if got := traceIDs(list(t, store, 10)); got != "trace-0,trace-1,trace-2,trace-3,trace-4" {
+			t.Errorf("List(10) = %s, want every record", got)
+		}
+	})
+
+	t.Run("records of the same instant are kept apart", func(t *testing.T) {
+		store := newStore(t)
+		now := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+		save(t, store, record(now, "trace-a"))
+		save(t, store, record(now, "trace-b"))
+		if records := list(t, store, 10); len(records) != 2 {
+			t.Errorf("List = %s, want both records", traceIDs(records))
+		}
+	})
+
+	t.Run("prune deletes records older than the cutoff", func(t *testing.T) {
+		store := newStore(t)
+		base := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+		for i := 0; i < 4; i++ {
+			save(t, store, record(base.Add(time.Duration(i)*time.Hour), fmt.Sprintf("trace-%d", i)))
+		}
+
+		deleted, err := store.Prune(context.Background(), base.Add(2*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deleted != 2 {
+			t.Errorf("Prune deleted %d, want 2", deleted)
+		}
+		if got := traceIDs(list(t, store, 10)); got != "trace-2,trace-3" {
+			t.Errorf("List after Prune = %s, want the records at or after the cutoff", got)
+		}
+	})
+
+	t.Run("state is replaced and kept apart from results", func(t *testing.T) {
+		store := newStore(t)
+		ctx := context.Background()
+		data, err := store.LoadState(ctx, "baselines")
+		if err != nil || data != nil {
+			t.Fatalf("LoadState of a missing key = %q, %v, want nil", data, err)
+		}
+		for _, value := range []string{`{"version":1}`, `{"version":2}`} {
+			if err := store.SaveState(ctx, "baselines", []byte(value)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		data, err = store.LoadState(ctx, "baselines")
+		if err != nil || string(data) != `{"version":2}` {
+			t.Errorf("LoadState = %q, %v, want the latest value", data, err)
+		}
+		if records := list(t, store, 10); len(records) != 0 {
+			t.Errorf("List = %s, want state out of the results", traceIDs(records))
+		}
+	})
+}
+
+// record returns a record whose data names its trace ID
+func record(timestamp time.Time, traceID string) storage.Record {
+	return storage.Record{Timestamp: timestamp, TraceID: traceID, Data: []byte(fmt.Sprintf(`{"traceId":%q}`, traceID))}
+}
+
+func save(t *testing.T, store storage.Store, record storage.Record) {
+	t.Helper()
+	if err := store.Save(context.Background(), record); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func list(t *testing.T, store storage.Store, limit int) []storage.Record {
+	t.Helper()
+	records, err := store.List(context.Background(), limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+// traceIDs lists the trace IDs of records in order, comma-separated
+func traceIDs(records []storage.Record) string {
+	ids := ""
+	for i, record := range records {
+		if i > 0 {
+			ids += ","
+		}
+		ids += record.TraceID
+	}
+	return ids
+}
@@ -0,0 +1,64 @@
+// Package timeutil guards duration logic against clock problems.
+//
+// Intervals between two of the controller's own time.Now() readings use Go's monotonic clock, so NTP
+// steps cannot make them negative. That only holds while the readings keep their monotonic part: do not
+// pass them through UTC, Local, In or Round(0), and do not rebuild them from stored or serialised values.
+// Timestamps set by the API server, kubelets, Azure or read back from storage come from other clocks,
+// so durations computed from them can be negative or implausibly large. Elapsed and Plausible handle those.
+package timeutil
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// warningInterval limits how often one kind of skew is logged, as skewed timestamps tend to repeat every cycle
+const warningInterval = 10 * time.Minute
+
+var warnings = struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}{last: make(map[string]time.Time)}
+
+// Elapsed returns to minus from. A negative result means the clocks that produced the two timestamps
+// disagree, so it is clamped to zero with a warning; what names the duration in that warning.
+func Elapsed(what string, from, to time.Time) time.Duration {
+	elapsed := to.Sub(from)
+	if elapsed < 0 {
+		warn(what, "Clock skew: %s is negative (%s from %s to %s), using zero",
+			what, elapsed, from.Format(time.RFC3339Nano), to.Format(time.RFC3339Nano))
+		return 0
+	}
+	return elapsed
+}
+
+// Plausible reports whether a timestamp that should be recent is set and at most maxAge before now.
+// Older timestamps are treated as missing, with a warning; they usually come from a clock that was far off
+// when they were written. Timestamps after now are plausible, since the writer's clock may simply be ahead.
+func Plausible(what string, t, now time.Time, maxAge time.Duration) bool {
+	if t.IsZero() {
+		return false
+	}
+	if age := now.Sub(t); age > maxAge {
+		warn(what, "Ignoring %s of %s: %s old, beyond the plausible %s", what, t.Format(time.RFC3339), age.Round(time.Second), maxAge)
+		return false
+	}
+	return true
+}
+
+// warn logs a skew warning unless the same kind was logged within the warning interval
+func warn(what, format string, args ...interface{}) {
+	now := time.Now()
+	warnings.mu.Lock()
+	last, seen := warnings.last[what]
+	if seen && now.Sub(last) < warningInterval {
+		warnings.mu.Unlock()
+		return
+	}
+	warnings.last[what] = now
+	warnings.mu.Unlock()
+
+	klog.Warningf(format, args...)
+}
@@ -0,0 +1,72 @@
+package timeutil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestElapsed(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		from time.Time
+		want time.Duration
+	}{
+		{name: "forward", from: now.Add(-90 * time.Second), want: 90 * time.Second},
+		{name: "same instant", from: now, want: 0},
+		{name: "skewed ahead", from: now.Add(3 * time.Second), want: 0},
+		{name: "stepped back by years", from: now.AddDate(3, 0, 0), want: 0},
+		{name: "implausibly large is kept", from: now.AddDate(-3, 0, 0), want: now.Sub(now.AddDate(-3, 0, 0))},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Elapsed("test "+test.name, test.from, now); got != test.want {
+				t.Errorf("Elapsed = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPlausible(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	const maxAge = 24 * time.Hour
+	tests := []struct {
+		name      string
+		timestamp time.Time
+		want      bool
+	}{
+		{name: "unset", timestamp: time.Time{}, want: false},
+		{name: "recent", timestamp: now.Add(-time.Hour), want: true},
+		{name: "at the bound", timestamp: now.Add(-maxAge), want: true},
+		{name: "beyond the bound", timestamp: now.Add(-maxAge - time.Second), want: false},
+		{name: "writer clock ahead", timestamp: now.Add(time.Hour), want: true},
+		{name: "epoch", timestamp: time.Unix(0, 0), want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Plausible("test "+test.name, test.timestamp, now, maxAge); got != test.want {
+				t.Errorf("Plausible(%s) = %v, want %v", test.timestamp, got, test.want)
+			}
+		})
+	}
+}
+
+func TestWarningsAreRateLimited(t *testing.T) {
+	what := "test rate limit"
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	Elapsed(what, now.Add(time.Second), now)
+
+	warnings.mu.Lock()
+	first := warnings.last[what]
+	warnings.mu.Unlock()
+	Elapsed(what, now.Add(time.Second), now)
+	warnings.mu.Lock()
+	second := warnings.last[what]
+	warnings.mu.Unlock()
+
+	if first.IsZero() || !second.Equal(first) {
+		t.Errorf("last warning = %s then %s, want the repeat within %s suppressed", first, second, warningInterval)
+	}
+}